@@ -0,0 +1,145 @@
+// Package activity tracks the work the editor is waiting on, such as
+// language server and AI requests, file loads, greps and builds, for the
+// spinner on the status line.
+package activity
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interval is how often the spinner turns while there is work
+const Interval = 100 * time.Millisecond
+
+// spinner is drawn one frame per Interval
+var spinner = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// asciiSpinner is drawn instead on terminals that show only ASCII
+var asciiSpinner = []rune(`|/-\`)
+
+// task is one piece of work that has started and not finished
+type task struct {
+	id    int
+	label string
+}
+
+// Tracker keeps the tasks running. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	tasks    []task // Oldest first
+	next     int
+	onChange func()
+	ascii    bool // Whether the spinner is drawn with asciiSpinner
+}
+
+// NewTracker creates a tracker with no tasks
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// OnChange sets fn to be called, from the goroutine starting or finishing
+// it, whenever a task starts or finishes
+func (t *Tracker) OnChange(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onChange = fn
+}
+
+// SetASCII makes the spinner turn with ASCII characters, for terminals
+// that can't show braille
+func (t *Tracker) SetASCII(ascii bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ascii = ascii
+}
+
+// Start records a task labelled label, such as "lsp hover", and returns
+// the function to call when it finishes, as in
+// defer activity.Start("grep")()
+func (t *Tracker) Start(label string) func() {
+	t.mu.Lock()
+	t.next++
+	id := t.next
+	t.tasks = append(t.tasks, task{id: id, label: label})
+	onChange := t.onChange
+	t.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { t.finish(id) })
+	}
+}
+
+// finish forgets the task id
+func (t *Tracker) finish(id int) {
+	t.mu.Lock()
+	for i, task := range t.tasks {
+		if task.id == id {
+			t.tasks = append(t.tasks[:i], t.tasks[i+1:]...)
+			break
+		}
+	}
+	onChange := t.onChange
+	t.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// Busy reports whether any task is running
+func (t *Tracker) Busy() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tasks) > 0
+}
+
+// Labels returns the labels of the running tasks, oldest first, with how
+// many are running when there is more than one of a label
+func (t *Tracker) Labels() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var labels []string
+	counts := make(map[string]int)
+	for _, task := range t.tasks {
+		if counts[task.label] == 0 {
+			labels = append(labels, task.label)
+		}
+		counts[task.label]++
+	}
+	for i, label := range labels {
+		if n := counts[label]; n > 1 {
+			labels[i] = fmt.Sprintf("%s (%d)", label, n)
+		}
+	}
+	return labels
+}
+
+// Status returns the spinner as it is at now followed by the labels of the
+// running tasks, or "" when there are none
+func (t *Tracker) Status(now time.Time) string {
+	labels := t.Labels()
+	if len(labels) == 0 {
+		return ""
+	}
+	frames := spinner
+	t.mu.Lock()
+	if t.ascii {
+		frames = asciiSpinner
+	}
+	t.mu.Unlock()
+	frame := frames[now.UnixMilli()/Interval.Milliseconds()%int64(len(frames))]
+	return string(frame) + " " + strings.Join(labels, ", ")
+}
+
+// Default is the tracker the editor's work is recorded in
+var Default = NewTracker()
+
+// Start records a task in the default tracker
+func Start(label string) func() {
+	return Default.Start(label)
+}
@@ -0,0 +1,64 @@
+package activity
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	changes := 0
+	tracker.OnChange(func() { changes++ })
+
+	if tracker.Busy() || tracker.Status(time.Now()) != "" {
+		t.Fatal("expected a new tracker to be idle")
+	}
+
+	doneHover := tracker.Start("lsp hover")
+	doneAI := tracker.Start("ai openai")
+	doneAI2 := tracker.Start("ai openai")
+	if got := strings.Join(tracker.Labels(), ", "); got != "lsp hover, ai openai (2)" {
+		t.Errorf("labels = %q", got)
+	}
+	if status := tracker.Status(time.Now()); !strings.HasSuffix(status, " lsp hover, ai openai (2)") {
+		t.Errorf("status = %q", status)
+	}
+
+	doneHover()
+	doneHover() // Finishing twice doesn't finish another task
+	doneAI()
+	if got := strings.Join(tracker.Labels(), ", "); got != "ai openai" {
+		t.Errorf("labels = %q", got)
+	}
+	doneAI2()
+	if tracker.Busy() {
+		t.Error("expected the tracker to be idle once every task finished")
+	}
+	if changes != 6 {
+		t.Errorf("OnChange called %d times, expected 6", changes)
+	}
+}
+
+func TestTrackerSpinnerTurns(t *testing.T) {
+	tracker := NewTracker()
+	defer tracker.Start("grep")()
+
+	now := time.Now()
+	if tracker.Status(now) == tracker.Status(now.Add(Interval)) {
+		t.Error("expected the spinner to turn each interval")
+	}
+}
+
+func TestTrackerASCIISpinner(t *testing.T) {
+	tracker := NewTracker()
+	tracker.SetASCII(true)
+	defer tracker.Start("grep")()
+
+	now := time.Now()
+	for i := range len(asciiSpinner) {
+		if status := tracker.Status(now.Add(time.Duration(i) * Interval)); !strings.ContainsRune(`|/-\`, []rune(status)[0]) {
+			t.Errorf("status %q doesn't start with an ASCII spinner frame", status)
+		}
+	}
+}
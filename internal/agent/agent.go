@@ -0,0 +1,279 @@
+// Package agent runs an AI model in a loop where it can use tools: read
+// files, list directories, run allowed commands and propose new file
+// contents. Tools that change something wait for the user's approval.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+)
+
+// Requester sends a request to an AI model; *ai.AIManager implements it
+type Requester interface {
+	Request(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error)
+}
+
+// Tool names the model can call
+const (
+	ToolReadFile     = "read_file"
+	ToolListDir      = "list_dir"
+	ToolRunCommand   = "run_command"
+	ToolProposePatch = "propose_patch"
+)
+
+// tools describes the tools to providers that support tool use; the model
+// calls them, or else writes the call as JSON in its reply
+var tools = []ai.Tool{
+	{
+		Name:        ToolReadFile,
+		Description: "Read a file of the project",
+		InputSchema: schema([]string{"path"}, "path", "File path relative to the project root"),
+	},
+	{
+		Name:        ToolListDir,
+		Description: "List a directory of the project",
+		InputSchema: schema([]string{"path"}, "path", "Directory path relative to the project root"),
+	},
+	{
+		Name:        ToolRunCommand,
+		Description: "Run one of the allowed commands in the project root, after the user approves it",
+		InputSchema: schema([]string{"command"}, "command", "Command line to run"),
+	},
+	{
+		Name:        ToolProposePatch,
+		Description: "Replace a file of the project with new content, after the user approves it",
+		InputSchema: schema([]string{"path", "content"}, "path", "File path relative to the project root", "content", "The whole new file"),
+	},
+}
+
+// schema returns the JSON schema of an object of the string properties
+// given as name and description pairs, all of them required
+func schema(required []string, properties ...string) map[string]any {
+	props := make(map[string]any)
+	for i := 0; i+1 < len(properties); i += 2 {
+		props[properties[i]] = map[string]any{"type": "string", "description": properties[i+1]}
+	}
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+// Call is a tool call made by the model
+type Call struct {
+	Tool    string `json:"tool"`
+	Path    string `json:"path,omitempty"`    // File or directory, relative to the root
+	Command string `json:"command,omitempty"` // Command line for run_command
+	Content string `json:"content,omitempty"` // Whole new file content for propose_patch
+}
+
+// NeedsApproval reports whether the call changes something, so it waits
+// for the user's approval
+func (c Call) NeedsApproval() bool {
+	return c.Tool == ToolRunCommand || c.Tool == ToolProposePatch
+}
+
+// String describes the call for the user
+func (c Call) String() string {
+	switch c.Tool {
+	case ToolRunCommand:
+		return "run " + c.Command
+	case ToolProposePatch:
+		return fmt.Sprintf("write %s (%d lines)", c.Path, strings.Count(strings.TrimSuffix(c.Content, "\n"), "\n")+1)
+	case ToolReadFile:
+		return "read " + c.Path
+	case ToolListDir:
+		return "list " + c.Path
+	}
+	return c.Tool
+}
+
+// Message is an entry of the conversation with the model
+type Message struct {
+	Role string // "task", "assistant", "tool" or "answer"
+	Text string
+}
+
+// Options configures an agent
+type Options struct {
+	Root            string        // Directory files and commands are confined to
+	AllowedCommands []string      // Commands, or command prefixes ending at a word, run_command may run
+	MaxSteps        int           // Most model requests per task; 0 means 10
+	CommandTimeout  time.Duration // Time limit of run_command; 0 means 2 minutes
+
+	// Apply writes a proposed file, given its path relative to Root. When
+	// nil the file is written to disk.
+	Apply func(path, content string) error
+
+	// Blocked reports whether a file mustn't be read, given its full path
+	Blocked func(path string) bool
+}
+
+// Agent works on a task by asking the model what to do next until it
+// answers without calling a tool
+type Agent struct {
+	requester Requester
+	opts      Options
+	messages  []Message
+	pending   *Call
+	done      bool
+	steps     int
+}
+
+// New creates an agent for task. Call Run to start it.
+func New(requester Requester, task string, opts Options) *Agent {
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = 10
+	}
+	if opts.CommandTimeout <= 0 {
+		opts.CommandTimeout = 2 * time.Minute
+	}
+	return &Agent{requester: requester, opts: opts, messages: []Message{{Role: "task", Text: task}}}
+}
+
+// Messages returns the conversation so far
+func (a *Agent) Messages() []Message {
+	return a.messages
+}
+
+// Pending returns the tool call waiting for approval, or nil
+func (a *Agent) Pending() *Call {
+	return a.pending
+}
+
+// Done reports whether the model gave its answer or the agent stopped
+func (a *Agent) Done() bool {
+	return a.done
+}
+
+// Run asks the model for its next steps, running the tools it calls that
+// don't need approval, until it answers or calls one that does
+func (a *Agent) Run(ctx context.Context) error {
+	for !a.done && a.pending == nil {
+		if a.steps >= a.opts.MaxSteps {
+			a.done = true
+			return fmt.Errorf("stopped after %d steps", a.steps)
+		}
+		a.steps++
+
+		resp, err := a.requester.Request(ctx, ai.AIRequest{
+			Prompt:  a.transcript(),
+			Context: a.instructions(),
+			Type:    ai.RequestChat,
+			Root:    a.opts.Root,
+			Tools:   tools,
+		})
+		if err != nil {
+			a.done = true
+			return err
+		}
+		text, call, ok := readReply(resp)
+		a.messages = append(a.messages, Message{Role: "assistant", Text: text})
+
+		switch {
+		case !ok:
+			a.messages = append(a.messages, Message{Role: "answer", Text: text})
+			a.done = true
+		case call.NeedsApproval() && (call.Tool != ToolRunCommand || a.allowed(call.Command)):
+			a.pending = &call
+		default:
+			// Commands that aren't allowed fail without asking the user
+			a.messages = append(a.messages, Message{Role: "tool", Text: a.execute(ctx, call)})
+		}
+	}
+	return nil
+}
+
+// Approve runs the pending tool call and continues
+func (a *Agent) Approve(ctx context.Context) error {
+	if a.pending == nil {
+		return fmt.Errorf("nothing to approve")
+	}
+	call := *a.pending
+	a.pending = nil
+	a.messages = append(a.messages, Message{Role: "tool", Text: a.execute(ctx, call)})
+	return a.Run(ctx)
+}
+
+// Reject tells the model the pending tool call was refused, and why if
+// reason isn't empty, and continues
+func (a *Agent) Reject(ctx context.Context, reason string) error {
+	if a.pending == nil {
+		return fmt.Errorf("nothing to reject")
+	}
+	call := *a.pending
+	a.pending = nil
+	text := fmt.Sprintf("%s: the user refused", call)
+	if reason != "" {
+		text += ": " + reason
+	}
+	a.messages = append(a.messages, Message{Role: "tool", Text: text})
+	return a.Run(ctx)
+}
+
+// Stop ends the task
+func (a *Agent) Stop() {
+	a.pending = nil
+	a.done = true
+}
+
+// instructions explains the tools to the model
+func (a *Agent) instructions() string {
+	return fmt.Sprintf(`You are a programming agent working in the project at %s. To use a tool, call it, or if you can't, reply with only a JSON object, one per reply:
+{"tool": "read_file", "path": "relative/path"}
+{"tool": "list_dir", "path": "relative/path"}
+{"tool": "run_command", "command": "go test ./..."}
+{"tool": "propose_patch", "path": "relative/path", "content": "the whole new file"}
+run_command only runs these commands: %s. The user approves commands and patches first.
+The result of each tool follows in the conversation. When the task is done, reply in plain text without JSON.`,
+		a.opts.Root, strings.Join(a.opts.AllowedCommands, ", "))
+}
+
+// transcript renders the conversation for the next request
+func (a *Agent) transcript() string {
+	var b strings.Builder
+	for _, m := range a.messages {
+		switch m.Role {
+		case "task":
+			fmt.Fprintf(&b, "Task: %s\n\n", m.Text)
+		case "assistant":
+			fmt.Fprintf(&b, "You: %s\n\n", m.Text)
+		case "tool":
+			fmt.Fprintf(&b, "Tool result: %s\n\n", m.Text)
+		}
+	}
+	b.WriteString("What is your next step?")
+	return b.String()
+}
+
+// readReply returns the text of a reply for the conversation and the tool
+// call it makes, if any: the first of its tool calls, written into the
+// text as JSON, or else one found in its text
+func readReply(resp *ai.AIResponse) (string, Call, bool) {
+	if len(resp.ToolCalls) == 0 {
+		call, ok := parseCall(resp.Content)
+		return resp.Content, call, ok
+	}
+	var call Call
+	// Missing or malformed arguments fail when the tool runs
+	json.Unmarshal(resp.ToolCalls[0].Input, &call)
+	call.Tool = resp.ToolCalls[0].Name
+	data, _ := json.Marshal(call)
+	return strings.TrimSpace(resp.Content + "\n" + string(data)), call, true
+}
+
+// parseCall finds a tool call in a reply, which may wrap its JSON in a
+// code fence or text
+func parseCall(reply string) (Call, bool) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return Call{}, false
+	}
+	var call Call
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &call); err != nil || call.Tool == "" {
+		return Call{}, false
+	}
+	return call, true
+}
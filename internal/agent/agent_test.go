@@ -0,0 +1,215 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+)
+
+// scripted replies with one reply per request and records the prompts
+type scripted struct {
+	replies []string
+	prompts []string
+}
+
+func (s *scripted) Request(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+	s.prompts = append(s.prompts, req.Prompt)
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return &ai.AIResponse{Content: reply}, nil
+}
+
+func TestAgent(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "add.go"), []byte("package add\n\nfunc Add(a, b int) int { return a - b }\n"), 0644)
+
+	model := &scripted{replies: []string{
+		`{"tool": "list_dir", "path": "."}`,
+		"```json\n{\"tool\": \"read_file\", \"path\": \"add.go\"}\n```",
+		`{"tool": "propose_patch", "path": "add.go", "content": "package add\n\nfunc Add(a, b int) int { return a + b }\n"}`,
+		`{"tool": "run_command", "command": "rm -rf /"}`,
+		`{"tool": "run_command", "command": "echo fixed"}`,
+		"Add subtracted instead of adding; it is fixed.",
+	}}
+	agent := New(model, "fix Add", Options{Root: root, AllowedCommands: []string{"echo"}})
+	ctx := context.Background()
+
+	// Reading runs without asking; the patch waits for approval
+	if err := agent.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if call := agent.Pending(); call == nil || call.Tool != ToolProposePatch {
+		t.Fatalf("expected a pending patch, got %+v", call)
+	}
+	if !strings.Contains(model.prompts[2], "func Add(a, b int) int { return a - b }") || !strings.Contains(model.prompts[1], "add.go\n") {
+		t.Errorf("expected tool results in the conversation, got %q", model.prompts[2])
+	}
+
+	// A command that isn't allowed is refused without asking
+	if err := agent.Approve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "add.go")); !strings.Contains(string(data), "a + b") {
+		t.Errorf("expected the approved patch written, got %q", data)
+	}
+	if !strings.Contains(model.prompts[4], "run rm -rf /: error: command not allowed") {
+		t.Errorf("expected the command refused, got %q", model.prompts[4])
+	}
+	if call := agent.Pending(); call == nil || call.Command != "echo fixed" {
+		t.Fatalf("expected a pending command, got %+v", call)
+	}
+
+	if err := agent.Approve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(model.prompts[5], "run echo fixed:\nexit status 0\nfixed") {
+		t.Errorf("expected the command output, got %q", model.prompts[5])
+	}
+	messages := agent.Messages()
+	if !agent.Done() || messages[len(messages)-1].Role != "answer" {
+		t.Errorf("expected the agent done with an answer, got %+v", messages[len(messages)-1])
+	}
+}
+
+func TestAgent_RejectAndLimits(t *testing.T) {
+	root := t.TempDir()
+	model := &scripted{replies: []string{
+		`{"tool": "propose_patch", "path": "x.go", "content": "x"}`,
+		`{"tool": "read_file", "path": "../secret"}`,
+		`{"tool": "read_file", "path": "x.go"}`,
+	}}
+	agent := New(model, "task", Options{Root: root, MaxSteps: 3})
+	ctx := context.Background()
+
+	agent.Run(ctx)
+	err := agent.Reject(ctx, "not that file")
+	if err == nil || err.Error() != "stopped after 3 steps" {
+		t.Errorf("expected the step limit, got %v", err)
+	}
+	if !strings.Contains(model.prompts[1], "write x.go (1 lines): the user refused: not that file") {
+		t.Errorf("expected the refusal in the conversation, got %q", model.prompts[1])
+	}
+	if !strings.Contains(model.prompts[2], "read ../secret: error: path ../secret is outside the project") {
+		t.Errorf("expected paths outside the root refused, got %q", model.prompts[2])
+	}
+	if _, err := os.Stat(filepath.Join(root, "x.go")); err == nil {
+		t.Error("expected the rejected patch not to be written")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	agent := New(nil, "", Options{AllowedCommands: []string{"go test", "make"}})
+	tests := map[string]bool{
+		"go test":           true,
+		"go test ./...":     true,
+		"go testify":        false,
+		"make lint":         true,
+		"go test; rm -rf /": false,
+		"go test $(whoami)": false,
+		"go test > out.txt": false,
+		"rm -rf /":          false,
+	}
+	for command, want := range tests {
+		if got := agent.allowed(command); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+// caller answers with tool calls where the provider supports tool use
+type caller struct {
+	calls []ai.ToolCall
+	tools []ai.Tool
+}
+
+func (c *caller) Request(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+	c.tools = req.Tools
+	if len(c.calls) == 0 {
+		return &ai.AIResponse{Content: "Done."}, nil
+	}
+	call := c.calls[0]
+	c.calls = c.calls[1:]
+	return &ai.AIResponse{ToolCalls: []ai.ToolCall{call}}, nil
+}
+
+func TestAgent_ToolCalls(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644)
+	model := &caller{calls: []ai.ToolCall{
+		{ID: "1", Name: ToolReadFile, Input: []byte(`{"path": "a.txt"}`)},
+		{ID: "2", Name: ToolProposePatch, Input: []byte(`{"path": "a.txt", "content": "bye"}`)},
+	}}
+	agent := New(model, "task", Options{Root: root})
+	ctx := context.Background()
+
+	if err := agent.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(model.tools) != 4 || model.tools[0].Name != ToolReadFile {
+		t.Errorf("expected the tools sent, got %+v", model.tools)
+	}
+	if call := agent.Pending(); call == nil || call.Tool != ToolProposePatch || call.Content != "bye" {
+		t.Fatalf("expected a pending patch, got %+v", call)
+	}
+	messages := agent.Messages()
+	if !strings.Contains(messages[1].Text, `"tool":"read_file"`) || !strings.Contains(messages[2].Text, "read a.txt:\nhello") {
+		t.Errorf("expected the call and its result in the conversation, got %+v", messages)
+	}
+
+	if err := agent.Approve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "bye" || !agent.Done() {
+		t.Errorf("expected the patch written and the agent done, got %q", data)
+	}
+}
+
+func TestAgent_Confinement(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644)
+	os.WriteFile(filepath.Join(root, ".env"), []byte("KEY=1"), 0644)
+	for link, target := range map[string]string{
+		"file":     filepath.Join(outside, "secret"),
+		"dir":      outside,
+		"dangling": filepath.Join(outside, "new"),
+		"env":      ".env",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	agent := New(nil, "", Options{Root: root, Blocked: func(path string) bool {
+		return filepath.Base(path) == ".env"
+	}})
+
+	refused := []Call{
+		{Tool: ToolReadFile, Path: "file"},
+		{Tool: ToolReadFile, Path: "dir/secret"},
+		{Tool: ToolListDir, Path: "dir"},
+		{Tool: ToolProposePatch, Path: "dir/new", Content: "x"},
+		{Tool: ToolProposePatch, Path: "dangling", Content: "x"},
+		{Tool: ToolReadFile, Path: "env"},
+		{Tool: ToolProposePatch, Path: ".env", Content: "KEY=2"},
+	}
+	for _, call := range refused {
+		if result := agent.execute(context.Background(), call); !strings.Contains(result, ": error: ") {
+			t.Errorf("%s wasn't refused: %q", call, result)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 1 {
+		t.Errorf("files were written outside the project: %v", entries)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, ".env")); string(data) != "KEY=1" {
+		t.Errorf("private file written: %q", data)
+	}
+
+	// New files can still be written
+	result := agent.execute(context.Background(), Call{Tool: ToolProposePatch, Path: "new.go", Content: "package sub"})
+	if data, _ := os.ReadFile(filepath.Join(root, "new.go")); string(data) != "package sub" {
+		t.Errorf("new file not written: %q", result)
+	}
+}
@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dshills/aied/internal/runner"
+)
+
+const (
+	maxFileBytes   = 64 * 1024 // Most of a file read_file returns
+	maxOutputLines = 200       // Most lines of command output returned
+)
+
+// execute runs a tool call and returns its result for the model
+func (a *Agent) execute(ctx context.Context, call Call) string {
+	var result string
+	var err error
+	switch call.Tool {
+	case ToolReadFile:
+		result, err = a.readFile(call.Path)
+	case ToolListDir:
+		result, err = a.listDir(call.Path)
+	case ToolRunCommand:
+		result, err = a.runCommand(ctx, call.Command)
+	case ToolProposePatch:
+		result, err = a.writeFile(call.Path, call.Content)
+	default:
+		err = fmt.Errorf("unknown tool %q", call.Tool)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s: error: %v", call, err)
+	}
+	return fmt.Sprintf("%s:\n%s", call, result)
+}
+
+// resolve returns the absolute path of a path relative to the root with
+// its symlinks followed, refusing paths that are, or lead, outside it
+func (a *Agent) resolve(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("path %s must be relative to the project", path)
+	}
+	full := filepath.Join(a.opts.Root, path)
+	if !within(a.opts.Root, full) {
+		return "", fmt.Errorf("path %s is outside the project", path)
+	}
+
+	root, err := filepath.EvalSymlinks(a.opts.Root)
+	if err != nil {
+		return "", err
+	}
+	real, err := realPath(full)
+	if err != nil {
+		return "", err
+	}
+	if !within(root, real) {
+		return "", fmt.Errorf("path %s leads outside the project", path)
+	}
+	return real, nil
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath follows the symlinks of path, whose last elements, such as a
+// file about to be written, may not exist yet
+func realPath(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if _, lerr := os.Lstat(path); !errors.Is(lerr, os.ErrNotExist) {
+		// It exists but can't be followed, like a dangling symlink
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return "", err
+	}
+	real, err = realPath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(real, filepath.Base(path)), nil
+}
+
+// blocked reports whether the privacy rules keep the model off path, by
+// the name it is given or the file it resolves to
+func (a *Agent) blocked(path, real string) bool {
+	return a.opts.Blocked != nil && (a.opts.Blocked(filepath.Join(a.opts.Root, path)) || a.opts.Blocked(real))
+}
+
+func (a *Agent) readFile(path string) (string, error) {
+	full, err := a.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if a.blocked(path, full) {
+		return "", fmt.Errorf("%s is private", path)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxFileBytes {
+		return string(data[:maxFileBytes]) + "\n[truncated]", nil
+	}
+	return string(data), nil
+}
+
+func (a *Agent) listDir(path string) (string, error) {
+	full, err := a.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+func (a *Agent) writeFile(path, content string) (string, error) {
+	full, err := a.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if a.blocked(path, full) {
+		return "", fmt.Errorf("%s is private", path)
+	}
+	if a.opts.Apply != nil {
+		err = a.opts.Apply(path, content)
+	} else {
+		err = os.WriteFile(full, []byte(content), 0644)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "written", nil
+}
+
+// allowed reports whether run_command may run command: it must be one of
+// the allowed commands, optionally followed by arguments, and mustn't
+// chain or redirect commands through the shell
+func (a *Agent) allowed(command string) bool {
+	if strings.ContainsAny(command, ";&|`$<>\n") {
+		return false
+	}
+	command = strings.TrimSpace(command)
+	for _, allowed := range a.opts.AllowedCommands {
+		if command == allowed || strings.HasPrefix(command, allowed+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Agent) runCommand(ctx context.Context, command string) (string, error) {
+	if !a.allowed(command) {
+		return "", fmt.Errorf("command not allowed")
+	}
+	job, err := runner.Start(command, a.opts.Root, nil)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.opts.CommandTimeout)
+	defer cancel()
+	finished := make(chan struct{})
+	go func() {
+		job.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		job.Cancel()
+		<-finished
+	}
+
+	output := job.Output()
+	if len(output) > maxOutputLines {
+		output = append([]string{"[earlier output cut]"}, output[len(output)-maxOutputLines:]...)
+	}
+	code, err := job.Result()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("timed out: %s", strings.Join(output, "\n"))
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("exit status %d\n%s", code, strings.Join(output, "\n")), nil
+}
@@ -13,10 +13,13 @@ import (
 
 // AnthropicProvider implements the Provider interface for Anthropic Claude
 type AnthropicProvider struct {
-	apiKey  string
-	baseURL string
-	model   string
-	client  *http.Client
+	apiKey      string
+	baseURL     string
+	model       string
+	maxTokens   int      // Longest chat or analysis answer, 0 for the defaults of each
+	temperature *float64 // Unset for the API's default
+	topP        *float64
+	client      *http.Client
 }
 
 // NewAnthropicProvider creates a new Anthropic provider
@@ -51,6 +54,9 @@ func (a *AnthropicProvider) Configure(config ProviderConfig) error {
 	if config.Model != "" {
 		a.model = config.Model
 	}
+	a.maxTokens = config.MaxTokens
+	a.temperature = config.Temperature
+	a.topP = config.TopP
 	
 	// Configure client timeout from options
 	if config.Options != nil {
@@ -69,20 +75,36 @@ type anthropicMessage struct {
 }
 
 type anthropicRequest struct {
-	Model     string             `json:"model"`
-	MaxTokens int                `json:"max_tokens"`
-	Messages  []anthropicMessage `json:"messages"`
-	System    string             `json:"system,omitempty"`
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	Messages    []anthropicMessage `json:"messages"`
+	System      string             `json:"system,omitempty"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	TopP        *float64           `json:"top_p,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+}
+
+// anthropicTool describes a tool the model may call
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// anthropicContent is a block of an answer: text, or a call of a tool
+type anthropicContent struct {
+	Type  string          `json:"type"` // "text" or "tool_use"
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"` // Of the tool call
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
 }
 
 type anthropicResponse struct {
 	ID      string `json:"id"`
 	Type    string `json:"type"`
 	Role    string `json:"role"`
-	Content []struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
-	} `json:"content"`
+	Content []anthropicContent `json:"content"`
 	Model        string `json:"model"`
 	StopReason   string `json:"stop_reason"`
 	StopSequence string `json:"stop_sequence"`
@@ -96,93 +118,94 @@ type anthropicResponse struct {
 func (a *AnthropicProvider) Complete(ctx context.Context, req AIRequest) (*AIResponse, error) {
 	prompt := a.buildCompletionPrompt(req)
 	
-	anthropicReq := anthropicRequest{
-		Model:     a.model,
-		MaxTokens: 150,
-		System:    "You are a helpful code completion assistant. Provide concise, accurate code completions without explanations.",
-		Messages: []anthropicMessage{
-			{Role: "user", Content: prompt},
-		},
-	}
+	// Completions stay short whatever max_tokens says
+	anthropicReq := a.newRequest(req, 150, "You are a helpful code completion assistant. Provide concise, accurate code completions without explanations.", prompt)
 	
 	response, err := a.makeRequest(ctx, anthropicReq)
 	if err != nil {
 		return nil, err
 	}
 	
-	content := ""
-	if len(response.Content) > 0 {
-		content = response.Content[0].Text
-	}
-	
-	return &AIResponse{
-		Content:    content,
-		Confidence: 0.85, // Claude is generally very reliable
-		Provider:   string(ProviderAnthropic),
-		Model:      a.model,
-	}, nil
+	return a.newResponse(req, response, 0.85), nil // Claude is generally very reliable
 }
 
 // Chat handles general conversational requests
 func (a *AnthropicProvider) Chat(ctx context.Context, req AIRequest) (*AIResponse, error) {
 	prompt := a.buildChatPrompt(req)
 	
-	anthropicReq := anthropicRequest{
-		Model:     a.model,
-		MaxTokens: 1000,
-		System:    a.getSystemPrompt(req.Type),
-		Messages: []anthropicMessage{
-			{Role: "user", Content: prompt},
-		},
-	}
+	anthropicReq := a.newRequest(req, a.maxTokensOr(1000), a.getSystemPrompt(req.Type), prompt)
 	
 	response, err := a.makeRequest(ctx, anthropicReq)
 	if err != nil {
 		return nil, err
 	}
 	
-	content := ""
-	if len(response.Content) > 0 {
-		content = response.Content[0].Text
-	}
-	
-	return &AIResponse{
-		Content:    content,
-		Confidence: 0.9, // Claude excels at conversational tasks
-		Provider:   string(ProviderAnthropic),
-		Model:      a.model,
-	}, nil
+	return a.newResponse(req, response, 0.9), nil // Claude excels at conversational tasks
 }
 
 // Analyze provides code analysis and suggestions
 func (a *AnthropicProvider) Analyze(ctx context.Context, req AIRequest) (*AIResponse, error) {
 	prompt := a.buildAnalysisPrompt(req)
 	
-	anthropicReq := anthropicRequest{
-		Model:     a.model,
-		MaxTokens: 800,
-		System:    "You are an expert code reviewer and refactoring assistant. Provide specific, actionable suggestions with clear explanations.",
-		Messages: []anthropicMessage{
-			{Role: "user", Content: prompt},
-		},
-	}
+	anthropicReq := a.newRequest(req, a.maxTokensOr(800), "You are an expert code reviewer and refactoring assistant. Provide specific, actionable suggestions with clear explanations.", prompt)
 	
 	response, err := a.makeRequest(ctx, anthropicReq)
 	if err != nil {
 		return nil, err
 	}
 	
-	content := ""
-	if len(response.Content) > 0 {
-		content = response.Content[0].Text
+	return a.newResponse(req, response, 0.9), nil // Claude is excellent at code analysis
+}
+
+// newRequest builds the API request for req, with the configured sampling
+// and req's tools
+func (a *AnthropicProvider) newRequest(req AIRequest, maxTokens int, system, prompt string) anthropicRequest {
+	anthropicReq := anthropicRequest{
+		Model:       modelFor(req, a.model),
+		MaxTokens:   maxTokens,
+		System:      system,
+		Messages: []anthropicMessage{
+			{Role: "user", Content: prompt},
+		},
+		Temperature: a.temperature,
+		TopP:        a.topP,
 	}
-	
-	return &AIResponse{
-		Content:    content,
-		Confidence: 0.9, // Claude is excellent at code analysis
+	for _, tool := range req.Tools {
+		anthropicReq.Tools = append(anthropicReq.Tools, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	return anthropicReq
+}
+
+// maxTokensOr returns the configured max_tokens, or fallback without one
+func (a *AnthropicProvider) maxTokensOr(fallback int) int {
+	if a.maxTokens > 0 {
+		return a.maxTokens
+	}
+	return fallback
+}
+
+// newResponse collects the text blocks and tool calls of response
+func (a *AnthropicProvider) newResponse(req AIRequest, response *anthropicResponse, confidence float64) *AIResponse {
+	resp := &AIResponse{
+		Confidence: confidence,
 		Provider:   string(ProviderAnthropic),
-		Model:      a.model,
-	}, nil
+		Model:      modelFor(req, a.model),
+	}
+	var text strings.Builder
+	for _, block := range response.Content {
+		switch block.Type {
+		case "tool_use":
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Input: block.Input})
+		default:
+			text.WriteString(block.Text)
+		}
+	}
+	resp.Content = text.String()
+	return resp
 }
 
 // makeRequest makes an HTTP request to Anthropic API
@@ -209,7 +232,7 @@ func (a *AnthropicProvider) makeRequest(ctx context.Context, req anthropicReques
 	
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 	
 	var response anthropicResponse
@@ -291,6 +314,8 @@ func (a *AnthropicProvider) getSystemPrompt(reqType RequestType) string {
 		return "You are a technical documentation expert. Generate clear, comprehensive documentation that follows best practices."
 	case RequestRefactor:
 		return "You are a senior software engineer specializing in code refactoring. Suggest improvements that enhance readability, maintainability, and performance."
+	case RequestEdit:
+		return "You are an expert programmer editing code in place. Reply with only the rewritten code, keeping its indentation, without explanations or markdown fences."
 	default:
 		return "You are a helpful AI programming assistant with expertise across multiple programming languages and best practices."
 	}
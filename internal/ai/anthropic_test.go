@@ -63,10 +63,7 @@ func TestAnthropicProvider_Complete(t *testing.T) {
 		
 		// Send response
 		resp := anthropicResponse{
-			Content: []struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
-			}{
+			Content: []anthropicContent{
 				{
 					Type: "text",
 					Text: "Completed code here",
@@ -117,10 +114,7 @@ func TestAnthropicProvider_Chat(t *testing.T) {
 		}
 		
 		resp := anthropicResponse{
-			Content: []struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
-			}{
+			Content: []anthropicContent{
 				{
 					Type: "text",
 					Text: "Chat response from Claude",
@@ -154,6 +148,65 @@ func TestAnthropicProvider_Chat(t *testing.T) {
 	}
 }
 
+func TestAnthropicProvider_ToolsAndSampling(t *testing.T) {
+	var sent []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req)
+		json.NewEncoder(w).Encode(anthropicResponse{
+			StopReason: "tool_use",
+			Content: []anthropicContent{
+				{Type: "text", Text: "Reading it first."},
+				{Type: "tool_use", ID: "toolu_1", Name: "read_file", Input: json.RawMessage(`{"path":"main.go"}`)},
+			},
+		})
+	}))
+	defer server.Close()
+
+	temperature, topP := 0.0, 0.9
+	provider := NewAnthropicProvider()
+	provider.Configure(ProviderConfig{
+		APIKey:      "test-key",
+		BaseURL:     server.URL,
+		MaxTokens:   8192,
+		Temperature: &temperature,
+		TopP:        &topP,
+	})
+
+	tool := Tool{
+		Name:        "read_file",
+		Description: "Read a file of the project",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{"path": map[string]any{"type": "string"}}},
+	}
+	resp, err := provider.Chat(context.Background(), AIRequest{Prompt: "Fix main.go", Type: RequestChat, Tools: []Tool{tool}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Content != "Reading it first." || len(resp.ToolCalls) != 1 {
+		t.Fatalf("Expected the text and a tool call, got %+v", resp)
+	}
+	if call := resp.ToolCalls[0]; call.ID != "toolu_1" || call.Name != "read_file" || string(call.Input) != `{"path":"main.go"}` {
+		t.Errorf("Unexpected tool call %+v", call)
+	}
+
+	req := sent[0]
+	if req["max_tokens"] != 8192.0 || req["temperature"] != 0.0 || req["top_p"] != 0.9 {
+		t.Errorf("Expected the configured max_tokens and sampling, got %v, %v, %v", req["max_tokens"], req["temperature"], req["top_p"])
+	}
+	tools, _ := req["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "read_file" || tools[0].(map[string]any)["input_schema"] == nil {
+		t.Errorf("Expected the tool with its schema, got %v", req["tools"])
+	}
+
+	// Completions stay short, and without sampling or tools set none is sent
+	provider.Configure(ProviderConfig{APIKey: "test-key", BaseURL: server.URL, MaxTokens: 8192})
+	provider.Complete(context.Background(), AIRequest{Prompt: "func"})
+	if req := sent[1]; req["max_tokens"] != 150.0 || req["temperature"] != nil || req["tools"] != nil {
+		t.Errorf("Unexpected completion request %v", req)
+	}
+}
+
 func TestAnthropicProvider_Analyze(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var req anthropicRequest
@@ -165,10 +218,7 @@ func TestAnthropicProvider_Analyze(t *testing.T) {
 		}
 		
 		resp := anthropicResponse{
-			Content: []struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
-			}{
+			Content: []anthropicContent{
 				{
 					Type: "text",
 					Text: "Code analysis results",
@@ -223,10 +273,7 @@ func TestAnthropicProvider_ErrorHandling(t *testing.T) {
 			name: "Empty Content",
 			serverResponse: func(w http.ResponseWriter, r *http.Request) {
 				resp := anthropicResponse{
-					Content: []struct {
-						Type string `json:"type"`
-						Text string `json:"text"`
-					}{},
+					Content: []anthropicContent{},
 				}
 				json.NewEncoder(w).Encode(resp)
 			},
@@ -278,6 +325,7 @@ func TestAnthropicProvider_GetSystemPrompt(t *testing.T) {
 		{RequestDebug, "expert debugger"},
 		{RequestDocumentation, "technical documentation expert"},
 		{RequestRefactor, "senior software engineer"},
+		{RequestEdit, "only the rewritten code"},
 		{RequestChat, "helpful AI programming assistant"},
 	}
 	
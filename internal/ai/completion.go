@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// introPattern matches a line of prose models put before the code of a
+// completion, like "Here's the completion:" or "Sure! Here is the code."
+var introPattern = regexp.MustCompile(`(?i)^((sure|certainly|of course|okay|here|below|the complet|complet)\S*\s.*[:.!]|(completion|code):)$`)
+
+// minOverlap is the fewest bytes of the text before the cursor a completion
+// must repeat for them to be taken off it
+const minOverlap = 4
+
+// normalizeCompletion makes the answer to a completion request fit at the
+// cursor, after before on its line: the code of a markdown fence instead of
+// the whole answer, without a line of prose before the code, without the
+// text before the cursor the model repeated, and indented like the line
+func normalizeCompletion(answer, before string) string {
+	code := extractCode(strings.ReplaceAll(answer, "\r\n", "\n"))
+	code = trimRepeated(code, before)
+	return strings.TrimRight(reindent(code, before), "\n")
+}
+
+// extractCode returns the code of the first markdown fence in answer, or
+// answer without a line of prose before the code
+func extractCode(answer string) string {
+	lines := strings.Split(answer, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		code := lines[i+1:]
+		for j, line := range code {
+			if strings.TrimSpace(line) == "```" {
+				code = code[:j]
+				break
+			}
+		}
+		return strings.Join(code, "\n")
+	}
+
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start < len(lines) && introPattern.MatchString(strings.TrimSpace(lines[start])) {
+		start++
+		for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+			start++
+		}
+		return strings.Join(lines[start:], "\n")
+	}
+	return answer
+}
+
+// trimRepeated takes the text of before that code starts with off it: the
+// whole of it, with or without its indentation, or else the longest end of
+// it from the start of a word
+func trimRepeated(code, before string) string {
+	text := strings.TrimLeft(before, " \t")
+	if text == "" {
+		return code
+	}
+	if strings.HasPrefix(code, before) {
+		return code[len(before):]
+	}
+	if trimmed := strings.TrimLeft(code, " \t"); strings.HasPrefix(trimmed, text) {
+		return trimmed[len(text):]
+	}
+	for i := 1; len(text)-i >= minOverlap; i++ {
+		if !utf8.RuneStart(text[i]) || !wordStart(text, i) {
+			continue
+		}
+		if strings.HasPrefix(code, text[i:]) {
+			return code[len(text)-i:]
+		}
+	}
+	return code
+}
+
+// wordStart reports whether a word, or a run of punctuation, starts at
+// byte i of s
+func wordStart(s string, i int) bool {
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+	next, _ := utf8.DecodeRuneInString(s[i:])
+	return isWordRune(prev) != isWordRune(next) || unicode.IsSpace(prev)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// reindent fits the indentation of code to the line it goes on, after
+// before: the first line loses its own when the cursor is in the line's
+// indentation, and the lines after it are indented with tabs when the line
+// is, counting the fewest spaces one of them is indented by as a tab
+func reindent(code, before string) string {
+	lines := strings.Split(code, "\n")
+	if strings.TrimSpace(before) == "" {
+		lines[0] = strings.TrimLeft(lines[0], " \t")
+	}
+	if !strings.HasPrefix(before, "\t") || len(lines) == 1 {
+		return strings.Join(lines, "\n")
+	}
+
+	width := 0
+	for _, line := range lines[1:] {
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		if spaces > 0 && spaces < len(line) && (width == 0 || spaces < width) {
+			width = spaces
+		}
+	}
+	if width < 2 {
+		return strings.Join(lines, "\n")
+	}
+	for i, line := range lines[1:] {
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		lines[i+1] = strings.Repeat("\t", spaces/width) + line[spaces-spaces%width:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeCompletion(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		before string
+		want   string
+	}{
+		{"plain", "b int) int {", "func add(a, ", "b int) int {"},
+		{"fenced", "Here you go:\n```go\nreturn a + b\n```\nThis adds them.", "\t", "return a + b"},
+		{"intro", "Here's the completion:\n\nreturn a + b\n", "\t", "return a + b"},
+		{"sure", "Sure! Here is the code.\nx := 1", "", "x := 1"},
+		{"repeated line", "func add(a, b int) int {", "func add(", "a, b int) int {"},
+		{"repeated without indentation", "if err != nil {", "\tif err", " != nil {"},
+		{"repeated end", `Println("hello")`, `	fmt.Println("`, `hello")`},
+		{"short overlap kept", "(x)", "f(", "(x)"},
+		{"code ending in a colon", "if x:\n    return y", "    ", "if x:\n    return y"},
+		{"spaces to tabs", "for i := range n {\n    sum += i\n}", "\t", "for i := range n {\n\tsum += i\n}"},
+		{"nested spaces to tabs", "{\n  a\n    b\n   c\n}", "\tx := ", "{\n\ta\n\t\tb\n\t c\n}"},
+		{"spaces kept", "{\n    a\n}", "    x := ", "{\n    a\n}"},
+		{"windows newlines", "a\r\nb\r\n", "", "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeCompletion(tt.answer, tt.before); got != tt.want {
+				t.Errorf("normalizeCompletion(%q, %q) = %q, want %q", tt.answer, tt.before, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAIManager_NormalizesCompletions(t *testing.T) {
+	provider := NewMockProvider(ProviderOllama)
+	answer := "```go\nfmt.Println(x)\n```"
+	provider.SetCompleteFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return &AIResponse{Content: answer}, nil
+	})
+	provider.SetChatFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return &AIResponse{Content: answer}, nil
+	})
+	manager := NewAIManager()
+	manager.RegisterProvider(provider)
+
+	resp, err := manager.Request(context.Background(), AIRequest{Prompt: "\tfmt.", Type: RequestCompletion})
+	if err != nil || resp.Content != "Println(x)" {
+		t.Errorf("completion = %v, %v", resp, err)
+	}
+
+	// Other answers are left as the model wrote them
+	resp, err = manager.Request(context.Background(), AIRequest{Prompt: "Print x", Type: RequestChat})
+	if err != nil || resp.Content != answer {
+		t.Errorf("chat = %v, %v", resp, err)
+	}
+}
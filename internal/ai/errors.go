@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorKind is the cause of a failed provider request, as far as it can be
+// told from the error
+type ErrorKind string
+
+const (
+	ErrorAuth      ErrorKind = "auth"       // The API key is missing, wrong or lacks access
+	ErrorRateLimit ErrorKind = "rate_limit" // Too many requests or the quota is used up
+	ErrorNetwork   ErrorKind = "network"    // The provider couldn't be reached
+	ErrorTimeout   ErrorKind = "timeout"    // The request took too long or was canceled
+	ErrorServer    ErrorKind = "server"     // The provider failed to handle the request
+	ErrorOther     ErrorKind = "other"
+)
+
+// StatusError is returned by providers for HTTP responses other than 200 OK
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// ProviderError is why one provider failed a request
+type ProviderError struct {
+	Provider ProviderType
+	Kind     ErrorKind
+	Err      error
+}
+
+// newProviderError classifies err from provider
+func newProviderError(provider ProviderType, err error) *ProviderError {
+	return &ProviderError{Provider: provider, Kind: classify(err), Err: err}
+}
+
+// classify tells the kind of a provider error
+func classify(err error) ErrorKind {
+	var status *StatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return ErrorTimeout
+	case errors.As(err, &status):
+		switch {
+		case status.StatusCode == http.StatusUnauthorized, status.StatusCode == http.StatusForbidden:
+			return ErrorAuth
+		case status.StatusCode == http.StatusTooManyRequests:
+			return ErrorRateLimit
+		case status.StatusCode >= 500:
+			return ErrorServer
+		}
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrorTimeout
+		}
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// Hint tells what to do about the error
+func (e *ProviderError) Hint() string {
+	switch e.Kind {
+	case ErrorAuth:
+		return "check its api_key"
+	case ErrorRateLimit:
+		return "rate limited, try again later or check the quota"
+	case ErrorNetwork:
+		if IsLocal(e.Provider) {
+			return "unreachable, check that it is running and its base_url"
+		}
+		return "unreachable, check the connection and its base_url"
+	case ErrorTimeout:
+		return "timed out"
+	case ErrorServer:
+		return "server error, try again later"
+	}
+	return ""
+}
+
+func (e *ProviderError) Error() string {
+	if hint := e.Hint(); hint != "" {
+		return fmt.Sprintf("%s: %s", e.Provider, hint)
+	}
+	return fmt.Sprintf("%s: %s", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// RequestError is returned by AIManager.Request when no provider served the
+// request, with the reason each one tried failed
+type RequestError struct {
+	Errors []*ProviderError
+	Local  bool // Only local providers could be tried
+}
+
+func (e *RequestError) Error() string {
+	if len(e.Errors) == 0 {
+		if e.Local {
+			return "no local AI provider available, and this project is restricted to local providers"
+		}
+		return "no available AI providers"
+	}
+	causes := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		causes[i] = err.Error()
+	}
+	return "all AI providers failed: " + strings.Join(causes, "; ")
+}
+
+// Unwrap returns the error of each provider tried
+func (e *RequestError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"unauthorized", &StatusError{StatusCode: 401}, ErrorAuth},
+		{"forbidden", &StatusError{StatusCode: 403}, ErrorAuth},
+		{"rate limited", &StatusError{StatusCode: 429}, ErrorRateLimit},
+		{"server error", &StatusError{StatusCode: 503}, ErrorServer},
+		{"bad request", &StatusError{StatusCode: 400}, ErrorOther},
+		{"connection refused", fmt.Errorf("request failed: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), ErrorNetwork},
+		{"deadline", fmt.Errorf("request failed: %w", context.DeadlineExceeded), ErrorTimeout},
+		{"other", errors.New("no response choices returned"), ErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(tt.err); got != tt.want {
+				t.Errorf("classify(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAIManager_RequestError(t *testing.T) {
+	ctx := context.Background()
+	manager := NewAIManager()
+	openai := NewMockProvider(ProviderOpenAI)
+	openai.SetChatFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return nil, &StatusError{StatusCode: 401, Body: "invalid key"}
+	})
+	ollama := NewMockProvider(ProviderOllama)
+	ollama.SetChatFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return nil, fmt.Errorf("request failed: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+	})
+	manager.RegisterProvider(openai)
+	manager.RegisterProvider(ollama)
+	manager.SetActiveProvider(ProviderOpenAI)
+
+	_, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat})
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("expected a *RequestError, got %v", err)
+	}
+	if len(reqErr.Errors) != 2 || reqErr.Errors[0].Kind != ErrorAuth || reqErr.Errors[1].Kind != ErrorNetwork {
+		t.Errorf("expected an auth then a network error, got %+v", reqErr.Errors)
+	}
+	for _, want := range []string{"openai: check its api_key", "ollama: unreachable, check that it is running"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != 401 {
+		t.Errorf("expected the status error to be reachable, got %v", status)
+	}
+
+	// On success the failures before it are reported with the response
+	ollama.SetChatFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return &AIResponse{Content: "hello", Provider: string(ProviderOllama)}, nil
+	})
+	resp, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Failed) != 1 || resp.Failed[0].Provider != ProviderOpenAI {
+		t.Errorf("expected openai's failure with the response, got %+v", resp.Failed)
+	}
+}
@@ -114,7 +114,7 @@ func (p *GoogleProvider) generateContent(ctx context.Context, req AIRequest, req
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	url := fmt.Sprintf("%s/%s:generateContent?key=%s", p.baseURL, p.model, p.apiKey)
+	url := fmt.Sprintf("%s/%s:generateContent?key=%s", p.baseURL, modelFor(req, p.model), p.apiKey)
 	
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -125,7 +125,7 @@ func (p *GoogleProvider) generateContent(ctx context.Context, req AIRequest, req
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -135,6 +135,9 @@ func (p *GoogleProvider) generateContent(ctx context.Context, req AIRequest, req
 	}
 
 	if googleResp.Error != nil {
+		if googleResp.Error.Code != 0 {
+			return nil, &StatusError{StatusCode: googleResp.Error.Code, Body: googleResp.Error.Message}
+		}
 		return nil, fmt.Errorf("google API error: %s", googleResp.Error.Message)
 	}
 
@@ -145,7 +148,7 @@ func (p *GoogleProvider) generateContent(ctx context.Context, req AIRequest, req
 	response := &AIResponse{
 		Content:  googleResp.Candidates[0].Content.Parts[0].Text,
 		Provider: string(ProviderGoogle),
-		Model:    p.model,
+		Model:    modelFor(req, p.model),
 	}
 
 	return response, nil
@@ -3,10 +3,13 @@ package ai
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
 )
 
-// MockProvider is a mock AI provider for testing
+// MockProvider is a mock AI provider for testing, safe for concurrent use
 type MockProvider struct {
+	mu             sync.Mutex
 	name           ProviderType
 	available      bool
 	completeFunc   func(context.Context, AIRequest) (*AIResponse, error)
@@ -60,37 +63,51 @@ func (m *MockProvider) Name() ProviderType {
 }
 
 func (m *MockProvider) IsAvailable() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.available
 }
 
 func (m *MockProvider) Complete(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	m.mu.Lock()
 	m.completeCalls = append(m.completeCalls, req)
-	if m.completeFunc != nil {
-		return m.completeFunc(ctx, req)
+	f := m.completeFunc
+	m.mu.Unlock()
+	if f != nil {
+		return f(ctx, req)
 	}
 	return nil, fmt.Errorf("complete not implemented")
 }
 
 func (m *MockProvider) Chat(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	m.mu.Lock()
 	m.chatCalls = append(m.chatCalls, req)
-	if m.chatFunc != nil {
-		return m.chatFunc(ctx, req)
+	f := m.chatFunc
+	m.mu.Unlock()
+	if f != nil {
+		return f(ctx, req)
 	}
 	return nil, fmt.Errorf("chat not implemented")
 }
 
 func (m *MockProvider) Analyze(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	m.mu.Lock()
 	m.analyzeCalls = append(m.analyzeCalls, req)
-	if m.analyzeFunc != nil {
-		return m.analyzeFunc(ctx, req)
+	f := m.analyzeFunc
+	m.mu.Unlock()
+	if f != nil {
+		return f(ctx, req)
 	}
 	return nil, fmt.Errorf("analyze not implemented")
 }
 
 func (m *MockProvider) Configure(config ProviderConfig) error {
+	m.mu.Lock()
 	m.configCalls = append(m.configCalls, config)
-	if m.configureFunc != nil {
-		return m.configureFunc(config)
+	f := m.configureFunc
+	m.mu.Unlock()
+	if f != nil {
+		return f(config)
 	}
 	return nil
 }
@@ -98,42 +115,62 @@ func (m *MockProvider) Configure(config ProviderConfig) error {
 // Test helpers
 
 func (m *MockProvider) SetAvailable(available bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.available = available
 }
 
 func (m *MockProvider) SetCompleteFunc(f func(context.Context, AIRequest) (*AIResponse, error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.completeFunc = f
 }
 
 func (m *MockProvider) SetChatFunc(f func(context.Context, AIRequest) (*AIResponse, error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.chatFunc = f
 }
 
 func (m *MockProvider) SetAnalyzeFunc(f func(context.Context, AIRequest) (*AIResponse, error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.analyzeFunc = f
 }
 
 func (m *MockProvider) SetConfigureFunc(f func(ProviderConfig) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.configureFunc = f
 }
 
 func (m *MockProvider) GetCompleteCalls() []AIRequest {
-	return m.completeCalls
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.completeCalls)
 }
 
 func (m *MockProvider) GetChatCalls() []AIRequest {
-	return m.chatCalls
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.chatCalls)
 }
 
 func (m *MockProvider) GetAnalyzeCalls() []AIRequest {
-	return m.analyzeCalls
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.analyzeCalls)
 }
 
 func (m *MockProvider) GetConfigCalls() []ProviderConfig {
-	return m.configCalls
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.configCalls)
 }
 
 func (m *MockProvider) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.configCalls = []ProviderConfig{}
 	m.completeCalls = []AIRequest{}
 	m.chatCalls = []AIRequest{}
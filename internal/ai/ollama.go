@@ -4,27 +4,61 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 )
 
+// availabilityTTL is how long what /api/tags said is trusted before it is
+// asked again, in the background
+const availabilityTTL = 30 * time.Second
+
+// firstCheckWait is how long the first availability check waits for the
+// server, which answers at once when it runs locally
+const firstCheckWait = 300 * time.Millisecond
+
 type OllamaProvider struct {
-	baseURL string
-	model   string
-	client  *http.Client
+	baseURL   string
+	model     string
+	options   OllamaOptions // Model parameters sent with every request
+	keepAlive any           // How long the model stays loaded, like "10m" or -1, unset for the server's default
+	client    *http.Client
+
+	mu         sync.Mutex    // Guards the settings above and what /api/tags said
+	checked    time.Time     // When /api/tags last answered or failed, zero before
+	waited     bool          // Whether a caller waited for the first answer
+	available  bool
+	models     []string      // Models pulled into the server, as /api/tags last listed them
+	refreshing chan struct{} // Closed when the check under way is done, nil without one
 }
 
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model     string         `json:"model"`
+	Prompt    string         `json:"prompt"`
+	Stream    bool           `json:"stream"`
+	Options   *OllamaOptions `json:"options,omitempty"`
+	KeepAlive any            `json:"keep_alive,omitempty"`
 }
 
 type OllamaChatRequest struct {
-	Model    string           `json:"model"`
-	Messages []OllamaMessage  `json:"messages"`
-	Stream   bool             `json:"stream"`
+	Model     string           `json:"model"`
+	Messages  []OllamaMessage  `json:"messages"`
+	Stream    bool             `json:"stream"`
+	Options   *OllamaOptions   `json:"options,omitempty"`
+	KeepAlive any              `json:"keep_alive,omitempty"`
+}
+
+// OllamaOptions are the model parameters of a request, those unset left
+// to the model's defaults
+type OllamaOptions struct {
+	NumCtx      int      `json:"num_ctx,omitempty"`     // Size of the context window, in tokens
+	NumPredict  int      `json:"num_predict,omitempty"` // Longest answer, in tokens
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
 }
 
 type OllamaMessage struct {
@@ -44,6 +78,15 @@ type OllamaChatResponse struct {
 	Error   string        `json:"error,omitempty"`
 }
 
+// ollamaAnswer is an answer of /api/generate or /api/chat, or a line of
+// one streamed
+type ollamaAnswer struct {
+	Response string        `json:"response"`
+	Message  OllamaMessage `json:"message"`
+	Done     bool          `json:"done"`
+	Error    string        `json:"error,omitempty"`
+}
+
 type OllamaTagsResponse struct {
 	Models []OllamaModel `json:"models"`
 }
@@ -66,79 +109,174 @@ func (p *OllamaProvider) Name() ProviderType {
 	return ProviderOllama
 }
 
+// IsAvailable reports whether the server answered when last asked, asking
+// again in the background when that was a while ago. Only the first caller
+// waits for the answer, and not for long; those asking while it is still
+// under way are told the server isn't available.
 func (p *OllamaProvider) IsAvailable() bool {
+	p.mu.Lock()
+	done := p.refreshIfStale()
+	first := p.checked.IsZero() && !p.waited
+	p.waited = true
+	p.mu.Unlock()
+	if first {
+		select {
+		case <-done:
+		case <-time.After(firstCheckWait):
+		}
+	}
+	
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.available
+}
+
+// Models lists the models pulled into the server, as /api/tags last listed
+// them, without waiting for it
+func (p *OllamaProvider) Models() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refreshIfStale()
+	return slices.Clone(p.models)
+}
+
+// SetModel sets the model of requests that name none
+func (p *OllamaProvider) SetModel(model string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.model = model
+}
+
+// currentModel returns the model of requests that name none
+func (p *OllamaProvider) currentModel() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.model
+}
+
+// refreshIfStale asks /api/tags again in the background unless it was asked
+// lately or is being asked, and returns a channel closed when it answers.
+// p.mu must be held.
+func (p *OllamaProvider) refreshIfStale() <-chan struct{} {
+	if p.refreshing != nil {
+		return p.refreshing
+	}
+	done := make(chan struct{})
+	if !p.checked.IsZero() && time.Since(p.checked) < availabilityTTL {
+		close(done)
+		return done
+	}
+	p.refreshing = done
+	go func(baseURL string) {
+		models, err := p.listModels(baseURL)
+		p.mu.Lock()
+		if p.baseURL == baseURL {
+			p.checked = time.Now()
+			p.available = err == nil
+			if err == nil {
+				p.models = models
+			}
+		}
+		if p.refreshing == done {
+			p.refreshing = nil
+		}
+		p.mu.Unlock()
+		close(done)
+	}(p.baseURL)
+	return done
+}
+
+// listModels asks the server at baseURL for the models pulled into it
+func (p *OllamaProvider) listModels(baseURL string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
-	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/tags", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/api/tags", nil)
 	if err != nil {
-		return false
+		return nil, err
 	}
 	
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return false
+		return nil, err
 	}
 	defer resp.Body.Close()
 	
-	return resp.StatusCode == http.StatusOK
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	
+	// A server listing no models still runs
+	var tags OllamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %v", err)
+	}
+	models := make([]string, len(tags.Models))
+	for i, model := range tags.Models {
+		models[i] = model.Name
+	}
+	return models, nil
 }
 
 func (p *OllamaProvider) Configure(config ProviderConfig) error {
-	if config.BaseURL != "" {
+	p.mu.Lock()
+	if config.BaseURL != "" && config.BaseURL != p.baseURL {
+		// Another server, asked about anew
 		p.baseURL = config.BaseURL
+		p.checked, p.waited, p.available, p.models, p.refreshing = time.Time{}, false, false, nil, nil
 	}
 	
 	if config.Model != "" {
 		p.model = config.Model
 	}
 	
+	p.options = OllamaOptions{
+		NumPredict:  config.MaxTokens,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+	}
+	p.keepAlive = nil
+	if config.Options != nil {
+		p.options.NumCtx, _ = config.Options["num_ctx"].(int)
+		p.keepAlive = config.Options["keep_alive"]
+	}
+	p.mu.Unlock()
+	
 	return nil
 }
 
+// requestOptions returns the options to send, nil when none is set, and
+// the keep_alive to send, as the provider may be configured meanwhile
+func (p *OllamaProvider) requestOptions() (*OllamaOptions, any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.options == (OllamaOptions{}) {
+		return nil, p.keepAlive
+	}
+	options := p.options
+	return &options, p.keepAlive
+}
+
+// currentBaseURL returns the URL of the server requests go to
+func (p *OllamaProvider) currentBaseURL() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.baseURL
+}
+
 func (p *OllamaProvider) Complete(ctx context.Context, req AIRequest) (*AIResponse, error) {
 	prompt := p.buildPrompt(req, "completion")
+	options, keepAlive := p.requestOptions()
 	
 	ollamaReq := OllamaRequest{
-		Model:  p.model,
-		Prompt: prompt,
-		Stream: false,
-	}
-
-	jsonData, err := json.Marshal(ollamaReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		Model:     modelFor(req, p.currentModel()),
+		Prompt:    prompt,
+		Stream:    req.OnChunk != nil,
+		Options:   options,
+		KeepAlive: keepAlive,
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	var ollamaResp OllamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	if ollamaResp.Error != "" {
-		return nil, fmt.Errorf("ollama API error: %s", ollamaResp.Error)
-	}
-
-	response := &AIResponse{
-		Content:  ollamaResp.Response,
-		Provider: string(ProviderOllama),
-		Model:    p.model,
-	}
-
-	return response, nil
+	return p.send(ctx, "/api/generate", ollamaReq, req)
 }
 
 func (p *OllamaProvider) Chat(ctx context.Context, req AIRequest) (*AIResponse, error) {
@@ -153,63 +291,42 @@ func (p *OllamaProvider) Chat(ctx context.Context, req AIRequest) (*AIResponse,
 		}
 	}
 	
+	options, keepAlive := p.requestOptions()
 	ollamaReq := OllamaChatRequest{
-		Model:    p.model,
-		Messages: messages,
-		Stream:   false,
-	}
-
-	jsonData, err := json.Marshal(ollamaReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		Model:     modelFor(req, p.currentModel()),
+		Messages:  messages,
+		Stream:    req.OnChunk != nil,
+		Options:   options,
+		KeepAlive: keepAlive,
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	var ollamaResp OllamaChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	if ollamaResp.Error != "" {
-		return nil, fmt.Errorf("ollama API error: %s", ollamaResp.Error)
-	}
-
-	response := &AIResponse{
-		Content:  ollamaResp.Message.Content,
-		Provider: string(ProviderOllama),
-		Model:    p.model,
-	}
-
-	return response, nil
+	return p.send(ctx, "/api/chat", ollamaReq, req)
 }
 
 func (p *OllamaProvider) Analyze(ctx context.Context, req AIRequest) (*AIResponse, error) {
 	prompt := p.buildPrompt(req, "analysis")
+	options, keepAlive := p.requestOptions()
 	
 	ollamaReq := OllamaRequest{
-		Model:  p.model,
-		Prompt: prompt,
-		Stream: false,
+		Model:     modelFor(req, p.currentModel()),
+		Prompt:    prompt,
+		Stream:    req.OnChunk != nil,
+		Options:   options,
+		KeepAlive: keepAlive,
 	}
 
-	jsonData, err := json.Marshal(ollamaReq)
+	return p.send(ctx, "/api/generate", ollamaReq, req)
+}
+
+// send posts body to path and reads the answer, streamed to req.OnChunk
+// when it is set
+func (p *OllamaProvider) send(ctx context.Context, path string, body any, req AIRequest) (*AIResponse, error) {
+	jsonData, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.currentBaseURL()+path, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
@@ -218,28 +335,53 @@ func (p *OllamaProvider) Analyze(ctx context.Context, req AIRequest) (*AIRespons
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var ollamaResp OllamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	if ollamaResp.Error != "" {
-		return nil, fmt.Errorf("ollama API error: %s", ollamaResp.Error)
+	content, err := readAnswer(resp.Body, req.OnChunk)
+	if err != nil {
+		return nil, err
 	}
 
 	response := &AIResponse{
-		Content:  ollamaResp.Response,
+		Content:  content,
 		Provider: string(ProviderOllama),
-		Model:    p.model,
+		Model:    modelFor(req, p.currentModel()),
 	}
 
 	return response, nil
 }
 
+// readAnswer reads the newline-delimited answers of a stream up to the one
+// done, passing the text of each to onChunk if set, and returns their text
+// together. An answer not streamed is a single one.
+func readAnswer(body io.Reader, onChunk func(text string)) (string, error) {
+	decoder := json.NewDecoder(body)
+	var content string
+	for chunks := 0; ; chunks++ {
+		var chunk ollamaAnswer
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) && chunks > 0 {
+				// The stream ended without saying it was done
+				return content, nil
+			}
+			return "", fmt.Errorf("failed to decode response: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama API error: %s", chunk.Error)
+		}
+		text := chunk.Response + chunk.Message.Content
+		content += text
+		if onChunk != nil && text != "" {
+			onChunk(text)
+		}
+		if chunk.Done {
+			return content, nil
+		}
+	}
+}
+
 func (p *OllamaProvider) buildPrompt(req AIRequest, requestType string) string {
 	switch requestType {
 	case "completion":
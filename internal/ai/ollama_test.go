@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestOllamaProvider_Configure(t *testing.T) {
@@ -40,8 +41,10 @@ func TestOllamaProvider_IsAvailable(t *testing.T) {
 	}
 	
 	// Test with mock server
+	var asked int
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/api/tags" {
+			asked++
 			resp := OllamaTagsResponse{
 				Models: []OllamaModel{
 					{Name: "llama2"},
@@ -53,10 +56,75 @@ func TestOllamaProvider_IsAvailable(t *testing.T) {
 	}))
 	defer server.Close()
 	
-	provider.baseURL = server.URL
+	provider.Configure(ProviderConfig{BaseURL: server.URL})
 	if !provider.IsAvailable() {
 		t.Error("Provider should be available with valid server")
 	}
+	
+	// The answer is kept, with the models listed
+	if !provider.IsAvailable() || asked != 1 {
+		t.Errorf("Expected the server asked once, got %d", asked)
+	}
+	if models := provider.Models(); len(models) != 2 || models[1] != "codellama" {
+		t.Errorf("Expected the models of the server, got %q", models)
+	}
+}
+
+func TestOllamaProvider_AvailabilityDoesNotWait(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(OllamaTagsResponse{Models: []OllamaModel{{Name: "llama2"}}})
+	}))
+	defer server.Close()
+	
+	provider := NewOllamaProvider()
+	provider.Configure(ProviderConfig{BaseURL: server.URL})
+	start := time.Now()
+	if provider.IsAvailable() {
+		t.Error("Provider should not be available before the server answers")
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("Checking availability waited %v", waited)
+	}
+	
+	// Only the first check waits
+	start = time.Now()
+	if provider.IsAvailable() || provider.IsAvailable() {
+		t.Error("Provider should not be available before the server answers")
+	}
+	if waited := time.Since(start); waited >= firstCheckWait {
+		t.Errorf("Checking availability again waited %v", waited)
+	}
+	
+	// The check goes on in the background
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !provider.IsAvailable() {
+		if time.Now().After(deadline) {
+			t.Fatal("Provider never became available")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if models := provider.Models(); len(models) != 1 || models[0] != "llama2" {
+		t.Errorf("Expected the model of the server, got %q", models)
+	}
+}
+
+func TestOllamaProvider_MalformedModelList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>not ollama</html>"))
+	}))
+	defer server.Close()
+	
+	provider := NewOllamaProvider()
+	if _, err := provider.listModels(server.URL); err == nil {
+		t.Error("Expected an error for a model list that isn't JSON")
+	}
+	provider.Configure(ProviderConfig{BaseURL: server.URL})
+	if provider.IsAvailable() {
+		t.Error("Provider should not be available when its model list can't be read")
+	}
 }
 
 func TestOllamaProvider_Complete(t *testing.T) {
@@ -212,6 +280,80 @@ func TestOllamaProvider_Analyze(t *testing.T) {
 	}
 }
 
+func TestOllamaProvider_OptionsAndStreaming(t *testing.T) {
+	var sent []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req)
+		if req["stream"] != true {
+			json.NewEncoder(w).Encode(OllamaResponse{Response: "whole", Done: true})
+			return
+		}
+		for _, piece := range []string{"Hel", "lo", ""} {
+			json.NewEncoder(w).Encode(OllamaChatResponse{Message: OllamaMessage{Role: "assistant", Content: piece}, Done: piece == ""})
+		}
+	}))
+	defer server.Close()
+
+	temperature := 0.2
+	provider := NewOllamaProvider()
+	provider.Configure(ProviderConfig{
+		BaseURL:     server.URL,
+		MaxTokens:   2048,
+		Temperature: &temperature,
+		Options:     map[string]interface{}{"num_ctx": 8192, "keep_alive": "10m"},
+	})
+
+	var chunks []string
+	resp, err := provider.Chat(context.Background(), AIRequest{Prompt: "Hi", OnChunk: func(text string) { chunks = append(chunks, text) }})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Content != "Hello" || len(chunks) != 2 || chunks[0] != "Hel" {
+		t.Errorf("Expected 'Hello' in two chunks, got %q from %q", resp.Content, chunks)
+	}
+	req := sent[0]
+	options, _ := req["options"].(map[string]any)
+	if options["num_ctx"] != 8192.0 || options["num_predict"] != 2048.0 || options["temperature"] != 0.2 || req["keep_alive"] != "10m" {
+		t.Errorf("Expected the configured options, got %v and keep_alive %v", options, req["keep_alive"])
+	}
+
+	// Without a callback the answer comes whole, and without options set
+	// none is sent
+	provider.Configure(ProviderConfig{BaseURL: server.URL})
+	resp, err = provider.Complete(context.Background(), AIRequest{Prompt: "func"})
+	if err != nil || resp.Content != "whole" {
+		t.Fatalf("Complete gave %v, %v", resp, err)
+	}
+	if req := sent[1]; req["options"] != nil || req["keep_alive"] != nil {
+		t.Errorf("Unexpected completion request %v", req)
+	}
+}
+
+func TestOllamaProvider_ConfigureWhileRequesting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OllamaResponse{Response: "whole", Done: true})
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider()
+	provider.Configure(ProviderConfig{BaseURL: server.URL})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			provider.Configure(ProviderConfig{BaseURL: server.URL, MaxTokens: i, Options: map[string]interface{}{"keep_alive": "5m"}})
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if _, err := provider.Complete(context.Background(), AIRequest{Prompt: "func"}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+	<-done
+}
+
 func TestOllamaProvider_ErrorHandling(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -317,4 +459,31 @@ func TestOllamaProvider_BuildPrompt(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestOllamaProvider_SlowStartDoesNotBlockManager(t *testing.T) {
+	// A server slow to say whether it runs mustn't hold up the requests
+	// made while the editor starts
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	manager := NewAIManager()
+	provider := NewOllamaProvider()
+	provider.baseURL = server.URL
+	go manager.RegisterProvider(provider)
+
+	done := make(chan struct{})
+	go func() {
+		manager.GetActiveProvider()
+		manager.ListProviders()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the manager was locked while the provider was asked whether it runs")
+	}
+}
@@ -13,10 +13,12 @@ import (
 
 // OpenAIProvider implements the Provider interface for OpenAI
 type OpenAIProvider struct {
-	apiKey  string
-	baseURL string
-	model   string
-	client  *http.Client
+	apiKey       string
+	baseURL      string
+	model        string
+	organization string // Sent as OpenAI-Organization when set
+	jsonMode     bool   // Requests wanting JSON use response_format json_object
+	client       *http.Client
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
@@ -52,11 +54,13 @@ func (o *OpenAIProvider) Configure(config ProviderConfig) error {
 		o.model = config.Model
 	}
 	
-	// Configure client timeout from options
+	// Configure client timeout, organization and JSON mode from options
 	if config.Options != nil {
 		if timeout, ok := config.Options["timeout"].(int); ok {
 			o.client.Timeout = time.Duration(timeout) * time.Second
 		}
+		o.organization, _ = config.Options["organization"].(string)
+		o.jsonMode, _ = config.Options["json_mode"].(bool)
 	}
 	
 	return nil
@@ -69,11 +73,52 @@ type openAIMessage struct {
 }
 
 type openAIChatRequest struct {
-	Model       string          `json:"model"`
-	Messages    []openAIMessage `json:"messages"`
-	MaxTokens   int             `json:"max_tokens,omitempty"`
-	Temperature float64         `json:"temperature,omitempty"`
-	Stream      bool            `json:"stream"`
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Temperature    float64               `json:"temperature,omitempty"`
+	Stream         bool                  `json:"stream"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Tools          []openAITool          `json:"tools,omitempty"`
+}
+
+// openAIResponseFormat asks for the answer as a JSON object
+type openAIResponseFormat struct {
+	Type string `json:"type"` // "json_object"
+}
+
+// openAITool describes a function the model may call
+type openAITool struct {
+	Type     string `json:"type"` // "function"
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+// openAIToolCall is a call of a function in an answer, its arguments a
+// JSON object in a string
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAIAnswer is the message of a choice
+type openAIAnswer struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int          `json:"index"`
+	Message      openAIAnswer `json:"message"`
+	FinishReason string       `json:"finish_reason"`
 }
 
 type openAIChatResponse struct {
@@ -81,14 +126,7 @@ type openAIChatResponse struct {
 	Object  string `json:"object"`
 	Created int64  `json:"created"`
 	Model   string `json:"model"`
-	Choices []struct {
-		Index   int `json:"index"`
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-		FinishReason string `json:"finish_reason"`
-	} `json:"choices"`
+	Choices []openAIChoice `json:"choices"`
 	Usage struct {
 		PromptTokens     int `json:"prompt_tokens"`
 		CompletionTokens int `json:"completion_tokens"`
@@ -101,7 +139,7 @@ func (o *OpenAIProvider) Complete(ctx context.Context, req AIRequest) (*AIRespon
 	prompt := o.buildCompletionPrompt(req)
 	
 	chatReq := openAIChatRequest{
-		Model: o.model,
+		Model: modelFor(req, o.model),
 		Messages: []openAIMessage{
 			{Role: "system", Content: "You are a helpful code completion assistant. Provide concise, accurate code completions."},
 			{Role: "user", Content: prompt},
@@ -116,12 +154,7 @@ func (o *OpenAIProvider) Complete(ctx context.Context, req AIRequest) (*AIRespon
 		return nil, err
 	}
 	
-	return &AIResponse{
-		Content:    response.Choices[0].Message.Content,
-		Confidence: 0.8, // Default confidence
-		Provider:   string(ProviderOpenAI),
-		Model:      o.model,
-	}, nil
+	return o.newResponse(req, response, 0.8), nil // Default confidence
 }
 
 // Chat handles general conversational requests
@@ -129,7 +162,7 @@ func (o *OpenAIProvider) Chat(ctx context.Context, req AIRequest) (*AIResponse,
 	prompt := o.buildChatPrompt(req)
 	
 	chatReq := openAIChatRequest{
-		Model: o.model,
+		Model: modelFor(req, o.model),
 		Messages: []openAIMessage{
 			{Role: "system", Content: o.getSystemPrompt(req.Type)},
 			{Role: "user", Content: prompt},
@@ -138,18 +171,14 @@ func (o *OpenAIProvider) Chat(ctx context.Context, req AIRequest) (*AIResponse,
 		Temperature: 0.3,
 		Stream:      false,
 	}
+	o.addOptions(&chatReq, req)
 	
 	response, err := o.makeRequest(ctx, chatReq)
 	if err != nil {
 		return nil, err
 	}
 	
-	return &AIResponse{
-		Content:    response.Choices[0].Message.Content,
-		Confidence: 0.9,
-		Provider:   string(ProviderOpenAI),
-		Model:      o.model,
-	}, nil
+	return o.newResponse(req, response, 0.9), nil
 }
 
 // Analyze provides code analysis and suggestions
@@ -157,7 +186,7 @@ func (o *OpenAIProvider) Analyze(ctx context.Context, req AIRequest) (*AIRespons
 	prompt := o.buildAnalysisPrompt(req)
 	
 	chatReq := openAIChatRequest{
-		Model: o.model,
+		Model: modelFor(req, o.model),
 		Messages: []openAIMessage{
 			{Role: "system", Content: "You are an expert code reviewer and refactoring assistant. Provide specific, actionable suggestions."},
 			{Role: "user", Content: prompt},
@@ -166,18 +195,45 @@ func (o *OpenAIProvider) Analyze(ctx context.Context, req AIRequest) (*AIRespons
 		Temperature: 0.2,
 		Stream:      false,
 	}
+	o.addOptions(&chatReq, req)
 	
 	response, err := o.makeRequest(ctx, chatReq)
 	if err != nil {
 		return nil, err
 	}
 	
-	return &AIResponse{
-		Content:    response.Choices[0].Message.Content,
-		Confidence: 0.85,
+	return o.newResponse(req, response, 0.85), nil
+}
+
+// addOptions adds req's tools to chatReq as functions, and JSON mode when
+// req wants JSON and the config turns it on
+func (o *OpenAIProvider) addOptions(chatReq *openAIChatRequest, req AIRequest) {
+	if req.JSON && o.jsonMode {
+		chatReq.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+	}
+	for _, tool := range req.Tools {
+		var t openAITool
+		t.Type = "function"
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.InputSchema
+		chatReq.Tools = append(chatReq.Tools, t)
+	}
+}
+
+// newResponse returns the first choice of response, with its tool calls
+func (o *OpenAIProvider) newResponse(req AIRequest, response *openAIChatResponse, confidence float64) *AIResponse {
+	answer := response.Choices[0].Message
+	resp := &AIResponse{
+		Content:    answer.Content,
+		Confidence: confidence,
 		Provider:   string(ProviderOpenAI),
-		Model:      o.model,
-	}, nil
+		Model:      modelFor(req, o.model),
+	}
+	for _, call := range answer.ToolCalls {
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Input: json.RawMessage(call.Function.Arguments)})
+	}
+	return resp
 }
 
 // makeRequest makes an HTTP request to OpenAI API
@@ -194,6 +250,9 @@ func (o *OpenAIProvider) makeRequest(ctx context.Context, req openAIChatRequest)
 	
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	if o.organization != "" {
+		httpReq.Header.Set("OpenAI-Organization", o.organization)
+	}
 	
 	resp, err := o.client.Do(httpReq)
 	if err != nil {
@@ -203,7 +262,7 @@ func (o *OpenAIProvider) makeRequest(ctx context.Context, req openAIChatRequest)
 	
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 	
 	var response openAIChatResponse
@@ -286,6 +345,8 @@ func (o *OpenAIProvider) getSystemPrompt(reqType RequestType) string {
 		return "You are an expert debugger. Help identify and fix issues in code."
 	case RequestDocumentation:
 		return "You are a technical writer. Generate clear, comprehensive documentation."
+	case RequestEdit:
+		return "You are an expert programmer editing code in place. Reply with only the rewritten code, keeping its indentation, without explanations or markdown fences."
 	default:
 		return "You are a helpful AI programming assistant."
 	}
@@ -82,19 +82,9 @@ func TestOpenAIProvider_Complete(t *testing.T) {
 		
 		// Send response
 		resp := openAIChatResponse{
-			Choices: []struct {
-				Index   int `json:"index"`
-				Message struct {
-					Role    string `json:"role"`
-					Content string `json:"content"`
-				} `json:"message"`
-				FinishReason string `json:"finish_reason"`
-			}{
+			Choices: []openAIChoice{
 				{
-					Message: struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					}{
+					Message: openAIAnswer{
 						Role:    "assistant",
 						Content: "Completed code",
 					},
@@ -141,19 +131,9 @@ func TestOpenAIProvider_Chat(t *testing.T) {
 	// Create test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := openAIChatResponse{
-			Choices: []struct {
-				Index   int `json:"index"`
-				Message struct {
-					Role    string `json:"role"`
-					Content string `json:"content"`
-				} `json:"message"`
-				FinishReason string `json:"finish_reason"`
-			}{
+			Choices: []openAIChoice{
 				{
-					Message: struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					}{
+					Message: openAIAnswer{
 						Role:    "assistant",
 						Content: "Chat response",
 					},
@@ -188,6 +168,70 @@ func TestOpenAIProvider_Chat(t *testing.T) {
 	}
 }
 
+func TestOpenAIProvider_JSONModeAndTools(t *testing.T) {
+	var sent []map[string]any
+	var organization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		organization = r.Header.Get("OpenAI-Organization")
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req)
+		var answer openAIAnswer
+		answer.Role = "assistant"
+		answer.ToolCalls = make([]openAIToolCall, 1)
+		answer.ToolCalls[0].ID = "call_1"
+		answer.ToolCalls[0].Type = "function"
+		answer.ToolCalls[0].Function.Name = "read_file"
+		answer.ToolCalls[0].Function.Arguments = `{"path":"main.go"}`
+		json.NewEncoder(w).Encode(openAIChatResponse{Choices: []openAIChoice{{Message: answer, FinishReason: "tool_calls"}}})
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider()
+	provider.Configure(ProviderConfig{
+		APIKey:  "test-key",
+		BaseURL: server.URL,
+		Options: map[string]interface{}{"organization": "org-1", "json_mode": true},
+	})
+
+	tool := Tool{
+		Name:        "read_file",
+		Description: "Read a file of the project",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{"path": map[string]any{"type": "string"}}},
+	}
+	resp, err := provider.Chat(context.Background(), AIRequest{Prompt: "Fix main.go, answering in JSON", Type: RequestChat, Tools: []Tool{tool}, JSON: true})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("Expected a tool call, got %+v", resp)
+	}
+	if call := resp.ToolCalls[0]; call.ID != "call_1" || call.Name != "read_file" || string(call.Input) != `{"path":"main.go"}` {
+		t.Errorf("Unexpected tool call %+v", call)
+	}
+	if organization != "org-1" {
+		t.Errorf("Expected the organization header, got %q", organization)
+	}
+
+	req := sent[0]
+	if format, _ := req["response_format"].(map[string]any); format["type"] != "json_object" {
+		t.Errorf("Expected JSON mode, got %v", req["response_format"])
+	}
+	tools, _ := req["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["type"] != "function" {
+		t.Fatalf("Expected the tool as a function, got %v", req["tools"])
+	}
+	if function := tools[0].(map[string]any)["function"].(map[string]any); function["name"] != "read_file" || function["parameters"] == nil {
+		t.Errorf("Expected the function with its schema, got %v", function)
+	}
+
+	// Requests not wanting JSON go without it
+	provider.Analyze(context.Background(), AIRequest{Prompt: "Review"})
+	if req := sent[1]; req["response_format"] != nil || req["tools"] != nil {
+		t.Errorf("Unexpected analysis request %v", req)
+	}
+}
+
 func TestOpenAIProvider_ErrorHandling(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -208,14 +252,7 @@ func TestOpenAIProvider_ErrorHandling(t *testing.T) {
 			name: "No Choices",
 			serverResponse: func(w http.ResponseWriter, r *http.Request) {
 				resp := openAIChatResponse{
-					Choices: []struct {
-						Index   int `json:"index"`
-						Message struct {
-							Role    string `json:"role"`
-							Content string `json:"content"`
-						} `json:"message"`
-						FinishReason string `json:"finish_reason"`
-					}{},
+					Choices: []openAIChoice{},
 				}
 				json.NewEncoder(w).Encode(resp)
 			},
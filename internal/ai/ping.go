@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Pinger is a provider that can check it is reachable and accepts its API
+// key without spending tokens, by listing its models
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ping sends req, returning a StatusError for a response other than 200 OK
+func ping(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
+
+// Ping lists the OpenAI models with the API key
+func (o *OpenAIProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", o.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	return ping(o.client, req)
+}
+
+// Ping lists the Anthropic models with the API key
+func (a *AnthropicProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", a.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	return ping(a.client, req)
+}
+
+// Ping lists the Google models with the API key
+func (p *GoogleProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"?key="+p.apiKey, nil)
+	if err != nil {
+		return err
+	}
+	return ping(p.client, req)
+}
+
+// Ping lists the models pulled into the Ollama server
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	return ping(p.client, req)
+}
+
+// Ping checks every registered provider at once, returning why each one
+// that can't be used failed. Providers that can't be pinged are only
+// checked for their configuration.
+func (am *AIManager) Ping(ctx context.Context) map[ProviderType]error {
+	providers := am.ListProviders()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[ProviderType]error, len(providers))
+	for name, provider := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if !provider.IsAvailable() {
+				err = &ProviderError{Provider: name, Kind: ErrorAuth, Err: fmt.Errorf("not configured")}
+			} else if pinger, ok := provider.(Pinger); ok {
+				if pingErr := pinger.Ping(ctx); pingErr != nil {
+					err = newProviderError(name, pingErr)
+				}
+			}
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPingSendsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/models" && r.Header.Get("Authorization") == "Bearer good":
+		case r.URL.Path == "/models" && r.Header.Get("x-api-key") == "good":
+		case r.URL.Path == "/" && r.URL.Query().Get("key") == "good":
+		case r.URL.Path == "/api/tags":
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	for _, key := range []string{"good", "bad"} {
+		pingers := map[string]Pinger{
+			"openai":    &OpenAIProvider{apiKey: key, baseURL: server.URL, client: server.Client()},
+			"anthropic": &AnthropicProvider{apiKey: key, baseURL: server.URL, client: server.Client()},
+			"google":    &GoogleProvider{apiKey: key, baseURL: server.URL + "/", client: server.Client()},
+		}
+		for name, p := range pingers {
+			err := p.Ping(ctx)
+			if key == "good" && err != nil {
+				t.Errorf("%s Ping() with a good key = %v", name, err)
+			}
+			var status *StatusError
+			if key == "bad" && (!errors.As(err, &status) || status.StatusCode != http.StatusUnauthorized) {
+				t.Errorf("%s Ping() with a bad key = %v, want 401", name, err)
+			}
+		}
+	}
+
+	ollama := &OllamaProvider{baseURL: server.URL, client: server.Client()}
+	if err := ollama.Ping(ctx); err != nil {
+		t.Errorf("ollama Ping() = %v", err)
+	}
+}
+
+func TestAIManager_Ping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	am := NewAIManager()
+	am.RegisterProvider(&OpenAIProvider{apiKey: "revoked", baseURL: server.URL, client: server.Client()})
+	am.RegisterProvider(&AnthropicProvider{baseURL: server.URL, client: server.Client()})
+	mock := NewMockProvider(ProviderOllama)
+	am.RegisterProvider(mock)
+
+	results := am.Ping(context.Background())
+	if len(results) != 3 {
+		t.Fatalf("Ping() checked %d providers, want 3", len(results))
+	}
+	var perr *ProviderError
+	if !errors.As(results[ProviderOpenAI], &perr) || perr.Kind != ErrorAuth {
+		t.Errorf("openai = %v, want an auth error", results[ProviderOpenAI])
+	}
+	if !errors.As(results[ProviderAnthropic], &perr) || perr.Kind != ErrorAuth {
+		t.Errorf("anthropic without a key = %v, want an auth error", results[ProviderAnthropic])
+	}
+	if results[ProviderOllama] != nil {
+		t.Errorf("available mock = %v, want nil", results[ProviderOllama])
+	}
+}
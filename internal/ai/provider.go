@@ -3,6 +3,13 @@ package ai
 import (
 	"context"
 	"fmt"
+	"maps"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/activity"
+	"github.com/dshills/aied/internal/perf"
 )
 
 // ProviderType represents different AI providers
@@ -13,8 +20,18 @@ const (
 	ProviderAnthropic ProviderType = "anthropic"
 	ProviderGoogle    ProviderType = "google"
 	ProviderOllama    ProviderType = "ollama"
+	ProviderReplay    ProviderType = "replay" // Recorded responses, for demos and tests
 )
 
+// Valid reports whether p names a known provider
+func (p ProviderType) Valid() bool {
+	switch p {
+	case ProviderOpenAI, ProviderAnthropic, ProviderGoogle, ProviderOllama, ProviderReplay:
+		return true
+	}
+	return false
+}
+
 // AIRequest represents a request to an AI provider
 type AIRequest struct {
 	Prompt      string            // The main prompt/question
@@ -22,6 +39,29 @@ type AIRequest struct {
 	Language    string            // Programming language for context
 	Type        RequestType       // Type of AI assistance requested
 	Options     map[string]interface{} // Provider-specific options
+	Filename    string            // File the request is about, if any, for privacy rules
+	Root        string            // Root of the project the request is about, for privacy rules
+	Model       string            // Model to use instead of the provider's configured one
+	Provider    ProviderType      // The only provider to send to, instead of the routed, active and fallback ones
+	ID          RequestID         // Set by Go to match the response to the request
+	OnQueued    func(position int) // Called while the request waits for a provider's rate limit, with its place in the queue
+	OnChunk     func(text string) // Called with each piece of the answer as it arrives, where the provider streams (ollama)
+	Tools       []Tool            // Functions the model may call instead of answering, where the provider supports tool use
+	JSON        bool              // Wants the answer as a JSON object, which the prompt must ask for; OpenAI's json_mode option enforces it
+}
+
+// modelFor returns the model req asks for, or configured when it names none
+func modelFor(req AIRequest, configured string) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return configured
+}
+
+// EstimateTokens guesses how many tokens text takes, at about four
+// characters a token, to show what sending it costs
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
 }
 
 // RequestType represents different types of AI assistance
@@ -34,8 +74,18 @@ const (
 	RequestDebug        RequestType = "debug"        // Debug help
 	RequestDocumentation RequestType = "documentation" // Generate docs
 	RequestChat         RequestType = "chat"         // General chat/help
+	RequestEdit         RequestType = "edit"         // Rewrite code following an instruction
 )
 
+// Valid reports whether t names a known type of request
+func (t RequestType) Valid() bool {
+	switch t {
+	case RequestCompletion, RequestExplanation, RequestRefactor, RequestDebug, RequestDocumentation, RequestChat, RequestEdit:
+		return true
+	}
+	return false
+}
+
 // AIResponse represents the response from an AI provider
 type AIResponse struct {
 	Content     string   // The AI's response content
@@ -44,6 +94,9 @@ type AIResponse struct {
 	Error       error    // Any error that occurred
 	Provider    string   // Which provider generated this response
 	Model       string   // Which model was used
+	Failed      []*ProviderError // Providers that failed the request before this one served it
+	RequestID   RequestID        // ID of the request, for requests started with Go
+	ToolCalls   []ToolCall       // Calls of the request's tools the model answered with
 }
 
 // Provider defines the interface that all AI providers must implement
@@ -67,6 +120,14 @@ type Provider interface {
 	Configure(config ProviderConfig) error
 }
 
+// ModelChooser is a provider that lists the models it can serve, as last
+// fetched so listing them doesn't wait, and lets one be chosen for the
+// requests that name none
+type ModelChooser interface {
+	Models() []string
+	SetModel(model string)
+}
+
 // ProviderConfig holds configuration for AI providers
 type ProviderConfig struct {
 	Type       ProviderType          `yaml:"type"`
@@ -75,19 +136,49 @@ type ProviderConfig struct {
 	Model      string                `yaml:"model"`
 	Options    map[string]interface{} `yaml:"options"`
 	Enabled    bool                  `yaml:"enabled"`
+	RateLimit  float64               `yaml:"rate_limit"` // requests per minute, 0 for no limit
+	Burst      int                   `yaml:"burst"`      // requests sent at once before the rate limit applies
+	MaxTokens  int                   `yaml:"max_tokens"` // longest chat, edit or analysis answer, 0 for the provider's defaults (anthropic, ollama)
+	Temperature *float64             `yaml:"temperature"` // sampling temperature, unset for the API's default (anthropic, ollama)
+	TopP       *float64              `yaml:"top_p"`      // nucleus sampling, unset for the API's default (anthropic, ollama)
+}
+
+// Guard checks requests before they are sent
+type Guard interface {
+	// Check returns req cleaned of what mustn't leave the machine, or an
+	// error if it mustn't be sent at all. local reports whether only local
+	// providers may receive it.
+	Check(req AIRequest) (cleaned AIRequest, local bool, err error)
+}
+
+// Route sends requests of one type to a provider, and optionally a model
+// other than the one it is configured with
+type Route struct {
+	Provider ProviderType
+	Model    string
 }
 
-// AIManager manages multiple AI providers and routing
+// AIManager manages multiple AI providers and routing. It is safe for
+// concurrent use, and requests run in parallel.
 type AIManager struct {
+	mu            sync.RWMutex
 	providers     map[ProviderType]Provider
 	activeProvider ProviderType
 	fallbackOrder []ProviderType
+	routes        map[RequestType]Route
+	guard         Guard
+	nextID        RequestID
+	inFlight      map[RequestID]context.CancelFunc
+	limiters      map[ProviderType]*Limiter
+	onQueue       func()
 }
 
 // NewAIManager creates a new AI manager
 func NewAIManager() *AIManager {
 	return &AIManager{
 		providers: make(map[ProviderType]Provider),
+		inFlight:  make(map[RequestID]context.CancelFunc),
+		limiters:  make(map[ProviderType]*Limiter),
 		fallbackOrder: []ProviderType{
 			ProviderOllama,    // Local first (no API costs)
 			ProviderOpenAI,    // Popular and reliable
@@ -103,10 +194,16 @@ func (am *AIManager) RegisterProvider(provider Provider) error {
 		return fmt.Errorf("provider cannot be nil")
 	}
 	
+	// Asking a local provider can take seconds, so it is asked before
+	// taking the lock that requests need
+	available := provider.IsAvailable()
+	
+	am.mu.Lock()
+	defer am.mu.Unlock()
 	am.providers[provider.Name()] = provider
 	
 	// Set as active if it's the first available provider
-	if am.activeProvider == "" && provider.IsAvailable() {
+	if am.activeProvider == "" && available {
 		am.activeProvider = provider.Name()
 	}
 	
@@ -115,7 +212,7 @@ func (am *AIManager) RegisterProvider(provider Provider) error {
 
 // SetActiveProvider sets the active AI provider
 func (am *AIManager) SetActiveProvider(providerType ProviderType) error {
-	provider, exists := am.providers[providerType]
+	provider, exists := am.GetProvider(providerType)
 	if !exists {
 		return fmt.Errorf("provider %s not registered", providerType)
 	}
@@ -124,12 +221,16 @@ func (am *AIManager) SetActiveProvider(providerType ProviderType) error {
 		return fmt.Errorf("provider %s not available", providerType)
 	}
 	
+	am.mu.Lock()
+	defer am.mu.Unlock()
 	am.activeProvider = providerType
 	return nil
 }
 
 // GetActiveProvider returns the currently active provider
 func (am *AIManager) GetActiveProvider() Provider {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
 	if am.activeProvider == "" {
 		return nil
 	}
@@ -139,17 +240,23 @@ func (am *AIManager) GetActiveProvider() Provider {
 
 // GetProvider returns a specific provider by type
 func (am *AIManager) GetProvider(providerType ProviderType) (Provider, bool) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
 	provider, exists := am.providers[providerType]
 	return provider, exists
 }
 
-// ListProviders returns all registered providers
+// ListProviders returns a copy of the registered providers
 func (am *AIManager) ListProviders() map[ProviderType]Provider {
-	return am.providers
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	return maps.Clone(am.providers)
 }
 
 // ListAvailableProviders returns only available providers
 func (am *AIManager) ListAvailableProviders() map[ProviderType]Provider {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
 	available := make(map[ProviderType]Provider)
 	for pType, provider := range am.providers {
 		if provider.IsAvailable() {
@@ -159,41 +266,193 @@ func (am *AIManager) ListAvailableProviders() map[ProviderType]Provider {
 	return available
 }
 
-// Request makes an AI request using the active provider with fallback
+// SetFallbackOrder sets the providers tried, in order, when the routed and
+// active providers fail
+func (am *AIManager) SetFallbackOrder(order []ProviderType) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.fallbackOrder = order
+}
+
+// SetRoutes sends each type of request to its route's provider before the
+// active provider
+func (am *AIManager) SetRoutes(routes map[RequestType]Route) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.routes = routes
+}
+
+// SetRateLimit limits requests to a provider to perMinute a minute, letting
+// up to burst through at once. A perMinute of 0 removes the limit.
+func (am *AIManager) SetRateLimit(providerType ProviderType, perMinute float64, burst int) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	if perMinute <= 0 {
+		delete(am.limiters, providerType)
+		return
+	}
+	am.limiters[providerType] = NewLimiter(perMinute, burst)
+}
+
+// Queued returns the number of requests waiting for a rate limit
+func (am *AIManager) Queued() int {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	queued := 0
+	for _, limiter := range am.limiters {
+		queued += limiter.Queued()
+	}
+	return queued
+}
+
+// SetQueueNotify sets a function called from the requests' goroutines
+// whenever a request starts or stops waiting for a rate limit
+func (am *AIManager) SetQueueNotify(notify func()) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.onQueue = notify
+}
+
+// SetGuard makes every request pass guard before it is sent
+func (am *AIManager) SetGuard(guard Guard) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.guard = guard
+}
+
+// IsLocal reports whether a provider runs on this machine
+func IsLocal(providerType ProviderType) bool {
+	return providerType == ProviderOllama
+}
+
+// Request makes an AI request using the routed provider for its type, then
+// the active provider, then the fallback order. When none serves it, the
+// error is a *RequestError with the reason each one failed.
 func (am *AIManager) Request(ctx context.Context, req AIRequest) (*AIResponse, error) {
-	// Try active provider first
-	if am.activeProvider != "" {
-		if provider := am.providers[am.activeProvider]; provider != nil && provider.IsAvailable() {
-			response, err := am.makeRequest(ctx, provider, req)
-			if err == nil {
-				return response, nil
-			}
-			// Log error but continue to fallback
+	p := am.plan(req)
+	local := false
+	if guard := p.guard; guard != nil {
+		var err error
+		if req, local, err = guard.Check(req); err != nil {
+			return nil, err
 		}
 	}
-	
-	// Try fallback providers
-	for _, providerType := range am.fallbackOrder {
-		provider, exists := am.providers[providerType]
-		if !exists || !provider.IsAvailable() || providerType == am.activeProvider {
+
+	tried := map[ProviderType]bool{}
+	var failed []*ProviderError
+	for _, a := range p.attempts {
+		provider, exists := p.providers[a.provider]
+		if !exists || !provider.IsAvailable() || tried[a.provider] || local && !IsLocal(a.provider) {
 			continue
 		}
-		
-		response, err := am.makeRequest(ctx, provider, req)
+		tried[a.provider] = true
+
+		attemptReq := req
+		if attemptReq.Model == "" {
+			attemptReq.Model = a.model
+		}
+		done := activity.Start("ai " + string(a.provider))
+		if err := p.wait(ctx, a.provider, req.OnQueued); err != nil {
+			done()
+			failed = append(failed, newProviderError(a.provider, err))
+			break
+		}
+		start := time.Now()
+		response, err := am.makeRequest(ctx, provider, attemptReq)
+		perf.Since("ai "+string(a.provider), start)
+		done()
 		if err == nil {
+			response.RequestID = req.ID
+			response.Failed = failed
 			return response, nil
 		}
+		failed = append(failed, newProviderError(a.provider, err))
+		if ctx.Err() != nil {
+			// The other providers would fail the same way
+			break
+		}
 	}
-	
-	return nil, fmt.Errorf("no available AI providers")
+	return nil, &RequestError{Errors: failed, Local: local}
+}
+
+// attempt is a provider to try, with the model its route asks for
+type attempt struct {
+	provider ProviderType
+	model    string
+}
+
+// requestPlan is a snapshot of what a request needs from the manager, so
+// that it runs without holding the lock
+type requestPlan struct {
+	attempts  []attempt
+	providers map[ProviderType]Provider
+	limiters  map[ProviderType]*Limiter
+	guard     Guard
+	onQueue   func()
+}
+
+// plan returns the providers to try for a request in order, with what
+// else the request needs. A request naming its provider tries only that.
+func (am *AIManager) plan(req AIRequest) requestPlan {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	var attempts []attempt
+	if req.Provider != "" {
+		attempts = append(attempts, attempt{provider: req.Provider})
+	} else {
+		if route, ok := am.routes[req.Type]; ok {
+			attempts = append(attempts, attempt{route.Provider, route.Model})
+		}
+		if am.activeProvider != "" {
+			attempts = append(attempts, attempt{provider: am.activeProvider})
+		}
+		for _, providerType := range am.fallbackOrder {
+			attempts = append(attempts, attempt{provider: providerType})
+		}
+	}
+	return requestPlan{
+		attempts:  attempts,
+		providers: maps.Clone(am.providers),
+		limiters:  maps.Clone(am.limiters),
+		guard:     am.guard,
+		onQueue:   am.onQueue,
+	}
+}
+
+// wait waits for the rate limit of a provider, if it has one
+func (p requestPlan) wait(ctx context.Context, providerType ProviderType, onQueued func(int)) error {
+	limiter := p.limiters[providerType]
+	if limiter == nil {
+		return nil
+	}
+	queued := false
+	err := limiter.Wait(ctx, func(position int) {
+		queued = true
+		if onQueued != nil {
+			onQueued(position)
+		}
+		if p.onQueue != nil {
+			p.onQueue()
+		}
+	})
+	if queued && p.onQueue != nil {
+		p.onQueue()
+	}
+	return err
 }
 
 // makeRequest makes a request to a specific provider based on request type
 func (am *AIManager) makeRequest(ctx context.Context, provider Provider, req AIRequest) (*AIResponse, error) {
 	switch req.Type {
 	case RequestCompletion:
-		return provider.Complete(ctx, req)
-	case RequestChat, RequestExplanation, RequestDebug, RequestDocumentation:
+		// The answer is inserted at the cursor, so it is made to fit there
+		response, err := provider.Complete(ctx, req)
+		if err == nil {
+			response.Content = normalizeCompletion(response.Content, req.Prompt)
+		}
+		return response, err
+	case RequestChat, RequestExplanation, RequestDebug, RequestDocumentation, RequestEdit:
 		return provider.Chat(ctx, req)
 	case RequestRefactor:
 		return provider.Analyze(ctx, req)
@@ -204,6 +463,8 @@ func (am *AIManager) makeRequest(ctx context.Context, provider Provider, req AIR
 
 // ConfigureProviders configures multiple providers from config
 func (am *AIManager) ConfigureProviders(configs []ProviderConfig) error {
+	configured := make(map[ProviderType]Provider)
+	var order []Provider
 	for _, config := range configs {
 		if !config.Enabled {
 			continue
@@ -217,7 +478,22 @@ func (am *AIManager) ConfigureProviders(configs []ProviderConfig) error {
 		if err := provider.Configure(config); err != nil {
 			continue // Skip failed configurations
 		}
+		am.SetRateLimit(config.Type, config.RateLimit, config.Burst)
 		
+		configured[config.Type] = provider
+		order = append(order, provider)
+	}
+
+	// A recording replay provider passes requests to the provider it records
+	for _, provider := range order {
+		if replay, ok := provider.(*ReplayProvider); ok && replay.Recording() != "" {
+			if live, ok := configured[replay.Recording()]; ok {
+				replay.SetLive(live)
+			}
+		}
+	}
+
+	for _, provider := range order {
 		am.RegisterProvider(provider)
 	}
 	
@@ -235,6 +511,8 @@ func CreateProvider(providerType ProviderType) (Provider, error) {
 		return NewGoogleProvider(), nil
 	case ProviderOllama:
 		return NewOllamaProvider(), nil
+	case ProviderReplay:
+		return NewReplayProvider(), nil
 	default:
 		return nil, fmt.Errorf("unknown provider type: %s", providerType)
 	}
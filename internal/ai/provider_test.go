@@ -138,6 +138,7 @@ func TestAIManager_RequestTypes(t *testing.T) {
 		{"Debug", RequestDebug, "chat"},
 		{"Documentation", RequestDocumentation, "chat"},
 		{"Refactor", RequestRefactor, "analyze"},
+		{"Edit", RequestEdit, "chat"},
 	}
 	
 	for _, tt := range tests {
@@ -261,6 +262,136 @@ func TestAIManager_NoProviders(t *testing.T) {
 	}
 }
 
+// localGuard keeps requests about the "private" project on local providers
+// and refuses requests about secret.txt
+type localGuard struct{}
+
+func (localGuard) Check(req AIRequest) (AIRequest, bool, error) {
+	if req.Filename == "secret.txt" {
+		return req, false, errors.New("blocked")
+	}
+	req.Prompt = "checked: " + req.Prompt
+	return req, req.Root == "private", nil
+}
+
+func TestAIManager_Guard(t *testing.T) {
+	ctx := context.Background()
+	manager := NewAIManager()
+	remote := NewMockProvider(ProviderOpenAI)
+	local := NewMockProvider(ProviderOllama)
+	manager.RegisterProvider(remote)
+	manager.RegisterProvider(local)
+	manager.SetActiveProvider(ProviderOpenAI)
+	manager.SetGuard(localGuard{})
+
+	resp, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat, Root: "private"})
+	if err != nil || resp.Provider != string(ProviderOllama) {
+		t.Fatalf("expected the local provider, got %+v, %v", resp, err)
+	}
+	if calls := local.GetChatCalls(); len(calls) != 1 || calls[0].Prompt != "checked: hi" {
+		t.Errorf("expected the checked request, got %+v", calls)
+	}
+
+	resp, err = manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat})
+	if err != nil || resp.Provider != string(ProviderOpenAI) {
+		t.Errorf("expected the active provider for other projects, got %+v, %v", resp, err)
+	}
+
+	if _, err := manager.Request(ctx, AIRequest{Prompt: "hi", Filename: "secret.txt"}); err == nil || err.Error() != "blocked" {
+		t.Errorf("expected the request refused, got %v", err)
+	}
+
+	local.SetAvailable(false)
+	if _, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat, Root: "private"}); err == nil {
+		t.Error("expected no fallback to remote providers for a local-only project")
+	}
+}
+
+func TestAIManager_Routes(t *testing.T) {
+	ctx := context.Background()
+	manager := NewAIManager()
+	openai := NewMockProvider(ProviderOpenAI)
+	ollama := NewMockProvider(ProviderOllama)
+	anthropic := NewMockProvider(ProviderAnthropic)
+	manager.RegisterProvider(openai)
+	manager.RegisterProvider(ollama)
+	manager.RegisterProvider(anthropic)
+	manager.SetActiveProvider(ProviderOpenAI)
+	manager.SetRoutes(map[RequestType]Route{
+		RequestCompletion: {Provider: ProviderOllama, Model: "codestral"},
+		RequestChat:       {Provider: ProviderAnthropic},
+	})
+
+	resp, err := manager.Request(ctx, AIRequest{Prompt: "func", Type: RequestCompletion})
+	if err != nil || resp.Provider != string(ProviderOllama) {
+		t.Fatalf("expected the routed provider, got %+v, %v", resp, err)
+	}
+	if calls := ollama.GetCompleteCalls(); len(calls) != 1 || calls[0].Model != "codestral" {
+		t.Errorf("expected the routed model, got %+v", calls)
+	}
+
+	resp, err = manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat, Model: "claude-3-opus"})
+	if err != nil || resp.Provider != string(ProviderAnthropic) {
+		t.Fatalf("expected the routed provider, got %+v, %v", resp, err)
+	}
+	if calls := anthropic.GetChatCalls(); len(calls) != 1 || calls[0].Model != "claude-3-opus" {
+		t.Errorf("expected the requested model to win over the route, got %+v", calls)
+	}
+
+	resp, err = manager.Request(ctx, AIRequest{Prompt: "why", Type: RequestExplanation})
+	if err != nil || resp.Provider != string(ProviderOpenAI) {
+		t.Errorf("expected the active provider for unrouted requests, got %+v, %v", resp, err)
+	}
+
+	// A failing route falls back to the active provider, without its model
+	ollama.SetCompleteFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return nil, errors.New("ollama failed")
+	})
+	resp, err = manager.Request(ctx, AIRequest{Prompt: "func", Type: RequestCompletion})
+	if err != nil || resp.Provider != string(ProviderOpenAI) {
+		t.Fatalf("expected the active provider, got %+v, %v", resp, err)
+	}
+	if calls := openai.GetCompleteCalls(); len(calls) != 1 || calls[0].Model != "" {
+		t.Errorf("expected the active provider's own model, got %+v", calls)
+	}
+
+	// A request naming its provider goes there alone
+	resp, err = manager.Request(ctx, AIRequest{Prompt: "func", Type: RequestCompletion, Provider: ProviderAnthropic, Model: "claude-3-5-haiku"})
+	if err != nil || resp.Provider != string(ProviderAnthropic) {
+		t.Fatalf("expected the named provider, got %+v, %v", resp, err)
+	}
+	if calls := anthropic.GetCompleteCalls(); len(calls) != 1 || calls[0].Model != "claude-3-5-haiku" {
+		t.Errorf("expected the named model, got %+v", calls)
+	}
+	if _, err := manager.Request(ctx, AIRequest{Prompt: "func", Type: RequestCompletion, Provider: ProviderOllama}); err == nil {
+		t.Error("expected no fallback from a named provider")
+	}
+}
+
+func TestAIManager_FallbackOrder(t *testing.T) {
+	ctx := context.Background()
+	manager := NewAIManager()
+	failing := NewMockProvider(ProviderOpenAI)
+	failing.SetChatFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return nil, errors.New("failed")
+	})
+	manager.RegisterProvider(failing)
+	manager.RegisterProvider(NewMockProvider(ProviderOllama))
+	manager.RegisterProvider(NewMockProvider(ProviderGoogle))
+	manager.SetActiveProvider(ProviderOpenAI)
+
+	manager.SetFallbackOrder([]ProviderType{ProviderGoogle, ProviderOllama})
+	resp, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat})
+	if err != nil || resp.Provider != string(ProviderGoogle) {
+		t.Errorf("expected the first provider of the fallback order, got %+v, %v", resp, err)
+	}
+
+	manager.SetFallbackOrder(nil)
+	if _, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat}); err == nil {
+		t.Error("expected no fallback without a fallback order")
+	}
+}
+
 func TestAIManager_ContextCancellation(t *testing.T) {
 	manager := NewAIManager()
 	provider := NewMockProvider("test-provider")
@@ -322,4 +453,20 @@ func TestCreateProvider(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcde", 2},
+		{"héllo wörld", 3},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket limiting how often a provider is sent requests.
+// Requests over the limit wait in a queue and are let through in order.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64 // most tokens the bucket holds
+	tokens  float64
+	last    time.Time
+	queue   []*int        // one entry per waiting request, compared by address
+	changed chan struct{} // closed and replaced whenever the queue moves
+}
+
+// NewLimiter allows perMinute requests a minute on average, which must be
+// more than 0, and up to burst at once. A burst below 1 allows one.
+func NewLimiter(perMinute float64, burst int) *Limiter {
+	b := float64(max(burst, 1))
+	return &Limiter{
+		rate:    perMinute / 60,
+		burst:   b,
+		tokens:  b,
+		last:    time.Now(),
+		changed: make(chan struct{}),
+	}
+}
+
+// Wait blocks until the request may be sent or ctx is done. While it waits,
+// onQueued, if not nil, is called with its place in the queue, 1 being next,
+// whenever that changes.
+func (l *Limiter) Wait(ctx context.Context, onQueued func(position int)) error {
+	self := new(int)
+	l.mu.Lock()
+	l.queue = append(l.queue, self)
+	reported := 0
+	for {
+		l.refill()
+		position := slices.Index(l.queue, self) + 1
+		if position == 1 && l.tokens >= 1 {
+			l.tokens--
+			l.leave(self)
+			l.mu.Unlock()
+			return nil
+		}
+
+		// Wait for the next token when first in line, or for the queue to
+		// move otherwise
+		var timer *time.Timer
+		var fired <-chan time.Time
+		if position == 1 {
+			timer = time.NewTimer(time.Duration((1 - l.tokens) / l.rate * float64(time.Second)))
+			fired = timer.C
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		if position != reported && onQueued != nil {
+			onQueued(position)
+		}
+		reported = position
+
+		select {
+		case <-ctx.Done():
+		case <-fired:
+		case <-changed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		l.mu.Lock()
+		if ctx.Err() != nil {
+			l.leave(self)
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// Queued returns the number of requests waiting
+func (l *Limiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.queue)
+}
+
+// refill adds the tokens earned since the last refill
+func (l *Limiter) refill() {
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// leave takes a request out of the queue and wakes the others
+func (l *Limiter) leave(self *int) {
+	if i := slices.Index(l.queue, self); i >= 0 {
+		l.queue = slices.Delete(l.queue, i, i+1)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Burst(t *testing.T) {
+	limiter := NewLimiter(60, 3)
+	ctx := context.Background()
+	for i := range 3 {
+		if err := limiter.Wait(ctx, func(int) { t.Errorf("request %d queued within the burst", i) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	var positions []int
+	err := limiter.Wait(ctx, func(position int) { positions = append(positions, position) })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected to wait past the burst, got %v", err)
+	}
+	if len(positions) != 1 || positions[0] != 1 {
+		t.Errorf("expected to be first in the queue, got %v", positions)
+	}
+	if queued := limiter.Queued(); queued != 0 {
+		t.Errorf("expected a canceled request to leave the queue, got %d waiting", queued)
+	}
+}
+
+func TestLimiter_Order(t *testing.T) {
+	// A token every 10ms
+	limiter := NewLimiter(6000, 1)
+	ctx := context.Background()
+	if err := limiter.Wait(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 3)
+	for i := range 3 {
+		queued := make(chan struct{})
+		go func() {
+			limiter.Wait(ctx, func(position int) {
+				if position == i+1 {
+					close(queued)
+				}
+			})
+			order <- i
+		}()
+		// Queue the requests one after another
+		select {
+		case <-queued:
+		case <-time.After(time.Second):
+			t.Fatalf("request %d wasn't queued at position %d", i, i+1)
+		}
+	}
+	for want := range 3 {
+		if got := <-order; got != want {
+			t.Errorf("expected request %d next, got %d", want, got)
+		}
+	}
+}
+
+func TestAIManager_RateLimit(t *testing.T) {
+	manager := NewAIManager()
+	manager.RegisterProvider(NewMockProvider(ProviderOpenAI))
+	manager.SetRateLimit(ProviderOpenAI, 1, 1)
+	notified := make(chan struct{}, 10)
+	manager.SetQueueNotify(func() { notified <- struct{}{} })
+
+	ctx := context.Background()
+	if _, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var position int
+	done := make(chan error)
+	go func() {
+		_, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat, OnQueued: func(p int) { position = p }})
+		done <- err
+	}()
+	<-notified
+	if queued := manager.Queued(); queued != 1 {
+		t.Errorf("expected one request queued, got %d", queued)
+	}
+	cancel()
+	var reqErr *RequestError
+	if err := <-done; !errors.As(err, &reqErr) || reqErr.Errors[0].Kind != ErrorTimeout {
+		t.Errorf("expected the queued request to be canceled, got %v", err)
+	}
+	if position != 1 {
+		t.Errorf("expected queue position 1, got %d", position)
+	}
+	if queued := manager.Queued(); queued != 0 {
+		t.Errorf("expected an empty queue, got %d", queued)
+	}
+
+	manager.SetRateLimit(ProviderOpenAI, 0, 0)
+	if _, err := manager.Request(context.Background(), AIRequest{Prompt: "hi", Type: RequestChat}); err != nil {
+		t.Errorf("expected no limit after removing it, got %v", err)
+	}
+}
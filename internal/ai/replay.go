@@ -0,0 +1,178 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReplayProvider answers requests with responses recorded to a directory,
+// for demos and tests without network access or costs. When it records, it
+// passes requests to a live provider and saves what it answers.
+type ReplayProvider struct {
+	dir    string
+	record ProviderType // Provider recorded from, empty when replaying
+	live   Provider
+}
+
+// Recording is a response saved by ReplayProvider, with the request it
+// answers for reference
+type Recording struct {
+	Type        RequestType `json:"type"`
+	Prompt      string      `json:"prompt"`
+	Context     string      `json:"context,omitempty"`
+	Language    string      `json:"language,omitempty"`
+	Model       string      `json:"model,omitempty"`
+	Content     string      `json:"content"`
+	Suggestions []string    `json:"suggestions,omitempty"`
+	Provider    string      `json:"provider"`
+	RecordedBy  string      `json:"recorded_model,omitempty"`
+}
+
+func NewReplayProvider() *ReplayProvider {
+	return &ReplayProvider{}
+}
+
+func (p *ReplayProvider) Name() ProviderType {
+	return ProviderReplay
+}
+
+// IsAvailable reports whether there is a live provider to record from, or
+// recordings to replay
+func (p *ReplayProvider) IsAvailable() bool {
+	if p.record != "" {
+		return p.live != nil && p.live.IsAvailable()
+	}
+	info, err := os.Stat(p.dir)
+	return err == nil && info.IsDir()
+}
+
+// Configure reads the "dir" option, where recordings are kept, and the
+// "record" option, naming the provider to record from
+func (p *ReplayProvider) Configure(config ProviderConfig) error {
+	dir, _ := config.Options["dir"].(string)
+	if dir == "" {
+		return fmt.Errorf("replay provider requires a dir option")
+	}
+	p.dir = expandHome(dir)
+	if record, ok := config.Options["record"].(string); ok && record != "" {
+		if !ProviderType(record).Valid() || ProviderType(record) == ProviderReplay {
+			return fmt.Errorf("replay provider can't record from %q", record)
+		}
+		p.record = ProviderType(record)
+	}
+	return nil
+}
+
+// Recording returns the provider the replay provider records from, or ""
+// when it replays
+func (p *ReplayProvider) Recording() ProviderType {
+	return p.record
+}
+
+// SetLive sets the provider requests are passed to while recording
+func (p *ReplayProvider) SetLive(live Provider) {
+	p.live = live
+}
+
+func (p *ReplayProvider) Complete(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	return p.answer(ctx, req, func(live Provider) (*AIResponse, error) { return live.Complete(ctx, req) })
+}
+
+func (p *ReplayProvider) Chat(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	return p.answer(ctx, req, func(live Provider) (*AIResponse, error) { return live.Chat(ctx, req) })
+}
+
+func (p *ReplayProvider) Analyze(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	return p.answer(ctx, req, func(live Provider) (*AIResponse, error) { return live.Analyze(ctx, req) })
+}
+
+// answer records the live provider's answer to req, or replays the one
+// recorded
+func (p *ReplayProvider) answer(ctx context.Context, req AIRequest, ask func(Provider) (*AIResponse, error)) (*AIResponse, error) {
+	path := filepath.Join(p.dir, recordingKey(req)+".json")
+
+	if p.record != "" {
+		if p.live == nil {
+			return nil, fmt.Errorf("replay provider has no %s provider to record from", p.record)
+		}
+		resp, err := ask(p.live)
+		if err != nil {
+			return nil, err
+		}
+		rec := Recording{
+			Type:        req.Type,
+			Prompt:      req.Prompt,
+			Context:     req.Context,
+			Language:    req.Language,
+			Model:       req.Model,
+			Content:     resp.Content,
+			Suggestions: resp.Suggestions,
+			Provider:    resp.Provider,
+			RecordedBy:  resp.Model,
+		}
+		if err := writeRecording(path, rec); err != nil {
+			return nil, fmt.Errorf("failed to save recording: %w", err)
+		}
+		return resp, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded response for this %s request", req.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to read recording %s: %w", path, err)
+	}
+	return &AIResponse{
+		Content:     rec.Content,
+		Suggestions: rec.Suggestions,
+		Confidence:  1.0,
+		Provider:    string(ProviderReplay),
+		Model:       rec.RecordedBy,
+	}, nil
+}
+
+// recordingKey identifies the recording of a request by what affects the
+// answer
+func recordingKey(req AIRequest) string {
+	key, _ := json.Marshal([]string{string(req.Type), req.Prompt, req.Context, req.Language, req.Model})
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeRecording saves rec to path, creating its directory
+func writeRecording(path string, rec Recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
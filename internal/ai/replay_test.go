@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayProvider_Configure(t *testing.T) {
+	provider := NewReplayProvider()
+	if err := provider.Configure(ProviderConfig{Type: ProviderReplay}); err == nil {
+		t.Error("expected an error without a dir option")
+	}
+	if err := provider.Configure(ProviderConfig{Type: ProviderReplay, Options: map[string]interface{}{"dir": t.TempDir(), "record": "replay"}}); err == nil {
+		t.Error("expected an error recording from itself")
+	}
+	if err := provider.Configure(ProviderConfig{Type: ProviderReplay, Options: map[string]interface{}{"dir": t.TempDir()}}); err != nil {
+		t.Fatal(err)
+	}
+	if !provider.IsAvailable() {
+		t.Error("expected replaying from an existing directory to be available")
+	}
+}
+
+func TestReplayProvider_RecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "recordings")
+	live := NewMockProvider(ProviderOpenAI)
+	live.SetChatFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		return &AIResponse{Content: "live answer to " + req.Prompt, Provider: string(ProviderOpenAI), Model: "gpt-4"}, nil
+	})
+
+	recorder := NewReplayProvider()
+	recorder.Configure(ProviderConfig{Type: ProviderReplay, Options: map[string]interface{}{"dir": dir, "record": "openai"}})
+	if recorder.IsAvailable() {
+		t.Error("expected recording without a live provider to be unavailable")
+	}
+	recorder.SetLive(live)
+
+	req := AIRequest{Prompt: "what is a goroutine", Type: RequestChat, Language: "go"}
+	resp, err := recorder.Chat(ctx, req)
+	if err != nil || resp.Content != "live answer to what is a goroutine" {
+		t.Fatalf("expected the live answer while recording, got %+v, %v", resp, err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected one recording, got %d", len(files))
+	}
+
+	// Replaying needs no live provider
+	player := NewReplayProvider()
+	player.Configure(ProviderConfig{Type: ProviderReplay, Options: map[string]interface{}{"dir": dir}})
+	resp, err = player.Chat(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "live answer to what is a goroutine" || resp.Provider != string(ProviderReplay) || resp.Model != "gpt-4" {
+		t.Errorf("expected the recorded answer, got %+v", resp)
+	}
+	if len(live.GetChatCalls()) != 1 {
+		t.Errorf("expected replaying not to reach the live provider, got %d calls", len(live.GetChatCalls()))
+	}
+
+	req.Prompt = "something else"
+	if _, err := player.Chat(ctx, req); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected an error for a request never recorded, got %v", err)
+	}
+}
+
+func TestAIManager_ConfigureReplay(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewAIManager()
+	manager.ConfigureProviders([]ProviderConfig{
+		{Type: ProviderReplay, Enabled: true, Options: map[string]interface{}{"dir": dir, "record": "ollama"}},
+		{Type: ProviderOllama, Enabled: true, BaseURL: "http://127.0.0.1:1"},
+	})
+	provider, ok := manager.GetProvider(ProviderReplay)
+	if !ok {
+		t.Fatal("expected the replay provider to be registered")
+	}
+	if live := provider.(*ReplayProvider).live; live == nil || live.Name() != ProviderOllama {
+		t.Errorf("expected the replay provider to record from ollama, got %v", live)
+	}
+}
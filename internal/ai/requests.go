@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// RequestID identifies a request started with Go
+type RequestID uint64
+
+// Result is the outcome of a request started with Go. Request carries what
+// the caller needs to match it to where it came from, like the file.
+type Result struct {
+	ID       RequestID
+	Request  AIRequest
+	Response *AIResponse
+	Err      error
+}
+
+// Go starts req in the background and returns its ID. done is called from
+// the request's goroutine with the result, also when it was canceled, its
+// error then context.Canceled.
+func (am *AIManager) Go(ctx context.Context, req AIRequest, done func(Result)) RequestID {
+	ctx, cancel := context.WithCancel(ctx)
+
+	am.mu.Lock()
+	am.nextID++
+	id := am.nextID
+	am.inFlight[id] = cancel
+	am.mu.Unlock()
+
+	req.ID = id
+	go func() {
+		defer cancel()
+		resp, err := am.Request(ctx, req)
+		if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+			// Canceled, however the providers put it
+			err = context.Canceled
+		}
+
+		am.mu.Lock()
+		delete(am.inFlight, id)
+		am.mu.Unlock()
+
+		if done != nil {
+			done(Result{ID: id, Request: req, Response: resp, Err: err})
+		}
+	}()
+	return id
+}
+
+// Cancel cancels a request started with Go, and reports whether it was
+// still running
+func (am *AIManager) Cancel(id RequestID) bool {
+	am.mu.Lock()
+	cancel, ok := am.inFlight[id]
+	am.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// InFlight returns the IDs of the requests started with Go that are still
+// running, oldest first
+func (am *AIManager) InFlight() []RequestID {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	ids := make([]RequestID, 0, len(am.inFlight))
+	for id := range am.inFlight {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAIManager_Go(t *testing.T) {
+	manager := NewAIManager()
+	provider := NewMockProvider(ProviderOllama)
+	release := make(chan struct{})
+	provider.SetChatFunc(func(ctx context.Context, req AIRequest) (*AIResponse, error) {
+		select {
+		case <-release:
+			return &AIResponse{Content: "answer to " + req.Prompt, Provider: string(ProviderOllama)}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	manager.RegisterProvider(provider)
+
+	results := make(chan Result, 3)
+	done := func(r Result) { results <- r }
+	first := manager.Go(context.Background(), AIRequest{Prompt: "first", Type: RequestChat, Filename: "a.go"}, done)
+	second := manager.Go(context.Background(), AIRequest{Prompt: "second", Type: RequestChat, Filename: "b.go"}, done)
+	third := manager.Go(context.Background(), AIRequest{Prompt: "third", Type: RequestChat}, done)
+	if first == second || second == third {
+		t.Fatalf("expected distinct IDs, got %d, %d, %d", first, second, third)
+	}
+	if inFlight := manager.InFlight(); len(inFlight) != 3 || inFlight[0] != first {
+		t.Errorf("expected three requests in flight, got %v", inFlight)
+	}
+
+	if !manager.Cancel(third) {
+		t.Error("expected the third request to be canceled")
+	}
+	r := <-results
+	if r.ID != third || !errors.Is(r.Err, context.Canceled) {
+		t.Errorf("expected the canceled request to fail as canceled, got %+v", r)
+	}
+
+	close(release)
+	for range 2 {
+		r := <-results
+		if r.Err != nil {
+			t.Fatalf("request %d failed: %v", r.ID, r.Err)
+		}
+		if r.Response.RequestID != r.ID || r.Response.Content != "answer to "+r.Request.Prompt {
+			t.Errorf("response doesn't match its request: %+v", r)
+		}
+		if r.ID == first && r.Request.Filename != "a.go" || r.ID == second && r.Request.Filename != "b.go" {
+			t.Errorf("expected the request's file with its result, got %+v", r.Request)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(manager.InFlight()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if inFlight := manager.InFlight(); len(inFlight) != 0 {
+		t.Errorf("expected no requests in flight, got %v", inFlight)
+	}
+	if manager.Cancel(first) {
+		t.Error("expected a finished request not to be canceled")
+	}
+}
+
+func TestAIManager_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	manager := NewAIManager()
+	manager.RegisterProvider(NewMockProvider(ProviderOllama))
+	manager.RegisterProvider(NewMockProvider(ProviderOpenAI))
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := manager.Request(ctx, AIRequest{Prompt: "hi", Type: RequestChat}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				manager.SetActiveProvider(ProviderOpenAI)
+			} else {
+				manager.SetActiveProvider(ProviderOllama)
+			}
+			manager.SetRoutes(map[RequestType]Route{RequestChat: {Provider: ProviderOllama}})
+			manager.ListAvailableProviders()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,19 @@
+package ai
+
+import "encoding/json"
+
+// Tool is a function a model may call instead of answering in text,
+// described by a JSON schema of its input. Providers that support tool use
+// send the tools of a request along with it.
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema map[string]any // JSON schema of the input object
+}
+
+// ToolCall is a call of one of the request's tools a model answered with
+type ToolCall struct {
+	ID    string // Identifies the call, for sending its result back
+	Name  string
+	Input json.RawMessage // The input object, as the tool's schema describes it
+}
@@ -0,0 +1,68 @@
+// Package batch runs ex commands on files without the terminal UI, for
+// aied --ex in scripts and CI.
+package batch
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+)
+
+// Run opens each file, runs the command lines on it in order and writes it
+// when they leave it modified. prepare, if set, applies the buffer options
+// to each file once opened, and executor runs the commands, with what its
+// context holds. The messages of the commands go to out.
+//
+// A file whose command fails is left as it is on disk and the remaining
+// files are still run; the error then tells how many failed.
+func Run(executor *commands.CommandExecutor, files, lines []string, prepare func(*buffer.Buffer), out io.Writer) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no files to run the commands on")
+	}
+	failed := 0
+	for _, file := range files {
+		if err := runFile(executor, file, lines, prepare, out); err != nil {
+			fmt.Fprintf(out, "%s: %v\n", file, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(files))
+	}
+	return nil
+}
+
+// runFile runs the command lines on one file
+func runFile(executor *commands.CommandExecutor, file string, lines []string, prepare func(*buffer.Buffer), out io.Writer) error {
+	buf, err := buffer.NewFromFile(file)
+	if err != nil {
+		return err
+	}
+	if prepare != nil {
+		prepare(buf)
+	}
+	executor.State().ApplyModeline(buf)
+
+	for _, line := range lines {
+		result := executor.Execute(line, buf)
+		if !result.Success {
+			return fmt.Errorf(":%s: %s", line, result.Message)
+		}
+		if result.ExitEditor {
+			// :q! leaves the file as it is, :wq has written it
+			return nil
+		}
+		if result.Message != "" {
+			fmt.Fprintf(out, "%s: %s\n", file, result.Message)
+		}
+	}
+
+	if buf.Modified() {
+		if err := buf.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+)
+
+// writeFile creates a file with content in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readFile returns the content of path
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRunWritesChangedFiles(t *testing.T) {
+	a := writeFile(t, "a.txt", "foo one  \nfoo two")
+	b := writeFile(t, "b.txt", "nothing here\n")
+
+	var out strings.Builder
+	err := Run(commands.NewCommandExecutor(), []string{a, b}, []string{"%s/foo/bar/ge", "StripWhitespace"}, nil, &out)
+	if err != nil {
+		t.Fatalf("Run() = %v\n%s", err, out.String())
+	}
+	if got := readFile(t, a); got != "bar one\nbar two" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if got := readFile(t, b); got != "nothing here\n" {
+		t.Errorf("b.txt = %q", got)
+	}
+	if !strings.Contains(out.String(), a+": ") {
+		t.Errorf("expected the messages prefixed by the file, got %q", out.String())
+	}
+}
+
+func TestRunLeavesFailedFiles(t *testing.T) {
+	a := writeFile(t, "a.txt", "foo\n")
+	b := writeFile(t, "b.txt", "foo\n")
+
+	var out strings.Builder
+	err := Run(commands.NewCommandExecutor(), []string{a, b}, []string{"%s/foo/bar/", "nosuchcommand"}, nil, &out)
+	if err == nil || err.Error() != "2 of 2 files failed" {
+		t.Errorf("Run() = %v", err)
+	}
+	if got := readFile(t, a); got != "foo\n" {
+		t.Errorf("a.txt changed to %q", got)
+	}
+	if !strings.Contains(out.String(), ":nosuchcommand: ") {
+		t.Errorf("expected the failing command reported, got %q", out.String())
+	}
+}
+
+func TestRunQuitWithoutWriting(t *testing.T) {
+	a := writeFile(t, "a.txt", "foo\n")
+	if err := Run(commands.NewCommandExecutor(), []string{a}, []string{"%s/foo/bar/", "q!"}, nil, &strings.Builder{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, a); got != "foo\n" {
+		t.Errorf("a.txt = %q after :q!", got)
+	}
+}
+
+func TestRunPreparesBuffers(t *testing.T) {
+	a := writeFile(t, "a.txt", "x\n")
+	prepared := false
+	Run(commands.NewCommandExecutor(), []string{a}, []string{"s/x/y/"}, func(buf *buffer.Buffer) { prepared = true }, &strings.Builder{})
+	if !prepared {
+		t.Error("expected prepare called on the buffer")
+	}
+	if err := Run(commands.NewCommandExecutor(), nil, []string{"w"}, nil, &strings.Builder{}); err == nil {
+		t.Error("expected an error without files")
+	}
+}
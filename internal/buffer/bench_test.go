@@ -0,0 +1,99 @@
+package buffer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchLines returns n lines of Go-like text
+func benchLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("\tresult%d := compute(%d, \"value\") // line %d", i, i*7, i)
+	}
+	return lines
+}
+
+// benchBuffer returns a buffer holding n lines
+func benchBuffer(n int) *Buffer {
+	buf := New()
+	buf.ReplaceLines(0, 0, benchLines(n))
+	return buf
+}
+
+func BenchmarkInsertChar(b *testing.B) {
+	buf := benchBuffer(10000)
+	i := 0
+	for b.Loop() {
+		// Spread the inserts over the lines so no line grows very long
+		if i%100 == 0 {
+			buf.SetCursor(Position{Line: i / 100 % buf.LineCount(), Col: 10})
+		}
+		if err := buf.InsertChar('x'); err != nil {
+			b.Fatal(err)
+		}
+		i++
+	}
+}
+
+func BenchmarkDeleteLine(b *testing.B) {
+	buf := benchBuffer(100000)
+	for b.Loop() {
+		if buf.LineCount() < 1000 {
+			b.StopTimer()
+			buf = benchBuffer(100000)
+			b.StartTimer()
+		}
+		buf.SetCursor(Position{Line: buf.LineCount() / 2})
+		if err := buf.DeleteLine(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoad100kLines(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "large.go")
+	if err := os.WriteFile(filename, []byte(strings.Join(benchLines(100000), "\n")+"\n"), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	buf := New()
+	for b.Loop() {
+		if err := buf.Load(filename); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if buf.LineCount() != 100000 {
+		b.Fatalf("loaded %d lines, want 100000", buf.LineCount())
+	}
+}
+
+// budgets are the slowest the benchmarks may run per operation. They are
+// loose enough for slow CI machines and the race detector, and catch an
+// operation becoming many times slower, like going from constant to linear
+// time.
+var budgets = []struct {
+	name   string
+	bench  func(*testing.B)
+	budget time.Duration
+}{
+	{"InsertChar", BenchmarkInsertChar, 10 * time.Microsecond},
+	{"DeleteLine", BenchmarkDeleteLine, 25 * time.Millisecond},
+	{"Load100kLines", BenchmarkLoad100kLines, time.Second},
+}
+
+func TestPerformanceBudgets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("benchmarks are slow")
+	}
+	for _, tt := range budgets {
+		result := testing.Benchmark(tt.bench)
+		if perOp := time.Duration(result.NsPerOp()); perOp > tt.budget {
+			t.Errorf("%s takes %v per operation, over its budget of %v", tt.name, perOp, tt.budget)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package buffer
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/filetype"
 )
 
 // Position represents a cursor position in the buffer
@@ -22,6 +23,34 @@ type Diagnostic struct {
 	Source   string
 }
 
+// Options holds per-buffer editing options
+type Options struct {
+	TrimTrailingWhitespace bool          // Strip trailing whitespace when saving
+	TextWidth              int           // Maximum line width used by gq (0 means 79)
+	Backup                 BackupOptions // Numbered backups made when saving
+	TabStop                int           // Width of a tab and of the Tab key's indent (0 means 4)
+	ShiftWidth             int           // Columns Ctrl-T and Ctrl-D shift by (0 means TabStop)
+	IndentTabs             bool          // Indent with tab characters instead of spaces
+	ReadOnly               bool          // Refuse to write the buffer
+	Scratch                string        // Name of the scratch buffer, which has no file and no unsaved changes; empty for a file's
+}
+
+// TabWidth returns the tab stop, applying the default
+func (o Options) TabWidth() int {
+	if o.TabStop > 0 {
+		return o.TabStop
+	}
+	return 4
+}
+
+// IndentWidth returns the shift width, applying the defaults
+func (o Options) IndentWidth() int {
+	if o.ShiftWidth > 0 {
+		return o.ShiftWidth
+	}
+	return o.TabWidth()
+}
+
 // Buffer represents a text buffer with cursor tracking
 type Buffer struct {
 	lines       []string      // Text content stored as lines
@@ -29,47 +58,89 @@ type Buffer struct {
 	filename    string        // Associated filename (empty for new buffer)
 	modified    bool          // Whether buffer has unsaved changes
 	diagnostics []Diagnostic  // LSP diagnostics for this buffer
+	options     Options       // Per-buffer editing options
+	extraCursors []Position   // Secondary cursors for multi-cursor editing
+	filetype     string       // Detected or set filetype
+	filetypeFor  string       // Filename the filetype was detected for
+	filetypeSet  bool         // Whether the filetype was set explicitly
+	undo         *undoTree    // Undo history
+	visual       [2]Position  // Last visual selection, for the '< and '> addresses
+	visualSet    bool         // Whether there has been a visual selection
+	changes      changeLog    // Which lines changed, for renders and LSP syncs
+	localDir     string       // Working directory set with :lcd, empty for the editor's
+	writeWarnings []string    // What went wrong in the last write that still wrote the file
 }
 
 // New creates a new empty buffer
 func New() *Buffer {
-	return &Buffer{
+	b := &Buffer{
 		lines:    []string{""},
 		cursor:   Position{Line: 0, Col: 0},
 		filename: "",
 		modified: false,
 	}
+	b.resetUndo()
+	return b
 }
 
 // NewFromFile creates a buffer from an existing file
 func NewFromFile(filename string) (*Buffer, error) {
-	file, err := os.Open(filename)
+	lines, err := readLines(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %q: %w", filename, err)
+		return nil, err
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	b := &Buffer{
+		lines:    lines,
+		cursor:   Position{Line: 0, Col: 0},
+		filename: filename,
+		modified: false,
 	}
+	b.resetUndo()
+	return b, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read file %q: %w", filename, err)
+// Load replaces the buffer content with a file, resetting the cursor,
+// diagnostics, undo history and modified state
+func (b *Buffer) Load(filename string) error {
+	lines, err := readLines(filename)
+	if err != nil {
+		return err
 	}
 
-	// Ensure at least one empty line
+	b.noteChange(0, len(b.lines), len(lines))
+	b.markSaved()
+	b.lines = lines
+	b.filename = filename
+	b.cursor = Position{}
+	b.diagnostics = nil
+	b.extraCursors = nil
+	b.filetypeSet = false
+	b.filetypeFor = ""
+	b.options.Scratch = ""
+	b.setModified(false)
+	b.resetUndo()
+	return nil
+}
+
+// LoadText replaces the buffer content with lines not read from a file,
+// like the text of a scratch buffer, leaving the buffer without a filename
+// and resetting the cursor, diagnostics and undo history as Load does
+func (b *Buffer) LoadText(lines []string) {
 	if len(lines) == 0 {
 		lines = []string{""}
 	}
-
-	return &Buffer{
-		lines:    lines,
-		cursor:   Position{Line: 0, Col: 0},
-		filename: filename,
-		modified: false,
-	}, nil
+	b.noteChange(0, len(b.lines), len(lines))
+	b.markSaved()
+	b.lines = append([]string(nil), lines...)
+	b.filename = ""
+	b.cursor = Position{}
+	b.diagnostics = nil
+	b.extraCursors = nil
+	b.filetypeSet = false
+	b.filetypeFor = ""
+	b.setModified(false)
+	b.resetUndo()
 }
 
 // LineCount returns the number of lines in the buffer
@@ -98,6 +169,19 @@ func (b *Buffer) Cursor() Position {
 	return b.cursor
 }
 
+// ByteOffset returns how many bytes of the buffer's text, lines joined by
+// newlines, come before pos
+func (b *Buffer) ByteOffset(pos Position) int {
+	offset := 0
+	for i := 0; i < pos.Line && i < len(b.lines); i++ {
+		offset += len(b.lines[i]) + 1
+	}
+	if pos.Line >= 0 && pos.Line < len(b.lines) {
+		offset += min(max(pos.Col, 0), len(b.lines[pos.Line]))
+	}
+	return offset
+}
+
 // SetCursor moves the cursor to the specified position with bounds checking
 func (b *Buffer) SetCursor(pos Position) {
 	// Clamp line to valid range
@@ -108,21 +192,39 @@ func (b *Buffer) SetCursor(pos Position) {
 	}
 
 	// Clamp column to valid range for the line
-	lineLen := len(b.lines[pos.Line])
+	line := b.lines[pos.Line]
 	if pos.Col < 0 {
 		pos.Col = 0
-	} else if pos.Col > lineLen {
-		pos.Col = lineLen
+	} else if pos.Col > len(line) {
+		pos.Col = len(line)
+	}
+
+	// Keep the cursor off the middle of a multi-byte character
+	for pos.Col > 0 && pos.Col < len(line) && !utf8.RuneStart(line[pos.Col]) {
+		pos.Col--
 	}
 
 	b.cursor = pos
 }
 
-// MoveCursor moves the cursor by the specified delta
+// MoveCursor moves the cursor by the specified delta, counting columns in
+// characters rather than bytes
 func (b *Buffer) MoveCursor(deltaLine, deltaCol int) {
+	col := b.cursor.Col
+	if b.cursor.Line >= 0 && b.cursor.Line < len(b.lines) {
+		line := b.lines[b.cursor.Line]
+		for ; deltaCol > 0 && col < len(line); deltaCol-- {
+			_, size := utf8.DecodeRuneInString(line[col:])
+			col += size
+		}
+		for ; deltaCol < 0 && col > 0 && col <= len(line); deltaCol++ {
+			_, size := utf8.DecodeLastRuneInString(line[:col])
+			col -= size
+		}
+	}
 	newPos := Position{
 		Line: b.cursor.Line + deltaLine,
-		Col:  b.cursor.Col + deltaCol,
+		Col:  col + deltaCol,
 	}
 	b.SetCursor(newPos)
 }
@@ -137,9 +239,71 @@ func (b *Buffer) SetFilename(filename string) {
 	b.filename = filename
 }
 
-// Modified returns whether the buffer has unsaved changes
+// LocalDir returns the working directory set for the buffer with :lcd, or
+// an empty string when it uses the editor's
+func (b *Buffer) LocalDir() string {
+	return b.localDir
+}
+
+// SetLocalDir sets the buffer's own working directory, which relative file
+// names are taken from; an empty dir returns to the editor's
+func (b *Buffer) SetLocalDir(dir string) {
+	b.localDir = dir
+}
+
+// Filetype returns the buffer's filetype: the one set with SetFiletype, or
+// else the one detected from the filename and content when first asked
+func (b *Buffer) Filetype() string {
+	if !b.filetypeSet && (b.filetype == "" || b.filetypeFor != b.filename) {
+		b.filetype = filetype.Detect(b.filename, b.lines)
+		b.filetypeFor = b.filename
+	}
+	return b.filetype
+}
+
+// SetFiletype overrides the detected filetype; an empty filetype returns
+// to detection
+func (b *Buffer) SetFiletype(ft string) {
+	b.filetype = ft
+	b.filetypeFor = b.filename
+	b.filetypeSet = ft != ""
+}
+
+// SetVisualArea records the last visual selection, from start to end
+func (b *Buffer) SetVisualArea(start, end Position) {
+	b.visual = [2]Position{start, end}
+	b.visualSet = true
+}
+
+// VisualArea returns the last visual selection and whether there was one
+func (b *Buffer) VisualArea() (Position, Position, bool) {
+	return b.visual[0], b.visual[1], b.visualSet
+}
+
+// InVisualArea reports whether the text from start up to end lies within
+// the last visual selection, whose end character is included
+func (b *Buffer) InVisualArea(start, end Position) bool {
+	if !b.visualSet {
+		return false
+	}
+	last := Position{Line: b.visual[1].Line, Col: b.visual[1].Col + 1}
+	return !positionLess(start, b.visual[0]) && !positionLess(last, end)
+}
+
+// Options returns the buffer's editing options
+func (b *Buffer) Options() Options {
+	return b.options
+}
+
+// SetOptions sets the buffer's editing options
+func (b *Buffer) SetOptions(options Options) {
+	b.options = options
+}
+
+// Modified returns whether the buffer has unsaved changes, which a
+// scratch buffer never has
 func (b *Buffer) Modified() bool {
-	return b.modified
+	return b.modified && b.options.Scratch == ""
 }
 
 // setModified marks the buffer as modified or unmodified
@@ -171,11 +335,13 @@ func (b *Buffer) InsertChar(ch rune) error {
 	}
 
 	// Insert character at cursor position
-	newLine := line[:b.cursor.Col] + string(ch) + line[b.cursor.Col:]
+	text := string(ch)
+	newLine := line[:b.cursor.Col] + text + line[b.cursor.Col:]
 	b.lines[b.cursor.Line] = newLine
+	b.noteChange(b.cursor.Line, 1, 1)
 
-	// Move cursor forward
-	b.cursor.Col++
+	// Move cursor past the character's bytes
+	b.cursor.Col += len(text)
 	b.setModified(true)
 
 	return nil
@@ -195,6 +361,7 @@ func (b *Buffer) InsertTextAt(line, col int, text string) error {
 	// Insert text at the specified position
 	newLine := lineContent[:col] + text + lineContent[col:]
 	b.lines[line] = newLine
+	b.noteChange(line, 1, 1)
 	
 	// Move cursor to end of inserted text
 	b.cursor.Line = line
@@ -220,9 +387,11 @@ func (b *Buffer) DeleteChar() error {
 		return fmt.Errorf("cannot delete at end of line")
 	}
 
-	// Delete character at cursor position
-	newLine := line[:b.cursor.Col] + line[b.cursor.Col+1:]
+	// Delete character at cursor position, all of its bytes
+	_, size := utf8.DecodeRuneInString(line[b.cursor.Col:])
+	newLine := line[:b.cursor.Col] + line[b.cursor.Col+size:]
 	b.lines[b.cursor.Line] = newLine
+	b.noteChange(b.cursor.Line, 1, 1)
 	b.setModified(true)
 
 	return nil
@@ -253,18 +422,24 @@ func (b *Buffer) Backspace() error {
 
 		// Remove the now-empty line
 		b.lines = append(b.lines[:b.cursor.Line+1], b.lines[b.cursor.Line+2:]...)
+		b.noteChange(b.cursor.Line, 2, 1)
 		b.setModified(true)
 
 		return nil
 	}
 
-	// Delete character before cursor
+	// Delete character before cursor, all of its bytes
 	line := b.lines[b.cursor.Line]
-	newLine := line[:b.cursor.Col-1] + line[b.cursor.Col:]
+	if b.cursor.Col < 0 || b.cursor.Col > len(line) {
+		return fmt.Errorf("cursor column %d out of range for line length %d", b.cursor.Col, len(line))
+	}
+	_, size := utf8.DecodeLastRuneInString(line[:b.cursor.Col])
+	newLine := line[:b.cursor.Col-size] + line[b.cursor.Col:]
 	b.lines[b.cursor.Line] = newLine
+	b.noteChange(b.cursor.Line, 1, 1)
 
 	// Move cursor back
-	b.cursor.Col--
+	b.cursor.Col -= size
 	b.setModified(true)
 
 	return nil
@@ -295,6 +470,7 @@ func (b *Buffer) InsertLine() error {
 	newLines[b.cursor.Line+1] = rightPart
 	copy(newLines[b.cursor.Line+2:], b.lines[b.cursor.Line+1:])
 	b.lines = newLines
+	b.noteChange(b.cursor.Line, 1, 2)
 
 	// Move cursor to beginning of new line
 	b.cursor.Line++
@@ -316,6 +492,7 @@ func (b *Buffer) InsertEmptyLine() error {
 	newLines[b.cursor.Line] = ""
 	copy(newLines[b.cursor.Line+1:], b.lines[b.cursor.Line:])
 	b.lines = newLines
+	b.noteChange(b.cursor.Line, 0, 1)
 
 	// Cursor stays at the new empty line
 	b.cursor.Col = 0
@@ -329,6 +506,7 @@ func (b *Buffer) DeleteLine() error {
 	if len(b.lines) <= 1 {
 		// Don't delete the last line, just clear it
 		b.lines[0] = ""
+		b.noteChange(0, 1, 1)
 		b.cursor = Position{Line: 0, Col: 0}
 		b.setModified(true)
 		return nil
@@ -343,19 +521,15 @@ func (b *Buffer) DeleteLine() error {
 	copy(newLines[:b.cursor.Line], b.lines[:b.cursor.Line])
 	copy(newLines[b.cursor.Line:], b.lines[b.cursor.Line+1:])
 	b.lines = newLines
+	b.noteChange(b.cursor.Line, 1, 0)
 
 	// Adjust cursor position
 	if b.cursor.Line >= len(b.lines) {
 		b.cursor.Line = len(b.lines) - 1
 	}
 	
-	// Clamp cursor column to line length
-	if b.cursor.Line >= 0 && b.cursor.Line < len(b.lines) {
-		lineLen := len(b.lines[b.cursor.Line])
-		if b.cursor.Col > lineLen {
-			b.cursor.Col = lineLen
-		}
-	}
+	// Clamp cursor column to the line
+	b.SetCursor(b.cursor)
 
 	b.setModified(true)
 	return nil
@@ -384,11 +558,63 @@ func (b *Buffer) JoinLines() error {
 	copy(newLines[:b.cursor.Line+1], b.lines[:b.cursor.Line+1])
 	copy(newLines[b.cursor.Line+1:], b.lines[b.cursor.Line+2:])
 	b.lines = newLines
+	b.noteChange(b.cursor.Line, 2, 1)
 
 	b.setModified(true)
 	return nil
 }
 
+// ReplaceLines replaces lines start..end (inclusive) with the given lines
+func (b *Buffer) ReplaceLines(start, end int, lines []string) error {
+	if start < 0 || end >= len(b.lines) || start > end {
+		return fmt.Errorf("line range %d-%d out of range", start, end)
+	}
+
+	newLines := make([]string, 0, len(b.lines)-(end-start+1)+len(lines))
+	newLines = append(newLines, b.lines[:start]...)
+	newLines = append(newLines, lines...)
+	newLines = append(newLines, b.lines[end+1:]...)
+	if len(newLines) == 0 {
+		newLines = []string{""}
+	}
+	b.noteChange(start, end-start+1, len(newLines)-len(b.lines)+end-start+1)
+	b.lines = newLines
+
+	// Keep the cursor within the buffer
+	b.SetCursor(b.cursor)
+	b.setModified(true)
+	return nil
+}
+
+// StripTrailingWhitespace removes trailing spaces and tabs from lines start..end (inclusive)
+// and returns the number of lines changed
+func (b *Buffer) StripTrailingWhitespace(start, end int) int {
+	if start < 0 {
+		start = 0
+	}
+	if end >= len(b.lines) {
+		end = len(b.lines) - 1
+	}
+
+	changed := 0
+	for i := start; i <= end; i++ {
+		trimmed := strings.TrimRight(b.lines[i], " \t")
+		if trimmed != b.lines[i] {
+			b.lines[i] = trimmed
+			b.noteChange(i, 1, 1)
+			changed++
+		}
+	}
+
+	if changed > 0 {
+		// Keep the cursor within the shortened line
+		b.SetCursor(b.cursor)
+		b.setModified(true)
+	}
+
+	return changed
+}
+
 // Save writes the buffer content to its associated file
 func (b *Buffer) Save() error {
 	if b.filename == "" {
@@ -399,24 +625,33 @@ func (b *Buffer) Save() error {
 
 // SaveAs writes the buffer content to the specified file
 func (b *Buffer) SaveAs(filename string) error {
+	return b.SaveWith(filename, b.writeFile)
+}
+
+// SaveWith saves the buffer to filename using write to store the content,
+// for saves that bypass the normal file writing such as privileged writes
+func (b *Buffer) SaveWith(filename string, write func(filename, content string) error) error {
 	if filename == "" {
 		return fmt.Errorf("filename cannot be empty")
 	}
+	if b.options.Scratch != "" {
+		return fmt.Errorf("scratch buffers aren't written to files")
+	}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create file %q: %w", filename, err)
+	if b.options.TrimTrailingWhitespace {
+		b.StripTrailingWhitespace(0, len(b.lines)-1)
 	}
-	defer file.Close()
 
-	content := b.String()
-	if _, err := file.WriteString(content); err != nil {
-		return fmt.Errorf("failed to write to file %q: %w", filename, err)
+	if err := write(filename, b.String()); err != nil {
+		return err
 	}
 
 	// Update buffer state
 	b.filename = filename
 	b.setModified(false)
+	b.markSaved()
+	b.Commit()
+	b.undo.saved = b.undo.current
 
 	return nil
 }
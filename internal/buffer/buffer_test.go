@@ -3,6 +3,7 @@ package buffer
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -75,6 +76,52 @@ func TestNewFromNonexistentFile(t *testing.T) {
 	}
 }
 
+func TestLoad(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "load.txt")
+	if err := os.WriteFile(tmpFile, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := New()
+	buf.InsertTextAt(0, 0, "scratch")
+	buf.SetCursor(Position{Line: 0, Col: 3})
+	if err := buf.Load(tmpFile); err != nil {
+		t.Fatalf("failed to load file: %v", err)
+	}
+
+	if buf.LineCount() != 2 || buf.CurrentLine() != "one" {
+		t.Errorf("expected file content, got %q", buf.Lines())
+	}
+	if buf.Cursor() != (Position{}) || buf.Modified() || buf.Filename() != tmpFile {
+		t.Errorf("expected fresh state, got cursor %v modified %v filename %q", buf.Cursor(), buf.Modified(), buf.Filename())
+	}
+	if err := buf.Load(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected error loading a missing file")
+	}
+}
+
+func TestScratchBuffer(t *testing.T) {
+	buf := New()
+	buf.SetFilename("notes.txt")
+	buf.LoadText([]string{"draft", "ideas"})
+	buf.SetOptions(Options{Scratch: "Scratch"})
+	buf.InsertChar('x')
+
+	if buf.Filename() != "" || buf.LineCount() != 2 || buf.Modified() {
+		t.Errorf("expected an unmodified scratch buffer without a file, got %q modified %v in %q", buf.Lines(), buf.Modified(), buf.Filename())
+	}
+	if err := buf.SaveAs(filepath.Join(t.TempDir(), "out.txt")); err == nil {
+		t.Error("expected writing a scratch buffer to fail")
+	}
+
+	tmpFile := filepath.Join(t.TempDir(), "file.txt")
+	os.WriteFile(tmpFile, []byte("text\n"), 0644)
+	buf.Load(tmpFile)
+	if buf.Options().Scratch != "" {
+		t.Error("expected loading a file to end the scratch buffer")
+	}
+}
+
 func TestInsertChar(t *testing.T) {
 	buf := New()
 	
@@ -376,6 +423,27 @@ func TestSetCursor(t *testing.T) {
 	}
 }
 
+func TestByteOffset(t *testing.T) {
+	buf := New()
+	buf.ReplaceLines(0, 0, []string{"ab", "héllo", ""})
+
+	tests := []struct {
+		pos  Position
+		want int
+	}{
+		{Position{Line: 0, Col: 0}, 0},
+		{Position{Line: 0, Col: 2}, 2},
+		{Position{Line: 1, Col: 3}, 6}, // é is two bytes
+		{Position{Line: 1, Col: 9}, 9},
+		{Position{Line: 2, Col: 0}, 10},
+	}
+	for _, tt := range tests {
+		if got := buf.ByteOffset(tt.pos); got != tt.want {
+			t.Errorf("ByteOffset(%v) = %d, want %d", tt.pos, got, tt.want)
+		}
+	}
+}
+
 func TestSaveAndLoad(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test_save.txt")
@@ -437,4 +505,155 @@ func TestString(t *testing.T) {
 	if buf.String() != expected {
 		t.Errorf("expected %q, got %q", expected, buf.String())
 	}
-}
\ No newline at end of file
+}
+func TestStripTrailingWhitespace(t *testing.T) {
+	buf := New()
+	buf.InsertTextAt(0, 0, "hello   ")
+	buf.InsertLine()
+	buf.InsertTextAt(1, 0, "world\t")
+	buf.InsertLine()
+	buf.InsertTextAt(2, 0, "clean")
+
+	changed := buf.StripTrailingWhitespace(0, buf.LineCount()-1)
+	if changed != 2 {
+		t.Errorf("expected 2 lines changed, got %d", changed)
+	}
+
+	expected := "hello\nworld\nclean"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestSaveTrimsTrailingWhitespace(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "trim.txt")
+
+	buf := New()
+	buf.InsertTextAt(0, 0, "code  ")
+	buf.SetOptions(Options{TrimTrailingWhitespace: true})
+
+	if err := buf.SaveAs(testFile); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "code" {
+		t.Errorf("expected trailing whitespace to be trimmed on save, got %q", string(content))
+	}
+}
+
+func TestReplaceLines(t *testing.T) {
+	buf := New()
+	buf.InsertTextAt(0, 0, "one")
+	buf.InsertLine()
+	buf.InsertTextAt(1, 0, "two")
+	buf.InsertLine()
+	buf.InsertTextAt(2, 0, "three")
+
+	if err := buf.ReplaceLines(1, 1, []string{"2a", "2b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"one", "2a", "2b", "three"}
+	if got := buf.Lines(); strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	if err := buf.ReplaceLines(0, 3, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.LineCount() != 1 || buf.CurrentLine() != "" {
+		t.Errorf("expected a single empty line, got %q", buf.Lines())
+	}
+
+	if err := buf.ReplaceLines(2, 5, nil); err == nil {
+		t.Error("expected error for out of range lines")
+	}
+}
+
+// memoryHandler is a FileHandler keeping mem:// files in a map
+type memoryHandler map[string]string
+
+func (m memoryHandler) Handles(filename string) bool {
+	return strings.HasPrefix(filename, "mem://")
+}
+
+func (m memoryHandler) ReadFile(filename string) ([]byte, error) {
+	content, ok := m[filename]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(content), nil
+}
+
+func (m memoryHandler) WriteFile(filename string, data []byte) error {
+	m[filename] = string(data)
+	return nil
+}
+
+func (m memoryHandler) Indicator(filename string) string {
+	return "[mem]"
+}
+
+func TestFileHandler(t *testing.T) {
+	files := memoryHandler{"mem://notes": "one\ntwo\n"}
+	RegisterFileHandler(files)
+
+	buf, err := NewFromFile("mem://notes")
+	if err != nil {
+		t.Fatalf("NewFromFile failed: %v", err)
+	}
+	if buf.LineCount() != 2 || buf.CurrentLine() != "one" {
+		t.Errorf("loaded %q", buf.Lines())
+	}
+	if got := buf.FileIndicator(); got != "[mem]" {
+		t.Errorf("FileIndicator() = %q, want [mem]", got)
+	}
+
+	buf.InsertChar('x')
+	if err := buf.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if files["mem://notes"] != "xone\ntwo" {
+		t.Errorf("handler content = %q", files["mem://notes"])
+	}
+
+	if _, err := NewFromFile("mem://missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if got := New().FileIndicator(); got != "" {
+		t.Errorf("FileIndicator() = %q for a local buffer", got)
+	}
+}
+
+func TestFiletype(t *testing.T) {
+	buf := New()
+	if got := buf.Filetype(); got != "text" {
+		t.Errorf("Filetype() = %q for a new buffer", got)
+	}
+
+	// Detection follows the filename and falls back to the shebang
+	buf.SetFilename("main.go")
+	if got := buf.Filetype(); got != "go" {
+		t.Errorf("Filetype() = %q for main.go", got)
+	}
+	buf.InsertTextAt(0, 0, "#!/usr/bin/env python3")
+	buf.SetFilename("tool")
+	if got := buf.Filetype(); got != "python" {
+		t.Errorf("Filetype() = %q for a python script", got)
+	}
+
+	// An explicit filetype survives renames until cleared
+	buf.SetFiletype("lua")
+	buf.SetFilename("other.go")
+	if got := buf.Filetype(); got != "lua" {
+		t.Errorf("Filetype() = %q after SetFiletype", got)
+	}
+	buf.SetFiletype("")
+	if got := buf.Filetype(); got != "go" {
+		t.Errorf("Filetype() = %q after clearing", got)
+	}
+}
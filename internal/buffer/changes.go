@@ -0,0 +1,91 @@
+package buffer
+
+import "slices"
+
+// maxChanges is how many line changes a buffer remembers for ChangedSince.
+// A consumer further behind than that reads the whole text again.
+const maxChanges = 1000
+
+// LineChange is an edit to a run of lines: the Removed lines from Start
+// were replaced by Added lines
+type LineChange struct {
+	Start   int
+	Removed int
+	Added   int
+}
+
+// changeLog tracks which lines of a buffer change, so that the renderer
+// and language servers can look at those instead of the whole text
+type changeLog struct {
+	seq    int          // Number of changes ever made
+	recent []LineChange // The last maxChanges changes, change n at n % maxChanges
+	dirty  []bool       // Lines changed since loading or saving; missing ones are unchanged
+}
+
+// noteChange records that the removed lines from start were replaced by
+// added lines
+func (b *Buffer) noteChange(start, removed, added int) {
+	c := &b.changes
+	if c.recent == nil {
+		c.recent = make([]LineChange, maxChanges)
+	}
+	c.recent[c.seq%maxChanges] = LineChange{Start: start, Removed: removed, Added: added}
+	c.seq++
+
+	if len(c.dirty) < start+removed {
+		c.dirty = append(c.dirty, make([]bool, start+removed-len(c.dirty))...)
+	}
+	if removed != added {
+		c.dirty = slices.Replace(c.dirty, start, start+removed, make([]bool, added)...)
+	}
+	for line := start; line < start+added; line++ {
+		c.dirty[line] = true
+	}
+}
+
+// ChangeSeq returns the number of line changes made to the buffer, to
+// pass to ChangedSince later
+func (b *Buffer) ChangeSeq() int {
+	return b.changes.seq
+}
+
+// ChangedSince returns the lines changed since ChangeSeq returned seq as
+// one change: the Removed lines from Start of the text then are now the
+// Added lines from Start. Nothing changed when both are 0. It returns
+// false when the buffer no longer remembers that far back.
+func (b *Buffer) ChangedSince(seq int) (LineChange, bool) {
+	c := &b.changes
+	if seq > c.seq || c.seq-seq > min(c.seq, maxChanges) {
+		return LineChange{}, false
+	}
+
+	var span LineChange
+	for n := seq; n < c.seq; n++ {
+		change := c.recent[n%maxChanges]
+		if n == seq {
+			span = change
+			continue
+		}
+		// The lines between the two changes haven't changed, so the span
+		// covering both is as long in the old text less what span added
+		start := min(span.Start, change.Start)
+		end := max(span.Start+span.Added, change.Start+change.Removed)
+		span = LineChange{
+			Start:   start,
+			Removed: end - start - (span.Added - span.Removed),
+			Added:   end - start - change.Removed + change.Added,
+		}
+	}
+	return span, true
+}
+
+// LineModified reports whether line changed since the buffer was loaded
+// or last saved
+func (b *Buffer) LineModified(line int) bool {
+	return line >= 0 && line < len(b.changes.dirty) && b.changes.dirty[line]
+}
+
+// markSaved forgets which lines changed, once they are on disk
+func (b *Buffer) markSaved() {
+	b.changes.dirty = nil
+}
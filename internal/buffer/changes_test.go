@@ -0,0 +1,96 @@
+package buffer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChangedSince(t *testing.T) {
+	buf := New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c", "d", "e", "f"})
+
+	edits := []func(){
+		func() { buf.SetCursor(Position{Line: 2}); buf.InsertChar('x') },
+		func() { buf.SetCursor(Position{Line: 4, Col: 1}); buf.InsertLine() },
+		func() { buf.SetCursor(Position{Line: 0}); buf.DeleteLine() },
+		func() { buf.SetCursor(Position{Line: 5}); buf.JoinLines() },
+		func() { buf.MoveLines(0, 0, 3) },
+		func() { buf.CopyLines(1, 2, -1) },
+		func() { buf.SetCursor(Position{Line: 3}); buf.InsertEmptyLine() },
+		func() { buf.SetCursor(Position{Line: 4}); buf.Backspace() },
+		func() { buf.Undo() },
+		func() { buf.Redo() },
+	}
+
+	type snapshot struct {
+		seq   int
+		lines []string
+	}
+	snapshots := []snapshot{{buf.ChangeSeq(), buf.Lines()}}
+	for i, edit := range edits {
+		edit()
+		if i%3 == 2 {
+			buf.Commit()
+		}
+		snapshots = append(snapshots, snapshot{buf.ChangeSeq(), buf.Lines()})
+
+		now := buf.Lines()
+		for _, s := range snapshots {
+			change, ok := buf.ChangedSince(s.seq)
+			if !ok {
+				t.Fatalf("after edit %d: ChangedSince(%d) not remembered", i, s.seq)
+			}
+			// Replacing the changed lines of the old text must give the new
+			got := slices.Concat(s.lines[:change.Start], now[change.Start:change.Start+change.Added], s.lines[change.Start+change.Removed:])
+			if !slices.Equal(got, now) {
+				t.Errorf("after edit %d: ChangedSince(%d) = %+v, patching %q gives %q, want %q", i, s.seq, change, s.lines, got, now)
+			}
+		}
+	}
+
+	if change, ok := buf.ChangedSince(buf.ChangeSeq()); !ok || change != (LineChange{}) {
+		t.Errorf("ChangedSince(now) = %+v, %v; want no change", change, ok)
+	}
+	if _, ok := buf.ChangedSince(buf.ChangeSeq() + 1); ok {
+		t.Error("ChangedSince of a future change should not be remembered")
+	}
+}
+
+func TestChangedSince_Forgotten(t *testing.T) {
+	buf := New()
+	seq := buf.ChangeSeq()
+	for range maxChanges + 1 {
+		buf.InsertChar('a')
+	}
+	if _, ok := buf.ChangedSince(seq); ok {
+		t.Error("expected changes older than the log to be forgotten")
+	}
+	if change, ok := buf.ChangedSince(seq + 1); !ok || change != (LineChange{Start: 0, Removed: 1, Added: 1}) {
+		t.Errorf("ChangedSince(%d) = %+v, %v; want line 0 changed", seq+1, change, ok)
+	}
+}
+
+func TestLineModified(t *testing.T) {
+	buf := New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c", "d"})
+	buf.markSaved()
+
+	buf.SetCursor(Position{Line: 1})
+	buf.InsertChar('x')
+	buf.SetCursor(Position{Line: 3})
+	buf.InsertEmptyLine()
+
+	want := []bool{false, true, false, true, false}
+	for line, modified := range want {
+		if got := buf.LineModified(line); got != modified {
+			t.Errorf("LineModified(%d) = %v, want %v", line, got, modified)
+		}
+	}
+
+	buf.SaveWith("file.txt", func(string, string) error { return nil })
+	for line := range want {
+		if buf.LineModified(line) {
+			t.Errorf("line %d still modified after saving", line)
+		}
+	}
+}
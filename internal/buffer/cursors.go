@@ -0,0 +1,100 @@
+package buffer
+
+import "sort"
+
+// Cursors returns the secondary cursors in buffer order
+func (b *Buffer) Cursors() []Position {
+	result := make([]Position, len(b.extraCursors))
+	copy(result, b.extraCursors)
+	return result
+}
+
+// HasMultipleCursors reports whether any secondary cursors are active
+func (b *Buffer) HasMultipleCursors() bool {
+	return len(b.extraCursors) > 0
+}
+
+// AddCursor adds a secondary cursor. Positions already holding a cursor are ignored.
+func (b *Buffer) AddCursor(pos Position) {
+	if pos == b.cursor {
+		return
+	}
+	for _, c := range b.extraCursors {
+		if c == pos {
+			return
+		}
+	}
+	b.extraCursors = append(b.extraCursors, pos)
+	sortPositions(b.extraCursors)
+}
+
+// ClearCursors removes all secondary cursors
+func (b *Buffer) ClearCursors() {
+	b.extraCursors = nil
+}
+
+// ApplyAtCursors runs edit once at every cursor, primary and secondary.
+// Cursors are visited from the end of the buffer backwards and cursors
+// already visited are shifted by each edit, so edit only needs to work
+// on the primary cursor like a normal single cursor edit.
+func (b *Buffer) ApplyAtCursors(edit func()) {
+	all := append([]Position{b.cursor}, b.extraCursors...)
+	order := make([]int, len(all))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return positionLess(all[order[j]], all[order[i]])
+	})
+
+	for n, idx := range order {
+		b.SetCursor(all[idx])
+		old := b.cursor
+		oldLen := len(b.lines[old.Line])
+		oldCount := len(b.lines)
+
+		edit()
+
+		updated := b.cursor
+		lineDelta := len(b.lines) - oldCount
+		for _, done := range order[:n] {
+			pos := all[done]
+			switch {
+			case pos.Line == old.Line && lineDelta == 0:
+				pos.Col += len(b.lines[old.Line]) - oldLen
+			case pos.Line == old.Line:
+				// The line was split or joined; keep the offset from the edited cursor
+				pos = Position{Line: updated.Line, Col: updated.Col + pos.Col - old.Col}
+			default:
+				pos.Line += lineDelta
+			}
+			all[done] = pos
+		}
+		all[idx] = updated
+	}
+
+	b.SetCursor(all[0])
+	b.extraCursors = nil
+	for _, pos := range all[1:] {
+		b.AddCursor(b.clampPosition(pos))
+	}
+}
+
+// clampPosition limits a position to the buffer contents
+func (b *Buffer) clampPosition(pos Position) Position {
+	pos.Line = max(0, min(pos.Line, len(b.lines)-1))
+	pos.Col = max(0, min(pos.Col, len(b.lines[pos.Line])))
+	return pos
+}
+
+// positionLess reports whether a comes before b
+func positionLess(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+}
+
+// sortPositions sorts positions in buffer order
+func sortPositions(positions []Position) {
+	sort.Slice(positions, func(i, j int) bool {
+		return positionLess(positions[i], positions[j])
+	})
+}
@@ -0,0 +1,70 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func newCursorTestBuffer(lines ...string) *Buffer {
+	buf := New()
+	buf.ReplaceLines(0, 0, lines)
+	return buf
+}
+
+func TestApplyAtCursors_SameLine(t *testing.T) {
+	buf := newCursorTestBuffer("a b c")
+	buf.SetCursor(Position{Line: 0, Col: 0})
+	buf.AddCursor(Position{Line: 0, Col: 2})
+	buf.AddCursor(Position{Line: 0, Col: 4})
+
+	buf.ApplyAtCursors(func() { buf.InsertChar('x') })
+
+	if buf.CurrentLine() != "xa xb xc" {
+		t.Errorf("expected 'xa xb xc', got %q", buf.CurrentLine())
+	}
+	if buf.Cursor() != (Position{Line: 0, Col: 1}) {
+		t.Errorf("expected primary cursor at col 1, got %+v", buf.Cursor())
+	}
+	expected := []Position{{Line: 0, Col: 4}, {Line: 0, Col: 7}}
+	if got := buf.Cursors(); len(got) != 2 || got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("expected secondary cursors %v, got %v", expected, got)
+	}
+}
+
+func TestApplyAtCursors_SplitAndJoin(t *testing.T) {
+	buf := newCursorTestBuffer("ab", "cd")
+	buf.SetCursor(Position{Line: 0, Col: 1})
+	buf.AddCursor(Position{Line: 1, Col: 1})
+
+	buf.ApplyAtCursors(func() { buf.InsertLine() })
+	if got := strings.Join(buf.Lines(), "|"); got != "a|b|c|d" {
+		t.Fatalf("expected 'a|b|c|d', got %q", got)
+	}
+	if buf.Cursor() != (Position{Line: 1, Col: 0}) || buf.Cursors()[0] != (Position{Line: 3, Col: 0}) {
+		t.Errorf("unexpected cursors %+v %v", buf.Cursor(), buf.Cursors())
+	}
+
+	buf.ApplyAtCursors(func() { buf.Backspace() })
+	if got := strings.Join(buf.Lines(), "|"); got != "ab|cd" {
+		t.Fatalf("expected 'ab|cd', got %q", got)
+	}
+	if buf.Cursor() != (Position{Line: 0, Col: 1}) || buf.Cursors()[0] != (Position{Line: 1, Col: 1}) {
+		t.Errorf("unexpected cursors %+v %v", buf.Cursor(), buf.Cursors())
+	}
+}
+
+func TestAddCursorIgnoresDuplicates(t *testing.T) {
+	buf := newCursorTestBuffer("abc")
+	buf.AddCursor(Position{Line: 0, Col: 0})
+	buf.AddCursor(Position{Line: 0, Col: 2})
+	buf.AddCursor(Position{Line: 0, Col: 2})
+
+	if len(buf.Cursors()) != 1 {
+		t.Errorf("expected 1 secondary cursor, got %v", buf.Cursors())
+	}
+
+	buf.ClearCursors()
+	if buf.HasMultipleCursors() {
+		t.Error("expected cursors to be cleared")
+	}
+}
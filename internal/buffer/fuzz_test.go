@@ -0,0 +1,85 @@
+package buffer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// fuzzRunes are what FuzzEditing types: ASCII, and runes of two, three and
+// four bytes to catch cursors left inside a rune
+var fuzzRunes = []rune{'a', 'b', ' ', '\t', 'é', '世', '🙂'}
+
+// FuzzEditing applies the edits encoded in ops to a buffer and checks after
+// each one that the cursor is on the text, the line count matches the text
+// and the text round-trips through String. Each edit is two bytes: the
+// operation and its argument.
+func FuzzEditing(f *testing.F) {
+	f.Add([]byte{0, 0, 2, 0, 0, 4, 1, 0, 3, 0})
+	f.Add([]byte{0, 5, 0, 6, 5, 1, 1, 0, 1, 0, 6, 0})
+	f.Add([]byte{2, 0, 2, 0, 4, 0x21, 3, 0, 3, 0, 3, 0})
+	f.Add([]byte{0, 4, 2, 0, 4, 0x10, 1, 0, 7, 0, 7, 0})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		// Checking the whole text after each edit makes long inputs slow
+		if len(ops) > 2000 {
+			ops = ops[:2000]
+		}
+		buf := New()
+		for i := 0; i+1 < len(ops); i += 2 {
+			op, arg := ops[i]%8, int(ops[i+1])
+			before := buf.Cursor()
+			switch op {
+			case 0:
+				buf.InsertChar(fuzzRunes[arg%len(fuzzRunes)])
+			case 1:
+				buf.Backspace()
+			case 2:
+				buf.InsertLine()
+			case 3:
+				buf.DeleteLine()
+			case 4:
+				// Lines in the high nibble, columns in the low one
+				buf.SetCursor(Position{Line: arg>>4 - 2, Col: arg&0xf - 2})
+			case 5:
+				buf.DeleteChar()
+			case 6:
+				buf.InsertEmptyLine()
+			case 7:
+				buf.JoinLines()
+			}
+			checkInvariants(t, buf, op, before)
+		}
+	})
+}
+
+// checkInvariants fails t when buf is inconsistent after operation op
+// with the cursor at before
+func checkInvariants(t *testing.T, buf *Buffer, op byte, before Position) {
+	t.Helper()
+	lines := buf.Lines()
+	if len(lines) == 0 || buf.LineCount() != len(lines) {
+		t.Fatalf("op %d at %+v: %d lines, LineCount %d", op, before, len(lines), buf.LineCount())
+	}
+
+	cursor := buf.Cursor()
+	if cursor.Line < 0 || cursor.Line >= len(lines) {
+		t.Fatalf("op %d at %+v: cursor line %d outside %d lines", op, before, cursor.Line, len(lines))
+	}
+	line := lines[cursor.Line]
+	if cursor.Col < 0 || cursor.Col > len(line) {
+		t.Fatalf("op %d at %+v: cursor column %d outside line %q", op, before, cursor.Col, line)
+	}
+	if cursor.Col < len(line) && !utf8.RuneStart(line[cursor.Col]) {
+		t.Fatalf("op %d at %+v: cursor column %d inside a rune of %q", op, before, cursor.Col, line)
+	}
+
+	text := buf.String()
+	if !utf8.ValidString(text) {
+		t.Fatalf("op %d at %+v: text %q is not valid UTF-8", op, before, text)
+	}
+	if got := strings.Split(text, "\n"); !slices.Equal(got, lines) {
+		t.Fatalf("op %d at %+v: String() = %q, which splits into %q, not the lines %q", op, before, text, got, lines)
+	}
+}
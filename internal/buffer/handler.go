@@ -0,0 +1,122 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dshills/aied/internal/activity"
+)
+
+// FileHandler reads and writes files that are not plain local paths, such
+// as remote URLs. Buffers whose filename a handler accepts are loaded and
+// saved through it instead of the local filesystem.
+type FileHandler interface {
+	// Handles reports whether the handler is responsible for filename
+	Handles(filename string) bool
+	// ReadFile returns the content of filename
+	ReadFile(filename string) ([]byte, error)
+	// WriteFile replaces the content of filename with data
+	WriteFile(filename string, data []byte) error
+	// Indicator is a short label shown in the status line, such as "[ssh]"
+	Indicator(filename string) string
+}
+
+// warningWriter is a FileHandler whose writes can succeed with warnings,
+// like those going through WriteLocalFile
+type warningWriter interface {
+	WriteFileWarnings(filename string, data []byte) ([]string, error)
+}
+
+// Registered file handlers, consulted in registration order
+var fileHandlers []FileHandler
+
+// RegisterFileHandler adds a handler for loading and saving files
+func RegisterFileHandler(handler FileHandler) {
+	fileHandlers = append(fileHandlers, handler)
+}
+
+// handlerFor returns the handler responsible for filename, or nil for local files
+func handlerFor(filename string) FileHandler {
+	for _, handler := range fileHandlers {
+		if handler.Handles(filename) {
+			return handler
+		}
+	}
+	return nil
+}
+
+// FileIndicator returns the status line label of the handler responsible
+// for the buffer's file, or an empty string for local files
+func (b *Buffer) FileIndicator() string {
+	if handler := handlerFor(b.filename); handler != nil {
+		return handler.Indicator(b.filename)
+	}
+	return ""
+}
+
+// readLines reads a file into lines, returning at least one empty line
+func readLines(filename string) ([]string, error) {
+	defer activity.Start("load " + filepath.Base(filename))()
+	data, err := readFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %q: %w", filename, err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %w", filename, err)
+	}
+
+	// Ensure at least one empty line
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	return lines, nil
+}
+
+// readFile returns the content of filename, through its handler when it
+// has one
+func readFile(filename string) ([]byte, error) {
+	if handler := handlerFor(filename); handler != nil {
+		return handler.ReadFile(filename)
+	}
+	return os.ReadFile(filename)
+}
+
+// writeFile writes content to filename, through its handler when it has
+// one, keeping the warnings of the write for WriteWarnings
+func (b *Buffer) writeFile(filename, content string) error {
+	b.writeWarnings = nil
+	if handler, ok := handlerFor(filename).(warningWriter); ok {
+		warnings, err := handler.WriteFileWarnings(filename, []byte(content))
+		if err != nil {
+			return fmt.Errorf("failed to write to file %q: %w", filename, err)
+		}
+		b.writeWarnings = warnings
+		return nil
+	}
+	if handler := handlerFor(filename); handler != nil {
+		if err := handler.WriteFile(filename, []byte(content)); err != nil {
+			return fmt.Errorf("failed to write to file %q: %w", filename, err)
+		}
+		return nil
+	}
+	warnings, err := writeLocal(filename, content, b.options.Backup, newFileMode)
+	b.writeWarnings = warnings
+	return err
+}
+
+// WriteWarnings returns what went wrong in the last write of the buffer,
+// or of lines of it, that still wrote the file, like a backup that
+// couldn't be made
+func (b *Buffer) WriteWarnings() []string {
+	return b.writeWarnings
+}
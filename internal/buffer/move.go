@@ -0,0 +1,56 @@
+package buffer
+
+import (
+	"fmt"
+	"slices"
+)
+
+// MoveLines moves lines start..end (inclusive) to below line dest, or to
+// the top of the buffer when dest is -1, and puts the cursor on the last
+// moved line. Moving lines below one of themselves is an error.
+func (b *Buffer) MoveLines(start, end, dest int) error {
+	if start < 0 || end >= len(b.lines) || start > end {
+		return fmt.Errorf("line range %d-%d out of range", start, end)
+	}
+	if dest < -1 || dest >= len(b.lines) {
+		return fmt.Errorf("destination line %d out of range", dest)
+	}
+	if dest >= start && dest < end {
+		return fmt.Errorf("cannot move a range of lines into itself")
+	}
+
+	at := start
+	if dest != start-1 && dest != end {
+		block := slices.Clone(b.lines[start : end+1])
+		rest := slices.Delete(slices.Clone(b.lines), start, end+1)
+		at = dest + 1
+		if dest > end {
+			at -= len(block)
+		}
+		b.lines = slices.Insert(rest, at, block...)
+		first, last := min(start, at), max(end, at+len(block)-1)
+		b.noteChange(first, last-first+1, last-first+1)
+		b.setModified(true)
+	}
+	b.SetCursor(Position{Line: at + end - start, Col: b.cursor.Col})
+	return nil
+}
+
+// CopyLines copies lines start..end (inclusive) to below line dest, or to
+// the top of the buffer when dest is -1, and puts the cursor on the last
+// copied line
+func (b *Buffer) CopyLines(start, end, dest int) error {
+	if start < 0 || end >= len(b.lines) || start > end {
+		return fmt.Errorf("line range %d-%d out of range", start, end)
+	}
+	if dest < -1 || dest >= len(b.lines) {
+		return fmt.Errorf("destination line %d out of range", dest)
+	}
+
+	block := slices.Clone(b.lines[start : end+1])
+	b.lines = slices.Insert(b.lines, dest+1, block...)
+	b.noteChange(dest+1, 0, len(block))
+	b.setModified(true)
+	b.SetCursor(Position{Line: dest + len(block), Col: b.cursor.Col})
+	return nil
+}
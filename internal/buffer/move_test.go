@@ -0,0 +1,54 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMoveLines(t *testing.T) {
+	tests := []struct {
+		start, end, dest int
+		want             string
+		cursor           int
+	}{
+		{0, 0, 2, "b c a d e", 2},
+		{3, 4, -1, "d e a b c", 1},
+		{1, 2, 4, "a d e b c", 4},
+		{1, 2, 0, "a b c d e", 2},
+		{1, 2, 2, "a b c d e", 2},
+	}
+
+	for _, tt := range tests {
+		buf := New()
+		buf.ReplaceLines(0, 0, []string{"a", "b", "c", "d", "e"})
+		if err := buf.MoveLines(tt.start, tt.end, tt.dest); err != nil {
+			t.Fatalf("MoveLines(%d, %d, %d): %v", tt.start, tt.end, tt.dest, err)
+		}
+		if got := strings.Join(buf.Lines(), " "); got != tt.want || buf.Cursor().Line != tt.cursor {
+			t.Errorf("MoveLines(%d, %d, %d) = %q with the cursor on %d, want %q on %d",
+				tt.start, tt.end, tt.dest, got, buf.Cursor().Line, tt.want, tt.cursor)
+		}
+	}
+
+	buf := New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c"})
+	if err := buf.MoveLines(0, 2, 1); err == nil {
+		t.Error("expected an error moving lines into themselves")
+	}
+}
+
+func TestCopyLines(t *testing.T) {
+	buf := New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c"})
+	buf.modified = false
+
+	if err := buf.CopyLines(0, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(buf.Lines(), " "); got != "a b c a b" || buf.Cursor().Line != 4 || !buf.Modified() {
+		t.Errorf("CopyLines(0, 1, 2) = %q with the cursor on %d", got, buf.Cursor().Line)
+	}
+	if err := buf.CopyLines(2, 2, -1); err != nil || strings.Join(buf.Lines(), " ") != "c a b c a b" {
+		t.Errorf("CopyLines(2, 2, -1) = %q, %v", strings.Join(buf.Lines(), " "), err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package buffer
+
+import "os"
+
+// copyOwner is a no-op where files have no Unix owner
+func copyOwner(path string, info os.FileInfo) bool {
+	return true
+}
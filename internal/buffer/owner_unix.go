@@ -0,0 +1,28 @@
+//go:build unix
+
+package buffer
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner gives path the owner and group of the file described by info,
+// reporting whether path ends up with them
+func copyOwner(path string, info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	if err := os.Lchown(path, int(stat.Uid), int(stat.Gid)); err != nil {
+		// Unprivileged users can't give files away, which is fine when
+		// the new file already has the right owner and group
+		created, err := os.Stat(path)
+		if err != nil {
+			return false
+		}
+		createdStat, ok := created.Sys().(*syscall.Stat_t)
+		return ok && createdStat.Uid == stat.Uid && createdStat.Gid == stat.Gid
+	}
+	return true
+}
@@ -0,0 +1,257 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// newFileMode is the permission of files created by saving
+const newFileMode = 0644
+
+// keepOwner gives the new copy of a file the owner of the old one,
+// reporting whether it has it; tests replace it
+var keepOwner = copyOwner
+
+// BackupOptions control the numbered backups made before overwriting a file
+type BackupOptions struct {
+	Enabled bool
+	Dir     string // Directory for backups (~/ allowed), the file's own directory when empty
+	Keep    int    // Number of backups kept per file, 0 keeps all
+}
+
+// WriteLines writes lines start to end to filename, replacing it or, with
+// appendTo, adding them after its content. The buffer keeps its file and
+// stays modified, as the write is a copy.
+func (b *Buffer) WriteLines(filename string, start, end int, appendTo bool) error {
+	if filename == "" {
+		return fmt.Errorf("filename cannot be empty")
+	}
+	if start < 0 || end >= len(b.lines) || start > end {
+		return fmt.Errorf("invalid line range %d-%d", start+1, end+1)
+	}
+
+	lines := append([]string(nil), b.lines[start:end+1]...)
+	if b.options.TrimTrailingWhitespace {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+	}
+	content := strings.Join(lines, "\n")
+
+	if appendTo {
+		existing, err := readFile(filename)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read file %q: %w", filename, err)
+		}
+		// The appended lines start on a line of their own
+		if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+			existing = append(existing, '\n')
+		}
+		content = string(existing) + content
+	}
+	return b.writeFile(filename, content)
+}
+
+// writeLocal saves content to a local file without ever leaving it
+// truncated: the content goes to a temporary file in the same directory
+// that then replaces the original. Symlinks are followed so the link stays
+// in place, and the mode and ownership of the existing file are kept.
+// When the directory isn't writable or a new copy can't be given the
+// file's owner, the file is overwritten in place instead, which the
+// returned warnings tell, as they tell of a backup that couldn't be made.
+// Files it creates get newMode.
+func writeLocal(filename, content string, backup BackupOptions, newMode os.FileMode) ([]string, error) {
+	target := filename
+	if resolved, err := filepath.EvalSymlinks(filename); err == nil {
+		target = resolved
+	}
+
+	info, err := os.Stat(target)
+	exists := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat file %q: %w", filename, err)
+	}
+
+	var warnings []string
+	if exists && backup.Enabled {
+		if err := writeBackup(target, backup); err != nil {
+			warnings = append(warnings, fmt.Sprintf("no backup: %v", err))
+		}
+	}
+
+	mode := newMode
+	if exists {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-")
+	if err != nil {
+		warnings = append(warnings, "written in place, the directory isn't writable")
+		return warnings, writeInPlace(filename, target, content, mode)
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return warnings, fmt.Errorf("failed to write to file %q: %w", filename, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return warnings, fmt.Errorf("failed to write to file %q: %w", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return warnings, fmt.Errorf("failed to write to file %q: %w", filename, err)
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return warnings, fmt.Errorf("failed to set mode of %q: %w", filename, err)
+	}
+	if exists && !keepOwner(tmpName, info) {
+		warnings = append(warnings, "written in place to keep its owner")
+		return warnings, writeInPlace(filename, target, content, mode)
+	}
+
+	if err := os.Rename(tmpName, target); err != nil {
+		return warnings, fmt.Errorf("failed to replace file %q: %w", filename, err)
+	}
+	committed = true
+	return warnings, nil
+}
+
+// WriteLocalFile replaces the local file filename with data the way
+// buffers are saved, without a backup, for file handlers that write the
+// file themselves. Files it creates get mode.
+func WriteLocalFile(filename string, data []byte, mode os.FileMode) ([]string, error) {
+	return writeLocal(filename, string(data), BackupOptions{}, mode)
+}
+
+// writeInPlace truncates and rewrites target, keeping its inode; it is
+// created with mode
+func writeInPlace(filename, target, content string, mode os.FileMode) error {
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create file %q: %w", filename, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("failed to write to file %q: %w", filename, err)
+	}
+	return file.Sync()
+}
+
+// writeBackup copies target to the next numbered backup, name.~N~, and
+// removes the oldest backups beyond backup.Keep. In a backup directory of
+// its own the name is target's full path with its separators turned into
+// %, as Vim does, so files with the same name don't share backups.
+func writeBackup(target string, backup BackupOptions) error {
+	dir := backup.Dir
+	if dir == "" {
+		dir = filepath.Dir(target)
+	} else {
+		if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				dir = filepath.Join(home, rest)
+			}
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	base := filepath.Base(target)
+	if backup.Dir != "" {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return err
+		}
+		base = strings.ReplaceAll(filepath.ToSlash(abs), "/", "%")
+	}
+	numbers, err := backupNumbers(dir, base)
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(numbers) > 0 {
+		next = numbers[len(numbers)-1] + 1
+	}
+	if err := copyFile(target, backupName(dir, base, next)); err != nil {
+		return err
+	}
+
+	numbers = append(numbers, next)
+	if backup.Keep > 0 {
+		for len(numbers) > backup.Keep {
+			os.Remove(backupName(dir, base, numbers[0]))
+			numbers = numbers[1:]
+		}
+	}
+	return nil
+}
+
+// backupName returns the path of backup number n of base
+func backupName(dir, base string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.~%d~", base, n))
+}
+
+// backupNumbers returns the numbers of the existing backups of base in
+// dir, in ascending order
+func backupNumbers(dir, base string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var numbers []int
+	prefix := base + ".~"
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, "~") {
+			continue
+		}
+		digits := name[len(prefix) : len(name)-1]
+		if strings.TrimLeft(digits, "0123456789") != "" {
+			continue
+		}
+		n, err := strconv.Atoi(digits)
+		if err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// copyFile copies src to dst with src's permissions
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,176 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSaveKeepsModeAndSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("old"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	buf, err := NewFromFile(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.InsertChar('x')
+	if err := buf.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink was replaced: %v", err)
+	}
+	data, _ := os.ReadFile(target)
+	if string(data) != "xold" {
+		t.Errorf("target content = %q", data)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("directory holds %d entries, want 2", len(entries))
+	}
+}
+
+func TestSaveNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.txt")
+	buf := New()
+	buf.InsertChar('a')
+	if err := buf.SaveAs(path); err != nil {
+		t.Fatalf("SaveAs failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != newFileMode {
+		t.Errorf("new file: %v, %v", info, err)
+	}
+}
+
+func TestSaveBackups(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir()) // Backups are named after the resolved path
+	path := filepath.Join(dir, "notes.txt")
+	os.WriteFile(path, []byte("v0"), 0644)
+
+	buf, _ := NewFromFile(path)
+	buf.SetOptions(Options{Backup: BackupOptions{Enabled: true, Keep: 2}})
+	for _, ch := range "abc" {
+		buf.InsertChar(ch)
+		if err := buf.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "notes.txt.~*~"))
+	sort.Strings(matches)
+	want := []string{filepath.Join(dir, "notes.txt.~2~"), filepath.Join(dir, "notes.txt.~3~")}
+	if len(matches) != 2 || matches[0] != want[0] || matches[1] != want[1] {
+		t.Fatalf("backups = %v, want %v", matches, want)
+	}
+	data, _ := os.ReadFile(want[1])
+	if string(data) != "abv0" {
+		t.Errorf("latest backup = %q, want the content before the last save", data)
+	}
+
+	// Backups can go to a separate directory
+	backupDir := filepath.Join(dir, "backups")
+	buf.SetOptions(Options{Backup: BackupOptions{Enabled: true, Dir: backupDir}})
+	if err := buf.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	encoded := strings.ReplaceAll(filepath.ToSlash(path), "/", "%")
+	if _, err := os.Stat(filepath.Join(backupDir, encoded+".~1~")); err != nil {
+		t.Errorf("backup not written to backup dir: %v", err)
+	}
+}
+
+func TestSaveBackupsOfSameNamedFiles(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	backupDir := filepath.Join(dir, "backups")
+	var bufs []*Buffer
+	for _, sub := range []string{"a", "b"} {
+		path := filepath.Join(dir, sub, "main.go")
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(sub), 0644)
+		buf, _ := NewFromFile(path)
+		buf.SetOptions(Options{Backup: BackupOptions{Enabled: true, Dir: backupDir, Keep: 1}})
+		bufs = append(bufs, buf)
+	}
+	for _, buf := range bufs {
+		buf.InsertChar('x')
+		if err := buf.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// Each file keeps its own backup
+	entries, _ := os.ReadDir(backupDir)
+	if len(entries) != 2 {
+		t.Fatalf("backup dir holds %d entries, want 2", len(entries))
+	}
+	for _, sub := range []string{"a", "b"} {
+		path := filepath.Join(dir, sub, "main.go")
+		name := strings.ReplaceAll(filepath.ToSlash(path), "/", "%") + ".~1~"
+		if data, _ := os.ReadFile(filepath.Join(backupDir, name)); string(data) != sub {
+			t.Errorf("backup of %s = %q, want %q", path, data, sub)
+		}
+	}
+}
+
+func TestSaveWithoutOwner(t *testing.T) {
+	keep := keepOwner
+	defer func() { keepOwner = keep }()
+	keepOwner = func(path string, info os.FileInfo) bool { return false }
+
+	path := filepath.Join(t.TempDir(), "theirs.txt")
+	os.WriteFile(path, []byte("old"), 0644)
+	buf, _ := NewFromFile(path)
+	buf.InsertChar('x')
+	if err := buf.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "xold" {
+		t.Errorf("file = %q, want it written in place", data)
+	}
+	if buf.Modified() {
+		t.Error("the buffer is still modified")
+	}
+	if len(buf.WriteWarnings()) != 1 {
+		t.Errorf("warnings = %q, want one", buf.WriteWarnings())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want 1", len(entries))
+	}
+}
+
+func TestSaveWithoutBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	os.WriteFile(path, []byte("old"), 0644)
+	blocker := filepath.Join(dir, "blocker")
+	os.WriteFile(blocker, nil, 0644)
+
+	buf, _ := NewFromFile(path)
+	buf.SetOptions(Options{Backup: BackupOptions{Enabled: true, Dir: filepath.Join(blocker, "backups")}})
+	buf.InsertChar('x')
+	if err := buf.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "xold" {
+		t.Errorf("file = %q, want %q", data, "xold")
+	}
+	if warnings := buf.WriteWarnings(); len(warnings) != 1 || !strings.HasPrefix(warnings[0], "no backup") {
+		t.Errorf("warnings = %q, want the missing backup", warnings)
+	}
+}
@@ -0,0 +1,134 @@
+package buffer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SortOptions controls how SortLines orders lines
+type SortOptions struct {
+	Numeric    bool // Compare the first decimal number in each line; lines without one come first
+	IgnoreCase bool // Compare letters case-insensitively
+	Unique     bool // Keep only the first of lines that compare equal
+	Reverse    bool // Sort in descending order
+}
+
+// firstNumber matches the number SortOptions.Numeric compares by
+var firstNumber = regexp.MustCompile(`-?\d+`)
+
+// SortLines sorts lines start..end (inclusive). The sort is stable, so
+// lines that compare equal keep their order. It returns the number of
+// lines removed as duplicates.
+func (b *Buffer) SortLines(start, end int, opts SortOptions) (int, error) {
+	if start < 0 || end >= len(b.lines) || start > end {
+		return 0, fmt.Errorf("line range %d-%d out of range", start, end)
+	}
+
+	type sortKey struct {
+		text      string
+		number    int64
+		hasNumber bool
+	}
+	keyOf := func(line string) sortKey {
+		key := sortKey{text: line}
+		if opts.IgnoreCase {
+			key.text = strings.ToLower(line)
+		}
+		if opts.Numeric {
+			if match := firstNumber.FindString(line); match != "" {
+				key.number, _ = strconv.ParseInt(match, 10, 64)
+				key.hasNumber = true
+			}
+		}
+		return key
+	}
+	compare := func(a, b sortKey) int {
+		if opts.Numeric {
+			switch {
+			case a.hasNumber != b.hasNumber:
+				if a.hasNumber {
+					return 1
+				}
+				return -1
+			case a.number < b.number:
+				return -1
+			case a.number > b.number:
+				return 1
+			}
+			return 0
+		}
+		return strings.Compare(a.text, b.text)
+	}
+
+	type keyedLine struct {
+		line string
+		key  sortKey
+	}
+	lines := make([]keyedLine, 0, end-start+1)
+	for _, line := range b.lines[start : end+1] {
+		lines = append(lines, keyedLine{line, keyOf(line)})
+	}
+	slices.SortStableFunc(lines, func(x, y keyedLine) int {
+		if opts.Reverse {
+			return compare(y.key, x.key)
+		}
+		return compare(x.key, y.key)
+	})
+
+	sorted := make([]string, 0, len(lines))
+	for i, l := range lines {
+		if opts.Unique && i > 0 && compare(lines[i-1].key, l.key) == 0 {
+			continue
+		}
+		sorted = append(sorted, l.line)
+	}
+	removed := len(lines) - len(sorted)
+	if err := b.ReplaceLines(start, end, sorted); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// ReverseLines reverses the order of lines start..end (inclusive)
+func (b *Buffer) ReverseLines(start, end int) error {
+	if start < 0 || end >= len(b.lines) || start > end {
+		return fmt.Errorf("line range %d-%d out of range", start, end)
+	}
+	lines := slices.Clone(b.lines[start : end+1])
+	slices.Reverse(lines)
+	return b.ReplaceLines(start, end, lines)
+}
+
+// UniqLines removes lines in start..end (inclusive) that repeat the line
+// before them, like uniq(1), and returns the number removed
+func (b *Buffer) UniqLines(start, end int, ignoreCase bool) (int, error) {
+	if start < 0 || end >= len(b.lines) || start > end {
+		return 0, fmt.Errorf("line range %d-%d out of range", start, end)
+	}
+	equal := func(x, y string) bool {
+		if ignoreCase {
+			return strings.EqualFold(x, y)
+		}
+		return x == y
+	}
+
+	lines := b.lines[start : end+1]
+	kept := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if i > 0 && equal(lines[i-1], line) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	removed := len(lines) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := b.ReplaceLines(start, end, kept); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
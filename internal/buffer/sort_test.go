@@ -0,0 +1,57 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortLines(t *testing.T) {
+	lines := []string{"b10", "a2", "B10", "x", "a2", "c-3"}
+	tests := []struct {
+		opts    SortOptions
+		want    string
+		removed int
+	}{
+		{SortOptions{}, "B10 a2 a2 b10 c-3 x", 0},
+		{SortOptions{Reverse: true}, "x c-3 b10 a2 a2 B10", 0},
+		{SortOptions{IgnoreCase: true}, "a2 a2 b10 B10 c-3 x", 0},
+		{SortOptions{IgnoreCase: true, Unique: true}, "a2 b10 c-3 x", 2},
+		{SortOptions{Unique: true}, "B10 a2 b10 c-3 x", 1},
+		{SortOptions{Numeric: true}, "x c-3 a2 a2 b10 B10", 0},
+		{SortOptions{Numeric: true, Unique: true}, "x c-3 a2 b10", 2},
+	}
+
+	for _, tt := range tests {
+		buf := New()
+		buf.ReplaceLines(0, 0, lines)
+		removed, err := buf.SortLines(0, len(lines)-1, tt.opts)
+		if err != nil {
+			t.Fatalf("SortLines(%+v): %v", tt.opts, err)
+		}
+		if got := strings.Join(buf.Lines(), " "); got != tt.want || removed != tt.removed {
+			t.Errorf("SortLines(%+v) = %q removing %d, want %q removing %d", tt.opts, got, removed, tt.want, tt.removed)
+		}
+	}
+}
+
+func TestReverseAndUniqLines(t *testing.T) {
+	buf := New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "b", "B", "c", "b"})
+
+	if err := buf.ReverseLines(1, 4); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(buf.Lines(), " "); got != "a c B b b b" {
+		t.Errorf("ReverseLines(1, 4) = %q", got)
+	}
+
+	if removed, _ := buf.UniqLines(0, 4, false); removed != 1 || strings.Join(buf.Lines(), " ") != "a c B b b" {
+		t.Errorf("UniqLines(0, 4) removed %d leaving %q", removed, strings.Join(buf.Lines(), " "))
+	}
+	if removed, _ := buf.UniqLines(0, 4, true); removed != 2 || strings.Join(buf.Lines(), " ") != "a c B" {
+		t.Errorf("UniqLines(0, 4, ignoring case) removed %d leaving %q", removed, strings.Join(buf.Lines(), " "))
+	}
+	if _, err := buf.SortLines(2, 5, SortOptions{}); err == nil {
+		t.Error("expected an error sorting past the last line")
+	}
+}
@@ -0,0 +1,270 @@
+package buffer
+
+import (
+	"fmt"
+	"time"
+)
+
+// now returns the current time; replaced in tests
+var now = time.Now
+
+// undoState is a text state in the undo tree. Every state but the root
+// records the change that turned its parent's text into its own.
+type undoState struct {
+	seq      int
+	parent   *undoState
+	children []*undoState
+	redo     *undoState // Child Redo returns to: the newest or last undone
+	time     time.Time
+	start    int      // First changed line
+	before   []string // Lines replaced by the change
+	after    []string // Lines inserted by the change
+}
+
+// undoTree holds every text state of the buffer. Undoing and making a new
+// change starts a new branch, so no state is ever lost.
+type undoTree struct {
+	states  []*undoState // Indexed by seq, in chronological order
+	current *undoState
+	saved   *undoState // State last written to disk, nil if none matches
+	base    []string   // Text of the current state
+}
+
+// UndoState describes a state of the undo tree
+type UndoState struct {
+	Seq     int       // Number of the state, 0 for the original text
+	Parent  int       // Seq of the state it was changed from, -1 for the root
+	Depth   int       // Number of changes from the original text
+	Time    time.Time // When the change was made
+	Line    int       // First changed line
+	Added   int       // Lines inserted by the change
+	Removed int       // Lines replaced by the change
+	Current bool      // Whether the buffer is in this state
+	Saved   bool      // Whether this state was last written to disk
+}
+
+// resetUndo discards the undo history, making the current text the root
+func (b *Buffer) resetUndo() {
+	root := &undoState{time: now()}
+	b.undo = &undoTree{
+		states:  []*undoState{root},
+		current: root,
+		saved:   root,
+		base:    append([]string(nil), b.lines...),
+	}
+}
+
+// undoTree returns the buffer's undo tree, creating it for buffers built
+// without a constructor
+func (b *Buffer) undoTree() *undoTree {
+	if b.undo == nil {
+		b.resetUndo()
+	}
+	return b.undo
+}
+
+// Commit records the changes made since the last commit as one undo step.
+// Everything between two commits is undone together, so callers choose
+// the granularity: one normal mode command, or one insert session. It
+// reports whether there was anything to record.
+func (b *Buffer) Commit() bool {
+	t := b.undoTree()
+	old := t.base
+
+	// The change is the lines between the common prefix and suffix
+	prefix := 0
+	for prefix < len(old) && prefix < len(b.lines) && old[prefix] == b.lines[prefix] {
+		prefix++
+	}
+	if prefix == len(old) && prefix == len(b.lines) {
+		return false
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(b.lines)-prefix &&
+		old[len(old)-1-suffix] == b.lines[len(b.lines)-1-suffix] {
+		suffix++
+	}
+
+	state := &undoState{
+		seq:    len(t.states),
+		parent: t.current,
+		time:   now(),
+		start:  prefix,
+		before: append([]string(nil), old[prefix:len(old)-suffix]...),
+		after:  append([]string(nil), b.lines[prefix:len(b.lines)-suffix]...),
+	}
+	t.current.children = append(t.current.children, state)
+	t.current.redo = state
+	t.states = append(t.states, state)
+	t.current = state
+	t.base = append([]string(nil), b.lines...)
+	return true
+}
+
+// Undo reverts the current change, moving to its parent state. It reports
+// false when there is nothing to undo.
+func (b *Buffer) Undo() bool {
+	b.Commit()
+	t := b.undo
+	if t.current.parent == nil {
+		return false
+	}
+	b.undoTo(t.current.parent)
+	return true
+}
+
+// Redo reapplies the change last undone from the current state, or its
+// newest change. It reports false when there is nothing to redo.
+func (b *Buffer) Redo() bool {
+	b.Commit()
+	t := b.undo
+	if t.current.redo == nil {
+		return false
+	}
+	b.undoTo(t.current.redo)
+	return true
+}
+
+// UndoTo moves to the state numbered seq, which may be on another branch
+func (b *Buffer) UndoTo(seq int) error {
+	b.Commit()
+	t := b.undo
+	if seq < 0 || seq >= len(t.states) {
+		return fmt.Errorf("undo number %d not found", seq)
+	}
+	b.undoTo(t.states[seq])
+	return nil
+}
+
+// Earlier moves back count states in the order they were made, crossing
+// branches, and returns the seq of the state reached
+func (b *Buffer) Earlier(count int) int {
+	b.Commit()
+	return b.undoToSeq(b.undo.current.seq - count)
+}
+
+// Later moves forward count states in the order they were made, crossing
+// branches, and returns the seq of the state reached
+func (b *Buffer) Later(count int) int {
+	b.Commit()
+	return b.undoToSeq(b.undo.current.seq + count)
+}
+
+// EarlierBy moves to the text as it was d before the current state: the
+// newest state made at least d earlier, or the original text. It moves at
+// least one state back when there is one.
+func (b *Buffer) EarlierBy(d time.Duration) int {
+	b.Commit()
+	t := b.undo
+	limit := t.current.time.Add(-d)
+	target := 0
+	for _, s := range t.states[:t.current.seq] {
+		if !s.time.After(limit) {
+			target = s.seq
+		}
+	}
+	return b.undoToSeq(target)
+}
+
+// LaterBy moves to the text as it was d after the current state: the
+// newest state made at most d later. It moves at least one state forward
+// when there is one.
+func (b *Buffer) LaterBy(d time.Duration) int {
+	b.Commit()
+	t := b.undo
+	limit := t.current.time.Add(d)
+	target := t.current.seq + 1
+	for _, s := range t.states[t.current.seq+1:] {
+		if !s.time.After(limit) {
+			target = s.seq
+		}
+	}
+	return b.undoToSeq(target)
+}
+
+// UndoStates returns every state of the undo tree in the order made
+func (b *Buffer) UndoStates() []UndoState {
+	t := b.undoTree()
+	depth := make([]int, len(t.states))
+	result := make([]UndoState, len(t.states))
+	for i, s := range t.states {
+		state := UndoState{
+			Seq:     s.seq,
+			Parent:  -1,
+			Time:    s.time,
+			Line:    s.start,
+			Added:   len(s.after),
+			Removed: len(s.before),
+			Current: s == t.current,
+			Saved:   s == t.saved,
+		}
+		if s.parent != nil {
+			state.Parent = s.parent.seq
+			depth[i] = depth[s.parent.seq] + 1
+		}
+		state.Depth = depth[i]
+		result[i] = state
+	}
+	return result
+}
+
+// undoToSeq moves to the state numbered seq, clamped to the existing states
+func (b *Buffer) undoToSeq(seq int) int {
+	t := b.undo
+	seq = max(0, min(seq, len(t.states)-1))
+	b.undoTo(t.states[seq])
+	return seq
+}
+
+// undoTo moves the text to target by undoing changes up to the closest
+// common ancestor and then applying the changes down to target. The cursor
+// is left at the last change applied.
+func (b *Buffer) undoTo(target *undoState) {
+	t := b.undo
+	if target == t.current {
+		return
+	}
+
+	onPath := make(map[*undoState]bool)
+	for s := target; s != nil; s = s.parent {
+		onPath[s] = true
+	}
+
+	line := -1
+	for !onPath[t.current] {
+		s := t.current
+		b.lines = splice(b.lines, s.start, len(s.after), s.before)
+		b.noteChange(s.start, len(s.after), len(s.before))
+		s.parent.redo = s
+		t.current = s.parent
+		line = s.start
+	}
+
+	var down []*undoState
+	for s := target; s != t.current; s = s.parent {
+		down = append(down, s)
+	}
+	for i := len(down) - 1; i >= 0; i-- {
+		s := down[i]
+		b.lines = splice(b.lines, s.start, len(s.before), s.after)
+		b.noteChange(s.start, len(s.before), len(s.after))
+		s.parent.redo = s
+		t.current = s
+		line = s.start
+	}
+
+	t.base = append([]string(nil), b.lines...)
+	b.extraCursors = nil
+	b.setModified(t.current != t.saved)
+	if line >= 0 {
+		b.SetCursor(Position{Line: line})
+	}
+}
+
+// splice replaces count lines at start with lines
+func splice(text []string, start, count int, lines []string) []string {
+	result := make([]string, 0, len(text)-count+len(lines))
+	result = append(result, text[:start]...)
+	result = append(result, lines...)
+	return append(result, text[start+count:]...)
+}
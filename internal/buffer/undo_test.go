@@ -0,0 +1,147 @@
+package buffer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setText replaces the whole buffer text, like an edit would
+func setText(buf *Buffer, text string) {
+	buf.ReplaceLines(0, buf.LineCount()-1, strings.Split(text, "\n"))
+}
+
+func TestUndoRedo(t *testing.T) {
+	buf := New()
+	setText(buf, "one\ntwo\nthree")
+	buf.Commit()
+	setText(buf, "one\n2\nthree")
+	buf.Commit()
+
+	if buf.Commit() {
+		t.Error("commit without changes recorded a state")
+	}
+
+	if !buf.Undo() || buf.String() != "one\ntwo\nthree" {
+		t.Fatalf("after undo: %q", buf.String())
+	}
+	if buf.Cursor().Line != 1 {
+		t.Errorf("cursor not at the change: %+v", buf.Cursor())
+	}
+	if !buf.Undo() || buf.String() != "" {
+		t.Fatalf("after second undo: %q", buf.String())
+	}
+	if buf.Undo() {
+		t.Error("undo past the original text")
+	}
+	if buf.Modified() {
+		t.Error("original text marked modified")
+	}
+
+	if !buf.Redo() || !buf.Redo() || buf.String() != "one\n2\nthree" {
+		t.Fatalf("after redo: %q", buf.String())
+	}
+	if buf.Redo() {
+		t.Error("redo past the newest change")
+	}
+	if !buf.Modified() {
+		t.Error("changed text not marked modified")
+	}
+}
+
+func TestUndoBranches(t *testing.T) {
+	buf := New()
+	setText(buf, "a")
+	buf.Commit() // 1
+	setText(buf, "ab")
+	buf.Commit() // 2
+	buf.Undo()
+	setText(buf, "ac")
+	buf.Commit() // 3, a branch from 1
+
+	if err := buf.UndoTo(2); err != nil || buf.String() != "ab" {
+		t.Fatalf("UndoTo(2) = %v, text %q", err, buf.String())
+	}
+	if err := buf.UndoTo(9); err == nil {
+		t.Error("UndoTo of a missing state succeeded")
+	}
+
+	// Undo then redo returns down the branch just left
+	buf.Undo()
+	buf.Redo()
+	if buf.String() != "ab" {
+		t.Errorf("redo went to %q, want the branch undone from", buf.String())
+	}
+
+	// g- and g+ go through states in the order they were made
+	buf.UndoTo(3)
+	if seq := buf.Earlier(1); seq != 2 || buf.String() != "ab" {
+		t.Errorf("Earlier(1) = %d, text %q", seq, buf.String())
+	}
+	if seq := buf.Later(5); seq != 3 || buf.String() != "ac" {
+		t.Errorf("Later(5) = %d, text %q", seq, buf.String())
+	}
+
+	states := buf.UndoStates()
+	if len(states) != 4 || states[3].Parent != 1 || states[3].Depth != 2 || !states[3].Current {
+		t.Errorf("unexpected states: %+v", states)
+	}
+}
+
+func TestUndoByTime(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	defer func(old func() time.Time) { now = old }(now)
+	now = func() time.Time { return clock }
+
+	buf := New()
+	for i, text := range []string{"1", "2", "3", "4"} {
+		clock = clock.Add(time.Duration(i+1) * time.Minute)
+		setText(buf, text)
+		buf.Commit()
+	}
+	// States 1-4 were made at 12:01, 12:03, 12:06 and 12:10
+
+	if seq := buf.EarlierBy(5 * time.Minute); seq != 2 {
+		t.Errorf("EarlierBy(5m) reached state %d, want 2", seq)
+	}
+	if seq := buf.EarlierBy(time.Second); seq != 1 {
+		t.Errorf("EarlierBy(1s) reached state %d, want 1", seq)
+	}
+	if seq := buf.EarlierBy(time.Hour); seq != 0 || buf.String() != "" {
+		t.Errorf("EarlierBy(1h) reached state %d", seq)
+	}
+	if seq := buf.LaterBy(3 * time.Minute); seq != 2 {
+		t.Errorf("LaterBy(3m) reached state %d, want 2", seq)
+	}
+	if seq := buf.LaterBy(5 * time.Minute); seq != 3 || buf.String() != "3" {
+		t.Errorf("LaterBy(5m) reached state %d, text %q", seq, buf.String())
+	}
+}
+
+func TestUndoSavedState(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "undo.txt")
+	buf := New()
+	setText(buf, "saved")
+	if err := buf.SaveAs(filename); err != nil {
+		t.Fatal(err)
+	}
+	setText(buf, "changed")
+	buf.Commit()
+
+	buf.Undo()
+	if buf.Modified() {
+		t.Error("text matching the saved file marked modified")
+	}
+	buf.Undo()
+	if !buf.Modified() {
+		t.Error("text differing from the saved file not marked modified")
+	}
+
+	if err := buf.Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Undo() {
+		t.Error("undo history kept across Load")
+	}
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/quickfix"
+	"go.lsp.dev/protocol"
+)
+
+// Action is something a command leaves for the executor to do once it has
+// run, beyond showing its message, like opening the file a definition is
+// in. Commands describe what should happen, so the result can be checked
+// without the editor.
+type Action interface {
+	apply(s *State, buf *buffer.Buffer) error
+}
+
+// OpenFile opens Filename in the buffer, with the cursor at Line and Col
+// counted from 0. A modified buffer is kept, failing the command.
+type OpenFile struct {
+	Filename string
+	Line     int
+	Col      int
+}
+
+func (a OpenFile) apply(s *State, buf *buffer.Buffer) error {
+	return s.jumpToItem(quickfix.Item{Filename: a.Filename, Line: a.Line, Col: a.Col}, buf)
+}
+
+// SetQuickfix replaces the quickfix list with Items under Title, opening
+// its window when Open is set
+type SetQuickfix struct {
+	Title string
+	Items []quickfix.Item
+	Open  bool
+}
+
+func (a SetQuickfix) apply(s *State, buf *buffer.Buffer) error {
+	s.quickfixLists.Set(a.Title, a.Items)
+	if a.Open {
+		s.quickfixLists.SetOpen(true)
+	}
+	return nil
+}
+
+// ShowText shows Text in the explanation panel under Title until :pclose
+type ShowText struct {
+	Title string
+	Text  string
+}
+
+func (a ShowText) apply(s *State, buf *buffer.Buffer) error {
+	s.ShowPanel(a.Title, a.Text)
+	return nil
+}
+
+// ApplyEdit applies a workspace edit from a language server: to the buffer
+// for the file being edited, and on disk for the rest
+type ApplyEdit struct {
+	Edit *protocol.WorkspaceEdit
+}
+
+func (a ApplyEdit) apply(s *State, buf *buffer.Buffer) error {
+	if _, err := applyWorkspaceEdit(a.Edit, buf); err != nil {
+		return fmt.Errorf("Failed to apply the edit: %v", err)
+	}
+	return nil
+}
+
+// Pick lists Items in the palette under Title for the user to choose one.
+// Chosen returns what choosing the item at an index does, handled like the
+// result of a command.
+type Pick struct {
+	Title  string
+	Items  []string
+	Chosen func(index int) CommandResult
+
+	state *State // Of the executor that ran the command, for the actions of Chosen
+}
+
+func (a Pick) apply(s *State, buf *buffer.Buffer) error {
+	a.state = s
+	s.pendingPick = &a
+	return nil
+}
+
+// PickPending reports whether a list is waiting to be shown in the palette
+func (s *State) PickPending() bool {
+	return s.pendingPick != nil
+}
+
+// TakePick returns the list waiting to be shown in the palette, which is
+// then no longer pending, or nil
+func (s *State) TakePick() *Pick {
+	pick := s.pendingPick
+	s.pendingPick = nil
+	return pick
+}
+
+// Choose does what choosing the item at index does, with its actions
+func (a *Pick) Choose(index int, buf *buffer.Buffer) CommandResult {
+	return applyActions(a.state, a.Chosen(index), buf)
+}
+
+// Compose opens the prompt composer to write a multi-line prompt, starting
+// with Text. Sending the prompt runs Command, a command line like "ai" or
+// "10,20aiedit", with the prompt as its argument.
+type Compose struct {
+	Command string
+	Text    string
+	Preview bool // Text is an AI request held to be trimmed, kept out of the history
+}
+
+func (a Compose) apply(s *State, buf *buffer.Buffer) error {
+	s.pendingCompose = &a
+	return nil
+}
+
+// ComposePending reports whether a prompt is waiting for the composer
+func (s *State) ComposePending() bool {
+	return s.pendingCompose != nil
+}
+
+// TakeCompose returns the prompt waiting for the composer, which is then
+// no longer pending, or nil
+func (s *State) TakeCompose() *Compose {
+	compose := s.pendingCompose
+	s.pendingCompose = nil
+	return compose
+}
+
+// Suggest shows Text as a suggestion to insert at Pos, which changes the
+// buffer only once accepted, in whole or a word or line at a time. Title
+// says where it comes from.
+type Suggest struct {
+	Pos   buffer.Position
+	Text  string
+	Title string
+}
+
+func (a Suggest) apply(s *State, buf *buffer.Buffer) error {
+	s.pendingSuggestion = &a
+	return nil
+}
+
+// SuggestionPending reports whether a suggestion is waiting to be shown
+func (s *State) SuggestionPending() bool {
+	return s.pendingSuggestion != nil
+}
+
+// TakeSuggestion returns the suggestion waiting to be shown, which is then
+// no longer pending, or nil
+func (s *State) TakeSuggestion() *Suggest {
+	suggestion := s.pendingSuggestion
+	s.pendingSuggestion = nil
+	return suggestion
+}
+
+// applyActions does the actions of result in order, failing the result at
+// the first that fails
+func applyActions(s *State, result CommandResult, buf *buffer.Buffer) CommandResult {
+	for _, action := range result.Actions {
+		if err := action.apply(s, buf); err != nil {
+			result.Success = false
+			result.Message = err.Error()
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/quickfix"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+// actionCommand returns its actions when run
+type actionCommand struct {
+	actions []Action
+}
+
+func (c *actionCommand) Name() string      { return "act" }
+func (c *actionCommand) Aliases() []string { return nil }
+func (c *actionCommand) Help() string      { return "" }
+func (c *actionCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return CommandResult{Success: true, Message: "done", Actions: c.actions}
+}
+
+// runActions runs a command returning actions on buf with executor
+func runActions(executor *CommandExecutor, buf *buffer.Buffer, actions ...Action) CommandResult {
+	executor.GetCommands().RegisterCommand(&actionCommand{actions: actions})
+	return executor.Execute("act", buf)
+}
+
+func TestActions(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other.go")
+	os.WriteFile(other, []byte("package other\n\nfunc Old() {}\n"), 0644)
+	executor := NewCommandExecutor()
+
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"Old()"})
+	buf.SetFilename(filepath.Join(dir, "main.go"))
+
+	// A modified buffer isn't replaced
+	if result := runActions(executor, buf, OpenFile{Filename: other, Line: 2, Col: 5}); result.Success || buf.Filename() == other {
+		t.Errorf("opening a file over a modified buffer = %+v", result)
+	}
+
+	// Edits go to the buffer and to the other files on disk
+	edit := &protocol.WorkspaceEdit{Changes: map[uri.URI][]protocol.TextEdit{
+		uri.File(buf.Filename()): {{Range: protocol.Range{End: protocol.Position{Character: 3}}, NewText: "New"}},
+		uri.File(other):          {{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 5}, End: protocol.Position{Line: 2, Character: 8}}, NewText: "New"}},
+	}}
+	if result := runActions(executor, buf, ApplyEdit{Edit: edit}); !result.Success || result.Message != "done" {
+		t.Fatalf("applying the edit = %+v", result)
+	}
+	if got := buf.CurrentLine(); got != "New()" {
+		t.Errorf("buffer after the edit = %q", got)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "package other\n\nfunc New() {}\n" {
+		t.Errorf("other file after the edit = %q", data)
+	}
+	buf.Save()
+
+	items := []quickfix.Item{{Filename: other, Line: 2, Text: "func New() {}"}}
+	result := runActions(executor, buf,
+		SetQuickfix{Title: ":definition", Items: items, Open: true},
+		OpenFile{Filename: other, Line: 2, Col: 5},
+		ShowText{Title: "[Definition]", Text: "func New()"},
+	)
+	if !result.Success {
+		t.Fatalf("actions failed: %+v", result)
+	}
+	if buf.Filename() != other || buf.Cursor() != (buffer.Position{Line: 2, Col: 5}) {
+		t.Errorf("opened %q at %v, want %q at 3:6", buf.Filename(), buf.Cursor(), other)
+	}
+	if list := executor.State().QuickfixLists().Current(); list == nil || list.Title != ":definition" || !executor.State().QuickfixLists().IsOpen() {
+		t.Errorf("quickfix list = %+v, want :definition open", list)
+	}
+	if title, _, open := executor.State().ExplanationPanel(80); !open || title != "[Definition] :pclose closes" {
+		t.Errorf("panel %q open %v", title, open)
+	}
+}
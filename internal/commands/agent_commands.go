@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/agent"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/diff"
+)
+
+// agentTimeout limits the model requests and commands run between two
+// approvals
+const agentTimeout = 10 * time.Minute
+
+// agentCommand is an :agent command
+type agentCommand struct {
+	name    string
+	aliases []string
+	help    string
+	run     func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult
+}
+
+func (c *agentCommand) Name() string {
+	return c.name
+}
+
+func (c *agentCommand) Aliases() []string {
+	return c.aliases
+}
+
+// textArgument makes the task and reasons arrive as typed
+func (c *agentCommand) textArgument() {}
+
+func (c *agentCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(cc, args, buf)
+}
+
+func (c *agentCommand) Help() string {
+	return c.help
+}
+
+// NewAgentCommands creates :agent, :agentyes, :agentno and :agentstop
+func NewAgentCommands() []Command {
+	return []Command{
+		&agentCommand{"agent", nil, ":agent {task} - Let the AI work on a task, reading files, running allowed commands and proposing changes", startAgent},
+		&agentCommand{"agentyes", nil, ":agentyes - Let the agent run the command or write the change it proposes", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			s := cc.State
+			return continueAgent(s, func(ctx context.Context) error { return s.currentAgent.Approve(ctx) })
+		}},
+		&agentCommand{"agentno", nil, ":agentno [reason] - Refuse the agent's proposal, telling it why", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			s, reason := cc.State, strings.Join(args, " ")
+			return continueAgent(s, func(ctx context.Context) error { return s.currentAgent.Reject(ctx, reason) })
+		}},
+		&agentCommand{"agentstop", nil, ":agentstop - Stop the agent and close its panel", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			s := cc.State
+			if s.currentAgent == nil {
+				return CommandResult{Success: false, Message: "No agent running"}
+			}
+			s.currentAgent.Stop()
+			s.currentAgent = nil
+			return CommandResult{Success: true}
+		}},
+	}
+}
+
+// startAgent implements :agent
+func startAgent(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
+		return CommandResult{Success: false, Message: "AI manager not initialized"}
+	}
+	if len(args) == 0 {
+		// Without a task the composer opens to write one
+		return CommandResult{Success: true, Actions: []Action{Compose{Command: "agent"}}}
+	}
+
+	s, cfg := cc.State, cc.currentConfig()
+	root := s.bufferProject(buf).Root
+	s.agentRoot = root
+	s.currentAgent = agent.New(cc.AI, args[0], agent.Options{
+		Root:            root,
+		AllowedCommands: cfg.AI.Agent.AllowedCommands,
+		MaxSteps:        cfg.AI.Agent.MaxSteps,
+		Apply: func(path, content string) error {
+			full := filepath.Join(root, path)
+			if !sameFile(full, buf.Filename()) {
+				return os.WriteFile(full, []byte(content), 0644)
+			}
+			// Changes to the file being edited go into the buffer, where
+			// they can be undone
+			buf.ReplaceLines(0, buf.LineCount()-1, strings.Split(strings.TrimSuffix(content, "\n"), "\n"))
+			return nil
+		},
+		Blocked: cc.Privacy.Blocked,
+	})
+	return continueAgent(s, s.currentAgent.Run)
+}
+
+// continueAgent runs the agent until it needs approval or finishes and
+// reports where it stopped
+func continueAgent(s *State, run func(ctx context.Context) error) CommandResult {
+	if s.currentAgent == nil {
+		return CommandResult{Success: false, Message: "No agent running"}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), agentTimeout)
+	defer cancel()
+
+	s.agentError = run(ctx)
+	switch {
+	case s.agentError != nil:
+		return CommandResult{Success: false, Message: "Agent: " + capitalize(s.agentError.Error())}
+	case s.currentAgent.Pending() != nil:
+		return CommandResult{Success: true, Message: fmt.Sprintf("The agent wants to %s: :agentyes or :agentno", s.currentAgent.Pending())}
+	}
+	return CommandResult{Success: true, Message: "The agent finished"}
+}
+
+// AgentPanel returns the title of the agent panel and its lines wrapped to
+// width, or false when no agent is shown. Proposed changes to the file in
+// buf are shown against the buffer.
+func (s *State) AgentPanel(buf *buffer.Buffer, width int) (string, []string, bool) {
+	if s.currentAgent == nil {
+		return "", nil, false
+	}
+
+	var lines []string
+	for _, m := range s.currentAgent.Messages() {
+		switch m.Role {
+		case "task":
+			lines = append(lines, wrapText("Task: "+m.Text, width)...)
+		case "tool":
+			// Only the call and how it went; file contents would drown the rest
+			first, _, _ := strings.Cut(m.Text, "\n")
+			lines = append(lines, "→ "+first)
+		case "answer":
+			lines = append(lines, wrapText(m.Text, width)...)
+		}
+	}
+
+	title := "[Agent] :agentstop closes"
+	switch call := s.currentAgent.Pending(); {
+	case s.agentError != nil:
+		lines = append(lines, "Stopped: "+s.agentError.Error())
+	case call != nil:
+		title = fmt.Sprintf("[Agent] %s? :agentyes or :agentno [reason]", call)
+		lines = append(lines, "? "+call.String())
+		if call.Tool == agent.ToolProposePatch {
+			lines = append(lines, patchLines(buf, s.agentRoot, call.Path, call.Content)...)
+		}
+	case !s.currentAgent.Done():
+		title = "[Agent] working"
+	}
+	return title, lines, true
+}
+
+// patchLines shows the changes content makes to a file of the agent's
+// project in root as a diff
+func patchLines(buf *buffer.Buffer, root, path, content string) []string {
+	var old []string
+	full := filepath.Join(root, path)
+	if sameFile(full, buf.Filename()) {
+		old = buf.Lines()
+	} else if data, err := os.ReadFile(full); err == nil {
+		old = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	return diff.Unified(old, strings.Split(strings.TrimSuffix(content, "\n"), "\n"))
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestAgentCommands(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n"), 0644)
+	file := filepath.Join(dir, "main.go")
+	os.WriteFile(file, []byte("package main\n"), 0644)
+
+	replies := []string{
+		`{"tool": "propose_patch", "path": "main.go", "content": "package main\n\nfunc main() {}\n"}`,
+		"Added main.",
+	}
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		reply := replies[0]
+		replies = replies[1:]
+		return &ai.AIResponse{Content: reply}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	buf, err := buffer.NewFromFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+	result := executor.Execute("agent add a main function", buf)
+	if !result.Success || result.Message != "The agent wants to write main.go (3 lines): :agentyes or :agentno" {
+		t.Fatalf(":agent = %+v", result)
+	}
+
+	title, lines, open := executor.State().AgentPanel(buf, 40)
+	if !open || !strings.HasPrefix(title, "[Agent] write main.go") {
+		t.Errorf("agent panel title = %q", title)
+	}
+	if want := []string{"Task: add a main function", "? write main.go (3 lines)", "@@ -1,0 +2,2 @@", "+", "+func main() {}"}; !slices.Equal(lines, want) {
+		t.Errorf("agent panel = %q, want %q", lines, want)
+	}
+
+	// Changes to the edited file go into the buffer
+	if result := executor.Execute("agentyes", buf); result.Message != "The agent finished" {
+		t.Errorf(":agentyes = %+v", result)
+	}
+	if want := []string{"package main", "", "func main() {}"}; !slices.Equal(buf.Lines(), want) {
+		t.Errorf("buffer = %q, want %q", buf.Lines(), want)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "package main\n" {
+		t.Errorf("expected the file unchanged until saved, got %q", data)
+	}
+	if _, lines, _ := executor.State().AgentPanel(buf, 40); lines[len(lines)-1] != "Added main." {
+		t.Errorf("expected the answer last in the panel, got %q", lines)
+	}
+
+	executor.Execute("agentstop", buf)
+	if _, _, open := executor.State().AgentPanel(buf, 40); open {
+		t.Error("expected :agentstop to close the panel")
+	}
+}
@@ -2,22 +2,19 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/dshills/aied/internal/ai"
 	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/conversation"
+	"go.lsp.dev/protocol"
 )
 
-// Global AI manager - will be initialized from main
-var aiManager *ai.AIManager
-
-// SetAIManager sets the global AI manager for commands
-func SetAIManager(manager *ai.AIManager) {
-	aiManager = manager
-}
-
 // AICompleteCommand implements AI-powered code completion
 type AICompleteCommand struct{}
 
@@ -33,8 +30,11 @@ func (c *AICompleteCommand) Aliases() []string {
 	return []string{"aic"}
 }
 
-func (c *AICompleteCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if aiManager == nil {
+// routedRequest lets :aic @provider/model complete with another model
+func (c *AICompleteCommand) routedRequest() {}
+
+func (c *AICompleteCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
 		return CommandResult{
 			Success:    false,
 			Message:    "AI manager not initialized",
@@ -58,6 +58,7 @@ func (c *AICompleteCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 	}
 	
 	var contextBuilder strings.Builder
+	contextBuilder.WriteString(cc.State.projectContext(buf))
 	for i := startLine; i <= endLine; i++ {
 		if i == cursor.Line {
 			contextBuilder.WriteString(">>> ")
@@ -71,38 +72,35 @@ func (c *AICompleteCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 	req := ai.AIRequest{
 		Prompt:   currentLine[:cursor.Col], // Everything before cursor
 		Context:  contextBuilder.String(),
-		Language: detectLanguage(buf.Filename()),
+		Language: buf.Filetype(),
 		Type:     ai.RequestCompletion,
 	}
 
-	// Make AI request with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	filename := buf.Filename()
+	return askAI(cc, req, buf, 30*time.Second, func(resp *ai.AIResponse) CommandResult {
+		if strings.TrimSpace(resp.Content) == "" {
+			return CommandResult{Success: false, Message: fmt.Sprintf("No completion from %s", servedBy(resp)), SwitchMode: true}
+		}
+		if line, _ := buf.Line(cursor.Line); buf.Filename() != filename || line != currentLine {
+			return CommandResult{Success: false, Message: "The line changed while waiting for the completion", SwitchMode: true}
+		}
 
-	resp, err := aiManager.Request(ctx, req)
-	if err != nil {
+		// Show the completion at the cursor, for the user to accept it
 		return CommandResult{
-			Success:    false,
-			Message:    fmt.Sprintf("AI error: %s", err.Error()),
+			Success:    true,
+			Message:    fmt.Sprintf("Completed with %s", servedBy(resp)),
 			SwitchMode: true,
+			Actions:    []Action{Suggest{Pos: cursor, Text: resp.Content, Title: resp.Provider}},
 		}
-	}
-
-	// Insert completion at cursor
-	buf.InsertTextAt(cursor.Line, cursor.Col, resp.Content)
-
-	return CommandResult{
-		Success:    true,
-		Message:    fmt.Sprintf("Completed with %s", resp.Provider),
-		SwitchMode: true,
-	}
+	})
 }
 
 func (c *AICompleteCommand) Help() string {
-	return "Complete code at cursor position using AI"
+	return "Complete code at cursor position using AI, shown as a suggestion to accept"
 }
 
-// AIExplainCommand explains selected code or current line
+// AIExplainCommand explains the selected lines, the function at the cursor
+// or code given as arguments, in the explanation panel
 type AIExplainCommand struct{}
 
 func NewAIExplainCommand() *AIExplainCommand {
@@ -117,8 +115,47 @@ func (c *AIExplainCommand) Aliases() []string {
 	return []string{"aie"}
 }
 
-func (c *AIExplainCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if aiManager == nil {
+// routedRequest lets :aie @provider/model explain with another model
+func (c *AIExplainCommand) routedRequest() {}
+
+func (c *AIExplainCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if len(args) > 0 {
+		// If args provided, use them as the code to explain
+		return explainCode(cc, strings.Join(args, " "), "", "[AI Explanation]", buf)
+	}
+	r, ok := enclosingFunction(cc, buf)
+	if !ok {
+		r = currentLine(buf)
+	}
+	return c.ExecuteRange(cc, r, args, buf)
+}
+
+func (c *AIExplainCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	code := strings.Join(buf.Lines()[r.Start:r.End+1], "\n")
+
+	// Problems the editor knows about are often what needs explaining
+	var problems strings.Builder
+	for line := r.Start; line <= r.End; line++ {
+		for _, diag := range buf.GetDiagnosticsForLine(line) {
+			fmt.Fprintf(&problems, "line %d: %s\n", line-r.Start+1, diag.Message)
+		}
+	}
+
+	title := fmt.Sprintf("[AI Explanation] line %d", r.Start+1)
+	if r.End > r.Start {
+		title = fmt.Sprintf("[AI Explanation] lines %d-%d", r.Start+1, r.End+1)
+	}
+	return explainCode(cc, code, problems.String(), title, buf)
+}
+
+func (c *AIExplainCommand) Help() string {
+	return ":[range]aiexplain [code] - Explain the selection, the function at the cursor or the given code"
+}
+
+// explainCode asks the AI to explain code, mentioning the problems reported
+// in it, and shows the answer in the explanation panel under title
+func explainCode(cc *CommandContext, code, problems, title string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
 		return CommandResult{
 			Success:    false,
 			Message:    "AI manager not initialized",
@@ -126,44 +163,137 @@ func (c *AIExplainCommand) Execute(args []string, buf *buffer.Buffer) CommandRes
 		}
 	}
 
-	// Get current line or selection (for now just current line)
-	codeToExplain := buf.CurrentLine()
-
-	if len(args) > 0 {
-		// If args provided, use them as the code to explain
-		codeToExplain = strings.Join(args, " ")
+	prompt := fmt.Sprintf("Explain this code:\n\n%s", code)
+	if problems != "" {
+		prompt += fmt.Sprintf("\n\nThe editor reports these problems, by line of the code:\n%s", problems)
 	}
-
-	// Create AI request
 	req := ai.AIRequest{
-		Prompt:   fmt.Sprintf("Explain this code: %s", codeToExplain),
-		Language: detectLanguage(buf.Filename()),
+		Prompt:   prompt,
+		Context:  cc.State.projectContext(buf),
+		Language: buf.Filetype(),
 		Type:     ai.RequestExplanation,
 	}
 
-	// Make AI request
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	resp, err := aiManager.Request(ctx, req)
-	if err != nil {
+	return askAI(cc, req, buf, 30*time.Second, func(resp *ai.AIResponse) CommandResult {
+		cc.State.ShowPanel(title, resp.Content)
 		return CommandResult{
-			Success:    false,
-			Message:    fmt.Sprintf("AI error: %s", err.Error()),
+			Success:    true,
+			Message:    fmt.Sprintf("Explained by %s", servedBy(resp)),
 			SwitchMode: true,
 		}
+	})
+}
+
+// enclosingFunction returns the lines of the innermost function or method
+// around the cursor, as reported by the language server
+func enclosingFunction(cc *CommandContext, buf *buffer.Buffer) (Range, bool) {
+	if cc.LSP == nil || buf.Filename() == "" {
+		return Range{}, false
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	symbols, err := cc.LSP.DocumentSymbols(ctx, buf.Filename())
+	if err != nil {
+		return Range{}, false
+	}
+	return innermostFunction(symbols, buf.Cursor().Line)
+}
 
-	// For now, just show the message - later we'll add a popup or split view
-	return CommandResult{
-		Success:    true,
-		Message:    resp.Content,
-		SwitchMode: true,
+// innermostFunction returns the lines of the smallest function or method
+// among symbols and their children that contains line
+func innermostFunction(symbols []protocol.DocumentSymbol, line int) (Range, bool) {
+	var found Range
+	ok := false
+	for _, symbol := range symbols {
+		start, end := int(symbol.Range.Start.Line), int(symbol.Range.End.Line)
+		if line < start || line > end {
+			continue
+		}
+		isFunction := symbol.Kind == protocol.SymbolKindFunction || symbol.Kind == protocol.SymbolKindMethod || symbol.Kind == protocol.SymbolKindConstructor
+		if isFunction && (!ok || end-start < found.End-found.Start) {
+			found, ok = Range{Start: start, End: end}, true
+		}
+		if inner, innerOK := innermostFunction(symbol.Children, line); innerOK && (!ok || inner.End-inner.Start < found.End-found.Start) {
+			found, ok = inner, true
+		}
 	}
+	return found, ok
 }
 
-func (c *AIExplainCommand) Help() string {
-	return "Explain code at cursor or provided as argument"
+// aiExplanation is the answer of the last :aiexplain
+type aiExplanation struct {
+	title string
+	text  string
+}
+
+// ShowPanel shows text in the explanation panel under title until :pclose
+func (s *State) ShowPanel(title, text string) {
+	s.explanation = &aiExplanation{title: title + " :pclose closes", text: text}
+}
+
+// ExplanationPanel returns the title of the explanation panel and its text
+// wrapped to width, or false when the panel is closed
+func (s *State) ExplanationPanel(width int) (string, []string, bool) {
+	if s.explanation == nil {
+		return "", nil, false
+	}
+	return s.explanation.title, wrapText(s.explanation.text, width), true
+}
+
+// wrapText breaks text into lines of at most width characters at spaces,
+// keeping the line breaks and indentation it has. Markdown code blocks are
+// left as they are.
+func wrapText(text string, width int) []string {
+	var lines []string
+	inCode := false
+	for _, paragraph := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(paragraph), "```") {
+			inCode = !inCode
+		}
+		if inCode || strings.HasPrefix(strings.TrimSpace(paragraph), "```") {
+			lines = append(lines, paragraph)
+			continue
+		}
+		indent := paragraph[:len(paragraph)-len(strings.TrimLeft(paragraph, " \t"))]
+		line := indent
+		for _, word := range strings.Fields(paragraph) {
+			switch {
+			case line == indent:
+				line += word
+			case len([]rune(line))+1+len([]rune(word)) > width:
+				lines = append(lines, line)
+				line = indent + word
+			default:
+				line += " " + word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// PreviewCloseCommand implements :pclose, closing the explanation panel
+type PreviewCloseCommand struct{}
+
+func NewPreviewCloseCommand() *PreviewCloseCommand {
+	return &PreviewCloseCommand{}
+}
+
+func (c *PreviewCloseCommand) Name() string {
+	return "pclose"
+}
+
+func (c *PreviewCloseCommand) Aliases() []string {
+	return []string{"pc"}
+}
+
+func (c *PreviewCloseCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	cc.State.explanation = nil
+	return CommandResult{Success: true}
+}
+
+func (c *PreviewCloseCommand) Help() string {
+	return ":pclose - Close the AI explanation panel"
 }
 
 // AIRefactorCommand suggests refactoring for selected code
@@ -181,8 +311,11 @@ func (c *AIRefactorCommand) Aliases() []string {
 	return []string{"air"}
 }
 
-func (c *AIRefactorCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if aiManager == nil {
+// routedRequest lets :air @provider/model suggest refactorings with another model
+func (c *AIRefactorCommand) routedRequest() {}
+
+func (c *AIRefactorCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
 		return CommandResult{
 			Success:    false,
 			Message:    "AI manager not initialized",
@@ -196,29 +329,18 @@ func (c *AIRefactorCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 	// Create AI request
 	req := ai.AIRequest{
 		Prompt:   codeToRefactor,
-		Language: detectLanguage(buf.Filename()),
+		Language: buf.Filetype(),
 		Type:     ai.RequestRefactor,
 	}
 
-	// Make AI request
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	resp, err := aiManager.Request(ctx, req)
-	if err != nil {
+	return askAI(cc, req, buf, 30*time.Second, func(resp *ai.AIResponse) CommandResult {
+		// For now, show suggestion - later we'll add preview and apply
 		return CommandResult{
-			Success:    false,
-			Message:    fmt.Sprintf("AI error: %s", err.Error()),
+			Success:    true,
+			Message:    withFallback(fmt.Sprintf("Refactor suggestion: %s", resp.Content), resp),
 			SwitchMode: true,
 		}
-	}
-
-	// For now, show suggestion - later we'll add preview and apply
-	return CommandResult{
-		Success:    true,
-		Message:    fmt.Sprintf("Refactor suggestion: %s", resp.Content),
-		SwitchMode: true,
-	}
+	})
 }
 
 func (c *AIRefactorCommand) Help() string {
@@ -240,8 +362,8 @@ func (c *AIChatCommand) Aliases() []string {
 	return []string{}
 }
 
-func (c *AIChatCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if aiManager == nil {
+func (c *AIChatCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
 		return CommandResult{
 			Success:    false,
 			Message:    "AI manager not initialized",
@@ -250,10 +372,11 @@ func (c *AIChatCommand) Execute(args []string, buf *buffer.Buffer) CommandResult
 	}
 
 	if len(args) == 0 {
+		// Without a question the composer opens to write one
 		return CommandResult{
-			Success:    false,
-			Message:    "Usage: :ai <question>",
+			Success:    true,
 			SwitchMode: true,
+			Actions:    []Action{Compose{Command: "ai" + cc.routeArgument()}},
 		}
 	}
 
@@ -261,43 +384,89 @@ func (c *AIChatCommand) Execute(args []string, buf *buffer.Buffer) CommandResult
 
 	// Get current file context
 	var contextBuilder strings.Builder
-	contextBuilder.WriteString(fmt.Sprintf("File: %s\n", buf.Filename()))
-	contextBuilder.WriteString(fmt.Sprintf("Language: %s\n", detectLanguage(buf.Filename())))
+	contextBuilder.WriteString(cc.State.projectContext(buf))
+	contextBuilder.WriteString(fmt.Sprintf("Language: %s\n", buf.Filetype()))
 	
 	// Add current line info
 	cursor := buf.Cursor()
 	contextBuilder.WriteString(fmt.Sprintf("Current line %d: %s\n", cursor.Line+1, buf.CurrentLine()))
 	contextBuilder.WriteString(fmt.Sprintf("Cursor at column %d\n", cursor.Col))
 
-	// Create AI request
+	// Continue the project's thread
+	settings := historySettings(cc)
+	store := cc.State.historyStore(buf, settings)
+	thread := cc.State.currentThread(buf, store)
+	if transcript := thread.Transcript(settings.MaxMessages); transcript != "" {
+		contextBuilder.WriteString("\nConversation so far:\n")
+		contextBuilder.WriteString(transcript)
+	}
+
+	// Create AI request, its answer shown as it arrives where the provider
+	// streams it
 	req := ai.AIRequest{
 		Prompt:   question,
 		Context:  contextBuilder.String(),
 		Type:     ai.RequestChat,
+		OnChunk:  streamAnswer(cc),
 	}
 
-	// Make AI request
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	return askAI(cc, req, buf, 30*time.Second, func(resp *ai.AIResponse) CommandResult {
+		now := time.Now()
+		thread.Add(conversation.RoleUser, question, now)
+		thread.Add(conversation.RoleAssistant, resp.Content, now)
+		message := withFallback(resp.Content, resp)
+		if store != nil {
+			if err := store.Save(thread); err != nil {
+				message += fmt.Sprintf(" (thread not saved: %s)", err.Error())
+			}
+		}
 
-	resp, err := aiManager.Request(ctx, req)
-	if err != nil {
 		return CommandResult{
-			Success:    false,
-			Message:    fmt.Sprintf("AI error: %s", err.Error()),
+			Success:    true,
+			Message:    message,
 			SwitchMode: true,
 		}
-	}
+	})
+}
 
-	return CommandResult{
-		Success:    true,
-		Message:    resp.Content,
-		SwitchMode: true,
+// ExecuteRange asks about the lines in range, which follow the question
+// as a code block
+func (c *AIChatCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	block := strings.Join(FencedLines(buf, r.Start, r.End), "\n")
+	if len(args) == 0 && cc.AI != nil {
+		return CommandResult{
+			Success:    true,
+			SwitchMode: true,
+			Actions:    []Action{Compose{Command: "ai" + cc.routeArgument(), Text: "\n\n" + block}},
+		}
+	}
+	if len(args) > 0 {
+		args = []string{args[0] + "\n\n" + block}
 	}
+	return c.Execute(cc, args, buf)
 }
 
+// textArgument makes the question arrive as typed, with its lines
+func (c *AIChatCommand) textArgument() {}
+
+// routedRequest lets @provider/model before the question choose where it
+// goes
+func (c *AIChatCommand) routedRequest() {}
+
 func (c *AIChatCommand) Help() string {
-	return "Ask AI a general question about your code"
+	return ":[range]ai [@provider/model] [question] - Ask AI a question about your code, with the lines in range as a code block, of the provider and model given for this question alone; without a question the prompt composer opens"
+}
+
+// FencedLines returns lines start to end of buf as a markdown code block,
+// fenced with the buffer's filetype
+func FencedLines(buf *buffer.Buffer, start, end int) []string {
+	lines := buf.Lines()
+	start, end = max(start, 0), min(end, len(lines)-1)
+	block := []string{"```" + buf.Filetype()}
+	if start <= end {
+		block = append(block, lines[start:end+1]...)
+	}
+	return append(block, "```")
 }
 
 // AIProviderCommand manages AI providers
@@ -315,8 +484,8 @@ func (c *AIProviderCommand) Aliases() []string {
 	return []string{"aip"}
 }
 
-func (c *AIProviderCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if aiManager == nil {
+func (c *AIProviderCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
 		return CommandResult{
 			Success:    false,
 			Message:    "AI manager not initialized",
@@ -325,90 +494,225 @@ func (c *AIProviderCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 	}
 
 	if len(args) == 0 {
-		// List available providers
-		providers := aiManager.ListAvailableProviders()
-		active := aiManager.GetActiveProvider()
-		
-		var providerList []string
-		for pType, provider := range providers {
-			marker := ""
-			if active != nil && provider.Name() == active.Name() {
-				marker = " (active)"
-			}
-			providerList = append(providerList, fmt.Sprintf("%s%s", pType, marker))
+		return pickProvider(cc)
+	}
+	return setProvider(cc, args[0])
+}
+
+func (c *AIProviderCommand) Help() string {
+	return "List or set active AI provider, as provider or provider/model"
+}
+
+// AICancelCommand implements :aicancel, which cancels the AI requests
+// under way
+type AICancelCommand struct{}
+
+func NewAICancelCommand() *AICancelCommand {
+	return &AICancelCommand{}
+}
+
+func (c *AICancelCommand) Name() string {
+	return "aicancel"
+}
+
+func (c *AICancelCommand) Aliases() []string {
+	return nil
+}
+
+func (c *AICancelCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
+		return CommandResult{Success: false, Message: "AI manager not initialized"}
+	}
+	canceled := 0
+	for _, id := range cc.AI.InFlight() {
+		if cc.AI.Cancel(id) {
+			canceled++
 		}
-		
-		if len(providerList) == 0 {
-			return CommandResult{
-				Success:    true,
-				Message:    "No AI providers available",
-				SwitchMode: true,
-			}
+	}
+	switch canceled {
+	case 0:
+		return CommandResult{Success: false, Message: "No AI request to cancel"}
+	case 1:
+		return CommandResult{Success: true, Message: "Canceling the AI request"}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Canceling %d AI requests", canceled)}
+}
+
+func (c *AICancelCommand) Help() string {
+	return ":aicancel - Cancel the AI requests under way, as Ctrl-C does while they run"
+}
+
+// askAI sends an AI request about buf, naming its file and project for
+// the privacy rules, and returns the result answered makes of the answer.
+// Where the editor finishes commands later, the request goes on in the
+// background, canceled with :aicancel or Ctrl-C, and answered runs on the
+// main loop once the answer arrives; the command's result then just says
+// it is waiting.
+func askAI(cc *CommandContext, req ai.AIRequest, buf *buffer.Buffer, timeout time.Duration, answered func(resp *ai.AIResponse) CommandResult) CommandResult {
+	req.Filename = buf.Filename()
+	req.Root = cc.State.bufferProject(buf).Root
+	if cc.Route != nil {
+		req.Provider = cc.Route.Provider
+		if cc.Route.Model != "" {
+			req.Model = cc.Route.Model
 		}
-		
-		return CommandResult{
-			Success:    true,
-			Message:    fmt.Sprintf("Available providers: %s", strings.Join(providerList, ", ")),
-			SwitchMode: true,
+	}
+	req, err := holdForPreview(cc, req, buf)
+	if err != nil {
+		return aiFailure(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if cc.Defer == nil {
+		defer cancel()
+		resp, err := cc.AI.Request(ctx, req)
+		if err != nil {
+			return aiFailure(err)
 		}
+		return answered(resp)
 	}
+	cc.AI.Go(ctx, req, func(result ai.Result) {
+		cancel()
+		cc.Defer(func() CommandResult {
+			if result.Err != nil {
+				return aiFailure(result.Err)
+			}
+			return applyActions(cc.State, answered(result.Response), buf)
+		})
+	})
+	return CommandResult{Success: true, Message: "Asking the AI... (Ctrl-C cancels)", SwitchMode: true}
+}
 
-	// Set active provider
-	providerType := ai.ProviderType(args[0])
-	err := aiManager.SetActiveProvider(providerType)
-	if err != nil {
-		return CommandResult{
-			Success:    false,
-			Message:    fmt.Sprintf("Failed to set provider: %s", err.Error()),
-			SwitchMode: true,
+// streamAnswer returns an OnChunk callback showing the answer so far as the
+// message of a command waiting in the background, or nil for commands that
+// wait for the whole answer. Pieces arriving before the main loop shows the
+// last are shown together.
+func streamAnswer(cc *CommandContext) func(text string) {
+	if cc.Defer == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	var answer strings.Builder
+	waiting := false // Whether the main loop is yet to show the answer so far
+	show := func() CommandResult {
+		mu.Lock()
+		defer mu.Unlock()
+		waiting = false
+		return CommandResult{Success: true, Message: answer.String()}
+	}
+	return func(text string) {
+		mu.Lock()
+		answer.WriteString(text)
+		schedule := !waiting
+		waiting = true
+		mu.Unlock()
+		if schedule {
+			cc.Defer(show)
 		}
 	}
+}
 
-	return CommandResult{
-		Success:    true,
-		Message:    fmt.Sprintf("Active provider set to: %s", providerType),
-		SwitchMode: true,
+// servedBy names the provider that served resp, and why the ones tried
+// before it failed
+func servedBy(resp *ai.AIResponse) string {
+	if len(resp.Failed) == 0 {
+		return resp.Provider
+	}
+	causes := make([]string, len(resp.Failed))
+	for i, err := range resp.Failed {
+		causes[i] = err.Error()
 	}
+	return fmt.Sprintf("%s (after %s)", resp.Provider, strings.Join(causes, "; "))
 }
 
-func (c *AIProviderCommand) Help() string {
-	return "List or set active AI provider"
+// routedCommand marks AI commands whose arguments may start with
+// @provider, @provider/model or --model model, sending the command's
+// request there instead of where it would go
+type routedCommand interface {
+	routedRequest()
+}
+
+// parseRoute takes the provider and model a routed command's argument text
+// starts with off it. A word after @ that names no provider is left, as
+// text like "@param" that just starts with @.
+func parseRoute(cc *CommandContext, text string) (*ai.Route, string, error) {
+	word, rest := cutWord(text)
+	switch {
+	case word == "--model":
+		model, rest := cutWord(rest)
+		if model == "" {
+			return nil, text, fmt.Errorf("--model needs a model name")
+		}
+		return &ai.Route{Model: model}, rest, nil
+	case strings.HasPrefix(word, "@"):
+		name, model, _ := strings.Cut(word[1:], "/")
+		provider := ai.ProviderType(name)
+		if !provider.Valid() {
+			return nil, text, nil
+		}
+		if cc.AI != nil {
+			if _, ok := cc.AI.GetProvider(provider); !ok {
+				return nil, text, fmt.Errorf("AI provider %s isn't configured (:aip lists them)", provider)
+			}
+		}
+		return &ai.Route{Provider: provider, Model: model}, rest, nil
+	}
+	return nil, text, nil
+}
+
+// cutWord splits text at the end of its first word, trimming the space
+// after it
+func cutWord(text string) (word, rest string) {
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		return text, ""
+	}
+	return text[:end], strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+}
+
+// routeArgument is the @provider/model of cc's route, with a space before
+// it, for commands the composer runs to go where the command went
+func (cc *CommandContext) routeArgument() string {
+	r := cc.Route
+	switch {
+	case r == nil:
+		return ""
+	case r.Provider == "":
+		return " --model " + r.Model
+	case r.Model == "":
+		return " @" + string(r.Provider)
+	}
+	return " @" + string(r.Provider) + "/" + r.Model
+}
+
+// aiFailure is the result of a command whose AI request failed, or was
+// held for preview
+func aiFailure(err error) CommandResult {
+	if errors.Is(err, errHeld) {
+		return CommandResult{Success: true, Message: "Trim what the AI request sends, then Ctrl-Enter sends it"}
+	}
+	if errors.Is(err, context.Canceled) {
+		return CommandResult{Success: false, Message: "AI request canceled", SwitchMode: true}
+	}
+	return CommandResult{Success: false, Message: fmt.Sprintf("AI error: %s", err.Error()), SwitchMode: true}
+}
+
+// withFallback adds to a message showing a response which provider served
+// it, when others failed first
+func withFallback(message string, resp *ai.AIResponse) string {
+	if len(resp.Failed) == 0 {
+		return message
+	}
+	return fmt.Sprintf("%s [%s]", message, servedBy(resp))
 }
 
 // Helper function to detect programming language from filename
-func detectLanguage(filename string) string {
-	parts := strings.Split(filename, ".")
-	if len(parts) < 2 {
-		return "text"
+// projectContext describes the buffer's project and file for AI requests
+func (s *State) projectContext(buf *buffer.Buffer) string {
+	proj := s.bufferProject(buf)
+	context := fmt.Sprintf("Project: %s (root %s)\n", proj.Name(), proj.Root)
+	if buf.Filename() != "" {
+		context += fmt.Sprintf("File: %s\n", proj.Relative(buf.Filename()))
 	}
-	
-	ext := parts[len(parts)-1]
-	switch ext {
-	case "go":
-		return "go"
-	case "py":
-		return "python"
-	case "js", "jsx":
-		return "javascript"
-	case "ts", "tsx":
-		return "typescript"
-	case "java":
-		return "java"
-	case "c":
-		return "c"
-	case "cpp", "cc", "cxx":
-		return "cpp"
-	case "rs":
-		return "rust"
-	case "rb":
-		return "ruby"
-	case "php":
-		return "php"
-	case "sh", "bash":
-		return "bash"
-	case "md":
-		return "markdown"
-	default:
-		return "text"
-	}
-}
\ No newline at end of file
+	return context
+}
@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"go.lsp.dev/protocol"
+)
+
+func TestAIExplain(t *testing.T) {
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	var prompt string
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		prompt = req.Prompt
+		return &ai.AIResponse{Content: "It adds one to x, which is never used.", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"func f() {", "\tx := 1", "\tx++", "}"})
+	buf.SetDiagnostics([]buffer.Diagnostic{{Line: 1, Message: "declared and not used: x"}})
+
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+	if result := executor.Execute("2,3aiexplain", buf); !result.Success {
+		t.Fatalf(":aiexplain = %+v", result)
+	}
+	if !strings.Contains(prompt, "\tx := 1\n\tx++") || !strings.Contains(prompt, "line 1: declared and not used: x") {
+		t.Errorf("prompt = %q", prompt)
+	}
+
+	title, lines, open := executor.State().ExplanationPanel(20)
+	if !open || title != "[AI Explanation] lines 2-3 :pclose closes" {
+		t.Errorf("explanation panel title = %q, open %v", title, open)
+	}
+	if want := []string{"It adds one to x,", "which is never used."}; !slices.Equal(lines, want) {
+		t.Errorf("explanation lines = %q, want %q", lines, want)
+	}
+
+	executor.Execute("pclose", buf)
+	if _, _, open := executor.State().ExplanationPanel(20); open {
+		t.Error("expected :pclose to close the explanation")
+	}
+}
+
+func TestInnermostFunction(t *testing.T) {
+	lines := func(start, end uint32) protocol.Range {
+		return protocol.Range{Start: protocol.Position{Line: start}, End: protocol.Position{Line: end}}
+	}
+	symbols := []protocol.DocumentSymbol{
+		{Name: "T", Kind: protocol.SymbolKindClass, Range: lines(0, 20), Children: []protocol.DocumentSymbol{
+			{Name: "m", Kind: protocol.SymbolKindMethod, Range: lines(2, 10)},
+			{Name: "x", Kind: protocol.SymbolKindField, Range: lines(12, 12)},
+		}},
+		{Name: "f", Kind: protocol.SymbolKindFunction, Range: lines(22, 30), Children: []protocol.DocumentSymbol{
+			{Name: "g", Kind: protocol.SymbolKindFunction, Range: lines(24, 26)},
+		}},
+	}
+
+	tests := []struct {
+		line int
+		want Range
+		ok   bool
+	}{
+		{5, Range{Start: 2, End: 10}, true},
+		{12, Range{}, false},
+		{23, Range{Start: 22, End: 30}, true},
+		{25, Range{Start: 24, End: 26}, true},
+	}
+	for _, tt := range tests {
+		if got, ok := innermostFunction(symbols, tt.line); got != tt.want || ok != tt.ok {
+			t.Errorf("innermostFunction at line %d = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	text := "A long sentence to wrap\n  - an indented item\n```\nif  x {\n```"
+	want := []string{"A long sentence", "to wrap", "  - an indented", "  item", "```", "if  x {", "```"}
+	if got := wrapText(text, 16); !slices.Equal(got, want) {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+}
+
+func TestAIComposeWithoutPrompt(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	var prompt string
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		prompt = req.Prompt
+		return &ai.AIResponse{Content: "Yes", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a := 1", "b := 2", "c := 3"})
+	buf.SetFiletype("go")
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+
+	tests := []struct {
+		line string
+		want Compose
+	}{
+		{"ai", Compose{Command: "ai"}},
+		{"2,3ai", Compose{Command: "ai", Text: "\n\n```go\nb := 2\nc := 3\n```"}},
+		{"2aiedit", Compose{Command: "2,2aiedit"}},
+		{"agent", Compose{Command: "agent"}},
+	}
+	for _, tt := range tests {
+		if result := executor.Execute(tt.line, buf); !result.Success {
+			t.Errorf(":%s = %+v", tt.line, result)
+		}
+		if got := executor.State().TakeCompose(); got == nil || *got != tt.want {
+			t.Errorf(":%s left %+v for the composer, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	// A question with a range has the lines after it
+	executor.Execute("1ai Is a used?", buf)
+	if prompt != "Is a used?\n\n```go\na := 1\n```" || executor.State().ComposePending() {
+		t.Errorf("prompt = %q", prompt)
+	}
+}
+
+func TestAIRoute(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ollama := ai.NewMockProvider(ai.ProviderOllama)
+	anthropic := ai.NewMockProvider(ai.ProviderAnthropic)
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(ollama)
+	manager.RegisterProvider(anthropic)
+	manager.SetActiveProvider(ai.ProviderOllama)
+
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"x := 1"})
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+
+	if result := executor.Execute("ai @anthropic/claude-3-5-haiku what's x?", buf); !result.Success {
+		t.Fatalf(":ai @anthropic = %+v", result)
+	}
+	if calls := anthropic.GetChatCalls(); len(calls) != 1 || calls[0].Prompt != "what's x?" || calls[0].Model != "claude-3-5-haiku" {
+		t.Errorf("expected the question sent to anthropic's claude-3-5-haiku, got %+v", calls)
+	}
+	if manager.GetActiveProvider().Name() != ai.ProviderOllama {
+		t.Error("expected the active provider kept")
+	}
+
+	executor.Execute("aic @anthropic", buf)
+	executor.Execute(`aiedit @anthropic "add y"`, buf)
+	if calls := anthropic.GetChatCalls(); len(anthropic.GetCompleteCalls()) != 1 || len(calls) != 2 || !strings.Contains(calls[1].Prompt, "add y") {
+		t.Errorf("expected :aic and :aiedit sent to anthropic, got %+v", calls)
+	}
+
+	executor.Execute("ai --model llama3 @param is what?", buf)
+	if calls := ollama.GetChatCalls(); len(calls) != 1 || calls[0].Model != "llama3" || calls[0].Prompt != "@param is what?" {
+		t.Errorf("expected --model with the routed provider, got %+v", calls)
+	}
+
+	if result := executor.Execute("ai @openai hi", buf); result.Success || !strings.Contains(result.Message, "openai isn't configured") {
+		t.Errorf(":ai @openai gave %+v", result)
+	}
+
+	// The composer sends to the provider named
+	executor.Execute("ai @anthropic", buf)
+	if got := executor.State().TakeCompose(); got == nil || got.Command != "ai @anthropic" {
+		t.Errorf(":ai @anthropic left %+v for the composer", got)
+	}
+}
+
+func TestAIInBackground(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	release := make(chan struct{})
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		select {
+		case <-release:
+			req.OnChunk("Forty")
+			req.OnChunk("-two")
+			return &ai.AIResponse{Content: "Forty-two", Provider: "ollama"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	finished := make(chan func() CommandResult, 1)
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager, Defer: func(finish func() CommandResult) { finished <- finish }})
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"x"})
+
+	// The command returns at once, and :aicancel cancels its request
+	if result := executor.Execute("ai Why?", buf); !result.Success || !strings.Contains(result.Message, "Ctrl-C") {
+		t.Fatalf(":ai = %+v", result)
+	}
+	if result := executor.Execute("aicancel", buf); !result.Success {
+		t.Errorf(":aicancel = %+v", result)
+	}
+	if result := (<-finished)(); result.Success || result.Message != "AI request canceled" {
+		t.Errorf("canceled request finished with %+v", result)
+	}
+	if result := executor.Execute("aicancel", buf); result.Success {
+		t.Errorf(":aicancel without requests = %+v", result)
+	}
+
+	// The answer is shown as it arrives, and whole once it has
+	executor.Execute("ai Why?", buf)
+	close(release)
+	if result := (<-finished)(); !result.Success || result.Message == "" || !strings.HasPrefix("Forty-two", result.Message) {
+		t.Errorf("streamed answer shown as %+v", result)
+	}
+	if result := (<-finished)(); !result.Success || result.Message != "Forty-two" {
+		t.Errorf("answered request finished with %+v", result)
+	}
+}
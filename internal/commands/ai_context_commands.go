@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// SetPreviewContext sets whether AI requests open a preview of their prompt
+// and context, sent with Ctrl-Enter once trimmed, instead of going out at once
+func (s *State) SetPreviewContext(on bool) {
+	s.previewContext = on
+}
+
+// contextMarker separates the prompt from the context in a preview
+const contextMarker = "--- context ---"
+
+// errHeld is what requestAI returns for a request held for preview
+var errHeld = errors.New("request held for preview")
+
+// heldRequest is an AI request held for preview, with the command line that
+// made it, which runs again to send it
+type heldRequest struct {
+	line    string
+	req     ai.AIRequest  // As the command made it
+	shown   ai.AIRequest  // As the preview shows it, with secrets redacted
+	trimmed *ai.AIRequest // What to send in its place, once the preview is sent
+}
+
+// holdForPreview holds req, unless it is the one a preview was sent for,
+// and opens the preview. It returns the request to send instead, or
+// errHeld when req waits for the preview.
+func holdForPreview(cc *CommandContext, req ai.AIRequest, buf *buffer.Buffer) (ai.AIRequest, error) {
+	s := cc.State
+	if h := s.held; h != nil && h.trimmed != nil {
+		s.held = nil
+		if h.req.Type == req.Type && h.req.Prompt == req.Prompt && h.req.Context == req.Context {
+			return *h.trimmed, nil
+		}
+	}
+	if !s.previewContext || s.runningLine == "" {
+		return req, nil
+	}
+
+	shown := req
+	if cc.Privacy != nil {
+		// Show what the privacy rules let through
+		var err error
+		if shown, _, err = cc.Privacy.Check(req); err != nil {
+			return req, err
+		}
+	}
+	s.held = &heldRequest{line: s.runningLine, req: req, shown: shown}
+	Compose{Command: "aicontext", Text: previewText(shown), Preview: true}.apply(s, buf)
+	return req, errHeld
+}
+
+// previewText is the prompt of req, then its context after contextMarker
+func previewText(req ai.AIRequest) string {
+	text := req.Prompt
+	if context := strings.TrimRight(req.Context, "\n"); context != "" {
+		text += "\n" + contextMarker + "\n" + context
+	}
+	return text
+}
+
+// parsePreview splits the text of a preview into prompt and context at the
+// first contextMarker line
+func parsePreview(text string) (prompt, context string) {
+	lines := strings.Split(text, "\n")
+	if i := slices.Index(lines, contextMarker); i >= 0 {
+		return strings.Join(lines[:i], "\n"), strings.Join(lines[i+1:], "\n") + "\n"
+	}
+	return text, ""
+}
+
+// AIContextCommand implements :aicontext, which sends the request held
+// for preview with the prompt and context written after it, or shows the
+// preview again without them
+type AIContextCommand struct {
+	executor *CommandExecutor // Runs the command that made the request again
+}
+
+// NewAIContextCommand creates the :aicontext command for executor
+func NewAIContextCommand(executor *CommandExecutor) *AIContextCommand {
+	return &AIContextCommand{executor: executor}
+}
+
+func (c *AIContextCommand) Name() string {
+	return "aicontext"
+}
+
+func (c *AIContextCommand) Aliases() []string {
+	return nil
+}
+
+// textArgument makes the preview arrive as written, with its lines
+func (c *AIContextCommand) textArgument() {}
+
+func (c *AIContextCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	h := s.held
+	if h == nil {
+		return CommandResult{Success: false, Message: "No AI request to preview (:set aipreview holds them)"}
+	}
+	if len(args) == 0 {
+		h.trimmed = nil
+		return CommandResult{Success: true, Actions: []Action{Compose{Command: "aicontext", Text: previewText(h.shown), Preview: true}}}
+	}
+
+	prompt, context := parsePreview(args[0])
+	if strings.TrimSpace(prompt) == "" {
+		return CommandResult{Success: false, Message: "The request has no prompt left"}
+	}
+	trimmed := h.shown
+	trimmed.Prompt, trimmed.Context = prompt, context
+	h.trimmed = &trimmed
+
+	s.runningLine = h.line
+	result := c.executor.run(h.line, buf)
+	if s.held == h {
+		// The command didn't make the request again
+		s.held = nil
+	}
+	if result.Success && result.Message == "" {
+		result.Message = fmt.Sprintf("Sent about %d tokens", ai.EstimateTokens(prompt+context))
+	}
+	return result
+}
+
+func (c *AIContextCommand) Help() string {
+	return ":aicontext - Show the preview of the AI request held by :set aipreview again, to trim what it sends"
+}
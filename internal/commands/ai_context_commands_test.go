@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/privacy"
+)
+
+func TestAIContextPreview(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	var requests []ai.AIRequest
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		requests = append(requests, req)
+		return &ai.AIResponse{Content: "It is one", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+	policy, err := privacy.New([]string{`sk-[a-z]+`}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{`key := "sk-abc"`})
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager, Privacy: policy})
+	executor.State().SetPreviewContext(true)
+	if result := executor.Execute("aicontext", buf); result.Success {
+		t.Error("expected :aicontext to fail without a request held")
+	}
+
+	result := executor.Execute("ai what is key?", buf)
+	if !result.Success || len(requests) != 0 {
+		t.Fatalf(":ai with a preview gave %+v and sent %d requests", result, len(requests))
+	}
+	compose := executor.State().TakeCompose()
+	if compose == nil || !compose.Preview || compose.Command != "aicontext" {
+		t.Fatalf("preview opened %+v", compose)
+	}
+	prompt, context := parsePreview(compose.Text)
+	if prompt != "what is key?" || !strings.Contains(context, "Current line 1: key := \"[REDACTED]\"") {
+		t.Fatalf("preview shows %q", compose.Text)
+	}
+
+	// Sending the preview sends what is left of it
+	lines := strings.Split(compose.Text, "\n")
+	trimmed := strings.Join(append(lines[:2:2], lines[3:]...), "\n")
+	if result := executor.Execute("aicontext "+trimmed, buf); !result.Success || result.Message != "It is one" {
+		t.Fatalf(":aicontext = %+v", result)
+	}
+	if len(requests) != 1 || requests[0].Prompt != "what is key?" || strings.Contains(requests[0].Context, lines[2]) {
+		t.Fatalf("sent %+v, without %q", requests, lines[2])
+	}
+	if executor.State().held != nil || executor.State().ComposePending() {
+		t.Error("expected nothing held after sending")
+	}
+
+	// Without a preview requests go out at once
+	executor.State().SetPreviewContext(false)
+	executor.Execute("ai and now?", buf)
+	if len(requests) != 2 || requests[1].Prompt != "and now?" {
+		t.Errorf("expected the request sent at once, got %d", len(requests))
+	}
+}
+
+func TestParsePreview(t *testing.T) {
+	prompt, context := parsePreview("Why?\nReally\n" + contextMarker + "\nFile: a.go")
+	if prompt != "Why?\nReally" || context != "File: a.go\n" {
+		t.Errorf("parsePreview = %q, %q", prompt, context)
+	}
+	if prompt, context := parsePreview("Only a prompt"); prompt != "Only a prompt" || context != "" {
+		t.Errorf("parsePreview without a context = %q, %q", prompt, context)
+	}
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// aiEdit is a rewrite of lines Start..End proposed by :aiedit, shown as a
+// diff until it is applied or discarded
+type aiEdit struct {
+	buf      *buffer.Buffer
+	r        Range
+	original []string
+	after    int            // Number of lines after the rewritten ones
+	proposal *buffer.Buffer // The whole buffer with the rewrite applied
+}
+
+// AIEditCommand implements :aiedit, which asks the AI to rewrite lines
+// following an instruction and previews the result as a diff
+type AIEditCommand struct{}
+
+// NewAIEditCommand creates a new :aiedit command
+func NewAIEditCommand() *AIEditCommand {
+	return &AIEditCommand{}
+}
+
+func (c *AIEditCommand) Name() string {
+	return "aiedit"
+}
+
+func (c *AIEditCommand) Aliases() []string {
+	return []string{"aied"}
+}
+
+// routedRequest lets @provider/model before the instruction choose where
+// the request goes
+func (c *AIEditCommand) routedRequest() {}
+
+// textArgument makes the instruction arrive as typed
+func (c *AIEditCommand) textArgument() {}
+
+func (c *AIEditCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.ExecuteRange(cc, currentLine(buf), args, buf)
+}
+
+func (c *AIEditCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if cc.AI == nil {
+		return CommandResult{Success: false, Message: "AI manager not initialized"}
+	}
+	if len(args) == 0 {
+		// Without an instruction the composer opens to write one
+		command := fmt.Sprintf("%d,%daiedit", r.Start+1, r.End+1) + cc.routeArgument()
+		return CommandResult{Success: true, Actions: []Action{Compose{Command: command}}}
+	}
+	if s.diffOther != nil && (s.pendingEdit == nil || s.diffOther != s.pendingEdit.proposal) {
+		return CommandResult{Success: false, Message: "Leave diff mode with :diffoff first"}
+	}
+	instruction := strings.Trim(args[0], `"'`)
+	original := slices.Clone(buf.Lines()[r.Start : r.End+1])
+
+	language := buf.Filetype()
+	if language == "" {
+		language = "text"
+	}
+	req := ai.AIRequest{
+		Prompt: fmt.Sprintf("Rewrite this %s code to %s. Reply with only the rewritten code, without explanations or markdown fences.\n\n%s",
+			language, instruction, strings.Join(original, "\n")),
+		Context:  cc.State.projectContext(buf),
+		Language: buf.Filetype(),
+		Type:     ai.RequestEdit,
+	}
+
+	filename := buf.Filename()
+	return askAI(cc, req, buf, 60*time.Second, func(resp *ai.AIResponse) CommandResult {
+		if buf.Filename() != filename || r.End >= buf.LineCount() || !slices.Equal(buf.Lines()[r.Start:r.End+1], original) {
+			return CommandResult{Success: false, Message: "The lines changed while waiting for the AI edit"}
+		}
+		rewrite := strings.Split(stripCodeFence(resp.Content), "\n")
+
+		proposal := buffer.New()
+		proposal.ReplaceLines(0, 0, slices.Concat(buf.Lines()[:r.Start], rewrite, buf.Lines()[r.End+1:]))
+		s.pendingEdit = &aiEdit{buf: buf, r: r, original: original, after: buf.LineCount() - r.End - 1, proposal: proposal}
+		s.StartDiff(proposal, fmt.Sprintf("AI edit %q (:aiapply or :aidiscard)", instruction))
+
+		return CommandResult{
+			Success: true,
+			Message: fmt.Sprintf("%s proposes %d lines for %d; :aiapply replaces them", servedBy(resp), len(rewrite), len(original)),
+		}
+	})
+}
+
+func (c *AIEditCommand) Help() string {
+	return `:[range]aiedit [@provider/model] "instruction" - Ask the AI to rewrite the selection or current line and preview the result as a diff`
+}
+
+// AIApplyCommand implements :aiapply, which replaces the lines :aiedit
+// rewrote with the proposal, keeping the original lines in the unnamed and
+// numbered registers like a delete
+type AIApplyCommand struct{}
+
+// NewAIApplyCommand creates a new :aiapply command
+func NewAIApplyCommand() *AIApplyCommand {
+	return &AIApplyCommand{}
+}
+
+func (c *AIApplyCommand) Name() string {
+	return "aiapply"
+}
+
+func (c *AIApplyCommand) Aliases() []string {
+	return []string{"aia"}
+}
+
+func (c *AIApplyCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	edit := s.pendingEdit
+	if edit == nil || s.diffOther != edit.proposal {
+		s.pendingEdit = nil
+		return CommandResult{Success: false, Message: "No AI edit to apply"}
+	}
+	if edit.buf != buf || buf.LineCount() != edit.r.End+1+edit.after || !slices.Equal(buf.Lines()[edit.r.Start:edit.r.End+1], edit.original) {
+		return CommandResult{Success: false, Message: "The lines changed since the AI edit; run :aiedit again or :aidiscard"}
+	}
+
+	// The lines around the rewrite are those of the buffer at the time of
+	// :aiedit, though :diffput may have changed the rewrite itself
+	proposal := edit.proposal.Lines()
+	rewrite := proposal[edit.r.Start : len(proposal)-edit.after]
+	s.registerStore.Delete(0, strings.Join(edit.original, "\n"), true)
+	spliceLines(buf, edit.r.Start, len(edit.original), rewrite)
+	buf.SetCursor(buffer.Position{Line: edit.r.Start})
+	s.pendingEdit = nil
+	s.StartDiff(nil, "")
+	return CommandResult{Success: true, Message: "AI edit applied; the previous text is in register \"1"}
+}
+
+func (c *AIApplyCommand) Help() string {
+	return ":aiapply - Accept the edit :aiedit previews"
+}
+
+// AIDiscardCommand implements :aidiscard, which drops the edit :aiedit
+// previews
+type AIDiscardCommand struct{}
+
+// NewAIDiscardCommand creates a new :aidiscard command
+func NewAIDiscardCommand() *AIDiscardCommand {
+	return &AIDiscardCommand{}
+}
+
+func (c *AIDiscardCommand) Name() string {
+	return "aidiscard"
+}
+
+func (c *AIDiscardCommand) Aliases() []string {
+	return []string{"aid"}
+}
+
+func (c *AIDiscardCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if s.pendingEdit == nil {
+		return CommandResult{Success: false, Message: "No AI edit to discard"}
+	}
+	if s.diffOther == s.pendingEdit.proposal {
+		s.StartDiff(nil, "")
+	}
+	s.pendingEdit = nil
+	return CommandResult{Success: true, Message: "AI edit discarded"}
+}
+
+func (c *AIDiscardCommand) Help() string {
+	return ":aidiscard - Drop the edit :aiedit previews"
+}
+
+// stripCodeFence returns the code inside a markdown code fence around s,
+// which models add despite being asked not to, or s without trailing
+// newlines
+func stripCodeFence(s string) string {
+	s = strings.TrimRight(s, "\n")
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") || len(trimmed) < 6 {
+		return s
+	}
+	body := strings.TrimSuffix(trimmed, "```")
+	if _, rest, ok := strings.Cut(body, "\n"); ok {
+		// The opening line may name the language
+		return strings.TrimRight(rest, "\n")
+	}
+	return s
+}
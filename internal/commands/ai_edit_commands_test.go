@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/registers"
+)
+
+func TestAIEdit(t *testing.T) {
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	var prompt string
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		prompt = req.Prompt
+		return &ai.AIResponse{Content: "```go\nfor _, x := range xs {\n\tsum += x\n}\n```\n", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+	store := registers.NewStore()
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+	executor.State().SetRegisters(store)
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"sum := 0", "for i := 0; i < len(xs); i++ {", "\tsum += xs[i]", "}", "return sum"})
+
+	result := executor.Execute(`2,4aiedit "use range"`, buf)
+	if !result.Success {
+		t.Fatalf(":aiedit = %+v", result)
+	}
+	if !strings.Contains(prompt, "to use range.") || !strings.HasSuffix(prompt, "\tsum += xs[i]\n}") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	other, title := executor.State().DiffBuffer()
+	if other == nil || !strings.HasPrefix(title, `AI edit "use range"`) {
+		t.Fatalf("expected a diff preview, got %q", title)
+	}
+	if other.Lines()[1] != "for _, x := range xs {" || buf.Lines()[1] != "for i := 0; i < len(xs); i++ {" {
+		t.Error("expected the proposal in the preview and the buffer unchanged")
+	}
+
+	if result := executor.Execute("aiapply", buf); !result.Success {
+		t.Fatalf(":aiapply = %+v", result)
+	}
+	want := []string{"sum := 0", "for _, x := range xs {", "\tsum += x", "}", "return sum"}
+	if !slices.Equal(buf.Lines(), want) {
+		t.Errorf("buffer after :aiapply = %q, want %q", buf.Lines(), want)
+	}
+	if reg, _ := store.Get('1'); reg.Text != "for i := 0; i < len(xs); i++ {\n\tsum += xs[i]\n}" {
+		t.Errorf("register 1 = %q", reg.Text)
+	}
+	if other, _ := executor.State().DiffBuffer(); other != nil {
+		t.Error("expected :aiapply to close the preview")
+	}
+
+	// A preview of lines edited since isn't applied
+	executor.Execute(`1aiedit "rename"`, buf)
+	buf.ReplaceLines(0, 0, []string{"total := 0"})
+	if result := executor.Execute("aiapply", buf); result.Success {
+		t.Error("expected :aiapply to refuse changed lines")
+	}
+	if result := executor.Execute("aidiscard", buf); !result.Success {
+		t.Errorf(":aidiscard = %+v", result)
+	}
+	if result := executor.Execute("aiapply", buf); result.Success {
+		t.Error("expected nothing to apply after :aidiscard")
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := map[string]string{
+		"x := 1\n":                         "x := 1",
+		"```\nx := 1\n```":                 "x := 1",
+		"```go\n\tx := 1\n\ty := 2\n```\n": "\tx := 1\n\ty := 2",
+		"```":                              "```",
+	}
+	for in, want := range tests {
+		if got := stripCodeFence(in); got != want {
+			t.Errorf("stripCodeFence(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/conversation"
+)
+
+// historySettings returns the :ai history settings
+func historySettings(cc *CommandContext) config.AIHistoryConfig {
+	return cc.currentConfig().AI.History
+}
+
+// historyStore returns the store of the :ai threads of the buffer's
+// project, or nil when they aren't saved
+func (s *State) historyStore(buf *buffer.Buffer, settings config.AIHistoryConfig) *conversation.Store {
+	if !settings.Enabled {
+		return nil
+	}
+	root := s.bufferProject(buf).Root
+	if settings.InProject {
+		return conversation.Open(filepath.Join(root, ".aied", "ai-history"))
+	}
+	dir := dataDir()
+	if dir == "" {
+		return nil
+	}
+	// Projects with the same name in different places get their own threads
+	sum := sha256.Sum256([]byte(root))
+	return conversation.Open(filepath.Join(dir, "aied", "ai-history", filepath.Base(root)+"-"+hex.EncodeToString(sum[:4])))
+}
+
+// dataDir returns $XDG_DATA_HOME, or ~/.local/share when it isn't set
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share")
+}
+
+// currentThread returns the thread :ai adds to for the buffer's project,
+// restoring the project's latest saved thread the first time
+func (s *State) currentThread(buf *buffer.Buffer, store *conversation.Store) *conversation.Thread {
+	root := s.bufferProject(buf).Root
+	if s.chatThread != nil && s.chatRoot == root {
+		return s.chatThread
+	}
+	s.chatRoot, s.chatThread = root, nil
+	if store != nil {
+		if threads, err := store.List(); err == nil && len(threads) > 0 {
+			s.chatThread = threads[0]
+		}
+	}
+	if s.chatThread == nil {
+		s.chatThread = conversation.NewThread(time.Now())
+	}
+	return s.chatThread
+}
+
+// AIHistoryCommand implements :ai-history, listing the saved :ai threads
+// of the project and resuming one
+type AIHistoryCommand struct{}
+
+func NewAIHistoryCommand() *AIHistoryCommand {
+	return &AIHistoryCommand{}
+}
+
+func (c *AIHistoryCommand) Name() string {
+	return "ai-history"
+}
+
+func (c *AIHistoryCommand) Aliases() []string {
+	return []string{"aih"}
+}
+
+func (c *AIHistoryCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s, settings := cc.State, historySettings(cc)
+	store := s.historyStore(buf, settings)
+	if store == nil {
+		return CommandResult{Success: false, Message: "AI history is off (ai.history.enabled)"}
+	}
+	threads, err := store.List()
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error reading AI history: %s", err.Error())}
+	}
+	current := s.currentThread(buf, store)
+
+	switch {
+	case len(args) == 0:
+		if len(threads) == 0 {
+			return CommandResult{Success: true, Message: "No saved AI threads for this project"}
+		}
+		var b strings.Builder
+		for i, t := range threads {
+			marker := " "
+			if t.ID == current.ID {
+				marker = "*"
+			}
+			fmt.Fprintf(&b, "%s%d. %s  %s (%d messages)\n", marker, i+1, t.Updated.Format("2006-01-02 15:04"), t.Title, len(t.Messages))
+		}
+		s.explanation = &aiExplanation{title: "[AI History] :ai-history N resumes, :ai-history new starts a thread", text: b.String()}
+		return CommandResult{Success: true}
+
+	case args[0] == "new":
+		s.chatThread = conversation.NewThread(time.Now())
+		return CommandResult{Success: true, Message: "Started a new AI thread"}
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(threads) {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Usage: :ai-history [N|new], with N from 1 to %d", len(threads))}
+	}
+	s.chatThread = threads[n-1]
+	s.ShowPanel("[AI Thread] "+s.chatThread.Title, s.chatThread.Transcript(0))
+	return CommandResult{Success: true, Message: fmt.Sprintf("Resumed %q; :ai continues it", s.chatThread.Title)}
+}
+
+func (c *AIHistoryCommand) Help() string {
+	return ":ai-history [N|new] - List the project's saved :ai threads, resume thread N or start a new one"
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestAIHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		return &ai.AIResponse{Content: "answer to " + req.Prompt, Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	buf := buffer.New()
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+	if result := executor.Execute("ai what is a slice", buf); !result.Success {
+		t.Fatalf(":ai = %+v", result)
+	}
+	if result := executor.Execute("ai and a map", buf); !result.Success {
+		t.Fatalf(":ai = %+v", result)
+	}
+	calls := provider.GetChatCalls()
+	if !strings.Contains(calls[1].Context, "User: what is a slice\n\nAssistant: answer to what is a slice") {
+		t.Errorf("expected the thread in the context of the next question, got %q", calls[1].Context)
+	}
+
+	// Reopening the project restores the thread
+	executor.State().chatThread, executor.State().chatRoot = nil, ""
+	executor.Execute("ai and a channel", buf)
+	if calls := provider.GetChatCalls(); !strings.Contains(calls[2].Context, "User: and a map") {
+		t.Errorf("expected the saved thread restored, got %q", calls[2].Context)
+	}
+
+	if result := executor.Execute("ai-history new", buf); !result.Success {
+		t.Fatalf(":ai-history new = %+v", result)
+	}
+	executor.Execute("ai unrelated", buf)
+	if calls := provider.GetChatCalls(); strings.Contains(calls[3].Context, "Conversation so far") {
+		t.Errorf("expected a new thread to start empty, got %q", calls[3].Context)
+	}
+
+	executor.Execute("ai-history", buf)
+	_, lines, open := executor.State().ExplanationPanel(80)
+	if !open || len(lines) != 2 || !strings.HasPrefix(lines[0], "*1.") || !strings.Contains(lines[1], "what is a slice (6 messages)") {
+		t.Errorf("history panel = %q", lines)
+	}
+
+	if result := executor.Execute("ai-history 2", buf); !result.Success || executor.State().chatThread.Title != "what is a slice" {
+		t.Errorf(":ai-history 2 = %+v, thread %q", result, executor.State().chatThread.Title)
+	}
+	if result := executor.Execute("ai-history 3", buf); result.Success {
+		t.Error("expected an error for a thread that doesn't exist")
+	}
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// pickProvider lists the available AI providers in the palette to make one
+// active, with the models of those that list theirs as provider/model
+func pickProvider(cc *CommandContext) CommandResult {
+	providers := cc.AI.ListAvailableProviders()
+	if len(providers) == 0 {
+		return CommandResult{Success: true, Message: "No AI providers available", SwitchMode: true}
+	}
+
+	var active ai.ProviderType
+	if provider := cc.AI.GetActiveProvider(); provider != nil {
+		active = provider.Name()
+	}
+	var names, items []string
+	for pType, provider := range providers {
+		names = append(names, string(pType))
+		if chooser, ok := provider.(ai.ModelChooser); ok {
+			for _, model := range chooser.Models() {
+				names = append(names, string(pType)+"/"+model)
+			}
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if name == string(active) {
+			name += " (active)"
+		}
+		items = append(items, name)
+	}
+	return CommandResult{
+		Success:    true,
+		SwitchMode: true,
+		Actions: []Action{Pick{Title: "AI providers", Items: items, Chosen: func(index int) CommandResult {
+			return setProvider(cc, names[index])
+		}}},
+	}
+}
+
+// setProvider makes the provider of name active, and when name is
+// provider/model, has it use the model
+func setProvider(cc *CommandContext, name string) CommandResult {
+	providerType, model, _ := strings.Cut(name, "/")
+	if model != "" {
+		provider, ok := cc.AI.GetProvider(ai.ProviderType(providerType))
+		if !ok {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Failed to set provider: provider %s not registered", providerType), SwitchMode: true}
+		}
+		if _, ok := provider.(ai.ModelChooser); !ok {
+			return CommandResult{Success: false, Message: fmt.Sprintf("AI provider %s takes its model from the config (@%s/%s sends one request there)", providerType, providerType, model), SwitchMode: true}
+		}
+	}
+
+	if err := cc.AI.SetActiveProvider(ai.ProviderType(providerType)); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Failed to set provider: %s", err.Error()), SwitchMode: true}
+	}
+	if model == "" {
+		return CommandResult{Success: true, Message: fmt.Sprintf("Active provider set to: %s", providerType), SwitchMode: true}
+	}
+	return chooseModel(cc, ai.ProviderType(providerType), model)
+}
+
+// chooseModel has the provider use model for the requests that name none,
+// when it lists models and model is one of them
+func chooseModel(cc *CommandContext, providerType ai.ProviderType, model string) CommandResult {
+	provider, _ := cc.AI.GetProvider(providerType)
+	chooser, ok := provider.(ai.ModelChooser)
+	if !ok {
+		return CommandResult{Success: false, Message: fmt.Sprintf("AI provider %s doesn't list models", providerType), SwitchMode: true}
+	}
+	// The list is left unchecked while it isn't known
+	if models := chooser.Models(); len(models) > 0 && !slices.Contains(models, model) && !slices.Contains(models, model+":latest") {
+		return CommandResult{Success: false, Message: fmt.Sprintf("AI provider %s has no model %s", providerType, model), SwitchMode: true}
+	}
+	chooser.SetModel(model)
+	return CommandResult{Success: true, Message: fmt.Sprintf("%s model set to: %s", providerType, model), SwitchMode: true}
+}
+
+// OllamaCommand implements :ollama, which lists the models pulled into the
+// Ollama server in the palette to use one, or uses the model named
+type OllamaCommand struct{}
+
+// NewOllamaCommand creates the :ollama command
+func NewOllamaCommand() *OllamaCommand {
+	return &OllamaCommand{}
+}
+
+func (c *OllamaCommand) Name() string {
+	return "ollama"
+}
+
+func (c *OllamaCommand) Aliases() []string {
+	return nil
+}
+
+func (c *OllamaCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
+		return CommandResult{Success: false, Message: "AI manager not initialized", SwitchMode: true}
+	}
+	provider, ok := cc.AI.GetProvider(ai.ProviderOllama)
+	if !ok {
+		return CommandResult{Success: false, Message: "AI provider ollama isn't configured (:aip lists them)", SwitchMode: true}
+	}
+	if len(args) > 0 {
+		return chooseModel(cc, ai.ProviderOllama, args[0])
+	}
+
+	chooser, ok := provider.(ai.ModelChooser)
+	if !ok {
+		return CommandResult{Success: false, Message: "AI provider ollama doesn't list models", SwitchMode: true}
+	}
+	models := chooser.Models()
+	if len(models) == 0 {
+		return CommandResult{Success: false, Message: "Ollama lists no models (not running, or none pulled yet)", SwitchMode: true}
+	}
+	return CommandResult{
+		Success:    true,
+		SwitchMode: true,
+		Actions: []Action{Pick{Title: "Ollama models", Items: models, Chosen: func(index int) CommandResult {
+			return chooseModel(cc, ai.ProviderOllama, models[index])
+		}}},
+	}
+}
+
+func (c *OllamaCommand) Help() string {
+	return ":ollama [model] - Pick the model Ollama answers with from those pulled into it"
+}
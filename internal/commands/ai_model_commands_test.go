@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestOllamaModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ai.OllamaTagsResponse{Models: []ai.OllamaModel{{Name: "llama3:latest"}, {Name: "codellama:7b"}}})
+	}))
+	defer server.Close()
+
+	ollama := ai.NewOllamaProvider()
+	ollama.Configure(ai.ProviderConfig{BaseURL: server.URL, Model: "llama3"})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(ollama)
+	manager.RegisterProvider(ai.NewMockProvider(ai.ProviderAnthropic))
+	cc := &CommandContext{AI: manager}
+	buf := buffer.New()
+
+	// :ollama picks from the models pulled
+	result := NewOllamaCommand().Execute(cc, nil, buf)
+	pick, ok := firstPick(result)
+	if !ok || len(pick.Items) != 2 || pick.Items[1] != "codellama:7b" {
+		t.Fatalf(":ollama gave %+v", result)
+	}
+	if result := pick.Chosen(1); !result.Success || result.Message != "ollama model set to: codellama:7b" {
+		t.Errorf("choosing a model gave %q", result.Message)
+	}
+	if result := NewOllamaCommand().Execute(cc, []string{"mistral"}, buf); result.Success {
+		t.Errorf(":ollama took a model the server doesn't have: %q", result.Message)
+	}
+	if result := NewOllamaCommand().Execute(cc, []string{"llama3"}, buf); !result.Success {
+		t.Errorf(":ollama llama3 gave %q", result.Message)
+	}
+
+	// :aip lists the providers with the models of Ollama
+	result = NewAIProviderCommand().Execute(cc, nil, buf)
+	pick, ok = firstPick(result)
+	want := []string{"anthropic", "ollama (active)", "ollama/codellama:7b", "ollama/llama3:latest"}
+	if !ok || len(pick.Items) != len(want) {
+		t.Fatalf(":aip gave %+v", result)
+	}
+	for i := range want {
+		if pick.Items[i] != want[i] {
+			t.Errorf("item %d = %q, want %q", i, pick.Items[i], want[i])
+		}
+	}
+	if result := pick.Chosen(0); !result.Success || manager.GetActiveProvider().Name() != ai.ProviderAnthropic {
+		t.Errorf("choosing anthropic gave %q", result.Message)
+	}
+	if result := NewAIProviderCommand().Execute(cc, []string{"ollama/codellama:7b"}, buf); !result.Success || manager.GetActiveProvider().Name() != ai.ProviderOllama {
+		t.Errorf(":aip ollama/codellama:7b gave %q", result.Message)
+	}
+	if result := NewAIProviderCommand().Execute(cc, []string{"anthropic/claude"}, buf); result.Success {
+		t.Errorf(":aip took a model for a provider that doesn't list them: %q", result.Message)
+	}
+}
+
+// firstPick returns the list a command result picks from
+func firstPick(result CommandResult) (Pick, bool) {
+	for _, action := range result.Actions {
+		if pick, ok := action.(Pick); ok {
+			return pick, true
+		}
+	}
+	return Pick{}, false
+}
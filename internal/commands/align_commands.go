@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// alignDelimiters are the delimiters :align lines up
+const alignDelimiters = "=:|,"
+
+// numberPattern matches a number as it is written in tables and literals,
+// with digit separators like 1,000, 1_000 or 1'000
+var numberPattern = regexp.MustCompile(`^[-+]?[$€£]?\d+(?:[,_']\d+)*(?:\.\d+)?%?$`)
+
+// isNumber reports whether cell holds a number, which :align lines up on
+// the right
+func isNumber(cell string) bool {
+	return numberPattern.MatchString(cell)
+}
+
+// isTableRule reports whether cell is the dashes under a table header,
+// with the colons that set its alignment in markdown
+func isTableRule(cell string) bool {
+	dashes := strings.TrimSuffix(strings.TrimPrefix(cell, ":"), ":")
+	return dashes != "" && strings.Trim(dashes, "-") == ""
+}
+
+// widenRule lengthens the table rule cell to width with more dashes
+func widenRule(cell string, width int) string {
+	if n := width - len(cell); n > 0 {
+		i := strings.LastIndexByte(cell, '-') + 1
+		cell = cell[:i] + strings.Repeat("-", n) + cell[i:]
+	}
+	return cell
+}
+
+// alignedLine is a line split at the delimiters :align lines up: the
+// cells around them, the first with the line's indent, and the delimiter
+// text between each pair, like := for =
+type alignedLine struct {
+	cells  []string
+	delims []string
+}
+
+// splitAligned splits line at delim outside quotes: at the first = or :,
+// but at every | and comma. An = is taken with the operator it ends, like
+// := or +=, and comparisons like == and <= are passed over. ok is false
+// when line has no delim.
+func splitAligned(line string, delim byte) (l alignedLine, ok bool) {
+	var quote byte
+	start := 0
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote != '`' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		case delim != '|' && (ch == '"' || ch == '\'' || ch == '`'):
+			// Apostrophes in table text aren't quotes
+			quote = ch
+			continue
+		case ch != delim:
+			continue
+		case delim == '|' && i > 0 && line[i-1] == '\\':
+			// A \| in a markdown table cell
+			continue
+		}
+
+		from, to := i, i+1
+		switch delim {
+		case '=':
+			for from > start && strings.IndexByte(":+-*/%&|^<>!.?", line[from-1]) >= 0 {
+				from--
+			}
+			for to < len(line) && (line[to] == '=' || line[to] == '>') {
+				to++
+			}
+			switch line[from:to] {
+			case "==", "===", "!=", "!==", "<=", ">=", "=>":
+				i = to - 1
+				continue
+			}
+		case ':':
+			// Not :: or :=
+			if to < len(line) && (line[to] == ':' || line[to] == '=') || from > 0 && line[from-1] == ':' {
+				i = to
+				continue
+			}
+		}
+
+		if len(l.cells) == 0 {
+			// The indent stays, also alone before a table's first |
+			first := strings.TrimRight(line[:from], " \t")
+			if first == "" {
+				first = line[:from]
+			}
+			l.cells = append(l.cells, first)
+		} else {
+			l.cells = append(l.cells, strings.TrimSpace(line[start:from]))
+		}
+		l.delims = append(l.delims, line[from:to])
+		start, i = to, to-1
+		if delim == '=' || delim == ':' {
+			break
+		}
+	}
+	if len(l.cells) == 0 {
+		return l, false
+	}
+	l.cells = append(l.cells, strings.TrimSpace(line[start:]))
+	return l, true
+}
+
+// alignLines lines up the delimiters delim in lines, leaving lines without
+// one as they are. The delimiter follows the padding for = and |, and
+// comes before it, next to its cell, for : and commas. Numbers are lined
+// up on the right.
+func alignLines(lines []string, delim byte) (aligned []string, count int) {
+	split := make([]alignedLine, len(lines))
+	has := make([]bool, len(lines))
+	var widths []int
+	for i, line := range lines {
+		if split[i], has[i] = splitAligned(line, delim); !has[i] {
+			continue
+		}
+		cells := split[i].cells
+		for col, cell := range cells {
+			if col == len(widths) {
+				widths = append(widths, 0)
+			}
+			// A last cell needs no padding unless a number
+			if col < len(cells)-1 || col > 0 && isNumber(cell) {
+				widths[col] = max(widths[col], utf8.RuneCountInString(cell))
+			}
+		}
+	}
+
+	after := delim == ':' || delim == ','
+	aligned = make([]string, len(lines))
+	for i, line := range lines {
+		if !has[i] {
+			aligned[i] = line
+			continue
+		}
+		count++
+		l := split[i]
+		var sb strings.Builder
+		for col, cell := range l.cells {
+			pad := strings.Repeat(" ", max(widths[col]-utf8.RuneCountInString(cell), 0))
+			switch {
+			case delim == '|' && isTableRule(cell):
+				cell, pad = widenRule(cell, widths[col]), ""
+			case col > 0 && isNumber(cell):
+				cell, pad = pad+cell, ""
+			}
+			if col == len(l.cells)-1 {
+				sb.WriteString(cell)
+				break
+			}
+			switch {
+			case after:
+				sb.WriteString(cell + l.delims[col] + pad + " ")
+			case col == 0 && strings.TrimSpace(cell) == "":
+				// A table row starting with |
+				sb.WriteString(cell + l.delims[col] + " ")
+			default:
+				sb.WriteString(cell + pad + " " + l.delims[col] + " ")
+			}
+		}
+		aligned[i] = strings.TrimRight(sb.String(), " ")
+	}
+	return aligned, count
+}
+
+// AlignCommand implements :align, which lines up the =, :, | or commas of
+// a range of lines, for tidying tables and struct literals
+type AlignCommand struct{}
+
+// NewAlignCommand creates the :align command
+func NewAlignCommand() *AlignCommand {
+	return &AlignCommand{}
+}
+
+func (c *AlignCommand) Name() string {
+	return "align"
+}
+
+func (c *AlignCommand) Aliases() []string {
+	return nil
+}
+
+// Execute aligns the lines around the cursor that have the delimiter
+func (c *AlignCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	delim, ok := alignDelimiter(args)
+	if !ok {
+		return alignUsage()
+	}
+	r := currentLine(buf)
+	has := func(n int) bool {
+		line, _ := buf.Line(n)
+		_, ok := splitAligned(line, delim)
+		return ok
+	}
+	if !has(r.Start) {
+		return CommandResult{Success: false, Message: "No " + string(delim) + " to align"}
+	}
+	for r.Start > 0 && has(r.Start-1) {
+		r.Start--
+	}
+	for r.End < buf.LineCount()-1 && has(r.End+1) {
+		r.End++
+	}
+	return c.ExecuteRange(cc, r, args, buf)
+}
+
+func (c *AlignCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	delim, ok := alignDelimiter(args)
+	if !ok {
+		return alignUsage()
+	}
+	lines, count := alignLines(buf.Lines()[r.Start:r.End+1], delim)
+	if count == 0 {
+		return CommandResult{Success: false, Message: "No " + string(delim) + " to align"}
+	}
+	buf.ReplaceLines(r.Start, r.End, lines)
+	buf.SetCursor(buffer.Position{Line: r.Start})
+	return CommandResult{Success: true, Message: linesMessage(count, "aligned")}
+}
+
+func (c *AlignCommand) Help() string {
+	return ":[range]align {delim} - Line up the =, :, | or commas of the lines in range, or of the lines around the cursor that have them"
+}
+
+// alignDelimiter returns the delimiter args name
+func alignDelimiter(args []string) (byte, bool) {
+	if len(args) != 1 || len(args[0]) != 1 || !strings.Contains(alignDelimiters, args[0]) {
+		return 0, false
+	}
+	return args[0][0], true
+}
+
+func alignUsage() CommandResult {
+	return CommandResult{Success: false, Message: "Usage: :[range]align {= | : | , | |}"}
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestAlignLines(t *testing.T) {
+	tests := []struct {
+		delim       byte
+		lines, want []string
+	}{
+		{'=', []string{"\tx := 1", "\tlonger = 200", "\tif a == b {", "\ty += 3_000"},
+			[]string{"\tx      :=     1", "\tlonger =   200", "\tif a == b {", "\ty      += 3_000"}},
+		{':', []string{`  Name: "a: b",`, "  LongName: b,", "  pkg::x"},
+			[]string{`  Name:     "a: b",`, "  LongName: b,", "  pkg::x"}},
+		{',', []string{"{1, 200, 3},", `{1000, 2, "a,b"},`},
+			[]string{"{1,    200, 3},", `{1000,   2, "a,b"},`}},
+		{'|', []string{"| a | bbb |", "|---|:-:|", "| long cell | 1,000 |", "| x | it's 2 |"},
+			[]string{"| a         | bbb    |", "| --------- | :----: |", "| long cell |  1,000 |", "| x         | it's 2 |"}},
+	}
+	for _, tt := range tests {
+		got, _ := alignLines(tt.lines, tt.delim)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("align on %c:\ngot  %q\nwant %q", tt.delim, got, tt.want)
+		}
+	}
+}
+
+func TestAlignCommand(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a = 1", "bcd = 2", "", "e = 3", "fg = 4"})
+	executor := NewCommandExecutor()
+
+	// Without a range the lines around the cursor with the delimiter
+	buf.SetCursor(buffer.Position{Line: 1})
+	if result := executor.Execute("align =", buf); !result.Success {
+		t.Fatalf(":align = failed: %s", result.Message)
+	}
+	want := []string{"a   = 1", "bcd = 2", "", "e = 3", "fg = 4"}
+	if got := buf.Lines(); !slices.Equal(got, want) {
+		t.Errorf(":align = gave %q, want %q", got, want)
+	}
+
+	if result := executor.Execute("%align =", buf); result.Message != "4 lines aligned" {
+		t.Errorf(":%%align = reported %q", result.Message)
+	}
+	if got, _ := buf.Line(3); got != "e   = 3" {
+		t.Errorf(":%%align = left %q", got)
+	}
+
+	for _, line := range []string{"align", "align ;", "3align ="} {
+		if result := executor.Execute(line, buf); result.Success {
+			t.Errorf(":%s succeeded", line)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dshills/aied/internal/activity"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/quickfix"
+	"github.com/dshills/aied/internal/runner"
+)
+
+// SetRedrawFunc sets the function background jobs call when they have new output
+func (s *State) SetRedrawFunc(fn func()) {
+	s.redrawFunc = fn
+}
+
+// BuildCommand runs the configured build (:make) or test (:test) command
+type BuildCommand struct {
+	test bool
+}
+
+// NewMakeCommand creates the :make command
+func NewMakeCommand() *BuildCommand {
+	return &BuildCommand{}
+}
+
+// NewTestCommand creates the :test command
+func NewTestCommand() *BuildCommand {
+	return &BuildCommand{test: true}
+}
+
+func (b *BuildCommand) Name() string {
+	if b.test {
+		return "test"
+	}
+	return "make"
+}
+
+func (b *BuildCommand) Aliases() []string {
+	if b.test {
+		return []string{}
+	}
+	return []string{"mak"}
+}
+
+func (b *BuildCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if s.buildRunning() {
+		return CommandResult{Success: false, Message: "A build is already running: " + s.buildJob.Command}
+	}
+
+	cfg := cc.currentConfig()
+	filetype := buf.Filetype()
+	build := buildConfigFor(cfg.Build, filetype)
+
+	command := build.Make
+	if b.test {
+		command = build.Test
+	}
+	if command == "" {
+		return CommandResult{Success: false, Message: fmt.Sprintf("No %s command configured for %s", b.Name(), filetype)}
+	}
+	if len(args) > 0 {
+		command += " " + strings.Join(args, " ")
+	}
+
+	formats, err := quickfix.CompileErrorFormats(build.ErrorFormat)
+	if err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+
+	if err := s.startBuild(":"+b.Name()+" "+command, command, formats); err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: "Running: " + command}
+}
+
+func (b *BuildCommand) Help() string {
+	if b.test {
+		return ":test [args] - Run the test command for this filetype and list failures in the quickfix list"
+	}
+	return ":make [args] - Run the build command for this filetype and list errors in the quickfix list"
+}
+
+// buildRunning reports whether a build is still running
+func (s *State) buildRunning() bool {
+	return s.buildJob != nil && !s.buildJob.Done()
+}
+
+// startBuild runs command in the background, showing its output in the
+// build window until it finishes and FinishBuild parses it into the
+// quickfix list with formats
+func (s *State) startBuild(title, command string, formats []*quickfix.ErrorFormat) error {
+	job, err := runner.Start(command, "", func() {
+		if s.redrawFunc != nil {
+			s.redrawFunc()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %v", command, err)
+	}
+	done := activity.Start(strings.TrimPrefix(strings.Fields(title)[0], ":"))
+	go func() {
+		job.Wait()
+		done()
+	}()
+
+	s.buildJob = job
+	s.buildTitle = title
+	s.buildFormats = formats
+	s.buildCollected = false
+	return nil
+}
+
+// buildConfigFor returns the build settings for filetype, filling unset
+// fields from the "default" entry
+func buildConfigFor(configs map[string]config.BuildConfig, filetype string) config.BuildConfig {
+	build := configs[filetype]
+	fallback := configs["default"]
+	if build.Make == "" {
+		build.Make = fallback.Make
+	}
+	if build.Test == "" {
+		build.Test = fallback.Test
+	}
+	if len(build.ErrorFormat) == 0 {
+		build.ErrorFormat = fallback.ErrorFormat
+	}
+	return build
+}
+
+// RunningBuild returns the title and output so far of a running build
+func (s *State) RunningBuild() (string, []string, bool) {
+	if s.buildJob == nil || s.buildCollected {
+		return "", nil, false
+	}
+	return s.buildTitle, s.buildJob.Output(), true
+}
+
+// FinishBuild moves the output of a finished build into the quickfix list,
+// opening the list window and jumping to the first error. It returns false
+// when no build has finished since the last call.
+func (s *State) FinishBuild(buf *buffer.Buffer) bool {
+	if s.buildJob == nil || s.buildCollected || !s.buildJob.Done() {
+		return false
+	}
+	s.buildCollected = true
+
+	items := quickfix.ParseOutput(s.buildJob.Output(), s.buildFormats)
+	status := "success"
+	if code, err := s.buildJob.Result(); err != nil {
+		status = err.Error()
+	} else if code != 0 {
+		status = fmt.Sprintf("exit %d", code)
+	}
+
+	errors := 0
+	for _, item := range items {
+		if !item.NoLocation {
+			errors++
+		}
+	}
+	s.quickfixLists.Set(fmt.Sprintf("%s (%s, %d errors)", s.buildTitle, status, errors), items)
+	s.quickfixLists.SetOpen(true)
+	if errors > 0 {
+		if item, err := s.quickfixLists.Select(0); err == nil {
+			s.jumpToItem(item, buf)
+		}
+	}
+	return true
+}
+
+// CancelBuild stops a running build
+func (s *State) CancelBuild() {
+	if s.buildJob != nil && !s.buildJob.Done() {
+		s.buildJob.Cancel()
+	}
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/quickfix"
+	"github.com/dshills/aied/internal/runner"
+)
+
+func TestFinishBuild(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	os.WriteFile(source, []byte("package main\n\nfunc main() {\n"), 0644)
+
+	job, err := runner.Start("echo '# main'; echo '"+source+":3:14: missing }'; exit 1", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewState()
+	s.buildJob = job
+	s.buildTitle = ":make test"
+	s.buildFormats, _ = quickfix.CompileErrorFormats(nil)
+	s.buildCollected = false
+
+	if _, _, running := s.RunningBuild(); !running {
+		t.Error("expected build to be reported as running until collected")
+	}
+	job.Wait()
+
+	buf := buffer.New()
+	if !s.FinishBuild(buf) {
+		t.Fatal("expected finished build to be collected")
+	}
+	if s.FinishBuild(buf) {
+		t.Error("expected build to be collected only once")
+	}
+
+	list := s.quickfixLists.Current()
+	if list == nil || len(list.Items) != 2 || list.Title != ":make test (exit 1, 1 errors)" {
+		t.Fatalf("unexpected quickfix list %+v", list)
+	}
+	if buf.Filename() != source || buf.Cursor() != (buffer.Position{Line: 2, Col: 13}) {
+		t.Errorf("expected jump to error, got %q at %v", buf.Filename(), buf.Cursor())
+	}
+}
+
+func TestBuildConfigFor(t *testing.T) {
+	configs := map[string]config.BuildConfig{
+		"default": {Make: "make", Test: "make test", ErrorFormat: []string{"%f:%l: %m"}},
+		"python":  {Test: "pytest"},
+	}
+	build := buildConfigFor(configs, "python")
+	if build.Make != "make" || build.Test != "pytest" || len(build.ErrorFormat) != 1 {
+		t.Errorf("expected defaults to fill unset fields, got %+v", build)
+	}
+}
@@ -3,16 +3,42 @@ package commands
 import (
 	"fmt"
 	"strings"
+	"unicode"
 
+	"github.com/dshills/aied/internal/ai"
 	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/privacy"
+	"github.com/dshills/aied/internal/recent"
 )
 
 // CommandResult represents the result of executing a command
 type CommandResult struct {
-	Success    bool   // Whether the command executed successfully
-	Message    string // Success or error message
-	ExitEditor bool   // Whether to exit the editor
-	SwitchMode bool   // Whether to switch back to Normal mode
+	Success    bool     // Whether the command executed successfully
+	Message    string   // Success or error message
+	ExitEditor bool     // Whether to exit the editor
+	SwitchMode bool     // Whether to switch back to Normal mode
+	Actions    []Action // Done by the executor after the command runs
+}
+
+// CommandContext is what commands work with besides the buffer they run
+// on. The editor fills it in; the services it runs without are nil.
+type CommandContext struct {
+	Buffers func() []*buffer.Buffer // The open buffers, or nil
+	AI      *ai.AIManager
+	LSP     *lsp.Manager
+	Config  *config.Service // The configuration the editor runs with
+	Privacy *privacy.Policy // Keeps private files from the agent
+	Recent  *recent.Files   // The files opened lately, for :oldfiles
+	Message func(string)    // Shows a message on the status line, or nil
+	Route   *ai.Route       // Provider and model named before an AI command's arguments, or nil
+	State   *State          // What the commands keep between command lines, the executor's
+
+	// Defer runs finish on the editor's main loop once the work a command
+	// left in the background is done, showing its result like a command's.
+	// Without it, as in --ex runs, commands wait for their work instead.
+	Defer func(finish func() CommandResult)
 }
 
 // Command represents a VIM ex command
@@ -24,7 +50,7 @@ type Command interface {
 	Aliases() []string
 	
 	// Execute runs the command with the given arguments
-	Execute(args []string, buf *buffer.Buffer) CommandResult
+	Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult
 	
 	// Help returns help text for the command
 	Help() string
@@ -46,26 +72,92 @@ func NewCommandRegistry() *CommandRegistry {
 	registry.RegisterCommand(NewQuitCommand())
 	registry.RegisterCommand(NewForceQuitCommand())
 	registry.RegisterCommand(NewWriteQuitCommand())
+	registry.RegisterCommand(NewSaveAsCommand())
+	for _, cmd := range NewAllCommands() {
+		registry.RegisterCommand(cmd)
+	}
+	registry.RegisterCommand(NewSudoWriteCommand())
 	registry.RegisterCommand(NewEditCommand())
 	registry.RegisterCommand(NewNewCommand())
+	registry.RegisterCommand(NewRenameFileCommand())
+	registry.RegisterCommand(NewCdCommand())
+	registry.RegisterCommand(NewLcdCommand())
+	registry.RegisterCommand(NewPwdCommand())
+	registry.RegisterCommand(NewOldfilesCommand())
+	registry.RegisterCommand(NewStatsCommand())
+	registry.RegisterCommand(NewExprCommand())
+	
+	// Register editing commands
+	registry.RegisterCommand(NewStripWhitespaceCommand())
+	registry.RegisterCommand(NewSetCommand())
+	registry.RegisterCommand(NewSubstituteCommand())
+	registry.RegisterCommand(NewAlignCommand())
+	registry.RegisterCommand(NewTableCommand())
+	registry.RegisterCommand(NewFindCommand())
+	registry.RegisterCommand(NewOpenCommand())
+	registry.RegisterCommand(NewScratchCommand())
+	registry.RegisterCommand(NewNohlsearchCommand())
+	for _, cmd := range NewLineCommands() {
+		registry.RegisterCommand(cmd)
+	}
+	for _, cmd := range NewUndoCommands() {
+		registry.RegisterCommand(cmd)
+	}
+	for _, cmd := range NewDiffCommands() {
+		registry.RegisterCommand(cmd)
+	}
+	for _, cmd := range NewMergeCommands() {
+		registry.RegisterCommand(cmd)
+	}
 	
 	// Register AI commands
 	registry.RegisterCommand(NewAICompleteCommand())
 	registry.RegisterCommand(NewAIExplainCommand())
+	registry.RegisterCommand(NewPreviewCloseCommand())
 	registry.RegisterCommand(NewAIRefactorCommand())
+	registry.RegisterCommand(NewAIEditCommand())
+	registry.RegisterCommand(NewAIApplyCommand())
+	registry.RegisterCommand(NewAIDiscardCommand())
+	for _, cmd := range NewAgentCommands() {
+		registry.RegisterCommand(cmd)
+	}
 	registry.RegisterCommand(NewAIChatCommand())
+	registry.RegisterCommand(NewAICancelCommand())
+	registry.RegisterCommand(NewAIScratchCommand())
+	registry.RegisterCommand(NewAISendCommand())
+	registry.RegisterCommand(NewAIHistoryCommand())
 	registry.RegisterCommand(NewAIProviderCommand())
+	registry.RegisterCommand(NewOllamaCommand())
 	
 	// Register config commands
 	registry.RegisterCommand(NewConfigGenerateCommand())
 	registry.RegisterCommand(NewConfigShowCommand())
 	registry.RegisterCommand(NewConfigReloadCommand())
+	registry.RegisterCommand(NewCheckHealthCommand())
+	registry.RegisterCommand(NewPerfCommand())
 	
 	// Register LSP commands
 	registry.RegisterCommand(NewHoverCommand())
 	registry.RegisterCommand(NewDefinitionCommand())
 	registry.RegisterCommand(NewReferencesCommand())
 	registry.RegisterCommand(NewRenameCommand())
+	registry.RegisterCommand(NewOrganizeImportsCommand())
+	
+	// Register quickfix and location list commands
+	for _, cmd := range NewListCommands() {
+		registry.RegisterCommand(cmd)
+	}
+	registry.RegisterCommand(NewGrepCommand())
+	registry.RegisterCommand(NewLocationGrepCommand())
+	for _, cmd := range NewReplaceCommands() {
+		registry.RegisterCommand(cmd)
+	}
+	registry.RegisterCommand(NewDiagnosticsCommand())
+	registry.RegisterCommand(NewMakeCommand())
+	registry.RegisterCommand(NewTestCommand())
+	
+	// Register help, which describes the registry's commands
+	registry.RegisterCommand(NewHelpCommand(registry))
 	
 	return registry
 }
@@ -136,20 +228,73 @@ func (cp *CommandParser) ParseCommand(cmdLine string) (string, []string, error)
 type CommandExecutor struct {
 	registry *CommandRegistry
 	parser   *CommandParser
+	context  *CommandContext // Passed to the commands it runs
+	state    *State          // Kept by the commands between command lines
+	last     string          // Last command line executed, for @:
 }
 
-// NewCommandExecutor creates a new command executor
+// NewCommandExecutor creates a new command executor with the built-in
+// commands
 func NewCommandExecutor() *CommandExecutor {
-	return &CommandExecutor{
+	state := NewState()
+	ce := &CommandExecutor{
 		registry: NewCommandRegistry(),
 		parser:   NewCommandParser(),
+		context:  &CommandContext{State: state},
+		state:    state,
+	}
+	for _, cmd := range NewGlobalCommands(ce) {
+		ce.registry.RegisterCommand(cmd)
 	}
+	ce.registry.RegisterCommand(NewAIContextCommand(ce))
+	return ce
+}
+
+// SetContext sets what the commands the executor runs work with. Its
+// State is set to the executor's.
+func (ce *CommandExecutor) SetContext(cc *CommandContext) {
+	cc.State = ce.state
+	ce.context = cc
+}
+
+// State returns what the executor's commands keep between command lines
+func (ce *CommandExecutor) State() *State {
+	return ce.state
 }
 
 // Execute parses and executes a command line
 func (ce *CommandExecutor) Execute(cmdLine string, buf *buffer.Buffer) CommandResult {
+	ce.last = cmdLine
+	ce.state.runningLine = cmdLine
+	return ce.run(cmdLine, buf)
+}
+
+// Run executes a command line without recording it for @:, for commands
+// started by keys rather than typed
+func (ce *CommandExecutor) Run(cmdLine string, buf *buffer.Buffer) CommandResult {
+	ce.state.runningLine = cmdLine
+	return ce.run(cmdLine, buf)
+}
+
+// run executes a command line without recording it for @:
+func (ce *CommandExecutor) run(cmdLine string, buf *buffer.Buffer) CommandResult {
+	// A leading line range, like 10,20 or %
+	r, hasRange, rest, err := parseRange(cmdLine, buf)
+	if err != nil {
+		return CommandResult{
+			Success:    false,
+			Message:    fmt.Sprintf("Error: %s", err.Error()),
+			SwitchMode: true,
+		}
+	}
+	if hasRange && strings.TrimSpace(rest) == "" {
+		// A range alone goes to its last line
+		buf.SetCursor(buffer.Position{Line: r.End})
+		return CommandResult{Success: true, SwitchMode: true}
+	}
+
 	// Parse the command line
-	cmdName, args, err := ce.parser.ParseCommand(cmdLine)
+	cmdName, args, err := ce.parser.ParseCommand(rest)
 	if err != nil {
 		return CommandResult{
 			Success:    false,
@@ -160,6 +305,14 @@ func (ce *CommandExecutor) Execute(cmdLine string, buf *buffer.Buffer) CommandRe
 	
 	// Get the command
 	cmd, exists := ce.registry.GetCommand(cmdName)
+	if !exists {
+		// Arguments may follow the name directly, as in :d3 or :>>
+		if name, arg := splitCommandName(cmdName); arg != "" {
+			if cmd, exists = ce.registry.GetCommand(name); exists {
+				cmdName, args = name, append([]string{arg}, args...)
+			}
+		}
+	}
 	if !exists {
 		return CommandResult{
 			Success:    false,
@@ -167,9 +320,47 @@ func (ce *CommandExecutor) Execute(cmdLine string, buf *buffer.Buffer) CommandRe
 			SwitchMode: true,
 		}
 	}
-	
+	cc := ce.context
+	text := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(rest, " \t"), cmdName), " \t")
+	if _, ok := cmd.(routedCommand); ok {
+		// @provider/model or --model first, for this request alone
+		route, remaining, err := parseRoute(cc, text)
+		if err != nil {
+			return CommandResult{Success: false, Message: err.Error(), SwitchMode: true}
+		}
+		if route != nil {
+			routed := *cc
+			routed.Route = route
+			cc, text = &routed, remaining
+			if _, args, err = ce.parser.ParseCommand(cmdName + " " + text); err != nil && !isTextArg(cmd) {
+				return CommandResult{Success: false, Message: fmt.Sprintf("Error: %s", err.Error()), SwitchMode: true}
+			}
+		}
+	}
+	if isTextArg(cmd) {
+		// The argument text as typed
+		args = nil
+		if text != "" {
+			args = []string{text}
+		}
+	}
+
 	// Execute the command
-	result := cmd.Execute(args, buf)
+	var result CommandResult
+	if hasRange {
+		rangeCmd, ok := cmd.(RangeCommand)
+		if !ok {
+			return CommandResult{
+				Success:    false,
+				Message:    fmt.Sprintf("No range allowed: %s", cmdName),
+				SwitchMode: true,
+			}
+		}
+		result = rangeCmd.ExecuteRange(cc, r, args, buf)
+	} else {
+		result = cmd.Execute(cc, args, buf)
+	}
+	result = applyActions(ce.state, result, buf)
 	
 	// Always switch back to normal mode unless explicitly requested not to
 	if !result.ExitEditor {
@@ -179,6 +370,28 @@ func (ce *CommandExecutor) Execute(cmdLine string, buf *buffer.Buffer) CommandRe
 	return result
 }
 
+// RepeatLast executes the last command line again, for @:
+func (ce *CommandExecutor) RepeatLast(buf *buffer.Buffer) CommandResult {
+	if ce.last == "" {
+		return CommandResult{Success: false, Message: "No previous command line", SwitchMode: true}
+	}
+	return ce.Execute(ce.last, buf)
+}
+
+// splitCommandName splits a command name from arguments written directly
+// after it: a name of letters ends at the first other character, and
+// other names are a single character
+func splitCommandName(token string) (string, string) {
+	end := 0
+	for end < len(token) && unicode.IsLetter(rune(token[end])) {
+		end++
+	}
+	if end == 0 {
+		end = 1
+	}
+	return token[:end], token[end:]
+}
+
 // GetCommands returns the command registry for introspection
 func (ce *CommandExecutor) GetCommands() *CommandRegistry {
 	return ce.registry
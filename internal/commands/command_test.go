@@ -3,7 +3,9 @@ package commands
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dshills/aied/internal/buffer"
 )
@@ -107,7 +109,7 @@ func TestWriteCommand(t *testing.T) {
 	buf.InsertChar('o')
 
 	// Test write with filename argument
-	result := cmd.Execute([]string{testFile}, buf)
+	result := cmd.Execute(&CommandContext{State: NewState()}, []string{testFile}, buf)
 	if !result.Success {
 		t.Errorf("expected write to succeed, got error: %s", result.Message)
 	}
@@ -118,7 +120,7 @@ func TestWriteCommand(t *testing.T) {
 	}
 
 	// Test write without filename (should use buffer's filename)
-	result = cmd.Execute([]string{}, buf)
+	result = cmd.Execute(&CommandContext{State: NewState()}, []string{}, buf)
 	if !result.Success {
 		t.Errorf("expected write to succeed, got error: %s", result.Message)
 	}
@@ -129,7 +131,7 @@ func TestQuitCommand(t *testing.T) {
 
 	// Test quit with unmodified buffer
 	buf := buffer.New()
-	result := cmd.Execute([]string{}, buf)
+	result := cmd.Execute(&CommandContext{State: NewState()}, []string{}, buf)
 	if !result.Success {
 		t.Errorf("expected quit to succeed with clean buffer, got: %s", result.Message)
 	}
@@ -139,7 +141,7 @@ func TestQuitCommand(t *testing.T) {
 
 	// Test quit with modified buffer
 	buf.InsertChar('a')
-	result = cmd.Execute([]string{}, buf)
+	result = cmd.Execute(&CommandContext{State: NewState()}, []string{}, buf)
 	if result.Success {
 		t.Error("expected quit to fail with modified buffer")
 	}
@@ -154,7 +156,7 @@ func TestForceQuitCommand(t *testing.T) {
 	// Test force quit with modified buffer
 	buf := buffer.New()
 	buf.InsertChar('a')
-	result := cmd.Execute([]string{}, buf)
+	result := cmd.Execute(&CommandContext{State: NewState()}, []string{}, buf)
 	if !result.Success {
 		t.Errorf("expected force quit to succeed, got: %s", result.Message)
 	}
@@ -176,7 +178,7 @@ func TestWriteQuitCommand(t *testing.T) {
 	buf.InsertChar('t')
 
 	// Test write and quit
-	result := cmd.Execute([]string{testFile}, buf)
+	result := cmd.Execute(&CommandContext{State: NewState()}, []string{testFile}, buf)
 	if !result.Success {
 		t.Errorf("expected write-quit to succeed, got: %s", result.Message)
 	}
@@ -211,4 +213,140 @@ func TestCommandExecutor(t *testing.T) {
 	if result.Success {
 		t.Error("expected empty command to fail")
 	}
-}
\ No newline at end of file
+}
+func TestSetCommand(t *testing.T) {
+	cmd := NewSetCommand()
+	buf := buffer.New()
+	buf.SetFilename("main.go")
+	cc := &CommandContext{State: NewState()}
+
+	result := cmd.Execute(cc, []string{"ft?"}, buf)
+	if !result.Success || result.Message != "filetype=go" {
+		t.Errorf(":set ft? = %+v", result)
+	}
+
+	if result := cmd.Execute(cc, []string{"filetype=python"}, buf); !result.Success {
+		t.Fatalf(":set filetype=python failed: %s", result.Message)
+	}
+	if buf.Filetype() != "python" {
+		t.Errorf("Filetype() = %q after :set", buf.Filetype())
+	}
+
+	// An empty value returns to detection
+	cmd.Execute(cc, []string{"ft="}, buf)
+	if buf.Filetype() != "go" {
+		t.Errorf("Filetype() = %q after clearing", buf.Filetype())
+	}
+
+	if result := cmd.Execute(cc, []string{"nosuchoption"}, buf); result.Success {
+		t.Error("expected an unknown option to fail")
+	}
+	if result := cmd.Execute(cc, nil, buf); !strings.HasPrefix(result.Message, "filetype=go tabstop=4") {
+		t.Errorf(":set = %q", result.Message)
+	}
+
+	// Numbers and switches
+	if result := cmd.Execute(cc, []string{"ts=8", "sw=2", "noet", "ro"}, buf); !result.Success {
+		t.Fatalf(":set failed: %s", result.Message)
+	}
+	options := buf.Options()
+	if options.TabStop != 8 || options.ShiftWidth != 2 || !options.IndentTabs || !options.ReadOnly {
+		t.Errorf("options = %+v", options)
+	}
+	if result := cmd.Execute(cc, []string{"et?", "ro?"}, buf); result.Message != "noexpandtab readonly" {
+		t.Errorf(":set et? ro? = %q", result.Message)
+	}
+	cmd.Execute(cc, []string{"ro!", "invet"}, buf)
+	if options := buf.Options(); options.ReadOnly || options.IndentTabs {
+		t.Errorf("toggling left %+v", options)
+	}
+
+	// The ruler is shown for every buffer
+	cmd.Execute(cc, []string{"noru"}, buf)
+	if result := cmd.Execute(cc, []string{"ruler?"}, buf); cc.State.Ruler() || result.Message != "noruler" {
+		t.Errorf(":set noru left the ruler %v, :set ruler? = %q", cc.State.Ruler(), result.Message)
+	}
+	cmd.Execute(cc, []string{"tm=250"}, buf)
+	if result := cmd.Execute(cc, []string{"timeoutlen?"}, buf); cc.State.TimeoutLen() != 250*time.Millisecond || result.Message != "timeoutlen=250" {
+		t.Errorf(":set tm=250 set %v, :set timeoutlen? = %q", cc.State.TimeoutLen(), result.Message)
+	}
+	for _, bad := range []string{"ts=0", "ts=x", "ro=1", "ft=a/b", "nots", "tm=-1"} {
+		if result := cmd.Execute(cc, []string{bad}, buf); result.Success {
+			t.Errorf(":set %s succeeded", bad)
+		}
+	}
+}
+
+func TestApplyModeline(t *testing.T) {
+	buf := buffer.New()
+	buf.SetFilename("notes")
+	buf.ReplaceLines(0, 0, []string{"# vim: set ts=2 sw=2 noet ft=yaml ro:", "key: value", "/* vim: tw=60 */"})
+
+	cc := &CommandContext{State: NewState()}
+	cc.State.ApplyModeline(buf)
+	options := buf.Options()
+	if options.TabStop != 2 || options.ShiftWidth != 2 || !options.IndentTabs || !options.ReadOnly || options.TextWidth != 60 {
+		t.Errorf("options after modeline = %+v", options)
+	}
+	if buf.Filetype() != "yaml" {
+		t.Errorf("Filetype() = %q", buf.Filetype())
+	}
+
+	// A read-only buffer refuses to be written
+	result := NewWriteCommand().Execute(cc, []string{filepath.Join(t.TempDir(), "out")}, buf)
+	if result.Success || !strings.Contains(result.Message, "readonly") {
+		t.Errorf(":w of a read-only buffer = %+v", result)
+	}
+}
+
+func TestWriteRangeAppendAndSaveAs(t *testing.T) {
+	dir := t.TempDir()
+	own := filepath.Join(dir, "notes.txt")
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"one", "two", "three"})
+	buf.SetFilename(own)
+	executor := NewCommandExecutor()
+
+	part := filepath.Join(dir, "part.txt")
+	if result := executor.Execute("2,3w "+part, buf); !result.Success || result.Message != "2 lines written to "+part {
+		t.Fatalf(":2,3w = %+v", result)
+	}
+	os.WriteFile(filepath.Join(dir, "log.txt"), []byte("start"), 0644)
+	executor.Execute("1w >> "+filepath.Join(dir, "log.txt"), buf)
+	executor.Execute("3w>>"+filepath.Join(dir, "log.txt"), buf)
+	for name, want := range map[string]string{"part.txt": "two\nthree", "log.txt": "start\none\nthree"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	if buf.Filename() != own || !buf.Modified() {
+		t.Errorf("writing copies changed the buffer to %q, modified %v", buf.Filename(), buf.Modified())
+	}
+
+	// Part of the buffer doesn't replace its own file
+	if result := executor.Execute("1,2w", buf); result.Success {
+		t.Errorf(":1,2w over the buffer's file = %+v", result)
+	}
+	if _, err := os.Stat(own); !os.IsNotExist(err) {
+		t.Errorf("the buffer's file was written: %v", err)
+	}
+
+	// :w to another file writes a copy, :saveas edits it from then on
+	copied := filepath.Join(dir, "copy.txt")
+	executor.Execute("w "+copied, buf)
+	if buf.Filename() != own || !buf.Modified() {
+		t.Errorf(":w file changed the buffer to %q, modified %v", buf.Filename(), buf.Modified())
+	}
+	moved := filepath.Join(dir, "moved.txt")
+	if result := executor.Execute("sav "+moved, buf); !result.Success {
+		t.Fatalf(":saveas = %+v", result)
+	}
+	if buf.Filename() != moved || buf.Modified() {
+		t.Errorf("after :saveas the buffer edits %q, modified %v", buf.Filename(), buf.Modified())
+	}
+	for _, name := range []string{copied, moved} {
+		if data, _ := os.ReadFile(name); string(data) != "one\ntwo\nthree" {
+			t.Errorf("%s = %q", name, data)
+		}
+	}
+}
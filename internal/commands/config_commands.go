@@ -5,11 +5,23 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/dshills/aied/internal/ai"
 	"github.com/dshills/aied/internal/buffer"
 	"github.com/dshills/aied/internal/config"
 )
 
+// currentConfig returns the configuration the editor runs with, loading
+// it when commands run without the editor
+func (cc *CommandContext) currentConfig() *config.Config {
+	if cc.Config != nil {
+		return cc.Config.Get()
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return config.DefaultConfig()
+	}
+	return cfg
+}
+
 // ConfigGenerateCommand generates an example configuration file
 type ConfigGenerateCommand struct{}
 
@@ -25,7 +37,7 @@ func (c *ConfigGenerateCommand) Aliases() []string {
 	return []string{"cg"}
 }
 
-func (c *ConfigGenerateCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
+func (c *ConfigGenerateCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
 	path := ".aied.yaml"
 	if len(args) > 0 {
 		path = args[0]
@@ -71,15 +83,8 @@ func (c *ConfigShowCommand) Aliases() []string {
 	return []string{"cfg"}
 }
 
-func (c *ConfigShowCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	cfg, err := config.Load()
-	if err != nil {
-		return CommandResult{
-			Success:    false,
-			Message:    fmt.Sprintf("Failed to load config: %s", err.Error()),
-			SwitchMode: true,
-		}
-	}
+func (c *ConfigShowCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	cfg := cc.currentConfig()
 	
 	var info strings.Builder
 	info.WriteString("Configuration:\n")
@@ -126,10 +131,14 @@ func (c *ConfigReloadCommand) Aliases() []string {
 	return []string{"cr"}
 }
 
-func (c *ConfigReloadCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	// Load new config
-	cfg, err := config.Load()
-	if err != nil {
+func (c *ConfigReloadCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.Config == nil {
+		return CommandResult{Success: false, Message: "No configuration to reload", SwitchMode: true}
+	}
+	
+	// The subscribers apply the new settings to the UI, buffers and AI
+	// providers
+	if err := cc.Config.Reload(); err != nil {
 		return CommandResult{
 			Success:    false,
 			Message:    fmt.Sprintf("Failed to reload config: %s", err.Error()),
@@ -137,16 +146,6 @@ func (c *ConfigReloadCommand) Execute(args []string, buf *buffer.Buffer) Command
 		}
 	}
 	
-	// Re-initialize AI providers
-	if aiManager != nil {
-		aiManager.ConfigureProviders(cfg.Providers)
-		
-		// Set default provider if specified
-		if cfg.AI.DefaultProvider != "" {
-			aiManager.SetActiveProvider(ai.ProviderType(cfg.AI.DefaultProvider))
-		}
-	}
-	
 	return CommandResult{
 		Success:    true,
 		Message:    "Configuration reloaded",
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+)
+
+func TestConfigReload(t *testing.T) {
+	reloaded := config.DefaultConfig()
+	reloaded.Editor.TabSize = 2
+	service := config.NewService(config.DefaultConfig(), func() (*config.Config, error) { return reloaded, nil })
+	var applied *config.Config
+	service.Subscribe(func(cfg *config.Config) { applied = cfg })
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{Config: service})
+	if result := executor.Execute("configreload", buffer.New()); !result.Success {
+		t.Fatalf(":configreload failed: %s", result.Message)
+	}
+	if applied != reloaded {
+		t.Error(":configreload didn't pass the reloaded config to the subscribers")
+	}
+	if result := executor.Execute("config", buffer.New()); !strings.Contains(result.Message, "Tab size: 2") {
+		t.Errorf(":config after reloading shows\n%s\nwant the reloaded tab size", result.Message)
+	}
+}
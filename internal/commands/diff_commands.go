@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/diff"
+)
+
+// DiffBuffer returns the buffer the edited buffer is compared with and its
+// title, or nil when diff mode is off
+func (s *State) DiffBuffer() (*buffer.Buffer, string) {
+	return s.diffOther, s.diffTitle
+}
+
+// StartDiff turns on diff mode against other, which may be a file or text
+// that exists only in memory, like a proposed change
+func (s *State) StartDiff(other *buffer.Buffer, title string) {
+	s.diffOther, s.diffTitle = other, title
+}
+
+// DiffGet replaces the difference at the cursor with the other side's
+// text, for do
+func (s *State) DiffGet(buf *buffer.Buffer) CommandResult {
+	return s.diffTransfer(currentLine(buf), buf, false)
+}
+
+// DiffPut copies the difference at the cursor to the other side, for dp
+func (s *State) DiffPut(buf *buffer.Buffer) CommandResult {
+	return s.diffTransfer(currentLine(buf), buf, true)
+}
+
+// DiffHunkLines returns the first line of each difference in buf, for ]c
+// and [c, or nil when diff mode is off
+func (s *State) DiffHunkLines(buf *buffer.Buffer) []int {
+	if s.diffOther == nil {
+		return nil
+	}
+	var lines []int
+	for _, h := range diff.Lines(buf.Lines(), s.diffOther.Lines()) {
+		lines = append(lines, hunkLines(h).Start)
+	}
+	return lines
+}
+
+// hunkLines returns the lines of the edited buffer a hunk covers. Lines only
+// on the other side count as below the line before them.
+func hunkLines(h diff.Hunk) Range {
+	if h.ACount == 0 {
+		line := max(h.A-1, 0)
+		return Range{Start: line, End: line}
+	}
+	return Range{Start: h.A, End: h.A + h.ACount - 1}
+}
+
+// diffCommand is a diff mode command
+type diffCommand struct {
+	name    string
+	aliases []string
+	help    string
+	run     func(s *State, r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult
+}
+
+func (c *diffCommand) Name() string {
+	return c.name
+}
+
+func (c *diffCommand) Aliases() []string {
+	return c.aliases
+}
+
+func (c *diffCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(cc.State, currentLine(buf), false, args, buf)
+}
+
+func (c *diffCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(cc.State, r, true, args, buf)
+}
+
+func (c *diffCommand) Help() string {
+	return c.help
+}
+
+// NewDiffCommands creates :diffsplit, :DiffOrig, :diffoff, :diffget,
+// :diffput and :diffwrite
+func NewDiffCommands() []Command {
+	return []Command{
+		&diffCommand{"diffsplit", []string{"diffs"}, ":diffsplit {file} - Compare the buffer with file side by side", (*State).diffSplit},
+		&diffCommand{"DiffOrig", []string{"difforig"}, ":DiffOrig - Compare the buffer with its file on disk, to review the changes not saved yet", (*State).diffOrig},
+		&diffCommand{"diffoff", []string{"diffo"}, ":diffoff[!] - Leave diff mode; ! drops unsaved changes put into the other side", (*State).diffOff},
+		&diffCommand{"diffget", []string{"diffg"}, ":[range]diffget - Replace the differences at the cursor or in range with the other side's text (do)", func(s *State, r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult {
+			return s.diffTransfer(r, buf, false)
+		}},
+		&diffCommand{"diffput", []string{"diffpu"}, ":[range]diffput - Copy the differences at the cursor or in range to the other side (dp)", func(s *State, r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult {
+			return s.diffTransfer(r, buf, true)
+		}},
+		&diffCommand{"diffwrite", []string{"diffw"}, ":diffwrite - Write the other side of the diff to its file", (*State).diffWrite},
+	}
+}
+
+// diffSplit implements :diffsplit
+func (s *State) diffSplit(r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult {
+	if hasRange {
+		return CommandResult{Success: false, Message: "No range allowed: diffsplit"}
+	}
+	if len(args) != 1 {
+		return CommandResult{Success: false, Message: "Usage: :diffsplit {file}"}
+	}
+	if s.otherSideUnsaved() {
+		return CommandResult{Success: false, Message: "The other side has unsaved changes (use :diffwrite or :diffoff!)"}
+	}
+	other, err := buffer.NewFromFile(args[0])
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error opening file: %s", err.Error())}
+	}
+	s.StartDiff(other, args[0])
+	hunks := diff.Lines(buf.Lines(), other.Lines())
+	return CommandResult{Success: true, Message: fmt.Sprintf("%d differences with %s", len(hunks), args[0])}
+}
+
+// diffOrig implements :DiffOrig
+func (s *State) diffOrig(r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult {
+	if hasRange || len(args) > 0 {
+		return CommandResult{Success: false, Message: "Usage: :DiffOrig"}
+	}
+	if buf.Filename() == "" {
+		return CommandResult{Success: false, Message: "No file name"}
+	}
+	if s.otherSideUnsaved() {
+		return CommandResult{Success: false, Message: "The other side has unsaved changes (use :diffwrite or :diffoff!)"}
+	}
+	saved, err := buffer.NewFromFile(buf.Filename())
+	if errors.Is(err, fs.ErrNotExist) {
+		return CommandResult{Success: false, Message: fmt.Sprintf("%s hasn't been saved yet", buf.Filename())}
+	}
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error opening file: %s", err.Error())}
+	}
+
+	// The saved text is only kept in memory, so that :diffput can't write
+	// over the file and :diffoff doesn't ask to save it
+	original := buffer.New()
+	original.ReplaceLines(0, 0, saved.Lines())
+	s.StartDiff(original, buf.Filename()+" (saved)")
+	hunks := diff.Lines(buf.Lines(), original.Lines())
+	if len(hunks) == 0 {
+		return CommandResult{Success: true, Message: "No changes since the last save"}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("%d differences with the saved file", len(hunks))}
+}
+
+// diffOff implements :diffoff
+func (s *State) diffOff(r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult {
+	force := len(args) > 0 && args[0] == "!"
+	if s.otherSideUnsaved() && !force {
+		return CommandResult{Success: false, Message: "The other side has unsaved changes (use :diffwrite or :diffoff!)"}
+	}
+	s.StartDiff(nil, "")
+	return CommandResult{Success: true}
+}
+
+// otherSideUnsaved reports whether changes were put into the file on the
+// other side of the diff and not written. Text that only exists in memory,
+// like a proposed change, isn't meant to be written.
+func (s *State) otherSideUnsaved() bool {
+	return s.diffOther != nil && s.diffOther.Modified() && s.diffOther.Filename() != ""
+}
+
+// diffWrite implements :diffwrite
+func (s *State) diffWrite(r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult {
+	if s.diffOther == nil {
+		return CommandResult{Success: false, Message: "Not in diff mode"}
+	}
+	if s.diffOther.Filename() == "" {
+		return CommandResult{Success: false, Message: "The other side has no file name"}
+	}
+	if err := s.diffOther.Save(); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error writing file: %s%s", err.Error(), permissionHint(err))}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("File written: %s", s.diffOther.Filename())}
+}
+
+// diffTransfer implements :diffget, or :diffput when put, for the hunks
+// touching r
+func (s *State) diffTransfer(r Range, buf *buffer.Buffer, put bool) CommandResult {
+	if s.diffOther == nil {
+		return CommandResult{Success: false, Message: "Not in diff mode"}
+	}
+
+	lines, otherLines := buf.Lines(), s.diffOther.Lines()
+	var selected []diff.Hunk
+	for _, h := range diff.Lines(lines, otherLines) {
+		if lines := hunkLines(h); lines.Start <= r.End && lines.End >= r.Start {
+			selected = append(selected, h)
+		}
+	}
+	if len(selected) == 0 {
+		return CommandResult{Success: false, Message: "No differences here"}
+	}
+
+	// Later hunks first, so the line numbers of earlier ones stay valid
+	for _, h := range slices.Backward(selected) {
+		if put {
+			spliceLines(s.diffOther, h.B, h.BCount, lines[h.A:h.A+h.ACount])
+		} else {
+			spliceLines(buf, h.A, h.ACount, otherLines[h.B:h.B+h.BCount])
+		}
+	}
+	if put {
+		// The mode manager only records undo steps of the edited buffer
+		s.diffOther.Commit()
+	} else {
+		buf.SetCursor(buffer.Position{Line: selected[0].A})
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("%d differences left", len(diff.Lines(buf.Lines(), s.diffOther.Lines())))}
+}
+
+// spliceLines replaces count lines of buf from line start with lines, so
+// that a count of 0 inserts them before line start
+func spliceLines(buf *buffer.Buffer, start, count int, lines []string) {
+	all := buf.Lines()
+	if count > 0 {
+		buf.ReplaceLines(start, start+count-1, lines)
+		return
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if start < len(all) {
+		buf.ReplaceLines(start, start, append(slices.Clone(lines), all[start]))
+	} else {
+		buf.ReplaceLines(start-1, start-1, append([]string{all[start-1]}, lines...))
+	}
+}
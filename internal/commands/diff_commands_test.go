@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestDiffCommands(t *testing.T) {
+	other := filepath.Join(t.TempDir(), "other.txt")
+	os.WriteFile(other, []byte("one\nTWO\nthree\nfour\nfive\n"), 0644)
+
+	executor := NewCommandExecutor()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"one", "two", "three", "five", "six"})
+
+	if result := executor.Execute("diffget", buf); result.Success {
+		t.Error("expected :diffget outside diff mode to fail")
+	}
+	if result := executor.Execute("diffsplit "+other, buf); !result.Success || result.Message != "3 differences with "+other {
+		t.Fatalf(":diffsplit = %+v", result)
+	}
+	if lines := executor.State().DiffHunkLines(buf); !slices.Equal(lines, []int{1, 2, 4}) {
+		t.Errorf("DiffHunkLines = %v", lines)
+	}
+
+	// do on a changed line
+	buf.SetCursor(buffer.Position{Line: 1})
+	if result := executor.State().DiffGet(buf); result.Message != "2 differences left" {
+		t.Errorf("do = %+v", result)
+	}
+	if line, _ := buf.Line(1); line != "TWO" {
+		t.Errorf("line 2 after do = %q", line)
+	}
+
+	// dp on a line only the edited buffer has
+	buf.SetCursor(buffer.Position{Line: 4})
+	executor.State().DiffPut(buf)
+	otherBuf, _ := executor.State().DiffBuffer()
+	if want := []string{"one", "TWO", "three", "four", "five", "six"}; !slices.Equal(otherBuf.Lines(), want) {
+		t.Errorf("other side after dp = %q", otherBuf.Lines())
+	}
+
+	// Lines only on the other side are obtained at the line above them
+	executor.Execute("3diffget", buf)
+	if want := []string{"one", "TWO", "three", "four", "five", "six"}; !slices.Equal(buf.Lines(), want) {
+		t.Errorf("buffer after :3diffget = %q", buf.Lines())
+	}
+
+	if result := executor.Execute("diffoff", buf); result.Success {
+		t.Error("expected :diffoff to refuse to drop changes to the other side")
+	}
+	if result := executor.Execute("diffwrite", buf); !result.Success {
+		t.Fatalf(":diffwrite = %+v", result)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "one\nTWO\nthree\nfour\nfive\nsix" {
+		t.Errorf("written file = %q", data)
+	}
+	if result := executor.Execute("diffoff", buf); !result.Success {
+		t.Errorf(":diffoff = %+v", result)
+	}
+	if other, _ := executor.State().DiffBuffer(); other != nil {
+		t.Error("expected diff mode to be off")
+	}
+}
+
+func TestDiffOrig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(file, []byte("one\ntwo\nthree\n"), 0644)
+	buf, err := buffer.NewFromFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := NewCommandExecutor()
+
+	if result := executor.Execute("DiffOrig", buf); result.Message != "No changes since the last save" {
+		t.Errorf(":DiffOrig without changes = %+v", result)
+	}
+	buf.ReplaceLines(1, 1, []string{"TWO"})
+	if result := executor.Execute("DiffOrig", buf); !result.Success || result.Message != "1 differences with the saved file" {
+		t.Fatalf(":DiffOrig = %+v", result)
+	}
+	if _, title := executor.State().DiffBuffer(); title != file+" (saved)" {
+		t.Errorf("title = %q", title)
+	}
+
+	// Putting the change into the saved side doesn't touch the file
+	buf.SetCursor(buffer.Position{Line: 1})
+	executor.State().DiffPut(buf)
+	if data, _ := os.ReadFile(file); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("file after dp = %q", data)
+	}
+	if result := executor.Execute("diffoff", buf); !result.Success {
+		t.Errorf(":diffoff = %+v", result)
+	}
+
+	buf.SetFilename(filepath.Join(t.TempDir(), "new.txt"))
+	if result := executor.Execute("DiffOrig", buf); result.Success {
+		t.Errorf(":DiffOrig on an unsaved file = %+v", result)
+	}
+}
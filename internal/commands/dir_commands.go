@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/project"
+	"github.com/dshills/aied/internal/stdio"
+)
+
+// CdCommand implements :cd, which changes the editor's working directory,
+// and :lcd, which changes only the buffer's
+type CdCommand struct {
+	local bool
+}
+
+// NewCdCommand creates the :cd command
+func NewCdCommand() *CdCommand {
+	return &CdCommand{}
+}
+
+// NewLcdCommand creates the :lcd command
+func NewLcdCommand() *CdCommand {
+	return &CdCommand{local: true}
+}
+
+func (c *CdCommand) Name() string {
+	if c.local {
+		return "lcd"
+	}
+	return "cd"
+}
+
+func (c *CdCommand) Aliases() []string {
+	if c.local {
+		return []string{"lchdir"}
+	}
+	return []string{"chdir"}
+}
+
+func (c *CdCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if len(args) > 1 {
+		return CommandResult{Success: false, Message: "Usage: :" + c.Name() + " [dir]"}
+	}
+
+	// No directory goes home, and :cd - back to the last one
+	target := "~"
+	if len(args) == 1 {
+		target = args[0]
+	}
+	if target == "-" && !c.local {
+		if s.previousDir == "" {
+			return CommandResult{Success: false, Message: "No previous directory"}
+		}
+		target = s.previousDir
+	}
+	if target == "~" || strings.HasPrefix(target, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("No home directory: %v", err)}
+		}
+		target = filepath.Join(home, strings.TrimPrefix(target, "~"))
+	}
+
+	dir, err := filepath.Abs(resolvePath(buf, target))
+	if err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Not a directory: %s", target)}
+	}
+
+	if c.local {
+		buf.SetLocalDir(dir)
+	} else {
+		cwd, _ := os.Getwd()
+		anchorFilenames(cc, buf)
+		if err := os.Chdir(dir); err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Can't change to %s: %v", dir, err)}
+		}
+		s.previousDir, s.cdDir = cwd, dir
+		buf.SetLocalDir("")
+	}
+	return CommandResult{Success: true, Message: dir + followRoot(cc, buf)}
+}
+
+func (c *CdCommand) Help() string {
+	if c.local {
+		return ":lcd [dir] - Change the working directory of this buffer only"
+	}
+	return ":cd [dir] - Change the working directory, home without one and back with -"
+}
+
+// PwdCommand implements :pwd, which shows the working directory
+type PwdCommand struct{}
+
+// NewPwdCommand creates the :pwd command
+func NewPwdCommand() *PwdCommand {
+	return &PwdCommand{}
+}
+
+func (c *PwdCommand) Name() string {
+	return "pwd"
+}
+
+func (c *PwdCommand) Aliases() []string {
+	return []string{"pw"}
+}
+
+func (c *PwdCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if dir := buf.LocalDir(); dir != "" {
+		return CommandResult{Success: true, Message: dir + " (:lcd)"}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+	return CommandResult{Success: true, Message: cwd}
+}
+
+func (c *PwdCommand) Help() string {
+	return ":pwd - Show the working directory, the buffer's own when set with :lcd"
+}
+
+// resolvePath returns name relative to the buffer's working directory,
+// which is only different from the editor's after :lcd
+func resolvePath(buf *buffer.Buffer, name string) string {
+	dir := buf.LocalDir()
+	if dir == "" || name == "" || name == stdio.Name || filepath.IsAbs(name) || strings.Contains(name, "://") {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// anchorFilenames makes the relative file names of buf and the other open
+// buffers absolute before :cd, so they keep naming the same files
+func anchorFilenames(cc *CommandContext, buf *buffer.Buffer) {
+	buffers := []*buffer.Buffer{buf}
+	if cc != nil && cc.Buffers != nil {
+		buffers = append(buffers, cc.Buffers()...)
+	}
+	for _, open := range buffers {
+		name := open.Filename()
+		if name == "" || name == stdio.Name || filepath.IsAbs(name) || strings.Contains(name, "://") {
+			continue
+		}
+		if abs, err := filepath.Abs(name); err == nil {
+			open.SetFilename(abs)
+		}
+	}
+}
+
+// chosenDir returns the directory picked with :lcd for the buffer or else
+// with :cd, or an empty string before either
+func (s *State) chosenDir(buf *buffer.Buffer) string {
+	if dir := buf.LocalDir(); dir != "" {
+		return dir
+	}
+	return s.cdDir
+}
+
+// workProject returns the project being worked in: the one of the
+// directory picked with :lcd or :cd, or else of the buffer's file
+func (s *State) workProject(buf *buffer.Buffer) project.Project {
+	if dir := s.chosenDir(buf); dir != "" {
+		return project.Detect(dir, s.rootMarkers)
+	}
+	return s.bufferProject(buf)
+}
+
+// followRoot restarts the language servers in the project being worked in
+// when it changed, opening the buffer's file in them again. It returns a
+// note for the message of the command that changed it.
+func followRoot(cc *CommandContext, buf *buffer.Buffer) string {
+	if cc.LSP == nil {
+		return ""
+	}
+	root := cc.State.workProject(buf).Root
+	if root == cc.LSP.Root() {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cc.LSP.SetRoot(ctx, root); err != nil {
+		return fmt.Sprintf(" (language servers: %v)", err)
+	}
+	if lspServes(cc, buf.Filename()) {
+		if err := cc.LSP.OpenFile(ctx, buf.Filename(), lsp.GetBufferContent(buf)); err != nil {
+			return fmt.Sprintf(" (language servers: %v)", err)
+		}
+	}
+	return " (language servers restarted in " + displayPath(root) + ")"
+}
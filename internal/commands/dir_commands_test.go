@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestCdAndLcd(t *testing.T) {
+	start := t.TempDir()
+	t.Chdir(start)
+	other := t.TempDir()
+	os.Mkdir(filepath.Join(other, "sub"), 0755)
+
+	executor := NewCommandExecutor()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"text"})
+
+	// :lcd changes where the buffer's relative names go, and only that
+	if result := executor.Execute("lcd "+other, buf); !result.Success || buf.LocalDir() != other {
+		t.Fatalf(":lcd = %+v, local dir %q", result, buf.LocalDir())
+	}
+	if cwd, _ := os.Getwd(); cwd != start {
+		t.Errorf(":lcd changed the working directory to %s", cwd)
+	}
+	if result := executor.Execute("pwd", buf); result.Message != other+" (:lcd)" {
+		t.Errorf(":pwd after :lcd = %q", result.Message)
+	}
+	executor.Execute("1w copy.txt", buf)
+	if _, err := os.Stat(filepath.Join(other, "copy.txt")); err != nil {
+		t.Errorf(":w copy.txt after :lcd didn't write into the local directory: %v", err)
+	}
+	if result := executor.Execute("lcd sub", buf); !result.Success || buf.LocalDir() != filepath.Join(other, "sub") {
+		t.Errorf(":lcd sub = %+v, local dir %q", result, buf.LocalDir())
+	}
+
+	// :cd changes the editor's, dropping the buffer's own, and :cd - goes back
+	if result := executor.Execute("cd "+other, buf); !result.Success || buf.LocalDir() != "" {
+		t.Fatalf(":cd = %+v, local dir %q", result, buf.LocalDir())
+	}
+	if cwd, _ := os.Getwd(); cwd != other {
+		t.Errorf("working directory after :cd = %s", cwd)
+	}
+	if executor.State().workProject(buf).Root != other {
+		t.Errorf("project after :cd = %s, want %s", executor.State().workProject(buf).Root, other)
+	}
+	executor.Execute("cd -", buf)
+	if result := executor.Execute("pwd", buf); result.Message != start {
+		t.Errorf(":pwd after :cd - = %q, want %q", result.Message, start)
+	}
+
+	if result := executor.Execute("cd nosuchdir", buf); result.Success {
+		t.Errorf(":cd to a missing directory = %+v", result)
+	}
+}
+
+func TestCdKeepsBufferFiles(t *testing.T) {
+	start := t.TempDir()
+	t.Chdir(start)
+	os.Mkdir("sub", 0755)
+	os.WriteFile("a.txt", []byte("orig\n"), 0644)
+	os.WriteFile("b.txt", []byte("other\n"), 0644)
+
+	executor := NewCommandExecutor()
+	buf, _ := buffer.NewFromFile("a.txt")
+	other, _ := buffer.NewFromFile("b.txt")
+	executor.SetContext(&CommandContext{Buffers: func() []*buffer.Buffer { return []*buffer.Buffer{buf, other} }})
+
+	if result := executor.Execute("cd sub", buf); !result.Success {
+		t.Fatalf(":cd sub = %+v", result)
+	}
+	buf.ReplaceLines(0, 0, []string{"changed"})
+	if result := executor.Execute("w", buf); !result.Success {
+		t.Fatalf(":w = %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(start, "a.txt")); string(data) != "changed" {
+		t.Errorf("a.txt = %q, want the buffer written to it", data)
+	}
+	if _, err := os.Stat(filepath.Join(start, "sub", "a.txt")); err == nil {
+		t.Error(":w after :cd wrote into the new directory")
+	}
+	if other.Filename() != filepath.Join(start, "b.txt") {
+		t.Errorf("other buffer's file = %q", other.Filename())
+	}
+}
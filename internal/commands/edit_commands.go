@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// StripWhitespaceCommand removes trailing whitespace from every line
+type StripWhitespaceCommand struct{}
+
+// NewStripWhitespaceCommand creates a new strip whitespace command
+func NewStripWhitespaceCommand() *StripWhitespaceCommand {
+	return &StripWhitespaceCommand{}
+}
+
+func (c *StripWhitespaceCommand) Name() string {
+	return "StripWhitespace"
+}
+
+func (c *StripWhitespaceCommand) Aliases() []string {
+	return []string{"stripwhitespace", "stripws"}
+}
+
+func (c *StripWhitespaceCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.ExecuteRange(cc, wholeBuffer(buf), args, buf)
+}
+
+func (c *StripWhitespaceCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	changed := buf.StripTrailingWhitespace(r.Start, r.End)
+	if changed == 0 {
+		return CommandResult{
+			Success: true,
+			Message: "No trailing whitespace found",
+		}
+	}
+
+	return CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Stripped trailing whitespace from %d lines", changed),
+	}
+}
+
+func (c *StripWhitespaceCommand) Help() string {
+	return ":[range]StripWhitespace - Remove trailing whitespace from all lines, or the lines in range"
+}
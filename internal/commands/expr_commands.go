@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"strconv"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/expr"
+)
+
+// ExprCommand implements :=, which shows the value of an expression, or
+// the number of lines without one, as in Vim
+type ExprCommand struct{}
+
+// NewExprCommand creates the := command
+func NewExprCommand() *ExprCommand {
+	return &ExprCommand{}
+}
+
+func (c *ExprCommand) Name() string {
+	return "="
+}
+
+func (c *ExprCommand) Aliases() []string {
+	return nil
+}
+
+func (c *ExprCommand) textArgument() {}
+
+func (c *ExprCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if len(args) == 0 {
+		return CommandResult{Success: true, Message: strconv.Itoa(buf.LineCount())}
+	}
+	result, err := expr.Eval(args[0])
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: result}
+}
+
+func (c *ExprCommand) Help() string {
+	return ":= [expr] - Show the value of an expression, like := 2 * (3 + 4) or := toupper($USER), or the number of lines"
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestExprCommand(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"one", "two", "three"})
+	executor := NewCommandExecutor()
+
+	for line, want := range map[string]string{
+		"=2 * (3 + 4)":            "14",
+		"= 'a b' .. toupper('c')": "a bC",
+		"=":                       "3",
+	} {
+		if result := executor.Execute(line, buf); !result.Success || result.Message != want {
+			t.Errorf(":%s = %q, want %q", line, result.Message, want)
+		}
+	}
+
+	if result := executor.Execute("=1 / 0", buf); result.Success || result.Message != "Division by zero" {
+		t.Errorf(":=1 / 0 = %+v, want a division by zero error", result)
+	}
+}
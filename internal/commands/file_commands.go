@@ -1,8 +1,12 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/dshills/aied/internal/buffer"
 )
@@ -23,12 +27,33 @@ func (w *WriteCommand) Aliases() []string {
 	return []string{"w"}
 }
 
-func (w *WriteCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
+func (w *WriteCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return w.write(cc, wholeBuffer(buf), args, buf)
+}
+
+func (w *WriteCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	return w.write(cc, r, args, buf)
+}
+
+// write writes the lines of r. The whole buffer written to its own file,
+// or to a file when it has none, saves it; anything else writes a copy.
+func (w *WriteCommand) write(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	// :w >> file appends, and >> may be written against the file name
+	appendTo := false
+	if len(args) > 0 && strings.HasPrefix(args[0], ">>") {
+		appendTo = true
+		if rest := strings.TrimPrefix(args[0], ">>"); rest != "" {
+			args = append([]string{rest}, args[1:]...)
+		} else {
+			args = args[1:]
+		}
+	}
+
 	var filename string
 	
 	if len(args) > 0 {
 		// Use provided filename
-		filename = args[0]
+		filename = resolvePath(buf, args[0])
 	} else {
 		// Use buffer's current filename
 		filename = buf.Filename()
@@ -40,35 +65,112 @@ func (w *WriteCommand) Execute(args []string, buf *buffer.Buffer) CommandResult
 		}
 	}
 	
-	// Save the file
-	if len(args) > 0 {
-		// Save as new filename
-		err := buf.SaveAs(filename)
-		if err != nil {
-			return CommandResult{
-				Success: false,
-				Message: fmt.Sprintf("Error writing file: %s", err.Error()),
-			}
+	if buf.Options().ReadOnly {
+		return CommandResult{
+			Success: false,
+			Message: "'readonly' option is set (use :set noreadonly to write)",
 		}
-	} else {
-		// Save to current filename
-		err := buf.Save()
-		if err != nil {
-			return CommandResult{
-				Success: false,
-				Message: fmt.Sprintf("Error writing file: %s", err.Error()),
-			}
+	}
+	
+	partial := r != wholeBuffer(buf)
+	ownFile := sameFile(filename, buf.Filename())
+	if !partial && !appendTo && (ownFile || buf.Filename() == "") {
+		return saveBuffer(cc, filename, buf)
+	}
+	if partial && ownFile && !appendTo {
+		return CommandResult{
+			Success: false,
+			Message: "Writing part of the buffer would replace the rest of its file (give another file name or use >>)",
 		}
 	}
 	
+	if err := buf.WriteLines(filename, r.Start, r.End, appendTo); err != nil {
+		return CommandResult{
+			Success: false,
+			Message: fmt.Sprintf("Error writing file: %s%s", err.Error(), permissionHint(err)),
+		}
+	}
+	verb := "written to"
+	if appendTo {
+		verb = "appended to"
+	}
 	return CommandResult{
 		Success: true,
-		Message: fmt.Sprintf("File written: %s", filename),
+		Message: fmt.Sprintf("%d lines %s %s%s", r.End-r.Start+1, verb, filename, writeWarnings(buf)),
 	}
 }
 
 func (w *WriteCommand) Help() string {
-	return ":w [filename] - Write buffer to file"
+	return ":[range]w [>>] [filename] - Write buffer, or the lines in range, to its file or a copy; >> appends"
+}
+
+// saveBuffer saves the buffer to filename, which becomes its file
+func saveBuffer(cc *CommandContext, filename string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	// Imports are organized first when configured, without keeping the
+	// file from being written
+	var note string
+	if s.organizeImportsOnSave {
+		if err := organizeImports(cc, buf); err != nil && !errors.Is(err, errNoImportOrganizer) {
+			note = fmt.Sprintf(" (organize imports failed: %v)", err)
+		}
+	}
+	
+	if err := buf.SaveAs(filename); err != nil {
+		return CommandResult{
+			Success: false,
+			Message: fmt.Sprintf("Error writing file: %s%s", err.Error(), permissionHint(err)),
+		}
+	}
+	
+	return CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("File written: %s%s%s", filename, writeWarnings(buf), note),
+	}
+}
+
+// writeWarnings notes what went wrong in the last write of buf that still
+// wrote the file
+func writeWarnings(buf *buffer.Buffer) string {
+	warnings := buf.WriteWarnings()
+	if len(warnings) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(warnings, "; ") + ")"
+}
+
+// SaveAsCommand implements :saveas, which writes the buffer to another
+// file and edits that file from then on
+type SaveAsCommand struct{}
+
+// NewSaveAsCommand creates the :saveas command
+func NewSaveAsCommand() *SaveAsCommand {
+	return &SaveAsCommand{}
+}
+
+func (c *SaveAsCommand) Name() string {
+	return "saveas"
+}
+
+func (c *SaveAsCommand) Aliases() []string {
+	return []string{"sav"}
+}
+
+func (c *SaveAsCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if len(args) != 1 {
+		return CommandResult{Success: false, Message: "Usage: :saveas {file}"}
+	}
+	if buf.Options().ReadOnly {
+		return CommandResult{
+			Success: false,
+			Message: "'readonly' option is set (use :set noreadonly to write)",
+		}
+	}
+	return saveBuffer(cc, resolvePath(buf, args[0]), buf)
+}
+
+func (c *SaveAsCommand) Help() string {
+	return ":saveas {file} - Write the buffer to file and edit it from then on"
 }
 
 // QuitCommand implements the :q (quit) command
@@ -87,24 +189,13 @@ func (q *QuitCommand) Aliases() []string {
 	return []string{"q"}
 }
 
-func (q *QuitCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	// Check if buffer has unsaved changes
-	if buf.Modified() {
-		return CommandResult{
-			Success: false,
-			Message: "Buffer has unsaved changes. Use :q! to force quit or :wq to save and quit",
-		}
-	}
-	
-	return CommandResult{
-		Success:    true,
-		Message:    "Goodbye!",
-		ExitEditor: true,
-	}
+func (q *QuitCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	// Unsaved changes are listed instead
+	return quitAll(openBuffers(cc, buf))
 }
 
 func (q *QuitCommand) Help() string {
-	return ":q - Quit editor (fails if unsaved changes)"
+	return ":q - Quit editor, or list the modified buffers when there are any"
 }
 
 // ForceQuitCommand implements the :q! (force quit) command
@@ -123,7 +214,7 @@ func (fq *ForceQuitCommand) Aliases() []string {
 	return []string{"q!"}
 }
 
-func (fq *ForceQuitCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
+func (fq *ForceQuitCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
 	return CommandResult{
 		Success:    true,
 		Message:    "Goodbye!",
@@ -151,10 +242,10 @@ func (wq *WriteQuitCommand) Aliases() []string {
 	return []string{"x", "exit"}
 }
 
-func (wq *WriteQuitCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
+func (wq *WriteQuitCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
 	// First try to write
 	writeCmd := NewWriteCommand()
-	writeResult := writeCmd.Execute(args, buf)
+	writeResult := writeCmd.Execute(cc, args, buf)
 	
 	if !writeResult.Success {
 		return writeResult
@@ -188,7 +279,7 @@ func (e *EditCommand) Aliases() []string {
 	return []string{"e"}
 }
 
-func (e *EditCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
+func (e *EditCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
 	if len(args) == 0 {
 		// Reload current file
 		filename := buf.Filename()
@@ -214,7 +305,7 @@ func (e *EditCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
 		}
 	}
 	
-	filename := args[0]
+	filename := resolvePath(buf, args[0])
 	
 	// Check if buffer has unsaved changes
 	if buf.Modified() {
@@ -260,7 +351,7 @@ func (n *NewCommand) Aliases() []string {
 	return []string{"enew"}
 }
 
-func (n *NewCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
+func (n *NewCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
 	// Check if buffer has unsaved changes
 	if buf.Modified() {
 		return CommandResult{
@@ -279,4 +370,92 @@ func (n *NewCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
 
 func (n *NewCommand) Help() string {
 	return ":new - Create new empty buffer"
-}
\ No newline at end of file
+}
+
+// RenameFileCommand implements :rename-file, which renames or moves the
+// file being edited and lets its language server fix what refers to it
+type RenameFileCommand struct{}
+
+// NewRenameFileCommand creates a new rename-file command
+func NewRenameFileCommand() *RenameFileCommand {
+	return &RenameFileCommand{}
+}
+
+func (r *RenameFileCommand) Name() string {
+	return "rename-file"
+}
+
+func (r *RenameFileCommand) Aliases() []string {
+	return []string{"move-file"}
+}
+
+func (r *RenameFileCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if len(args) != 1 {
+		return CommandResult{Success: false, Message: "Usage: :rename-file <new-name>"}
+	}
+	oldName := buf.Filename()
+	if oldName == "" {
+		return CommandResult{Success: false, Message: "No file associated with buffer"}
+	}
+	if _, err := os.Stat(oldName); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("%s isn't on disk (use :w %s)", oldName, args[0])}
+	}
+
+	// Like mv, a directory keeps the file's name
+	newName := resolvePath(buf, args[0])
+	if info, err := os.Stat(newName); err == nil && info.IsDir() {
+		newName = filepath.Join(newName, filepath.Base(oldName))
+	}
+	if sameFile(oldName, newName) {
+		return CommandResult{Success: false, Message: fmt.Sprintf("%s is already called that", oldName)}
+	}
+	if _, err := os.Stat(newName); err == nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("%s already exists", newName)}
+	}
+
+	// The server's edits, such as fixed imports, apply before the rename
+	ctx := context.Background()
+	var changed []string
+	var serverErr error
+	serving := lspServes(cc, oldName)
+	if serving {
+		edit, err := cc.LSP.WillRenameFile(ctx, oldName, newName)
+		if err != nil {
+			serverErr = err
+		} else if changed, err = applyWorkspaceEdit(edit, buf); err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Failed to apply the language server's edits: %v", err)}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newName), 0755); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error renaming file: %v", err)}
+	}
+	if err := os.Rename(oldName, newName); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error renaming file: %v", err)}
+	}
+	buf.SetFilename(newName)
+	if serving {
+		if err := cc.LSP.DidRenameFile(ctx, oldName, newName, buf.String()); err != nil && serverErr == nil {
+			serverErr = err
+		}
+	}
+
+	message := fmt.Sprintf("Renamed %s to %s", oldName, newName)
+	var others []string
+	for _, filename := range changed {
+		if !sameFile(filename, oldName) {
+			others = append(others, displayPath(filename))
+		}
+	}
+	if len(others) > 0 {
+		message += fmt.Sprintf(", updated %s", strings.Join(others, ", "))
+	}
+	if serverErr != nil {
+		message += fmt.Sprintf(" (language server: %v)", serverErr)
+	}
+	return CommandResult{Success: true, Message: message}
+}
+
+func (r *RenameFileCommand) Help() string {
+	return ":rename-file <new-name> - Rename or move the file, updating what refers to it"
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func TestRenameFileCommand(t *testing.T) {
+	dir := t.TempDir()
+	oldName := filepath.Join(dir, "old.txt")
+	os.WriteFile(oldName, []byte("hello\n"), 0644)
+	buf, err := buffer.NewFromFile(oldName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := NewCommandExecutor()
+
+	newName := filepath.Join(dir, "sub", "new.txt")
+	result := executor.Execute("rename-file "+newName, buf)
+	if !result.Success || !strings.HasPrefix(result.Message, "Renamed ") {
+		t.Fatalf("rename-file = %+v", result)
+	}
+	if buf.Filename() != newName {
+		t.Errorf("buffer filename = %q", buf.Filename())
+	}
+	if _, err := os.Stat(oldName); !os.IsNotExist(err) {
+		t.Error("expected the old file to be gone")
+	}
+	if data, _ := os.ReadFile(newName); string(data) != "hello\n" {
+		t.Errorf("new file = %q", data)
+	}
+
+	// A directory keeps the name, and an existing file isn't overwritten
+	if result := executor.Execute("rename-file "+dir, buf); !result.Success || buf.Filename() != filepath.Join(dir, "new.txt") {
+		t.Errorf("rename-file into a directory = %+v, filename %q", result, buf.Filename())
+	}
+	os.WriteFile(oldName, []byte("taken\n"), 0644)
+	if result := executor.Execute("rename-file "+oldName, buf); result.Success || !strings.Contains(result.Message, "already exists") {
+		t.Errorf("rename-file onto an existing file = %+v", result)
+	}
+
+	if result := executor.Execute("rename-file "+oldName, buffer.New()); result.Success {
+		t.Error("expected a buffer without a file to fail")
+	}
+}
+
+func TestApplyWorkspaceEdit(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "main.go")
+	other := filepath.Join(dir, "other.go")
+	os.WriteFile(current, []byte("import \"old\"\n"), 0644)
+	os.WriteFile(other, []byte("import \"old\"\r\n"), 0600)
+	buf, err := buffer.NewFromFile(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fix := []protocol.TextEdit{{
+		Range:   protocol.Range{Start: protocol.Position{Line: 0, Character: 8}, End: protocol.Position{Line: 0, Character: 11}},
+		NewText: "new",
+	}}
+	changed, err := applyWorkspaceEdit(&protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			uri.File(current): fix,
+			uri.File(other):   fix,
+		},
+	}, buf)
+	if err != nil || len(changed) != 2 {
+		t.Fatalf("applyWorkspaceEdit() = %v, %v", changed, err)
+	}
+	if got := buf.Lines()[0]; got != "import \"new\"" || !buf.Modified() {
+		t.Errorf("buffer line = %q, modified %v", got, buf.Modified())
+	}
+	if data, _ := os.ReadFile(current); string(data) != "import \"old\"\n" {
+		t.Errorf("expected the file being edited to be changed in the buffer only, got %q", data)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "import \"new\"\r\n" {
+		t.Errorf("other file = %q", data)
+	}
+	if info, _ := os.Stat(other); info.Mode().Perm() != 0600 {
+		t.Errorf("other file mode = %v", info.Mode().Perm())
+	}
+}
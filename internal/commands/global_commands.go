@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/search"
+)
+
+// GlobalCommand implements :g/pattern/cmd, running an ex command on every
+// line matching pattern, and :v, or :g!, on every line that doesn't
+type GlobalCommand struct {
+	name     string
+	aliases  []string
+	invert   bool
+	executor *CommandExecutor // Runs the command on each line
+	running  bool
+}
+
+// NewGlobalCommands creates :global and :vglobal for executor
+func NewGlobalCommands(executor *CommandExecutor) []Command {
+	return []Command{
+		&GlobalCommand{name: "global", aliases: []string{"g"}, executor: executor},
+		&GlobalCommand{name: "vglobal", aliases: []string{"v"}, invert: true, executor: executor},
+	}
+}
+
+func (g *GlobalCommand) Name() string {
+	return g.name
+}
+
+func (g *GlobalCommand) Aliases() []string {
+	return g.aliases
+}
+
+func (g *GlobalCommand) textArgument() {}
+
+func (g *GlobalCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return g.ExecuteRange(cc, wholeBuffer(buf), args, buf)
+}
+
+func (g *GlobalCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	text := strings.Join(args, " ")
+	invert := g.invert
+	if !invert {
+		text, invert = strings.CutPrefix(text, "!")
+	}
+	if text == "" {
+		return CommandResult{Success: false, Message: "Usage: :g/pattern/command"}
+	}
+	delim := text[0]
+	if !validDelimiter(delim) {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid delimiter: %c", delim)}
+	}
+	pattern, cmdLine := cutDelimited(text[1:], delim)
+	if pattern == "" {
+		return CommandResult{Success: false, Message: "Empty search pattern"}
+	}
+	if strings.TrimSpace(cmdLine) == "" {
+		return CommandResult{Success: false, Message: "Missing command after :" + g.name + "/" + pattern + "/"}
+	}
+	re, err := search.Compile(pattern)
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid pattern: %v", err)}
+	}
+	search.SetLast(pattern, false)
+	if g.running {
+		return CommandResult{Success: false, Message: "Cannot use :global recursively"}
+	}
+
+	// Mark the lines first, so that the command sees them as they are
+	// after the changes made on earlier lines
+	var marks []int
+	visual := search.VisualOnly(pattern)
+	for i, line := range buf.Lines()[r.Start : r.End+1] {
+		if matchesLine(re, visual, buf, r.Start+i, line) != invert {
+			marks = append(marks, r.Start+i)
+		}
+	}
+	if len(marks) == 0 {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Pattern not found: %s", pattern)}
+	}
+
+	g.running = true
+	defer func() { g.running = false }()
+
+	var failed CommandResult
+	succeeded := false
+	for len(marks) > 0 {
+		line := marks[0]
+		before := buf.Lines()
+		buf.SetCursor(buffer.Position{Line: line})
+		result := g.executor.run(cmdLine, buf)
+		if result.ExitEditor {
+			return result
+		}
+		// Like Vim, keep going after failures: :g/x/s/y/z/ fails on the
+		// lines without y
+		if result.Success {
+			succeeded = true
+		} else if failed.Message == "" {
+			failed = result
+		}
+		marks = remapMarks(before, buf.Lines(), line, marks[1:])
+	}
+
+	if !succeeded {
+		return failed
+	}
+	return CommandResult{Success: true}
+}
+
+func (g *GlobalCommand) Help() string {
+	if g.invert {
+		return ":[range]v/pattern/command - Run an ex command on every line not matching pattern, the whole buffer by default"
+	}
+	return ":[range]g[!]/pattern/command - Run an ex command, like d or s/x/y/, on every line matching pattern, the whole buffer by default"
+}
+
+// remapMarks moves the marked lines of old to where they are in new after
+// a command ran on line. Lines before and after the changed region keep
+// their place, and marks inside it are found again by their text, in
+// order, which follows lines moved like :m$ does. Marks on removed lines
+// are dropped.
+func remapMarks(old, new []string, line int, marks []int) []int {
+	prefix := 0
+	for prefix < line && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	oldEnd, newEnd := len(old)-suffix, len(new)-suffix
+
+	var remapped []int
+	next := prefix
+	for _, m := range marks {
+		switch {
+		case m < prefix:
+			remapped = append(remapped, m)
+		case m >= oldEnd:
+			remapped = append(remapped, m+newEnd-oldEnd)
+		default:
+			for j := next; j < newEnd; j++ {
+				if new[j] == old[m] {
+					remapped = append(remapped, j)
+					next = j + 1
+					break
+				}
+			}
+		}
+	}
+	return remapped
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestGlobal(t *testing.T) {
+	lines := []string{"// a", "x := 1", "// b", "// b", "y := 2"}
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"g/^\\/\\//d", "x := 1\ny := 2"},
+		{"g!/^\\/\\//d", "// a\n// b\n// b"},
+		{"v/:=/d", "x := 1\ny := 2"},
+		{"g/b/.,+1d", "// a\nx := 1\ny := 2"},
+		{"g/:=/s/(\\w) := (\\d)/\\1 = \\2/", "// a\nx = 1\n// b\n// b\ny = 2"},
+		{"g/=/s/1/one/", "// a\nx := one\n// b\n// b\ny := 2"},
+		{"2,3g/\\//d", "// a\nx := 1\n// b\ny := 2"},
+		{"g/:=/>", "// a\n    x := 1\n// b\n// b\n    y := 2"},
+		{"g/^/m0", "y := 2\n// b\n// b\nx := 1\n// a"},
+		{"g/^\\/\\//m$", "x := 1\ny := 2\n// a\n// b\n// b"},
+	}
+
+	for _, tt := range tests {
+		buf := buffer.New()
+		buf.ReplaceLines(0, 0, lines)
+		result := NewCommandExecutor().Execute(tt.command, buf)
+		if !result.Success {
+			t.Errorf(":%s failed: %s", tt.command, result.Message)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf(":%s left %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestGlobal_Errors(t *testing.T) {
+	for _, command := range []string{"g/none/d", "g/x/", "g//d", "g/x/g/y/d", "g/x/nosuch"} {
+		buf := buffer.New()
+		buf.ReplaceLines(0, 0, []string{"x", "y"})
+		if result := NewCommandExecutor().Execute(command, buf); result.Success {
+			t.Errorf(":%s succeeded", command)
+		}
+	}
+}
+
+func TestRemapMarks(t *testing.T) {
+	old := []string{"x", "x", "x", "y"}
+	// :d on line 0 of identical lines shifts the marks after it up
+	if got := remapMarks(old, []string{"x", "x", "y"}, 0, []int{1, 2}); len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Errorf("after deleting line 0 marks = %v, want [0 1]", got)
+	}
+	// Marks on deleted lines are dropped
+	if got := remapMarks(old, []string{"y"}, 0, []int{1, 2, 3}); len(got) != 1 || got[0] != 0 {
+		t.Errorf("after deleting lines 0-2 marks = %v, want [0]", got)
+	}
+}
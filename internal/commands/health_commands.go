@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/health"
+)
+
+// CheckHealthCommand checks the config, language servers, AI providers,
+// terminal and clipboard, and shows the report in the explanation panel
+type CheckHealthCommand struct{}
+
+func NewCheckHealthCommand() *CheckHealthCommand {
+	return &CheckHealthCommand{}
+}
+
+func (c *CheckHealthCommand) Name() string {
+	return "checkhealth"
+}
+
+func (c *CheckHealthCommand) Aliases() []string {
+	return []string{"checkh"}
+}
+
+func (c *CheckHealthCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	cfg := cc.currentConfig()
+
+	sections := []health.Section{
+		health.ConfigFile(config.ConfigPaths()),
+		health.Settings(cfg),
+		health.LanguageServers(cfg.LSP),
+		providerHealth(cc),
+		health.Terminal(),
+		health.Clipboard(),
+	}
+
+	failures := 0
+	for _, s := range sections {
+		for _, r := range s.Results {
+			if r.Status == health.Fail {
+				failures++
+			}
+		}
+	}
+	show := []Action{ShowText{Title: "[Health]", Text: health.Report(sections)}}
+	if failures > 0 {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Health check found %d failures", failures), Actions: show}
+	}
+	return CommandResult{Success: true, Message: "Health check passed", Actions: show}
+}
+
+func (c *CheckHealthCommand) Help() string {
+	return ":checkhealth - Check the config, language servers, AI providers, terminal and clipboard"
+}
+
+// providerHealth pings the configured AI providers
+func providerHealth(cc *CommandContext) health.Section {
+	if cc.AI == nil {
+		return health.Providers(nil, "")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var active ai.ProviderType
+	if p := cc.AI.GetActiveProvider(); p != nil {
+		active = p.Name()
+	}
+	return health.Providers(cc.AI.Ping(ctx), active)
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestCheckHealth(t *testing.T) {
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(ai.NewMockProvider(ai.ProviderOllama))
+
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+	executor.Execute("checkhealth", buffer.New())
+
+	title, lines, open := executor.State().ExplanationPanel(200)
+	if !open || !strings.HasPrefix(title, "[Health]") {
+		t.Fatalf("health panel title = %q, open %v", title, open)
+	}
+	report := strings.Join(lines, "\n")
+	for _, want := range []string{"Configuration", "Language servers", "[OK] ollama (active): reachable", "Terminal", "Clipboard"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/help"
+)
+
+// KeyHelp describes a key of a mode for :help
+type KeyHelp struct {
+	Mode        string
+	Keys        string
+	Description string
+}
+
+// SetKeyHelp sets the function listing the keys of the modes for :help
+func (s *State) SetKeyHelp(keys func() []KeyHelp) {
+	s.keyHelp = keys
+}
+
+// HelpView returns the help being read, or nil when it is closed
+func (s *State) HelpView() *help.View {
+	return s.helpView
+}
+
+// CloseHelp closes the help
+func (s *State) CloseHelp() {
+	s.helpView = nil
+}
+
+// HelpCommand opens the help at a topic
+type HelpCommand struct {
+	registry *CommandRegistry // Commands described by the help
+}
+
+// NewHelpCommand creates a :help command describing the commands of registry
+func NewHelpCommand(registry *CommandRegistry) *HelpCommand {
+	return &HelpCommand{registry: registry}
+}
+
+func (c *HelpCommand) Name() string {
+	return "help"
+}
+
+func (c *HelpCommand) Aliases() []string {
+	return []string{"h"}
+}
+
+func (c *HelpCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	view := help.NewView(helpDoc(c.registry, s.keyHelp))
+	if topic := strings.Join(args, " "); topic != "" && !view.Jump(topic) {
+		return CommandResult{Success: false, Message: fmt.Sprintf("No help for %s", topic)}
+	}
+	s.helpView = view
+	return CommandResult{Success: true}
+}
+
+func (c *HelpCommand) Help() string {
+	return ":help [topic] - Open the help at a command, key, config option or any text"
+}
+
+// helpDoc generates the help for the commands of registry, the keys of the
+// modes keys lists, the features and the config options
+func helpDoc(registry *CommandRegistry, keys func() []KeyHelp) *help.Doc {
+	sections := []help.Section{
+		commandHelp(registry),
+		keysHelp(keys),
+		help.AISection,
+		help.LSPSection,
+		help.ConfigSection("config", "Configuration", config.DefaultConfig()),
+	}
+	return help.New(append([]help.Section{help.Intro(sections)}, sections...))
+}
+
+// commandHelp describes every command, tagged by its name and aliases
+func commandHelp(registry *CommandRegistry) help.Section {
+	names := registry.ListCommands()
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+
+	var lines []string
+	for _, name := range names {
+		cmd, _ := registry.GetCommand(name)
+		tags := "*:" + name + "*"
+		for _, alias := range cmd.Aliases() {
+			tags += " *:" + alias + "*"
+		}
+		lines = append(lines, fmt.Sprintf(":%s%s%s", name, strings.Repeat(" ", max(2, 60-len(name)-1-len(tags))), tags))
+		lines = append(lines, "    "+cmd.Help(), "")
+	}
+	return help.Section{Tag: "commands", Title: "Commands", Lines: lines}
+}
+
+// keysHelp describes the keys of the modes keys lists, grouped by mode
+func keysHelp(keys func() []KeyHelp) help.Section {
+	section := help.Section{Tag: "keys", Title: "Keys"}
+	if keys == nil {
+		return section
+	}
+	mode := ""
+	for _, k := range keys() {
+		if k.Mode != mode {
+			mode = k.Mode
+			tag := "*" + strings.ToLower(strings.ReplaceAll(mode, " ", "-")) + "*"
+			section.Lines = append(section.Lines, "", mode+strings.Repeat(" ", max(2, 60-len(mode)-len(tag)))+tag)
+		}
+		section.Lines = append(section.Lines, fmt.Sprintf("  %-14s %s", k.Keys, k.Description))
+	}
+	return section
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestHelpCommand(t *testing.T) {
+	executor := NewCommandExecutor()
+	executor.State().SetKeyHelp(func() []KeyHelp {
+		return []KeyHelp{{Mode: "Normal mode", Keys: "gg", Description: "Go to the first line"}}
+	})
+	buf := buffer.New()
+
+	if result := executor.Execute("help", buf); !result.Success || executor.State().HelpView() == nil {
+		t.Fatalf(":help = %+v", result)
+	}
+	view := executor.State().HelpView()
+	if view.Line() != 0 {
+		t.Errorf(":help opened at line %d, want the start", view.Line())
+	}
+	text := strings.Join(view.Doc().Lines(), "\n")
+	for _, want := range []string{"*:write* *:w*", ":help [topic]", "Go to the first line", "*config-editor*", "*lsp*"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("help is missing %q", want)
+		}
+	}
+
+	executor.Execute("h w", buf)
+	view = executor.State().HelpView()
+	if line := view.Doc().Lines()[view.Line()]; !strings.HasPrefix(line, ":write") {
+		t.Errorf(":h w opened at %q", line)
+	}
+
+	if result := executor.Execute("help no-such-topic-anywhere", buf); result.Success {
+		t.Error(":help with an unknown topic succeeded")
+	}
+	if executor.State().HelpView() != view {
+		t.Error("unknown topic replaced the open help")
+	}
+
+	executor.State().CloseHelp()
+	if executor.State().HelpView() != nil {
+		t.Error("CloseHelp left the help open")
+	}
+}
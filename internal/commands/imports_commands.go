@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// goimportsTool organizes the imports of Go files without a language server
+var goimportsTool = "goimports"
+
+// errNoImportOrganizer is returned for files nothing can organize the
+// imports of
+var errNoImportOrganizer = errors.New("no language server or goimports for this file")
+
+// SetOrganizeImportsOnSave sets whether :w organizes imports first
+func (s *State) SetOrganizeImportsOnSave(on bool) {
+	s.organizeImportsOnSave = on
+}
+
+// OrganizeImportsCommand implements :organize-imports, which sorts the
+// imports of the buffer and adds and removes them as the code needs
+type OrganizeImportsCommand struct{}
+
+// NewOrganizeImportsCommand creates a new organize-imports command
+func NewOrganizeImportsCommand() *OrganizeImportsCommand {
+	return &OrganizeImportsCommand{}
+}
+
+func (o *OrganizeImportsCommand) Name() string {
+	return "organize-imports"
+}
+
+func (o *OrganizeImportsCommand) Aliases() []string {
+	return []string{"OrganizeImports"}
+}
+
+func (o *OrganizeImportsCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if buf.Options().ReadOnly {
+		return CommandResult{Success: false, Message: "'readonly' option is set (use :set noreadonly to change it)"}
+	}
+	before := buf.String()
+	if err := organizeImports(cc, buf); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Organize imports failed: %v", err)}
+	}
+	if buf.String() == before {
+		return CommandResult{Success: true, Message: "Imports already organized"}
+	}
+	return CommandResult{Success: true, Message: "Imports organized"}
+}
+
+func (o *OrganizeImportsCommand) Help() string {
+	return ":organize-imports - Sort, add and remove imports with the language server (or goimports for Go)"
+}
+
+// organizeImports applies the language server's source.organizeImports
+// code action to the buffer, or runs goimports over Go files the server
+// can't help with
+func organizeImports(cc *CommandContext, buf *buffer.Buffer) error {
+	filename := buf.Filename()
+	var serverErr error
+	if lspServes(cc, filename) {
+		edit, err := cc.LSP.OrganizeImports(context.Background(), filename, buf.String())
+		if err == nil {
+			_, err = applyWorkspaceEdit(edit, buf)
+			return err
+		}
+		serverErr = err
+	}
+
+	if buf.Filetype() == "go" {
+		return runGoimports(buf)
+	}
+	if serverErr != nil {
+		return serverErr
+	}
+	return errNoImportOrganizer
+}
+
+// runGoimports replaces the buffer with its text as goimports prints it
+func runGoimports(buf *buffer.Buffer) error {
+	path, err := exec.LookPath(goimportsTool)
+	if err != nil {
+		return fmt.Errorf("%s isn't installed (go install golang.org/x/tools/cmd/goimports@latest)", goimportsTool)
+	}
+
+	// Imports from the file's own module are found from its directory
+	text := buf.String()
+	args := []string{}
+	if filename := buf.Filename(); filename != "" {
+		if abs, err := filepath.Abs(filename); err == nil {
+			args = append(args, "-srcdir", filepath.Dir(abs))
+		}
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("goimports: %s", strings.SplitN(message, "\n", 2)[0])
+		}
+		return fmt.Errorf("goimports: %v", err)
+	}
+	return setBufferText(buf, strings.TrimSuffix(stdout.String(), "\n"))
+}
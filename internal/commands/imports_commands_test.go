@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// fakeGoimports stands in for goimports with a script that adds an import
+// of fmt and records its arguments
+func fakeGoimports(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "goimports")
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\nawk '{ print } /^package main$/ { print \"\"; print \"import \\\"fmt\\\"\" }'\n"
+	if err := os.WriteFile(tool, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldTool := goimportsTool
+	t.Cleanup(func() { goimportsTool = oldTool })
+	goimportsTool = tool
+	return dir
+}
+
+func TestOrganizeImportsCommand_Goimports(t *testing.T) {
+	toolDir := fakeGoimports(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "main.go")
+	os.WriteFile(filename, []byte("package main\n\nfunc main() { fmt.Println() }\n"), 0644)
+	buf, err := buffer.NewFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := NewCommandExecutor()
+
+	result := executor.Execute("organize-imports", buf)
+	if !result.Success || result.Message != "Imports organized" {
+		t.Fatalf("organize-imports = %+v", result)
+	}
+	if got := strings.Join(buf.Lines(), "|"); got != "package main||import \"fmt\"||func main() { fmt.Println() }" {
+		t.Errorf("buffer = %q", got)
+	}
+	if args, _ := os.ReadFile(filepath.Join(toolDir, "args")); !strings.Contains(string(args), "-srcdir "+dir) {
+		t.Errorf("goimports args = %q", args)
+	}
+
+	// Running it again changes nothing
+	os.WriteFile(filepath.Join(toolDir, "goimports"), []byte("#!/bin/sh\ncat\n"), 0755)
+	if result := executor.Execute("organize-imports", buf); result.Message != "Imports already organized" {
+		t.Errorf("second organize-imports = %+v", result)
+	}
+
+	text := buffer.New()
+	text.SetFilename(filepath.Join(dir, "notes.txt"))
+	if result := executor.Execute("organize-imports", text); result.Success {
+		t.Error("expected a file nothing organizes to fail")
+	}
+}
+
+func TestWriteCommand_OrganizesImportsOnSave(t *testing.T) {
+	fakeGoimports(t)
+	dir := t.TempDir()
+	executor := NewCommandExecutor()
+	executor.State().SetOrganizeImportsOnSave(true)
+
+	filename := filepath.Join(dir, "main.go")
+	os.WriteFile(filename, []byte("package main\n"), 0644)
+	buf, err := buffer.NewFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := executor.Execute("w", buf); !result.Success || result.Message != "File written: "+filename {
+		t.Fatalf("w = %+v", result)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "package main\n\nimport \"fmt\"" {
+		t.Errorf("written file = %q", data)
+	}
+
+	// Files nothing organizes are written as they are
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notes, []byte("package main\n"), 0644)
+	buf, _ = buffer.NewFromFile(notes)
+	if result := executor.Execute("w", buf); !result.Success || result.Message != "File written: "+notes {
+		t.Errorf("w = %+v", result)
+	}
+}
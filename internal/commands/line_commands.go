@@ -0,0 +1,311 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/registers"
+)
+
+// SetRegisters sets the register store shared with the editing modes
+func (s *State) SetRegisters(store *registers.Store) {
+	s.registerStore = store
+}
+
+// lineCommand is an ex command working on a range of lines, the cursor
+// line when no range is given
+type lineCommand struct {
+	name    string
+	aliases []string
+	help    string
+	run     func(s *State, r Range, args []string, buf *buffer.Buffer) CommandResult
+}
+
+func (c *lineCommand) Name() string {
+	return c.name
+}
+
+func (c *lineCommand) Aliases() []string {
+	return c.aliases
+}
+
+func (c *lineCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(cc.State, currentLine(buf), args, buf)
+}
+
+func (c *lineCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(cc.State, r, args, buf)
+}
+
+func (c *lineCommand) Help() string {
+	return c.help
+}
+
+// wholeBufferCommand is a line command working on the whole buffer when no
+// range is given, like :sort
+type wholeBufferCommand struct {
+	lineCommand
+}
+
+func (c *wholeBufferCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(cc.State, wholeBuffer(buf), args, buf)
+}
+
+// NewLineCommands creates :delete, :yank, :>, :<, :join, :move, :copy,
+// :sort, :reverse and :uniq
+func NewLineCommands() []Command {
+	return []Command{
+		&lineCommand{"delete", []string{"d", "de", "del"}, ":[range]d [x] [count] - Delete lines into register x", (*State).deleteLines},
+		&lineCommand{"yank", []string{"y", "ya"}, ":[range]y [x] [count] - Yank lines into register x", (*State).yankLines},
+		&lineCommand{">", nil, ":[range]> [count] - Shift lines right by shiftwidth, once per >", shiftLines(1)},
+		&lineCommand{"<", nil, ":[range]< [count] - Shift lines left by shiftwidth, once per <", shiftLines(-1)},
+		&lineCommand{"join", []string{"j"}, ":[range]j [count] - Join lines, the line and the next without a range", joinLines},
+		&lineCommand{"move", []string{"m", "mo"}, ":[range]m {address} - Move lines below the address line; 0 moves them to the top", moveLines},
+		&lineCommand{"copy", []string{"t", "co"}, ":[range]t {address} - Copy lines below the address line; 0 copies them to the top", copyLines},
+		&wholeBufferCommand{lineCommand{"sort", []string{"sor"}, ":[range]sort[!] [n][i][u] - Sort lines, the whole buffer without a range; ! reverses, n sorts by the first number, i ignores case, u drops duplicates", sortLines}},
+		&wholeBufferCommand{lineCommand{"reverse", []string{"rev"}, ":[range]reverse - Reverse the order of lines, the whole buffer without a range", reverseLines}},
+		&wholeBufferCommand{lineCommand{"uniq", []string{"uni"}, ":[range]uniq [i] - Remove lines repeating the line before them, the whole buffer without a range; i ignores case", uniqLines}},
+	}
+}
+
+// registerAndCount parses the optional register name and count that
+// follow :d and :y, extending r to count lines from its last line
+func registerAndCount(r Range, args []string, buf *buffer.Buffer) (Range, rune, error) {
+	var register rune
+	if len(args) > 0 {
+		if name := []rune(args[0]); len(name) == 1 && registers.IsValid(name[0]) && (name[0] < '0' || name[0] > '9') {
+			register = name[0]
+			args = args[1:]
+		}
+	}
+	r, err := withCount(r, args, buf)
+	return r, register, err
+}
+
+// withCount applies a trailing count argument: count lines starting at the
+// last line of r
+func withCount(r Range, args []string, buf *buffer.Buffer) (Range, error) {
+	if len(args) == 0 {
+		return r, nil
+	}
+	count, err := strconv.Atoi(args[0])
+	if err != nil || count <= 0 || len(args) > 1 {
+		return r, fmt.Errorf("trailing characters: %s", strings.Join(args, " "))
+	}
+	return Range{Start: r.End, End: min(r.End+count-1, buf.LineCount()-1)}, nil
+}
+
+// deleteLines implements :d
+func (s *State) deleteLines(r Range, args []string, buf *buffer.Buffer) CommandResult {
+	r, register, err := registerAndCount(r, args, buf)
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	lines := buf.Lines()[r.Start : r.End+1]
+	s.registerStore.Delete(register, strings.Join(lines, "\n"), true)
+	buf.ReplaceLines(r.Start, r.End, nil)
+	buf.SetCursor(buffer.Position{Line: r.Start})
+	return CommandResult{Success: true, Message: linesMessage(len(lines), "deleted")}
+}
+
+// yankLines implements :y
+func (s *State) yankLines(r Range, args []string, buf *buffer.Buffer) CommandResult {
+	r, register, err := registerAndCount(r, args, buf)
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	lines := buf.Lines()[r.Start : r.End+1]
+	s.registerStore.Yank(register, strings.Join(lines, "\n"), true)
+	return CommandResult{Success: true, Message: linesMessage(len(lines), "yanked")}
+}
+
+// shiftLines implements :> and :<. The command name may repeat, like
+// :>>>, to shift several times; the extra characters arrive as an argument.
+func shiftLines(direction int) func(s *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	return func(_ *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+		times := 1
+		if len(args) > 0 && strings.Trim(args[0], "<>") == "" {
+			times += len(args[0])
+			args = args[1:]
+		}
+		r, err := withCount(r, args, buf)
+		if err != nil {
+			return CommandResult{Success: false, Message: capitalize(err.Error())}
+		}
+
+		options := buf.Options()
+		lines := buf.Lines()[r.Start : r.End+1]
+		for i, line := range lines {
+			lines[i] = shiftLine(line, direction*times, options)
+		}
+		buf.ReplaceLines(r.Start, r.End, lines)
+		last := lines[len(lines)-1]
+		buf.SetCursor(buffer.Position{Line: r.End, Col: len(last) - len(strings.TrimLeft(last, " \t"))})
+		return CommandResult{Success: true, Message: linesMessage(len(lines), "shifted")}
+	}
+}
+
+// shiftLine changes the indent of line by shifts shiftwidths, rebuilding
+// the indent with tabs or spaces as the buffer's options say. Empty lines
+// are left alone.
+func shiftLine(line string, shifts int, options buffer.Options) string {
+	body := strings.TrimLeft(line, " \t")
+	if body == "" {
+		return line
+	}
+
+	tabWidth := options.TabWidth()
+	width := 0
+	for _, ch := range line[:len(line)-len(body)] {
+		if ch == '\t' {
+			width += tabWidth - width%tabWidth
+		} else {
+			width++
+		}
+	}
+	width = max(width+shifts*options.IndentWidth(), 0)
+
+	indent := strings.Repeat(" ", width)
+	if options.IndentTabs {
+		indent = strings.Repeat("\t", width/tabWidth) + strings.Repeat(" ", width%tabWidth)
+	}
+	return indent + body
+}
+
+// joinLines implements :j, joining with a single space and dropping the
+// leading whitespace of the joined lines
+func joinLines(_ *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	r, err := withCount(r, args, buf)
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	if r.Start == r.End {
+		r.End++
+	}
+	if r.End >= buf.LineCount() {
+		return CommandResult{Success: false, Message: "Cannot join the last line"}
+	}
+
+	lines := buf.Lines()[r.Start : r.End+1]
+	joined := strings.TrimRight(lines[0], " \t")
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if joined != "" && line != "" {
+			joined += " "
+		}
+		joined += line
+	}
+	buf.ReplaceLines(r.Start, r.End, []string{joined})
+	buf.SetCursor(buffer.Position{Line: r.Start})
+	return CommandResult{Success: true}
+}
+
+// moveLines implements :m
+func moveLines(_ *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	dest, err := targetLine(args, buf)
+	if err == nil {
+		err = buf.MoveLines(r.Start, r.End, dest)
+	}
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: linesMessage(r.End-r.Start+1, "moved")}
+}
+
+// copyLines implements :t and :co
+func copyLines(_ *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	dest, err := targetLine(args, buf)
+	if err == nil {
+		err = buf.CopyLines(r.Start, r.End, dest)
+	}
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: linesMessage(r.End-r.Start+1, "copied")}
+}
+
+// targetLine parses the address :m and :t put lines below, where 0 means
+// above the first line and is returned as -1
+func targetLine(args []string, buf *buffer.Buffer) (int, error) {
+	text := strings.Join(args, "")
+	if text == "" {
+		return 0, fmt.Errorf("argument required")
+	}
+	if text == "0" {
+		return -1, nil
+	}
+	line, rest, ok, err := parseAddress(text, buf.Cursor().Line, buf)
+	if err != nil {
+		return 0, err
+	}
+	if !ok || rest != "" {
+		return 0, fmt.Errorf("invalid address: %s", text)
+	}
+	// An address above the first line, like -1 on the first line, means the top
+	if line < -1 || line >= buf.LineCount() {
+		return 0, fmt.Errorf("invalid range")
+	}
+	return line, nil
+}
+
+// sortLines implements :sort
+func sortLines(_ *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	var opts buffer.SortOptions
+	var flags string
+	flags, opts.Reverse = strings.CutPrefix(strings.Join(args, ""), "!")
+	for _, flag := range flags {
+		switch flag {
+		case 'n':
+			opts.Numeric = true
+		case 'i':
+			opts.IgnoreCase = true
+		case 'u':
+			opts.Unique = true
+		default:
+			return CommandResult{Success: false, Message: fmt.Sprintf("Invalid argument: %s", strings.Join(args, " "))}
+		}
+	}
+	removed, err := buf.SortLines(r.Start, r.End, opts)
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	message := linesMessage(r.End-r.Start+1, "sorted")
+	if removed > 0 {
+		message += fmt.Sprintf(", %d duplicates removed", removed)
+	}
+	return CommandResult{Success: true, Message: message}
+}
+
+// reverseLines implements :reverse
+func reverseLines(_ *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	if len(args) > 0 {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Trailing characters: %s", strings.Join(args, " "))}
+	}
+	if err := buf.ReverseLines(r.Start, r.End); err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: linesMessage(r.End-r.Start+1, "reversed")}
+}
+
+// uniqLines implements :uniq
+func uniqLines(_ *State, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	flags := strings.Join(args, "")
+	if flags != "" && flags != "i" {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid argument: %s", strings.Join(args, " "))}
+	}
+	removed, err := buf.UniqLines(r.Start, r.End, flags == "i")
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: linesMessage(removed, "removed")}
+}
+
+// linesMessage reports how many lines a command changed
+func linesMessage(n int, action string) string {
+	if n == 1 {
+		return "1 line " + action
+	}
+	return fmt.Sprintf("%d lines %s", n, action)
+}
@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/registers"
+)
+
+func TestLineCommands(t *testing.T) {
+	store := registers.NewStore()
+	executor := NewCommandExecutor()
+	executor.State().SetRegisters(store)
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"one", "\ttwo", "three", "", "four"})
+
+	executor.Execute("%y a", buf)
+	if reg, ok := store.Get('a'); !ok || !reg.Linewise || reg.Text != "one\n\ttwo\nthree\n\nfour" {
+		t.Errorf("register a after :%%y a = %+v", reg)
+	}
+
+	// Empty lines aren't shifted
+	executor.Execute("1,4>", buf)
+	want := []string{"    one", "        two", "    three", "", "four"}
+	for i, line := range buf.Lines() {
+		if line != want[i] {
+			t.Errorf(":1,4> line %d = %q, want %q", i+1, line, want[i])
+		}
+	}
+
+	options := buf.Options()
+	options.IndentTabs = true
+	options.TabStop = 8
+	options.ShiftWidth = 4
+	buf.SetOptions(options)
+	executor.Execute("2>>", buf)
+	if line, _ := buf.Line(1); line != "\t\ttwo" {
+		t.Errorf(":2>> with tabs = %q", line)
+	}
+	executor.Execute("2<<<", buf)
+	if line, _ := buf.Line(1); line != "    two" {
+		t.Errorf(":2<<< = %q", line)
+	}
+
+	executor.Execute("1j 3", buf)
+	if line, _ := buf.Line(0); line != "    one two three" || buf.LineCount() != 3 {
+		t.Errorf(":1j 3 = %q with %d lines", line, buf.LineCount())
+	}
+
+	executor.Execute("d b 2", buf)
+	if reg, _ := store.Get('b'); reg.Text != "    one two three\n" || buf.String() != "four" {
+		t.Errorf(":d b 2 stored %q, left %q", reg.Text, buf.String())
+	}
+}
+
+func TestSortCommands(t *testing.T) {
+	executor := NewCommandExecutor()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"item 10", "item 9", "item 10", "Item 1"})
+
+	executor.Execute("sort n", buf)
+	if got := buf.String(); got != "Item 1\nitem 9\nitem 10\nitem 10" {
+		t.Errorf(":sort n = %q", got)
+	}
+	executor.Execute("uniq", buf)
+	if got := buf.String(); got != "Item 1\nitem 9\nitem 10" {
+		t.Errorf(":uniq = %q", got)
+	}
+	executor.Execute("sort! i", buf)
+	if got := buf.String(); got != "item 9\nitem 10\nItem 1" {
+		t.Errorf(":sort! i = %q", got)
+	}
+	executor.Execute("2,3reverse", buf)
+	if got := buf.String(); got != "item 9\nItem 1\nitem 10" {
+		t.Errorf(":2,3reverse = %q", got)
+	}
+	if result := executor.Execute("sort x", buf); result.Success {
+		t.Error("expected :sort x to fail")
+	}
+}
+
+func TestMoveAndCopyCommands(t *testing.T) {
+	executor := NewCommandExecutor()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c", "d"})
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"1m$", "b\nc\nd\na"},
+		{"3,4m0", "d\na\nb\nc"},
+		{"m+1", "d\nb\na\nc"},
+		{"1t.", "d\nb\na\nd\nc"},
+		{"2,3co 0", "b\na\nd\nb\na\nd\nc"},
+		{"1m-1", "b\na\nd\nb\na\nd\nc"},
+	}
+	for _, tt := range tests {
+		if result := executor.Execute(tt.command, buf); !result.Success {
+			t.Errorf(":%s failed: %s", tt.command, result.Message)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf(":%s = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	for _, command := range []string{"m", "1,3m2", "t x"} {
+		if result := executor.Execute(command, buf); result.Success {
+			t.Errorf("expected :%s to fail", command)
+		}
+	}
+}
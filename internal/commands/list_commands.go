@@ -0,0 +1,396 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/quickfix"
+	"github.com/dshills/aied/internal/search"
+)
+
+// QuickfixLists returns the quickfix list history
+func (s *State) QuickfixLists() *quickfix.Stack {
+	return s.quickfixLists
+}
+
+// LocationLists returns the location list history of the current window
+func (s *State) LocationLists() *quickfix.Stack {
+	return s.locationLists
+}
+
+// listCommand is a navigation command for the quickfix or location list.
+// Each command exists in a quickfix (:c...) and a location list (:l...) form.
+type listCommand struct {
+	name     string
+	aliases  []string
+	help     string
+	location bool
+	run      func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult
+}
+
+func (c *listCommand) Name() string {
+	return c.name
+}
+
+func (c *listCommand) Aliases() []string {
+	return c.aliases
+}
+
+func (c *listCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	stack := s.quickfixLists
+	if c.location {
+		stack = s.locationLists
+	}
+	return c.run(s, stack, args, buf)
+}
+
+func (c *listCommand) Help() string {
+	return c.help
+}
+
+// NewListCommands creates the quickfix and location list navigation commands
+func NewListCommands() []Command {
+	type action struct {
+		suffix  string
+		aliases []string
+		help    string
+		run     func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult
+	}
+	actions := []action{
+		{"next", []string{"n", "ne"}, "Go to the [count] next entry", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			item, err := stack.Next(listCount(args, 1))
+			return s.jumpResult(stack, item, err, buf)
+		}},
+		{"previous", []string{"p", "N", "prev"}, "Go to the [count] previous entry", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			item, err := stack.Prev(listCount(args, 1))
+			return s.jumpResult(stack, item, err, buf)
+		}},
+		{"first", []string{"fir"}, "Go to the first entry, or entry [nr]", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			item, err := stack.Select(listCount(args, 1) - 1)
+			return s.jumpResult(stack, item, err, buf)
+		}},
+		{"last", []string{"la"}, "Go to the last entry, or entry [nr]", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			n := len(quickfixItems(stack))
+			item, err := stack.Select(listCount(args, n) - 1)
+			return s.jumpResult(stack, item, err, buf)
+		}},
+		{"older", []string{"ol"}, "Go to the [count] older list", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			list, err := stack.Older(listCount(args, 1))
+			return historyResult(stack, list, err)
+		}},
+		{"newer", []string{"new"}, "Go to the [count] newer list", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			list, err := stack.Newer(listCount(args, 1))
+			return historyResult(stack, list, err)
+		}},
+		{"open", []string{"ope", "op"}, "Open the list window", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			stack.SetOpen(true)
+			return CommandResult{Success: true}
+		}},
+		{"close", []string{"cl"}, "Close the list window", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			stack.SetOpen(false)
+			return CommandResult{Success: true}
+		}},
+		{"list", []string{"li"}, "Show the list in the list window", func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+			list := stack.Current()
+			if list == nil {
+				return CommandResult{Success: false, Message: "No list"}
+			}
+			stack.SetOpen(true)
+			return CommandResult{Success: true, Message: fmt.Sprintf("%s (%d entries)", list.Title, len(list.Items))}
+		}},
+	}
+
+	var result []Command
+	for _, prefix := range []string{"c", "l"} {
+		location := prefix == "l"
+		kind := "quickfix"
+		if location {
+			kind = "location"
+		}
+		for _, a := range actions {
+			var aliases []string
+			for _, alias := range a.aliases {
+				aliases = append(aliases, prefix+alias)
+			}
+			result = append(result, &listCommand{
+				name:     prefix + a.suffix,
+				aliases:  aliases,
+				help:     fmt.Sprintf(":%s%s - %s in the %s list", prefix, a.suffix, a.help, kind),
+				location: location,
+				run:      a.run,
+			})
+		}
+	}
+
+	// :cc [nr] and :ll [nr] go to an entry, or redisplay the current one
+	for _, name := range []string{"cc", "ll"} {
+		result = append(result, &listCommand{
+			name:     name,
+			help:     fmt.Sprintf(":%s [nr] - Go to entry [nr] or the current entry", name),
+			location: name == "ll",
+			run: func(s *State, stack *quickfix.Stack, args []string, buf *buffer.Buffer) CommandResult {
+				list := stack.Current()
+				if list == nil {
+					return s.jumpResult(stack, quickfix.Item{}, quickfix.ErrNoList, buf)
+				}
+				item, err := stack.Select(listCount(args, list.Index+1) - 1)
+				return s.jumpResult(stack, item, err, buf)
+			},
+		})
+	}
+	return result
+}
+
+// listCount parses a numeric command argument, returning fallback when absent
+func listCount(args []string, fallback int) int {
+	if len(args) == 0 {
+		return fallback
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return fallback
+	}
+	return n
+}
+
+// quickfixItems returns the entries of the active list
+func quickfixItems(stack *quickfix.Stack) []quickfix.Item {
+	if list := stack.Current(); list != nil {
+		return list.Items
+	}
+	return nil
+}
+
+// historyResult reports switching to another list in the history
+func historyResult(stack *quickfix.Stack, list *quickfix.List, err error) CommandResult {
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("%s (%d entries)", list.Title, len(list.Items))}
+}
+
+// jumpResult moves to a list entry and reports its position in the list
+func (s *State) jumpResult(stack *quickfix.Stack, item quickfix.Item, err error, buf *buffer.Buffer) CommandResult {
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	if err := s.jumpToItem(item, buf); err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+	list := stack.Current()
+	return CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("(%d of %d): %s", list.Index+1, len(list.Items), item.Text),
+	}
+}
+
+// jumpToItem moves the cursor to a list entry, loading its file into the buffer
+func (s *State) jumpToItem(item quickfix.Item, buf *buffer.Buffer) error {
+	if item.Filename != "" && !sameFile(item.Filename, buf.Filename()) {
+		if buf.Modified() {
+			return fmt.Errorf("No write since last change (save before jumping to %s)", item.Filename)
+		}
+		s.KeepScratch(buf)
+		if err := buf.Load(item.Filename); err != nil {
+			return err
+		}
+		s.ApplyModeline(buf)
+	}
+	buf.SetCursor(buffer.Position{Line: item.Line, Col: item.Col})
+	return nil
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// capitalize upper-cases the first letter of a message
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// setList replaces the active list and jumps to its first entry
+func (s *State) setList(stack *quickfix.Stack, title string, items []quickfix.Item, buf *buffer.Buffer) CommandResult {
+	stack.Set(title, items)
+	if len(items) == 0 {
+		return CommandResult{Success: false, Message: "No matches: " + title}
+	}
+	item, err := stack.Select(0)
+	return s.jumpResult(stack, item, err, buf)
+}
+
+// GrepCommand searches files and fills the quickfix (:grep) or location (:lgrep) list
+type GrepCommand struct {
+	location bool
+}
+
+// NewGrepCommand creates a grep command filling the quickfix list
+func NewGrepCommand() *GrepCommand {
+	return &GrepCommand{}
+}
+
+// NewLocationGrepCommand creates a grep command filling the location list
+func NewLocationGrepCommand() *GrepCommand {
+	return &GrepCommand{location: true}
+}
+
+func (g *GrepCommand) Name() string {
+	if g.location {
+		return "lgrep"
+	}
+	return "grep"
+}
+
+func (g *GrepCommand) Aliases() []string {
+	if g.location {
+		return []string{"lgr"}
+	}
+	return []string{"gr"}
+}
+
+func (g *GrepCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if len(args) == 0 {
+		return CommandResult{Success: false, Message: "Usage: :" + g.Name() + " <pattern> [path...]"}
+	}
+	pattern, err := regexp.Compile(args[0])
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid pattern: %v", err)}
+	}
+
+	// Search the project being worked in unless paths are given
+	var paths []string
+	for _, path := range args[1:] {
+		paths = append(paths, resolvePath(buf, path))
+	}
+	if len(paths) == 0 {
+		paths = []string{displayPath(s.workProject(buf).Root)}
+	}
+
+	items, err := quickfix.Grep(pattern, paths)
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Grep failed: %v", err)}
+	}
+
+	stack := s.quickfixLists
+	if g.location {
+		stack = s.locationLists
+	}
+	return s.setList(stack, ":"+g.Name()+" "+strings.Join(args, " "), items, buf)
+}
+
+// ExecuteRange searches the range of lines of the buffer instead of files,
+// with the editor's search options, so :'<,'>lgrep lists the matches in
+// the selected lines and \%V in the pattern those in the selection
+func (g *GrepCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if len(args) != 1 {
+		return CommandResult{Success: false, Message: "Usage: :[range]" + g.Name() + " <pattern>"}
+	}
+	if buf.Filename() == "" {
+		return CommandResult{Success: false, Message: "No file name"}
+	}
+	pattern := args[0]
+	re, err := search.Compile(pattern)
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid pattern: %v", err)}
+	}
+
+	visual := search.VisualOnly(pattern)
+	var items []quickfix.Item
+	for i := r.Start; i <= r.End; i++ {
+		line, _ := buf.Line(i)
+		matches := re.FindAllStringIndex(line, -1)
+		if visual {
+			matches = search.VisualMatches(buf, i, matches)
+		}
+		if len(matches) > 0 {
+			items = append(items, quickfix.Item{Filename: buf.Filename(), Line: i, Col: matches[0][0], Text: strings.TrimSpace(line)})
+		}
+	}
+
+	stack := s.quickfixLists
+	if g.location {
+		stack = s.locationLists
+	}
+	return s.setList(stack, ":"+g.Name()+" "+pattern, items, buf)
+}
+
+func (g *GrepCommand) Help() string {
+	list := "quickfix"
+	if g.location {
+		list = "location"
+	}
+	return fmt.Sprintf(":[range]%s <pattern> [path...] - Search files (the project by default), or the lines of the range, and fill the %s list", g.Name(), list)
+}
+
+// DiagnosticsCommand fills the location list with the buffer's diagnostics
+type DiagnosticsCommand struct{}
+
+// NewDiagnosticsCommand creates a new diagnostics command
+func NewDiagnosticsCommand() *DiagnosticsCommand {
+	return &DiagnosticsCommand{}
+}
+
+func (d *DiagnosticsCommand) Name() string {
+	return "diagnostics"
+}
+
+func (d *DiagnosticsCommand) Aliases() []string {
+	return []string{"diag"}
+}
+
+func (d *DiagnosticsCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	var items []quickfix.Item
+	for _, diag := range buf.GetDiagnostics() {
+		items = append(items, quickfix.Item{
+			Filename: buf.Filename(),
+			Line:     diag.Line,
+			Col:      diag.Column,
+			Text:     diag.Message,
+			Type:     diagnosticType(diag.Severity),
+		})
+	}
+	if len(items) == 0 {
+		return CommandResult{Success: true, Message: "No diagnostics"}
+	}
+
+	s.locationLists.Set(":diagnostics", items)
+	s.locationLists.SetOpen(true)
+	return CommandResult{Success: true, Message: fmt.Sprintf("%d diagnostics", len(items))}
+}
+
+func (d *DiagnosticsCommand) Help() string {
+	return ":diagnostics - Fill the location list with the buffer's diagnostics"
+}
+
+// diagnosticType maps an LSP severity to a list entry type
+func diagnosticType(severity int) string {
+	switch severity {
+	case 1:
+		return "E"
+	case 2:
+		return "W"
+	case 3, 4:
+		return "I"
+	}
+	return ""
+}
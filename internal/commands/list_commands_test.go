@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/quickfix"
+)
+
+func TestListCommands_Navigate(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other.txt")
+	os.WriteFile(other, []byte("one\ntwo\nthree\n"), 0644)
+
+	executor := NewCommandExecutor()
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "current")
+	buf.SetFilename(filepath.Join(dir, "current.txt"))
+	buf.Save()
+
+	executor.State().quickfixLists.Set("test", []quickfix.Item{
+		{Filename: buf.Filename(), Line: 0, Col: 3, Text: "here"},
+		{Filename: other, Line: 2, Col: 1, Text: "there"},
+	})
+
+	result := executor.Execute("cfirst", buf)
+	if !result.Success || buf.Cursor() != (buffer.Position{Line: 0, Col: 3}) {
+		t.Fatalf("expected jump to first entry, got %+v at %v", result, buf.Cursor())
+	}
+
+	result = executor.Execute("cn", buf)
+	if !result.Success || buf.Filename() != other || buf.Cursor().Line != 2 {
+		t.Fatalf("expected jump into other file, got %+v in %q", result, buf.Filename())
+	}
+	if !strings.HasPrefix(result.Message, "(2 of 2)") {
+		t.Errorf("unexpected message %q", result.Message)
+	}
+
+	if result := executor.Execute("cnext", buf); result.Success {
+		t.Error("expected error past the last entry")
+	}
+
+	buf.InsertChar('x')
+	if result := executor.Execute("cc 1", buf); result.Success {
+		t.Error("expected modified buffer to block jumping to another file")
+	}
+}
+
+func TestGrepCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	os.WriteFile(file, []byte("first\nTODO fix\n"), 0644)
+
+	buf := buffer.New()
+	cc := &CommandContext{State: NewState()}
+	result := NewLocationGrepCommand().Execute(cc, []string{"TODO", dir}, buf)
+	if !result.Success {
+		t.Fatalf("grep failed: %s", result.Message)
+	}
+	if buf.Filename() != file || buf.Cursor().Line != 1 {
+		t.Errorf("expected jump to match, got %q line %d", buf.Filename(), buf.Cursor().Line)
+	}
+	if list := cc.State.locationLists.Current(); list == nil || len(list.Items) != 1 {
+		t.Errorf("expected location list with one entry, got %+v", list)
+	}
+}
+
+func TestDiagnosticsCommand(t *testing.T) {
+	buf := buffer.New()
+	buf.SetDiagnostics([]buffer.Diagnostic{{Line: 2, Column: 1, Severity: 1, Message: "bad"}})
+	cc := &CommandContext{State: NewState()}
+	NewDiagnosticsCommand().Execute(cc, nil, buf)
+
+	list := cc.State.locationLists.Current()
+	if list == nil || len(list.Items) != 1 || list.Items[0].Type != "E" {
+		t.Fatalf("expected error entry, got %+v", list)
+	}
+	if !cc.State.locationLists.IsOpen() {
+		t.Error("expected location list window to open")
+	}
+}
+
+func TestGrepCommand_Range(t *testing.T) {
+	buf := buffer.New()
+	buf.SetFilename("notes.txt")
+	buf.ReplaceLines(0, 0, []string{"TODO one", "TODO two", "done", "TODO three"})
+	buf.SetVisualArea(buffer.Position{Line: 1, Col: 0}, buffer.Position{Line: 3, Col: 1})
+	executor := NewCommandExecutor()
+
+	result := executor.Execute("'<,'>lgrep TODO", buf)
+	if list := executor.State().locationLists.Current(); !result.Success || list == nil || len(list.Items) != 2 {
+		t.Fatalf(":'<,'>lgrep = %+v, list %+v", result, list)
+	}
+	if cursor := buf.Cursor(); cursor.Line != 1 {
+		t.Errorf("cursor on line %d, want the first match on line 2", cursor.Line+1)
+	}
+
+	// TODO on the last line is only partly selected
+	executor.Execute(`%lgrep \%VTODO`, buf)
+	if list := executor.State().locationLists.Current(); len(list.Items) != 1 || list.Items[0].Line != 1 {
+		t.Errorf(`:%%lgrep \%%V = %+v`, list.Items)
+	}
+	if result := executor.Execute("'<,'>lgrep TODO extra", buf); result.Success {
+		t.Error("a range grep took paths")
+	}
+}
@@ -3,19 +3,17 @@ package commands
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/dshills/aied/internal/buffer"
 	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/quickfix"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
 )
 
-// Global LSP manager reference
-var lspManager *lsp.Manager
-
-// SetLSPManager sets the global LSP manager
-func SetLSPManager(manager *lsp.Manager) {
-	lspManager = manager
-}
-
 // HoverCommand shows hover information at cursor position
 type HoverCommand struct{}
 
@@ -31,8 +29,8 @@ func (c *HoverCommand) Aliases() []string {
 	return []string{"lsp-hover"}
 }
 
-func (c *HoverCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if lspManager == nil {
+func (c *HoverCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.LSP == nil {
 		return CommandResult{
 			Success: false,
 			Message: "LSP not available",
@@ -49,7 +47,7 @@ func (c *HoverCommand) Execute(args []string, buf *buffer.Buffer) CommandResult
 	cursor := buf.Cursor()
 	ctx := context.Background()
 	
-	hover, err := lspManager.Hover(ctx, buf.Filename(), cursor.Line, cursor.Col)
+	hover, err := cc.LSP.Hover(ctx, buf.Filename(), cursor.Line, cursor.Col)
 	if err != nil {
 		return CommandResult{
 			Success: false,
@@ -89,8 +87,8 @@ func (c *DefinitionCommand) Aliases() []string {
 	return []string{"def", "lsp-definition"}
 }
 
-func (c *DefinitionCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if lspManager == nil {
+func (c *DefinitionCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.LSP == nil {
 		return CommandResult{
 			Success: false,
 			Message: "LSP not available",
@@ -107,7 +105,7 @@ func (c *DefinitionCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 	cursor := buf.Cursor()
 	ctx := context.Background()
 	
-	locations, err := lspManager.Definition(ctx, buf.Filename(), cursor.Line, cursor.Col)
+	locations, err := cc.LSP.Definition(ctx, buf.Filename(), cursor.Line, cursor.Col)
 	if err != nil {
 		return CommandResult{
 			Success: false,
@@ -122,12 +120,21 @@ func (c *DefinitionCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 		}
 	}
 	
-	// For now, just show the first location
+	// Go to the first location, listing them all when there are more
 	loc := locations[0]
-	return CommandResult{
+	result := CommandResult{
 		Success: true,
-		Message: fmt.Sprintf("Definition at %s:%d:%d", loc.URI, loc.Range.Start.Line+1, loc.Range.Start.Character+1),
+		Actions: []Action{OpenFile{
+			Filename: uri.URI(loc.URI).Filename(),
+			Line:     int(loc.Range.Start.Line),
+			Col:      int(loc.Range.Start.Character),
+		}},
+	}
+	if len(locations) > 1 {
+		result.Message = fmt.Sprintf("1 of %d definitions, :cnext goes to the next", len(locations))
+		result.Actions = append([]Action{SetQuickfix{Title: ":definition", Items: locationItems(locations)}}, result.Actions...)
 	}
+	return result
 }
 
 func (c *DefinitionCommand) Help() string {
@@ -149,8 +156,8 @@ func (c *ReferencesCommand) Aliases() []string {
 	return []string{"refs", "lsp-references"}
 }
 
-func (c *ReferencesCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if lspManager == nil {
+func (c *ReferencesCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.LSP == nil {
 		return CommandResult{
 			Success: false,
 			Message: "LSP not available",
@@ -167,7 +174,7 @@ func (c *ReferencesCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 	cursor := buf.Cursor()
 	ctx := context.Background()
 	
-	locations, err := lspManager.References(ctx, buf.Filename(), cursor.Line, cursor.Col)
+	locations, err := cc.LSP.References(ctx, buf.Filename(), cursor.Line, cursor.Col)
 	if err != nil {
 		return CommandResult{
 			Success: false,
@@ -182,20 +189,11 @@ func (c *ReferencesCommand) Execute(args []string, buf *buffer.Buffer) CommandRe
 		}
 	}
 	
-	// Format references
-	msg := fmt.Sprintf("Found %d references:", len(locations))
-	for i, loc := range locations {
-		if i < 5 { // Show first 5 references
-			msg += fmt.Sprintf("\n  %s:%d:%d", loc.URI, loc.Range.Start.Line+1, loc.Range.Start.Character+1)
-		}
-	}
-	if len(locations) > 5 {
-		msg += fmt.Sprintf("\n  ... and %d more", len(locations)-5)
-	}
-	
+	// Fill the quickfix list and show it
 	return CommandResult{
 		Success: true,
-		Message: msg,
+		Message: fmt.Sprintf("Found %d references", len(locations)),
+		Actions: []Action{SetQuickfix{Title: ":references", Items: locationItems(locations), Open: true}},
 	}
 }
 
@@ -203,6 +201,35 @@ func (c *ReferencesCommand) Help() string {
 	return "Find all references to symbol at cursor"
 }
 
+// locationItems converts LSP locations to quickfix entries showing the source line
+func locationItems(locations []protocol.Location) []quickfix.Item {
+	files := make(map[string][]string)
+	var items []quickfix.Item
+	for _, loc := range locations {
+		filename := uri.URI(loc.URI).Filename()
+		lines, ok := files[filename]
+		if !ok {
+			if content, err := os.ReadFile(filename); err == nil {
+				lines = strings.Split(string(content), "\n")
+			}
+			files[filename] = lines
+		}
+
+		line := int(loc.Range.Start.Line)
+		text := ""
+		if line < len(lines) {
+			text = strings.TrimSpace(lines[line])
+		}
+		items = append(items, quickfix.Item{
+			Filename: filename,
+			Line:     line,
+			Col:      int(loc.Range.Start.Character),
+			Text:     text,
+		})
+	}
+	return items
+}
+
 // RenameCommand renames a symbol
 type RenameCommand struct{}
 
@@ -218,8 +245,8 @@ func (c *RenameCommand) Aliases() []string {
 	return []string{"lsp-rename"}
 }
 
-func (c *RenameCommand) Execute(args []string, buf *buffer.Buffer) CommandResult {
-	if lspManager == nil {
+func (c *RenameCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if cc.LSP == nil {
 		return CommandResult{
 			Success: false,
 			Message: "LSP not available",
@@ -244,7 +271,7 @@ func (c *RenameCommand) Execute(args []string, buf *buffer.Buffer) CommandResult
 	cursor := buf.Cursor()
 	ctx := context.Background()
 	
-	workspaceEdit, err := lspManager.Rename(ctx, buf.Filename(), cursor.Line, cursor.Col, newName)
+	workspaceEdit, err := cc.LSP.Rename(ctx, buf.Filename(), cursor.Line, cursor.Col, newName)
 	if err != nil {
 		return CommandResult{
 			Success: false,
@@ -252,7 +279,8 @@ func (c *RenameCommand) Execute(args []string, buf *buffer.Buffer) CommandResult
 		}
 	}
 	
-	if workspaceEdit == nil || len(workspaceEdit.Changes) == 0 {
+	files := lsp.WorkspaceEditFiles(workspaceEdit)
+	if len(files) == 0 {
 		return CommandResult{
 			Success: true,
 			Message: "No changes to apply",
@@ -261,16 +289,76 @@ func (c *RenameCommand) Execute(args []string, buf *buffer.Buffer) CommandResult
 	
 	// Count total edits
 	totalEdits := 0
-	for _, edits := range workspaceEdit.Changes {
+	for _, edits := range files {
 		totalEdits += len(edits)
 	}
 	
 	return CommandResult{
 		Success: true,
-		Message: fmt.Sprintf("Rename would affect %d locations in %d files", totalEdits, len(workspaceEdit.Changes)),
+		Message: fmt.Sprintf("Renamed %d locations in %d files", totalEdits, len(files)),
+		Actions: []Action{ApplyEdit{Edit: workspaceEdit}},
 	}
 }
 
 func (c *RenameCommand) Help() string {
 	return "Rename symbol at cursor"
-}
\ No newline at end of file
+}
+// applyWorkspaceEdit applies the edits a language server sent: to the
+// buffer for the file being edited, and on disk for the rest. It returns
+// the files changed.
+func applyWorkspaceEdit(edit *protocol.WorkspaceEdit, buf *buffer.Buffer) ([]string, error) {
+	files := lsp.WorkspaceEditFiles(edit)
+	filenames := make([]string, 0, len(files))
+	for filename, edits := range files {
+		if len(edits) > 0 {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		if sameFile(filename, buf.Filename()) {
+			if err := setBufferText(buf, lsp.ApplyTextEdits(buf.String(), files[filename])); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		text := lsp.ApplyTextEdits(string(data), files[filename])
+		if err := os.WriteFile(filename, []byte(text), info.Mode().Perm()); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", displayPath(filename), err)
+		}
+	}
+	return filenames, nil
+}
+
+// setBufferText replaces the text of the buffer as one change, keeping the
+// cursor where it was. The buffer is left alone when the text is the same.
+func setBufferText(buf *buffer.Buffer, text string) error {
+	if text == buf.String() {
+		return nil
+	}
+	cursor := buf.Cursor()
+	if err := buf.ReplaceLines(0, buf.LineCount()-1, strings.Split(text, "\n")); err != nil {
+		return err
+	}
+	buf.SetCursor(cursor)
+	return nil
+}
+
+// lspServes reports whether a language server is running for filename
+func lspServes(cc *CommandContext, filename string) bool {
+	if cc.LSP == nil || filename == "" {
+		return false
+	}
+	_, err := cc.LSP.GetClient(filename)
+	return err == nil
+}
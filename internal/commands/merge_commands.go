@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/merge"
+)
+
+// MergeToolOpen reports whether the merge view is shown
+func (s *State) MergeToolOpen() bool {
+	return s.mergeToolOpen
+}
+
+// OpenMergeTool shows the merge view if buf has conflict markers, and
+// reports whether it does
+func (s *State) OpenMergeTool(buf *buffer.Buffer) bool {
+	s.mergeToolOpen = len(merge.Find(buf.Lines())) > 0
+	return s.mergeToolOpen
+}
+
+// ConflictLines returns the first line of each conflict in buf, for ]x and
+// [x
+func ConflictLines(buf *buffer.Buffer) []int {
+	var lines []int
+	for _, c := range merge.Find(buf.Lines()) {
+		lines = append(lines, c.Start)
+	}
+	return lines
+}
+
+// MergeToolLines returns the title and lines of the merge view, showing our
+// side, the common ancestor if known and their side of the conflict at or
+// after the cursor in columns fitting width
+func MergeToolLines(buf *buffer.Buffer, width int) (string, []string) {
+	lines := buf.Lines()
+	conflicts := merge.Find(lines)
+	if len(conflicts) == 0 {
+		return "[Merge] No conflicts", nil
+	}
+	i := slices.IndexFunc(conflicts, func(c merge.Conflict) bool {
+		return c.End >= buf.Cursor().Line
+	})
+	if i < 0 {
+		i = len(conflicts) - 1
+	}
+	c := conflicts[i]
+	title := fmt.Sprintf("[Merge] Conflict %d of %d: :ours :theirs :both :base, ]x next", i+1, len(conflicts))
+
+	columns := [][]string{
+		append([]string{"ours: " + c.OursLabel(lines)}, c.Ours(lines)...),
+		append([]string{"theirs: " + c.TheirsLabel(lines)}, c.Theirs(lines)...),
+	}
+	if c.Base >= 0 {
+		columns = slices.Insert(columns, 1, append([]string{"base"}, c.BaseLines(lines)...))
+	}
+
+	// Columns are separated by " │ "
+	columnWidth := max((width-3*(len(columns)-1))/len(columns), 1)
+	rows := 0
+	for _, column := range columns {
+		rows = max(rows, len(column))
+	}
+	view := make([]string, rows)
+	for row := range view {
+		cells := make([]string, len(columns))
+		for j, column := range columns {
+			var cell string
+			if row < len(column) {
+				cell = column[row]
+			}
+			cells[j] = fitColumn(cell, columnWidth)
+		}
+		view[row] = strings.Join(cells, " │ ")
+	}
+	return title, view
+}
+
+// fitColumn pads or cuts s to width characters, expanding tabs
+func fitColumn(s string, width int) string {
+	runes := []rune(strings.ReplaceAll(s, "\t", "    "))
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return string(runes) + strings.Repeat(" ", width-len(runes))
+}
+
+// mergeCommand resolves conflicts with one of their sides
+type mergeCommand struct {
+	name    string
+	aliases []string
+	help    string
+	choice  merge.Choice
+}
+
+func (c *mergeCommand) Name() string {
+	return c.name
+}
+
+func (c *mergeCommand) Aliases() []string {
+	return c.aliases
+}
+
+func (c *mergeCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return cc.State.resolveConflicts(currentLine(buf), c.choice, buf)
+}
+
+func (c *mergeCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	return cc.State.resolveConflicts(r, c.choice, buf)
+}
+
+func (c *mergeCommand) Help() string {
+	return c.help
+}
+
+// mergeToolCommand implements :mergetool
+type mergeToolCommand struct{}
+
+func (c *mergeToolCommand) Name() string {
+	return "mergetool"
+}
+
+func (c *mergeToolCommand) Aliases() []string {
+	return nil
+}
+
+func (c *mergeToolCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if s.mergeToolOpen {
+		s.mergeToolOpen = false
+		return CommandResult{Success: true}
+	}
+	if !s.OpenMergeTool(buf) {
+		return CommandResult{Success: false, Message: "No conflicts"}
+	}
+	return CommandResult{Success: true}
+}
+
+func (c *mergeToolCommand) Help() string {
+	return ":mergetool - Toggle the view of the conflict at the cursor"
+}
+
+// NewMergeCommands creates :mergetool and the commands resolving conflicts
+func NewMergeCommands() []Command {
+	return []Command{
+		&mergeToolCommand{},
+		&mergeCommand{"ours", nil, ":[range]ours - Resolve the conflict at the cursor or in range with our side", merge.ChoiceOurs},
+		&mergeCommand{"theirs", nil, ":[range]theirs - Resolve the conflict at the cursor or in range with their side", merge.ChoiceTheirs},
+		&mergeCommand{"both", nil, ":[range]both - Resolve the conflict at the cursor or in range with our side then theirs", merge.ChoiceBoth},
+		&mergeCommand{"base", nil, ":[range]base - Resolve the conflict at the cursor or in range with the common ancestor", merge.ChoiceBase},
+		&mergeCommand{"none", nil, ":[range]none - Resolve the conflict at the cursor or in range by deleting both sides", merge.ChoiceNone},
+	}
+}
+
+// resolveConflicts replaces the conflicts touching r, markers included,
+// with the text choice selects
+func (s *State) resolveConflicts(r Range, choice merge.Choice, buf *buffer.Buffer) CommandResult {
+	lines := buf.Lines()
+	var selected []merge.Conflict
+	for _, c := range merge.Find(lines) {
+		if c.Start <= r.End && c.End >= r.Start {
+			selected = append(selected, c)
+		}
+	}
+	if len(selected) == 0 {
+		return CommandResult{Success: false, Message: "No conflict here"}
+	}
+	if choice == merge.ChoiceBase && slices.ContainsFunc(selected, func(c merge.Conflict) bool { return c.Base < 0 }) {
+		return CommandResult{Success: false, Message: "No common ancestor in conflict (set git's merge.conflictStyle to diff3)"}
+	}
+
+	// Later conflicts first, so the line numbers of earlier ones stay valid
+	for _, c := range slices.Backward(selected) {
+		buf.ReplaceLines(c.Start, c.End, c.Resolve(lines, choice))
+	}
+	buf.SetCursor(buffer.Position{Line: selected[0].Start})
+
+	left := len(merge.Find(buf.Lines()))
+	if left == 0 {
+		s.mergeToolOpen = false
+		return CommandResult{Success: true, Message: "All conflicts resolved"}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("%d conflicts left", left)}
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestMergeCommands(t *testing.T) {
+
+	executor := NewCommandExecutor()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{
+		"<<<<<<< HEAD", "a := 1", "=======", "a := 2", ">>>>>>> feature",
+		"",
+		"<<<<<<< HEAD", "b := 1", "||||||| base", "b := 0", "=======", "b := 2", ">>>>>>> feature",
+		"<<<<<<< HEAD", "c := 1", "=======", "c := 2", ">>>>>>> feature",
+	})
+
+	if !executor.State().OpenMergeTool(buf) {
+		t.Fatal("expected the merge view to open")
+	}
+	if lines := ConflictLines(buf); !slices.Equal(lines, []int{0, 6, 13}) {
+		t.Errorf("ConflictLines = %v", lines)
+	}
+
+	buf.SetCursor(buffer.Position{Line: 8})
+	title, view := MergeToolLines(buf, 30)
+	if title != "[Merge] Conflict 2 of 3: :ours :theirs :both :base, ]x next" {
+		t.Errorf("merge view title = %q", title)
+	}
+	if want := []string{"ours: HE │ base     │ theirs: ", "b := 1   │ b := 0   │ b := 2  "}; !slices.Equal(view, want) {
+		t.Errorf("merge view = %q, want %q", view, want)
+	}
+
+	if result := executor.Execute("base", buf); !result.Success || result.Message != "2 conflicts left" {
+		t.Errorf(":base = %+v", result)
+	}
+	buf.SetCursor(buffer.Position{Line: 0})
+	if result := executor.Execute("base", buf); result.Success {
+		t.Error("expected :base to fail without a common ancestor")
+	}
+	if result := executor.Execute("both", buf); !result.Success {
+		t.Errorf(":both = %+v", result)
+	}
+	if result := executor.Execute("ours", buf); result.Success {
+		t.Error("expected :ours outside a conflict to fail")
+	}
+	if result := executor.Execute("%theirs", buf); result.Message != "All conflicts resolved" {
+		t.Errorf(":%%theirs = %+v", result)
+	}
+	if want := []string{"a := 1", "a := 2", "", "b := 0", "c := 2"}; !slices.Equal(buf.Lines(), want) {
+		t.Errorf("resolved buffer = %q, want %q", buf.Lines(), want)
+	}
+	if executor.State().MergeToolOpen() {
+		t.Error("expected the merge view to close once every conflict is resolved")
+	}
+}
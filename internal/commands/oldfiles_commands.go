@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// OldfilesCommand implements :oldfiles, which lists the files opened
+// lately in the palette to open one again
+type OldfilesCommand struct{}
+
+// NewOldfilesCommand creates the :oldfiles command
+func NewOldfilesCommand() *OldfilesCommand {
+	return &OldfilesCommand{}
+}
+
+func (c *OldfilesCommand) Name() string {
+	return "oldfiles"
+}
+
+func (c *OldfilesCommand) Aliases() []string {
+	return []string{"ol"}
+}
+
+func (c *OldfilesCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	files := recentFiles(cc)
+	if len(files) == 0 {
+		return CommandResult{Success: false, Message: "No recent files"}
+	}
+
+	items := make([]string, len(files))
+	for i, filename := range files {
+		items[i] = displayPath(filename)
+	}
+	return CommandResult{
+		Success:    true,
+		SwitchMode: true,
+		Actions: []Action{Pick{Title: "Recent files", Items: items, Chosen: func(index int) CommandResult {
+			return CommandResult{
+				Success: true,
+				Message: "\"" + items[index] + "\"",
+				Actions: []Action{OpenFile{Filename: files[index]}},
+			}
+		}}},
+	}
+}
+
+func (c *OldfilesCommand) Help() string {
+	return ":oldfiles - Pick a recently opened file to open again"
+}
+
+// recentFiles returns the files opened lately that still exist, most
+// recent first
+func recentFiles(cc *CommandContext) []string {
+	var files []string
+	for _, filename := range cc.Recent.List() {
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			files = append(files, filename)
+		}
+	}
+	return files
+}
@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// SetPath sets the directories :find and gf look for files in
+func (s *State) SetPath(path string) {
+	s.searchPath = path
+}
+
+// urlPattern matches URLs like https://example.com/a?b=c
+var urlPattern = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s<>"'` + "`" + `]+`)
+
+// fileLinePattern splits a file name from the line and column numbers
+// after it, as in main.go:12:5
+var fileLinePattern = regexp.MustCompile(`^(.+?)(?::(\d+))?(?::\d+)?$`)
+
+// isFileNameChar reports whether r may be part of a file name under the
+// cursor, like Vim's isfname
+func isFileNameChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("/\\._+,#$%~=-:", r)
+}
+
+// fileNameAt returns the file name around byte offset col of line and the
+// line number after it, as in main.go:12, or 0 without one
+func fileNameAt(line string, col int) (string, int) {
+	col = min(col, len(line))
+	start, end := col, col
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(line[:start])
+		if !isFileNameChar(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(line) {
+		r, size := utf8.DecodeRuneInString(line[end:])
+		if !isFileNameChar(r) {
+			break
+		}
+		end += size
+	}
+	name := strings.TrimRight(line[start:end], ".,:")
+	if name == "" {
+		return "", 0
+	}
+	m := fileLinePattern.FindStringSubmatch(name)
+	number, _ := strconv.Atoi(m[2])
+	return m[1], number
+}
+
+// urlAt returns the URL around byte offset col of line, without the
+// punctuation ending a sentence after it
+func urlAt(line string, col int) string {
+	for _, m := range urlPattern.FindAllStringIndex(line, -1) {
+		if col >= m[0] && col < m[1] {
+			return strings.TrimRight(line[m[0]:m[1]], ".,;:!?)]}")
+		}
+	}
+	return ""
+}
+
+// findFile looks for name in the directories of the path option, returning
+// the first regular file found
+func (s *State) findFile(buf *buffer.Buffer, name string) (string, bool) {
+	if strings.HasPrefix(name, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			name = filepath.Join(home, name[2:])
+		}
+	}
+	isFile := func(path string) bool {
+		info, err := os.Stat(path)
+		return err == nil && info.Mode().IsRegular()
+	}
+	if filepath.IsAbs(name) {
+		return name, isFile(name)
+	}
+
+	for _, dir := range strings.Split(s.searchPath, ",") {
+		switch {
+		case dir == ".":
+			dir = filepath.Dir(resolvePath(buf, buf.Filename()))
+			if buf.Filename() == "" {
+				dir = resolvePath(buf, ".")
+			}
+		case dir == "":
+			dir = resolvePath(buf, ".")
+		case strings.HasSuffix(dir, "/**"):
+			if path, ok := findInTree(resolvePath(buf, strings.TrimSuffix(dir, "/**")), name); ok {
+				return path, true
+			}
+			continue
+		default:
+			dir = resolvePath(buf, dir)
+		}
+		if path := filepath.Join(dir, name); isFile(path) {
+			return path, true
+		}
+	}
+	return name, false
+}
+
+// findInTree looks for name in root and the directories below it, leaving
+// out hidden ones like .git
+func findInTree(root, name string) (string, bool) {
+	var found string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		candidate := filepath.Join(path, name)
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			found = candidate
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, found != ""
+}
+
+// FindCommand implements :find, which opens a file looked for in the
+// directories of the path option. Without a name it opens the one under
+// the cursor, for gf.
+type FindCommand struct{}
+
+// NewFindCommand creates the :find command
+func NewFindCommand() *FindCommand {
+	return &FindCommand{}
+}
+
+func (c *FindCommand) Name() string {
+	return "find"
+}
+
+func (c *FindCommand) Aliases() []string {
+	return []string{"fin"}
+}
+
+func (c *FindCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	name, line := strings.Join(args, " "), 0
+	if name == "" {
+		name, line = fileNameAt(buf.CurrentLine(), buf.Cursor().Col)
+		if name == "" {
+			return CommandResult{Success: false, Message: "No file name under the cursor"}
+		}
+	}
+	path, ok := cc.State.findFile(buf, name)
+	if !ok {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Can't find file %q in path", name)}
+	}
+	return CommandResult{
+		Success: true,
+		Message: "\"" + displayPath(path) + "\"",
+		Actions: []Action{OpenFile{Filename: path, Line: max(line-1, 0)}},
+	}
+}
+
+func (c *FindCommand) Help() string {
+	return ":find [file] - Open a file looked for in the directories of the path option, or the file named under the cursor (gf), going to the line after a colon, as in main.go:12"
+}
+
+// openExternally opens target, a URL or file, with the system's default
+// application without waiting for it. Tests replace it.
+var openExternally = func(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// OpenCommand implements :Open, which opens a URL or file in the system's
+// default application, like a browser. Without one it opens the URL under
+// the cursor, or else the file named there, for gx.
+type OpenCommand struct{}
+
+// NewOpenCommand creates the :Open command
+func NewOpenCommand() *OpenCommand {
+	return &OpenCommand{}
+}
+
+func (c *OpenCommand) Name() string {
+	return "Open"
+}
+
+func (c *OpenCommand) Aliases() []string {
+	return nil
+}
+
+func (c *OpenCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	target := strings.Join(args, " ")
+	if target == "" {
+		line, col := buf.CurrentLine(), buf.Cursor().Col
+		if target = urlAt(line, col); target == "" {
+			name, _ := fileNameAt(line, col)
+			if path, ok := cc.State.findFile(buf, name); ok {
+				target = path
+			}
+		}
+		if target == "" {
+			return CommandResult{Success: false, Message: "No URL or file under the cursor"}
+		}
+	}
+	if !strings.Contains(target, "://") {
+		target = resolvePath(buf, target)
+	}
+	if err := openExternally(target); err != nil {
+		return CommandResult{Success: false, Message: "Can't open " + target + ": " + err.Error()}
+	}
+	return CommandResult{Success: true, Message: "Opening " + target}
+}
+
+func (c *OpenCommand) Help() string {
+	return ":Open [url|file] - Open a URL or file in the system's default application, or the URL or file under the cursor (gx)"
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestFileNameAt(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		name string
+		num  int
+	}{
+		{"see internal/ui/swatch.go for more", 8, "internal/ui/swatch.go", 0},
+		{"main.go:12:5: undefined: x", 0, "main.go", 12},
+		{"(notes.txt).", 3, "notes.txt", 0},
+		{"import \"fmt\"", 8, "fmt", 0},
+		{"   ", 1, "", 0},
+	}
+	for _, tt := range tests {
+		name, num := fileNameAt(tt.line, tt.col)
+		if name != tt.name || num != tt.num {
+			t.Errorf("fileNameAt(%q, %d) = %q, %d, want %q, %d", tt.line, tt.col, name, num, tt.name, tt.num)
+		}
+	}
+}
+
+func TestURLAt(t *testing.T) {
+	line := "Docs (https://go.dev/doc/?q=1). Mail"
+	if got := urlAt(line, 10); got != "https://go.dev/doc/?q=1" {
+		t.Errorf("urlAt gave %q", got)
+	}
+	if got := urlAt(line, 2); got != "" {
+		t.Errorf("urlAt outside the URL gave %q", got)
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs", "api"), 0755)
+	os.WriteFile(filepath.Join(dir, "docs", "guide.md"), []byte("one\ntwo\nthree\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "docs", "api", "ref.md"), []byte("ref\n"), 0644)
+
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "Read guide.md:3 first")
+	buf.SetFilename(filepath.Join(dir, "docs", "index.md"))
+	buf.Save()
+	buf.SetCursor(buffer.Position{Line: 0, Col: 7})
+
+	// gf looks next to the buffer's file and goes to the line
+	executor := NewCommandExecutor()
+	result := executor.Execute("find", buf)
+	if !result.Success || buf.Filename() != filepath.Join(dir, "docs", "guide.md") || buf.Cursor().Line != 2 {
+		t.Fatalf("gf gave %+v in %q at %+v", result, buf.Filename(), buf.Cursor())
+	}
+
+	if result := executor.Execute("find ref.md", buf); result.Success {
+		t.Errorf("expected ref.md outside the path not to be found, got %+v", result)
+	}
+	executor.State().SetPath(filepath.Join(dir, "**"))
+	if path, ok := executor.State().findFile(buf, "ref.md"); !ok || path != filepath.Join(dir, "docs", "api", "ref.md") {
+		t.Errorf("findFile in a tree gave %q, %v", path, ok)
+	}
+
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+	buf.ReplaceLines(0, 0, []string{"  "})
+	if result := NewFindCommand().Execute(&CommandContext{State: NewState()}, nil, buf); result.Success {
+		t.Error("expected an error without a file name under the cursor")
+	}
+}
+
+func TestOpenCommand(t *testing.T) {
+	var opened []string
+	defer func(saved func(string) error) { openExternally = saved }(openExternally)
+	openExternally = func(target string) error {
+		opened = append(opened, target)
+		return nil
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "logo.png"), []byte("png"), 0644)
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"Home: https://example.com/aied, logo.png"})
+	buf.SetFilename(filepath.Join(dir, "README.md"))
+
+	cmd := NewOpenCommand()
+	buf.SetCursor(buffer.Position{Line: 0, Col: 10})
+	if result := cmd.Execute(&CommandContext{State: NewState()}, nil, buf); !result.Success || result.Message != "Opening https://example.com/aied" {
+		t.Errorf("gx on a URL gave %+v", result)
+	}
+	buf.SetCursor(buffer.Position{Line: 0, Col: 34})
+	cmd.Execute(&CommandContext{State: NewState()}, nil, buf)
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+	if result := cmd.Execute(&CommandContext{State: NewState()}, nil, buf); result.Success {
+		t.Errorf("expected an error without a URL or file, got %+v", result)
+	}
+
+	want := []string{"https://example.com/aied", filepath.Join(dir, "logo.png")}
+	if len(opened) != 2 || opened[0] != want[0] || opened[1] != want[1] {
+		t.Errorf("opened %q, want %q", opened, want)
+	}
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/perf"
+)
+
+// PerfLines returns the timings window, or false when it is closed
+func (s *State) PerfLines() ([]string, bool) {
+	if !s.perfOpen {
+		return nil, false
+	}
+	return perf.Lines(perf.Default.Stats()), true
+}
+
+// PerfCommand toggles the window showing render, key, language server and
+// AI timings
+type PerfCommand struct{}
+
+func NewPerfCommand() *PerfCommand {
+	return &PerfCommand{}
+}
+
+func (c *PerfCommand) Name() string {
+	return "perf"
+}
+
+func (c *PerfCommand) Aliases() []string {
+	return []string{}
+}
+
+func (c *PerfCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if len(args) > 0 {
+		if args[0] != "reset" {
+			return CommandResult{Success: false, Message: "Usage: :perf [reset]"}
+		}
+		perf.Default.Reset()
+		s.perfOpen = true
+		return CommandResult{Success: true, Message: "Timings reset"}
+	}
+	s.perfOpen = !s.perfOpen
+	return CommandResult{Success: true}
+}
+
+func (c *PerfCommand) Help() string {
+	return ":perf [reset] - Toggle the window of render, key, language server and AI timings, or start them over"
+}
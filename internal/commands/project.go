@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/project"
+)
+
+// SetRootMarkers sets the files and directories that mark a project root
+func (s *State) SetRootMarkers(markers []string) {
+	s.rootMarkers = markers
+}
+
+// bufferProject returns the project the buffer's file belongs to
+func (s *State) bufferProject(buf *buffer.Buffer) project.Project {
+	return project.Detect(buf.Filename(), s.rootMarkers)
+}
+
+// displayPath shortens path relative to the working directory when it lies inside it
+func displayPath(path string) string {
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// allCommand is a command working on every open buffer: :wa, :qa, :qa!
+// and :wqa
+type allCommand struct {
+	name    string
+	aliases []string
+	help    string
+	run     func(buffers []*buffer.Buffer, args []string) CommandResult
+}
+
+func (c *allCommand) Name() string {
+	return c.name
+}
+
+func (c *allCommand) Aliases() []string {
+	return c.aliases
+}
+
+func (c *allCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(openBuffers(cc, buf), args)
+}
+
+func (c *allCommand) Help() string {
+	return c.help
+}
+
+// NewAllCommands creates :wa, :qa, :qa! and :wqa
+func NewAllCommands() []Command {
+	return []Command{
+		&allCommand{"wall", []string{"wa"}, ":wa [N...] - Write every modified buffer, or those numbered in the unsaved summary", writeAll},
+		&allCommand{"qall", []string{"qa", "quitall"}, ":qa - Quit, or list the modified buffers when there are any", func(buffers []*buffer.Buffer, args []string) CommandResult {
+			return quitAll(buffers)
+		}},
+		&allCommand{"qall!", []string{"qa!", "quitall!"}, ":qa! - Quit, discarding the changes to every buffer", func(buffers []*buffer.Buffer, args []string) CommandResult {
+			return CommandResult{Success: true, Message: "Goodbye!", ExitEditor: true}
+		}},
+		&allCommand{"wqall", []string{"wqa", "xall", "xa"}, ":wqa - Write every modified buffer and quit", func(buffers []*buffer.Buffer, args []string) CommandResult {
+			if result := writeAll(buffers, nil); !result.Success {
+				return result
+			}
+			return CommandResult{Success: true, Message: "Files written and editor closed", ExitEditor: true}
+		}},
+	}
+}
+
+// openBuffers returns the buffers open in the editor, or buf alone when
+// commands run without it
+func openBuffers(cc *CommandContext, buf *buffer.Buffer) []*buffer.Buffer {
+	if cc.Buffers != nil {
+		if buffers := cc.Buffers(); len(buffers) > 0 {
+			return buffers
+		}
+	}
+	return []*buffer.Buffer{buf}
+}
+
+// modifiedBuffers returns the numbers, counted from 1, of the buffers with
+// unsaved changes
+func modifiedBuffers(buffers []*buffer.Buffer) []int {
+	var modified []int
+	for i, buf := range buffers {
+		if buf.Modified() {
+			modified = append(modified, i+1)
+		}
+	}
+	return modified
+}
+
+// writeAll writes the modified buffers numbered in args, or all of them,
+// stopping at the first that fails
+func writeAll(buffers []*buffer.Buffer, args []string) CommandResult {
+	numbers := modifiedBuffers(buffers)
+	if len(args) > 0 {
+		numbers = nil
+		for _, arg := range args {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > len(buffers) {
+				return CommandResult{Success: false, Message: fmt.Sprintf("No buffer %s", arg)}
+			}
+			numbers = append(numbers, n)
+		}
+	}
+
+	written := 0
+	for _, n := range numbers {
+		buf := buffers[n-1]
+		switch {
+		case buf.Filename() == "":
+			return CommandResult{Success: false, Message: fmt.Sprintf("No file name for buffer %d (use :saveas)", n)}
+		case buf.Options().ReadOnly:
+			return CommandResult{Success: false, Message: fmt.Sprintf("'readonly' option is set for %s", displayPath(buf.Filename()))}
+		}
+		if err := buf.Save(); err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Error writing %s: %s%s", displayPath(buf.Filename()), err.Error(), permissionHint(err))}
+		}
+		written++
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("%d files written", written)}
+}
+
+// quitAll quits unless a buffer has unsaved changes, which it lists
+func quitAll(buffers []*buffer.Buffer) CommandResult {
+	if modified := modifiedBuffers(buffers); len(modified) > 0 {
+		return unsavedSummary(buffers, modified)
+	}
+	return CommandResult{Success: true, Message: "Goodbye!", ExitEditor: true}
+}
+
+// unsavedSummary refuses to quit, listing the modified buffers in the
+// panel with the commands that save or discard them
+func unsavedSummary(buffers []*buffer.Buffer, modified []int) CommandResult {
+	var b strings.Builder
+	for _, n := range modified {
+		name := "[No Name]"
+		if filename := buffers[n-1].Filename(); filename != "" {
+			name = displayPath(filename)
+		}
+		fmt.Fprintf(&b, "%d %s\n", n, name)
+	}
+	b.WriteString("\n:wa N saves buffer N, :wa saves them all, :wqa saves them all and quits, :qa! quits without saving\n")
+
+	message := "1 buffer has unsaved changes"
+	if len(modified) > 1 {
+		message = fmt.Sprintf("%d buffers have unsaved changes", len(modified))
+	}
+	return CommandResult{
+		Success: false,
+		Message: message + " (:wqa saves and quits, :qa! discards)",
+		Actions: []Action{ShowText{Title: "[Unsaved]", Text: b.String()}},
+	}
+}
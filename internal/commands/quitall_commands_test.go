@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestAllCommands(t *testing.T) {
+	dir := t.TempDir()
+	var buffers []*buffer.Buffer
+	for _, name := range []string{"a.txt", "b.txt", ""} {
+		buf := buffer.New()
+		if name != "" {
+			buf.SetFilename(filepath.Join(dir, name))
+		}
+		buffers = append(buffers, buf)
+	}
+	buffers[0].ReplaceLines(0, 0, []string{"a"})
+	buffers[2].ReplaceLines(0, 0, []string{"scratch"})
+
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{Buffers: func() []*buffer.Buffer { return buffers }})
+	current := buffers[1]
+
+	// Quitting lists the modified buffers, even from an unmodified one
+	for _, command := range []string{"q", "qa"} {
+		result := executor.Execute(command, current)
+		if result.Success || result.ExitEditor || !strings.HasPrefix(result.Message, "2 buffers have unsaved changes") {
+			t.Errorf(":%s = %+v", command, result)
+		}
+	}
+	_, lines, open := executor.State().ExplanationPanel(200)
+	if !open || len(lines) < 2 || lines[0] != "1 "+filepath.Join(dir, "a.txt") || lines[1] != "3 [No Name]" {
+		t.Errorf("unsaved summary = %q", lines)
+	}
+
+	// Buffers are saved by number, and all of them stop at one without a name
+	if result := executor.Execute("wa 1", current); !result.Success || buffers[0].Modified() {
+		t.Errorf(":wa 1 = %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "a" {
+		t.Errorf("a.txt = %q", data)
+	}
+	if result := executor.Execute("wqa", current); result.Success || result.ExitEditor || !strings.Contains(result.Message, "buffer 3") {
+		t.Errorf(":wqa with an unnamed buffer = %+v", result)
+	}
+
+	if result := executor.Execute("qa!", current); !result.ExitEditor {
+		t.Errorf(":qa! = %+v", result)
+	}
+	buffers = buffers[:2]
+	if result := executor.Execute("qa", current); !result.ExitEditor {
+		t.Errorf(":qa with everything saved = %+v", result)
+	}
+}
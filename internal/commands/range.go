@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/search"
+)
+
+// Range is the lines an ex command operates on, 0-based and inclusive
+type Range struct {
+	Start int
+	End   int
+}
+
+// RangeCommand is a command that accepts a line range, like :10,20d
+type RangeCommand interface {
+	Command
+
+	// ExecuteRange runs the command on the lines of r
+	ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult
+}
+
+// currentLine returns the range of the cursor line, the default of most
+// range commands
+func currentLine(buf *buffer.Buffer) Range {
+	line := buf.Cursor().Line
+	return Range{Start: line, End: line}
+}
+
+// wholeBuffer returns the range of every line, as % does
+func wholeBuffer(buf *buffer.Buffer) Range {
+	return Range{Start: 0, End: buf.LineCount() - 1}
+}
+
+// parseRange parses the line range at the start of an ex command line:
+// addresses like 10, ., $, /pattern/, ?pattern? and '< and '> (the last
+// visual selection) with +N and -N offsets, separated by , or ; (which
+// moves to the first address before the second), or % for the whole
+// buffer. It returns the range, whether one was given
+// and the rest of the command line.
+func parseRange(line string, buf *buffer.Buffer) (Range, bool, string, error) {
+	rest := strings.TrimLeft(line, " \t")
+	if after, ok := strings.CutPrefix(rest, "%"); ok {
+		return wholeBuffer(buf), true, after, nil
+	}
+
+	cursor := buf.Cursor().Line
+	var lines []int
+	for {
+		addr, after, ok, err := parseAddress(rest, cursor, buf)
+		if err != nil {
+			return Range{}, false, line, err
+		}
+		if !ok {
+			separator := strings.HasPrefix(rest, ",") || strings.HasPrefix(rest, ";")
+			if len(lines) == 0 && !separator {
+				return Range{}, false, line, nil
+			}
+			// An address left out next to a separator is the current line
+			addr = cursor
+		}
+		lines = append(lines, addr)
+		rest = after
+
+		if strings.HasPrefix(rest, ";") {
+			cursor = addr
+		} else if !strings.HasPrefix(rest, ",") {
+			break
+		}
+		rest = rest[1:]
+	}
+
+	// Only the last two addresses count
+	r := Range{Start: lines[len(lines)-1], End: lines[len(lines)-1]}
+	if len(lines) > 1 {
+		r.Start = lines[len(lines)-2]
+	}
+	if r.Start > r.End {
+		r.Start, r.End = r.End, r.Start
+	}
+	if r.Start < 0 || r.End >= buf.LineCount() {
+		return Range{}, false, line, fmt.Errorf("invalid range")
+	}
+	return r, true, rest, nil
+}
+
+// parseAddress parses one line address relative to the cursor line,
+// returning the 0-based line, the rest of s and whether s held an address
+func parseAddress(s string, cursor int, buf *buffer.Buffer) (int, string, bool, error) {
+	line, found := cursor, false
+	switch {
+	case s == "":
+		return 0, s, false, nil
+	case s[0] >= '0' && s[0] <= '9':
+		end := numberEnd(s)
+		n, _ := strconv.Atoi(s[:end])
+		// Line 0 is treated as the first line
+		line, found, s = max(n-1, 0), true, s[end:]
+	case s[0] == '.':
+		found, s = true, s[1:]
+	case s[0] == '$':
+		line, found, s = buf.LineCount()-1, true, s[1:]
+	case strings.HasPrefix(s, "'<") || strings.HasPrefix(s, "'>"):
+		start, end, ok := buf.VisualArea()
+		if !ok {
+			return 0, s, false, fmt.Errorf("mark not set: %s", s[:2])
+		}
+		line = start.Line
+		if s[1] == '>' {
+			line = end.Line
+		}
+		found, s = true, s[2:]
+	case s[0] == '/' || s[0] == '?':
+		pattern, after := cutDelimited(s[1:], s[0])
+		match, err := searchLine(pattern, cursor, s[0] == '?', buf)
+		if err != nil {
+			return 0, s, false, err
+		}
+		line, found, s = match, true, after
+	}
+
+	// Offsets, where a bare + or - means 1
+	for len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		sign := 1
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+		n, end := 1, numberEnd(s)
+		if end > 0 {
+			n, _ = strconv.Atoi(s[:end])
+			s = s[end:]
+		}
+		line += sign * n
+		found = true
+	}
+	return line, s, found, nil
+}
+
+// numberEnd returns the length of the run of digits at the start of s
+func numberEnd(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return end
+}
+
+// cutDelimited splits s at the first delim not escaped with a backslash,
+// unescaping \delim in the first part. Without a closing delim all of s is
+// the first part.
+func cutDelimited(s string, delim byte) (string, string) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == delim:
+			b.WriteByte(delim)
+			i++
+		case s[i] == delim:
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
+
+// searchLine finds the next line after the cursor line matching pattern,
+// or the previous one when backward, wrapping around the buffer
+func searchLine(pattern string, cursor int, backward bool, buf *buffer.Buffer) (int, error) {
+	if pattern == "" {
+		return 0, fmt.Errorf("empty search pattern")
+	}
+	re, err := search.Compile(pattern)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pattern: %v", err)
+	}
+	search.SetLast(pattern, backward)
+
+	count := buf.LineCount()
+	step := 1
+	if backward {
+		step = -1
+	}
+	visual := search.VisualOnly(pattern)
+	for i := 1; i <= count; i++ {
+		line := ((cursor+step*i)%count + count) % count
+		if text, _ := buf.Line(line); matchesLine(re, visual, buf, line, text) {
+			return line, nil
+		}
+	}
+	return 0, fmt.Errorf("pattern not found: %s", pattern)
+}
+
+// matchesLine reports whether re matches text, line lineNum of buf, and
+// with visual, for \%V, whether a match lies inside the visual selection
+func matchesLine(re search.Regexp, visual bool, buf *buffer.Buffer, lineNum int, text string) bool {
+	if !visual {
+		return re.MatchString(text)
+	}
+	return len(search.VisualMatches(buf, lineNum, re.FindAllStringIndex(text, -1))) > 0
+}
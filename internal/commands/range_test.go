@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// numberedBuffer returns a buffer with lines "1" to "n" and the cursor on line 5
+func numberedBuffer(n int) *buffer.Buffer {
+	buf := buffer.New()
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = string(rune('0'+(i+1)%10)) + " line"
+	}
+	buf.ReplaceLines(0, 0, lines)
+	buf.SetCursor(buffer.Position{Line: 4})
+	return buf
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		want     Range
+		hasRange bool
+		rest     string
+	}{
+		{"d", Range{}, false, "d"},
+		{"10,20d", Range{9, 19}, true, "d"},
+		{"%y", Range{0, 19}, true, "y"},
+		{".,+5>", Range{4, 9}, true, ">"},
+		{"$", Range{19, 19}, true, ""},
+		{"-2,.", Range{2, 4}, true, ""},
+		{"3;+2d", Range{2, 4}, true, "d"},
+		{",7j", Range{4, 6}, true, "j"},
+		{"8,3d", Range{2, 7}, true, "d"},
+		{"/^8/d", Range{7, 7}, true, "d"},
+		{"?^2?,$-1d", Range{1, 18}, true, "d"},
+		{"0", Range{0, 0}, true, ""},
+	}
+	for _, tt := range tests {
+		r, hasRange, rest, err := parseRange(tt.input, numberedBuffer(20))
+		if err != nil {
+			t.Errorf("parseRange(%q) error: %v", tt.input, err)
+			continue
+		}
+		if r != tt.want || hasRange != tt.hasRange || rest != tt.rest {
+			t.Errorf("parseRange(%q) = %+v, %v, %q; want %+v, %v, %q", tt.input, r, hasRange, rest, tt.want, tt.hasRange, tt.rest)
+		}
+	}
+
+	for _, input := range []string{"30d", "1,$+1d", "/nomatch/d", "/[/d"} {
+		if _, _, _, err := parseRange(input, numberedBuffer(20)); err == nil {
+			t.Errorf("parseRange(%q) succeeded", input)
+		}
+	}
+}
+
+func TestExecuteRange(t *testing.T) {
+	executor := NewCommandExecutor()
+	buf := numberedBuffer(10)
+
+	if result := executor.Execute("2,4d", buf); !result.Success || buf.LineCount() != 7 {
+		t.Fatalf(":2,4d = %+v, %d lines left", result, buf.LineCount())
+	}
+	if line, _ := buf.Line(1); line != "5 line" {
+		t.Errorf("line 2 after :2,4d is %q", line)
+	}
+
+	if result := executor.Execute("3", buf); !result.Success || buf.Cursor().Line != 2 {
+		t.Errorf(":3 = %+v, cursor %+v", result, buf.Cursor())
+	}
+	if result := executor.Execute("1,2wq", buf); result.Success {
+		t.Error("range given to a command without ranges succeeded")
+	}
+
+	// @: repeats the last command line
+	executor.Execute("1d", buf)
+	executor.RepeatLast(buf)
+	if line, _ := buf.Line(0); line != "6 line" {
+		t.Errorf("first line after :1d twice is %q", line)
+	}
+}
@@ -0,0 +1,403 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/quickfix"
+)
+
+// replaceChange is one line a workspace replace changes
+type replaceChange struct {
+	line     int // 0-based
+	old, new string
+}
+
+// replaceFile is the changes a workspace replace makes to one file
+type replaceFile struct {
+	filename string
+	changes  []replaceChange
+	skip     bool // Toggled off in the preview
+}
+
+// workspaceReplace is a substitution across the files of a list, previewed
+// until it is applied or discarded
+type workspaceReplace struct {
+	title string // The command that made it
+	stack *quickfix.Stack
+	files []*replaceFile
+}
+
+// ReplacePanel returns the title and lines of the workspace replace preview
+func (s *State) ReplacePanel() (string, []string, bool) {
+	if s.pendingReplace == nil {
+		return "", nil, false
+	}
+	title := "[Replace] " + s.pendingReplace.title + " - :replacetoggle N skips file N, :replaceapply writes, :replacediscard closes"
+	var lines []string
+	for i, f := range s.pendingReplace.files {
+		mark := "[x]"
+		if f.skip {
+			mark = "[ ]"
+		}
+		lines = append(lines, fmt.Sprintf("%d %s %s (%s)", i+1, mark, displayPath(f.filename), plural(len(f.changes), "change")))
+		for _, c := range f.changes {
+			number := strconv.Itoa(c.line + 1)
+			lines = append(lines, fmt.Sprintf("    %5s - %s", number, c.old))
+			for _, line := range strings.Split(c.new, "\n") {
+				lines = append(lines, fmt.Sprintf("    %5s + %s", number, line))
+				number = "" // Lines the replacement splits off are new
+			}
+		}
+	}
+	return title, lines, true
+}
+
+// plural formats n things, adding an s unless n is 1
+func plural(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
+
+// doCommand implements :cdo and :cfdo, and :ldo and :lfdo for the location
+// list, which preview a substitution on the lines or files of the list
+type doCommand struct {
+	location  bool
+	wholeFile bool // Every line of the files, not just the lines listed
+}
+
+// NewReplaceCommands creates :cdo, :cfdo, :ldo and :lfdo, and the commands
+// applying the replace they preview
+func NewReplaceCommands() []Command {
+	return []Command{
+		&doCommand{},
+		&doCommand{wholeFile: true},
+		&doCommand{location: true},
+		&doCommand{location: true, wholeFile: true},
+		&replaceToggleCommand{},
+		&replaceApplyCommand{},
+		&replaceDiscardCommand{},
+	}
+}
+
+func (c *doCommand) Name() string {
+	name := "do"
+	if c.wholeFile {
+		name = "fdo"
+	}
+	if c.location {
+		return "l" + name
+	}
+	return "c" + name
+}
+
+func (c *doCommand) Aliases() []string {
+	return nil
+}
+
+// textArgument makes the :s command arrive as typed
+func (c *doCommand) textArgument() {}
+
+func (c *doCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	sub, err := parseDoCommand(strings.Join(args, " "))
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	stack := s.quickfixLists
+	if c.location {
+		stack = s.locationLists
+	}
+	items := quickfixItems(stack)
+	if len(items) == 0 {
+		return CommandResult{Success: false, Message: "No entries"}
+	}
+
+	replace := &workspaceReplace{title: ":" + c.Name() + " " + strings.Join(args, " "), stack: stack}
+	substitutions := 0
+	for _, filename := range listFiles(items) {
+		lines, err := fileLines(filename, buf)
+		if err != nil {
+			return CommandResult{Success: false, Message: err.Error()}
+		}
+		f := &replaceFile{filename: filename}
+		for i, line := range lines {
+			if !c.wholeFile && !listsLine(items, filename, i) {
+				continue
+			}
+			if replaced, n := sub.apply(line); n > 0 {
+				f.changes = append(f.changes, replaceChange{line: i, old: line, new: replaced})
+				substitutions += n
+			}
+		}
+		if len(f.changes) > 0 {
+			replace.files = append(replace.files, f)
+		}
+	}
+
+	if substitutions == 0 {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Pattern not found: %s", sub.pattern)}
+	}
+	files := plural(len(replace.files), "file")
+	if sub.countOnly {
+		return CommandResult{Success: true, Message: fmt.Sprintf("%d matches in %s", substitutions, files)}
+	}
+	s.pendingReplace = replace
+	return CommandResult{Success: true, Message: fmt.Sprintf("Previewing %s in %s", plural(substitutions, "substitution"), files)}
+}
+
+func (c *doCommand) Help() string {
+	list, entries := "quickfix", "lines"
+	if c.location {
+		list = "location"
+	}
+	if c.wholeFile {
+		entries = "files"
+	}
+	return fmt.Sprintf(":%s s/pattern/replacement/[flags] - Preview substituting on the %s of the %s list, to apply with :replaceapply", c.Name(), entries, list)
+}
+
+// parseDoCommand parses the command :cdo and :cfdo run, which must be :s
+func parseDoCommand(text string) (*substitution, error) {
+	text = strings.TrimSpace(text)
+	end := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(text)
+	}
+	switch text[:end] {
+	case "s", "su", "substitute":
+		return parseSubstitute(text[end:])
+	case "":
+		return nil, errors.New("usage: s/pattern/replacement/[flags]")
+	}
+	return nil, fmt.Errorf("only :s can be run on the list: %s", text[:end])
+}
+
+// listFiles returns the files of items in the order they are listed
+func listFiles(items []quickfix.Item) []string {
+	var files []string
+	for _, item := range items {
+		if item.NoLocation || item.Filename == "" {
+			continue
+		}
+		if !slices.ContainsFunc(files, func(f string) bool { return sameFile(f, item.Filename) }) {
+			files = append(files, item.Filename)
+		}
+	}
+	return files
+}
+
+// listsLine reports whether items has an entry on line of filename
+func listsLine(items []quickfix.Item, filename string, line int) bool {
+	return slices.ContainsFunc(items, func(item quickfix.Item) bool {
+		return !item.NoLocation && item.Line == line && sameFile(item.Filename, filename)
+	})
+}
+
+// fileLines returns the lines of filename, from buf when it is being edited
+func fileLines(filename string, buf *buffer.Buffer) ([]string, error) {
+	if sameFile(filename, buf.Filename()) {
+		return buf.Lines(), nil
+	}
+	lines, _, err := readFileLines(filename)
+	return lines, err
+}
+
+// readFileLines returns the lines of filename without their line endings,
+// and the lines as they are in the file
+func readFileLines(filename string) ([]string, []string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw := strings.SplitAfter(string(data), "\n")
+	if raw[len(raw)-1] == "" {
+		raw = raw[:len(raw)-1]
+	}
+	lines := make([]string, len(raw))
+	for i, line := range raw {
+		lines[i] = strings.TrimRight(line, "\r\n")
+	}
+	return lines, raw, nil
+}
+
+// replaceToggleCommand implements :replacetoggle, which includes or skips
+// files of the previewed replace
+type replaceToggleCommand struct{}
+
+func (c *replaceToggleCommand) Name() string {
+	return "replacetoggle"
+}
+
+func (c *replaceToggleCommand) Aliases() []string {
+	return nil
+}
+
+func (c *replaceToggleCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if s.pendingReplace == nil {
+		return CommandResult{Success: false, Message: "No replace to preview"}
+	}
+	files := s.pendingReplace.files
+	if len(args) == 0 {
+		// Skip every file, or include them all when they are all skipped
+		skip := slices.ContainsFunc(files, func(f *replaceFile) bool { return !f.skip })
+		for _, f := range files {
+			f.skip = skip
+		}
+		return CommandResult{Success: true}
+	}
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(files) {
+			return CommandResult{Success: false, Message: fmt.Sprintf("No file %s in the preview", arg)}
+		}
+		files[n-1].skip = !files[n-1].skip
+	}
+	return CommandResult{Success: true}
+}
+
+func (c *replaceToggleCommand) Help() string {
+	return ":replacetoggle [N...] - Include or skip files N of the previewed replace, or all of them"
+}
+
+// replaceApplyCommand implements :replaceapply, which makes the previewed
+// changes to the files not skipped and lists the changed lines in the list
+// they came from
+type replaceApplyCommand struct{}
+
+func (c *replaceApplyCommand) Name() string {
+	return "replaceapply"
+}
+
+func (c *replaceApplyCommand) Aliases() []string {
+	return nil
+}
+
+func (c *replaceApplyCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	replace := s.pendingReplace
+	if replace == nil {
+		return CommandResult{Success: false, Message: "No replace to preview"}
+	}
+
+	var modified []string
+	var items []quickfix.Item
+	var failed []string
+	for _, f := range replace.files {
+		if f.skip {
+			continue
+		}
+		if err := applyReplace(f, buf); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		modified = append(modified, filepath.Base(f.filename))
+		// Lines split by the replacement move the lines after them down
+		shift := 0
+		for _, change := range f.changes {
+			items = append(items, quickfix.Item{Filename: f.filename, Line: change.line + shift, Text: strings.Split(change.new, "\n")[0]})
+			shift += strings.Count(change.new, "\n")
+		}
+	}
+	s.pendingReplace = nil
+
+	if len(modified) == 0 && len(failed) == 0 {
+		return CommandResult{Success: false, Message: "Every file was skipped"}
+	}
+	if len(items) > 0 {
+		replace.stack.Set(replace.title, items)
+	}
+	message := fmt.Sprintf("Replaced in %s: %s", plural(len(modified), "file"), strings.Join(modified, ", "))
+	if len(failed) > 0 {
+		return CommandResult{Success: false, Message: message + "; " + strings.Join(failed, "; ")}
+	}
+	return CommandResult{Success: true, Message: message}
+}
+
+func (c *replaceApplyCommand) Help() string {
+	return ":replaceapply - Make the previewed replace in the files not skipped, and list the changed lines"
+}
+
+// applyReplace makes the changes to f. The file being edited is changed in
+// buf, to be written with :w; other files are written, keeping their line
+// endings. Either is left alone if a line changed since the preview.
+func applyReplace(f *replaceFile, buf *buffer.Buffer) error {
+	editing := sameFile(f.filename, buf.Filename())
+	var lines, raw []string
+	if editing {
+		lines = buf.Lines()
+	} else {
+		var err error
+		if lines, raw, err = readFileLines(f.filename); err != nil {
+			return err
+		}
+	}
+	for _, change := range f.changes {
+		if change.line >= len(lines) || lines[change.line] != change.old {
+			return fmt.Errorf("%s changed since the preview", displayPath(f.filename))
+		}
+	}
+
+	// From the end, so lines split by the replacement don't move the rest
+	for i := len(f.changes) - 1; i >= 0; i-- {
+		change := f.changes[i]
+		if editing {
+			buf.ReplaceLines(change.line, change.line, strings.Split(change.new, "\n"))
+			continue
+		}
+		line := raw[change.line]
+		ending := line[len(strings.TrimRight(line, "\r\n")):]
+		separator := "\n"
+		if ending == "\r\n" {
+			separator = ending
+		}
+		raw[change.line] = strings.ReplaceAll(change.new, "\n", separator) + ending
+	}
+	if editing {
+		return nil
+	}
+
+	info, err := os.Stat(f.filename)
+	if err == nil {
+		err = os.WriteFile(f.filename, []byte(strings.Join(raw, "")), info.Mode().Perm())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", displayPath(f.filename), err)
+	}
+	return nil
+}
+
+// replaceDiscardCommand implements :replacediscard, which drops the
+// previewed replace
+type replaceDiscardCommand struct{}
+
+func (c *replaceDiscardCommand) Name() string {
+	return "replacediscard"
+}
+
+func (c *replaceDiscardCommand) Aliases() []string {
+	return nil
+}
+
+func (c *replaceDiscardCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if s.pendingReplace == nil {
+		return CommandResult{Success: false, Message: "No replace to preview"}
+	}
+	s.pendingReplace = nil
+	return CommandResult{Success: true, Message: "Replace discarded"}
+}
+
+func (c *replaceDiscardCommand) Help() string {
+	return ":replacediscard - Drop the previewed replace"
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/quickfix"
+)
+
+// replaceTestFiles writes two files containing foo and opens the first in
+// the buffer, with the lines containing foo in the quickfix list. It
+// returns an executor to run the commands with, the buffer and the other
+// file.
+func replaceTestFiles(t *testing.T) (*CommandExecutor, *buffer.Buffer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.txt")
+	other := filepath.Join(dir, "other.txt")
+	os.WriteFile(current, []byte("foo one\nbar\nfoo foo\n"), 0644)
+	os.WriteFile(other, []byte("a foo\n"), 0644)
+
+	buf, err := buffer.NewFromFile(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := NewCommandExecutor()
+	executor.State().QuickfixLists().Set("grep", []quickfix.Item{
+		{Filename: current, Line: 0},
+		{Filename: other, Line: 0},
+		{Filename: current, Line: 2},
+	})
+	return executor, buf, other
+}
+
+func TestReplaceCommands_PreviewAndApply(t *testing.T) {
+	executor, buf, other := replaceTestFiles(t)
+
+	result := executor.Execute("cdo s/foo/baz/g", buf)
+	if !result.Success || result.Message != "Previewing 4 substitutions in 2 files" {
+		t.Fatalf("cdo = %+v", result)
+	}
+	if buf.Modified() {
+		t.Error("expected the preview to leave the buffer alone")
+	}
+	title, lines, open := executor.State().ReplacePanel()
+	if !open || !strings.HasPrefix(title, "[Replace] :cdo s/foo/baz/g") {
+		t.Fatalf("panel title = %q", title)
+	}
+	if !strings.HasPrefix(lines[0], "1 [x] ") || !strings.HasSuffix(lines[0], "current.txt (2 changes)") {
+		t.Errorf("first file line = %q", lines[0])
+	}
+	if lines[1] != "        1 - foo one" || lines[2] != "        1 + baz one" {
+		t.Errorf("first change = %q", lines[1:3])
+	}
+
+	// Skip the other file
+	if result := executor.Execute("replacetoggle 2", buf); !result.Success {
+		t.Fatalf("replacetoggle = %+v", result)
+	}
+	if _, lines, _ := executor.State().ReplacePanel(); !strings.HasPrefix(lines[5], "2 [ ] ") {
+		t.Errorf("skipped file line = %q", lines[5])
+	}
+
+	result = executor.Execute("replaceapply", buf)
+	if !result.Success || result.Message != "Replaced in 1 file: current.txt" {
+		t.Fatalf("replaceapply = %+v", result)
+	}
+	if got := strings.Join(buf.Lines(), "|"); got != "baz one|bar|baz baz" || !buf.Modified() {
+		t.Errorf("buffer = %q, modified %v", got, buf.Modified())
+	}
+	if data, _ := os.ReadFile(other); string(data) != "a foo\n" {
+		t.Errorf("expected the skipped file to be left alone, got %q", data)
+	}
+	if _, _, open := executor.State().ReplacePanel(); open {
+		t.Error("expected applying to close the preview")
+	}
+	if list := executor.State().quickfixLists.Current(); list.Title != ":cdo s/foo/baz/g" || len(list.Items) != 2 || list.Items[1].Text != "baz baz" {
+		t.Errorf("quickfix list = %+v", list)
+	}
+}
+
+func TestReplaceCommands_WholeFilesWritten(t *testing.T) {
+	executor, buf, other := replaceTestFiles(t)
+	os.WriteFile(other, []byte("a foo\nfoo b\n"), 0644)
+
+	if result := executor.Execute("cfdo s/foo/x\\ry/", buf); !result.Success {
+		t.Fatalf("cfdo = %+v", result)
+	}
+	if result := executor.Execute("replaceapply", buf); !result.Success {
+		t.Fatalf("replaceapply = %+v", result)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "a x\ny\nx\ny b\n" {
+		t.Errorf("other file = %q", data)
+	}
+	// Lines split by the replacement move the entries after them
+	if items := executor.State().quickfixLists.Current().Items; items[len(items)-1].Line != 2 {
+		t.Errorf("last entry = %+v", items[len(items)-1])
+	}
+}
+
+func TestReplaceCommands_Errors(t *testing.T) {
+	executor, buf, other := replaceTestFiles(t)
+
+	if result := executor.Execute("cdo d", buf); result.Success {
+		t.Error("expected commands other than :s to fail")
+	}
+	if result := executor.Execute("cdo s/nothing/x/", buf); result.Success {
+		t.Error("expected a pattern that doesn't match to fail")
+	}
+	if result := executor.Execute("replaceapply", buf); result.Success {
+		t.Error("expected nothing to apply without a preview")
+	}
+	if result := executor.Execute("cdo s/foo/x/n", buf); !result.Success || result.Message != "3 matches in 2 files" || executor.State().pendingReplace != nil {
+		t.Errorf("n flag = %+v", result)
+	}
+
+	// A file changed after the preview is left alone
+	executor.Execute("cdo s/foo/x/", buf)
+	os.WriteFile(other, []byte("a foo changed\n"), 0644)
+	result := executor.Execute("replaceapply", buf)
+	if result.Success || !strings.Contains(result.Message, "other.txt changed since the preview") {
+		t.Errorf("replaceapply = %+v", result)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "a foo changed\n" {
+		t.Errorf("other file = %q", data)
+	}
+
+	executor.Execute("cdo s/foo/x/", buf)
+	if result := executor.Execute("replacediscard", buf); !result.Success || executor.State().pendingReplace != nil {
+		t.Errorf("replacediscard = %+v", result)
+	}
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// AIScratchpad is the name of the scratch buffer :aiscratch opens, where
+// Enter in normal mode sends the prompt at the cursor
+const AIScratchpad = "AI Scratchpad"
+
+// The lines around an answer in the AI scratchpad
+const (
+	answerStart = "--- answer ---"
+	answerEnd   = "--- end ---"
+)
+
+// scratchpad is the text of a scratch buffer and where its cursor was,
+// kept for the session while another buffer is shown
+type scratchpad struct {
+	lines  []string
+	cursor buffer.Position
+}
+
+// KeepScratch keeps the text of buf for the session if it is a scratch
+// buffer, before a file replaces it
+func (s *State) KeepScratch(buf *buffer.Buffer) {
+	if name := buf.Options().Scratch; name != "" {
+		s.scratchpads[name] = scratchpad{lines: buf.Lines(), cursor: buf.Cursor()}
+	}
+}
+
+// openScratch shows the scratch buffer name in buf, empty the first time.
+// The file shown before is remembered for :scratch to go back to.
+func (s *State) openScratch(buf *buffer.Buffer, name string) error {
+	if buf.Options().Scratch == name {
+		return nil
+	}
+	if buf.Modified() {
+		return fmt.Errorf("No write since last change (save before opening a scratch buffer)")
+	}
+	if buf.Options().Scratch != "" {
+		s.KeepScratch(buf)
+	} else {
+		cursor := buf.Cursor()
+		s.scratchReturn = OpenFile{Filename: buf.Filename(), Line: cursor.Line, Col: cursor.Col}
+	}
+
+	pad := s.scratchpads[name]
+	buf.LoadText(pad.lines)
+	options := buf.Options()
+	options.Scratch = name
+	buf.SetOptions(options)
+	if name == AIScratchpad {
+		buf.SetFiletype("markdown")
+	}
+	buf.SetCursor(pad.cursor)
+	return nil
+}
+
+// closeScratch keeps the text of the scratch buffer in buf and shows the
+// file it was opened from again
+func (s *State) closeScratch(buf *buffer.Buffer) error {
+	s.KeepScratch(buf)
+	if s.scratchReturn.Filename != "" {
+		return s.scratchReturn.apply(s, buf)
+	}
+	buf.LoadText(nil)
+	options := buf.Options()
+	options.Scratch = ""
+	buf.SetOptions(options)
+	return nil
+}
+
+// ScratchCommand implements :scratch, which shows a scratch buffer: text
+// kept for the session but never written to a file, so it has no unsaved
+// changes to prompt about. In a scratch buffer it goes back to the file.
+type ScratchCommand struct{}
+
+// NewScratchCommand creates the :scratch command
+func NewScratchCommand() *ScratchCommand {
+	return &ScratchCommand{}
+}
+
+func (c *ScratchCommand) Name() string {
+	return "scratch"
+}
+
+func (c *ScratchCommand) Aliases() []string {
+	return []string{"scr"}
+}
+
+func (c *ScratchCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	name := strings.Join(args, " ")
+	if name == "" && buf.Options().Scratch != "" {
+		if err := cc.State.closeScratch(buf); err != nil {
+			return CommandResult{Success: false, Message: capitalize(err.Error())}
+		}
+		return CommandResult{Success: true}
+	}
+	if name == "" {
+		name = "Scratch"
+	}
+	if err := cc.State.openScratch(buf, name); err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+	return CommandResult{Success: true, Message: "[" + name + "] :scratch goes back"}
+}
+
+func (c *ScratchCommand) Help() string {
+	return ":scratch [name] - Show a scratch buffer, kept for the session but never saved, or go back to the file from one"
+}
+
+// AIScratchCommand implements :aiscratch, which shows the AI scratchpad
+type AIScratchCommand struct{}
+
+// NewAIScratchCommand creates the :aiscratch command
+func NewAIScratchCommand() *AIScratchCommand {
+	return &AIScratchCommand{}
+}
+
+func (c *AIScratchCommand) Name() string {
+	return "aiscratch"
+}
+
+func (c *AIScratchCommand) Aliases() []string {
+	return nil
+}
+
+func (c *AIScratchCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if err := cc.State.openScratch(buf, AIScratchpad); err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+	return CommandResult{Success: true, Message: "[" + AIScratchpad + "] Write a prompt and press Enter in normal mode to send it"}
+}
+
+func (c *AIScratchCommand) Help() string {
+	return ":aiscratch - Show the AI scratchpad, where Enter in normal mode sends the prompt at the cursor and the answer is written below it"
+}
+
+// scratchPrompt finds the prompt around line n of the AI scratchpad: the
+// lines after the answer above it, up to the next answer or the end. answer
+// is the line of the answer right after the prompt, or -1 without one.
+func scratchPrompt(lines []string, n int) (start, end, answer int, err error) {
+	start, end, answer = 0, len(lines)-1, -1
+	for i := n; i >= 0; i-- {
+		if lines[i] == answerEnd && i < n {
+			start = i + 1
+			break
+		}
+		if lines[i] == answerStart || lines[i] == answerEnd {
+			return 0, 0, 0, fmt.Errorf("The cursor is in an answer; write the prompt below it")
+		}
+	}
+	for i := n + 1; i < len(lines); i++ {
+		if lines[i] == answerEnd {
+			return 0, 0, 0, fmt.Errorf("The cursor is in an answer; write the prompt below it")
+		}
+		if lines[i] == answerStart {
+			end, answer = i-1, i
+			break
+		}
+	}
+
+	for start <= end && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	for end >= start && strings.TrimSpace(lines[end]) == "" {
+		end--
+	}
+	if start > end {
+		return 0, 0, 0, fmt.Errorf("No prompt to send")
+	}
+	return start, end, answer, nil
+}
+
+// AISendCommand implements :aisend, which sends the prompt at the cursor
+// in the AI scratchpad and writes the answer below it, in place of the one
+// already there. The scratchpad above the prompt is the conversation.
+type AISendCommand struct{}
+
+// NewAISendCommand creates the :aisend command
+func NewAISendCommand() *AISendCommand {
+	return &AISendCommand{}
+}
+
+func (c *AISendCommand) Name() string {
+	return "aisend"
+}
+
+func (c *AISendCommand) Aliases() []string {
+	return nil
+}
+
+func (c *AISendCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	if buf.Options().Scratch != AIScratchpad {
+		return CommandResult{Success: false, Message: "Not in the AI scratchpad (:aiscratch)"}
+	}
+	if cc.AI == nil {
+		return CommandResult{Success: false, Message: "AI manager not initialized"}
+	}
+	lines := buf.Lines()
+	start, end, answer, err := scratchPrompt(lines, buf.Cursor().Line)
+	if err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+
+	var contextBuilder strings.Builder
+	contextBuilder.WriteString(cc.State.projectContext(buf))
+	if conversation := strings.TrimSpace(strings.Join(lines[:start], "\n")); conversation != "" {
+		contextBuilder.WriteString("\nConversation so far, answers between " + answerStart + " and " + answerEnd + ":\n")
+		contextBuilder.WriteString(conversation + "\n")
+	}
+	req := ai.AIRequest{
+		Prompt:  strings.Join(lines[start:end+1], "\n"),
+		Context: contextBuilder.String(),
+		Type:    ai.RequestChat,
+	}
+
+	filename := buf.Filename()
+	return askAI(cc, req, buf, 30*time.Second, func(resp *ai.AIResponse) CommandResult {
+		if buf.Filename() != filename || !slices.Equal(buf.Lines(), lines) {
+			return CommandResult{Success: false, Message: "The scratchpad changed while waiting for the answer, ask again"}
+		}
+
+		block := append([]string{answerStart}, strings.Split(strings.TrimRight(resp.Content, "\n"), "\n")...)
+		block = append(block, answerEnd)
+		after := len(block)
+		if answer >= 0 {
+			// Asking again replaces the answer
+			last := answer
+			for last < len(lines)-1 && lines[last] != answerEnd {
+				last++
+			}
+			buf.ReplaceLines(answer, last, block)
+		} else {
+			if end == len(lines)-1 {
+				// A line to write the next prompt on
+				block = append(block, "")
+			}
+			buf.ReplaceLines(end, end, append([]string{lines[end]}, block...))
+			answer = end + 1
+		}
+		buf.SetCursor(buffer.Position{Line: min(answer+after, buf.LineCount()-1)})
+		return CommandResult{Success: true, Message: withFallback("Answered by "+resp.Provider, resp)}
+	})
+}
+
+func (c *AISendCommand) Help() string {
+	return ":aisend - Send the prompt at the cursor in the AI scratchpad, writing the answer below it (Enter in normal mode)"
+}
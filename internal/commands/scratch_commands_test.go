@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestScratchCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.go")
+	os.WriteFile(file, []byte("package main\n\nfunc main() {}\n"), 0644)
+	buf, _ := buffer.NewFromFile(file)
+	buf.SetCursor(buffer.Position{Line: 2, Col: 5})
+	executor := NewCommandExecutor()
+
+	if result := executor.Execute("scratch", buf); !result.Success || buf.Options().Scratch != "Scratch" || buf.Filename() != "" {
+		t.Fatalf(":scratch gave %+v in %q", result, buf.Filename())
+	}
+	buf.InsertTextAt(0, 0, "notes")
+	if buf.Modified() {
+		t.Error("expected a scratch buffer to have no unsaved changes")
+	}
+	if result := executor.Execute("w "+filepath.Join(t.TempDir(), "notes.txt"), buf); result.Success {
+		t.Error("expected writing a scratch buffer to fail")
+	}
+
+	// Back to the file, and the scratch text is kept
+	executor.Execute("scratch", buf)
+	if buf.Filename() != file || buf.Cursor() != (buffer.Position{Line: 2, Col: 5}) || buf.Options().Scratch != "" {
+		t.Fatalf(":scratch went back to %q at %+v", buf.Filename(), buf.Cursor())
+	}
+	executor.Execute("scratch", buf)
+	if got := buf.Lines(); !slices.Equal(got, []string{"notes"}) {
+		t.Errorf("scratch buffer holds %q after going back, want the notes", got)
+	}
+
+	// Another scratch buffer, and leaving it for a file keeps its text
+	executor.Execute("scratch todo", buf)
+	buf.InsertTextAt(0, 0, "buy milk")
+	if err := (OpenFile{Filename: file}).apply(executor.State(), buf); err != nil || buf.Filename() != file {
+		t.Fatalf("opening a file from a scratch buffer failed: %v", err)
+	}
+	if pad := executor.State().scratchpads["todo"]; !slices.Equal(pad.lines, []string{"buy milk"}) {
+		t.Errorf("todo scratch buffer kept %q", pad.lines)
+	}
+
+	buf.InsertChar('x')
+	if result := executor.Execute("scratch", buf); result.Success {
+		t.Error("expected a modified file to block opening a scratch buffer")
+	}
+}
+
+func TestScratchPrompt(t *testing.T) {
+	lines := []string{"First", "", answerStart, "One", answerEnd, "", "Second", "line", ""}
+	tests := []struct {
+		line              int
+		start, end, answr int
+		err               string
+	}{
+		{0, 0, 0, 2, ""},
+		{3, 0, 0, 0, "The cursor is in an answer"},
+		{4, 0, 0, 0, "The cursor is in an answer"},
+		{8, 6, 7, -1, ""},
+		{5, 6, 7, -1, ""},
+	}
+	for _, tt := range tests {
+		start, end, answer, err := scratchPrompt(lines, tt.line)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("scratchPrompt at line %d gave error %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || start != tt.start || end != tt.end || answer != tt.answr {
+			t.Errorf("scratchPrompt at line %d = %d, %d, %d, %v, want %d, %d, %d", tt.line, start, end, answer, err, tt.start, tt.end, tt.answr)
+		}
+	}
+	if _, _, _, err := scratchPrompt([]string{answerStart, "One", answerEnd, ""}, 3); err == nil {
+		t.Error("expected an error without a prompt")
+	}
+}
+
+func TestAISendCommand(t *testing.T) {
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	var requests []ai.AIRequest
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		requests = append(requests, req)
+		return &ai.AIResponse{Content: "Answer " + string(rune('0'+len(requests))) + "\n", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	buf := buffer.New()
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+	if result := executor.Execute("aisend", buf); result.Success {
+		t.Error("expected :aisend to fail outside the AI scratchpad")
+	}
+	executor.Execute("aiscratch", buf)
+	if buf.Filetype() != "markdown" {
+		t.Errorf("AI scratchpad filetype = %q, want markdown", buf.Filetype())
+	}
+
+	buf.ReplaceLines(0, 0, []string{"Why is", "the sky blue?"})
+	if result := executor.Execute("aisend", buf); !result.Success {
+		t.Fatalf(":aisend = %+v", result)
+	}
+	want := []string{"Why is", "the sky blue?", answerStart, "Answer 1", answerEnd, ""}
+	if got := buf.Lines(); !slices.Equal(got, want) {
+		t.Fatalf("scratchpad after :aisend = %q, want %q", got, want)
+	}
+	if requests[0].Prompt != "Why is\nthe sky blue?" || buf.Cursor().Line != 5 {
+		t.Errorf("sent %q with the cursor left at %+v", requests[0].Prompt, buf.Cursor())
+	}
+
+	// The next prompt has the conversation above it
+	buf.ReplaceLines(5, 5, []string{"And at sunset?"})
+	executor.Execute("aisend", buf)
+	if !strings.Contains(requests[1].Context, "the sky blue?\n"+answerStart+"\nAnswer 1\n"+answerEnd) {
+		t.Errorf("second request context = %q", requests[1].Context)
+	}
+
+	// Asking the first again replaces its answer
+	buf.SetCursor(buffer.Position{Line: 0})
+	executor.Execute("aisend", buf)
+	want = []string{"Why is", "the sky blue?", answerStart, "Answer 3", answerEnd, "And at sunset?", answerStart, "Answer 2", answerEnd, ""}
+	if got := buf.Lines(); !slices.Equal(got, want) {
+		t.Errorf("scratchpad after asking again = %q, want %q", got, want)
+	}
+}
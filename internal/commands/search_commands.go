@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/search"
+)
+
+// NohlsearchCommand implements :nohlsearch, which hides the highlighted
+// matches until the next search
+type NohlsearchCommand struct{}
+
+// NewNohlsearchCommand creates a new nohlsearch command
+func NewNohlsearchCommand() *NohlsearchCommand {
+	return &NohlsearchCommand{}
+}
+
+func (n *NohlsearchCommand) Name() string {
+	return "nohlsearch"
+}
+
+func (n *NohlsearchCommand) Aliases() []string {
+	return []string{"noh", "nohl"}
+}
+
+func (n *NohlsearchCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	search.HideHighlight()
+	return CommandResult{Success: true}
+}
+
+func (n *NohlsearchCommand) Help() string {
+	return ":noh - Hide the highlighted search matches until the next search"
+}
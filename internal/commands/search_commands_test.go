@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/search"
+)
+
+func TestSearchOptions(t *testing.T) {
+	old := search.Current()
+	defer func() {
+		search.SetOptions(old)
+		search.SetLast("", false)
+	}()
+	search.SetOptions(search.Options{})
+	executor := NewCommandExecutor()
+
+	substitute := func(command string) string {
+		buf := buffer.New()
+		buf.ReplaceLines(0, 0, []string{"Foo foo FOO"})
+		executor.Execute(command, buf)
+		return buf.String()
+	}
+
+	if result := executor.Execute("set ic scs hls", buffer.New()); !result.Success {
+		t.Fatalf(":set = %+v", result)
+	}
+	if result := executor.Execute("set ic? smartcase? hlsearch?", buffer.New()); result.Message != "ignorecase smartcase hlsearch" {
+		t.Errorf(":set ic? = %q", result.Message)
+	}
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"s/foo/x/g", "x x x"},
+		{"s/Foo/x/g", "x foo FOO"},   // smartcase
+		{"s/Foo/x/gi", "x x x"},      // the i flag ignores case regardless
+		{`s/foo\C/x/g`, "Foo x FOO"}, // \C matches case
+		{"s/foo/x/gI", "Foo x FOO"},
+	}
+	for _, tt := range tests {
+		if got := substitute(tt.command); got != tt.want {
+			t.Errorf(":%s left %q, want %q", tt.command, got, tt.want)
+		}
+	}
+	executor.Execute("set noic", buffer.New())
+	if got := substitute(`s/foo\c/x/g`); got != "x x x" {
+		t.Errorf(`\c left %q`, got)
+	}
+
+	// :s searches, so its pattern is highlighted until :noh
+	if re := search.Highlighted(); re == nil || re.String() != "(?i)foo" {
+		t.Errorf("highlighted = %v", re)
+	}
+	if result := executor.Execute("noh", buffer.New()); !result.Success || search.Highlighted() != nil {
+		t.Errorf(":noh = %+v, highlighted %v", result, search.Highlighted())
+	}
+}
+
+func TestRegexpEngine(t *testing.T) {
+	old := search.Current()
+	defer func() {
+		search.SetOptions(old)
+		search.SetLast("", false)
+	}()
+	search.SetOptions(search.Options{})
+	executor := NewCommandExecutor()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"price: $5, cost: $7"})
+
+	if result := executor.Execute(`s/(?<=\$)\d/9/g`, buf); result.Success {
+		t.Errorf("the Go engine ran a lookbehind: %+v", result)
+	}
+	if result := executor.Execute("set re=regexp2", buf); !result.Success {
+		t.Fatalf(":set re=regexp2 = %+v", result)
+	}
+	if result := executor.Execute("set re?", buf); result.Message != "regexpengine=regexp2" {
+		t.Errorf(":set re? = %q", result.Message)
+	}
+	if result := executor.Execute(`s/(?<=\$)\d/9/g`, buf); !result.Success || buf.String() != "price: $9, cost: $9" {
+		t.Errorf("lookbehind = %+v, left %q", result, buf.String())
+	}
+	if result := executor.Execute(`s/(c)(o)/\2\1/`, buf); !result.Success || buf.String() != "price: $9, ocst: $9" {
+		t.Errorf("groups = %+v, left %q", result, buf.String())
+	}
+	if result := executor.Execute("set re=pcre", buf); result.Success || search.Current().Engine != search.EngineRegexp2 {
+		t.Errorf(":set re=pcre = %+v", result)
+	}
+}
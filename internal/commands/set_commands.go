@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/modeline"
+	"github.com/dshills/aied/internal/search"
+)
+
+// bufferOption is a per-buffer setting changed with :set and modelines
+type bufferOption struct {
+	names   []string // Full name first, then abbreviations
+	boolean bool     // Switched with "name", "noname" and "name!" instead of assigned
+	get     func(s *State, buf *buffer.Buffer) string
+	set     func(s *State, buf *buffer.Buffer, value string) error
+}
+
+// SetRuler sets whether the status line shows the ruler: the line count,
+// the cursor's byte offset and how far the view is scrolled
+func (s *State) SetRuler(on bool) {
+	s.ruler = on
+}
+
+// Ruler reports whether the status line shows the ruler
+func (s *State) Ruler() bool {
+	return s.ruler
+}
+
+// SetTimeoutLen sets how many milliseconds a prefix, operator or leader
+// waits for the key that completes it, 0 for ever
+func (s *State) SetTimeoutLen(ms int) {
+	s.timeoutLen = max(ms, 0)
+}
+
+// TimeoutLen returns how long the keys of an unfinished command wait for
+// the next, or 0 when they wait for ever
+func (s *State) TimeoutLen() time.Duration {
+	return time.Duration(s.timeoutLen) * time.Millisecond
+}
+
+// filetypeName matches the values :set filetype accepts
+var filetypeName = regexp.MustCompile(`^[A-Za-z0-9_.-]*$`)
+
+// bufferOptions are the settings :set and modelines know about
+var bufferOptions = []bufferOption{
+	{
+		names: []string{"filetype", "ft"},
+		get:   func(_ *State, buf *buffer.Buffer) string { return buf.Filetype() },
+		set: func(_ *State, buf *buffer.Buffer, value string) error {
+			if !filetypeName.MatchString(value) {
+				return fmt.Errorf("invalid filetype %q", value)
+			}
+			buf.SetFiletype(value)
+			return nil
+		},
+	},
+	intOption([]string{"tabstop", "ts"}, 1,
+		func(o buffer.Options) int { return o.TabWidth() },
+		func(o *buffer.Options, n int) { o.TabStop = n }),
+	intOption([]string{"shiftwidth", "sw"}, 0,
+		func(o buffer.Options) int { return o.ShiftWidth },
+		func(o *buffer.Options, n int) { o.ShiftWidth = n }),
+	intOption([]string{"textwidth", "tw"}, 0,
+		func(o buffer.Options) int { return o.TextWidth },
+		func(o *buffer.Options, n int) { o.TextWidth = n }),
+	boolOption([]string{"expandtab", "et"},
+		func(o buffer.Options) bool { return !o.IndentTabs },
+		func(o *buffer.Options, on bool) { o.IndentTabs = !on }),
+	boolOption([]string{"readonly", "ro"},
+		func(o buffer.Options) bool { return o.ReadOnly },
+		func(o *buffer.Options, on bool) { o.ReadOnly = on }),
+	searchOption([]string{"ignorecase", "ic"},
+		func(o search.Options) bool { return o.IgnoreCase },
+		func(o *search.Options, on bool) { o.IgnoreCase = on }),
+	searchOption([]string{"smartcase", "scs"},
+		func(o search.Options) bool { return o.SmartCase },
+		func(o *search.Options, on bool) { o.SmartCase = on }),
+	searchOption([]string{"hlsearch", "hls"},
+		func(o search.Options) bool { return o.HLSearch },
+		func(o *search.Options, on bool) { o.HLSearch = on }),
+	{
+		names:   []string{"ruler", "ru"},
+		boolean: true,
+		get:     func(s *State, _ *buffer.Buffer) string { return strconv.FormatBool(s.ruler) },
+		set: func(s *State, _ *buffer.Buffer, value string) error {
+			on, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			s.ruler = on
+			return nil
+		},
+	},
+	{
+		names: []string{"timeoutlen", "tm"},
+		get:   func(s *State, _ *buffer.Buffer) string { return strconv.Itoa(s.timeoutLen) },
+		set: func(s *State, _ *buffer.Buffer, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid number %q", value)
+			}
+			s.timeoutLen = n
+			return nil
+		},
+	},
+	{
+		names: []string{"path", "pa"},
+		get:   func(s *State, _ *buffer.Buffer) string { return s.searchPath },
+		set: func(s *State, _ *buffer.Buffer, value string) error {
+			s.searchPath = value
+			return nil
+		},
+	},
+	{
+		names:   []string{"aipreview"},
+		boolean: true,
+		get:     func(s *State, _ *buffer.Buffer) string { return strconv.FormatBool(s.previewContext) },
+		set: func(s *State, _ *buffer.Buffer, value string) error {
+			on, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			s.previewContext = on
+			return nil
+		},
+	},
+	{
+		names: []string{"regexpengine", "re"},
+		get: func(*State, *buffer.Buffer) string {
+			if engine := search.Current().Engine; engine != "" {
+				return string(engine)
+			}
+			return string(search.EngineGo)
+		},
+		set: func(_ *State, _ *buffer.Buffer, value string) error {
+			engine, err := search.ParseEngine(value)
+			if err != nil {
+				return err
+			}
+			options := search.Current()
+			options.Engine = engine
+			search.SetOptions(options)
+			return nil
+		},
+	},
+}
+
+// intOption creates a numeric option stored in the buffer's Options
+func intOption(names []string, min int, get func(buffer.Options) int, set func(*buffer.Options, int)) bufferOption {
+	return bufferOption{
+		names: names,
+		get:   func(_ *State, buf *buffer.Buffer) string { return strconv.Itoa(get(buf.Options())) },
+		set: func(_ *State, buf *buffer.Buffer, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < min {
+				return fmt.Errorf("invalid number %q", value)
+			}
+			options := buf.Options()
+			set(&options, n)
+			buf.SetOptions(options)
+			return nil
+		},
+	}
+}
+
+// boolOption creates a switch stored in the buffer's Options
+func boolOption(names []string, get func(buffer.Options) bool, set func(*buffer.Options, bool)) bufferOption {
+	return bufferOption{
+		names:   names,
+		boolean: true,
+		get:     func(_ *State, buf *buffer.Buffer) string { return strconv.FormatBool(get(buf.Options())) },
+		set: func(_ *State, buf *buffer.Buffer, value string) error {
+			on, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			options := buf.Options()
+			set(&options, on)
+			buf.SetOptions(options)
+			return nil
+		},
+	}
+}
+
+// searchOption creates a switch for searching, which applies to every
+// buffer
+func searchOption(names []string, get func(search.Options) bool, set func(*search.Options, bool)) bufferOption {
+	return bufferOption{
+		names:   names,
+		boolean: true,
+		get:     func(*State, *buffer.Buffer) string { return strconv.FormatBool(get(search.Current())) },
+		set: func(_ *State, _ *buffer.Buffer, value string) error {
+			on, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			options := search.Current()
+			set(&options, on)
+			search.SetOptions(options)
+			return nil
+		},
+	}
+}
+
+// SetCommand implements :set for buffer options
+type SetCommand struct{}
+
+// NewSetCommand creates a new set command
+func NewSetCommand() *SetCommand {
+	return &SetCommand{}
+}
+
+func (s *SetCommand) Name() string {
+	return "set"
+}
+
+func (s *SetCommand) Aliases() []string {
+	return []string{"se"}
+}
+
+func (s *SetCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	// Without arguments show every option
+	if len(args) == 0 {
+		var values []string
+		for _, opt := range bufferOptions {
+			values = append(values, opt.show(cc.State, buf))
+		}
+		return CommandResult{Success: true, Message: strings.Join(values, " ")}
+	}
+
+	var shown []string
+	for _, arg := range args {
+		value, err := applySetting(cc.State, buf, arg)
+		if err != nil {
+			return CommandResult{Success: false, Message: capitalize(err.Error())}
+		}
+		if value != "" {
+			shown = append(shown, value)
+		}
+	}
+	return CommandResult{Success: true, Message: strings.Join(shown, " ")}
+}
+
+func (s *SetCommand) Help() string {
+	return ":set [option[=value]|[no]option|option!|option?]... - Show or change buffer options (filetype, tabstop, shiftwidth, textwidth, expandtab, readonly) the search options (ignorecase, smartcase, hlsearch, regexpengine), ruler, timeoutlen and path"
+}
+
+// show formats the option's value like :set does
+func (opt bufferOption) show(s *State, buf *buffer.Buffer) string {
+	value := opt.get(s, buf)
+	if !opt.boolean {
+		return opt.names[0] + "=" + value
+	}
+	if value == "true" {
+		return opt.names[0]
+	}
+	return "no" + opt.names[0]
+}
+
+// applySetting applies one :set argument, returning the option's value
+// when the argument asks to show it
+func applySetting(s *State, buf *buffer.Buffer, arg string) (string, error) {
+	name, value, assign := strings.Cut(arg, "=")
+	query := !assign && strings.HasSuffix(name, "?")
+	toggle := !assign && strings.HasSuffix(name, "!")
+	name = strings.TrimRight(name, "?!")
+
+	opt, ok := findBufferOption(name)
+	var negate, invert bool
+	if !ok && !assign {
+		// noname and invname switch boolean options
+		for prefix, flag := range map[string]*bool{"no": &negate, "inv": &invert} {
+			if rest, found := strings.CutPrefix(name, prefix); found {
+				if o, found := findBufferOption(rest); found && o.boolean {
+					opt, ok, *flag = o, true, true
+					break
+				}
+			}
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown option: %s", name)
+	}
+
+	switch {
+	case query:
+		return opt.show(s, buf), nil
+	case assign:
+		if opt.boolean {
+			return "", fmt.Errorf("%s is a switch: use %s or no%s", opt.names[0], opt.names[0], opt.names[0])
+		}
+		if err := opt.set(s, buf, value); err != nil {
+			return "", fmt.Errorf("invalid value for %s: %v", opt.names[0], err)
+		}
+	case !opt.boolean:
+		return opt.show(s, buf), nil
+	case toggle || invert:
+		return "", opt.set(s, buf, strconv.FormatBool(opt.get(s, buf) != "true"))
+	default:
+		return "", opt.set(s, buf, strconv.FormatBool(!negate))
+	}
+	return "", nil
+}
+
+// findBufferOption looks up an option by name or abbreviation
+func findBufferOption(name string) (bufferOption, bool) {
+	for _, opt := range bufferOptions {
+		for _, n := range opt.names {
+			if n == name {
+				return opt, true
+			}
+		}
+	}
+	return bufferOption{}, false
+}
+
+// ApplyModeline applies the settings of the buffer's modelines. Only the
+// options :set knows about can be changed; anything else is ignored.
+func (s *State) ApplyModeline(buf *buffer.Buffer) {
+	for _, setting := range modeline.Find(buf.Lines(), modeline.DefaultLines) {
+		applySetting(s, buf, setting)
+	}
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"github.com/dshills/aied/internal/agent"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/conversation"
+	"github.com/dshills/aied/internal/help"
+	"github.com/dshills/aied/internal/quickfix"
+	"github.com/dshills/aied/internal/registers"
+	"github.com/dshills/aied/internal/runner"
+)
+
+// State is what the commands of an executor keep between command lines:
+// the windows they open, the work they leave pending and the editor-wide
+// options. Each executor has its own, reached through the command context,
+// so two editors or two tests don't share it.
+type State struct {
+	// Editor-wide options
+	ruler                 bool     // Whether the status line shows the ruler
+	timeoutLen            int      // Milliseconds the keys of an unfinished command wait for the next, 0 for ever
+	searchPath            string   // The path option: the directories :find and gf look in
+	previewContext        bool     // Whether AI requests are held to be previewed and trimmed first
+	rootMarkers           []string // Mark a project root (project.DefaultMarkers when empty)
+	organizeImportsOnSave bool     // Whether :w organizes the imports of the files it writes
+
+	// Set by the editor
+	redrawFunc    func()                       // Called when background jobs have new output
+	terminalFunc  func(run func() error) error // Hands the terminal to run, so sudo can ask for a password
+	keyHelp       func() []KeyHelp             // Lists the keys of the modes
+	registerStore *registers.Store             // Holds the text deleted and yanked by line commands
+
+	// Waiting for the modes to show them, or nil
+	pendingPick       *Pick
+	pendingCompose    *Compose
+	pendingSuggestion *Suggest
+
+	// The windows the commands show, closed when nil or false
+	explanation   *aiExplanation // Shown in the explanation panel until :pclose
+	helpView      *help.View
+	perfOpen      bool
+	undoTreeOpen  bool
+	mergeToolOpen bool // Shows the merge view of the conflict at the cursor
+
+	// The quickfix list and the location list of the current window
+	quickfixLists *quickfix.Stack
+	locationLists *quickfix.Stack
+
+	// The most recent :make or :test run
+	buildJob       *runner.Job
+	buildTitle     string
+	buildFormats   []*quickfix.ErrorFormat
+	buildCollected bool
+
+	// Diff mode compares the edited buffer with diffOther, named diffTitle,
+	// and is off when it is nil
+	diffOther *buffer.Buffer
+	diffTitle string
+
+	// The directories :cd changed to and from, for grep's scope and :cd -
+	cdDir       string
+	previousDir string
+
+	// The agent working on the last :agent task, shown in the agent panel
+	// until :agentstop, the directory it works in and why it stopped early
+	currentAgent *agent.Agent
+	agentRoot    string
+	agentError   error
+
+	// The :ai thread questions are added to, and the project it belongs
+	// to. A buffer of another project switches to that project's latest
+	// thread.
+	chatThread *conversation.Thread
+	chatRoot   string
+
+	// The AI request waiting in the preview, and the command line the
+	// executor runs, which makes the request again to send it
+	held        *heldRequest
+	runningLine string
+
+	pendingEdit    *aiEdit           // The :aiedit being previewed
+	pendingReplace *workspaceReplace // The :replace being previewed
+
+	// The scratch buffers of the session by name, and the file shown
+	// before the first, which :scratch goes back to
+	scratchpads   map[string]scratchpad
+	scratchReturn OpenFile
+}
+
+// NewState creates the state of an executor with the default options
+func NewState() *State {
+	return &State{
+		ruler:         true,
+		timeoutLen:    1000,
+		searchPath:    ".,,",
+		registerStore: registers.NewStore(),
+		quickfixLists: quickfix.NewStack(),
+		locationLists: quickfix.NewStack(),
+		scratchpads:   map[string]scratchpad{},
+	}
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// textCounts are the lines, words, characters and bytes of some text
+type textCounts struct {
+	lines, words, chars, bytes int
+}
+
+// countLines counts lines of text, each ending in a newline when eol is
+// set and all but the last otherwise
+func countLines(lines []string, eol bool) textCounts {
+	c := textCounts{lines: len(lines)}
+	for i, line := range lines {
+		c.words += len(strings.Fields(line))
+		c.chars += utf8.RuneCountInString(line)
+		c.bytes += len(line)
+		if eol || i < len(lines)-1 {
+			c.chars++
+			c.bytes++
+		}
+	}
+	return c
+}
+
+// textBetween returns the lines of text from start up to end, which is
+// exclusive, clamping both to the buffer
+func textBetween(buf *buffer.Buffer, start, end buffer.Position) []string {
+	var lines []string
+	for n := max(start.Line, 0); n <= end.Line && n < buf.LineCount(); n++ {
+		line, _ := buf.Line(n)
+		from, to := 0, len(line)
+		if n == start.Line {
+			from = min(max(start.Col, 0), len(line))
+		}
+		if n == end.Line {
+			to = min(max(end.Col, from), len(line))
+		}
+		lines = append(lines, line[from:to])
+	}
+	return lines
+}
+
+// SelectionStats describes the text from start up to end, which is
+// exclusive, against the whole buffer, for g Ctrl-G over a selection.
+// Columns are byte offsets, like the cursor's.
+func SelectionStats(buf *buffer.Buffer, start, end buffer.Position) string {
+	return selectedStats(countLines(textBetween(buf, start, end), false), countLines(buf.Lines(), true))
+}
+
+// selectedStats formats the counts of the selected text against those of
+// the buffer like Vim's g Ctrl-G
+func selectedStats(selected, total textCounts) string {
+	return fmt.Sprintf("Selected %d of %d Lines; %d of %d Words; %d of %d Chars; %d of %d Bytes",
+		selected.lines, total.lines, selected.words, total.words, selected.chars, total.chars, selected.bytes, total.bytes)
+}
+
+// cursorStats describes where the cursor is in the buffer by column,
+// line, word, character and byte. Columns count bytes, as in Vim.
+func cursorStats(buf *buffer.Buffer) string {
+	cursor := buf.Cursor()
+	line := buf.CurrentLine()
+	lineLen := len(line)
+	total := countLines(buf.Lines(), true)
+
+	// Up to and including the character under the cursor
+	_, size := utf8.DecodeRuneInString(line[min(cursor.Col, lineLen):])
+	before := countLines(textBetween(buf, buffer.Position{}, buffer.Position{Line: cursor.Line, Col: cursor.Col + size}), false)
+	return fmt.Sprintf("Col %d of %d; Line %d of %d; Word %d of %d; Char %d of %d; Byte %d of %d",
+		min(cursor.Col+1, max(lineLen, 1)), lineLen, cursor.Line+1, total.lines, before.words, total.words,
+		before.chars, total.chars, buf.ByteOffset(cursor)+1, total.bytes)
+}
+
+// StatsCommand implements :stats, which counts the lines, words,
+// characters and bytes of the buffer or of a range of its lines
+type StatsCommand struct{}
+
+// NewStatsCommand creates the :stats command
+func NewStatsCommand() *StatsCommand {
+	return &StatsCommand{}
+}
+
+func (c *StatsCommand) Name() string {
+	return "stats"
+}
+
+func (c *StatsCommand) Aliases() []string {
+	return nil
+}
+
+func (c *StatsCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return CommandResult{Success: true, Message: cursorStats(buf)}
+}
+
+func (c *StatsCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	selected := countLines(textBetween(buf, buffer.Position{Line: r.Start}, buffer.Position{Line: r.End, Col: math.MaxInt}), true)
+	return CommandResult{Success: true, Message: selectedStats(selected, countLines(buf.Lines(), true))}
+}
+
+func (c *StatsCommand) Help() string {
+	return ":[range]stats - Count the lines, words, characters and bytes of the buffer, with the cursor's place in them, or of the lines in range"
+}
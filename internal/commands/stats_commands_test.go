@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestStats(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"The quick brown", "fox jumps", "", "héllo world"})
+	executor := NewCommandExecutor()
+
+	// The cursor on the j of jumps
+	buf.SetCursor(buffer.Position{Line: 1, Col: 4})
+	want := "Col 5 of 9; Line 2 of 4; Word 5 of 7; Char 21 of 39; Byte 21 of 40"
+	if result := executor.Execute("stats", buf); result.Message != want {
+		t.Errorf(":stats = %q, want %q", result.Message, want)
+	}
+
+	want = "Selected 2 of 4 Lines; 2 of 7 Words; 11 of 39 Chars; 11 of 40 Bytes"
+	if result := executor.Execute("2,3stats", buf); result.Message != want {
+		t.Errorf(":2,3stats = %q, want %q", result.Message, want)
+	}
+
+	// A selection within lines counts only the newlines inside it
+	want = "Selected 2 of 4 Lines; 2 of 7 Words; 9 of 39 Chars; 9 of 40 Bytes"
+	if got := SelectionStats(buf, buffer.Position{Line: 0, Col: 10}, buffer.Position{Line: 1, Col: 3}); got != want {
+		t.Errorf("SelectionStats = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,297 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/search"
+)
+
+// textArgCommand marks commands that take their argument text as typed,
+// like the pattern of :s/a  b/c/, instead of split into words. The text
+// arrives as the single argument.
+type textArgCommand interface {
+	textArgument()
+}
+
+// isTextArg reports whether cmd takes its argument text as typed
+func isTextArg(cmd Command) bool {
+	_, ok := cmd.(textArgCommand)
+	return ok
+}
+
+// SubstituteCommand implements :s/pattern/replacement/flags
+type SubstituteCommand struct{}
+
+// NewSubstituteCommand creates a new substitute command
+func NewSubstituteCommand() *SubstituteCommand {
+	return &SubstituteCommand{}
+}
+
+func (s *SubstituteCommand) Name() string {
+	return "substitute"
+}
+
+func (s *SubstituteCommand) Aliases() []string {
+	return []string{"s", "su"}
+}
+
+func (s *SubstituteCommand) textArgument() {}
+
+func (s *SubstituteCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return s.ExecuteRange(cc, currentLine(buf), args, buf)
+}
+
+func (s *SubstituteCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	sub, err := parseSubstitute(strings.Join(args, " "))
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	search.SetLast(sub.pattern, false)
+	if sub.count > 0 {
+		r = Range{Start: r.End, End: min(r.End+sub.count-1, buf.LineCount()-1)}
+	}
+
+	lines := buf.Lines()
+	var replaced []string
+	substitutions, changedLines, lastLine := 0, 0, -1
+	if search.Multiline(sub.pattern) {
+		// A pattern matching line breaks sees the rest of the buffer as one
+		// text, so a match starting in the range may end below it
+		var text string
+		text, substitutions, changedLines, lastLine = sub.applyLines(buf, r.Start, r.End-r.Start+1)
+		replaced = strings.Split(text, "\n")
+		r.End = len(lines) - 1
+	} else {
+		for i := r.Start; i <= r.End; i++ {
+			matches := sub.re.FindAllStringSubmatchIndex(lines[i], -1)
+			if sub.visual {
+				matches = search.VisualMatches(buf, i, matches)
+			}
+			line, n := sub.replace(lines[i], matches)
+			if n == 0 {
+				replaced = append(replaced, lines[i])
+				continue
+			}
+			substitutions += n
+			changedLines++
+			lastLine = i
+			replaced = append(replaced, strings.Split(line, "\n")...)
+		}
+		// Lines split by the replacement move the last changed line down
+		lastLine += len(replaced) - (r.End - r.Start + 1)
+	}
+
+	if substitutions == 0 {
+		if sub.quiet {
+			return CommandResult{Success: true}
+		}
+		return CommandResult{Success: false, Message: fmt.Sprintf("Pattern not found: %s", sub.pattern)}
+	}
+	message := fmt.Sprintf("%d substitutions on %d lines", substitutions, changedLines)
+	if sub.countOnly {
+		return CommandResult{Success: true, Message: fmt.Sprintf("%d matches on %d lines", substitutions, changedLines)}
+	}
+
+	buf.ReplaceLines(r.Start, r.End, replaced)
+	buf.SetCursor(buffer.Position{Line: lastLine})
+	return CommandResult{Success: true, Message: message}
+}
+
+func (s *SubstituteCommand) Help() string {
+	return ":[range]s/pattern/replacement/[flags] [count] - Replace matches of a regular expression; & and \\1-\\9 insert the match and groups, \\r a line break; flags g (all), i (ignore case), I (match case), n (count only), e (no error without a match); \\c or \\C in the pattern ignores or matches case, \\n matches a line break, joining lines, and \\%V keeps to matches inside the last visual selection"
+}
+
+// substitution is a parsed :s command
+type substitution struct {
+	pattern   string
+	re        search.Regexp
+	template  string // Replacement in regexp.Expand form
+	all       bool   // g flag: every match on a line, not just the first
+	countOnly bool   // n flag: count matches without replacing
+	quiet     bool   // e flag: no error when the pattern isn't found
+	visual    bool   // \%V in the pattern: only matches in the visual selection
+	count     int    // Number of lines from the end of the range
+}
+
+// parseSubstitute parses the text after :s, which starts with the
+// delimiter: /pattern/replacement/flags count
+func parseSubstitute(text string) (*substitution, error) {
+	if text == "" {
+		return nil, fmt.Errorf("usage: :s/pattern/replacement/[flags]")
+	}
+	delim := text[0]
+	if !validDelimiter(delim) {
+		return nil, fmt.Errorf("invalid delimiter: %c", delim)
+	}
+
+	pattern, rest := cutDelimited(text[1:], delim)
+	replacement, rest := cutDelimited(rest, delim)
+	if pattern == "" {
+		return nil, fmt.Errorf("empty search pattern")
+	}
+
+	sub := &substitution{pattern: pattern, template: replacementTemplate(replacement), visual: search.VisualOnly(pattern)}
+	rest = strings.TrimLeft(rest, " ")
+	end := 0
+	for end < len(rest) && unicode.IsLetter(rune(rest[end])) {
+		end++
+	}
+	flags, countText := rest[:end], rest[end:]
+	options := search.Current()
+	for _, flag := range flags {
+		switch flag {
+		case 'g':
+			sub.all = true
+		case 'i':
+			options.IgnoreCase, options.SmartCase = true, false
+		case 'I':
+			options.IgnoreCase = false
+		case 'n':
+			sub.countOnly = true
+		case 'e':
+			sub.quiet = true
+		default:
+			return nil, fmt.Errorf("invalid flag: %c", flag)
+		}
+	}
+	if countText = strings.TrimSpace(countText); countText != "" {
+		n, err := strconv.Atoi(countText)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("trailing characters: %s", countText)
+		}
+		sub.count = n
+	}
+
+	re, err := options.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	sub.re = re
+	return sub, nil
+}
+
+// validDelimiter reports whether ch can delimit the pattern of :s and :g:
+// anything but a letter, digit, space, backslash, " or |
+func validDelimiter(ch byte) bool {
+	return strings.IndexByte(` \"|`, ch) < 0 && !unicode.IsLetter(rune(ch)) && !unicode.IsDigit(rune(ch))
+}
+
+// replacementTemplate converts a Vim replacement string to regexp.Expand
+// form: & and \0 are the whole match, \1-\9 groups, \r and \n line breaks
+// and \& a literal &
+func replacementTemplate(replacement string) string {
+	var b strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		ch := replacement[i]
+		switch {
+		case ch == '$':
+			b.WriteString("$$")
+		case ch == '&':
+			b.WriteString("${0}")
+		case ch == '\\' && i+1 < len(replacement):
+			i++
+			next := replacement[i]
+			switch {
+			case next >= '0' && next <= '9':
+				b.WriteString("${" + string(next) + "}")
+			case next == 'r' || next == 'n':
+				b.WriteByte('\n')
+			case next == 't':
+				b.WriteByte('\t')
+			case next == '$':
+				b.WriteString("$$")
+			default:
+				b.WriteByte(next)
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// apply substitutes in line, returning the result and the number of
+// substitutions made
+func (s *substitution) apply(line string) (string, int) {
+	return s.replace(line, s.re.FindAllStringSubmatchIndex(line, -1))
+}
+
+// replace substitutes the first of matches in line, or all of them with
+// the g flag, returning the result and the number of substitutions made
+func (s *substitution) replace(line string, matches [][]int) (string, int) {
+	if len(matches) == 0 {
+		return line, 0
+	}
+	if !s.all {
+		matches = matches[:1]
+	}
+	return s.expand(line, matches), len(matches)
+}
+
+// applyLines substitutes in the lines of buf from first on joined into
+// one text, for patterns matching line breaks, replacing the matches that
+// start on the count lines from first. Without the g flag only the first
+// match starting on each line is replaced. It returns the result, the
+// number of substitutions, the number of lines they started on and the
+// line the last one starts on once replaced.
+func (s *substitution) applyLines(buf *buffer.Buffer, first, count int) (string, int, int, int) {
+	lines := buf.Lines()[first:]
+	text := strings.Join(lines, "\n")
+	starts := make([]int, len(lines)) // Offset of each line in text
+	for i := 1; i < len(lines); i++ {
+		starts[i] = starts[i-1] + len(lines[i-1]) + 1
+	}
+	position := func(offset int) buffer.Position {
+		line := sort.SearchInts(starts, offset+1) - 1
+		return buffer.Position{Line: first + line, Col: offset - starts[line]}
+	}
+
+	var matches [][]int
+	changedLines, prevLine := 0, -1
+	for _, m := range s.re.FindAllStringSubmatchIndex(text, -1) {
+		start := position(m[0])
+		if start.Line >= first+count {
+			break
+		}
+		if s.visual && !buf.InVisualArea(start, position(m[1])) {
+			continue
+		}
+		if start.Line == prevLine && !s.all {
+			continue
+		}
+		if start.Line != prevLine {
+			changedLines++
+			prevLine = start.Line
+		}
+		matches = append(matches, m)
+	}
+	if len(matches) == 0 {
+		return text, 0, 0, -1
+	}
+
+	// The text after the earlier replacements is unchanged, so the last
+	// match has moved by the difference in length
+	last := len(matches) - 1
+	before := s.expand(text, matches[:last])
+	lastStart := matches[last][0] + len(before) - len(text)
+	return s.expand(text, matches), len(matches), changedLines, first + strings.Count(before[:lastStart], "\n")
+}
+
+// expand replaces matches, which are in order, in text with the
+// replacement
+func (s *substitution) expand(text string, matches [][]int) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m[0]])
+		b.Write(s.re.ExpandString(nil, s.template, text, m))
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+		success bool
+	}{
+		{"s/foo/bar/", "bar foo\nfoo baz\nqux", true},
+		{"s/foo/bar/g", "bar bar\nfoo baz\nqux", true},
+		{"%s/foo/bar/g", "bar bar\nbar baz\nqux", true},
+		{"%s/FOO/x/i", "x foo\nx baz\nqux", true},
+		{"%s#o+#0#g", "f0 f0\nf0 baz\nqux", true},
+		{"2s/b\\w+/[&]/", "foo foo\nfoo [baz]\nqux", true},
+		{"2s/(b)(az)/\\2\\1 $1/", "foo foo\nfoo azb $1\nqux", true},
+		{"s/ /\\r/", "foo\nfoo\nfoo baz\nqux", true},
+		{"s/foo/x/ 2", "x foo\nx baz\nqux", true},
+		{"s/foo/x/g2", "x x\nx baz\nqux", true},
+		{"%s/foo/x/n", "foo foo\nfoo baz\nqux", true},
+		{"s/nothing/x/", "foo foo\nfoo baz\nqux", false},
+		{"s/foo/x/z", "foo foo\nfoo baz\nqux", false},
+		{"sa/b/", "foo foo\nfoo baz\nqux", false},
+	}
+
+	for _, tt := range tests {
+		buf := buffer.New()
+		buf.ReplaceLines(0, 0, []string{"foo foo", "foo baz", "qux"})
+		result := NewCommandExecutor().Execute(tt.command, buf)
+		if result.Success != tt.success {
+			t.Errorf(":%s success = %v: %s", tt.command, result.Success, result.Message)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf(":%s left %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestSubstitute_Messages(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a a", "b", "a"})
+	executor := NewCommandExecutor()
+
+	if result := executor.Execute("%s/a/x/gn", buf); result.Message != "3 matches on 2 lines" {
+		t.Errorf(":%%s///gn message = %q", result.Message)
+	}
+	if result := executor.Execute("%s/a/x/g", buf); result.Message != "3 substitutions on 2 lines" {
+		t.Errorf(":%%s///g message = %q", result.Message)
+	}
+	if cursor := buf.Cursor(); cursor.Line != 2 {
+		t.Errorf("cursor after :%%s on line %d, want the last changed line 3", cursor.Line+1)
+	}
+	if result := executor.Execute("s/a/x/", buf); result.Success || !strings.Contains(result.Message, "Pattern not found") {
+		t.Errorf(":s without a match = %+v", result)
+	}
+	if result := executor.Execute("s/a/x/e", buf); !result.Success || result.Message != "" {
+		t.Errorf(":s with e without a match = %+v", result)
+	}
+}
+
+func TestSubstitute_AcrossLines(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{`%s/,\n\s*/, /`, "call(a, b, c)\nend"},
+		{`%s/\n//`, "call(a,  b,  c)end"},
+		{`1s/(a,)\n\s*/\1 /`, "call(a, b,\n  c)\nend"},
+		{`3s/\)\n/);/`, "call(a,\n  b,\n  c);end"},
+		{`%s/^\s+//`, "call(a,\nb,\nc)\nend"},
+	}
+	for _, tt := range tests {
+		buf := buffer.New()
+		buf.ReplaceLines(0, 0, []string{"call(a,", "  b,", "  c)", "end"})
+		if result := NewCommandExecutor().Execute(tt.command, buf); !result.Success {
+			t.Errorf(":%s failed: %s", tt.command, result.Message)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf(":%s left %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "a", "b"})
+	result := NewCommandExecutor().Execute(`%s/a\nb/x/`, buf)
+	if result.Message != "2 substitutions on 2 lines" || buf.String() != "x\nx" {
+		t.Errorf(":%%s/a\\nb/x/ = %q, left %q", result.Message, buf.String())
+	}
+	if cursor := buf.Cursor(); cursor.Line != 1 {
+		t.Errorf("cursor on line %d, want the last substitution's line 2", cursor.Line+1)
+	}
+}
+
+func TestSubstitute_Visual(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		// The selection runs from "b" on line 1 to "b" on line 2
+		{`'<,'>s/a/x/g`, "x b x\nx b x"},
+		{`'<,'>s/\%Va/x/g`, "a b x\nx b a"},
+		{`%s/\%V\w/_/g`, "a _ _\n_ _ a"},
+		{`%s/\%Va\n/x/`, "a b xa b a"},
+	}
+	for _, tt := range tests {
+		buf := buffer.New()
+		buf.ReplaceLines(0, 0, []string{"a b a", "a b a"})
+		buf.SetVisualArea(buffer.Position{Line: 0, Col: 2}, buffer.Position{Line: 1, Col: 2})
+		NewCommandExecutor().Execute(tt.command, buf)
+		if got := buf.String(); got != tt.want {
+			t.Errorf(":%s left %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a"})
+	if result := NewCommandExecutor().Execute(`s/\%Va/x/`, buf); result.Success {
+		t.Errorf(`\%%V without a selection = %+v`, result)
+	}
+}
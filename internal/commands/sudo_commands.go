@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// privilegedTools are tried in order for :SudoWrite
+var privilegedTools = []string{"sudo", "doas"}
+
+// SetTerminalFunc sets the function that suspends the editor while a
+// command uses the terminal
+func (s *State) SetTerminalFunc(fn func(run func() error) error) {
+	s.terminalFunc = fn
+}
+
+// SudoWriteCommand writes the buffer with root privileges by piping it
+// through "sudo tee" (or doas), for files the user can't write
+type SudoWriteCommand struct{}
+
+// NewSudoWriteCommand creates a new sudo write command
+func NewSudoWriteCommand() *SudoWriteCommand {
+	return &SudoWriteCommand{}
+}
+
+func (s *SudoWriteCommand) Name() string {
+	return "SudoWrite"
+}
+
+func (s *SudoWriteCommand) Aliases() []string {
+	return []string{}
+}
+
+func (s *SudoWriteCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	filename := buf.Filename()
+	if len(args) > 0 {
+		filename = resolvePath(buf, args[0])
+	} else if buf.FileIndicator() != "" {
+		return CommandResult{Success: false, Message: ":SudoWrite only writes local files"}
+	}
+	if filename == "" {
+		return CommandResult{Success: false, Message: "No file name specified"}
+	}
+
+	tool, err := privilegedTool()
+	if err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+
+	if err := buf.SaveWith(filename, func(filename, content string) error {
+		return cc.State.privilegedWrite(tool, filename, content)
+	}); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error writing file: %s", err.Error())}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("File written with %s: %s", filepath.Base(tool), filename)}
+}
+
+func (s *SudoWriteCommand) Help() string {
+	return ":SudoWrite [filename] - Write buffer to a file you don't have permission to write, using sudo or doas"
+}
+
+// privilegedTool returns the first installed privileged tool
+func privilegedTool() (string, error) {
+	for _, tool := range privilegedTools {
+		if path, err := exec.LookPath(tool); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("neither %s is installed", strings.Join(privilegedTools, " nor "))
+}
+
+// privilegedWrite pipes content through "tool tee filename" with the
+// editor suspended, so the tool can prompt for a password
+func (s *State) privilegedWrite(tool, filename, content string) error {
+	cmd := exec.Command(tool, "tee", "--", filename)
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = os.Stderr
+
+	run := cmd.Run
+	if s.terminalFunc != nil {
+		return s.terminalFunc(run)
+	}
+	return run()
+}
+
+// permissionHint suggests :SudoWrite when a write failed for lack of permission
+func permissionHint(err error) string {
+	if errors.Is(err, os.ErrPermission) {
+		return " (use :SudoWrite to write it with sudo)"
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestSudoWriteCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	// A stand-in for sudo that runs its arguments unprivileged
+	tool := filepath.Join(dir, "fakesudo")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\nexec \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldTools := privilegedTools
+	defer func() { privilegedTools = oldTools }()
+	privilegedTools = []string{tool}
+
+	suspended := 0
+	cc := &CommandContext{State: NewState()}
+	cc.State.SetTerminalFunc(func(run func() error) error {
+		suspended++
+		return run()
+	})
+
+	target := filepath.Join(dir, "hosts")
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "127.0.0.1 localhost")
+
+	result := NewSudoWriteCommand().Execute(cc, []string{target}, buf)
+	if !result.Success {
+		t.Fatalf(":SudoWrite failed: %s", result.Message)
+	}
+	data, _ := os.ReadFile(target)
+	if string(data) != "127.0.0.1 localhost" {
+		t.Errorf("file content = %q", data)
+	}
+	if suspended != 1 {
+		t.Errorf("terminal suspended %d times, want 1", suspended)
+	}
+	if buf.Modified() || buf.Filename() != target {
+		t.Errorf("buffer not marked saved: modified=%v filename=%q", buf.Modified(), buf.Filename())
+	}
+
+	privilegedTools = []string{filepath.Join(dir, "missing")}
+	if result := NewSudoWriteCommand().Execute(cc, nil, buf); result.Success {
+		t.Error("expected :SudoWrite to fail without sudo or doas")
+	}
+}
+
+func TestPermissionHint(t *testing.T) {
+	err := fmt.Errorf("failed to create file: %w", os.ErrPermission)
+	if hint := permissionHint(err); !strings.Contains(hint, ":SudoWrite") {
+		t.Errorf("permissionHint = %q, want a :SudoWrite suggestion", hint)
+	}
+	if hint := permissionHint(os.ErrNotExist); hint != "" {
+		t.Errorf("permissionHint = %q for a missing file", hint)
+	}
+}
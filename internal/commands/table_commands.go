@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// IsTableRow reports whether line is a row of a markdown pipe table, which
+// starts with |
+func IsTableRow(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " \t"), "|")
+}
+
+// tableBounds returns the lines of the table around line n
+func tableBounds(buf *buffer.Buffer, n int) (Range, bool) {
+	isRow := func(n int) bool {
+		line, _ := buf.Line(n)
+		return IsTableRow(line)
+	}
+	if !isRow(n) {
+		return Range{}, false
+	}
+	r := Range{Start: n, End: n}
+	for r.Start > 0 && isRow(r.Start-1) {
+		r.Start--
+	}
+	for r.End < buf.LineCount()-1 && isRow(r.End+1) {
+		r.End++
+	}
+	return r, true
+}
+
+// tablePipes returns the byte offsets of the | separating the cells of
+// row, passing over the escaped \|
+func tablePipes(row string) []int {
+	var pipes []int
+	for i := 0; i < len(row); i++ {
+		if row[i] == '|' && (i == 0 || row[i-1] != '\\') {
+			pipes = append(pipes, i)
+		}
+	}
+	return pipes
+}
+
+// isTableRuleRow reports whether row is the rule under a table's header
+func isTableRuleRow(row string) bool {
+	l, ok := splitAligned(row, '|')
+	if !ok {
+		return false
+	}
+	for _, cell := range l.cells[1 : len(l.cells)-1] {
+		if !isTableRule(cell) {
+			return false
+		}
+	}
+	return len(l.cells) > 2
+}
+
+// tableCell returns which cell of row the byte offset col is in, counting
+// from 0, or -1 before the first |
+func tableCell(row string, col int) int {
+	cell := -1
+	for _, pipe := range tablePipes(row) {
+		if pipe >= col {
+			break
+		}
+		cell++
+	}
+	return cell
+}
+
+// tableCellStart returns where the text of cell starts in row: after the
+// space following its |, or at the end of a row without the cell
+func tableCellStart(row string, cell int) int {
+	pipes := tablePipes(row)
+	if cell < 0 || cell >= len(pipes) {
+		return len(row)
+	}
+	col := pipes[cell] + 1
+	for col < len(row) && row[col] == ' ' {
+		col++
+	}
+	if cell == len(pipes)-1 || col < pipes[cell+1] {
+		return col
+	}
+	// An empty cell
+	return min(pipes[cell]+2, len(row))
+}
+
+// formatTable aligns the rows of a table, first giving the rows ending in
+// | the cells they lack
+func formatTable(rows []string) []string {
+	cells := 0
+	for _, row := range rows {
+		cells = max(cells, len(tablePipes(row))-1)
+	}
+	rows = slices.Clone(rows)
+	for i, row := range rows {
+		missing := cells - (len(tablePipes(row)) - 1)
+		if missing <= 0 || !strings.HasSuffix(row, "|") {
+			continue
+		}
+		fill := " |"
+		if isTableRuleRow(row) {
+			fill = "---|"
+		}
+		rows[i] = row + strings.Repeat(fill, missing)
+	}
+	aligned, _ := alignLines(rows, '|')
+	return aligned
+}
+
+// FormatTable aligns the markdown table around line n, keeping the cursor
+// in the same place of its cell. It reports whether n is in a table.
+func FormatTable(buf *buffer.Buffer, n int) bool {
+	r, ok := tableBounds(buf, n)
+	if !ok {
+		return false
+	}
+	rows := buf.Lines()[r.Start : r.End+1]
+	formatted := formatTable(rows)
+	if slices.Equal(rows, formatted) {
+		return true
+	}
+
+	cursor := buf.Cursor()
+	row := rows[cursor.Line-r.Start]
+	cell := tableCell(row, cursor.Col)
+	buf.ReplaceLines(r.Start, r.End, formatted)
+	if cursor.Line < r.Start || cursor.Line > r.End || cell < 0 {
+		buf.SetCursor(cursor)
+		return true
+	}
+
+	// Right after the | stays there, and within the text of the cell the
+	// cursor keeps its place in the text
+	newRow := formatted[cursor.Line-r.Start]
+	pipe, newPipe := tablePipes(row)[cell], tablePipes(newRow)[cell]
+	col := newPipe + 1
+	if cursor.Col > pipe+1 {
+		typed := strings.TrimLeft(row[pipe+1:cursor.Col], " ")
+		col = tableCellStart(newRow, cell) + len(typed)
+	}
+	buf.SetCursor(buffer.Position{Line: cursor.Line, Col: min(col, len(newRow))})
+	return true
+}
+
+// NextTableCell aligns the markdown table at the cursor and moves to the
+// start of the next cell, or the previous one when backward, passing over
+// the rule under the header. Going past the last cell adds a row. It
+// reports whether the cursor is in a table.
+func NextTableCell(buf *buffer.Buffer, backward bool) bool {
+	// A row being typed is closed with a |
+	n := buf.Cursor().Line
+	if current := strings.TrimRight(buf.CurrentLine(), " \t"); IsTableRow(current) {
+		if pipes := tablePipes(current); pipes[len(pipes)-1] != len(current)-1 {
+			buf.ReplaceLines(n, n, []string{current + " |"})
+		}
+	}
+	if !FormatTable(buf, n) {
+		return false
+	}
+	r, _ := tableBounds(buf, buf.Cursor().Line)
+	line, cell := buf.Cursor().Line, tableCell(buf.CurrentLine(), buf.Cursor().Col)
+	row := func(n int) string {
+		text, _ := buf.Line(n)
+		return text
+	}
+	cells := func(n int) int {
+		return len(tablePipes(row(n))) - 1
+	}
+
+	if backward {
+		cell--
+		for cell < 0 || isTableRuleRow(row(line)) {
+			if line == r.Start {
+				return true
+			}
+			line--
+			cell = cells(line) - 1
+		}
+	} else {
+		cell++
+		for cell >= cells(line) || isTableRuleRow(row(line)) {
+			if line == r.End {
+				addTableRow(buf, line)
+			}
+			line, cell = line+1, 0
+		}
+	}
+	buf.SetCursor(buffer.Position{Line: line, Col: tableCellStart(row(line), cell)})
+	return true
+}
+
+// addTableRow adds an empty row after line n of a table, with as many
+// cells as the row above it, and aligns the table
+func addTableRow(buf *buffer.Buffer, n int) {
+	above, _ := buf.Line(n)
+	indent := above[:len(above)-len(strings.TrimLeft(above, " \t"))]
+	cells := max(len(tablePipes(above))-1, 1)
+	buf.ReplaceLines(n, n, []string{above, indent + "|" + strings.Repeat("  |", cells)})
+	FormatTable(buf, n)
+}
+
+// addTableColumn adds an empty column after the given cell of every row
+// of the table r, and aligns the table
+func addTableColumn(buf *buffer.Buffer, r Range, cell int) {
+	rows := buf.Lines()[r.Start : r.End+1]
+	for i, row := range rows {
+		pipes := tablePipes(row)
+		if cell+1 >= len(pipes) {
+			continue
+		}
+		fill := "  |"
+		if isTableRuleRow(row) {
+			fill = "---|"
+		}
+		at := pipes[cell+1] + 1
+		rows[i] = row[:at] + fill + row[at:]
+	}
+	buf.ReplaceLines(r.Start, r.End, formatTable(rows))
+}
+
+// TableCommand implements :table, which aligns the markdown table at the
+// cursor, and :table row and :table column, which add an empty row below
+// the cursor or a column after it
+type TableCommand struct{}
+
+// NewTableCommand creates the :table command
+func NewTableCommand() *TableCommand {
+	return &TableCommand{}
+}
+
+func (c *TableCommand) Name() string {
+	return "table"
+}
+
+func (c *TableCommand) Aliases() []string {
+	return nil
+}
+
+func (c *TableCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	cursor := buf.Cursor()
+	r, ok := tableBounds(buf, cursor.Line)
+	if !ok {
+		return CommandResult{Success: false, Message: "Not in a table"}
+	}
+	if len(args) > 1 {
+		return tableUsage()
+	}
+
+	row := buf.CurrentLine()
+	cell := max(tableCell(row, cursor.Col), 0)
+	switch strings.Join(args, "") {
+	case "":
+		FormatTable(buf, cursor.Line)
+		return CommandResult{Success: true, Message: linesMessage(r.End-r.Start+1, "aligned")}
+	case "row":
+		// Below the rule when on the header
+		line := cursor.Line
+		if next, _ := buf.Line(line + 1); line < r.End && isTableRuleRow(next) {
+			line++
+		}
+		addTableRow(buf, line)
+		added, _ := buf.Line(line + 1)
+		buf.SetCursor(buffer.Position{Line: line + 1, Col: tableCellStart(added, 0)})
+		return CommandResult{Success: true}
+	case "column":
+		addTableColumn(buf, r, cell)
+		row = buf.CurrentLine()
+		buf.SetCursor(buffer.Position{Line: cursor.Line, Col: tableCellStart(row, cell+1)})
+		return CommandResult{Success: true}
+	}
+	return tableUsage()
+}
+
+func (c *TableCommand) Help() string {
+	return ":table [row|column] - Align the markdown table at the cursor, or add an empty row below the cursor or a column after it"
+}
+
+func tableUsage() CommandResult {
+	return CommandResult{Success: false, Message: "Usage: :table [row|column]"}
+}
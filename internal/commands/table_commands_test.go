@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestTableCommands(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"Text", "| Name | Qty |", "|-|-:|", "| apple | 1,000 |", "| fig | 20 |", "", "after"})
+	executor := NewCommandExecutor()
+
+	buf.SetCursor(buffer.Position{Line: 3, Col: 4})
+	if result := executor.Execute("table", buf); !result.Success {
+		t.Fatalf(":table failed: %s", result.Message)
+	}
+	want := []string{
+		"| Name  | Qty   |",
+		"| ----- | ----: |",
+		"| apple | 1,000 |",
+		"| fig   |    20 |",
+	}
+	if got := buf.Lines()[1:5]; !slices.Equal(got, want) {
+		t.Errorf(":table gave\n%q\nwant\n%q", got, want)
+	}
+	if got := buf.Cursor(); got != (buffer.Position{Line: 3, Col: 4}) {
+		t.Errorf("cursor at %+v after :table, want it kept in the cell", got)
+	}
+
+	// A row on the header goes below the rule
+	buf.SetCursor(buffer.Position{Line: 1, Col: 2})
+	executor.Execute("table row", buf)
+	if got, _ := buf.Line(3); got != "|       |       |" || buf.Cursor() != (buffer.Position{Line: 3, Col: 2}) {
+		t.Errorf(":table row added %q with the cursor at %+v", got, buf.Cursor())
+	}
+
+	executor.Execute("table column", buf)
+	want = []string{
+		"| Name  |     | Qty   |",
+		"| ----- | --- | ----: |",
+	}
+	if got := buf.Lines()[1:3]; !slices.Equal(got, want) {
+		t.Errorf(":table column gave %q, want %q", got, want)
+	}
+	if got := buf.Cursor(); got != (buffer.Position{Line: 3, Col: 10}) {
+		t.Errorf("cursor at %+v after :table column, want the new cell", got)
+	}
+
+	buf.SetCursor(buffer.Position{Line: 0})
+	if result := executor.Execute("table", buf); result.Success || result.Message != "Not in a table" {
+		t.Errorf(":table outside a table = %+v", result)
+	}
+}
+
+func TestNextTableCell(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"  | a | b |", "  |---|---|", "  | c | d"})
+
+	// Tab closes the row being typed and goes on to a new row after the last cell
+	buf.SetCursor(buffer.Position{Line: 2, Col: 9})
+	NextTableCell(buf, false)
+	want := []string{"  | a   | b   |", "  | --- | --- |", "  | c   | d   |", "  |     |     |"}
+	if got := buf.Lines(); !slices.Equal(got, want) {
+		t.Errorf("Tab gave %q, want %q", got, want)
+	}
+	if got := buf.Cursor(); got != (buffer.Position{Line: 3, Col: 4}) {
+		t.Errorf("Tab moved to %+v, want the first cell of the new row", got)
+	}
+
+	// Shift-Tab goes back over the rule
+	buf.SetCursor(buffer.Position{Line: 2, Col: 4})
+	NextTableCell(buf, true)
+	if got := buf.Cursor(); got != (buffer.Position{Line: 0, Col: 10}) {
+		t.Errorf("Shift-Tab moved to %+v, want the last header cell", got)
+	}
+
+	if NextTableCell(buf, false); buf.Cursor() != (buffer.Position{Line: 2, Col: 4}) {
+		t.Errorf("Tab from the header moved to %+v, want past the rule", buf.Cursor())
+	}
+}
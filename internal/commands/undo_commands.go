@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// UndoTreeOpen reports whether the undo tree window is shown
+func (s *State) UndoTreeOpen() bool {
+	return s.undoTreeOpen
+}
+
+// undoCommand is a command moving through the buffer's undo tree
+type undoCommand struct {
+	name    string
+	aliases []string
+	help    string
+	run     func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult
+}
+
+func (c *undoCommand) Name() string {
+	return c.name
+}
+
+func (c *undoCommand) Aliases() []string {
+	return c.aliases
+}
+
+func (c *undoCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return c.run(cc, args, buf)
+}
+
+func (c *undoCommand) Help() string {
+	return c.help
+}
+
+// NewUndoCommands creates :undo, :redo, :earlier, :later and :undotree
+func NewUndoCommands() []Command {
+	return []Command{
+		&undoCommand{"undo", []string{"u", "un"}, ":undo [N] - Undo one change, or go to text state N of :undotree", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			if len(args) == 0 {
+				if !buf.Undo() {
+					return CommandResult{Success: false, Message: "Already at oldest change"}
+				}
+				return undoResult(buf)
+			}
+			seq, err := strconv.Atoi(args[0])
+			if err != nil {
+				return CommandResult{Success: false, Message: fmt.Sprintf("Invalid undo number: %s", args[0])}
+			}
+			if err := buf.UndoTo(seq); err != nil {
+				return CommandResult{Success: false, Message: capitalize(err.Error())}
+			}
+			return undoResult(buf)
+		}},
+		&undoCommand{"redo", []string{"red"}, ":redo - Redo the change last undone", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			if !buf.Redo() {
+				return CommandResult{Success: false, Message: "Already at newest change"}
+			}
+			return undoResult(buf)
+		}},
+		&undoCommand{"earlier", []string{"ea"}, ":earlier {N|Ns|Nm|Nh|Nd} - Go to the text N changes or the given time earlier, across undo branches", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			return timeTravel(args, buf, buf.Earlier, buf.EarlierBy)
+		}},
+		&undoCommand{"later", []string{"lat"}, ":later {N|Ns|Nm|Nh|Nd} - Go to the text N changes or the given time later, across undo branches", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			return timeTravel(args, buf, buf.Later, buf.LaterBy)
+		}},
+		&undoCommand{"undotree", []string{"undol", "undolist"}, ":undotree - Toggle the window listing every text state; restore one with :undo N", func(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+			cc.State.undoTreeOpen = !cc.State.undoTreeOpen
+			return CommandResult{Success: true}
+		}},
+	}
+}
+
+// timeTravel runs :earlier or :later with a count of changes or a time
+func timeTravel(args []string, buf *buffer.Buffer, bySteps func(int) int, byTime func(time.Duration) int) CommandResult {
+	arg := "1"
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+		bySteps(n)
+		return undoResult(buf)
+	}
+	d, err := parseUndoTime(arg)
+	if err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	byTime(d)
+	return undoResult(buf)
+}
+
+// parseUndoTime parses the times :earlier and :later accept: a number
+// followed by s, m, h or d
+func parseUndoTime(arg string) (time.Duration, error) {
+	units := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour}
+	if len(arg) < 2 {
+		return 0, fmt.Errorf("invalid argument: %s", arg)
+	}
+	unit, ok := units[arg[len(arg)-1]]
+	n, err := strconv.Atoi(arg[:len(arg)-1])
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid argument: %s", arg)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// undoResult reports the text state the buffer is in
+func undoResult(buf *buffer.Buffer) CommandResult {
+	states := buf.UndoStates()
+	for _, s := range states {
+		if s.Current {
+			return CommandResult{Success: true, Message: fmt.Sprintf("State %d of %d, %s", s.Seq, len(states)-1, age(s.Time, time.Now()))}
+		}
+	}
+	return CommandResult{Success: true}
+}
+
+// UndoTreeLines returns a line per text state of the buffer, oldest first,
+// and the index of the current state
+func UndoTreeLines(buf *buffer.Buffer, now time.Time) ([]string, int) {
+	var lines []string
+	current := 0
+	for i, s := range buf.UndoStates() {
+		var line strings.Builder
+		fmt.Fprintf(&line, "%3d  ", s.Seq)
+		if s.Parent < 0 {
+			line.WriteString("original text")
+		} else {
+			fmt.Fprintf(&line, "line %d: +%d -%d", s.Line+1, s.Added, s.Removed)
+		}
+		fmt.Fprintf(&line, "  %s", age(s.Time, now))
+		if s.Parent >= 0 && s.Parent != s.Seq-1 {
+			fmt.Fprintf(&line, "  (branch from %d)", s.Parent)
+		}
+		if s.Saved {
+			line.WriteString("  [saved]")
+		}
+		if s.Current {
+			current = i
+		}
+		lines = append(lines, line.String())
+	}
+	return lines, current
+}
+
+// age describes how long before now t was
+func age(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Second:
+		return "just now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	}
+	return t.Format("2006-01-02 15:04")
+}
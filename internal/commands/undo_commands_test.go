@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestUndoCommands(t *testing.T) {
+	registry := NewCommandRegistry()
+	cc := &CommandContext{State: NewState()}
+	buf := buffer.New()
+	for _, text := range []string{"one", "two", "three"} {
+		buf.ReplaceLines(0, 0, []string{text})
+		buf.Commit()
+	}
+
+	run := func(command string) CommandResult {
+		t.Helper()
+		name, args, _ := strings.Cut(command, " ")
+		cmd, ok := registry.GetCommand(name)
+		if !ok {
+			t.Fatalf("command %q not registered", name)
+		}
+		return cmd.Execute(cc, strings.Fields(args), buf)
+	}
+
+	if result := run("undo"); !result.Success || buf.CurrentLine() != "two" {
+		t.Errorf(":undo = %+v, line %q", result, buf.CurrentLine())
+	}
+	if result := run("redo"); !result.Success || buf.CurrentLine() != "three" {
+		t.Errorf(":redo = %+v, line %q", result, buf.CurrentLine())
+	}
+	if result := run("redo"); result.Success {
+		t.Error(":redo past the newest change succeeded")
+	}
+	if result := run("undo 1"); !result.Success || buf.CurrentLine() != "one" {
+		t.Errorf(":undo 1 = %+v, line %q", result, buf.CurrentLine())
+	}
+	if result := run("later 2"); !result.Success || buf.CurrentLine() != "three" {
+		t.Errorf(":later 2 = %+v, line %q", result, buf.CurrentLine())
+	}
+	if result := run("earlier 1h"); !result.Success || buf.CurrentLine() != "" {
+		t.Errorf(":earlier 1h = %+v, line %q", result, buf.CurrentLine())
+	}
+	if result := run("earlier 5x"); result.Success {
+		t.Error(":earlier with an invalid time succeeded")
+	}
+
+	run("undotree")
+	if !cc.State.UndoTreeOpen() {
+		t.Error(":undotree did not open the window")
+	}
+	run("undotree")
+	if cc.State.UndoTreeOpen() {
+		t.Error("second :undotree did not close the window")
+	}
+}
+
+func TestUndoTreeLines(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a"})
+	buf.Commit()
+	buf.ReplaceLines(0, 0, []string{"b"})
+	buf.Commit()
+	buf.Undo()
+	buf.ReplaceLines(0, 0, []string{"c", "d"})
+	buf.Commit()
+
+	lines, current := UndoTreeLines(buf, time.Now().Add(90*time.Second))
+	if len(lines) != 4 || current != 3 {
+		t.Fatalf("got %d lines, current %d: %q", len(lines), current, lines)
+	}
+	if !strings.Contains(lines[0], "original text") || !strings.Contains(lines[0], "[saved]") {
+		t.Errorf("unexpected root line %q", lines[0])
+	}
+	if want := "  3  line 1: +2 -1  1m ago  (branch from 1)"; lines[3] != want {
+		t.Errorf("got %q, want %q", lines[3], want)
+	}
+}
@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/quickfix"
+)
+
+// userCommandName matches valid user command names. Like Vim they start
+// with an uppercase letter, which keeps them apart from built-in commands.
+var userCommandName = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// SetUserCommands replaces the executor's user-defined commands with those
+// of the config. Commands with invalid names or definitions, or that would
+// replace a built-in command, are left out and reported in the returned
+// error.
+func (ce *CommandExecutor) SetUserCommands(defs map[string]config.UserCommandConfig) error {
+	registry := ce.registry
+	for name, cmd := range registry.commands {
+		if _, ok := cmd.(*UserCommand); ok {
+			delete(registry.commands, name)
+		}
+	}
+	var errs []error
+	for name, def := range defs {
+		kinds := 0
+		for _, set := range []bool{len(def.Run) > 0, def.Shell != "", def.AI != ""} {
+			if set {
+				kinds++
+			}
+		}
+		_, builtin := registry.GetCommand(name)
+		switch {
+		case !userCommandName.MatchString(name):
+			errs = append(errs, fmt.Errorf("command %q: names must start with an uppercase letter", name))
+		case builtin:
+			errs = append(errs, fmt.Errorf("command %q: a built-in command has that name", name))
+		case kinds != 1:
+			errs = append(errs, fmt.Errorf("command %q: set exactly one of run, shell and ai", name))
+		default:
+			registry.RegisterCommand(&UserCommand{name: name, def: def, executor: ce})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// UserCommand is a command defined in the config. It runs a sequence of
+// ex commands, a shell command whose output goes to the quickfix list like
+// :make, or an AI prompt about the range or the whole buffer.
+type UserCommand struct {
+	name     string
+	def      config.UserCommandConfig
+	executor *CommandExecutor // Runs the ex commands of Run
+}
+
+func (u *UserCommand) Name() string {
+	return u.name
+}
+
+func (u *UserCommand) Aliases() []string {
+	return []string{}
+}
+
+func (u *UserCommand) Execute(cc *CommandContext, args []string, buf *buffer.Buffer) CommandResult {
+	return u.execute(cc, currentLine(buf), false, args, buf)
+}
+
+func (u *UserCommand) ExecuteRange(cc *CommandContext, r Range, args []string, buf *buffer.Buffer) CommandResult {
+	return u.execute(cc, r, true, args, buf)
+}
+
+func (u *UserCommand) Help() string {
+	description := u.def.Description
+	if description == "" {
+		switch {
+		case len(u.def.Run) > 0:
+			description = "Run " + strings.Join(u.def.Run, " | ")
+		case u.def.Shell != "":
+			description = "Run " + u.def.Shell
+		default:
+			description = "Ask the AI: " + u.def.AI
+		}
+	}
+	return fmt.Sprintf(":[range]%s [args] - %s (user command)", u.name, description)
+}
+
+// execute runs the command on r, which for AI prompts is the whole buffer
+// unless hasRange
+func (u *UserCommand) execute(cc *CommandContext, r Range, hasRange bool, args []string, buf *buffer.Buffer) CommandResult {
+	vars := map[string]string{
+		"{file}":     buf.Filename(),
+		"{filetype}": buf.Filetype(),
+		"{start}":    strconv.Itoa(r.Start + 1),
+		"{end}":      strconv.Itoa(r.End + 1),
+		"{args}":     strings.Join(args, " "),
+	}
+
+	switch {
+	case len(u.def.Run) > 0:
+		return u.runCommands(vars, buf)
+	case u.def.Shell != "":
+		vars["{file}"] = shellQuote(buf.Filename())
+		return u.runShell(cc, expandVars(u.def.Shell, vars), buf)
+	default:
+		if !hasRange {
+			r = wholeBuffer(buf)
+		}
+		return u.askAI(cc, expandVars(u.def.AI, vars), r, buf)
+	}
+}
+
+// runCommands runs the ex commands in order, stopping at the first failure
+func (u *UserCommand) runCommands(vars map[string]string, buf *buffer.Buffer) CommandResult {
+	result := CommandResult{Success: true}
+	for _, line := range u.def.Run {
+		result = u.executor.run(expandVars(line, vars), buf)
+		if !result.Success || result.ExitEditor {
+			break
+		}
+	}
+	return result
+}
+
+// runShell runs command like :make, with the filetype's error format
+func (u *UserCommand) runShell(cc *CommandContext, command string, buf *buffer.Buffer) CommandResult {
+	s := cc.State
+	if s.buildRunning() {
+		return CommandResult{Success: false, Message: "A build is already running: " + s.buildJob.Command}
+	}
+	formats, err := quickfix.CompileErrorFormats(buildConfigFor(cc.currentConfig().Build, buf.Filetype()).ErrorFormat)
+	if err != nil {
+		return CommandResult{Success: false, Message: err.Error()}
+	}
+	if err := s.startBuild(":"+u.name+" "+command, command, formats); err != nil {
+		return CommandResult{Success: false, Message: capitalize(err.Error())}
+	}
+	return CommandResult{Success: true, Message: "Running: " + command}
+}
+
+// askAI sends prompt with the lines of r as context
+func (u *UserCommand) askAI(cc *CommandContext, prompt string, r Range, buf *buffer.Buffer) CommandResult {
+	if cc.AI == nil {
+		return CommandResult{Success: false, Message: "AI manager not initialized"}
+	}
+
+	var contextBuilder strings.Builder
+	contextBuilder.WriteString(cc.State.projectContext(buf))
+	fmt.Fprintf(&contextBuilder, "Lines %d-%d:\n", r.Start+1, r.End+1)
+	for _, line := range buf.Lines()[r.Start : r.End+1] {
+		contextBuilder.WriteString(line)
+		contextBuilder.WriteString("\n")
+	}
+
+	req := ai.AIRequest{
+		Prompt:   prompt,
+		Context:  contextBuilder.String(),
+		Language: buf.Filetype(),
+		Type:     ai.RequestChat,
+	}
+
+	return askAI(cc, req, buf, 30*time.Second, func(resp *ai.AIResponse) CommandResult {
+		return CommandResult{Success: true, Message: withFallback(resp.Content, resp)}
+	})
+}
+
+// expandVars replaces the {name} placeholders of s
+func expandVars(s string, vars map[string]string) string {
+	var pairs []string
+	for name, value := range vars {
+		pairs = append(pairs, name, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// shellQuote quotes s as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+)
+
+func TestSetUserCommands(t *testing.T) {
+	executor := NewCommandExecutor()
+	err := executor.SetUserCommands(map[string]config.UserCommandConfig{
+		"Tidy":      {Run: []string{"StripWhitespace"}},
+		"lower":     {Run: []string{"w"}},
+		"SudoWrite": {Run: []string{"w"}},
+		"Both":      {Run: []string{"w"}, Shell: "true"},
+	})
+	if err == nil {
+		t.Fatal("expected errors for invalid commands")
+	}
+	for _, name := range []string{"lower", "SudoWrite", "Both"} {
+		if !strings.Contains(err.Error(), `"`+name+`"`) {
+			t.Errorf("error does not mention %s: %v", name, err)
+		}
+	}
+
+	registry := executor.GetCommands()
+	if _, ok := registry.GetCommand("Tidy"); !ok {
+		t.Error("valid command Tidy not registered")
+	}
+	if _, ok := registry.GetCommand("lower"); ok {
+		t.Error("invalid command lower registered")
+	}
+	if _, ok := NewCommandExecutor().GetCommands().GetCommand("Tidy"); ok {
+		t.Error("another executor has the user commands")
+	}
+
+	// Setting them again replaces them
+	if err := executor.SetUserCommands(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.GetCommand("Tidy"); ok {
+		t.Error("removed command Tidy still registered")
+	}
+}
+
+func TestUserCommandRun(t *testing.T) {
+	executor := NewCommandExecutor()
+	executor.SetUserCommands(map[string]config.UserCommandConfig{
+		"Trim":  {Run: []string{"{start},{end}StripWhitespace", "{start}"}},
+		"Fail":  {Run: []string{"nosuchcommand", "1d"}},
+		"Chain": {Run: []string{"Trim"}},
+	})
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a ", "b ", "c "})
+
+	if result := executor.Execute("2,3Trim", buf); !result.Success {
+		t.Fatalf(":2,3Trim failed: %s", result.Message)
+	}
+	if buf.String() != "a \nb\nc" || buf.Cursor().Line != 1 {
+		t.Errorf(":2,3Trim left %q with the cursor on line %d", buf.String(), buf.Cursor().Line+1)
+	}
+
+	// User commands may use each other
+	buf.SetCursor(buffer.Position{Line: 0})
+	executor.Execute("Chain", buf)
+	if buf.String() != "a\nb\nc" {
+		t.Errorf(":Chain left %q", buf.String())
+	}
+
+	if result := executor.Execute("Fail", buf); result.Success || buf.LineCount() != 3 {
+		t.Errorf(":Fail = %+v, %d lines left; want a failure before :1d", result, buf.LineCount())
+	}
+}
+
+func TestUserCommandAI(t *testing.T) {
+	provider := ai.NewMockProvider(ai.ProviderOpenAI)
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		return &ai.AIResponse{Content: "looks fine"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	executor := NewCommandExecutor()
+	executor.SetContext(&CommandContext{AI: manager})
+	executor.SetUserCommands(map[string]config.UserCommandConfig{
+		"Review": {AI: "Review this {filetype} code. {args}"},
+	})
+	buf := buffer.New()
+	buf.SetFilename("main.go")
+	buf.ReplaceLines(0, 0, []string{"package main", "", "func main() {}"})
+
+	result := executor.Execute("3Review be strict", buf)
+	if !result.Success || result.Message != "looks fine" {
+		t.Fatalf(":Review = %+v", result)
+	}
+	calls := provider.GetChatCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 AI request, got %d", len(calls))
+	}
+	if calls[0].Prompt != "Review this go code. be strict" {
+		t.Errorf("prompt = %q", calls[0].Prompt)
+	}
+	if !strings.Contains(calls[0].Context, "func main() {}") || strings.Contains(calls[0].Context, "package main") {
+		t.Errorf("context is not the range: %q", calls[0].Context)
+	}
+}
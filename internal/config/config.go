@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/dshills/aied/internal/ai"
 	"gopkg.in/yaml.v3"
@@ -16,6 +17,10 @@ type Config struct {
 	Providers []ai.ProviderConfig       `yaml:"providers" json:"providers"`
 	AI        AIConfig                  `yaml:"ai" json:"ai"`
 	LSP       LSPConfig                 `yaml:"lsp" json:"lsp"`
+	Build     map[string]BuildConfig    `yaml:"build" json:"build"` // :make and :test commands by filetype
+	Commands  map[string]UserCommandConfig `yaml:"commands" json:"commands"` // user-defined ex commands by name
+	Privacy   PrivacyConfig             `yaml:"privacy" json:"privacy"` // what AI requests may contain and where they go
+	Keymap    KeymapConfig              `yaml:"keymap" json:"keymap"` // user key mappings
 }
 
 // EditorConfig holds editor-specific settings
@@ -24,8 +29,149 @@ type EditorConfig struct {
 	IndentStyle  string `yaml:"indent_style" json:"indent_style"` // "tabs" or "spaces"
 	LineNumbers  bool   `yaml:"line_numbers" json:"line_numbers"`
 	Theme        string `yaml:"theme" json:"theme"`
+	Background   string `yaml:"background" json:"background"` // "auto" asks the terminal, "dark" or "light"
+	Transparent  bool   `yaml:"transparent" json:"transparent"` // draw on the terminal's own background
+	Colors       map[string]ColorConfig `yaml:"colors" json:"colors"` // theme overrides by style name
 	AutoSave     bool   `yaml:"auto_save" json:"auto_save"`
 	AutoSaveDelay int   `yaml:"auto_save_delay" json:"auto_save_delay"` // seconds
+	Spell        SpellConfig `yaml:"spell" json:"spell"`
+	List         bool            `yaml:"list" json:"list"`                                       // show whitespace characters
+	ListChars    ListCharsConfig `yaml:"listchars" json:"listchars"`                             // glyphs used by list mode
+	TrimTrailingWhitespace bool  `yaml:"trim_trailing_whitespace" json:"trim_trailing_whitespace"` // strip on save
+	ColorColumn  []int           `yaml:"color_column" json:"color_column"`                       // columns to draw guides at
+	CursorLine   bool            `yaml:"cursorline" json:"cursorline"`                           // highlight the cursor line
+	CursorColumn bool            `yaml:"cursorcolumn" json:"cursorcolumn"`                       // highlight the cursor column
+	ScrollOff    int             `yaml:"scrolloff" json:"scrolloff"`                             // context lines around the cursor
+	SideScrollOff int            `yaml:"sidescrolloff" json:"sidescrolloff"`                     // context columns around the cursor
+	TextWidth    int             `yaml:"textwidth" json:"textwidth"`                             // line width used by gq
+	Comments     map[string]CommentConfig `yaml:"comments" json:"comments"`                      // comment strings by filetype
+	Registers    RegistersConfig `yaml:"registers" json:"registers"`                             // register persistence
+	RootMarkers  []string        `yaml:"root_markers" json:"root_markers"`                       // files marking a project root
+	Modeline     bool            `yaml:"modeline" json:"modeline"`                               // apply vim: modelines in files
+	Encryption   EncryptionConfig `yaml:"encryption" json:"encryption"`                          // .gpg/.age file handling
+	Backup       BackupConfig    `yaml:"backup" json:"backup"`                                   // numbered backups on save
+	WhichKey     WhichKeyConfig  `yaml:"which_key" json:"which_key"`                             // hints for pending keys
+	IgnoreCase   bool            `yaml:"ignorecase" json:"ignorecase"`                           // searches and :s ignore case
+	SmartCase    bool            `yaml:"smartcase" json:"smartcase"`                             // unless the pattern has upper case
+	HLSearch     bool            `yaml:"hlsearch" json:"hlsearch"`                               // highlight the last search's matches
+	RegexpEngine string          `yaml:"regexpengine" json:"regexpengine"`                       // "go" or "regexp2" (lookarounds, backreferences)
+	Title        bool            `yaml:"title" json:"title"`                                     // set the terminal's title to the file
+	ReportDir    bool            `yaml:"report_dir" json:"report_dir"`                           // tell the terminal the working directory (OSC 7)
+	Ruler        bool            `yaml:"ruler" json:"ruler"`                                     // status line shows line count, byte offset and scroll position
+	TimeoutLen   int             `yaml:"timeoutlen" json:"timeoutlen"`                           // milliseconds pending keys wait for the next, 0 for ever
+	Path         string          `yaml:"path" json:"path"`                                       // directories :find and gf look in, comma separated
+}
+
+// WhichKeyConfig controls the popup listing the keys that may complete a
+// pending prefix or operator
+type WhichKeyConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	Delay   int  `yaml:"delay" json:"delay"` // milliseconds before the popup shows
+}
+
+// ColorConfig overrides the colors of a style: "#rrggbb", a color name,
+// or "none" for the terminal's default
+type ColorConfig struct {
+	Fg string `yaml:"fg" json:"fg"`
+	Bg string `yaml:"bg" json:"bg"`
+}
+
+// ListCharsConfig holds the glyphs used to display whitespace in list mode
+type ListCharsConfig struct {
+	Tab   string `yaml:"tab" json:"tab"`
+	Trail string `yaml:"trail" json:"trail"`
+	Nbsp  string `yaml:"nbsp" json:"nbsp"`
+}
+
+// SpellConfig holds spell checking settings
+type SpellConfig struct {
+	Enabled        bool     `yaml:"enabled" json:"enabled"`
+	Filetypes      []string `yaml:"filetypes" json:"filetypes"`             // filetypes to check
+	Dictionaries   []string `yaml:"dictionaries" json:"dictionaries"`       // word lists or hunspell .dic files
+	UserDictionary string   `yaml:"user_dictionary" json:"user_dictionary"` // words added with zg
+}
+
+// RegistersConfig controls persisting registers between sessions
+type RegistersConfig struct {
+	Persist bool   `yaml:"persist" json:"persist"` // save registers on exit and load them on start
+	File    string `yaml:"file" json:"file"`       // register file (defaults to ~/.config/aied/registers.json)
+	Shared  bool   `yaml:"shared" json:"shared"`   // sync registers between running instances
+}
+
+// BackupConfig controls the numbered backups (file.~1~, file.~2~, ...) made before saving
+type BackupConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Dir     string `yaml:"dir" json:"dir"`   // backup directory, the file's directory when empty
+	Keep    int    `yaml:"keep" json:"keep"` // backups kept per file, 0 keeps all
+}
+
+// EncryptionConfig controls transparent editing of .gpg and .age files
+type EncryptionConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	GPGRecipients []string `yaml:"gpg_recipients" json:"gpg_recipients"` // encrypt to keys instead of a passphrase
+	AgeIdentity   string   `yaml:"age_identity" json:"age_identity"`     // identity file, asked for when empty
+	AgeRecipients []string `yaml:"age_recipients" json:"age_recipients"` // extra recipients for saved files
+}
+
+// CommentConfig defines the comment strings gc uses for a filetype
+type CommentConfig struct {
+	Line       string `yaml:"line" json:"line"`               // line comment leader, e.g. "//"
+	BlockStart string `yaml:"block_start" json:"block_start"` // used when there is no line comment
+	BlockEnd   string `yaml:"block_end" json:"block_end"`
+}
+
+// BuildConfig holds the build and test commands for a filetype. The
+// "default" entry is used for filetypes without their own commands.
+type BuildConfig struct {
+	Make        string   `yaml:"make" json:"make"`               // command run by :make
+	Test        string   `yaml:"test" json:"test"`               // command run by :test
+	ErrorFormat []string `yaml:"errorformat" json:"errorformat"` // %f/%l/%c/%m/%t patterns for error lines
+}
+
+// UserCommandConfig defines a user ex command, which runs ex commands, a
+// shell command or an AI prompt. {file}, {filetype}, {start}, {end} and
+// {args} are replaced by the file name, filetype, first and last line of
+// the range and the command's arguments. In YAML a plain string is short
+// for a single ex command, making the command an alias.
+type UserCommandConfig struct {
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Run         []string `yaml:"run,omitempty" json:"run,omitempty"`     // ex commands run in order
+	Shell       string   `yaml:"shell,omitempty" json:"shell,omitempty"` // shell command run like :make
+	AI          string   `yaml:"ai,omitempty" json:"ai,omitempty"`       // prompt sent with the range or buffer as context
+}
+
+// UnmarshalYAML accepts a plain string as a single ex command
+func (u *UserCommandConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*u = UserCommandConfig{Run: []string{node.Value}}
+		return nil
+	}
+	type plain UserCommandConfig
+	return node.Decode((*plain)(u))
+}
+
+// KeymapConfig holds the user's key mappings
+type KeymapConfig struct {
+	Leader         string                      `yaml:"leader" json:"leader"`                   // key starting leader mappings: one character, or "space"
+	LeaderMappings map[string]KeyMappingConfig `yaml:"leader_mappings" json:"leader_mappings"` // mappings by the keys typed after the leader
+}
+
+// KeyMappingConfig is a command line run by a key mapping. A command ending
+// in a space is left on the command line to finish. In YAML a plain string
+// is short for the command.
+type KeyMappingConfig struct {
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Command     string `yaml:"command" json:"command"`
+}
+
+// UnmarshalYAML accepts a plain string as the command
+func (k *KeyMappingConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*k = KeyMappingConfig{Command: node.Value}
+		return nil
+	}
+	type plain KeyMappingConfig
+	return node.Decode((*plain)(k))
 }
 
 // AIConfig holds AI-specific settings
@@ -37,6 +183,51 @@ type AIConfig struct {
 	MaxTokens           int      `yaml:"max_tokens" json:"max_tokens"`
 	Temperature         float64  `yaml:"temperature" json:"temperature"`
 	EnabledCommands     []string `yaml:"enabled_commands" json:"enabled_commands"`
+	Agent               AgentConfig `yaml:"agent" json:"agent"` // :agent tool use
+	FallbackOrder       []string `yaml:"fallback_order" json:"fallback_order"` // providers tried in order when the routed and default ones fail
+	Routes              map[string]AIRouteConfig `yaml:"routes" json:"routes"` // provider and model for each request type
+	History             AIHistoryConfig `yaml:"history" json:"history"` // saved :ai chat threads
+	PreviewContext      bool     `yaml:"preview_context" json:"preview_context"` // show and trim what each request sends before it goes out
+}
+
+// AIHistoryConfig holds where :ai chat threads are saved and how much of
+// them is sent with a question
+type AIHistoryConfig struct {
+	Enabled     bool `yaml:"enabled" json:"enabled"`
+	InProject   bool `yaml:"in_project" json:"in_project"`     // save in the project's .aied/ai-history instead of the data directory
+	MaxMessages int  `yaml:"max_messages" json:"max_messages"` // most earlier messages of the thread sent with a question
+}
+
+// AIRouteConfig sends one type of AI request (completion, chat,
+// explanation, refactor, debug, documentation or edit) to a provider
+type AIRouteConfig struct {
+	Provider string `yaml:"provider" json:"provider"`
+	Model    string `yaml:"model" json:"model"` // empty for the provider's configured model
+}
+
+// AgentConfig holds settings of the :agent tool loop
+type AgentConfig struct {
+	AllowedCommands []string `yaml:"allowed_commands" json:"allowed_commands"` // commands, or prefixes of them, the agent may run
+	MaxSteps        int      `yaml:"max_steps" json:"max_steps"`               // most model requests per task
+}
+
+// PrivacyConfig holds the rules applied to AI requests before they leave
+// the machine
+type PrivacyConfig struct {
+	Redact     []string `yaml:"redact" json:"redact"`           // regular expressions of secrets to replace; only a group named "secret" if present
+	BlockFiles []string `yaml:"block_files" json:"block_files"` // globs of files never sent, matched against the path or name
+	LocalOnly  []string `yaml:"local_only" json:"local_only"`   // globs of project roots or names restricted to local providers
+}
+
+// DefaultRedactions match common API keys, tokens and private keys
+var DefaultRedactions = []string{
+	`AKIA[0-9A-Z]{16}`,                  // AWS access key IDs
+	`gh[pousr]_[A-Za-z0-9]{36,}`,        // GitHub tokens
+	`sk-[A-Za-z0-9_-]{20,}`,             // OpenAI and Anthropic API keys
+	`xox[abprs]-[A-Za-z0-9-]{10,}`,      // Slack tokens
+	`AIza[0-9A-Za-z_-]{35}`,             // Google API keys
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	`(?i)(?:api[_-]?key|secret|token|password|passwd)["']?\s*(?::=|[:=])\s*["'](?P<secret>[^\s"']{8,})["']`, // quoted values assigned to secret-looking names
 }
 
 // LSPConfig holds LSP-specific settings
@@ -45,6 +236,7 @@ type LSPConfig struct {
 	AutoStart        bool              `yaml:"auto_start" json:"auto_start"`
 	ShowDiagnostics  bool              `yaml:"show_diagnostics" json:"show_diagnostics"`
 	CompletionTrigger string           `yaml:"completion_trigger" json:"completion_trigger"` // "auto" or "manual"
+	OrganizeImportsOnSave bool         `yaml:"organize_imports_on_save" json:"organize_imports_on_save"` // run :organize-imports before :w
 	Servers          []LSPServerConfig `yaml:"servers" json:"servers"`
 }
 
@@ -67,8 +259,38 @@ func DefaultConfig() *Config {
 			IndentStyle:   "spaces",
 			LineNumbers:   true,
 			Theme:         "default",
+			Background:    "auto",
 			AutoSave:      false,
 			AutoSaveDelay: 60,
+			Spell: SpellConfig{
+				Enabled:   false,
+				Filetypes: []string{"markdown", "text", "gitcommit"},
+			},
+			List: false,
+			ListChars: ListCharsConfig{
+				Tab:   "»",
+				Trail: "·",
+				Nbsp:  "␣",
+			},
+			TrimTrailingWhitespace: false,
+			TextWidth:              79,
+			Modeline:               true,
+			HLSearch:               true,
+			Title:                  true,
+			ReportDir:              true,
+			Ruler:                  true,
+			TimeoutLen:             1000,
+			Path:                   ".,,",
+			Registers: RegistersConfig{
+				Persist: true,
+			},
+			Backup: BackupConfig{
+				Keep: 5,
+			},
+			WhichKey: WhichKeyConfig{
+				Enabled: true,
+				Delay:   500,
+			},
 		},
 		AI: AIConfig{
 			DefaultProvider:  "ollama",
@@ -78,6 +300,15 @@ func DefaultConfig() *Config {
 			MaxTokens:        1000,
 			Temperature:      0.3,
 			EnabledCommands:  []string{"ai", "aic", "aie", "air", "aip"},
+			Agent: AgentConfig{
+				AllowedCommands: []string{"go build", "go test", "go vet", "git status", "git diff", "make"},
+				MaxSteps:        10,
+			},
+			FallbackOrder: []string{"ollama", "openai", "anthropic", "google"},
+			History: AIHistoryConfig{
+				Enabled:     true,
+				MaxMessages: 20,
+			},
 		},
 		Providers: []ai.ProviderConfig{
 			{
@@ -87,6 +318,13 @@ func DefaultConfig() *Config {
 				Enabled: true,
 			},
 		},
+		Privacy: PrivacyConfig{
+			Redact:     DefaultRedactions,
+			BlockFiles: []string{".env", ".env.*", "*.pem", "*.key", "id_rsa*", "id_ed25519*", ".netrc"},
+		},
+		Keymap: KeymapConfig{
+			Leader: "\\",
+		},
 		LSP: LSPConfig{
 			Enabled:          true,
 			AutoStart:        true,
@@ -102,6 +340,14 @@ func DefaultConfig() *Config {
 				},
 			},
 		},
+		Build: map[string]BuildConfig{
+			"default":    {Make: "make", Test: "make test"},
+			"go":         {Make: "go build ./...", Test: "go test ./..."},
+			"rust":       {Make: "cargo build", Test: "cargo test"},
+			"python":     {Test: "python -m pytest"},
+			"javascript": {Make: "npm run build", Test: "npm test"},
+			"typescript": {Make: "npx tsc --noEmit", Test: "npm test"},
+		},
 	}
 }
 
@@ -286,7 +532,75 @@ func (c *Config) Save(path string) error {
 
 // GenerateExample generates an example configuration file
 func GenerateExample(path string) error {
-	config := &Config{
+	return exampleConfig().Save(path)
+}
+
+// Setup holds the choices made when setting up the editor on first run
+type Setup struct {
+	Providers []ai.ProviderConfig // Providers to enable, the first as the default; empty fields keep the example's
+	Servers   []string            // Names of the example's language servers to enable
+}
+
+// GenerateStarter writes the example configuration with only the providers
+// and language servers chosen in setup enabled. The file is only readable
+// by the user, since it may hold API keys.
+func GenerateStarter(path string, setup Setup) error {
+	cfg := exampleConfig()
+
+	enabled := make(map[ai.ProviderType]bool)
+	var order []string
+	for _, chosen := range setup.Providers {
+		enabled[chosen.Type] = true
+		order = append(order, string(chosen.Type))
+		for i, p := range cfg.Providers {
+			if p.Type != chosen.Type {
+				continue
+			}
+			// Keys left empty are read from the environment
+			cfg.Providers[i].APIKey = chosen.APIKey
+			if chosen.Model != "" {
+				cfg.Providers[i].Model = chosen.Model
+			}
+			if chosen.BaseURL != "" {
+				cfg.Providers[i].BaseURL = chosen.BaseURL
+			}
+		}
+	}
+	for i, p := range cfg.Providers {
+		cfg.Providers[i].Enabled = enabled[p.Type]
+		if !enabled[p.Type] {
+			cfg.Providers[i].APIKey = ""
+		}
+	}
+	cfg.AI.FallbackOrder = order
+	cfg.AI.DefaultProvider = ""
+	if len(order) > 0 {
+		cfg.AI.DefaultProvider = order[0]
+	}
+	for name, route := range cfg.AI.Routes {
+		if !enabled[ai.ProviderType(route.Provider)] {
+			delete(cfg.AI.Routes, name)
+		}
+	}
+
+	for i, srv := range cfg.LSP.Servers {
+		cfg.LSP.Servers[i].Enabled = slices.Contains(setup.Servers, srv.Name)
+	}
+
+	if err := cfg.Save(path); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// ExampleServers returns the language servers of the example configuration
+func ExampleServers() []LSPServerConfig {
+	return exampleConfig().LSP.Servers
+}
+
+// exampleConfig returns the configuration written by GenerateExample
+func exampleConfig() *Config {
+	return &Config{
 		Editor: EditorConfig{
 			TabSize:       4,
 			IndentStyle:   "spaces",
@@ -294,6 +608,54 @@ func GenerateExample(path string) error {
 			Theme:         "monokai",
 			AutoSave:      true,
 			AutoSaveDelay: 30,
+			Spell: SpellConfig{
+				Enabled:   true,
+				Filetypes: []string{"markdown", "text", "gitcommit", "go"},
+			},
+			List: true,
+			ListChars: ListCharsConfig{
+				Tab:   "»",
+				Trail: "·",
+				Nbsp:  "␣",
+			},
+			TrimTrailingWhitespace: true,
+			ColorColumn:            []int{80, 120},
+			CursorLine:             true,
+			ScrollOff:              5,
+			SideScrollOff:          10,
+			TextWidth:              100,
+			Modeline:               true,
+			IgnoreCase:             true,
+			SmartCase:              true,
+			HLSearch:               true,
+			RegexpEngine:           "go",
+			Title:                  true,
+			ReportDir:              true,
+			Ruler:                  true,
+			TimeoutLen:             1000,
+			Path:                   ".,,",
+			Comments: map[string]CommentConfig{
+				"sql": {Line: "--"},
+				"css": {BlockStart: "/*", BlockEnd: "*/"},
+			},
+			Registers: RegistersConfig{
+				Persist: true,
+				Shared:  true,
+			},
+			RootMarkers: []string{".git", "go.mod", "package.json", "pyproject.toml", "Cargo.toml"},
+			Backup: BackupConfig{
+				Enabled: true,
+				Dir:     "~/.local/share/aied/backup",
+				Keep:    10,
+			},
+			Encryption: EncryptionConfig{
+				Enabled:     true,
+				AgeIdentity: "~/.config/age/key.txt",
+			},
+			WhichKey: WhichKeyConfig{
+				Enabled: true,
+				Delay:   300,
+			},
 		},
 		AI: AIConfig{
 			DefaultProvider:  "openai",
@@ -303,6 +665,20 @@ func GenerateExample(path string) error {
 			MaxTokens:        1500,
 			Temperature:      0.2,
 			EnabledCommands:  []string{"ai", "aic", "aie", "air", "aip"},
+			Agent: AgentConfig{
+				AllowedCommands: []string{"go build", "go test", "go vet", "git status", "git diff", "make"},
+				MaxSteps:        10,
+			},
+			FallbackOrder: []string{"openai", "anthropic", "ollama"},
+			Routes: map[string]AIRouteConfig{
+				"completion": {Provider: "ollama", Model: "codestral"},
+				"chat":       {Provider: "anthropic"},
+				"refactor":   {Provider: "openai", Model: "gpt-4"},
+			},
+			History: AIHistoryConfig{
+				Enabled:     true,
+				MaxMessages: 20,
+			},
 		},
 		Providers: []ai.ProviderConfig{
 			{
@@ -311,6 +687,8 @@ func GenerateExample(path string) error {
 				Model:   "gpt-4",
 				BaseURL: "https://api.openai.com/v1",
 				Enabled: true,
+				RateLimit: 60,
+				Burst:   5,
 				Options: map[string]interface{}{
 					"timeout": 30,
 				},
@@ -321,6 +699,9 @@ func GenerateExample(path string) error {
 				Model:   "claude-3-5-sonnet-20241022",
 				BaseURL: "https://api.anthropic.com/v1",
 				Enabled: true,
+				RateLimit: 50,
+				Burst:   5,
+				MaxTokens: 4096,
 			},
 			{
 				Type:    ai.ProviderGoogle,
@@ -374,7 +755,29 @@ func GenerateExample(path string) error {
 				},
 			},
 		},
+		Build: map[string]BuildConfig{
+			"default": {Make: "make", Test: "make test"},
+			"go":      {Make: "go build ./...", Test: "go test ./..."},
+			"rust": {
+				Make:        "cargo build",
+				Test:        "cargo test",
+				ErrorFormat: []string{"--> %f:%l:%c"},
+			},
+		},
+		Commands: map[string]UserCommandConfig{
+			"W":      {Run: []string{"w"}},
+			"Tidy":   {Description: "Strip whitespace and save", Run: []string{"StripWhitespace", "w"}},
+			"Lint":   {Description: "Lint the current file", Shell: "golangci-lint run {file}"},
+			"Review": {Description: "Review the file or range", AI: "Review this {filetype} code for bugs and unclear names. {args}"},
+		},
+		Keymap: KeymapConfig{
+			Leader: "space",
+			LeaderMappings: map[string]KeyMappingConfig{
+				"ff": {Description: "Find file", Command: "e "},
+				"ai": {Description: "Ask the AI", Command: "ai "},
+				"ah": {Description: "AI conversations", Command: "ai-history"},
+				"w":  {Command: "w"},
+			},
+		},
 	}
-	
-	return config.Save(path)
 }
\ No newline at end of file
@@ -21,6 +21,9 @@ func TestDefaultConfig(t *testing.T) {
 	if !cfg.Editor.LineNumbers {
 		t.Error("Expected line numbers to be enabled by default")
 	}
+	if !cfg.Editor.WhichKey.Enabled || cfg.Editor.WhichKey.Delay != 500 {
+		t.Errorf("Expected key hints enabled after 500ms, got %+v", cfg.Editor.WhichKey)
+	}
 	
 	// Test AI defaults
 	if cfg.AI.DefaultProvider != "ollama" {
@@ -29,6 +32,9 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.AI.ContextLines != 10 {
 		t.Errorf("Expected context lines 10, got %d", cfg.AI.ContextLines)
 	}
+	if len(cfg.AI.FallbackOrder) != 4 || cfg.AI.FallbackOrder[0] != "ollama" {
+		t.Errorf("Expected fallback order starting with ollama, got %v", cfg.AI.FallbackOrder)
+	}
 	
 	// Test default provider
 	if len(cfg.Providers) != 1 {
@@ -52,11 +58,21 @@ editor:
 ai:
   default_provider: openai
   context_lines: 20
+  fallback_order: [anthropic, ollama]
+  routes:
+    completion:
+      provider: ollama
+      model: codestral
 providers:
   - type: openai
     api_key: test-key
     model: gpt-3.5-turbo
     enabled: true
+keymap:
+  leader: space
+  leader_mappings:
+    ff: "e "
+    ai: {description: Ask the AI, command: "ai "}
 `
 	if err := os.WriteFile(yamlPath, []byte(yamlContent), 0644); err != nil {
 		t.Fatal(err)
@@ -79,6 +95,21 @@ providers:
 	if cfg.AI.ContextLines != 20 {
 		t.Errorf("Expected context lines 20, got %d", cfg.AI.ContextLines)
 	}
+	if len(cfg.AI.FallbackOrder) != 2 || cfg.AI.FallbackOrder[0] != "anthropic" {
+		t.Errorf("Expected fallback order [anthropic ollama], got %v", cfg.AI.FallbackOrder)
+	}
+	if route := cfg.AI.Routes["completion"]; route.Provider != "ollama" || route.Model != "codestral" {
+		t.Errorf("Expected completions routed to ollama's codestral, got %+v", route)
+	}
+	if cfg.Keymap.Leader != "space" {
+		t.Errorf("Expected leader space, got %q", cfg.Keymap.Leader)
+	}
+	if m := cfg.Keymap.LeaderMappings["ff"]; m.Command != "e " {
+		t.Errorf("Expected plain string mapping to be the command, got %+v", m)
+	}
+	if m := cfg.Keymap.LeaderMappings["ai"]; m.Command != "ai " || m.Description != "Ask the AI" {
+		t.Errorf("Expected described mapping, got %+v", m)
+	}
 	
 	// Check provider was loaded
 	found := false
@@ -188,4 +219,93 @@ func TestGenerateExample(t *testing.T) {
 	if len(cfg.Providers) < 4 {
 		t.Errorf("Expected at least 4 providers in example, got %d", len(cfg.Providers))
 	}
-}
\ No newline at end of file
+}
+
+func TestGenerateStarter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	setup := Setup{
+		Providers: []ai.ProviderConfig{
+			{Type: ai.ProviderOllama, Model: "qwen2.5-coder"},
+			{Type: ai.ProviderAnthropic, APIKey: "sk-test"},
+		},
+		Servers: []string{"pyright"},
+	}
+	if err := GenerateStarter(path, setup); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the starter config readable only by its owner, got %v", info.Mode().Perm())
+	}
+
+	cfg, err := LoadFromFileOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range cfg.Providers {
+		switch p.Type {
+		case ai.ProviderOllama:
+			if !p.Enabled || p.Model != "qwen2.5-coder" {
+				t.Errorf("Expected Ollama enabled with the chosen model, got %+v", p)
+			}
+		case ai.ProviderAnthropic:
+			if !p.Enabled || p.APIKey != "sk-test" {
+				t.Errorf("Expected Anthropic enabled with the given key, got %+v", p)
+			}
+		default:
+			if p.Enabled {
+				t.Errorf("Expected %s disabled", p.Type)
+			}
+			if p.APIKey != "" {
+				t.Errorf("Expected no placeholder key for %s, got %q", p.Type, p.APIKey)
+			}
+		}
+	}
+	if cfg.AI.DefaultProvider != "ollama" {
+		t.Errorf("Expected the first choice as default provider, got %q", cfg.AI.DefaultProvider)
+	}
+	if len(cfg.AI.FallbackOrder) != 2 || cfg.AI.FallbackOrder[1] != "anthropic" {
+		t.Errorf("Expected the chosen providers as fallback order, got %v", cfg.AI.FallbackOrder)
+	}
+	if _, ok := cfg.AI.Routes["refactor"]; ok {
+		t.Error("Expected routes to disabled providers dropped")
+	}
+	for _, srv := range cfg.LSP.Servers {
+		if srv.Enabled != (srv.Name == "pyright") {
+			t.Errorf("Expected only pyright enabled, %s enabled=%v", srv.Name, srv.Enabled)
+		}
+	}
+}
+func TestLoadUserCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.yaml")
+	content := `
+commands:
+  W: w
+  Tidy:
+    description: Strip and save
+    run: [StripWhitespace, w]
+  Review:
+    ai: Review this code
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFromFileOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Commands["W"].Run; len(got) != 1 || got[0] != "w" {
+		t.Errorf("alias W runs %q, want [w]", got)
+	}
+	if tidy := cfg.Commands["Tidy"]; len(tidy.Run) != 2 || tidy.Description != "Strip and save" {
+		t.Errorf("unexpected Tidy command: %+v", tidy)
+	}
+	if review := cfg.Commands["Review"]; review.AI != "Review this code" {
+		t.Errorf("unexpected Review command: %+v", review)
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"slices"
+	"sync"
+)
+
+// Service holds the configuration the editor runs with. Everything reads
+// it from here instead of loading its own, and Reload replaces it and
+// tells the subscribers, so settings change without a restart. It is safe
+// for concurrent use.
+type Service struct {
+	mu          sync.RWMutex
+	cfg         *Config
+	load        func() (*Config, error)
+	subscribers []func(*Config)
+}
+
+// NewService creates a service holding cfg, which Reload replaces with
+// what load returns
+func NewService(cfg *Config, load func() (*Config, error)) *Service {
+	return &Service{cfg: cfg, load: load}
+}
+
+// Get returns the current configuration. It is shared, so it must not be
+// modified.
+func (s *Service) Get() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Subscribe calls fn with every configuration Reload loads, on the
+// goroutine calling Reload
+func (s *Service) Subscribe(fn func(*Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Reload loads the configuration again and passes it to the subscribers.
+// The current configuration stays when it fails to load.
+func (s *Service) Reload() error {
+	cfg, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cfg = cfg
+	subscribers := slices.Clone(s.subscribers)
+	s.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(cfg)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestServiceReload(t *testing.T) {
+	next := DefaultConfig()
+	next.Editor.TabSize = 8
+	var loadErr error
+	service := NewService(DefaultConfig(), func() (*Config, error) { return next, loadErr })
+
+	var got []int
+	service.Subscribe(func(cfg *Config) { got = append(got, cfg.Editor.TabSize) })
+	if err := service.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if service.Get() != next || len(got) != 1 || got[0] != 8 {
+		t.Errorf("after Reload Get() tab size %d, subscriber saw %v; want the loaded config passed once", service.Get().Editor.TabSize, got)
+	}
+
+	// A config that fails to load leaves the current one
+	loadErr = errors.New("bad yaml")
+	next = DefaultConfig()
+	if err := service.Reload(); err == nil {
+		t.Error("Reload succeeded with a failing load")
+	}
+	if service.Get().Editor.TabSize != 8 || len(got) != 1 {
+		t.Errorf("after a failed Reload tab size %d, subscriber calls %d; want 8 and 1", service.Get().Editor.TabSize, len(got))
+	}
+}
@@ -0,0 +1,148 @@
+// Package conversation keeps AI chat threads, and saves them to a directory
+// so that they can be resumed later.
+package conversation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Roles of the messages of a thread
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one question or answer of a thread
+type Message struct {
+	Role    string    `json:"role"`
+	Content string    `json:"content"`
+	Time    time.Time `json:"time"`
+}
+
+// Thread is a conversation with the AI
+type Thread struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Messages []Message `json:"messages"`
+}
+
+// titleLength is the most runes of the first question used as the title
+const titleLength = 60
+
+// NewThread starts an empty thread
+func NewThread(now time.Time) *Thread {
+	return &Thread{ID: now.Format("20060102-150405.000000"), Created: now, Updated: now}
+}
+
+// Add appends a message, taking the title from the first question
+func (t *Thread) Add(role, content string, now time.Time) {
+	t.Messages = append(t.Messages, Message{Role: role, Content: content, Time: now})
+	t.Updated = now
+	if t.Title == "" && role == RoleUser {
+		title := strings.Join(strings.Fields(content), " ")
+		if runes := []rune(title); len(runes) > titleLength {
+			title = strings.TrimRight(string(runes[:titleLength-3]), " ") + "..."
+		}
+		t.Title = title
+	}
+}
+
+// Transcript returns the last limit messages, or all of them when limit is
+// 0, as text to give the AI as context. It is "" for an empty thread.
+func (t *Thread) Transcript(limit int) string {
+	messages := t.Messages
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	var b strings.Builder
+	for _, m := range messages {
+		name := "User"
+		if m.Role == RoleAssistant {
+			name = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", name, m.Content)
+	}
+	return b.String()
+}
+
+// Store saves threads as JSON files in a directory
+type Store struct {
+	dir string
+}
+
+// Open returns the store of threads in dir, which is created when the
+// first thread is saved
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the directory of the store
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes t to the store
+func (s *Store) Save(t *Thread) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(t.ID), append(data, '\n'), 0600)
+}
+
+// Load reads the thread with id
+func (s *Store) Load(id string) (*Thread, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var t Thread
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to read thread %s: %w", id, err)
+	}
+	return &t, nil
+}
+
+// List returns the threads of the store, most recently updated first.
+// Files that can't be read are skipped.
+func (s *Store) List() ([]*Thread, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var threads []*Thread
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if t, err := s.Load(id); err == nil {
+			threads = append(threads, t)
+		}
+	}
+	slices.SortFunc(threads, func(a, b *Thread) int {
+		return b.Updated.Compare(a.Updated)
+	})
+	return threads, nil
+}
+
+// path returns the file of the thread with id
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
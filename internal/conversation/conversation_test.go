@@ -0,0 +1,59 @@
+package conversation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThread(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	thread := NewThread(now)
+	if thread.Transcript(0) != "" {
+		t.Errorf("expected an empty transcript, got %q", thread.Transcript(0))
+	}
+
+	thread.Add(RoleUser, "Why does   this loop\nnever end, and what would be the most idiomatic way to fix it in Go?", now)
+	thread.Add(RoleAssistant, "The counter is never incremented.", now.Add(time.Minute))
+	thread.Add(RoleUser, "Show me", now.Add(2*time.Minute))
+
+	if want := "Why does this loop never end, and what would be the most..."; thread.Title != want {
+		t.Errorf("title = %q, want %q", thread.Title, want)
+	}
+	if !thread.Updated.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("updated = %v", thread.Updated)
+	}
+	if got, want := thread.Transcript(2), "Assistant: The counter is never incremented.\n\nUser: Show me\n\n"; got != want {
+		t.Errorf("transcript = %q, want %q", got, want)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := Open(t.TempDir() + "/history")
+	if threads, err := store.List(); err != nil || len(threads) != 0 {
+		t.Fatalf("expected no threads before saving, got %v, %v", threads, err)
+	}
+
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	older := NewThread(now)
+	older.Add(RoleUser, "first", now)
+	newer := NewThread(now.Add(time.Second))
+	newer.Add(RoleUser, "second", now.Add(time.Hour))
+	for _, thread := range []*Thread{newer, older} {
+		if err := store.Save(thread); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	threads, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 2 || threads[0].Title != "second" || threads[1].Title != "first" {
+		t.Errorf("expected the most recent thread first, got %+v", threads)
+	}
+	loaded, err := store.Load(older.ID)
+	if err != nil || !strings.Contains(loaded.Transcript(0), "User: first") {
+		t.Errorf("load = %+v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package crash handles a panic in the editor: it gives the terminal back,
+// writes the modified buffers to recovery files and a report of the panic,
+// and tells the user where they are.
+package crash
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/crypt"
+)
+
+// Handler recovers from a panic of the editor
+type Handler struct {
+	dir     string                  // Where recovery files and reports are written
+	restore func()                  // Gives the terminal back
+	buffers func() []*buffer.Buffer // The open buffers
+	out     io.Writer               // Where the user is told what happened
+	now     func() time.Time
+}
+
+// NewHandler creates a handler writing to dir, restoring the terminal with
+// restore and saving the buffers returned by buffers
+func NewHandler(dir string, restore func(), buffers func() []*buffer.Buffer) *Handler {
+	return &Handler{dir: dir, restore: restore, buffers: buffers, out: os.Stderr, now: time.Now}
+}
+
+// DefaultDir returns the directory for recovery files and crash reports
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "aied-recovery")
+	}
+	return filepath.Join(home, ".config", "aied", "recovery")
+}
+
+// Recover handles a panic of the calling goroutine and exits. It must be
+// deferred.
+func (h *Handler) Recover() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	h.Handle(value, debug.Stack())
+	os.Exit(2)
+}
+
+// Handle restores the terminal, writes the recovery files and the report
+// of a panic with value and stack, and prints where they are. It returns
+// the path of the report, empty if it couldn't be written.
+func (h *Handler) Handle(value any, stack []byte) string {
+	h.restoreTerminal()
+
+	stamp := h.now().Format("20060102-150405")
+	var saved, failed []string
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		fmt.Fprintf(h.out, "aied crashed: %v\n%s\nCouldn't write recovery files: %v\n", value, stack, err)
+		return ""
+	}
+	for i, buf := range h.buffers() {
+		if !buf.Modified() {
+			continue
+		}
+		name := buf.Filename()
+		if crypt.IsEncrypted(name) {
+			// Never write the plaintext of an encrypted file
+			failed = append(failed, name+": encrypted, not written")
+			continue
+		}
+		path := filepath.Join(h.dir, recoveryName(stamp, i, name))
+		if err := os.WriteFile(path, []byte(buf.String()+"\n"), 0600); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", displayName(name), err))
+			continue
+		}
+		saved = append(saved, fmt.Sprintf("%s -> %s", displayName(name), path))
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "aied crashed at %s\n\n", h.now().Format(time.RFC3339))
+	fmt.Fprintf(&report, "panic: %v\n\n%s\n", value, stack)
+	fmt.Fprintf(&report, "%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&report, "%s %s\n", info.Main.Path, info.Main.Version)
+	}
+	if len(saved) > 0 {
+		fmt.Fprintf(&report, "\nRecovered buffers:\n  %s\n", strings.Join(saved, "\n  "))
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&report, "\nBuffers not recovered:\n  %s\n", strings.Join(failed, "\n  "))
+	}
+
+	reportPath := filepath.Join(h.dir, "crash-"+stamp+".log")
+	if err := os.WriteFile(reportPath, []byte(report.String()), 0600); err != nil {
+		fmt.Fprintf(h.out, "%s\nCouldn't write the crash report: %v\n", report.String(), err)
+		return ""
+	}
+
+	fmt.Fprintf(h.out, "aied crashed: %v\n", value)
+	for _, s := range saved {
+		fmt.Fprintf(h.out, "Recovered %s\n", s)
+	}
+	for _, f := range failed {
+		fmt.Fprintf(h.out, "Not recovered %s\n", f)
+	}
+	fmt.Fprintf(h.out, "Crash report: %s\n", reportPath)
+	return reportPath
+}
+
+// restoreTerminal gives the terminal back, going on if that panics too
+func (h *Handler) restoreTerminal() {
+	if h.restore == nil {
+		return
+	}
+	defer func() { recover() }()
+	h.restore()
+}
+
+// recoveryName names the recovery file of the ith buffer, holding name
+func recoveryName(stamp string, i int, name string) string {
+	base := "unnamed"
+	if name != "" {
+		base = filepath.Base(name)
+	}
+	return fmt.Sprintf("%s-%d-%s", stamp, i, base)
+}
+
+// displayName names a buffer in the report
+func displayName(name string) string {
+	if name == "" {
+		return "[No Name]"
+	}
+	return name
+}
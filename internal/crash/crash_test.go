@@ -0,0 +1,57 @@
+package crash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestHandleWritesRecoveryFilesAndReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recovery")
+
+	modified := buffer.New()
+	modified.InsertTextAt(0, 0, "unsaved work")
+	clean := buffer.New()
+	secret := buffer.New()
+	secret.SetFilename("notes.txt.gpg")
+	secret.InsertTextAt(0, 0, "password")
+
+	restored := false
+	h := NewHandler(dir, func() { restored = true; panic("terminal gone") }, func() []*buffer.Buffer {
+		return []*buffer.Buffer{modified, clean, secret}
+	})
+	var out strings.Builder
+	h.out = &out
+	h.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	reportPath := h.Handle("index out of range", []byte("goroutine 1 [running]:"))
+	if !restored {
+		t.Error("expected the terminal restored")
+	}
+	if reportPath != filepath.Join(dir, "crash-20260102-030405.log") {
+		t.Fatalf("report path = %q", reportPath)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "20260102-030405-0-unnamed"))
+	if err != nil || string(data) != "unsaved work\n" {
+		t.Errorf("recovery file = %q, %v", data, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected one recovery file and the report, got %d entries", len(entries))
+	}
+
+	report, _ := os.ReadFile(reportPath)
+	for _, want := range []string{"panic: index out of range", "goroutine 1 [running]:", "[No Name] -> ", "notes.txt.gpg: encrypted, not written"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+	if !strings.Contains(out.String(), "Crash report: "+reportPath) {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -0,0 +1,245 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// Options configure how encrypted files are written back
+type Options struct {
+	GPGRecipients []string // Encrypt .gpg files to these keys instead of a passphrase
+	AgeIdentity   string   // age identity file, prompted for when empty
+	AgeRecipients []string // Additional age recipients
+}
+
+// Handler decrypts .gpg and .age files into memory on load and encrypts
+// them again on save with the gpg and age commands. Plaintext only passes
+// through pipes and is never written to disk.
+type Handler struct {
+	mu      sync.Mutex
+	options Options
+	prompt  func(prompt string) (string, error)
+	secrets map[string]string // gpg passphrase or age identity by file
+	run     func(name string, args []string, stdin, passphrase []byte) ([]byte, error)
+}
+
+// NewHandler creates an encrypted file handler
+func NewHandler(options Options) *Handler {
+	return &Handler{
+		options: options,
+		secrets: make(map[string]string),
+		run:     runCommand,
+	}
+}
+
+// SetPrompt sets the function asking for passphrases and identity files.
+// Without a prompt, files whose secret isn't known yet can't be opened.
+func (h *Handler) SetPrompt(prompt func(prompt string) (string, error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prompt = prompt
+}
+
+// Handles reports whether filename is a .gpg or .age file
+func (h *Handler) Handles(filename string) bool {
+	return IsEncrypted(filename)
+}
+
+// IsEncrypted reports whether filename is kept encrypted on disk
+func IsEncrypted(filename string) bool {
+	return kind(filename) != ""
+}
+
+// Indicator labels encrypted buffers in the status line
+func (h *Handler) Indicator(filename string) string {
+	return "[" + kind(filename) + "]"
+}
+
+// ReadFile decrypts filename, asking for its passphrase or identity when needed
+func (h *Handler) ReadFile(filename string) ([]byte, error) {
+	ciphertext, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext []byte
+	switch kind(filename) {
+	case "gpg":
+		passphrase, err := h.secret(filename, "Passphrase for "+filename+": ")
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err = h.run("gpg", append(gpgArgs(), "--decrypt"), ciphertext, []byte(passphrase))
+		if err != nil {
+			h.Forget(filename)
+			return nil, err
+		}
+	case "age":
+		identity := h.options.AgeIdentity
+		if identity == "" {
+			if identity, err = h.secret(filename, "age identity file for "+filename+": "); err != nil {
+				return nil, err
+			}
+		}
+		plaintext, err = h.run("age", []string{"--decrypt", "-i", expandHome(identity)}, ciphertext, nil)
+		if err != nil {
+			h.Forget(filename)
+			return nil, err
+		}
+	}
+	return plaintext, nil
+}
+
+// WriteFile encrypts data and replaces filename with the ciphertext
+func (h *Handler) WriteFile(filename string, data []byte) error {
+	_, err := h.WriteFileWarnings(filename, data)
+	return err
+}
+
+// WriteFileWarnings is WriteFile returning what went wrong in a write that
+// still replaced the file. The ciphertext goes through a temporary file
+// like any saved buffer, so a crash while writing can't truncate the file.
+func (h *Handler) WriteFileWarnings(filename string, data []byte) ([]string, error) {
+	var ciphertext []byte
+	var err error
+	switch kind(filename) {
+	case "gpg":
+		ciphertext, err = h.encryptGPG(filename, data)
+	case "age":
+		ciphertext, err = h.encryptAge(filename, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buffer.WriteLocalFile(filename, ciphertext, 0600)
+}
+
+// Forget drops the cached secret of filename
+func (h *Handler) Forget(filename string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.secrets, filename)
+}
+
+// encryptGPG encrypts to the configured recipients, or symmetrically with
+// the passphrase the file was opened with
+func (h *Handler) encryptGPG(filename string, data []byte) ([]byte, error) {
+	if len(h.options.GPGRecipients) > 0 {
+		args := append(gpgArgs(), "--encrypt")
+		for _, recipient := range h.options.GPGRecipients {
+			args = append(args, "--recipient", recipient)
+		}
+		return h.run("gpg", args, data, nil)
+	}
+
+	passphrase, err := h.secret(filename, "New passphrase for "+filename+": ")
+	if err != nil {
+		return nil, err
+	}
+	return h.run("gpg", append(gpgArgs(), "--symmetric"), data, []byte(passphrase))
+}
+
+// encryptAge encrypts to the configured recipients and the identity the
+// file was opened with
+func (h *Handler) encryptAge(filename string, data []byte) ([]byte, error) {
+	args := []string{"--encrypt"}
+	for _, recipient := range h.options.AgeRecipients {
+		args = append(args, "-r", recipient)
+	}
+
+	identity := h.options.AgeIdentity
+	if identity == "" {
+		h.mu.Lock()
+		identity = h.secrets[filename]
+		h.mu.Unlock()
+	}
+	if identity != "" {
+		args = append(args, "-i", expandHome(identity))
+	}
+	if len(args) == 1 {
+		return nil, fmt.Errorf("no age recipients for %s: set age_recipients or age_identity", filename)
+	}
+	return h.run("age", args, data, nil)
+}
+
+// secret returns the cached secret of filename, prompting for it first
+func (h *Handler) secret(filename, prompt string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if secret, ok := h.secrets[filename]; ok {
+		return secret, nil
+	}
+	if h.prompt == nil {
+		return "", fmt.Errorf("cannot ask for the passphrase or identity of %s", filename)
+	}
+	secret, err := h.prompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	h.secrets[filename] = secret
+	return secret, nil
+}
+
+// kind returns "gpg" or "age" for encrypted files and "" otherwise
+func kind(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gpg":
+		return "gpg"
+	case ".age":
+		return "age"
+	}
+	return ""
+}
+
+// gpgArgs are the options shared by all gpg invocations. The passphrase,
+// when given, is read from file descriptor 3.
+func gpgArgs() []string {
+	return []string{"--batch", "--quiet", "--yes", "--pinentry-mode", "loopback", "--passphrase-fd", "3", "--output", "-"}
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// runCommand runs name with stdin as input, passing passphrase on file
+// descriptor 3, and returns its output
+func runCommand(name string, args []string, stdin, passphrase []byte) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	w.Write(append(passphrase, '\n'))
+	w.Close()
+	cmd.ExtraFiles = []*os.File{r}
+
+	if err := cmd.Run(); err != nil {
+		// gpg and age prefix their messages with the program name
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
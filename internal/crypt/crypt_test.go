@@ -0,0 +1,201 @@
+package crypt
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCipher is a command runner "encrypting" by prefixing the passphrase
+// and recording each invocation
+type fakeCipher struct {
+	calls [][]string
+}
+
+func (f *fakeCipher) run(name string, args []string, stdin, passphrase []byte) ([]byte, error) {
+	f.calls = append(f.calls, append([]string{name}, args...))
+	key := string(passphrase) + ":"
+	for _, arg := range args {
+		switch arg {
+		case "--decrypt":
+			plaintext, ok := strings.CutPrefix(string(stdin), key)
+			if !ok {
+				return nil, errors.New("gpg: decryption failed: Bad session key")
+			}
+			return []byte(plaintext), nil
+		case "--encrypt", "--symmetric":
+			return []byte(key + string(stdin)), nil
+		}
+	}
+	return nil, errors.New("unexpected command")
+}
+
+func TestHandler_Handles(t *testing.T) {
+	h := NewHandler(Options{})
+	for name, want := range map[string]string{
+		"secrets.gpg":     "[gpg]",
+		"keys.AGE":        "[age]",
+		"notes.txt":       "",
+		"archive.gpg.txt": "",
+	} {
+		if got := h.Handles(name); got != (want != "") {
+			t.Errorf("Handles(%q) = %v", name, got)
+		}
+		if want != "" && h.Indicator(name) != want {
+			t.Errorf("Indicator(%q) = %q, want %q", name, h.Indicator(name), want)
+		}
+	}
+}
+
+func TestHandler_GPGPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.gpg")
+	if err := os.WriteFile(path, []byte("hunter2:secret text"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeCipher{}
+	h := NewHandler(Options{})
+	h.run = fake.run
+
+	// Without a prompt the passphrase can't be asked for
+	if _, err := h.ReadFile(path); err == nil {
+		t.Fatal("expected an error without a prompt")
+	}
+
+	prompts := 0
+	answer := "wrong"
+	h.SetPrompt(func(string) (string, error) {
+		prompts++
+		return answer, nil
+	})
+	if _, err := h.ReadFile(path); err == nil || !strings.Contains(err.Error(), "decryption failed") {
+		t.Fatalf("ReadFile with a wrong passphrase returned %v", err)
+	}
+
+	// A wrong passphrase is not remembered
+	answer = "hunter2"
+	data, err := h.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "secret text" {
+		t.Errorf("ReadFile = %q", data)
+	}
+
+	// Saving reuses the passphrase and keeps the file mode
+	if err := h.WriteFile(path, []byte("edited")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if prompts != 2 {
+		t.Errorf("prompted %d times, want 2", prompts)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "hunter2:edited" {
+		t.Errorf("file content = %q", content)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("file mode = %v, want 0640", info.Mode().Perm())
+	}
+}
+
+func TestHandler_GPGRecipients(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.gpg")
+	fake := &fakeCipher{}
+	h := NewHandler(Options{GPGRecipients: []string{"alice@example.com"}})
+	h.run = fake.run
+
+	if err := h.WriteFile(path, []byte("text")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	args := strings.Join(fake.calls[0], " ")
+	if !strings.Contains(args, "--encrypt --recipient alice@example.com") {
+		t.Errorf("gpg arguments = %q", args)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("new file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestHandler_Age(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.age")
+	os.WriteFile(path, []byte(":text"), 0600)
+
+	fake := &fakeCipher{}
+	h := NewHandler(Options{})
+	h.run = fake.run
+
+	// Encrypting needs a recipient
+	if err := h.WriteFile(path, []byte("text")); err == nil {
+		t.Fatal("expected an error without recipients")
+	}
+
+	h.SetPrompt(func(string) (string, error) { return "/keys/me.txt", nil })
+	if _, err := h.ReadFile(path); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := h.WriteFile(path, []byte("text")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	args := strings.Join(fake.calls[len(fake.calls)-1], " ")
+	if args != "age --encrypt -i /keys/me.txt" {
+		t.Errorf("age arguments = %q", args)
+	}
+}
+
+func TestHandler_GPGRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not installed")
+	}
+	t.Setenv("GNUPGHOME", t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "notes.gpg")
+	h := NewHandler(Options{})
+	h.SetPrompt(func(string) (string, error) { return "correct horse", nil })
+	if err := h.WriteFile(path, []byte("plain text\n")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), "plain text") {
+		t.Fatal("file contains plaintext")
+	}
+
+	reader := NewHandler(Options{})
+	reader.SetPrompt(func(string) (string, error) { return "correct horse", nil })
+	data, err := reader.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "plain text\n" {
+		t.Errorf("ReadFile = %q", data)
+	}
+}
+
+func TestHandler_WriteKeepsModeAndSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "notes.gpg")
+	os.WriteFile(target, []byte("secret:old"), 0640)
+	link := filepath.Join(dir, "link.gpg")
+	os.Symlink(target, link)
+
+	h := NewHandler(Options{})
+	h.run = (&fakeCipher{}).run
+	h.SetPrompt(func(string) (string, error) { return "secret", nil })
+	if err := h.WriteFile(link, []byte("new")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "secret:new" {
+		t.Errorf("target = %q", data)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Error("the symlink was replaced")
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("directory holds %d entries, want 2", len(entries))
+	}
+}
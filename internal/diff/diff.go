@@ -0,0 +1,235 @@
+// Package diff compares texts line by line and within lines using the
+// Myers algorithm, and aligns two texts for side-by-side display.
+package diff
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Hunk is a region where two texts differ: lines A to A+ACount of the first
+// were replaced by lines B to B+BCount of the second. An insertion has an
+// ACount of 0 and a deletion a BCount of 0; A and B are then where the
+// lines would go.
+type Hunk struct {
+	A, ACount int
+	B, BCount int
+}
+
+// Lines returns the hunks turning a into b, in order
+func Lines(a, b []string) []Hunk {
+	return compute(a, b)
+}
+
+// Unified returns the hunks turning a into b in unified diff format,
+// without context lines
+func Unified(a, b []string) []string {
+	// An empty side is numbered by the line before it
+	start := func(line, count int) int {
+		if count == 0 {
+			return line
+		}
+		return line + 1
+	}
+	var out []string
+	for _, h := range Lines(a, b) {
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", start(h.A, h.ACount), h.ACount, start(h.B, h.BCount), h.BCount))
+		for _, line := range a[h.A : h.A+h.ACount] {
+			out = append(out, "-"+line)
+		}
+		for _, line := range b[h.B : h.B+h.BCount] {
+			out = append(out, "+"+line)
+		}
+	}
+	return out
+}
+
+// Span is a byte range [Start, End) of a line
+type Span struct {
+	Start, End int
+}
+
+// Runes compares two versions of a line and returns the byte ranges of a
+// and of b that changed
+func Runes(a, b string) ([]Span, []Span) {
+	ra, rb := []rune(a), []rune(b)
+	offsetsA, offsetsB := byteOffsets(a), byteOffsets(b)
+
+	var spansA, spansB []Span
+	for _, h := range compute(ra, rb) {
+		if h.ACount > 0 {
+			spansA = append(spansA, Span{offsetsA[h.A], offsetsA[h.A+h.ACount]})
+		}
+		if h.BCount > 0 {
+			spansB = append(spansB, Span{offsetsB[h.B], offsetsB[h.B+h.BCount]})
+		}
+	}
+	return spansA, spansB
+}
+
+// byteOffsets returns the byte offset of every rune of s, and len(s)
+func byteOffsets(s string) []int {
+	var offsets []int
+	for i := range s {
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(s))
+}
+
+// op is one step of an edit script
+type op int
+
+const (
+	opEqual op = iota
+	opDelete
+	opInsert
+)
+
+// compute finds the hunks turning a into b. The common prefix and suffix
+// are skipped before running Myers, which is quadratic in the size of the
+// differences.
+func compute[T comparable](a, b []T) []Hunk {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var hunks []Hunk
+	x, y := prefix, prefix
+	var current *Hunk
+	for _, o := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		if o == opEqual {
+			current = nil
+			x++
+			y++
+			continue
+		}
+		if current == nil {
+			hunks = append(hunks, Hunk{A: x, B: y})
+			current = &hunks[len(hunks)-1]
+		}
+		if o == opDelete {
+			current.ACount++
+			x++
+		} else {
+			current.BCount++
+			y++
+		}
+	}
+	return hunks
+}
+
+// myers returns the shortest edit script turning a into b, as described in
+// "An O(ND) Difference Algorithm and Its Variations"
+func myers[T comparable](a, b []T) []op {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	offset := n + m
+	v := make([]int, 2*(n+m)+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back through the furthest points reached for each d
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, opEqual)
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, opInsert)
+			} else {
+				ops = append(ops, opDelete)
+			}
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// RowKind is how a row of a side-by-side diff differs
+type RowKind int
+
+const (
+	RowEqual   RowKind = iota // The same line on both sides
+	RowChanged                // A line of a replaced by a line of b
+	RowDeleted                // A line only in a
+	RowAdded                  // A line only in b
+)
+
+// Row is one row of a side-by-side diff, showing line A of the first text
+// and line B of the second. Either is -1 on the side with a filler line.
+type Row struct {
+	A, B int
+	Kind RowKind
+}
+
+// Align lays out a text of aLen lines and the text hunks turn it into side
+// by side. The lines of a hunk are paired as changed lines, and the lines one
+// side has more of face filler lines.
+func Align(hunks []Hunk, aLen int) []Row {
+	var rows []Row
+	a, b := 0, 0
+	equal := func(untilA int) {
+		for ; a < untilA; a, b = a+1, b+1 {
+			rows = append(rows, Row{A: a, B: b, Kind: RowEqual})
+		}
+	}
+	for _, h := range hunks {
+		equal(h.A)
+		for i := 0; i < max(h.ACount, h.BCount); i++ {
+			switch {
+			case i < h.ACount && i < h.BCount:
+				rows = append(rows, Row{A: a, B: b, Kind: RowChanged})
+				a, b = a+1, b+1
+			case i < h.ACount:
+				rows = append(rows, Row{A: a, B: -1, Kind: RowDeleted})
+				a++
+			default:
+				rows = append(rows, Row{A: -1, B: b, Kind: RowAdded})
+				b++
+			}
+		}
+	}
+	equal(aLen)
+	return rows
+}
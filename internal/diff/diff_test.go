@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []Hunk
+	}{
+		{"a b c", "a b c", nil},
+		{"a b c", "a x c", []Hunk{{1, 1, 1, 1}}},
+		{"a b c", "a c", []Hunk{{1, 1, 1, 0}}},
+		{"a c", "a b c", []Hunk{{1, 0, 1, 1}}},
+		{"", "a b", []Hunk{{0, 0, 0, 2}}},
+		{"a b c d e", "x b c e f", []Hunk{{0, 1, 0, 1}, {3, 1, 3, 0}, {5, 0, 4, 1}}},
+	}
+
+	for _, tt := range tests {
+		got := Lines(strings.Fields(tt.a), strings.Fields(tt.b))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// apply turns a into b with the hunks, to check they are a correct edit script
+func apply(a, b []string, hunks []Hunk) []string {
+	var out []string
+	next := 0
+	for _, h := range hunks {
+		out = append(out, a[next:h.A]...)
+		out = append(out, b[h.B:h.B+h.BCount]...)
+		next = h.A + h.ACount
+	}
+	return append(out, a[next:]...)
+}
+
+func TestLines_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		hunks := Lines(a, b)
+		if got := apply(a, b, hunks); strings.Join(got, " ") != strings.Join(b, " ") {
+			t.Fatalf("hunks %v turn %v into %v, want %v", hunks, a, got, b)
+		}
+
+		rows := Align(hunks, len(a))
+		left, right := 0, 0
+		for _, row := range rows {
+			if row.A >= 0 {
+				left++
+			}
+			if row.B >= 0 {
+				right++
+			}
+		}
+		if left != len(a) || right != len(b) {
+			t.Fatalf("Align shows %d and %d lines of %v and %v", left, right, a, b)
+		}
+	}
+}
+
+func TestRunes(t *testing.T) {
+	a, b := Runes("héllo world", "héllo there world")
+	if len(a) != 0 || !reflect.DeepEqual(b, []Span{{7, 13}}) {
+		t.Errorf("Runes insertion = %v, %v", a, b)
+	}
+
+	a, b = Runes("x := 1", "x := 42")
+	if !reflect.DeepEqual(a, []Span{{5, 6}}) || !reflect.DeepEqual(b, []Span{{5, 7}}) {
+		t.Errorf("Runes change = %v, %v", a, b)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	a := strings.Fields("a b c d")
+	b := strings.Fields("a x y d e")
+	want := []Row{
+		{0, 0, RowEqual},
+		{1, 1, RowChanged},
+		{2, 2, RowChanged},
+		{3, 3, RowEqual},
+		{-1, 4, RowAdded},
+	}
+	if got := Align(Lines(a, b), len(a)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Align = %v, want %v", got, want)
+	}
+
+	want = []Row{{0, 0, RowChanged}, {1, -1, RowDeleted}, {2, -1, RowDeleted}}
+	if got := Align(Lines(strings.Fields("a b c"), []string{"x"}), 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("Align deletion = %v, want %v", got, want)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := []string{"a", "b", "c", "d"}
+	b := []string{"a", "B", "c", "d", "e"}
+	want := []string{"@@ -2,1 +2,1 @@", "-b", "+B", "@@ -4,0 +5,1 @@", "+e"}
+	if got := Unified(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unified = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,402 @@
+// Package editor ties the editing core together: it owns the buffer, the
+// modes, the frontend, the language servers and the AI providers, and runs
+// the loop turning the frontend's events into frames.
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/crypt"
+	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/modeline"
+	"github.com/dshills/aied/internal/modes"
+	"github.com/dshills/aied/internal/perf"
+	"github.com/dshills/aied/internal/privacy"
+	"github.com/dshills/aied/internal/recent"
+	"github.com/dshills/aied/internal/registers"
+	"github.com/dshills/aied/internal/rpc"
+	"github.com/dshills/aied/internal/stdio"
+	"github.com/dshills/aied/internal/ui"
+	"github.com/gdamore/tcell/v2"
+	"go.lsp.dev/protocol"
+)
+
+// Options are what an Editor is made of
+type Options struct {
+	Frontend ui.Frontend
+	Buffer   *buffer.Buffer
+	Config   *config.Service
+	AI       *ai.AIManager
+	LSP      *lsp.Manager    // nil without language servers
+	Privacy  *privacy.Policy // Keeps private files from the agent, or nil
+	Light    bool            // Whether the terminal's background is light, for themes following it
+}
+
+// Editor runs the editor on a frontend. Its methods must be called from
+// the main loop, the goroutine running Run, or before Run starts.
+type Editor struct {
+	frontend    ui.Frontend
+	buf         *buffer.Buffer
+	config      *config.Service
+	aiManager   *ai.AIManager
+	lspManager  *lsp.Manager
+	modeManager *modes.ModeManager
+	commands    *commands.State // Kept by the ex commands the modes run
+	light       bool
+	hinter      *keyHinter
+	spinner     *activitySpinner
+	registers   *registers.Store
+	rpcServer   *rpc.Server
+	recent      *recent.Files
+	recorded    string // The file last added to the recent files
+	keys        int    // Keys handled, so a timeout knows whether another came
+
+	diagnosticsMu sync.Mutex
+	diagnostics   map[string][]buffer.Diagnostic // From the language servers, by file
+}
+
+// New sets up an editor on opts.Frontend editing opts.Buffer with the
+// configured settings
+func New(opts Options) *Editor {
+	cfg := opts.Config.Get()
+	e := &Editor{
+		frontend:    opts.Frontend,
+		buf:         opts.Buffer,
+		config:      opts.Config,
+		aiManager:   opts.AI,
+		lspManager:  opts.LSP,
+		modeManager: modes.NewModeManager(),
+		light:       opts.Light,
+		spinner:     &activitySpinner{frontend: opts.Frontend},
+		diagnostics: make(map[string][]buffer.Diagnostic),
+	}
+	buf := e.buf
+	e.commands = e.modeManager.Context().Commands()
+
+	// Files the editor keeps between sessions that can't be read, and
+	// settings it can't use, are shown on the status line once it runs;
+	// the terminal belongs to the frontend by now
+	var warnings []string
+
+	// Files opened are remembered for :oldfiles and the start screen
+	e.recent = initializeRecent(&warnings)
+	e.recordRecent()
+
+	// Let :make and :test stream their output to the screen, and
+	// :SudoWrite take over the terminal to ask for a password
+	e.commands.SetRedrawFunc(e.frontend.RequestRedraw)
+	e.commands.SetTerminalFunc(e.frontend.RunInTerminal)
+
+	// Show AI requests waiting for a provider's rate limit as they come and go
+	e.aiManager.SetQueueNotify(e.frontend.RequestRedraw)
+
+	// Language servers answer the modes, and their diagnostics are shown
+	if e.lspManager != nil {
+		e.modeManager.SetLSPManager(e.lspManager)
+		if cfg.LSP.ShowDiagnostics {
+			e.lspManager.SetDiagnosticsHandler(e.setDiagnostics)
+		}
+	}
+
+	// Apply editor display and buffer settings
+	applyEditorConfig(cfg, e.frontend, e.commands, buf)
+
+	// Modelines in the file override the configured buffer options
+	modeline.SetEnabled(cfg.Editor.Modeline)
+	e.commands.ApplyModeline(buf)
+
+	// Files with conflict markers open in the merge view
+	e.commands.OpenMergeTool(buf)
+
+	// Set up user commands, comment strings, completion buffers, project
+	// detection and persistent registers
+	if err := e.modeManager.SetUserCommands(cfg.Commands); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Skipping user commands: %v", err))
+	}
+	e.commands.SetRootMarkers(cfg.Editor.RootMarkers)
+	e.commands.SetOrganizeImportsOnSave(cfg.LSP.OrganizeImportsOnSave)
+	e.modeManager.SetCommentStyles(commentStyles(cfg))
+	e.modeManager.SetLeader(leaderMappings(cfg, &warnings))
+	buffers := func() []*buffer.Buffer { return []*buffer.Buffer{buf} }
+	e.modeManager.SetBufferProvider(buffers)
+	e.modeManager.SetViewport(e.frontend.GetViewport)
+
+	// Ex commands reach the services through the command context
+	e.modeManager.SetCommandContext(&commands.CommandContext{
+		Buffers: buffers,
+		AI:      e.aiManager,
+		LSP:     e.lspManager,
+		Config:  e.config,
+		Privacy: opts.Privacy,
+		Recent:  e.recent,
+		Message: e.modeManager.Context().Message,
+		Defer: func(finish func() commands.CommandResult) {
+			e.frontend.Call(func() { e.modeManager.Finish(finish(), e.buf) })
+		},
+	})
+	if store := initializeRegisters(cfg, &warnings); store != nil {
+		// Text yanked from an encrypted file never reaches the register file
+		store.SetPrivate(func() bool { return crypt.IsEncrypted(buf.Filename()) })
+		e.modeManager.SetRegisters(store)
+		e.registers = store
+	}
+
+	// The palette lists the commands run from it most first
+	e.modeManager.SetPaletteHistory(initializePalette(&warnings))
+
+	// Set up spell checking if enabled
+	if checker := initializeSpell(cfg, &warnings); checker != nil {
+		e.modeManager.SetSpellChecker(checker)
+		e.frontend.AddHighlighter(spellHighlighter(checker, buf))
+	}
+	e.frontend.AddHighlighter(ui.ColorSwatches)
+	e.frontend.AddHighlighter(searchHighlighter(buf))
+
+	// Keys that may complete a pending command are listed after a delay
+	e.hinter = &keyHinter{cfg: cfg.Editor.WhichKey, redraw: e.frontend.RequestRedraw}
+
+	// :configreload applies the new settings
+	e.config.Subscribe(e.reload)
+	e.warn(warnings)
+	return e
+}
+
+// Close saves what the editor keeps between sessions and stops the work
+// it started
+func (e *Editor) Close() {
+	if e.rpcServer != nil {
+		e.rpcServer.Close()
+	}
+	if e.registers != nil {
+		e.registers.Save()
+	}
+	e.commands.CancelBuild()
+}
+
+// Run shows the editor and handles the frontend's events until it quits.
+// The AI providers and language servers start once the first frame shows.
+func (e *Editor) Run() {
+	e.startScreen()
+	e.draw()
+
+	// The status line spinner turns while the editor waits on work
+	go e.spinner.run()
+
+	// With the editor showing, configure the AI providers and start the
+	// language servers, the spinner turning while they do
+	cfg := e.config.Get()
+	go func() { e.warn(ConfigureAI(e.aiManager, cfg)) }()
+	if e.lspManager != nil {
+		go func() {
+			warnings := startLSP(e.lspManager, cfg)
+			e.frontend.Call(func() {
+				if err := openInLSP(e.lspManager, e.buf); err != nil {
+					warnings = append(warnings, fmt.Sprintf("Failed to open file in LSP: %v", err))
+				}
+			})
+			e.warn(warnings)
+		}()
+	}
+
+	for e.frontend.IsRunning() {
+		e.HandleEvent(e.frontend.WaitForEvent())
+	}
+}
+
+// HandleEvent handles one event from the frontend and draws the frame
+// after it
+func (e *Editor) HandleEvent(event interface{}) {
+	handled := time.Now()
+	e.spinner.handling.Store(true)
+
+	switch ev := event.(type) {
+	case ui.KeyEvent:
+		// Handle input through mode system, then the keys no mode takes
+		if e.handleKey(ev) {
+			e.frontend.PostQuit()
+		}
+		e.awaitKey()
+	case ui.ResizeEvent:
+		e.frontend.HandleResize(ev)
+	case ui.RedrawEvent:
+		// Background output arrived; just render again
+	case ui.CallEvent:
+		// An RPC client's request, or work finished in the background
+		ev.Fn()
+	}
+
+	// Update buffer diagnostics if available
+	if e.buf.Filename() != "" {
+		if diags, ok := e.fileDiagnostics(e.buf.Filename()); ok {
+			e.buf.SetDiagnostics(diags)
+		}
+	}
+
+	// Show build output, or the undo tree, quickfix or location list window if open
+	e.commands.FinishBuild(e.buf)
+	e.spinner.handling.Store(false)
+
+	// Keep the language server's copy of the file current
+	updateLSPBuffer(e.lspManager, e.buf)
+	e.recordRecent()
+
+	// Re-render after any changes with current mode
+	rendered := time.Now()
+	e.draw()
+
+	// Tell RPC clients what changed
+	if e.rpcServer != nil {
+		e.rpcServer.Update(e.State(), e.buf.String)
+	}
+
+	// Time the frame, and for keys everything from the key to the frame
+	perf.Since("render", rendered)
+	if _, ok := event.(ui.KeyEvent); ok {
+		perf.Since("key to frame", handled)
+	}
+}
+
+// handleKey passes key to the modes, and handles what they leave. It
+// returns whether the editor should quit.
+func (e *Editor) handleKey(key ui.KeyEvent) bool {
+	// Ctrl-C cancels the AI requests under way before it quits
+	if key.Key == tcell.KeyCtrlC || key.Action == ui.KeyActionCtrlC {
+		if e.aiManager != nil && len(e.aiManager.InFlight()) > 0 {
+			e.modeManager.Context().Execute("aicancel", e.buf)
+			return false
+		}
+	}
+
+	result := e.modeManager.HandleInput(key, e.buf)
+	if result.ExitEditor {
+		return true
+	}
+	if result.Handled {
+		return false
+	}
+
+	switch key.Action {
+	case ui.KeyActionQuit, ui.KeyActionCtrlC:
+		return true
+	case ui.KeyActionCtrlS:
+		// Global save command
+		if e.buf.Filename() != "" {
+			e.buf.Save()
+		}
+	}
+	return false
+}
+
+// awaitKey ends the command the keys so far leave unfinished when no other
+// key comes within timeoutlen
+func (e *Editor) awaitKey() {
+	e.keys++
+	timeout := e.commands.TimeoutLen()
+	if timeout == 0 || !e.modeManager.AwaitingKey() {
+		return
+	}
+	keys := e.keys
+	time.AfterFunc(timeout, func() {
+		e.frontend.Call(func() {
+			if e.keys == keys && e.modeManager.TimeOut(e.buf).ExitEditor {
+				e.frontend.PostQuit()
+			}
+		})
+	})
+}
+
+// startScreen lists the recent files to open one when the editor starts
+// without a file
+func (e *Editor) startScreen() {
+	if e.buf.Filename() != "" || e.buf.Modified() || e.buf.LineCount() > 1 || e.buf.CurrentLine() != "" {
+		return
+	}
+	if len(e.recent.List()) > 0 {
+		e.modeManager.Execute("oldfiles", e.buf)
+	}
+}
+
+// recordRecent adds the file being edited to the recent files when it
+// changed since the last time
+func (e *Editor) recordRecent() {
+	filename := e.buf.Filename()
+	if filename == "" || filename == stdio.Name || filename == e.recorded {
+		return
+	}
+	e.recorded = filename
+	e.recent.Add(filename)
+}
+
+// draw shows the current frame
+func (e *Editor) draw() {
+	e.frontend.Draw(e.Frame())
+}
+
+// Modes returns the editor's modes
+func (e *Editor) Modes() *modes.ModeManager {
+	return e.modeManager
+}
+
+// warn shows warnings from work in the background on the status line. It
+// may be called from any goroutine.
+func (e *Editor) warn(warnings []string) {
+	if len(warnings) > 0 {
+		e.frontend.Call(func() { e.modeManager.Context().Message(strings.Join(warnings, "; ")) })
+	}
+}
+
+// reload applies cfg, a reloaded configuration
+func (e *Editor) reload(cfg *config.Config) {
+	var warnings []string
+	applyEditorConfig(cfg, e.frontend, e.commands, e.buf)
+	if styles, err := ThemeStyles(cfg, e.light); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Keeping the colors: %v", err))
+	} else {
+		e.frontend.SetStyles(styles)
+	}
+	modeline.SetEnabled(cfg.Editor.Modeline)
+	if err := e.modeManager.SetUserCommands(cfg.Commands); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Skipping user commands: %v", err))
+	}
+	e.commands.SetRootMarkers(cfg.Editor.RootMarkers)
+	e.commands.SetOrganizeImportsOnSave(cfg.LSP.OrganizeImportsOnSave)
+	e.modeManager.SetCommentStyles(commentStyles(cfg))
+	e.modeManager.SetLeader(leaderMappings(cfg, &warnings))
+	e.hinter.cfg = cfg.Editor.WhichKey
+	e.warn(warnings)
+	go func() { e.warn(ConfigureAI(e.aiManager, cfg)) }()
+}
+
+// setDiagnostics keeps the diagnostics a language server published for
+// filename. It is called from the server's goroutine.
+func (e *Editor) setDiagnostics(filename string, diagnostics []protocol.Diagnostic) {
+	var bufDiags []buffer.Diagnostic
+	for _, diag := range diagnostics {
+		bufDiags = append(bufDiags, buffer.Diagnostic{
+			Line:     int(diag.Range.Start.Line),
+			Column:   int(diag.Range.Start.Character),
+			Severity: int(diag.Severity),
+			Message:  diag.Message,
+			Source:   diag.Source,
+		})
+	}
+
+	e.diagnosticsMu.Lock()
+	defer e.diagnosticsMu.Unlock()
+	e.diagnostics[filename] = bufDiags
+}
+
+// fileDiagnostics returns the last diagnostics published for filename
+func (e *Editor) fileDiagnostics(filename string) ([]buffer.Diagnostic, bool) {
+	e.diagnosticsMu.Lock()
+	defer e.diagnosticsMu.Unlock()
+	diags, ok := e.diagnostics[filename]
+	return diags, ok
+}
@@ -0,0 +1,257 @@
+package editor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/ui"
+	"github.com/gdamore/tcell/v2"
+	"go.lsp.dev/protocol"
+)
+
+// fakeFrontend replays events and keeps the frames drawn. It stops
+// running once the events run out.
+type fakeFrontend struct {
+	mu      sync.Mutex
+	events  []interface{}
+	frames  []*ui.Frame
+	running bool
+	options ui.RenderOptions
+}
+
+func newFakeFrontend(t *testing.T, keys string) *fakeFrontend {
+	t.Helper()
+	events, err := ui.ParseKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeFrontend{running: true, options: ui.DefaultRenderOptions()}
+	for _, event := range events {
+		f.events = append(f.events, event)
+	}
+	return f
+}
+
+func (f *fakeFrontend) WaitForEvent() interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.events) == 0 {
+		f.running = false
+		return ui.RedrawEvent{}
+	}
+	event := f.events[0]
+	f.events = f.events[1:]
+	return event
+}
+
+func (f *fakeFrontend) Draw(frame *ui.Frame) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frames = append(f.frames, frame)
+}
+
+func (f *fakeFrontend) lastFrame() *ui.Frame {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frames[len(f.frames)-1]
+}
+
+// pending returns how many events wait to be handled
+func (f *fakeFrontend) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.events)
+}
+
+func (f *fakeFrontend) Call(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, ui.CallEvent{Fn: fn})
+}
+
+func (f *fakeFrontend) IsRunning() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running
+}
+
+func (f *fakeFrontend) PostQuit() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = false
+}
+
+func (f *fakeFrontend) HandleResize(event ui.ResizeEvent)         {}
+func (f *fakeFrontend) GetSize() (int, int)                       { return 80, 24 }
+func (f *fakeFrontend) GetViewport() ui.Viewport                  { return ui.Viewport{Width: 80, Height: 22} }
+func (f *fakeFrontend) RequestRedraw()                            {}
+func (f *fakeFrontend) RunInTerminal(run func() error) error      { return run() }
+func (f *fakeFrontend) ShowActivity(text string)                  {}
+func (f *fakeFrontend) RenderOptions() ui.RenderOptions           { return f.options }
+func (f *fakeFrontend) SetRenderOptions(options ui.RenderOptions) { f.options = options }
+func (f *fakeFrontend) SetStyles(styles *ui.StyleConfig)          {}
+func (f *fakeFrontend) AddHighlighter(h ui.Highlighter)           {}
+func (f *fakeFrontend) Close()                                    {}
+
+// newTestEditor returns an editor on frontend editing a file holding text,
+// with nothing kept between sessions and no AI providers or language
+// servers
+func newTestEditor(t *testing.T, frontend ui.Frontend, text string) (*Editor, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	filename := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := buffer.NewFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Providers = nil
+	cfg.Editor.Registers.Persist = false
+	cfg.Editor.ReportDir = false
+	service := config.NewService(cfg, func() (*config.Config, error) { return cfg, nil })
+	e := New(Options{Frontend: frontend, Buffer: buf, Config: service, AI: ai.NewAIManager()})
+	t.Cleanup(e.Close)
+	return e, filename
+}
+
+func TestRunEditsSavesAndQuits(t *testing.T) {
+	frontend := newFakeFrontend(t, "ihello <Esc>:wq<CR>")
+	e, filename := newTestEditor(t, frontend, "world")
+	e.Run()
+
+	if frontend.IsRunning() {
+		t.Error("the editor still runs after :wq")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "hello world" {
+		t.Errorf("saved %q, want %q", got, "hello world")
+	}
+}
+
+func TestHandleEventDrawsFrame(t *testing.T) {
+	frontend := newFakeFrontend(t, "")
+	e, _ := newTestEditor(t, frontend, "one\ntwo")
+
+	keys, _ := ui.ParseKeys("jdd")
+	for _, key := range keys {
+		e.HandleEvent(key)
+	}
+	frame := frontend.lastFrame()
+	if got := strings.Join(frame.Buffer.Lines(), "|"); got != "one" {
+		t.Errorf("after jdd the frame shows lines %q, want %q", got, "one")
+	}
+	if !strings.Contains(frame.Title, "notes.txt +") {
+		t.Errorf("frame title %q doesn't show the modified file", frame.Title)
+	}
+}
+
+func TestHandleEventShowsDiagnostics(t *testing.T) {
+	frontend := newFakeFrontend(t, "")
+	e, filename := newTestEditor(t, frontend, "package main")
+
+	// A language server publishes from its own goroutine
+	published := []protocol.Diagnostic{{
+		Range:    protocol.Range{Start: protocol.Position{Line: 0, Character: 8}},
+		Severity: protocol.DiagnosticSeverityError,
+		Message:  "expected main function",
+	}}
+	done := make(chan struct{})
+	go func() {
+		e.setDiagnostics(filename, published)
+		close(done)
+	}()
+	<-done
+
+	e.HandleEvent(ui.RedrawEvent{})
+	diags := e.buf.GetDiagnostics()
+	if len(diags) != 1 || diags[0].Column != 8 || diags[0].Message != "expected main function" {
+		t.Errorf("buffer diagnostics after the next event = %+v, want the published one", diags)
+	}
+}
+
+func TestProviderConfigs(t *testing.T) {
+	cfg := config.DefaultConfig()
+	own := 0.7
+	cfg.Providers = []ai.ProviderConfig{
+		{Type: ai.ProviderAnthropic, MaxTokens: 8192, Temperature: &own},
+		{Type: ai.ProviderOllama},
+	}
+	configs := providerConfigs(cfg)
+	if configs[0].MaxTokens != 8192 || *configs[0].Temperature != 0.7 {
+		t.Errorf("expected the provider's own settings kept, got %+v", configs[0])
+	}
+	if configs[1].MaxTokens != cfg.AI.MaxTokens || configs[1].Temperature == nil || *configs[1].Temperature != cfg.AI.Temperature {
+		t.Errorf("expected ai.max_tokens and ai.temperature, got %+v", configs[1])
+	}
+	if cfg.Providers[1].Temperature != nil {
+		t.Error("expected the config left as it was")
+	}
+}
+
+func TestSettingsWarnings(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Keymap.Leader = "ab"
+	cfg.Keymap.LeaderMappings = map[string]config.KeyMappingConfig{"w": {Command: "w"}}
+	cfg.Editor.Registers.Persist = true
+	cfg.Editor.Registers.File = filepath.Join(t.TempDir(), "registers.json")
+	os.WriteFile(cfg.Editor.Registers.File, []byte("not json"), 0644)
+
+	var warnings []string
+	if leader, _ := leaderMappings(cfg, &warnings); leader != 0 {
+		t.Errorf("leader %q, want none", leader)
+	}
+	initializeRegisters(cfg, &warnings)
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "leader \"ab\"") || !strings.HasPrefix(warnings[1], "Failed to load registers") {
+		t.Errorf("warnings = %q", warnings)
+	}
+}
+
+func TestCtrlCCancelsAIRequests(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	frontend := newFakeFrontend(t, "")
+	e, _ := newTestEditor(t, frontend, "x")
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	e.aiManager.RegisterProvider(provider)
+
+	keys, _ := ui.ParseKeys(":ai Why?<CR>")
+	for _, key := range keys {
+		e.HandleEvent(key)
+	}
+	if len(e.aiManager.InFlight()) != 1 {
+		t.Fatal(":ai isn't waiting for its answer in the background")
+	}
+
+	// Ctrl-C cancels the request instead of quitting, and the command
+	// then says so
+	e.HandleEvent(ui.KeyEvent{Key: tcell.KeyCtrlC, Action: ui.KeyActionQuit})
+	if !frontend.IsRunning() {
+		t.Fatal("Ctrl-C quit with an AI request under way")
+	}
+	deadline := time.Now().Add(time.Second)
+	for frontend.pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	e.HandleEvent(frontend.WaitForEvent())
+	if got := frontend.lastFrame().Message; got != "AI request canceled" {
+		t.Errorf("message after Ctrl-C = %q", got)
+	}
+}
@@ -0,0 +1,196 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/dshills/aied/internal/activity"
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/modes"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// Frame gathers what the frontend shows after an event
+func (e *Editor) Frame() *ui.Frame {
+	buf, modeManager := e.buf, e.modeManager
+	width, height := e.frontend.GetSize()
+	frame := &ui.Frame{
+		Buffer:     buf,
+		Status:     statusText(modeManager, e.aiManager),
+		Pending:    modeManager.Pending(),
+		Activity:   activity.Default.Status(time.Now()),
+		Panel:      listPanel(e.commands, buf, width, height),
+		Diff:       diffView(e.commands),
+		Palette:    modeManager.Palette(),
+		Composer:   modeManager.Composer(),
+		Suggestion: modeManager.Suggestion(),
+		KeyHints:   e.hinter.update(modeManager.PendingKeys()),
+		Ruler:      e.commands.Ruler(),
+	}
+	cfg := e.config.Get()
+	if cfg.Editor.Title {
+		frame.Title = ui.WindowTitle(buf.Filename(), buf.Modified())
+	}
+	if cfg.Editor.ReportDir {
+		// :cd changes it, so it is read for every frame
+		frame.Dir, _ = os.Getwd()
+	}
+	frame.CommandLine, frame.Message, frame.CommandMode = modeManager.GetCommandInfo()
+	frame.CommandCursor = modeManager.CommandCursor()
+	if !frame.CommandMode {
+		frame.Message = modeManager.Message()
+	}
+
+	// Completions in insert mode, and spelling suggestions while z= is active
+	switch mode := modeManager.CurrentMode().(type) {
+	case *modes.InsertMode:
+		frame.Completion = mode.Completion()
+	case *modes.NormalMode:
+		frame.Completion = mode.Suggestions()
+	}
+	return frame
+}
+
+// statusText returns the mode's status, with the number of AI requests
+// waiting for a rate limit if any
+func statusText(modeManager *modes.ModeManager, aiManager *ai.AIManager) string {
+	text := modeManager.GetStatusText()
+	if queued := aiManager.Queued(); queued > 0 {
+		text += fmt.Sprintf(" [AI queued: %d]", queued)
+	}
+	return text
+}
+
+// activitySpinner keeps the status line spinner turning while there is
+// work: each frame draws it when the main loop is free, and it is drawn on
+// its own while the main loop is busy handling an event
+type activitySpinner struct {
+	frontend ui.Frontend
+	handling atomic.Bool // Whether the main loop is handling an event
+}
+
+// run redraws the spinner every activity.Interval, and once more when the
+// work is done to clear it
+func (s *activitySpinner) run() {
+	ticker := time.NewTicker(activity.Interval)
+	defer ticker.Stop()
+	wasBusy := false
+	for range ticker.C {
+		busy := activity.Default.Busy()
+		switch {
+		case busy && s.handling.Load():
+			s.frontend.ShowActivity(activity.Default.Status(time.Now()))
+		case busy || wasBusy:
+			s.frontend.RequestRedraw()
+		}
+		wasBusy = busy
+	}
+}
+
+// keyHinter lists the keys that may complete a pending command once it has
+// been pending for the configured delay
+type keyHinter struct {
+	cfg     config.WhichKeyConfig
+	redraw  func() // Renders again when the delay has passed
+	pending string
+	since   time.Time
+}
+
+// update returns the hints for the pending keys, or nil while there are
+// none or they haven't waited long enough
+func (h *keyHinter) update(pending string, bindings []modes.KeyBinding) *ui.KeyHints {
+	if !h.cfg.Enabled || pending == "" {
+		h.pending = ""
+		return nil
+	}
+	delay := time.Duration(h.cfg.Delay) * time.Millisecond
+	if pending != h.pending {
+		h.pending, h.since = pending, time.Now()
+		if delay > 0 {
+			time.AfterFunc(delay, h.redraw)
+		}
+	}
+	if time.Since(h.since) < delay {
+		return nil
+	}
+	hints := &ui.KeyHints{Pending: pending}
+	for _, b := range bindings {
+		hints.Items = append(hints.Items, ui.KeyHint{Keys: b.Keys, Description: b.Description})
+	}
+	return hints
+}
+
+// diffView returns the buffer diff mode compares with, or nil when it is off
+func diffView(state *commands.State) *ui.DiffView {
+	other, title := state.DiffBuffer()
+	if other == nil {
+		return nil
+	}
+	return &ui.DiffView{Other: other, Title: title}
+}
+
+// listPanel returns the output of a running build or the open undo tree,
+// agent, AI explanation, merge view, quickfix or location list window
+// sized for a screen of width by height, or nil
+func listPanel(state *commands.State, buf *buffer.Buffer, width, height int) *ui.Panel {
+	if title, output, running := state.RunningBuild(); running {
+		// Show the end of the output as it streams in
+		return &ui.Panel{Title: "[Running] " + title, Lines: output, Selected: len(output) - 1}
+	}
+
+	if view := state.HelpView(); view != nil {
+		view.SetHeight(height - 3)
+		lines := view.Doc().Lines()
+		title := fmt.Sprintf("[Help] line %d of %d - q closes, Enter follows a |tag|", view.Line()+1, len(lines))
+		return &ui.Panel{Title: title, Lines: lines, Selected: view.Line(), Top: view.Top(), Height: height - 3}
+	}
+
+	if lines, open := state.PerfLines(); open {
+		return &ui.Panel{Title: "[Perf] :perf closes, :perf reset starts over", Lines: lines, Selected: -1}
+	}
+
+	if state.UndoTreeOpen() {
+		lines, current := commands.UndoTreeLines(buf, time.Now())
+		return &ui.Panel{Title: "[Undo Tree] :undo N restores a state", Lines: lines, Selected: current}
+	}
+
+	if title, lines, open := state.AgentPanel(buf, width); open {
+		return &ui.Panel{Title: title, Lines: lines, Selected: -1, Height: height / 2}
+	}
+
+	if title, lines, open := state.ReplacePanel(); open {
+		return &ui.Panel{Title: title, Lines: lines, Selected: -1, Height: height / 2}
+	}
+
+	if title, lines, open := state.ExplanationPanel(width); open {
+		return &ui.Panel{Title: title, Lines: lines, Selected: -1, Height: height / 2}
+	}
+
+	if state.MergeToolOpen() {
+		title, lines := commands.MergeToolLines(buf, width)
+		return &ui.Panel{Title: title, Lines: lines, Selected: -1}
+	}
+
+	stack, title := state.QuickfixLists(), "[Quickfix List]"
+	if !stack.IsOpen() {
+		stack, title = state.LocationLists(), "[Location List]"
+		if !stack.IsOpen() {
+			return nil
+		}
+	}
+
+	panel := &ui.Panel{Title: title, Selected: -1}
+	if list := stack.Current(); list != nil {
+		panel.Title += " " + list.Title
+		for _, item := range list.Items {
+			panel.Lines = append(panel.Lines, item.String())
+		}
+		panel.Selected = list.Index
+	}
+	return panel
+}
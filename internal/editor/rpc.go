@@ -0,0 +1,100 @@
+package editor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/remote"
+	"github.com/dshills/aied/internal/rpc"
+	"github.com/dshills/aied/internal/stdio"
+	"github.com/dshills/aied/internal/ui"
+)
+
+var _ rpc.Editor = (*Editor)(nil)
+
+// Serve lets other programs drive the editor over JSON-RPC on addr, and
+// the programs it runs find it through $AIED_LISTEN, with the token TCP
+// clients send in $AIED_LISTEN_TOKEN. A token already in $AIED_LISTEN_TOKEN
+// is the one clients must send. Requests run on the main loop.
+func (e *Editor) Serve(addr string) error {
+	server := rpc.NewServer(e, e.frontend.Call)
+	server.SetToken(os.Getenv("AIED_LISTEN_TOKEN"))
+	if err := server.Listen(addr); err != nil {
+		return err
+	}
+	e.rpcServer = server
+	os.Setenv("AIED_LISTEN", server.Addr())
+	if token := server.Token(); token != "" {
+		os.Setenv("AIED_LISTEN_TOKEN", token)
+	}
+	return nil
+}
+
+func (e *Editor) Open(filename string) error {
+	if e.buf.Modified() {
+		return fmt.Errorf("No write since last change (save before opening %s)", filename)
+	}
+	e.commands.KeepScratch(e.buf)
+	if err := e.buf.Load(filename); err != nil {
+		return err
+	}
+	ApplyBufferConfig(e.config.Get(), e.buf)
+	e.commands.ApplyModeline(e.buf)
+	if e.lspManager != nil && filename != stdio.Name && !remote.IsURL(filename) {
+		if err := e.lspManager.OpenFile(context.Background(), filename, lsp.GetBufferContent(e.buf)); err != nil {
+			return fmt.Errorf("opened, but not in the language server: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *Editor) Lines() []string {
+	return e.buf.Lines()
+}
+
+func (e *Editor) SetLines(lines []string) error {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	if err := e.buf.ReplaceLines(0, e.buf.LineCount()-1, lines); err != nil {
+		return err
+	}
+	e.buf.SetCursor(e.buf.Cursor())
+	e.buf.Commit()
+	return nil
+}
+
+func (e *Editor) Execute(command string) (string, error) {
+	result := e.modeManager.Execute(command, e.buf)
+	if result.ExitEditor {
+		e.frontend.PostQuit()
+	}
+	if !result.Success {
+		return "", errors.New(result.Message)
+	}
+	return result.Message, nil
+}
+
+func (e *Editor) Input(keys []ui.KeyEvent) {
+	for _, key := range keys {
+		if e.handleKey(key) {
+			e.frontend.PostQuit()
+			return
+		}
+	}
+}
+
+func (e *Editor) State() rpc.State {
+	cursor := e.buf.Cursor()
+	return rpc.State{
+		Filename:  e.buf.Filename(),
+		Line:      cursor.Line,
+		Column:    cursor.Col,
+		Mode:      e.modeManager.CurrentModeType().String(),
+		Modified:  e.buf.Modified(),
+		LineCount: e.buf.LineCount(),
+	}
+}
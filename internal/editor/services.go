@@ -0,0 +1,102 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/dshills/aied/internal/activity"
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/remote"
+	"github.com/dshills/aied/internal/stdio"
+)
+
+// ConfigureAI configures the AI providers and picks the default one,
+// returning the warnings to show. Local providers are asked whether they
+// run, so it runs in the background.
+func ConfigureAI(aiManager *ai.AIManager, cfg *config.Config) []string {
+	defer activity.Start("ai providers")()
+	var warnings []string
+	if err := aiManager.ConfigureProviders(providerConfigs(cfg)); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Failed to configure providers: %v", err))
+	}
+	if cfg.AI.DefaultProvider != "" {
+		if err := aiManager.SetActiveProvider(ai.ProviderType(cfg.AI.DefaultProvider)); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Failed to set default provider: %v", err))
+		}
+	}
+	return warnings
+}
+
+// providerConfigs returns the configured providers, with ai.max_tokens and
+// ai.temperature for those that don't set their own
+func providerConfigs(cfg *config.Config) []ai.ProviderConfig {
+	configs := slices.Clone(cfg.Providers)
+	for i := range configs {
+		if configs[i].MaxTokens == 0 {
+			configs[i].MaxTokens = cfg.AI.MaxTokens
+		}
+		if configs[i].Temperature == nil {
+			temperature := cfg.AI.Temperature
+			configs[i].Temperature = &temperature
+		}
+	}
+	return configs
+}
+
+// startLSP starts the language servers if configured to, all at once, and
+// returns the warnings to show
+func startLSP(lspManager *lsp.Manager, cfg *config.Config) []string {
+	if !cfg.LSP.AutoStart {
+		return nil
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		warnings []string
+	)
+	for _, srv := range cfg.LSP.Servers {
+		if !srv.Enabled {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer activity.Start("lsp start " + srv.Name)()
+			if err := lspManager.Start(context.Background(), srv.Name); err != nil {
+				mu.Lock()
+				warnings = append(warnings, fmt.Sprintf("Failed to start LSP server %s: %v", srv.Name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return warnings
+}
+
+// openInLSP opens buf in its language server if one has started. Language
+// servers only see local files.
+func openInLSP(lspManager *lsp.Manager, buf *buffer.Buffer) error {
+	filename := buf.Filename()
+	if filename == "" || filename == stdio.Name || remote.IsURL(filename) {
+		return nil
+	}
+	if _, err := lspManager.GetClient(filename); err != nil {
+		return nil
+	}
+	return lspManager.OpenFile(context.Background(), filename, lsp.GetBufferContent(buf))
+}
+
+// updateLSPBuffer sends the lines changed in the buffer to its LSP server
+func updateLSPBuffer(lspManager *lsp.Manager, buf *buffer.Buffer) {
+	if lspManager == nil || buf.Filename() == "" {
+		return
+	}
+
+	// Silently ignore LSP update errors for now
+	lspManager.SyncBuffer(context.Background(), buf)
+}
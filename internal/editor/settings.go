@@ -0,0 +1,260 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/modes"
+	"github.com/dshills/aied/internal/palette"
+	"github.com/dshills/aied/internal/recent"
+	"github.com/dshills/aied/internal/registers"
+	"github.com/dshills/aied/internal/search"
+	"github.com/dshills/aied/internal/spell"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// applyEditorConfig applies editor settings to the UI, the ex commands and
+// buffer
+func applyEditorConfig(cfg *config.Config, terminalUI ui.Frontend, state *commands.State, buf *buffer.Buffer) {
+	options := terminalUI.RenderOptions()
+	options.List = cfg.Editor.List
+	options.ListChars = ui.ListChars{
+		Tab:   firstRune(cfg.Editor.ListChars.Tab, options.ListChars.Tab),
+		Trail: firstRune(cfg.Editor.ListChars.Trail, options.ListChars.Trail),
+		Nbsp:  firstRune(cfg.Editor.ListChars.Nbsp, options.ListChars.Nbsp),
+	}
+	options.ColorColumns = cfg.Editor.ColorColumn
+	options.CursorLine = cfg.Editor.CursorLine
+	options.CursorColumn = cfg.Editor.CursorColumn
+	options.ScrollOff = cfg.Editor.ScrollOff
+	options.SideScrollOff = cfg.Editor.SideScrollOff
+	terminalUI.SetRenderOptions(options)
+	search.SetOptions(SearchOptions(cfg))
+	state.SetRuler(cfg.Editor.Ruler)
+	state.SetTimeoutLen(cfg.Editor.TimeoutLen)
+	state.SetPath(cfg.Editor.Path)
+	state.SetPreviewContext(cfg.AI.PreviewContext)
+	ApplyBufferConfig(cfg, buf)
+}
+
+// TerminalLight asks the terminal whether its background is light, when
+// the config leaves it to the terminal and the theme follows it. It must
+// be called before the UI takes the terminal.
+func TerminalLight(cfg *config.Config) bool {
+	// Only the default theme follows the background
+	background, theme := cfg.Editor.Background, cfg.Editor.Theme
+	if (background == "" || background == "auto") && (theme == "" || theme == "default") {
+		light, _ := ui.QueryBackground(200 * time.Millisecond)
+		return light
+	}
+	return false
+}
+
+// ThemeStyles returns the styles of the configured theme. terminalLight
+// is whether the terminal's background is light, for editor.background
+// "auto".
+func ThemeStyles(cfg *config.Config, terminalLight bool) (*ui.StyleConfig, error) {
+	theme := ui.Theme{Name: cfg.Editor.Theme, Transparent: cfg.Editor.Transparent, Colors: make(map[string]ui.ThemeColor)}
+	for name, color := range cfg.Editor.Colors {
+		theme.Colors[name] = ui.ThemeColor(color)
+	}
+
+	light := false
+	switch cfg.Editor.Background {
+	case "", "auto":
+		light = terminalLight
+	case "light":
+		light = true
+	case "dark":
+	default:
+		return nil, fmt.Errorf("editor.background %q isn't auto, dark or light", cfg.Editor.Background)
+	}
+	return theme.Styles(light)
+}
+
+// SearchOptions returns the configured search options. An unknown regexp
+// engine, which :checkhealth reports, falls back to Go's.
+func SearchOptions(cfg *config.Config) search.Options {
+	engine, err := search.ParseEngine(cfg.Editor.RegexpEngine)
+	if err != nil {
+		engine = search.EngineGo
+	}
+	return search.Options{
+		IgnoreCase: cfg.Editor.IgnoreCase,
+		SmartCase:  cfg.Editor.SmartCase,
+		HLSearch:   cfg.Editor.HLSearch,
+		Engine:     engine,
+	}
+}
+
+// ApplyBufferConfig applies the configured buffer options to buf
+func ApplyBufferConfig(cfg *config.Config, buf *buffer.Buffer) {
+	bufOptions := buf.Options()
+	bufOptions.TrimTrailingWhitespace = cfg.Editor.TrimTrailingWhitespace
+	bufOptions.TextWidth = cfg.Editor.TextWidth
+	bufOptions.TabStop = cfg.Editor.TabSize
+	bufOptions.IndentTabs = cfg.Editor.IndentStyle == "tabs"
+	bufOptions.Backup = buffer.BackupOptions{
+		Enabled: cfg.Editor.Backup.Enabled,
+		Dir:     cfg.Editor.Backup.Dir,
+		Keep:    cfg.Editor.Backup.Keep,
+	}
+	buf.SetOptions(bufOptions)
+}
+
+// leaderMappings converts the configured leader key and its mappings,
+// adding what is wrong with them to warnings
+func leaderMappings(cfg *config.Config, warnings *[]string) (rune, map[string]modes.LeaderMapping) {
+	leader := []rune(cfg.Keymap.Leader)
+	switch {
+	case strings.EqualFold(cfg.Keymap.Leader, "space"):
+		leader = []rune{' '}
+	case len(leader) != 1:
+		if len(cfg.Keymap.LeaderMappings) > 0 {
+			*warnings = append(*warnings, fmt.Sprintf("Ignoring leader mappings, leader %q isn't one key", cfg.Keymap.Leader))
+		}
+		return 0, nil
+	}
+	mappings := make(map[string]modes.LeaderMapping, len(cfg.Keymap.LeaderMappings))
+	for keys, m := range cfg.Keymap.LeaderMappings {
+		mappings[keys] = modes.LeaderMapping{Command: m.Command, Description: m.Description}
+	}
+	return leader[0], mappings
+}
+
+// commentStyles converts the configured comment strings for the gc operator
+func commentStyles(cfg *config.Config) map[string]modes.CommentStyle {
+	styles := make(map[string]modes.CommentStyle, len(cfg.Editor.Comments))
+	for filetype, comment := range cfg.Editor.Comments {
+		styles[filetype] = modes.CommentStyle{
+			Line:       comment.Line,
+			BlockStart: comment.BlockStart,
+			BlockEnd:   comment.BlockEnd,
+		}
+	}
+	return styles
+}
+
+// firstRune returns the first rune of s, or fallback if s is empty
+func firstRune(s string, fallback rune) rune {
+	for _, r := range s {
+		return r
+	}
+	return fallback
+}
+
+// initializeRegisters opens the persistent register file if enabled,
+// adding why it couldn't be read to warnings
+func initializeRegisters(cfg *config.Config, warnings *[]string) *registers.Store {
+	if !cfg.Editor.Registers.Persist {
+		return nil
+	}
+
+	path := cfg.Editor.Registers.File
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".config", "aied", "registers.json")
+	}
+
+	store, err := registers.Open(path, cfg.Editor.Registers.Shared)
+	if err != nil {
+		*warnings = append(*warnings, fmt.Sprintf("Failed to load registers: %v", err))
+	}
+	return store
+}
+
+// initializePalette opens the file remembering the commands run from the
+// command palette, adding why it couldn't be read to warnings
+func initializePalette(warnings *[]string) *palette.Frecency {
+	path := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		path = filepath.Join(home, ".config", "aied", "palette.json")
+	}
+	history, err := palette.OpenFrecency(path)
+	if err != nil {
+		*warnings = append(*warnings, fmt.Sprintf("Failed to load palette history: %v", err))
+	}
+	return history
+}
+
+// initializeRecent opens the file remembering the files opened lately,
+// adding why it couldn't be read to warnings
+func initializeRecent(warnings *[]string) *recent.Files {
+	path := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		path = filepath.Join(home, ".config", "aied", "recent.json")
+	}
+	files, err := recent.Open(path)
+	if err != nil {
+		*warnings = append(*warnings, fmt.Sprintf("Failed to load recent files: %v", err))
+	}
+	return files
+}
+
+// initializeSpell loads the spell checker if spell checking is enabled,
+// adding why a dictionary couldn't be read to warnings
+func initializeSpell(cfg *config.Config, warnings *[]string) *spell.Checker {
+	if !cfg.Editor.Spell.Enabled {
+		return nil
+	}
+
+	userDict := cfg.Editor.Spell.UserDictionary
+	if userDict == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			userDict = filepath.Join(home, ".config", "aied", "spell", "user.add")
+		}
+	}
+
+	checker, err := spell.Load(cfg.Editor.Spell.Dictionaries, userDict)
+	if err != nil {
+		*warnings = append(*warnings, fmt.Sprintf("Failed to load spell dictionary: %v", err))
+	}
+	checker.SetFiletypes(cfg.Editor.Spell.Filetypes)
+
+	return checker
+}
+
+// searchHighlighter highlights the matches of the last search while
+// hlsearch is on, only those in the visual selection for \%V
+func searchHighlighter(buf *buffer.Buffer) ui.Highlighter {
+	return func(lineNum int, line string) []ui.Highlight {
+		re := search.Highlighted()
+		if re == nil {
+			return nil
+		}
+		matches := re.FindAllStringIndex(line, -1)
+		if pattern, _ := search.Last(); search.VisualOnly(pattern) {
+			matches = search.VisualMatches(buf, lineNum, matches)
+		}
+		var highlights []ui.Highlight
+		for _, m := range matches {
+			highlights = append(highlights, ui.Highlight{Start: m[0], End: m[1], Kind: ui.HighlightSearch})
+		}
+		return highlights
+	}
+}
+
+// spellHighlighter highlights misspelled words in spell-enabled buffers
+func spellHighlighter(checker *spell.Checker, buf *buffer.Buffer) ui.Highlighter {
+	return func(lineNum int, line string) []ui.Highlight {
+		filetype := buf.Filetype()
+		if !checker.EnabledFor(filetype) {
+			return nil
+		}
+
+		var highlights []ui.Highlight
+		for _, m := range checker.CheckLine(line, filetype) {
+			highlights = append(highlights, ui.Highlight{Start: m.Start, End: m.End, Kind: ui.HighlightSpellBad})
+		}
+		return highlights
+	}
+}
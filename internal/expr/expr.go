@@ -0,0 +1,324 @@
+// Package expr evaluates the small expressions of := and the expression
+// register: arithmetic on numbers, strings joined with .., environment
+// variables as $NAME and a few functions like toupper() and sqrt()
+package expr
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// value is a number or a string
+type value struct {
+	num      float64
+	str      string
+	isString bool
+}
+
+func number(n float64) value { return value{num: n} }
+func text(s string) value    { return value{str: s, isString: true} }
+
+// String formats v, whole numbers without a fraction
+func (v value) String() string {
+	if v.isString {
+		return v.str
+	}
+	return strconv.FormatFloat(v.num, 'f', -1, 64)
+}
+
+// number returns v as a number, converting a string that holds one
+func (v value) number() (float64, error) {
+	if !v.isString {
+		return v.num, nil
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a number: %q", v.str)
+	}
+	return n, nil
+}
+
+// Eval evaluates src and returns its value as text
+func Eval(src string) (string, error) {
+	p := &parser{src: src}
+	v, err := p.concat()
+	if err != nil {
+		return "", err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return "", fmt.Errorf("unexpected %q", p.src[p.pos:])
+	}
+	return v.String(), nil
+}
+
+// parser evaluates an expression while reading it, lowest precedence
+// first: .. then + and - then *, / and %
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// accept skips op and the space before it if it comes next
+func (p *parser) accept(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *parser) concat() (value, error) {
+	left, err := p.additive()
+	for err == nil && p.accept("..") {
+		var right value
+		if right, err = p.additive(); err == nil {
+			left = text(left.String() + right.String())
+		}
+	}
+	return left, err
+}
+
+func (p *parser) additive() (value, error) {
+	left, err := p.term()
+	for err == nil {
+		var op byte
+		switch {
+		case p.accept("+"):
+			op = '+'
+		case p.accept("-"):
+			op = '-'
+		default:
+			return left, nil
+		}
+		var right value
+		if right, err = p.term(); err == nil {
+			left, err = arithmetic(op, left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *parser) term() (value, error) {
+	left, err := p.unary()
+	for err == nil {
+		var op byte
+		switch {
+		case p.accept("*"):
+			op = '*'
+		case p.accept("/"):
+			op = '/'
+		case p.accept("%"):
+			op = '%'
+		default:
+			return left, nil
+		}
+		var right value
+		if right, err = p.unary(); err == nil {
+			left, err = arithmetic(op, left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *parser) unary() (value, error) {
+	switch {
+	case p.accept("-"):
+		v, err := p.unary()
+		if err != nil {
+			return v, err
+		}
+		n, err := v.number()
+		return number(-n), err
+	case p.accept("+"):
+		v, err := p.unary()
+		if err != nil {
+			return v, err
+		}
+		n, err := v.number()
+		return number(n), err
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (value, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return value{}, fmt.Errorf("expression expected")
+	}
+	switch ch := p.src[p.pos]; {
+	case ch == '(':
+		p.pos++
+		v, err := p.concat()
+		if err == nil && !p.accept(")") {
+			err = fmt.Errorf("missing )")
+		}
+		return v, err
+	case ch == '"' || ch == '\'':
+		return p.quoted(ch)
+	case ch == '$':
+		p.pos++
+		return text(os.Getenv(p.name())), nil
+	case ch == '.' || ch >= '0' && ch <= '9':
+		return p.number()
+	case ch == '_' || unicode.IsLetter(rune(ch)):
+		return p.call(p.name())
+	}
+	return value{}, fmt.Errorf("unexpected %q", p.src[p.pos:])
+}
+
+// name reads a name of letters, digits and underscores
+func (p *parser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if ch != '_' && !unicode.IsLetter(rune(ch)) && !unicode.IsDigit(rune(ch)) {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// number reads a decimal number, with an optional fraction and exponent,
+// or a hexadecimal one starting 0x
+func (p *parser) number() (value, error) {
+	start := p.pos
+	if strings.HasPrefix(p.src[p.pos:], "0x") || strings.HasPrefix(p.src[p.pos:], "0X") {
+		p.pos += 2
+		for p.pos < len(p.src) && strings.IndexByte("0123456789abcdefABCDEF", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, err := strconv.ParseUint(p.src[start+2:p.pos], 16, 64)
+		if err != nil {
+			return value{}, fmt.Errorf("bad number %q", p.src[start:p.pos])
+		}
+		return number(float64(n)), nil
+	}
+
+	digits := func() {
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	digits()
+	// A fraction, but not the .. that joins strings
+	if p.pos < len(p.src) && p.src[p.pos] == '.' && !strings.HasPrefix(p.src[p.pos:], "..") {
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		return value{}, fmt.Errorf("bad number %q", p.src[start:p.pos])
+	}
+	return number(n), nil
+}
+
+// quoted reads a string. Double quotes take backslash escapes like \n and
+// \"; single quotes take the text as it is, with ” for a quote, as in Vim.
+func (p *parser) quoted(quote byte) (value, error) {
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		p.pos++
+		switch {
+		case ch == quote && quote == '\'' && p.pos < len(p.src) && p.src[p.pos] == '\'':
+			sb.WriteByte('\'')
+			p.pos++
+		case ch == quote:
+			return text(sb.String()), nil
+		case ch == '\\' && quote == '"' && p.pos < len(p.src):
+			esc := p.src[p.pos]
+			p.pos++
+			switch esc {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(esc)
+			}
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return value{}, fmt.Errorf("missing closing %c", quote)
+}
+
+// call reads the arguments of the function called name and calls it
+func (p *parser) call(name string) (value, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return value{}, fmt.Errorf("unknown function: %s", name)
+	}
+	if !p.accept("(") {
+		return value{}, fmt.Errorf("missing ( after %s", name)
+	}
+	var args []value
+	if !p.accept(")") {
+		for {
+			arg, err := p.concat()
+			if err != nil {
+				return value{}, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(",") {
+				return value{}, fmt.Errorf("missing ) after arguments of %s", name)
+			}
+		}
+	}
+	if len(args) < fn.min || fn.max >= 0 && len(args) > fn.max {
+		return value{}, fmt.Errorf("wrong number of arguments for %s", name)
+	}
+	return fn.call(args)
+}
+
+// arithmetic applies the operator op to two numbers
+func arithmetic(op byte, left, right value) (value, error) {
+	a, err := left.number()
+	if err != nil {
+		return value{}, err
+	}
+	b, err := right.number()
+	if err != nil {
+		return value{}, err
+	}
+	switch op {
+	case '+':
+		return number(a + b), nil
+	case '-':
+		return number(a - b), nil
+	case '*':
+		return number(a * b), nil
+	}
+	if b == 0 {
+		return value{}, fmt.Errorf("division by zero")
+	}
+	if op == '%' {
+		return number(math.Mod(a, b)), nil
+	}
+	return number(a / b), nil
+}
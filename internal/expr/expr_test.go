@@ -0,0 +1,40 @@
+package expr
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	t.Setenv("AIED_EXPR_TEST", "world")
+	tests := []struct {
+		src, want string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"7 / 2", "3.5"},
+		{"-7 % 3", "-1"},
+		{"0x1f + 1", "32"},
+		{"1.5e2", "150"},
+		{"'it''s' .. \" \\\"ok\\\"\"", `it's "ok"`},
+		{"'hello ' .. $AIED_EXPR_TEST", "hello world"},
+		{"1 .. 2 + 3", "15"},
+		{"toupper(getenv('AIED_EXPR_TEST'))", "WORLD"},
+		{"len('héllo') * 2", "10"},
+		{"repeat('ab', 3)", "ababab"},
+		{"replace(trim('  a-b '), '-', '+')", "a+b"},
+		{"sqrt(16) + pow(2, 10)", "1028"},
+		{"max(3, '8', 5) - min(4, 2)", "6"},
+		{"round(2.5) .. floor(-1.5) .. ceil(1.2) .. abs(-3)", "3-223"},
+		{"'4' * 2", "8"},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.src)
+		if err != nil || got != tt.want {
+			t.Errorf("Eval(%q) = %q, %v; want %q", tt.src, got, err, tt.want)
+		}
+	}
+
+	for _, src := range []string{"", "1 +", "(1", "1 / 0", "'a' * 2", "nope(1)", "sqrt(1, 2)", "'open", "1 2"} {
+		if got, err := Eval(src); err == nil {
+			t.Errorf("Eval(%q) = %q, want an error", src, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package expr
+
+import (
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// function is a function expressions can call, taking from min to max
+// arguments, or any number from min when max is -1
+type function struct {
+	min, max int
+	call     func(args []value) (value, error)
+}
+
+// functions are the functions expressions can call, by name
+var functions = map[string]function{
+	"len": {1, 1, func(args []value) (value, error) {
+		return number(float64(utf8.RuneCountInString(args[0].String()))), nil
+	}},
+	"toupper": {1, 1, func(args []value) (value, error) {
+		return text(strings.ToUpper(args[0].String())), nil
+	}},
+	"tolower": {1, 1, func(args []value) (value, error) {
+		return text(strings.ToLower(args[0].String())), nil
+	}},
+	"trim": {1, 1, func(args []value) (value, error) {
+		return text(strings.TrimSpace(args[0].String())), nil
+	}},
+	"repeat": {2, 2, func(args []value) (value, error) {
+		n, err := args[1].number()
+		if err != nil {
+			return value{}, err
+		}
+		return text(strings.Repeat(args[0].String(), max(int(n), 0))), nil
+	}},
+	"replace": {3, 3, func(args []value) (value, error) {
+		return text(strings.ReplaceAll(args[0].String(), args[1].String(), args[2].String())), nil
+	}},
+	"getenv": {1, 1, func(args []value) (value, error) {
+		return text(os.Getenv(args[0].String())), nil
+	}},
+	"abs":   numeric(math.Abs),
+	"round": numeric(math.Round),
+	"floor": numeric(math.Floor),
+	"ceil":  numeric(math.Ceil),
+	"sqrt":  numeric(math.Sqrt),
+	"pow": {2, 2, func(args []value) (value, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return value{}, err
+		}
+		return number(math.Pow(nums[0], nums[1])), nil
+	}},
+	"min": {1, -1, func(args []value) (value, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return value{}, err
+		}
+		return number(fold(nums, math.Min)), nil
+	}},
+	"max": {1, -1, func(args []value) (value, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return value{}, err
+		}
+		return number(fold(nums, math.Max)), nil
+	}},
+}
+
+// numeric makes a function of one number from f
+func numeric(f func(float64) float64) function {
+	return function{1, 1, func(args []value) (value, error) {
+		n, err := args[0].number()
+		return number(f(n)), err
+	}}
+}
+
+// numbers returns args as numbers
+func numbers(args []value) ([]float64, error) {
+	nums := make([]float64, len(args))
+	for i, arg := range args {
+		n, err := arg.number()
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// fold folds nums with pick, math.Min or math.Max
+func fold(nums []float64, pick func(a, b float64) float64) float64 {
+	result := nums[0]
+	for _, n := range nums[1:] {
+		result = pick(result, n)
+	}
+	return result
+}
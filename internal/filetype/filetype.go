@@ -0,0 +1,229 @@
+package filetype
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dshills/aied/internal/modeline"
+)
+
+// Text is the filetype of plain text and files without a known type
+const Text = "text"
+
+// extensions maps lower-case file extensions to filetypes
+var extensions = map[string]string{
+	"go":         "go",
+	"py":         "python",
+	"pyw":        "python",
+	"js":         "javascript",
+	"jsx":        "javascript",
+	"mjs":        "javascript",
+	"cjs":        "javascript",
+	"ts":         "typescript",
+	"tsx":        "typescript",
+	"mts":        "typescript",
+	"rs":         "rust",
+	"rb":         "ruby",
+	"sh":         "bash",
+	"bash":       "bash",
+	"zsh":        "bash",
+	"c":          "c",
+	"h":          "c",
+	"cc":         "cpp",
+	"cpp":        "cpp",
+	"cxx":        "cpp",
+	"c++":        "cpp",
+	"hpp":        "cpp",
+	"hxx":        "cpp",
+	"h++":        "cpp",
+	"java":       "java",
+	"php":        "php",
+	"cs":         "csharp",
+	"swift":      "swift",
+	"kt":         "kotlin",
+	"kts":        "kotlin",
+	"scala":      "scala",
+	"clj":        "clojure",
+	"ex":         "elixir",
+	"exs":        "elixir",
+	"erl":        "erlang",
+	"hrl":        "erlang",
+	"hs":         "haskell",
+	"ml":         "ocaml",
+	"mli":        "ocaml",
+	"vim":        "vim",
+	"lua":        "lua",
+	"yaml":       "yaml",
+	"yml":        "yaml",
+	"toml":       "toml",
+	"json":       "json",
+	"xml":        "xml",
+	"html":       "html",
+	"htm":        "html",
+	"css":        "css",
+	"scss":       "scss",
+	"less":       "less",
+	"sql":        "sql",
+	"md":         "markdown",
+	"markdown":   "markdown",
+	"rst":        "rst",
+	"txt":        Text,
+	"tex":        "latex",
+	"r":          "r",
+	"pl":         "perl",
+	"pm":         "perl",
+	"mk":         "make",
+	"cmake":      "cmake",
+	"proto":      "proto",
+	"tf":         "terraform",
+	"dockerfile": "dockerfile",
+	"jl":         "julia",
+	"nim":        "nim",
+	"zig":        "zig",
+	"dart":       "dart",
+	"ini":        "ini",
+	"m":          "matlab",
+}
+
+// filenames maps exact base names to filetypes
+var filenames = map[string]string{
+	"Makefile":       "make",
+	"makefile":       "make",
+	"GNUmakefile":    "make",
+	"Dockerfile":     "dockerfile",
+	"Containerfile":  "dockerfile",
+	"CMakeLists.txt": "cmake",
+	"go.mod":         "gomod",
+	"go.sum":         "gosum",
+	"Gemfile":        "ruby",
+	"Rakefile":       "ruby",
+	"Jenkinsfile":    "groovy",
+	"COMMIT_EDITMSG": "gitcommit",
+	"MERGE_MSG":      "gitcommit",
+	".bashrc":        "bash",
+	".bash_profile":  "bash",
+	".zshrc":         "bash",
+	".profile":       "bash",
+	".vimrc":         "vim",
+}
+
+// interpreters maps shebang interpreters to filetypes
+var interpreters = map[string]string{
+	"sh":      "bash",
+	"bash":    "bash",
+	"zsh":     "bash",
+	"dash":    "bash",
+	"ksh":     "bash",
+	"python":  "python",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"lua":     "lua",
+	"make":    "make",
+}
+
+// languageIDs maps filetypes to LSP language identifiers where they differ
+var languageIDs = map[string]string{
+	"bash": "shellscript",
+	"make": "makefile",
+	Text:   "plaintext",
+}
+
+// validName matches the filetype names a modeline may set
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ForFile returns the filetype of a file from its name alone: its exact
+// base name, then its extension. Unknown extensions are returned as is and
+// files without an extension are Text.
+func ForFile(filename string) string {
+	base := filepath.Base(filename)
+	if ft, ok := filenames[base]; ok {
+		return ft
+	}
+	if strings.HasPrefix(base, "Dockerfile.") || strings.HasPrefix(base, "Containerfile.") {
+		return "dockerfile"
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(base), "."))
+	if ext == "" {
+		return Text
+	}
+	if ft, ok := extensions[ext]; ok {
+		return ft
+	}
+	return ext
+}
+
+// Detect returns the filetype of a file from its name and content. A
+// modeline setting the filetype wins, then the file name, then for files
+// without a known type a #! interpreter line.
+func Detect(filename string, lines []string) string {
+	if ft := FromModeline(lines); ft != "" {
+		return ft
+	}
+
+	ft := ForFile(filename)
+	if ft == Text && len(lines) > 0 {
+		if shebang := FromShebang(lines[0]); shebang != "" {
+			return shebang
+		}
+	}
+	return ft
+}
+
+// FromShebang returns the filetype named by a #! interpreter line, or ""
+func FromShebang(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env options like -S
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = field
+				break
+			}
+		}
+	}
+	// python3, python3.12, ruby2.7, ...
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	return interpreters[interpreter]
+}
+
+// FromModeline returns the filetype set by a modeline in the first or last
+// lines of a file, e.g. "vim: set ft=python:", or ""
+func FromModeline(lines []string) string {
+	ft := ""
+	for _, setting := range modeline.Find(lines, modeline.DefaultLines) {
+		name, value, ok := strings.Cut(setting, "=")
+		if ok && (name == "ft" || name == "filetype") && validName.MatchString(value) {
+			ft = value
+		}
+	}
+	return ft
+}
+
+// LanguageID returns the LSP language identifier for a file of filetype ft
+func LanguageID(ft, filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsx":
+		return "javascriptreact"
+	case ".tsx":
+		return "typescriptreact"
+	}
+	if id, ok := languageIDs[ft]; ok {
+		return id
+	}
+	return ft
+}
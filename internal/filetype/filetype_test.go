@@ -0,0 +1,113 @@
+package filetype
+
+import "testing"
+
+func TestForFile(t *testing.T) {
+	tests := map[string]string{
+		"main.go":              "go",
+		"src/App.TSX":          "typescript",
+		"lib/util.jsx":         "javascript",
+		"README.md":            "markdown",
+		"notes":                Text,
+		"notes.txt":            Text,
+		"/repo/Makefile":       "make",
+		"Dockerfile":           "dockerfile",
+		"Dockerfile.dev":       "dockerfile",
+		"CMakeLists.txt":       "cmake",
+		"go.mod":               "gomod",
+		".git/COMMIT_EDITMSG":  "gitcommit",
+		"script.sh":            "bash",
+		"data.unknownext":      "unknownext",
+		"ssh://host/x/main.rs": "rust",
+	}
+	for filename, want := range tests {
+		if got := ForFile(filename); got != want {
+			t.Errorf("ForFile(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
+
+func TestFromShebang(t *testing.T) {
+	tests := map[string]string{
+		"#!/bin/sh":                      "bash",
+		"#!/usr/bin/env python3":         "python",
+		"#!/usr/bin/env -S deno run":     "typescript",
+		"#!/usr/local/bin/python3.12 -u": "python",
+		"#!/usr/bin/env node":            "javascript",
+		"#!/usr/bin/unknown":             "",
+		"# not a shebang":                "",
+		"#!":                             "",
+	}
+	for line, want := range tests {
+		if got := FromShebang(line); got != want {
+			t.Errorf("FromShebang(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestFromModeline(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{"# vim: set ft=python :"}, "python"},
+		{[]string{"// vim: ts=4 filetype=go"}, "go"},
+		{[]string{"text", "", "/* vi: set sw=2 ft=c: */"}, "c"},
+		{[]string{"no modeline here"}, ""},
+		{[]string{"ft=go without a vim: prefix"}, ""},
+		{[]string{"vimft=go"}, ""},
+	}
+	for _, tt := range tests {
+		if got := FromModeline(tt.lines); got != tt.want {
+			t.Errorf("FromModeline(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+
+	// Only the first and last lines are searched
+	lines := make([]string, 20)
+	lines[10] = "vim: ft=lua"
+	if got := FromModeline(lines); got != "" {
+		t.Errorf("modeline in the middle of a file detected as %q", got)
+	}
+	lines[18] = "vim: ft=lua"
+	if got := FromModeline(lines); got != "lua" {
+		t.Errorf("modeline at the end of a file detected as %q", got)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		filename string
+		lines    []string
+		want     string
+	}{
+		{"deploy", []string{"#!/bin/bash", "echo hi"}, "bash"},
+		{"tool.py", []string{"#!/bin/sh"}, "python"},
+		{"config", []string{"# vim: ft=yaml"}, "yaml"},
+		{"main.c", []string{"// vim: ft=cpp"}, "cpp"},
+		{"", []string{""}, Text},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.filename, tt.lines); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %q, want %q", tt.filename, tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageID(t *testing.T) {
+	tests := []struct {
+		ft, filename, want string
+	}{
+		{"go", "main.go", "go"},
+		{"bash", "run.sh", "shellscript"},
+		{"typescript", "App.tsx", "typescriptreact"},
+		{"javascript", "App.jsx", "javascriptreact"},
+		{Text, "notes", "plaintext"},
+		{"make", "Makefile", "makefile"},
+	}
+	for _, tt := range tests {
+		if got := LanguageID(tt.ft, tt.filename); got != tt.want {
+			t.Errorf("LanguageID(%q, %q) = %q, want %q", tt.ft, tt.filename, got, tt.want)
+		}
+	}
+}
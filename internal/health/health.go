@@ -0,0 +1,312 @@
+// Package health checks the editor's setup for :checkhealth: the config,
+// language servers, AI providers, terminal and clipboard.
+package health
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/search"
+	"github.com/dshills/aied/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	}
+	return "OK"
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status  Status
+	Message string
+}
+
+// Section is a group of checks
+type Section struct {
+	Title   string
+	Results []Result
+}
+
+func (s *Section) add(status Status, format string, args ...any) {
+	s.Results = append(s.Results, Result{Status: status, Message: fmt.Sprintf(format, args...)})
+}
+
+// Replaced in tests
+var (
+	lookPath = exec.LookPath
+	getenv   = os.Getenv
+	goos     = runtime.GOOS
+)
+
+// Report formats sections as text, starting with a count of the outcomes
+func Report(sections []Section) string {
+	var counts [Fail + 1]int
+	var body strings.Builder
+	for _, s := range sections {
+		fmt.Fprintf(&body, "\n%s\n", s.Title)
+		for _, r := range s.Results {
+			counts[r.Status]++
+			fmt.Fprintf(&body, "  [%s] %s\n", r.Status, r.Message)
+		}
+	}
+	return fmt.Sprintf("%d OK, %d warnings, %d failures\n", counts[Pass], counts[Warn], counts[Fail]) + body.String()
+}
+
+// ConfigFile checks the first of paths that exists, the one loaded, for
+// errors and options it doesn't know
+func ConfigFile(paths []string) Section {
+	s := Section{Title: "Configuration"}
+	i := slices.IndexFunc(paths, func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+	if i < 0 {
+		s.add(Warn, "No config file found, using the defaults; :configgen writes an example")
+		return s
+	}
+	path := paths[i]
+
+	if _, err := config.LoadFromFileOnly(path); err != nil {
+		s.add(Fail, "%s: %v", path, err)
+		return s
+	}
+	if err := knownFields(path); err != nil {
+		s.add(Warn, "%s: %v", path, err)
+		return s
+	}
+	s.add(Pass, "%s loaded", path)
+	return s
+}
+
+// knownFields decodes the YAML config at path strictly, failing on options
+// the config doesn't have
+func knownFields(path string) error {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg config.Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Settings checks the loaded config for values the editor can't use
+func Settings(cfg *config.Config) Section {
+	s := Section{Title: "Settings"}
+	enabled := make(map[ai.ProviderType]bool)
+	for _, p := range cfg.Providers {
+		if !p.Type.Valid() {
+			s.add(Fail, "Unknown provider type %q", p.Type)
+		}
+		if p.Enabled {
+			enabled[p.Type] = true
+		}
+	}
+
+	if name := cfg.AI.DefaultProvider; name != "" {
+		switch {
+		case !ai.ProviderType(name).Valid():
+			s.add(Fail, "ai.default_provider: unknown provider %q", name)
+		case !enabled[ai.ProviderType(name)]:
+			s.add(Warn, "ai.default_provider: %s isn't enabled", name)
+		}
+	}
+	for _, name := range cfg.AI.FallbackOrder {
+		if !ai.ProviderType(name).Valid() {
+			s.add(Fail, "ai.fallback_order: unknown provider %q", name)
+		}
+	}
+	requestTypes := []ai.RequestType{
+		ai.RequestCompletion, ai.RequestExplanation, ai.RequestRefactor, ai.RequestDebug,
+		ai.RequestDocumentation, ai.RequestChat, ai.RequestEdit,
+	}
+	for name, route := range cfg.AI.Routes {
+		if !slices.Contains(requestTypes, ai.RequestType(name)) {
+			s.add(Warn, "ai.routes.%s: unknown request type", name)
+		}
+		if !ai.ProviderType(route.Provider).Valid() {
+			s.add(Fail, "ai.routes.%s: unknown provider %q", name, route.Provider)
+		} else if !enabled[ai.ProviderType(route.Provider)] {
+			s.add(Warn, "ai.routes.%s: %s isn't enabled", name, route.Provider)
+		}
+	}
+
+	if cfg.Editor.TabSize <= 0 {
+		s.add(Fail, "editor.tab_size: %d isn't positive", cfg.Editor.TabSize)
+	}
+	if t := cfg.LSP.CompletionTrigger; t != "" && t != "auto" && t != "manual" {
+		s.add(Fail, "lsp.completion_trigger: %q isn't auto or manual", t)
+	}
+	if _, err := search.ParseEngine(cfg.Editor.RegexpEngine); err != nil {
+		s.add(Fail, "editor.regexpengine: %v", err)
+	}
+	if b := cfg.Editor.Background; b != "" && b != "auto" && b != "dark" && b != "light" {
+		s.add(Fail, "editor.background: %q isn't auto, dark or light", b)
+	}
+	theme := ui.Theme{Name: cfg.Editor.Theme, Colors: make(map[string]ui.ThemeColor)}
+	for name, color := range cfg.Editor.Colors {
+		theme.Colors[name] = ui.ThemeColor(color)
+	}
+	if _, err := theme.Styles(false); err != nil {
+		s.add(Fail, "editor.theme: %v", err)
+	}
+
+	if len(s.Results) == 0 {
+		s.add(Pass, "No problems found")
+	}
+	return s
+}
+
+// LanguageServers checks that the enabled language servers are on the PATH
+func LanguageServers(cfg config.LSPConfig) Section {
+	s := Section{Title: "Language servers"}
+	if !cfg.Enabled {
+		s.add(Warn, "Disabled by lsp.enabled")
+		return s
+	}
+	for _, srv := range cfg.Servers {
+		if !srv.Enabled {
+			continue
+		}
+		path, err := lookPath(srv.Command)
+		if err != nil {
+			s.add(Fail, "%s: %s not found on the PATH", srv.Name, srv.Command)
+			continue
+		}
+		s.add(Pass, "%s: %s", srv.Name, path)
+	}
+	if len(s.Results) == 0 {
+		s.add(Warn, "No language servers enabled")
+	}
+	return s
+}
+
+// Providers reports the outcome of pinging each configured AI provider,
+// as returned by AIManager.Ping
+func Providers(results map[ai.ProviderType]error, active ai.ProviderType) Section {
+	s := Section{Title: "AI providers"}
+	names := make([]ai.ProviderType, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		label := string(name)
+		if name == active {
+			label += " (active)"
+		}
+		if err := results[name]; err != nil {
+			reason := err.Error()
+			var perr *ai.ProviderError
+			if errors.As(err, &perr) {
+				if reason = perr.Hint(); reason == "" {
+					reason = perr.Err.Error()
+				}
+			}
+			s.add(Fail, "%s: %s", label, reason)
+			continue
+		}
+		s.add(Pass, "%s: reachable", label)
+	}
+	if len(s.Results) == 0 {
+		s.add(Warn, "No providers configured; set an API key or run Ollama")
+	}
+	return s
+}
+
+// Terminal checks the terminal for true color and bracketed paste, as far
+// as its environment tells
+func Terminal() Section {
+	s := Section{Title: "Terminal"}
+	term := getenv("TERM")
+	switch term {
+	case "":
+		s.add(Fail, "TERM isn't set")
+		return s
+	case "dumb":
+		s.add(Fail, "TERM=dumb can't run a full screen editor")
+		return s
+	}
+	s.add(Pass, "TERM=%s", term)
+
+	switch colorterm := getenv("COLORTERM"); {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		s.add(Pass, "True color (COLORTERM=%s)", colorterm)
+	case strings.Contains(term, "256color"):
+		s.add(Warn, "256 colors only; set COLORTERM=truecolor if the terminal supports more")
+	default:
+		s.add(Warn, "Few colors; use a TERM ending in -256color or set COLORTERM=truecolor")
+	}
+
+	pasteTerms := []string{"xterm", "screen", "tmux", "alacritty", "kitty", "wezterm", "foot", "rxvt", "st", "vte", "ghostty"}
+	family, _, _ := strings.Cut(term, "-")
+	if slices.Contains(pasteTerms, family) {
+		s.add(Pass, "Bracketed paste supported by %s", family)
+	} else {
+		s.add(Warn, "Bracketed paste may be unsupported by %s; pasted text is typed as keys", term)
+	}
+	if getenv("TMUX") != "" && getenv("COLORTERM") == "" {
+		s.add(Warn, "Inside tmux; enable its RGB feature and set COLORTERM for true color")
+	}
+	return s
+}
+
+// Clipboard checks for a tool to reach the system clipboard
+func Clipboard() Section {
+	s := Section{Title: "Clipboard"}
+	var tools []string
+	switch {
+	case goos == "darwin":
+		tools = []string{"pbcopy"}
+	case goos == "windows":
+		tools = []string{"clip.exe"}
+	case getenv("WAYLAND_DISPLAY") != "":
+		tools = []string{"wl-copy", "xclip", "xsel"}
+	case getenv("DISPLAY") != "":
+		tools = []string{"xclip", "xsel"}
+	default:
+		// WSL and remote sessions without a display
+		tools = []string{"clip.exe"}
+	}
+	for _, tool := range tools {
+		if path, err := lookPath(tool); err == nil {
+			s.add(Pass, "%s", path)
+			return s
+		}
+	}
+	s.add(Warn, "None of %s found; the clipboard can't be reached", strings.Join(tools, ", "))
+	return s
+}
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/config"
+)
+
+// fakeEnv replaces the environment and PATH seen by the checks
+func fakeEnv(t *testing.T, env map[string]string, path ...string) {
+	t.Helper()
+	oldLookPath, oldGetenv, oldGOOS := lookPath, getenv, goos
+	t.Cleanup(func() { lookPath, getenv, goos = oldLookPath, oldGetenv, oldGOOS })
+
+	getenv = func(key string) string { return env[key] }
+	lookPath = func(file string) (string, error) {
+		for _, p := range path {
+			if p == file {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	goos = "linux"
+}
+
+// statuses returns the status of each result of s
+func statuses(s Section) []Status {
+	var out []Status
+	for _, r := range s.Results {
+		out = append(out, r.Status)
+	}
+	return out
+}
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.yaml")
+	if s := ConfigFile([]string{missing}); s.Results[0].Status != Warn {
+		t.Errorf("no config file: %+v", s.Results)
+	}
+
+	good := filepath.Join(dir, "good.yaml")
+	os.WriteFile(good, []byte("editor:\n  tab_size: 2\n"), 0644)
+	if s := ConfigFile([]string{missing, good}); s.Results[0].Status != Pass {
+		t.Errorf("good config: %+v", s.Results)
+	}
+
+	typo := filepath.Join(dir, "typo.yaml")
+	os.WriteFile(typo, []byte("editor:\n  tabsize: 2\n"), 0644)
+	if s := ConfigFile([]string{typo}); s.Results[0].Status != Warn || !strings.Contains(s.Results[0].Message, "tabsize") {
+		t.Errorf("unknown option: %+v", s.Results)
+	}
+
+	broken := filepath.Join(dir, "broken.yaml")
+	os.WriteFile(broken, []byte("editor: [\n"), 0644)
+	if s := ConfigFile([]string{broken, good}); s.Results[0].Status != Fail {
+		t.Errorf("broken config: %+v", s.Results)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	if s := Settings(config.DefaultConfig()); s.Results[0].Status != Pass {
+		t.Errorf("default config: %+v", s.Results)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.AI.DefaultProvider = "anthropic"
+	cfg.AI.FallbackOrder = []string{"ollama", "copilot"}
+	cfg.AI.Routes = map[string]config.AIRouteConfig{"chat": {Provider: "openai"}}
+	cfg.Providers = []ai.ProviderConfig{{Type: ai.ProviderOllama, Enabled: true}}
+	s := Settings(cfg)
+	report := Report([]Section{s})
+	for _, want := range []string{"default_provider: anthropic isn't enabled", `unknown provider "copilot"`, "routes.chat: openai isn't enabled"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+
+	cfg = config.DefaultConfig()
+	cfg.Editor.Background = "grey"
+	cfg.Editor.Colors = map[string]config.ColorConfig{"cursor_line": {Bg: "#12345"}}
+	report = Report([]Section{Settings(cfg)})
+	for _, want := range []string{`editor.background: "grey"`, `editor.theme: cursor_line: unknown color "#12345"`} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestLanguageServers(t *testing.T) {
+	fakeEnv(t, nil, "gopls")
+	cfg := config.LSPConfig{Enabled: true, Servers: []config.LSPServerConfig{
+		{Name: "gopls", Command: "gopls", Enabled: true},
+		{Name: "pyright", Command: "pyright-langserver", Enabled: true},
+		{Name: "rust-analyzer", Command: "rust-analyzer"},
+	}}
+	s := LanguageServers(cfg)
+	if got := statuses(s); len(got) != 2 || got[0] != Pass || got[1] != Fail {
+		t.Errorf("LanguageServers() = %+v", s.Results)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	s := Providers(map[ai.ProviderType]error{
+		ai.ProviderOllama: nil,
+		ai.ProviderOpenAI: &ai.ProviderError{Provider: ai.ProviderOpenAI, Kind: ai.ErrorAuth, Err: errors.New("401")},
+	}, ai.ProviderOllama)
+	report := Report([]Section{s})
+	if !strings.Contains(report, "[OK] ollama (active): reachable") || !strings.Contains(report, "[FAIL] openai: check its api_key") {
+		t.Errorf("Providers report:\n%s", report)
+	}
+	if s := Providers(nil, ""); s.Results[0].Status != Warn {
+		t.Errorf("no providers: %+v", s.Results)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	fakeEnv(t, map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"})
+	if got := statuses(Terminal()); len(got) != 3 || got[1] != Pass || got[2] != Pass {
+		t.Errorf("true color xterm: %v", got)
+	}
+
+	fakeEnv(t, map[string]string{"TERM": "screen-256color", "TMUX": "/tmp/tmux"})
+	if got := statuses(Terminal()); len(got) != 4 || got[1] != Warn || got[3] != Warn {
+		t.Errorf("256 color tmux: %v", got)
+	}
+
+	fakeEnv(t, map[string]string{"TERM": "dumb"})
+	if got := statuses(Terminal()); len(got) != 1 || got[0] != Fail {
+		t.Errorf("dumb terminal: %v", got)
+	}
+}
+
+func TestClipboard(t *testing.T) {
+	fakeEnv(t, map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, "xclip")
+	if s := Clipboard(); s.Results[0].Status != Pass || s.Results[0].Message != "/usr/bin/xclip" {
+		t.Errorf("wayland with xclip: %+v", s.Results)
+	}
+
+	fakeEnv(t, map[string]string{"DISPLAY": ":0"})
+	if s := Clipboard(); s.Results[0].Status != Warn {
+		t.Errorf("no clipboard tool: %+v", s.Results)
+	}
+}
+
+func TestReportCounts(t *testing.T) {
+	report := Report([]Section{
+		{Title: "One", Results: []Result{{Pass, "a"}, {Warn, "b"}}},
+		{Title: "Two", Results: []Result{{Fail, "c"}}},
+	})
+	if !strings.HasPrefix(report, "1 OK, 1 warnings, 1 failures\n") {
+		t.Errorf("Report() = %q", report)
+	}
+}
@@ -0,0 +1,79 @@
+package help
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// maxValueLength is the longest default value shown in full
+const maxValueLength = 60
+
+// ConfigSection lists every config option of cfg, a config struct holding
+// the defaults, by its dotted YAML path with its default value
+func ConfigSection(tag, title string, cfg any) Section {
+	var lines []string
+	walkConfig(reflect.ValueOf(cfg), "", &lines)
+	return Section{Tag: tag, Title: title, Lines: lines}
+}
+
+// walkConfig adds the options of v under path to lines, tagging the
+// top-level groups as config-<name>
+func walkConfig(v reflect.Value, path string, lines *[]string) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+			continue
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			child := name
+			if path != "" {
+				child = path + "." + name
+			} else {
+				tag := "*config-" + name + "*"
+				*lines = append(*lines, "", name+":"+strings.Repeat(" ", max(2, 59-len(name)-len(tag)))+tag)
+			}
+			walkConfig(v.Field(i), child, lines)
+		}
+	case reflect.Slice:
+		if elem := v.Type().Elem(); elem.Kind() == reflect.Struct {
+			walkConfig(reflect.Zero(elem), path+"[]", lines)
+			return
+		}
+		*lines = append(*lines, option(path, v))
+	case reflect.Map:
+		if elem := v.Type().Elem(); elem.Kind() == reflect.Struct {
+			walkConfig(reflect.Zero(elem), path+".<name>", lines)
+			return
+		}
+		*lines = append(*lines, option(path, v))
+	default:
+		*lines = append(*lines, option(path, v))
+	}
+}
+
+// option formats an option and its default
+func option(path string, v reflect.Value) string {
+	value := fmt.Sprint(v.Interface())
+	switch {
+	case v.Kind() == reflect.String:
+		value = fmt.Sprintf("%q", v.String())
+	case v.Kind() == reflect.Slice && v.Len() == 0, v.Kind() == reflect.Map && v.Len() == 0:
+		value = "none"
+	}
+	if len(value) > maxValueLength {
+		value = value[:maxValueLength-3] + "..."
+	}
+	return fmt.Sprintf("  %-44s default %s", path, value)
+}
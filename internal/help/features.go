@@ -0,0 +1,82 @@
+package help
+
+// Intro is the first section of the help, listing the others
+func Intro(contents []Section) Section {
+	lines := []string{
+		"Move with j/k, Ctrl-D/Ctrl-U and g/G. Enter on a line with a |tag|",
+		"jumps to it and Backspace goes back. q or Esc closes the help, and",
+		"  :help {topic}",
+		"opens it at a command, key, option or any text.",
+		"",
+		"Contents",
+	}
+	for _, s := range contents {
+		lines = append(lines, "  |"+s.Tag+"|  "+s.Title)
+	}
+	return Section{Tag: "help", Title: "AIED help", Lines: lines}
+}
+
+// AISection describes the AI features
+var AISection = Section{
+	Tag:   "ai",
+	Title: "AI features",
+	Lines: []string{
+		"Requests go to the active provider, switched with |:aiprovider|, or to",
+		"the provider and model routed for the request type by ai.routes. If a",
+		"provider fails the next in ai.fallback_order is tried, and the message",
+		"says why each one failed. See |config-ai| and |config-providers|.",
+		"@provider or @provider/model before the arguments of :ai, :aic, :aie,",
+		":air or :aiedit sends that request alone there, and --model name asks",
+		"for another model of the provider it goes to.",
+		"|:aiprovider| without arguments lists the providers in the palette, with",
+		"the models pulled into Ollama as ollama/model, and |:ollama| lists just",
+		"those models. Whether Ollama runs is asked in the background and kept",
+		"for 30 seconds, so listing never waits for it.",
+		"",
+		"  |:ai|        Ask a question; questions continue the project's thread",
+		"  |:ai-history| List the saved threads, resume one or start a new one",
+		"  |:aiscratch| Write prompts in a scratch buffer; Enter in normal mode",
+		"              sends the one at the cursor and writes the answer below",
+		"  |:aicomplete| Suggest a completion of the code at the cursor",
+		"  |:aiexplain| Explain the selection or the function at the cursor",
+		"  |:airefactor| Suggest refactorings",
+		"  |:aiedit|    Rewrite the range and preview the change as a diff",
+		"  |:aiapply|   Accept the previewed change; |:aidiscard| drops it",
+		"  |:agent|     Let the AI work on a task, asking before each change",
+		"",
+		"Without a prompt :ai, :aiedit and :agent open the composer, a popup",
+		"for writing one over several lines: Ctrl-Enter or Ctrl-S sends it,",
+		"Ctrl-P and Ctrl-N go through the prompts sent, Ctrl-F pastes the last",
+		"visual selection as a code block and Esc closes it, keeping the draft.",
+		"With |:set| aipreview each request first opens in the composer with",
+		"its prompt and context and an estimate of its tokens: Ctrl-D deletes",
+		"what shouldn't be sent and Ctrl-Enter sends the rest. |:aicontext|",
+		"shows a closed preview again.",
+		"",
+		"|:aicomplete| shows its completion in a box below the cursor before it",
+		"changes the buffer: Tab or Enter inserts it, w or Right its next word,",
+		"l or Down the rest of its line, and Esc drops what is left.",
+		"",
+		"Providers may set rate_limit and burst: requests over the limit wait",
+		"in a queue, counted on the status line. The replay provider records",
+		"another provider's answers and replays them without network access.",
+	},
+}
+
+// LSPSection describes the language server features
+var LSPSection = Section{
+	Tag:   "lsp",
+	Title: "Language servers",
+	Lines: []string{
+		"Language servers start for the filetypes configured under lsp, rooted",
+		"at the project found by editor.root_markers. See |config-lsp|.",
+		"",
+		"  |:hover|       Show information about the symbol at the cursor",
+		"  |:definition|  Go to the definition of the symbol at the cursor",
+		"  |:references|  List references in the quickfix list",
+		"  |:rename|      Rename the symbol across the project",
+		"  |:diagnostics| List the buffer's diagnostics in the location list",
+		"",
+		"In insert mode Ctrl-Space asks the server for completions.",
+	},
+}
@@ -0,0 +1,131 @@
+// Package help builds the editor's help document from its commands, keys,
+// config options and features, and keeps the place of a reader moving
+// through it.
+//
+// Like Vim's help, a section starts with a line holding its *tag*, and
+// |tag| elsewhere refers to it.
+package help
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Section is a titled part of the help, found by its tag
+type Section struct {
+	Tag   string
+	Title string
+	Lines []string
+}
+
+// Doc is the help text with the line of each tag defined in it
+type Doc struct {
+	lines []string
+	tags  map[string]int
+}
+
+var (
+	tagDefinition = regexp.MustCompile(`\*([^*\s]+)\*`)
+	tagReference  = regexp.MustCompile(`\|([^|\s]+)\|`)
+)
+
+// New joins sections into a document, each starting with a heading line
+// defining its tag. Tags written as *tag* in the lines are defined too.
+func New(sections []Section) *Doc {
+	d := &Doc{tags: make(map[string]int)}
+	for i, s := range sections {
+		if i > 0 {
+			d.lines = append(d.lines, "")
+		}
+		d.lines = append(d.lines, heading(s.Title, s.Tag), strings.Repeat("=", len(s.Title)))
+		d.lines = append(d.lines, s.Lines...)
+	}
+	for n, line := range d.lines {
+		for _, m := range tagDefinition.FindAllStringSubmatch(line, -1) {
+			if _, dup := d.tags[m[1]]; !dup {
+				d.tags[m[1]] = n
+			}
+		}
+	}
+	return d
+}
+
+// heading returns a section's title line, with its tag on the right
+func heading(title, tag string) string {
+	if tag == "" {
+		return title
+	}
+	return title + strings.Repeat(" ", max(2, 58-len(title)-len(tag))) + "*" + tag + "*"
+}
+
+// Lines returns the text of the document
+func (d *Doc) Lines() []string {
+	return d.lines
+}
+
+// Tags returns the tags defined in the document, sorted
+func (d *Doc) Tags() []string {
+	tags := make([]string, 0, len(d.tags))
+	for tag := range d.tags {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// Find returns the line a topic is found on: its tag, the tag of the
+// command it names, a tag ignoring case, the shortest tag it starts, and
+// otherwise the first line mentioning it
+func (d *Doc) Find(topic string) (int, bool) {
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		return 0, len(d.lines) > 0
+	}
+	for _, t := range []string{topic, ":" + topic} {
+		if line, ok := d.tags[t]; ok {
+			return line, true
+		}
+	}
+
+	var prefixed []string
+	for _, tag := range d.Tags() {
+		if strings.EqualFold(tag, topic) {
+			return d.tags[tag], true
+		}
+		if hasPrefixFold(tag, topic) || hasPrefixFold(tag, ":"+topic) {
+			prefixed = append(prefixed, tag)
+		}
+	}
+	if len(prefixed) > 0 {
+		shortest := slices.MinFunc(prefixed, func(a, b string) int { return len(a) - len(b) })
+		return d.tags[shortest], true
+	}
+
+	lower := strings.ToLower(topic)
+	for n, line := range d.lines {
+		if strings.Contains(strings.ToLower(line), lower) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// hasPrefixFold reports whether s starts with prefix, ignoring case
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// References returns the tags referred to as |tag| on line n
+func (d *Doc) References(n int) []string {
+	if n < 0 || n >= len(d.lines) {
+		return nil
+	}
+	var refs []string
+	for _, m := range tagReference.FindAllStringSubmatch(d.lines[n], -1) {
+		if _, ok := d.tags[m[1]]; ok {
+			refs = append(refs, m[1])
+		}
+	}
+	return refs
+}
@@ -0,0 +1,132 @@
+package help
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testDoc() *Doc {
+	return New([]Section{
+		{Tag: "help", Title: "Help", Lines: []string{"See |commands| and |missing|."}},
+		{Tag: "commands", Title: "Commands", Lines: []string{
+			":write  *:write* *:w*",
+			"    Write the buffer",
+			":wq  *:wq*",
+			"    Write and quit",
+		}},
+		{Tag: "config", Title: "Configuration", Lines: []string{"  editor.tab_size  default 4"}},
+	})
+}
+
+func TestNew_Tags(t *testing.T) {
+	d := testDoc()
+	want := []string{":w", ":wq", ":write", "commands", "config", "help"}
+	if got := d.Tags(); !slices.Equal(got, want) {
+		t.Errorf("Tags() = %v, want %v", got, want)
+	}
+	if line, _ := d.Find("commands"); !strings.HasPrefix(d.Lines()[line], "Commands") {
+		t.Errorf("section tag at %q", d.Lines()[line])
+	}
+}
+
+func TestDoc_Find(t *testing.T) {
+	d := testDoc()
+	tests := []struct {
+		topic string
+		want  string // Start of the line found
+	}{
+		{":w", ":write"},
+		{"w", ":write"}, // Command without its colon
+		{"wq", ":wq"},   // Exact tag before a longer one
+		{"COMMANDS", "Commands"},
+		{"wri", ":write"}, // Prefix of a tag
+		{"tab_size", "  editor.tab_size"},
+		{"", "Help"},
+	}
+	for _, tt := range tests {
+		line, ok := d.Find(tt.topic)
+		if !ok || !strings.HasPrefix(d.Lines()[line], tt.want) {
+			t.Errorf("Find(%q) found %q, want %q", tt.topic, d.Lines()[line], tt.want)
+		}
+	}
+	if _, ok := d.Find("nowhere"); ok {
+		t.Error("Find found a topic that isn't in the help")
+	}
+}
+
+func TestDoc_References(t *testing.T) {
+	d := testDoc()
+	line, _ := d.Find("See")
+	if got := d.References(line); !slices.Equal(got, []string{"commands"}) {
+		t.Errorf("References = %v, want only the defined tag", got)
+	}
+	if got := d.References(-1); got != nil {
+		t.Errorf("References outside the document = %v", got)
+	}
+}
+
+func TestView(t *testing.T) {
+	d := testDoc()
+	v := NewView(d)
+	v.SetHeight(3)
+
+	v.Move(2)
+	if !v.Follow() {
+		t.Fatal("Follow found no tag on the line")
+	}
+	if want, _ := d.Find("commands"); v.Line() != want || v.Top() != want {
+		t.Errorf("after Follow line %d top %d, want both %d", v.Line(), v.Top(), want)
+	}
+	if !v.Jump(":wq") || !v.Back() {
+		t.Fatal("Jump and Back failed")
+	}
+	if want, _ := d.Find("commands"); v.Line() != want {
+		t.Errorf("Back went to line %d, want %d", v.Line(), want)
+	}
+	v.Back()
+	if v.Line() != 2 || v.Back() {
+		t.Errorf("Back should return to the first jump's line and then stop, at %d", v.Line())
+	}
+
+	v.Move(len(d.Lines()))
+	if v.Line() != len(d.Lines())-1 || v.Top() != len(d.Lines())-3 {
+		t.Errorf("at the end line %d top %d", v.Line(), v.Top())
+	}
+	v.Move(-100)
+	if v.Line() != 0 || v.Top() != 0 {
+		t.Errorf("at the start line %d top %d", v.Line(), v.Top())
+	}
+}
+
+func TestConfigSection(t *testing.T) {
+	type server struct {
+		Command string `yaml:"command"`
+	}
+	type editor struct {
+		TabSize int      `yaml:"tab_size"`
+		Theme   string   `yaml:"theme"`
+		Markers []string `yaml:"markers"`
+	}
+	cfg := struct {
+		Editor  editor            `yaml:"editor"`
+		Servers []server          `yaml:"servers"`
+		Build   map[string]server `yaml:"build"`
+		hidden  int
+	}{Editor: editor{TabSize: 4, Theme: "default"}}
+
+	text := strings.Join(ConfigSection("config", "Configuration", cfg).Lines, "\n")
+	for _, want := range []string{
+		"*config-editor*",
+		"editor.tab_size",
+		"default 4",
+		`default "default"`,
+		"editor.markers",
+		"servers[].command",
+		"build.<name>.command",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("config help is missing %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package help
+
+// View is a reader's place in a help document: the line they are on, the
+// first line shown, and the lines they jumped from
+type View struct {
+	doc    *Doc
+	line   int
+	top    int
+	height int   // Lines shown at once
+	back   []int // Lines to return to, most recent last
+}
+
+// NewView opens doc at its first line
+func NewView(doc *Doc) *View {
+	return &View{doc: doc, height: 1}
+}
+
+// Doc returns the document being read
+func (v *View) Doc() *Doc {
+	return v.doc
+}
+
+// Line returns the line the reader is on
+func (v *View) Line() int {
+	return v.line
+}
+
+// Top returns the first line shown
+func (v *View) Top() int {
+	return v.top
+}
+
+// SetHeight sets how many lines are shown at once, scrolling to keep the
+// reader's line in view
+func (v *View) SetHeight(height int) {
+	v.height = max(height, 1)
+	v.scroll()
+}
+
+// Move moves the reader's line by n lines, staying in the document
+func (v *View) Move(n int) {
+	v.line = min(max(v.line+n, 0), max(len(v.doc.lines)-1, 0))
+	v.scroll()
+}
+
+// Page moves by half the lines shown, down for a positive dir and up for a
+// negative one
+func (v *View) Page(dir int) {
+	v.Move(dir * max(v.height/2, 1))
+}
+
+// Jump goes to the line of topic, remembering the current line to go back
+// to. It reports whether the topic was found.
+func (v *View) Jump(topic string) bool {
+	line, ok := v.doc.Find(topic)
+	if !ok {
+		return false
+	}
+	v.back = append(v.back, v.line)
+	v.line, v.top = line, line
+	v.scroll()
+	return true
+}
+
+// Follow jumps to the first tag referred to on the reader's line
+func (v *View) Follow() bool {
+	refs := v.doc.References(v.line)
+	if len(refs) == 0 {
+		return false
+	}
+	return v.Jump(refs[0])
+}
+
+// Back returns to the line of the last jump
+func (v *View) Back() bool {
+	if len(v.back) == 0 {
+		return false
+	}
+	v.line = v.back[len(v.back)-1]
+	v.back = v.back[:len(v.back)-1]
+	v.scroll()
+	return true
+}
+
+// scroll keeps the reader's line in view and the view within the document
+func (v *View) scroll() {
+	if v.line < v.top {
+		v.top = v.line
+	}
+	if v.line >= v.top+v.height {
+		v.top = v.line - v.height + 1
+	}
+	v.top = max(min(v.top, len(v.doc.lines)-v.height), 0)
+}
@@ -6,8 +6,13 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/dshills/aied/internal/activity"
+	"github.com/dshills/aied/internal/perf"
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
 	"go.lsp.dev/uri"
@@ -22,6 +27,18 @@ type rwCloser struct {
 
 func (rwCloser) Close() error { return nil }
 
+// timedConn times the requests sent to the language server for :perf and
+// shows them on the status line while they run
+type timedConn struct {
+	jsonrpc2.Conn
+}
+
+func (c timedConn) Call(ctx context.Context, method string, params, result interface{}) (jsonrpc2.ID, error) {
+	defer perf.Since("lsp "+method, time.Now())
+	defer activity.Start("lsp " + method[strings.LastIndex(method, "/")+1:])()
+	return c.Conn.Call(ctx, method, params, result)
+}
+
 // Client represents a simplified LSP client
 type Client struct {
 	conn       jsonrpc2.Conn
@@ -34,6 +51,7 @@ type Client struct {
 	initialized  bool
 	capabilities *protocol.ServerCapabilities
 	diagnostics  map[string][]protocol.Diagnostic
+	version      atomic.Int32 // Last document version sent
 	
 	// Callbacks
 	onDiagnostics func(string, []protocol.Diagnostic)
@@ -94,7 +112,7 @@ func (c *Client) Start(ctx context.Context, cmd string, args ...string) error {
 	
 	// Create a no-op logger to avoid nil pointer issues
 	logger := zap.NewNop()
-	c.server = protocol.ServerDispatcher(conn, logger)
+	c.server = protocol.ServerDispatcher(timedConn{conn}, logger)
 	
 	// Initialize the server with minimal capabilities
 	if err := c.initialize(ctx); err != nil {
@@ -148,8 +166,29 @@ func (c *Client) initialize(ctx context.Context) error {
 			Name:    "aied",
 			Version: "0.1.0",
 		},
-		// Minimal capabilities - let server decide what to support
-		Capabilities: protocol.ClientCapabilities{},
+		// Minimal capabilities - let server decide what to support. Renaming
+		// files asks the server for the edits, such as fixed imports, it
+		// needs, and code actions come back with their edits.
+		Capabilities: protocol.ClientCapabilities{
+			TextDocument: &protocol.TextDocumentClientCapabilities{
+				CodeAction: &protocol.CodeActionClientCapabilities{
+					CodeActionLiteralSupport: &protocol.CodeActionClientCapabilitiesLiteralSupport{
+						CodeActionKind: &protocol.CodeActionClientCapabilitiesKind{
+							ValueSet: []protocol.CodeActionKind{protocol.SourceOrganizeImports},
+						},
+					},
+				},
+			},
+			Workspace: &protocol.WorkspaceClientCapabilities{
+				WorkspaceEdit: &protocol.WorkspaceClientCapabilitiesWorkspaceEdit{
+					DocumentChanges: true,
+				},
+				FileOperations: &protocol.WorkspaceClientCapabilitiesFileOperations{
+					WillRename: true,
+					DidRename:  true,
+				},
+			},
+		},
 	}
 	
 	result, err := c.server.Initialize(ctx, params)
@@ -206,27 +245,133 @@ func (c *Client) CloseFile(ctx context.Context, filename string) error {
 
 // UpdateFile sends file changes to the language server
 func (c *Client) UpdateFile(ctx context.Context, filename string, content string, version int32) error {
+	return c.didChange(ctx, filename, version, contentChange{Text: content})
+}
+
+// contentChange is a protocol.TextDocumentContentChangeEvent whose range is
+// left out when it replaces the whole text, which the protocol package
+// can't do
+type contentChange struct {
+	Range *protocol.Range `json:"range,omitempty"`
+	Text  string          `json:"text"`
+}
+
+// didChangeParams are protocol.DidChangeTextDocumentParams with contentChange
+type didChangeParams struct {
+	TextDocument   protocol.VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []contentChange                          `json:"contentChanges"`
+}
+
+// didChange sends a change to an open file as its version
+func (c *Client) didChange(ctx context.Context, filename string, version int32, change contentChange) error {
 	if !c.initialized {
 		return fmt.Errorf("client not initialized")
 	}
 	
-	fileURI := protocol.DocumentURI(uri.File(filename))
-	
-	params := &protocol.DidChangeTextDocumentParams{
+	params := &didChangeParams{
 		TextDocument: protocol.VersionedTextDocumentIdentifier{
 			TextDocumentIdentifier: protocol.TextDocumentIdentifier{
-				URI: fileURI,
+				URI: protocol.DocumentURI(uri.File(filename)),
 			},
 			Version: version,
 		},
-		ContentChanges: []protocol.TextDocumentContentChangeEvent{
-			{
-				Text: content,
-			},
-		},
+		ContentChanges: []contentChange{change},
 	}
 	
-	return c.server.DidChange(ctx, params)
+	return c.conn.Notify(ctx, protocol.MethodTextDocumentDidChange, params)
+}
+
+// syncsIncrementally reports whether the server accepts changes to part of
+// a document rather than only its whole text
+func (c *Client) syncsIncrementally() bool {
+	if c.capabilities == nil {
+		return false
+	}
+	// The sync kind is either given alone or in the sync options
+	kind := c.capabilities.TextDocumentSync
+	if options, ok := kind.(map[string]interface{}); ok {
+		kind = options["change"]
+	}
+	number, ok := kind.(float64)
+	return ok && protocol.TextDocumentSyncKind(number) == protocol.TextDocumentSyncKindIncremental
+}
+
+// fileOperations returns the file operations the server wants to hear
+// about, or nil
+func (c *Client) fileOperations() *protocol.ServerCapabilitiesWorkspaceFileOperations {
+	if c.capabilities == nil || c.capabilities.Workspace == nil {
+		return nil
+	}
+	return c.capabilities.Workspace.FileOperations
+}
+
+// renameParams describes renaming oldName to newName
+func renameParams(oldName, newName string) *protocol.RenameFilesParams {
+	return &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: string(uri.File(oldName)),
+			NewURI: string(uri.File(newName)),
+		}},
+	}
+}
+
+// WillRenameFile asks the server for the edits renaming oldName to newName
+// needs before it happens. It returns nil when the server has none or
+// doesn't handle renames.
+func (c *Client) WillRenameFile(ctx context.Context, oldName, newName string) (*protocol.WorkspaceEdit, error) {
+	if !c.initialized {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	if ops := c.fileOperations(); ops == nil || ops.WillRename == nil {
+		return nil, nil
+	}
+	return c.server.WillRenameFiles(ctx, renameParams(oldName, newName))
+}
+
+// DidRenameFile tells the server oldName was renamed to newName
+func (c *Client) DidRenameFile(ctx context.Context, oldName, newName string) error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	if ops := c.fileOperations(); ops == nil || ops.DidRename == nil {
+		return nil
+	}
+	return c.server.DidRenameFiles(ctx, renameParams(oldName, newName))
+}
+
+// SyncFile sends content as the new text of an open file, so requests
+// see changes not yet saved
+func (c *Client) SyncFile(ctx context.Context, filename string, content string) error {
+	// Opening is version 1, so every change is newer
+	version := c.version.Add(1) + 1
+	return c.UpdateFile(ctx, filename, content, version)
+}
+
+// SyncLines sends text as the new content of lines start up to end of an
+// open file. The server must sync incrementally.
+func (c *Client) SyncLines(ctx context.Context, filename string, start, end int, text string) error {
+	r := BufferToLSPRange(start, 0, end, 0)
+	version := c.version.Add(1) + 1
+	return c.didChange(ctx, filename, version, contentChange{Range: &r, Text: text})
+}
+
+// GetCodeActions requests the code actions of the kinds in only for a range
+func (c *Client) GetCodeActions(ctx context.Context, filename string, r protocol.Range, only []protocol.CodeActionKind) ([]protocol.CodeAction, error) {
+	if !c.initialized {
+		return nil, fmt.Errorf("client not initialized")
+	}
+
+	params := &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: protocol.DocumentURI(uri.File(filename)),
+		},
+		Range: r,
+		Context: protocol.CodeActionContext{
+			Diagnostics: []protocol.Diagnostic{},
+			Only:        only,
+		},
+	}
+	return c.server.CodeAction(ctx, params)
 }
 
 // GetHover requests hover information
@@ -314,6 +459,48 @@ func (c *Client) GetDefinition(ctx context.Context, filename string, line, chara
 	return result, nil
 }
 
+// GetDocumentSymbols requests the symbols defined in a file. Servers answer
+// with either a tree of DocumentSymbol or a flat list of SymbolInformation;
+// the latter are returned as DocumentSymbols without children.
+func (c *Client) GetDocumentSymbols(ctx context.Context, filename string) ([]protocol.DocumentSymbol, error) {
+	if !c.initialized {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	
+	params := &protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: protocol.DocumentURI(uri.File(filename)),
+		},
+	}
+	
+	result, err := c.server.DocumentSymbol(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	
+	// The elements arrive as generic JSON values
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var decoded []struct {
+		protocol.DocumentSymbol
+		Location *protocol.Location `json:"location"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid document symbols: %w", err)
+	}
+	
+	symbols := make([]protocol.DocumentSymbol, 0, len(decoded))
+	for _, d := range decoded {
+		if d.Location != nil {
+			d.DocumentSymbol.Range = d.Location.Range
+		}
+		symbols = append(symbols, d.DocumentSymbol)
+	}
+	return symbols, nil
+}
+
 // GetDiagnostics returns diagnostics for a file
 func (c *Client) GetDiagnostics(filename string) []protocol.Diagnostic {
 	c.mu.Lock()
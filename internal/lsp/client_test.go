@@ -0,0 +1,53 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestSyncsIncrementally(t *testing.T) {
+	tests := []struct {
+		capabilities string
+		want         bool
+	}{
+		{`{}`, false},
+		{`{"textDocumentSync": 1}`, false},
+		{`{"textDocumentSync": 2}`, true},
+		{`{"textDocumentSync": {"openClose": true, "change": 1}}`, false},
+		{`{"textDocumentSync": {"openClose": true, "change": 2}}`, true},
+	}
+
+	for _, tt := range tests {
+		var capabilities protocol.ServerCapabilities
+		if err := json.Unmarshal([]byte(tt.capabilities), &capabilities); err != nil {
+			t.Fatal(err)
+		}
+		c := &Client{capabilities: &capabilities}
+		if got := c.syncsIncrementally(); got != tt.want {
+			t.Errorf("syncsIncrementally() with %s = %v, want %v", tt.capabilities, got, tt.want)
+		}
+	}
+}
+
+func TestContentChange(t *testing.T) {
+	r := BufferToLSPRange(1, 0, 3, 0)
+	tests := []struct {
+		change contentChange
+		want   string
+	}{
+		{contentChange{Text: "all"}, `{"text":"all"}`},
+		{contentChange{Range: &r, Text: "b\n"}, `{"range":{"start":{"line":1,"character":0},"end":{"line":3,"character":0}},"text":"b\n"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.change)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("got %s, want %s", data, tt.want)
+		}
+	}
+}
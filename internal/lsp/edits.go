@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// WorkspaceEditFiles returns the text edits of a workspace edit by the
+// file they change, from both its changes and its document changes
+func WorkspaceEditFiles(edit *protocol.WorkspaceEdit) map[string][]protocol.TextEdit {
+	files := make(map[string][]protocol.TextEdit)
+	if edit == nil {
+		return files
+	}
+	for fileURI, edits := range edit.Changes {
+		filename := fileURI.Filename()
+		files[filename] = append(files[filename], edits...)
+	}
+	for _, change := range edit.DocumentChanges {
+		filename := change.TextDocument.URI.Filename()
+		files[filename] = append(files[filename], change.Edits...)
+	}
+	return files
+}
+
+// ApplyTextEdits returns text with edits applied. Like every range the
+// server sends, the edits all refer to text as it was before any of them.
+func ApplyTextEdits(text string, edits []protocol.TextEdit) string {
+	lines := strings.SplitAfter(text, "\n")
+	offset := func(pos protocol.Position) int {
+		if int(pos.Line) >= len(lines) {
+			return len(text)
+		}
+		n := 0
+		for _, line := range lines[:pos.Line] {
+			n += len(line)
+		}
+		line := strings.TrimRight(lines[pos.Line], "\r\n")
+		return n + min(int(pos.Character), len(line))
+	}
+
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, len(edits))
+	for i, edit := range edits {
+		start, end := offset(edit.Range.Start), offset(edit.Range.End)
+		spans[i] = span{start: start, end: max(start, end), text: edit.NewText}
+	}
+
+	// From the end, so each edit leaves the offsets before it alone. Edits
+	// inserting at the same place go in the order they were sent.
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	for i := len(spans) - 1; i >= 0; i-- {
+		text = text[:spans[i].start] + spans[i].text + text[spans[i].end:]
+	}
+	return text
+}
@@ -0,0 +1,72 @@
+package lsp
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func edit(startLine, startCol, endLine, endCol int, text string) protocol.TextEdit {
+	return protocol.TextEdit{Range: BufferToLSPRange(startLine, startCol, endLine, endCol), NewText: text}
+}
+
+func TestApplyTextEdits(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		edits []protocol.TextEdit
+		want  string
+	}{
+		{
+			name:  "edits refer to the original text",
+			text:  "import \"a/old\"\nold.Run()\n",
+			edits: []protocol.TextEdit{edit(1, 0, 1, 3, "renamed"), edit(0, 10, 0, 13, "renamed")},
+			want:  "import \"a/renamed\"\nrenamed.Run()\n",
+		},
+		{
+			name:  "inserts at the same place keep their order",
+			text:  "x",
+			edits: []protocol.TextEdit{edit(0, 0, 0, 0, "a"), edit(0, 0, 0, 0, "b")},
+			want:  "abx",
+		},
+		{
+			name:  "line endings are kept",
+			text:  "one\r\ntwo\r\n",
+			edits: []protocol.TextEdit{edit(0, 0, 0, 99, "1"), edit(2, 0, 2, 0, "three\r\n")},
+			want:  "1\r\ntwo\r\nthree\r\n",
+		},
+		{
+			name:  "whole lines deleted",
+			text:  "a\nb\nc",
+			edits: []protocol.TextEdit{edit(1, 0, 2, 0, "")},
+			want:  "a\nc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApplyTextEdits(tt.text, tt.edits); got != tt.want {
+				t.Errorf("ApplyTextEdits() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspaceEditFiles(t *testing.T) {
+	a := protocol.DocumentURI("file:///src/a.go")
+	b := protocol.DocumentURI("file:///src/b.go")
+	files := WorkspaceEditFiles(&protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{a: {edit(0, 0, 0, 0, "x")}},
+		DocumentChanges: []protocol.TextDocumentEdit{{
+			TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: b},
+			},
+			Edits: []protocol.TextEdit{edit(1, 0, 1, 0, "y")},
+		}},
+	})
+	if len(files) != 2 || len(files["/src/a.go"]) != 1 || len(files["/src/b.go"]) != 1 {
+		t.Errorf("WorkspaceEditFiles() = %v", files)
+	}
+	if len(WorkspaceEditFiles(nil)) != 0 {
+		t.Error("expected no files for no edit")
+	}
+}
@@ -2,11 +2,15 @@ package lsp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/filetype"
 	"go.lsp.dev/protocol"
 )
 
@@ -27,6 +31,8 @@ type Manager struct {
 	extToServer   map[string]string  // file extension to server name
 	configs       []ServerConfig
 	rootPath      string
+	documents     map[string]*document // Open files, by name
+	stopped       bool                 // Whether StopAll has run, so servers still starting are stopped
 	
 	// Callbacks
 	onDiagnostics func(filename string, diagnostics []protocol.Diagnostic)
@@ -39,9 +45,17 @@ func NewManager(rootPath string) *Manager {
 		langToServer: make(map[string]string),
 		extToServer:  make(map[string]string),
 		rootPath:     rootPath,
+		documents:    make(map[string]*document),
 	}
 }
 
+// document is what the language server has of an open file: the buffer
+// last synced to it at a change sequence, or nil when it has other text
+type document struct {
+	buf *buffer.Buffer
+	seq int
+}
+
 // Configure adds server configurations
 func (m *Manager) Configure(configs []ServerConfig) {
 	m.mu.Lock()
@@ -60,17 +74,13 @@ func (m *Manager) Configure(configs []ServerConfig) {
 	}
 }
 
-// Start starts a specific language server
+// Start starts a specific language server. The lock isn't held while the
+// server starts, which can take seconds, so the other servers answer in
+// the meantime.
 func (m *Manager) Start(ctx context.Context, serverName string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	
-	// Check if already started
-	if _, exists := m.clients[serverName]; exists {
-		return nil
-	}
-	
-	// Find config
+	m.mu.RLock()
+	_, exists := m.clients[serverName]
+	rootPath := m.rootPath
 	var config *ServerConfig
 	for _, cfg := range m.configs {
 		if cfg.Name == serverName {
@@ -78,18 +88,26 @@ func (m *Manager) Start(ctx context.Context, serverName string) error {
 			break
 		}
 	}
-	
+	m.mu.RUnlock()
+
+	// Check if already started
+	if exists {
+		return nil
+	}
 	if config == nil {
 		return fmt.Errorf("no configuration found for server %s", serverName)
 	}
 	
 	// Create and start client
-	client := NewClient(serverName, m.rootPath)
+	client := NewClient(serverName, rootPath)
 	
 	// Set diagnostics handler
 	client.SetDiagnosticsHandler(func(filename string, diagnostics []protocol.Diagnostic) {
-		if m.onDiagnostics != nil {
-			m.onDiagnostics(filename, diagnostics)
+		m.mu.RLock()
+		onDiagnostics := m.onDiagnostics
+		m.mu.RUnlock()
+		if onDiagnostics != nil {
+			onDiagnostics(filename, diagnostics)
 		}
 	})
 	
@@ -97,6 +115,13 @@ func (m *Manager) Start(ctx context.Context, serverName string) error {
 		return fmt.Errorf("failed to start %s: %w", serverName, err)
 	}
 	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.clients[serverName]; exists || m.stopped {
+		// Another Start won, or StopAll ran while this one was starting
+		client.Stop()
+		return nil
+	}
 	m.clients[serverName] = client
 	return nil
 }
@@ -115,11 +140,49 @@ func (m *Manager) Stop(serverName string) error {
 	return client.Stop()
 }
 
+// Root returns the directory the servers are rooted at
+func (m *Manager) Root() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.rootPath
+}
+
+// SetRoot moves the workspace to root, restarting the running servers
+// there. The files open in them are closed, so they have to be opened
+// again.
+func (m *Manager) SetRoot(ctx context.Context, root string) error {
+	m.mu.Lock()
+	if root == m.rootPath {
+		m.mu.Unlock()
+		return nil
+	}
+	m.rootPath = root
+	running := m.clients
+	m.clients = make(map[string]*Client)
+	m.documents = make(map[string]*document)
+	m.mu.Unlock()
+
+	var names []string
+	for name, client := range running {
+		client.Stop()
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if err := m.Start(ctx, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // StopAll stops all language servers
 func (m *Manager) StopAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	m.stopped = true
 	for name, client := range m.clients {
 		client.Stop()
 		delete(m.clients, name)
@@ -175,8 +238,12 @@ func (m *Manager) OpenFile(ctx context.Context, filename string, content string)
 		return err
 	}
 	
-	languageID := m.getLanguageID(filename)
-	return client.OpenFile(ctx, filename, content, languageID)
+	languageID := filetype.LanguageID(filetype.Detect(filename, strings.Split(content, "\n")), filename)
+	if err := client.OpenFile(ctx, filename, content, languageID); err != nil {
+		return err
+	}
+	m.setDocument(filename, &document{})
+	return nil
 }
 
 // CloseFile closes a file in the appropriate language server
@@ -186,6 +253,7 @@ func (m *Manager) CloseFile(ctx context.Context, filename string) error {
 		return err
 	}
 	
+	m.setDocument(filename, nil)
 	return client.CloseFile(ctx, filename)
 }
 
@@ -199,6 +267,120 @@ func (m *Manager) UpdateFile(ctx context.Context, filename string, content strin
 	return client.UpdateFile(ctx, filename, content, version)
 }
 
+// SyncBuffer sends the changes to buf since it was last synced to the
+// language server of its file, if the file is open there. Only the changed
+// lines are sent when the server takes changes to part of a file.
+func (m *Manager) SyncBuffer(ctx context.Context, buf *buffer.Buffer) error {
+	filename := buf.Filename()
+	doc := m.document(filename)
+	seq := buf.ChangeSeq()
+	if doc == nil || doc.buf == buf && doc.seq == seq {
+		return nil
+	}
+	client, err := m.GetClient(filename)
+	if err != nil {
+		return err
+	}
+	
+	change, ok := buf.ChangedSince(doc.seq)
+	oldCount := buf.LineCount() - change.Added + change.Removed
+	// A change reaching the end of the file has no line start to end at
+	if doc.buf == buf && ok && change.Start+change.Removed < oldCount && client.syncsIncrementally() {
+		var text strings.Builder
+		for line := change.Start; line < change.Start+change.Added; line++ {
+			content, _ := buf.Line(line)
+			text.WriteString(content)
+			text.WriteByte('\n')
+		}
+		err = client.SyncLines(ctx, filename, change.Start, change.Start+change.Removed, text.String())
+	} else {
+		err = client.SyncFile(ctx, filename, GetBufferContent(buf))
+	}
+	if err != nil {
+		return err
+	}
+	
+	m.setDocument(filename, &document{buf: buf, seq: seq})
+	return nil
+}
+
+// document returns what the language server has of filename, or nil when
+// it isn't open
+func (m *Manager) document(filename string) *document {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.documents[filename]
+}
+
+// setDocument records what the language server has of filename; nil means
+// it was closed
+func (m *Manager) setDocument(filename string, doc *document) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if doc == nil {
+		delete(m.documents, filename)
+		return
+	}
+	m.documents[filename] = doc
+}
+
+// WillRenameFile asks the language server of oldName for the edits
+// renaming it to newName needs
+func (m *Manager) WillRenameFile(ctx context.Context, oldName, newName string) (*protocol.WorkspaceEdit, error) {
+	client, err := m.GetClient(oldName)
+	if err != nil {
+		return nil, err
+	}
+	
+	return client.WillRenameFile(ctx, oldName, newName)
+}
+
+// DidRenameFile tells the language server of oldName it was renamed to
+// newName, and opens it there under its new name with content
+func (m *Manager) DidRenameFile(ctx context.Context, oldName, newName string, content string) error {
+	client, err := m.GetClient(oldName)
+	if err != nil {
+		return err
+	}
+	
+	m.setDocument(oldName, nil)
+	if err := client.CloseFile(ctx, oldName); err != nil {
+		return err
+	}
+	if err := client.DidRenameFile(ctx, oldName, newName); err != nil {
+		return err
+	}
+	return m.OpenFile(ctx, newName, content)
+}
+
+// OrganizeImports asks the language server of filename for its
+// source.organizeImports edits to content, or nil when it has none
+func (m *Manager) OrganizeImports(ctx context.Context, filename string, content string) (*protocol.WorkspaceEdit, error) {
+	client, err := m.GetClient(filename)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := client.SyncFile(ctx, filename, content); err != nil {
+		return nil, err
+	}
+	if m.document(filename) != nil {
+		m.setDocument(filename, &document{})
+	}
+	lines := strings.Split(content, "\n")
+	whole := BufferToLSPRange(0, 0, len(lines)-1, len(lines[len(lines)-1]))
+	actions, err := client.GetCodeActions(ctx, filename, whole, []protocol.CodeActionKind{protocol.SourceOrganizeImports})
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range actions {
+		if action.Edit != nil && action.Disabled == nil {
+			return action.Edit, nil
+		}
+	}
+	return nil, nil
+}
+
 // Completion requests completions for a file position
 func (m *Manager) Completion(ctx context.Context, filename string, line, col int) ([]protocol.CompletionItem, error) {
 	client, err := m.GetClient(filename)
@@ -229,6 +411,16 @@ func (m *Manager) Definition(ctx context.Context, filename string, line, col int
 	return client.GetDefinition(ctx, filename, uint32(line), uint32(col))
 }
 
+// DocumentSymbols returns the symbols defined in a file
+func (m *Manager) DocumentSymbols(ctx context.Context, filename string) ([]protocol.DocumentSymbol, error) {
+	client, err := m.GetClient(filename)
+	if err != nil {
+		return nil, err
+	}
+	
+	return client.GetDocumentSymbols(ctx, filename)
+}
+
 // References finds all references to a symbol
 func (m *Manager) References(ctx context.Context, filename string, line, col int) ([]protocol.Location, error) {
 	// TODO: Implement references when client supports it
@@ -257,111 +449,6 @@ func (m *Manager) SetDiagnosticsHandler(handler func(filename string, diagnostic
 	defer m.mu.Unlock()
 	m.onDiagnostics = handler
 }
-
-// getLanguageID determines the language ID for a file
-func (m *Manager) getLanguageID(filename string) string {
-	ext := filepath.Ext(filename)
-	if ext != "" {
-		ext = ext[1:] // Remove leading dot
-	}
-	
-	// Common language ID mappings
-	switch strings.ToLower(ext) {
-	case "go":
-		return "go"
-	case "rs":
-		return "rust"
-	case "py":
-		return "python"
-	case "js", "mjs":
-		return "javascript"
-	case "jsx":
-		return "javascriptreact"
-	case "ts", "mts":
-		return "typescript"
-	case "tsx":
-		return "typescriptreact"
-	case "c":
-		return "c"
-	case "cc", "cpp", "cxx", "c++":
-		return "cpp"
-	case "h", "hpp", "hxx", "h++":
-		return "cpp"
-	case "java":
-		return "java"
-	case "rb":
-		return "ruby"
-	case "php":
-		return "php"
-	case "cs":
-		return "csharp"
-	case "swift":
-		return "swift"
-	case "kt":
-		return "kotlin"
-	case "scala":
-		return "scala"
-	case "clj":
-		return "clojure"
-	case "ex", "exs":
-		return "elixir"
-	case "erl", "hrl":
-		return "erlang"
-	case "hs":
-		return "haskell"
-	case "ml", "mli":
-		return "ocaml"
-	case "vim":
-		return "vim"
-	case "lua":
-		return "lua"
-	case "sh", "bash":
-		return "shellscript"
-	case "yaml", "yml":
-		return "yaml"
-	case "json":
-		return "json"
-	case "xml":
-		return "xml"
-	case "html", "htm":
-		return "html"
-	case "css":
-		return "css"
-	case "scss":
-		return "scss"
-	case "less":
-		return "less"
-	case "sql":
-		return "sql"
-	case "md", "markdown":
-		return "markdown"
-	case "tex":
-		return "latex"
-	case "r":
-		return "r"
-	case "m":
-		return "matlab"
-	case "jl":
-		return "julia"
-	case "nim":
-		return "nim"
-	case "zig":
-		return "zig"
-	case "dart":
-		return "dart"
-	case "toml":
-		return "toml"
-	case "ini":
-		return "ini"
-	case "dockerfile":
-		return "dockerfile"
-	case "makefile", "mk":
-		return "makefile"
-	default:
-		return "plaintext"
-	}
-}
-
 // DefaultConfigs returns default LSP server configurations
 func DefaultConfigs() []ServerConfig {
 	return []ServerConfig{
@@ -0,0 +1,120 @@
+// Package merge finds the conflicts version control leaves in a file as
+// <<<<<<<, ||||||| and >>>>>>> marker blocks, and resolves them.
+package merge
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	oursMarker      = "<<<<<<<"
+	baseMarker      = "|||||||"
+	separatorMarker = "======="
+	theirsMarker    = ">>>>>>>"
+)
+
+// Conflict is a block of conflicting changes, given as the line numbers of
+// its markers. Base is -1 unless the conflict has a common ancestor
+// section, as written with merge.conflictStyle diff3.
+type Conflict struct {
+	Start     int // <<<<<<< line
+	Base      int // ||||||| line, or -1
+	Separator int // ======= line
+	End       int // >>>>>>> line
+}
+
+// Find returns the conflicts in lines, in order. Blocks missing a marker
+// are ignored.
+func Find(lines []string) []Conflict {
+	var conflicts []Conflict
+	var c *Conflict
+	for i, line := range lines {
+		switch {
+		case isMarker(line, oursMarker):
+			c = &Conflict{Start: i, Base: -1, Separator: -1}
+		case c == nil:
+		case isMarker(line, baseMarker) && c.Base < 0 && c.Separator < 0:
+			c.Base = i
+		case isMarker(line, separatorMarker) && c.Separator < 0:
+			c.Separator = i
+		case isMarker(line, theirsMarker) && c.Separator >= 0:
+			c.End = i
+			conflicts = append(conflicts, *c)
+			c = nil
+		}
+	}
+	return conflicts
+}
+
+// isMarker reports whether line is a conflict marker, which is seven
+// marker characters followed by nothing or a space and a label
+func isMarker(line, marker string) bool {
+	rest, ok := strings.CutPrefix(line, marker)
+	return ok && (rest == "" || rest[0] == ' ')
+}
+
+// Ours returns the lines of our side of c
+func (c Conflict) Ours(lines []string) []string {
+	end := c.Separator
+	if c.Base >= 0 {
+		end = c.Base
+	}
+	return lines[c.Start+1 : end]
+}
+
+// BaseLines returns the lines of the common ancestor of c, or nil when the
+// conflict doesn't have them
+func (c Conflict) BaseLines(lines []string) []string {
+	if c.Base < 0 {
+		return nil
+	}
+	return lines[c.Base+1 : c.Separator]
+}
+
+// Theirs returns the lines of their side of c
+func (c Conflict) Theirs(lines []string) []string {
+	return lines[c.Separator+1 : c.End]
+}
+
+// OursLabel returns the name on the <<<<<<< marker of c, usually a branch
+// or commit
+func (c Conflict) OursLabel(lines []string) string {
+	return label(lines[c.Start])
+}
+
+// TheirsLabel returns the name on the >>>>>>> marker of c
+func (c Conflict) TheirsLabel(lines []string) string {
+	return label(lines[c.End])
+}
+
+// label returns the text after a marker
+func label(line string) string {
+	return strings.TrimSpace(line[len(oursMarker):])
+}
+
+// Choice is the text that replaces a resolved conflict
+type Choice int
+
+const (
+	ChoiceOurs   Choice = iota // Our side
+	ChoiceTheirs               // Their side
+	ChoiceBoth                 // Our side followed by theirs
+	ChoiceBase                 // The common ancestor
+	ChoiceNone                 // Neither side
+)
+
+// Resolve returns the lines that replace lines c.Start to c.End for choice
+func (c Conflict) Resolve(lines []string, choice Choice) []string {
+	switch choice {
+	case ChoiceOurs:
+		return slices.Clone(c.Ours(lines))
+	case ChoiceTheirs:
+		return slices.Clone(c.Theirs(lines))
+	case ChoiceBoth:
+		return slices.Concat(c.Ours(lines), c.Theirs(lines))
+	case ChoiceBase:
+		return slices.Clone(c.BaseLines(lines))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package merge
+
+import (
+	"slices"
+	"testing"
+)
+
+var conflicted = []string{
+	"package main",
+	"<<<<<<< HEAD",
+	"const a = 1",
+	"=======",
+	"const a = 2",
+	">>>>>>> feature",
+	"",
+	"<<<<<<< ours",
+	"x := f()",
+	"||||||| base",
+	"x := g()",
+	"=======",
+	"x := h()",
+	"y := x",
+	">>>>>>> theirs",
+	"<<<<<<< unfinished",
+	"=======",
+}
+
+func TestFind(t *testing.T) {
+	conflicts := Find(conflicted)
+	want := []Conflict{
+		{Start: 1, Base: -1, Separator: 3, End: 5},
+		{Start: 7, Base: 9, Separator: 11, End: 14},
+	}
+	if !slices.Equal(conflicts, want) {
+		t.Fatalf("Find = %+v, want %+v", conflicts, want)
+	}
+
+	c := conflicts[1]
+	if got := c.Ours(conflicted); !slices.Equal(got, []string{"x := f()"}) {
+		t.Errorf("Ours = %q", got)
+	}
+	if got := c.BaseLines(conflicted); !slices.Equal(got, []string{"x := g()"}) {
+		t.Errorf("BaseLines = %q", got)
+	}
+	if got := c.Theirs(conflicted); !slices.Equal(got, []string{"x := h()", "y := x"}) {
+		t.Errorf("Theirs = %q", got)
+	}
+	if c.OursLabel(conflicted) != "ours" || c.TheirsLabel(conflicted) != "theirs" {
+		t.Errorf("labels = %q, %q", c.OursLabel(conflicted), c.TheirsLabel(conflicted))
+	}
+	if got := conflicts[0].BaseLines(conflicted); got != nil {
+		t.Errorf("BaseLines without a base = %q", got)
+	}
+
+	// Lines that only start like markers aren't markers
+	if got := Find([]string{"<<<<<<<<", "=======", ">>>>>>>"}); got != nil {
+		t.Errorf("Find without a start marker = %+v", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c := Find(conflicted)[1]
+	tests := []struct {
+		choice Choice
+		want   []string
+	}{
+		{ChoiceOurs, []string{"x := f()"}},
+		{ChoiceTheirs, []string{"x := h()", "y := x"}},
+		{ChoiceBoth, []string{"x := f()", "x := h()", "y := x"}},
+		{ChoiceBase, []string{"x := g()"}},
+		{ChoiceNone, nil},
+	}
+	for _, tt := range tests {
+		if got := c.Resolve(conflicted, tt.choice); !slices.Equal(got, tt.want) {
+			t.Errorf("Resolve(%d) = %q, want %q", tt.choice, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package modeline
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultLines is how many lines at the start and end of a file are
+// searched for modelines
+const DefaultLines = 5
+
+// enabled turns modeline processing on or off for all files
+var enabled = true
+
+// marker finds the start of a modeline: vi:, vim: or ex: at the start of
+// the line or after whitespace
+var marker = regexp.MustCompile(`(?:^|\s)(?:vim?|Vim|ex):`)
+
+// SetEnabled turns modeline processing on or off. Modelines come from the
+// files being edited, so they can be disabled for untrusted files.
+func SetEnabled(on bool) {
+	enabled = on
+}
+
+// Enabled reports whether modelines are processed
+func Enabled() bool {
+	return enabled
+}
+
+// Parse returns the settings of a modeline, like "ts=4" or "noet", in the
+// order they appear. Both Vim forms are understood:
+//
+//	// vim: ts=4 sw=4 et
+//	/* vim: set ft=c tw=80: */
+//
+// Lines without a modeline return nil.
+func Parse(line string) []string {
+	loc := marker.FindStringIndex(line)
+	if loc == nil {
+		return nil
+	}
+	rest := strings.TrimLeft(line[loc[1]:], " \t")
+
+	// "set" form: whitespace separated settings ending at the first
+	// unescaped colon, with any text after it ignored
+	for _, prefix := range []string{"set ", "se "} {
+		if body, ok := strings.CutPrefix(rest, prefix); ok {
+			end := unescapedColon(body)
+			if end < 0 {
+				return nil
+			}
+			return split(body[:end], " \t")
+		}
+	}
+
+	// Plain form: settings separated by whitespace or colons up to the end
+	return split(rest, " \t:")
+}
+
+// Find returns the settings of the modelines in the first and last lines
+// of a file, later settings overriding earlier ones. It returns nil when
+// modelines are disabled.
+func Find(lines []string, count int) []string {
+	if !enabled {
+		return nil
+	}
+
+	var settings []string
+	for i := 0; i < len(lines); i++ {
+		if i == count && len(lines) > 2*count {
+			i = len(lines) - count
+		}
+		settings = append(settings, Parse(lines[i])...)
+	}
+	return settings
+}
+
+// unescapedColon returns the index of the first colon not preceded by a
+// backslash, or -1
+func unescapedColon(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == ':' {
+			return i
+		}
+	}
+	return -1
+}
+
+// split splits s at any of seps, dropping empty fields and unescaping "\:"
+func split(s, seps string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ':':
+			field.WriteByte(':')
+			i++
+		case strings.IndexByte(seps, s[i]) >= 0:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteByte(s[i])
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
@@ -0,0 +1,47 @@
+package modeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"// vim: ts=4 sw=4 et", []string{"ts=4", "sw=4", "et"}},
+		{"vim:ts=4:sw=4", []string{"ts=4", "sw=4"}},
+		{"/* vim: set ft=c tw=80: */", []string{"ft=c", "tw=80"}},
+		{"# vi: set noet :", []string{"noet"}},
+		{"# ex: se ts=2: trailing text", []string{"ts=2"}},
+		{`# vim: set fdm=marker fmr=a\:b:`, []string{"fdm=marker", "fmr=a:b"}},
+		{"# vim: set ts=4", nil}, // set form without the closing colon
+		{"novim: ts=4", nil},     // vim: must follow whitespace
+		{"plain text", nil},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	lines := make([]string, 20)
+	lines[0] = "# vim: ts=2"
+	lines[10] = "# vim: ts=3"
+	lines[19] = "# vim: ts=8 et"
+
+	got := Find(lines, DefaultLines)
+	want := []string{"ts=2", "ts=8", "et"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find = %q, want %q", got, want)
+	}
+
+	SetEnabled(false)
+	defer SetEnabled(true)
+	if got := Find(lines, DefaultLines); got != nil {
+		t.Errorf("Find with modelines disabled = %q", got)
+	}
+}
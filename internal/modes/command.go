@@ -1,16 +1,31 @@
 package modes
 
 import (
+	"cmp"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/dshills/aied/internal/buffer"
 	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/expr"
+	"github.com/dshills/aied/internal/registers"
 	"github.com/dshills/aied/internal/ui"
 )
 
 // CommandMode implements VIM command mode behavior (:commands)
 type CommandMode struct {
 	commandLine string                    // Current command being typed
+	cursor      int                       // Byte offset of the cursor in commandLine
 	executor    *commands.CommandExecutor // Command executor
 	message     string                    // Last command result message
+	nextLine    string                    // Command line to start with when next entered
+	nextMessage string                    // Message to show when next entered
+	prompt      rune                      // ':' for ex commands, '/' or '?' for searches, '=' for Ctrl-R =
+	nextPrompt  rune                      // Prompt to start with when next entered
+	searchCount int                       // Match a search goes to
+	ctx         *Context                  // Shows where a search went
+	registers   *registers.Store          // Read by Ctrl-R
+	ctrlR       bool                      // Ctrl-R pressed, waiting for the register to insert
 }
 
 // NewCommandMode creates a new command mode instance
@@ -19,6 +34,7 @@ func NewCommandMode() *CommandMode {
 		commandLine: "",
 		executor:    commands.NewCommandExecutor(),
 		message:     "",
+		prompt:      ':',
 	}
 }
 
@@ -29,34 +45,47 @@ func (c *CommandMode) Type() ModeType {
 
 // HandleInput processes keyboard input in command mode
 func (c *CommandMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	// Ctrl-R inserts the register named by the next key
+	if c.ctrlR {
+		c.ctrlR = false
+		if event.Action == ui.KeyActionChar && registers.IsReadable(event.Rune) && c.registers != nil {
+			if reg, ok := c.registers.Get(event.Rune); ok {
+				c.insert(strings.ReplaceAll(reg.Text, "\n", " "))
+			}
+		}
+		return ModeResult{Handled: true}
+	}
+
+	// Move the cursor and delete within the line
+	if c.editLine(event.Action) {
+		return ModeResult{Handled: true}
+	}
+
 	switch event.Action {
 	case ui.KeyActionEscape:
 		// Cancel command mode
-		c.commandLine = ""
+		c.setLine("")
 		c.message = ""
-		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+		return ModeResult{SwitchToMode: c.returnMode(), Handled: true}
 
 	case ui.KeyActionEnter:
 		// Execute the command
 		return c.executeCommand(buf)
 
-	case ui.KeyActionBackspace:
-		// Remove last character from command line
-		if len(c.commandLine) > 0 {
-			c.commandLine = c.commandLine[:len(c.commandLine)-1]
-		}
-		return ModeResult{Handled: true}
-
 	case ui.KeyActionChar:
-		// Add character to command line
-		c.commandLine += string(event.Rune)
+		// Add character to command line at the cursor
+		c.insert(string(event.Rune))
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionCtrlC:
 		// Cancel command mode
-		c.commandLine = ""
+		c.setLine("")
 		c.message = ""
-		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+		return ModeResult{SwitchToMode: c.returnMode(), Handled: true}
+
+	case ui.KeyActionCtrlR:
+		c.ctrlR = true
+		return ModeResult{Handled: true}
 
 	default:
 		return ModeResult{Handled: false}
@@ -65,6 +94,17 @@ func (c *CommandMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeRes
 
 // executeCommand executes the current command line
 func (c *CommandMode) executeCommand(buf *buffer.Buffer) ModeResult {
+	if c.prompt == '=' {
+		return c.insertExpression(buf)
+	}
+	if c.prompt != ':' {
+		message := searchPattern(buf, c.commandLine, c.prompt == '?', c.searchCount)
+		c.setLine("")
+		if c.ctx != nil {
+			c.ctx.Message(message)
+		}
+		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+	}
 	if c.commandLine == "" {
 		// Empty command, just return to normal mode
 		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
@@ -77,7 +117,7 @@ func (c *CommandMode) executeCommand(buf *buffer.Buffer) ModeResult {
 	c.message = result.Message
 	
 	// Clear the command line
-	c.commandLine = ""
+	c.setLine("")
 
 	// Handle the result
 	if result.ExitEditor {
@@ -98,20 +138,115 @@ func (c *CommandMode) executeCommand(buf *buffer.Buffer) ModeResult {
 	return ModeResult{Handled: true}
 }
 
+// insertExpression inserts the value of the expression on the command
+// line for Ctrl-R = and goes back to insert mode
+func (c *CommandMode) insertExpression(buf *buffer.Buffer) ModeResult {
+	line := c.commandLine
+	c.setLine("")
+	if strings.TrimSpace(line) != "" {
+		value, err := expr.Eval(line)
+		if err != nil && c.ctx != nil {
+			c.ctx.Message("Invalid expression: " + err.Error())
+		} else if err == nil {
+			insertText(buf, value)
+		}
+	}
+	return ModeResult{SwitchToMode: c.returnMode(), Handled: true}
+}
+
+// returnMode returns the mode to go back to when the command line is done:
+// insert mode for an expression, normal mode otherwise
+func (c *CommandMode) returnMode() *ModeType {
+	if c.prompt == '=' {
+		return &[]ModeType{ModeInsert}[0]
+	}
+	return &[]ModeType{ModeNormal}[0]
+}
+
+// RepeatLast executes the last command line again, for @:
+func (c *CommandMode) RepeatLast(buf *buffer.Buffer) ModeResult {
+	result := c.executor.RepeatLast(buf)
+	c.message = result.Message
+	return ModeResult{ExitEditor: result.ExitEditor, Handled: true}
+}
+
+// SetContext runs commands with the context's executor, so they share @:
+// with commands run from other modes
+func (c *CommandMode) SetContext(ctx *Context) {
+	c.executor = ctx.executor
+	c.ctx = ctx
+}
+
+// SetRegisters sets the register store read by Ctrl-R
+func (c *CommandMode) SetRegisters(store *registers.Store) {
+	c.registers = store
+}
+
 // OnEnter is called when entering command mode
 func (c *CommandMode) OnEnter(buf *buffer.Buffer) {
 	if buf == nil {
 		return
 	}
-	// Clear any previous command and message
-	c.commandLine = ""
-	c.message = ""
+	// Clear any previous command and message, unless another mode started
+	// this one with them
+	c.setLine(c.nextLine)
+	c.message = c.nextMessage
+	c.prompt = cmp.Or(c.nextPrompt, ':')
+	c.nextLine, c.nextMessage, c.nextPrompt = "", "", 0
+}
+
+// startWith makes command mode start with line and message the next time
+// it is entered
+func (c *CommandMode) startWith(line, message string) {
+	c.nextLine, c.nextMessage = line, message
+}
+
+// startSearch makes command mode read a pattern to search for the next
+// time it is entered, going to the count'th match after the cursor, or
+// before it when backward
+func (c *CommandMode) startSearch(backward bool, count int) {
+	c.nextPrompt = '/'
+	if backward {
+		c.nextPrompt = '?'
+	}
+	c.searchCount = count
+}
+
+// startExpression makes command mode read an expression to insert the
+// value of the next time it is entered, for Ctrl-R = in insert mode
+func (c *CommandMode) startExpression() {
+	c.nextPrompt = '='
+}
+
+// run executes line as if typed, for commands started from other modes. A
+// line ending in a space, or a command asking for arguments it wasn't
+// given, is left on the command line to finish. A command leaving a
+// message shows it in command mode.
+func (c *CommandMode) run(line string, buf *buffer.Buffer) ModeResult {
+	toCommandLine := ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
+	if strings.HasSuffix(line, " ") {
+		c.startWith(line, "")
+		return toCommandLine
+	}
+
+	c.setLine(line)
+	result := c.executeCommand(buf)
+	if strings.HasPrefix(c.message, "Usage:") {
+		c.startWith(line+" ", "")
+		return toCommandLine
+	}
+	if result.SwitchToMode == nil && !result.ExitEditor {
+		c.startWith("", c.message)
+		return toCommandLine
+	}
+	return result
 }
 
 // OnExit is called when leaving command mode
 func (c *CommandMode) OnExit(buf *buffer.Buffer) {
 	// Clear command line when leaving command mode
-	c.commandLine = ""
+	c.setLine("")
+	c.ctrlR = false
 }
 
 // GetStatusText returns mode-specific status information
@@ -121,7 +256,18 @@ func (c *CommandMode) GetStatusText() string {
 
 // GetCommandLine returns the current command line being typed
 func (c *CommandMode) GetCommandLine() string {
-	return ":" + c.commandLine
+	if c.ctrlR {
+		// Ctrl-R shows " where the register will go
+		cursor := min(c.cursor, len(c.commandLine))
+		return string(c.prompt) + c.commandLine[:cursor] + "\"" + c.commandLine[cursor:]
+	}
+	return string(c.prompt) + c.commandLine
+}
+
+// CommandCursor returns the column of the cursor in the command line
+// GetCommandLine returns, counting characters
+func (c *CommandMode) CommandCursor() int {
+	return 1 + utf8.RuneCountInString(c.commandLine[:min(c.cursor, len(c.commandLine))])
 }
 
 // GetMessage returns the last command result message
@@ -0,0 +1,91 @@
+package modes
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/ui"
+)
+
+// setLine replaces the command line with line, the cursor at its end
+func (c *CommandMode) setLine(line string) {
+	c.commandLine = line
+	c.cursor = len(line)
+}
+
+// insert puts text on the command line at the cursor, and the cursor after it
+func (c *CommandMode) insert(text string) {
+	c.cursor = min(c.cursor, len(c.commandLine))
+	c.commandLine = c.commandLine[:c.cursor] + text + c.commandLine[c.cursor:]
+	c.cursor += len(text)
+}
+
+// deleteBefore removes the command line from from up to the cursor
+func (c *CommandMode) deleteBefore(from int) {
+	c.cursor = min(c.cursor, len(c.commandLine))
+	c.commandLine = c.commandLine[:from] + c.commandLine[c.cursor:]
+	c.cursor = from
+}
+
+// editLine handles the keys that move the cursor within the command line
+// and delete around it, reporting whether key was one of them
+func (c *CommandMode) editLine(key ui.KeyAction) bool {
+	line := c.commandLine
+	c.cursor = min(c.cursor, len(line))
+	switch key {
+	case ui.KeyActionBackspace:
+		if c.cursor > 0 {
+			_, size := utf8.DecodeLastRuneInString(line[:c.cursor])
+			c.deleteBefore(c.cursor - size)
+		}
+	case ui.KeyActionDelete:
+		if c.cursor < len(line) {
+			_, size := utf8.DecodeRuneInString(line[c.cursor:])
+			c.commandLine = line[:c.cursor] + line[c.cursor+size:]
+		}
+	case ui.KeyActionLeft:
+		if c.cursor > 0 {
+			_, size := utf8.DecodeLastRuneInString(line[:c.cursor])
+			c.cursor -= size
+		}
+	case ui.KeyActionRight:
+		if c.cursor < len(line) {
+			_, size := utf8.DecodeRuneInString(line[c.cursor:])
+			c.cursor += size
+		}
+	case ui.KeyActionHome:
+		c.cursor = 0
+	case ui.KeyActionEnd:
+		c.cursor = len(line)
+	case ui.KeyActionCtrlW:
+		c.deleteBefore(wordStartBefore(line, c.cursor))
+	case ui.KeyActionCtrlU:
+		c.deleteBefore(0)
+	default:
+		return false
+	}
+	return true
+}
+
+// wordStartBefore returns where Ctrl-W deletes back to from col in line:
+// past whitespace, then a run of identifier characters or of punctuation
+func wordStartBefore(line string, col int) int {
+	before := func() rune {
+		r, _ := utf8.DecodeLastRuneInString(line[:col])
+		return r
+	}
+	back := func(keep func(rune) bool) {
+		for col > 0 && keep(before()) {
+			_, size := utf8.DecodeLastRuneInString(line[:col])
+			col -= size
+		}
+	}
+
+	back(unicode.IsSpace)
+	if col > 0 && isIdentifierChar(before()) {
+		back(isIdentifierChar)
+	} else {
+		back(func(r rune) bool { return !isIdentifierChar(r) && !unicode.IsSpace(r) })
+	}
+	return col
+}
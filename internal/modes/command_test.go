@@ -157,4 +157,36 @@ func TestCommandMode_GetStatusText(t *testing.T) {
 	if mode.GetStatusText() != expected {
 		t.Errorf("expected status text %q, got %q", expected, mode.GetStatusText())
 	}
-}
\ No newline at end of file
+}
+func TestCommandMode_LineEditing(t *testing.T) {
+	mode := NewCommandMode()
+	buf := buffer.New()
+	key := func(action ui.KeyAction) { mode.HandleInput(ui.KeyEvent{Action: action}, buf) }
+	typeKeys(mode, buf, "s/foo/bär")
+
+	tests := []struct {
+		keys   []ui.KeyAction
+		typed  string
+		want   string
+		cursor int
+	}{
+		{[]ui.KeyAction{ui.KeyActionLeft, ui.KeyActionLeft, ui.KeyActionLeft}, "", ":s/foo/bär", 7},
+		{[]ui.KeyAction{ui.KeyActionLeft}, "x", ":s/foox/bär", 7},
+		{[]ui.KeyAction{ui.KeyActionCtrlW}, "", ":s//bär", 3},
+		{[]ui.KeyAction{ui.KeyActionDelete}, "", ":s/bär", 3},
+		{[]ui.KeyAction{ui.KeyActionBackspace, ui.KeyActionRight}, "", ":sbär", 3},
+		{[]ui.KeyAction{ui.KeyActionHome}, "%", ":%sbär", 2},
+		{[]ui.KeyAction{ui.KeyActionEnd, ui.KeyActionLeft, ui.KeyActionLeft}, "", ":%sbär", 4},
+		{[]ui.KeyAction{ui.KeyActionCtrlU}, "", ":är", 1},
+	}
+	for _, tt := range tests {
+		for _, k := range tt.keys {
+			key(k)
+		}
+		typeKeys(mode, buf, tt.typed)
+		if got := mode.GetCommandLine(); got != tt.want || mode.CommandCursor() != tt.cursor {
+			t.Errorf("after %v %q the line is %q with the cursor at %d, want %q at %d",
+				tt.keys, tt.typed, got, mode.CommandCursor(), tt.want, tt.cursor)
+		}
+	}
+}
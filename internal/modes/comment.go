@@ -0,0 +1,140 @@
+package modes
+
+import (
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// CommentStyle describes how a language writes comments. Line comments are
+// preferred; BlockStart/BlockEnd are used when a language has no line comment.
+type CommentStyle struct {
+	Line       string
+	BlockStart string
+	BlockEnd   string
+}
+
+// DefaultCommentStyles are the built-in comment definitions keyed by filetype
+var DefaultCommentStyles = map[string]CommentStyle{
+	"go":         {Line: "//"},
+	"c":          {Line: "//"},
+	"cpp":        {Line: "//"},
+	"java":       {Line: "//"},
+	"javascript": {Line: "//"},
+	"typescript": {Line: "//"},
+	"rust":       {Line: "//"},
+	"swift":      {Line: "//"},
+	"kotlin":     {Line: "//"},
+	"php":        {Line: "//"},
+	"python":     {Line: "#"},
+	"ruby":       {Line: "#"},
+	"bash":       {Line: "#"},
+	"yaml":       {Line: "#"},
+	"toml":       {Line: "#"},
+	"lua":        {Line: "--"},
+	"sql":        {Line: "--"},
+	"haskell":    {Line: "--"},
+	"vim":        {Line: "\""},
+	"css":        {BlockStart: "/*", BlockEnd: "*/"},
+	"html":       {BlockStart: "<!--", BlockEnd: "-->"},
+	"xml":        {BlockStart: "<!--", BlockEnd: "-->"},
+	"markdown":   {BlockStart: "<!--", BlockEnd: "-->"},
+}
+
+// commentStyleFor returns the comment style for the buffer's filetype,
+// preferring configured styles over the defaults
+func commentStyleFor(buf *buffer.Buffer, styles map[string]CommentStyle) (CommentStyle, bool) {
+	filetype := buf.Filetype()
+	if style, ok := styles[filetype]; ok {
+		return style, true
+	}
+	style, ok := DefaultCommentStyles[filetype]
+	return style, ok
+}
+
+// toggleComments comments lines start..end (inclusive), or uncomments them
+// if every non-blank line is already commented. Blank lines are left alone.
+func toggleComments(buf *buffer.Buffer, start, end int, style CommentStyle) {
+	var lines []string
+	for i := start; i <= end; i++ {
+		line, err := buf.Line(i)
+		if err != nil {
+			return
+		}
+		lines = append(lines, line)
+	}
+
+	if allCommented(lines, style) {
+		for i, line := range lines {
+			lines[i] = uncommentLine(line, style)
+		}
+	} else {
+		// Comment markers line up at the shallowest indentation
+		indent := -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if indent < 0 || indentEnd(line) < indent {
+				indent = indentEnd(line)
+			}
+		}
+		for i, line := range lines {
+			if strings.TrimSpace(line) != "" {
+				lines[i] = commentLine(line, indent, style)
+			}
+		}
+	}
+
+	buf.ReplaceLines(start, end, lines)
+	buf.SetCursor(buffer.Position{Line: start, Col: indentEnd(lines[0])})
+}
+
+// allCommented reports whether every non-blank line is commented
+func allCommented(lines []string, style CommentStyle) bool {
+	found := false
+	for _, line := range lines {
+		text := strings.TrimSpace(line)
+		if text == "" {
+			continue
+		}
+		found = true
+		if style.Line != "" {
+			if !strings.HasPrefix(text, style.Line) {
+				return false
+			}
+		} else if !strings.HasPrefix(text, style.BlockStart) || !strings.HasSuffix(text, style.BlockEnd) {
+			return false
+		}
+	}
+	return found
+}
+
+// commentLine inserts comment markers into line at byte offset indent
+func commentLine(line string, indent int, style CommentStyle) string {
+	if style.Line != "" {
+		return line[:indent] + style.Line + " " + line[indent:]
+	}
+	return line[:indent] + style.BlockStart + " " + strings.TrimRight(line[indent:], " \t") + " " + style.BlockEnd
+}
+
+// uncommentLine removes comment markers, and the space next to them, from line
+func uncommentLine(line string, style CommentStyle) string {
+	indent := indentEnd(line)
+	text := line[indent:]
+	if text == "" {
+		return line
+	}
+
+	if style.Line != "" {
+		text = strings.TrimPrefix(text, style.Line)
+		return line[:indent] + strings.TrimPrefix(text, " ")
+	}
+
+	text = strings.TrimRight(text, " \t")
+	text = strings.TrimPrefix(text, style.BlockStart)
+	text = strings.TrimSuffix(text, style.BlockEnd)
+	text = strings.TrimPrefix(text, " ")
+	text = strings.TrimSuffix(text, " ")
+	return line[:indent] + text
+}
@@ -0,0 +1,91 @@
+package modes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestToggleComments(t *testing.T) {
+	goStyle := CommentStyle{Line: "//"}
+	htmlStyle := CommentStyle{BlockStart: "<!--", BlockEnd: "-->"}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		style    CommentStyle
+		expected []string
+	}{
+		{
+			"comment at shallowest indent",
+			[]string{"\tif x {", "\t\ty()", "", "\t}"},
+			goStyle,
+			[]string{"\t// if x {", "\t// \ty()", "", "\t// }"},
+		},
+		{
+			"uncomment",
+			[]string{"  // a", "  //b"},
+			goStyle,
+			[]string{"  a", "  b"},
+		},
+		{
+			"partially commented gets commented",
+			[]string{"// a", "b"},
+			goStyle,
+			[]string{"// // a", "// b"},
+		},
+		{
+			"block comment",
+			[]string{"  <p>hi</p>"},
+			htmlStyle,
+			[]string{"  <!-- <p>hi</p> -->"},
+		},
+		{
+			"block uncomment",
+			[]string{"  <!-- <p>hi</p> -->"},
+			htmlStyle,
+			[]string{"  <p>hi</p>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newOperatorTestBuffer(tt.lines...)
+			toggleComments(buf, 0, len(tt.lines)-1, tt.style)
+			if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalMode_CommentOperator(t *testing.T) {
+	mode := NewNormalMode()
+	buf := newOperatorTestBuffer("x := 1", "y := 2", "z := 3")
+	buf.SetFilename("main.go")
+
+	typeKeys(mode, buf, "gcj")
+	expected := []string{"// x := 1", "// y := 2", "z := 3"}
+	if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	typeKeys(mode, buf, "gcc")
+	if line, _ := buf.Line(0); line != "x := 1" {
+		t.Errorf("expected gcc to uncomment, got %q", line)
+	}
+}
+
+func TestNormalMode_CommentOperatorConfiguredStyle(t *testing.T) {
+	mode := NewNormalMode()
+	mode.SetCommentStyles(map[string]CommentStyle{"go": {Line: "#"}})
+	buf := newOperatorTestBuffer("x := 1")
+	buf.SetFilename("main.go")
+	buf.SetCursor(buffer.Position{})
+
+	typeKeys(mode, buf, "gcc")
+	if buf.CurrentLine() != "# x := 1" {
+		t.Errorf("expected configured comment string, got %q", buf.CurrentLine())
+	}
+}
@@ -0,0 +1,200 @@
+package modes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// completionSource gathers completions for the text before the cursor and
+// returns them with the column where the text they replace starts
+type completionSource func(buf *buffer.Buffer) ([]CompletionItem, int)
+
+// BufferProvider returns the open buffers searched for word completion
+type BufferProvider func() []*buffer.Buffer
+
+// SetBufferProvider sets the buffers searched by Ctrl-N/Ctrl-P word completion
+func (i *InsertMode) SetBufferProvider(provider BufferProvider) {
+	i.buffers = provider
+}
+
+// startLocalCompletion shows completions from source. The source stays active
+// so the list is filtered again as the user keeps typing.
+func (i *InsertMode) startLocalCompletion(buf *buffer.Buffer, source completionSource, selectLast bool) {
+	i.source = source
+	i.refreshCompletion(buf)
+	if selectLast {
+		i.popup.Prev()
+	}
+}
+
+// refreshCompletion re-runs the active completion source
+func (i *InsertMode) refreshCompletion(buf *buffer.Buffer) {
+	if i.source == nil {
+		return
+	}
+	items, start := i.source(buf)
+	if len(items) == 0 {
+		i.hideCompletion()
+		return
+	}
+	i.popup.SetItems(items)
+	i.completionStart = start
+}
+
+// fallbackCompletion picks a completion source when no LSP server is available:
+// paths when the text before the cursor looks like one, otherwise buffer words
+func (i *InsertMode) fallbackCompletion(buf *buffer.Buffer) completionSource {
+	line := buf.CurrentLine()
+	col := min(buf.Cursor().Col, len(line))
+	if looksLikePath(line[pathStart(line, col):col]) {
+		return pathCompletions
+	}
+	return i.wordCompletions
+}
+
+// wordCompletions offers words from the open buffers that start with the
+// identifier before the cursor. Words in the current buffer come first,
+// ordered by distance after the cursor, wrapping around the end of the buffer.
+func (i *InsertMode) wordCompletions(buf *buffer.Buffer) ([]CompletionItem, int) {
+	cursor := buf.Cursor()
+	line := buf.CurrentLine()
+	col := min(cursor.Col, len(line))
+	start := col
+	for start > 0 && isIdentifierChar(rune(line[start-1])) {
+		start--
+	}
+	prefix := line[start:col]
+
+	seen := map[string]bool{prefix: true}
+	var items []CompletionItem
+	collect := func(b *buffer.Buffer, lineNum int, skipCol int) {
+		text, err := b.Line(lineNum)
+		if err != nil {
+			return
+		}
+		for _, w := range lineWords(text) {
+			if w.Start <= skipCol && skipCol <= w.End {
+				// The word being typed
+				continue
+			}
+			if seen[w.Word] || !strings.HasPrefix(w.Word, prefix) {
+				continue
+			}
+			seen[w.Word] = true
+			items = append(items, CompletionItem{Label: w.Word, InsertText: w.Word, Kind: "Word"})
+		}
+	}
+
+	lineCount := buf.LineCount()
+	for n := 0; n < lineCount; n++ {
+		lineNum := (cursor.Line + n) % lineCount
+		skip := -1
+		if n == 0 {
+			skip = col
+		}
+		collect(buf, lineNum, skip)
+	}
+
+	if i.buffers != nil {
+		for _, other := range i.buffers() {
+			if other == nil || other == buf {
+				continue
+			}
+			for lineNum := 0; lineNum < other.LineCount(); lineNum++ {
+				collect(other, lineNum, -1)
+			}
+		}
+	}
+	return items, start
+}
+
+// lineWord is an identifier and its byte span within a line
+type lineWord struct {
+	Word       string
+	Start, End int
+}
+
+// lineWords splits a line into identifier words
+func lineWords(line string) []lineWord {
+	var words []lineWord
+	for col := 0; col < len(line); {
+		if !isIdentifierChar(rune(line[col])) {
+			col++
+			continue
+		}
+		end := col
+		for end < len(line) && isIdentifierChar(rune(line[end])) {
+			end++
+		}
+		words = append(words, lineWord{Word: line[col:end], Start: col, End: end})
+		col = end
+	}
+	return words
+}
+
+// pathCompletions lists the directory entries matching the path before the
+// cursor. Directories get a trailing slash so completion can continue into them.
+func pathCompletions(buf *buffer.Buffer) ([]CompletionItem, int) {
+	line := buf.CurrentLine()
+	col := min(buf.Cursor().Col, len(line))
+	token := line[pathStart(line, col):col]
+
+	dir, base := "", token
+	if idx := strings.LastIndex(token, "/"); idx >= 0 {
+		dir, base = token[:idx+1], token[idx+1:]
+	}
+
+	entries, err := os.ReadDir(expandHome(dir))
+	if err != nil {
+		return nil, col
+	}
+
+	var items []CompletionItem
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		item := CompletionItem{Label: name, InsertText: name, Kind: "File"}
+		if entry.IsDir() {
+			item.Label += "/"
+			item.InsertText += "/"
+			item.Kind = "Dir"
+		}
+		items = append(items, item)
+	}
+	return items, col - len(base)
+}
+
+// pathStart finds the start of the path-like text ending at col
+func pathStart(line string, col int) int {
+	start := col
+	for start > 0 && !strings.ContainsRune(" \t\"'`()[]{}<>,;=", rune(line[start-1])) {
+		start--
+	}
+	return start
+}
+
+// looksLikePath reports whether text is worth completing as a file path
+func looksLikePath(text string) bool {
+	return strings.Contains(text, "/") || strings.HasPrefix(text, "~") || strings.HasPrefix(text, ".")
+}
+
+// expandHome resolves a leading ~ and an empty directory to the working directory
+func expandHome(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, dir[1:])
+		}
+	}
+	return dir
+}
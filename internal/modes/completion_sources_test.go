@@ -0,0 +1,109 @@
+package modes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func completionLabels(mode *InsertMode) []string {
+	var labels []string
+	for _, item := range mode.Completion().Items() {
+		labels = append(labels, item.Label)
+	}
+	return labels
+}
+
+func TestInsertMode_WordCompletion(t *testing.T) {
+	mode := NewInsertMode()
+	buf := newOperatorTestBuffer("alpha beta", "al", "alphabet alpha gamma")
+	buf.SetCursor(buffer.Position{Line: 1, Col: 2})
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlN}, buf)
+	labels := completionLabels(mode)
+	if len(labels) != 2 || labels[0] != "alphabet" || labels[1] != "alpha" {
+		t.Fatalf("expected [alphabet alpha], got %q", labels)
+	}
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlN}, buf)
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if line := buf.CurrentLine(); line != "alpha" {
+		t.Errorf("expected completed word, got %q", line)
+	}
+	if mode.Completion().IsVisible() {
+		t.Error("expected completion to close after accepting")
+	}
+}
+
+func TestInsertMode_WordCompletionCtrlPSelectsLast(t *testing.T) {
+	mode := NewInsertMode()
+	buf := newOperatorTestBuffer("foo fob", "fo")
+	buf.SetCursor(buffer.Position{Line: 1, Col: 2})
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlP}, buf)
+	items, selected := mode.Completion().Items(), mode.Completion().Selected()
+	if len(items) != 2 || items[selected].Label != "fob" {
+		t.Fatalf("expected fob selected, got %v at %d", items, selected)
+	}
+}
+
+func TestInsertMode_WordCompletionOtherBuffers(t *testing.T) {
+	other := newOperatorTestBuffer("network netmask")
+	mode := NewInsertMode()
+	buf := newOperatorTestBuffer("ne")
+	buf.SetCursor(buffer.Position{Line: 0, Col: 2})
+	mode.SetBufferProvider(func() []*buffer.Buffer { return []*buffer.Buffer{buf, other} })
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlN}, buf)
+	labels := completionLabels(mode)
+	if len(labels) != 2 || labels[0] != "network" || labels[1] != "netmask" {
+		t.Fatalf("expected words from other buffer, got %q", labels)
+	}
+
+	// Typing narrows the list
+	typeKeys(mode, buf, "tw")
+	labels = completionLabels(mode)
+	if len(labels) != 1 || labels[0] != "network" {
+		t.Errorf("expected list filtered to network, got %q", labels)
+	}
+}
+
+func TestInsertMode_PathCompletion(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "setup.go"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, ".secret"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), nil, 0644)
+
+	mode := NewInsertMode()
+	line := "open(" + dir + "/s"
+	buf := newOperatorTestBuffer(line)
+	buf.SetCursor(buffer.Position{Line: 0, Col: len(line)})
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlX}, buf)
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlF}, buf)
+	labels := completionLabels(mode)
+	if len(labels) != 2 || labels[0] != "setup.go" || labels[1] != "src/" {
+		t.Fatalf("expected [setup.go src/], got %q", labels)
+	}
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionDown}, buf)
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionTab}, buf)
+	if got := buf.CurrentLine(); got != "open("+dir+"/src/" {
+		t.Errorf("expected directory completed, got %q", got)
+	}
+}
+
+func TestInsertMode_CtrlSpaceWithoutLSP(t *testing.T) {
+	mode := NewInsertMode()
+	buf := newOperatorTestBuffer("hello help", "he")
+	buf.SetCursor(buffer.Position{Line: 1, Col: 2})
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlSpace}, buf)
+	if labels := completionLabels(mode); len(labels) != 2 {
+		t.Errorf("expected word completion fallback, got %q", labels)
+	}
+}
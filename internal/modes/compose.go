@@ -0,0 +1,295 @@
+package modes
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// composeHint lists the keys of the composer on its border
+const composeHint = "Ctrl-Enter/Ctrl-S send  Esc close  Ctrl-P/Ctrl-N history  Ctrl-F selection"
+
+// previewHint lists the keys of the composer showing an AI request held
+// for preview
+const previewHint = "Ctrl-D delete line  Ctrl-Enter/Ctrl-S send  Esc close (:aicontext reopens)"
+
+// ComposeMode is the prompt composer opened by :ai, :aiedit and :agent
+// without a prompt: a popup for writing one over several lines. Ctrl-Enter
+// or Ctrl-S sends it, Ctrl-P and Ctrl-N go through the prompts sent before,
+// Ctrl-D deletes a line and Ctrl-F pastes the last visual selection as a
+// code block. Esc closes it, keeping the draft for the next time.
+//
+// With :set aipreview it also shows the prompt and context of each AI
+// request before it is sent, to trim them.
+type ComposeMode struct {
+	ctx      *Context
+	command  string   // The command line sending runs, with the prompt after it
+	lines    []string // The prompt being written
+	line     int      // Line of the cursor
+	col      int      // Byte offset of the cursor in its line
+	history  []string // Prompts sent, oldest first
+	browsing int      // Index in history of the prompt shown, len(history) for the draft
+	draft    []string // The prompt being written while browsing the history
+	preview  bool     // Showing an AI request held for preview
+}
+
+// NewComposeMode creates a prompt composer
+func NewComposeMode() *ComposeMode {
+	return &ComposeMode{lines: []string{""}}
+}
+
+// SetContext sets the editor context the composer runs commands in
+func (c *ComposeMode) SetContext(ctx *Context) {
+	c.ctx = ctx
+}
+
+// Type returns the mode type
+func (c *ComposeMode) Type() ModeType {
+	return ModeCompose
+}
+
+// HandleInput edits the prompt, sends it or closes the composer
+func (c *ComposeMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	toNormal := ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+	switch event.Action {
+	case ui.KeyActionEscape, ui.KeyActionCtrlC:
+		return toNormal
+	case ui.KeyActionCtrlEnter, ui.KeyActionCtrlS:
+		if c.send(buf) {
+			return toNormal
+		}
+	case ui.KeyActionChar:
+		c.insert(string(event.Rune))
+	case ui.KeyActionEnter:
+		c.splitLine()
+	case ui.KeyActionBackspace:
+		if c.col == 0 && c.line > 0 {
+			c.line--
+			c.col = len(c.lines[c.line])
+			c.joinLine()
+		} else if c.col > 0 {
+			_, size := utf8.DecodeLastRuneInString(c.lines[c.line][:c.col])
+			c.deleteBefore(c.col - size)
+		}
+	case ui.KeyActionDelete:
+		if line := c.lines[c.line]; c.col < len(line) {
+			_, size := utf8.DecodeRuneInString(line[c.col:])
+			c.lines[c.line] = line[:c.col] + line[c.col+size:]
+		} else {
+			c.joinLine()
+		}
+	case ui.KeyActionCtrlW:
+		c.deleteBefore(wordStartBefore(c.lines[c.line], c.col))
+	case ui.KeyActionCtrlU:
+		c.deleteBefore(0)
+	case ui.KeyActionCtrlD:
+		c.deleteLine()
+	case ui.KeyActionLeft:
+		if c.col > 0 {
+			_, size := utf8.DecodeLastRuneInString(c.lines[c.line][:c.col])
+			c.col -= size
+		}
+	case ui.KeyActionRight:
+		if line := c.lines[c.line]; c.col < len(line) {
+			_, size := utf8.DecodeRuneInString(line[c.col:])
+			c.col += size
+		}
+	case ui.KeyActionUp:
+		c.moveLine(-1)
+	case ui.KeyActionDown:
+		c.moveLine(1)
+	case ui.KeyActionHome:
+		c.col = 0
+	case ui.KeyActionEnd:
+		c.col = len(c.lines[c.line])
+	case ui.KeyActionCtrlP:
+		if !c.preview {
+			c.browse(-1)
+		}
+	case ui.KeyActionCtrlN:
+		if !c.preview {
+			c.browse(1)
+		}
+	case ui.KeyActionCtrlF:
+		c.pasteSelection(buf)
+	}
+	return ModeResult{Handled: true}
+}
+
+// insert puts text at the cursor, and the cursor after it
+func (c *ComposeMode) insert(text string) {
+	line := c.lines[c.line]
+	c.lines[c.line] = line[:c.col] + text + line[c.col:]
+	c.col += len(text)
+}
+
+// deleteBefore removes the cursor's line from from up to the cursor
+func (c *ComposeMode) deleteBefore(from int) {
+	line := c.lines[c.line]
+	c.lines[c.line] = line[:from] + line[c.col:]
+	c.col = from
+}
+
+// splitLine breaks the line at the cursor, which goes to the new line
+func (c *ComposeMode) splitLine() {
+	line := c.lines[c.line]
+	c.lines[c.line] = line[:c.col]
+	c.lines = slices.Insert(c.lines, c.line+1, line[c.col:])
+	c.line, c.col = c.line+1, 0
+}
+
+// deleteLine removes the cursor's line, or empties it when it is the only
+// one
+func (c *ComposeMode) deleteLine() {
+	if len(c.lines) == 1 {
+		c.lines[0], c.col = "", 0
+		return
+	}
+	c.lines = slices.Delete(c.lines, c.line, c.line+1)
+	c.line = min(c.line, len(c.lines)-1)
+	c.col = min(c.col, len(c.lines[c.line]))
+	for c.col > 0 && c.col < len(c.lines[c.line]) && !utf8.RuneStart(c.lines[c.line][c.col]) {
+		c.col--
+	}
+}
+
+// joinLine joins the line after the cursor's to it
+func (c *ComposeMode) joinLine() {
+	if c.line+1 < len(c.lines) {
+		c.lines[c.line] += c.lines[c.line+1]
+		c.lines = slices.Delete(c.lines, c.line+1, c.line+2)
+	}
+}
+
+// moveLine moves the cursor by lines, keeping its column where it can
+func (c *ComposeMode) moveLine(by int) {
+	line := min(max(c.line+by, 0), len(c.lines)-1)
+	if line == c.line {
+		return
+	}
+	chars := utf8.RuneCountInString(c.lines[c.line][:c.col])
+	c.line, c.col = line, 0
+	for i := 0; i < chars && c.col < len(c.lines[line]); i++ {
+		_, size := utf8.DecodeRuneInString(c.lines[line][c.col:])
+		c.col += size
+	}
+}
+
+// setText replaces the prompt with text, the cursor at its end
+func (c *ComposeMode) setText(text string) {
+	c.lines = strings.Split(text, "\n")
+	c.line = len(c.lines) - 1
+	c.col = len(c.lines[c.line])
+}
+
+// browse shows a prompt sent before, by steps back or forward from the
+// one shown, or the draft again past the last
+func (c *ComposeMode) browse(by int) {
+	to := min(max(c.browsing+by, 0), len(c.history))
+	if to == c.browsing {
+		return
+	}
+	if c.browsing == len(c.history) {
+		c.draft = c.lines
+	}
+	c.browsing = to
+	if to == len(c.history) {
+		c.setText(strings.Join(c.draft, "\n"))
+		return
+	}
+	c.setText(c.history[to])
+}
+
+// pasteSelection puts the lines of the last visual selection in buf below
+// the cursor's line as a code block, with an empty line after it to go on
+// writing
+func (c *ComposeMode) pasteSelection(buf *buffer.Buffer) {
+	start, end, ok := buf.VisualArea()
+	if !ok {
+		c.message("No selection to paste")
+		return
+	}
+	block := commands.FencedLines(buf, start.Line, end.Line)
+	at := c.line + 1
+	if strings.TrimSpace(c.lines[c.line]) == "" {
+		// In place of an empty line
+		at = c.line
+		c.lines = slices.Delete(c.lines, at, at+1)
+	}
+	c.lines = slices.Insert(c.lines, at, append(block, "")...)
+	c.line, c.col = at+len(block), 0
+}
+
+// send runs the command with the prompt, reporting whether there was one
+func (c *ComposeMode) send(buf *buffer.Buffer) bool {
+	prompt := strings.TrimSpace(strings.Join(c.lines, "\n"))
+	if prompt == "" {
+		c.message("Nothing to send")
+		return false
+	}
+	if n := len(c.history); !c.preview && (n == 0 || c.history[n-1] != prompt) {
+		c.history = append(c.history, prompt)
+	}
+	c.browsing = len(c.history)
+	c.lines, c.line, c.col = []string{""}, 0, 0
+	if c.ctx != nil {
+		c.ctx.Execute(c.command+" "+prompt, buf)
+	}
+	return true
+}
+
+func (c *ComposeMode) message(text string) {
+	if c.ctx != nil {
+		c.ctx.Message(text)
+	}
+}
+
+// OnEnter opens the composer for the prompt a command left, with its
+// text, or on the draft kept when it is for the same command
+func (c *ComposeMode) OnEnter(buf *buffer.Buffer) {
+	if c.ctx == nil {
+		return
+	}
+	compose := c.ctx.Commands().TakeCompose()
+	if compose == nil {
+		return
+	}
+	if compose.Text != "" || compose.Command != c.command {
+		c.lines = strings.Split(compose.Text, "\n")
+		c.line, c.col = 0, 0
+	}
+	c.command = compose.Command
+	c.preview = compose.Preview
+	c.browsing = len(c.history)
+}
+
+// OnExit closes the composer, keeping the draft
+func (c *ComposeMode) OnExit(buf *buffer.Buffer) {}
+
+// GetStatusText returns mode-specific status information
+func (c *ComposeMode) GetStatusText() string {
+	return "-- COMPOSE --"
+}
+
+// Composer returns the composer for display. A preview shows how many
+// tokens are left to send as it is trimmed.
+func (c *ComposeMode) Composer() *ui.Composer {
+	title, hint := ":"+c.command, composeHint
+	if c.preview {
+		title = fmt.Sprintf("AI request, about %d tokens", ai.EstimateTokens(strings.Join(c.lines, "\n")))
+		hint = previewHint
+	}
+	return &ui.Composer{
+		Title: title,
+		Lines: c.lines,
+		Line:  c.line,
+		Col:   utf8.RuneCountInString(c.lines[c.line][:c.col]),
+		Hint:  hint,
+	}
+}
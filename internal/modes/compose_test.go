@@ -0,0 +1,158 @@
+package modes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestComposeMode_WritesAndSends(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	var prompts []string
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		prompts = append(prompts, req.Prompt)
+		return &ai.AIResponse{Content: "Fine", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	mm := NewModeManager()
+	mm.SetCommandContext(&commands.CommandContext{AI: manager})
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"x := 1", "y := 2"})
+	buf.SetFiletype("go")
+
+	mm.Execute("ai", buf)
+	if mm.CurrentModeType() != ModeCompose || mm.Composer() == nil || mm.Composer().Title != ":ai" {
+		t.Fatalf(":ai without a question switched to %v", mm.CurrentModeType())
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlS}, buf)
+	if mm.CurrentModeType() != ModeCompose || mm.Context().message != "Nothing to send" {
+		t.Errorf("sending nothing gave %q in %v", mm.Context().message, mm.CurrentModeType())
+	}
+
+	typeInto(mm, "Is this", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	typeInto(mm, "fine?", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlEnter}, buf)
+	if mm.CurrentModeType() != ModeNormal || mm.Composer() != nil {
+		t.Fatalf("Ctrl-Enter left the composer in %v", mm.CurrentModeType())
+	}
+	if len(prompts) != 1 || prompts[0] != "Is this\nfine?" {
+		t.Fatalf("sent %q", prompts)
+	}
+
+	// Esc keeps the draft for the next time
+	mm.Execute("ai", buf)
+	typeInto(mm, "draft", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	mm.Execute("ai", buf)
+	if c := mm.Composer(); len(c.Lines) != 1 || c.Lines[0] != "draft" || c.Col != 5 {
+		t.Fatalf("composer reopened on %+v", c)
+	}
+
+	// Ctrl-P brings back the prompt sent, Ctrl-N the draft again
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlP}, buf)
+	if c := mm.Composer(); len(c.Lines) != 2 || c.Lines[1] != "fine?" || c.Line != 1 {
+		t.Errorf("Ctrl-P showed %+v", c)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlN}, buf)
+	if c := mm.Composer(); len(c.Lines) != 1 || c.Lines[0] != "draft" {
+		t.Errorf("Ctrl-N showed %+v", c)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlU}, buf)
+
+	// Ctrl-F pastes the last visual selection as a code block
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlF}, buf)
+	if mm.Context().message != "No selection to paste" {
+		t.Errorf("Ctrl-F without a selection gave %q", mm.Context().message)
+	}
+	buf.SetVisualArea(buffer.Position{Line: 1}, buffer.Position{Line: 1, Col: 5})
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlF}, buf)
+	typeInto(mm, "Why?", buf)
+	want := []string{"```go", "y := 2", "```", "Why?"}
+	c := mm.Composer()
+	if len(c.Lines) != len(want) || c.Line != 3 {
+		t.Fatalf("composer after Ctrl-F = %q, line %d", c.Lines, c.Line)
+	}
+	for i := range want {
+		if c.Lines[i] != want[i] {
+			t.Errorf("composer line %d = %q, want %q", i, c.Lines[i], want[i])
+		}
+	}
+}
+
+func TestComposeMode_Editing(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	mm.modes[ModeCompose].(*ComposeMode).command = "agent"
+	mm.SwitchToMode(ModeCompose, buf)
+
+	typeInto(mm, "ab", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	typeInto(mm, "cd", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionHome}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionBackspace}, buf)
+	if c := mm.Composer(); len(c.Lines) != 1 || c.Lines[0] != "abcd" || c.Col != 2 {
+		t.Fatalf("Backspace at the start of a line gave %+v", c)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionUp}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnd}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionDelete}, buf)
+	typeInto(mm, " é", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionLeft}, buf)
+	if c := mm.Composer(); len(c.Lines) != 1 || c.Lines[0] != "ab écd" || c.Col != 3 {
+		t.Errorf("Delete at the end of a line gave %+v", c)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlW}, buf)
+	if c := mm.Composer(); c.Lines[0] != "écd" {
+		t.Errorf("Ctrl-W gave %q", c.Lines[0])
+	}
+}
+
+func TestComposeMode_Preview(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	var sent []ai.AIRequest
+	provider.SetChatFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		sent = append(sent, req)
+		return &ai.AIResponse{Content: "Because", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	mm := NewModeManager()
+	mm.SetCommandContext(&commands.CommandContext{AI: manager})
+	buf := buffer.New()
+	mm.Execute("set aipreview", buf)
+	mm.Execute("ai Why?", buf)
+	c := mm.Composer()
+	if mm.CurrentModeType() != ModeCompose || !strings.HasPrefix(c.Title, "AI request, about ") || c.Hint != previewHint {
+		t.Fatalf("preview opened %+v in %v", c, mm.CurrentModeType())
+	}
+	if len(sent) != 0 || c.Lines[0] != "Why?" || c.Lines[1] != "--- context ---" {
+		t.Fatalf("preview shows %q, sent %d", c.Lines, len(sent))
+	}
+
+	// Ctrl-D deletes a line, and the count follows
+	title := c.Title
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionDown}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionDown}, buf)
+	line := mm.Composer().Lines[2]
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlD}, buf)
+	if c := mm.Composer(); c.Title == title || c.Lines[2] == line {
+		t.Errorf("Ctrl-D left %q titled %q", c.Lines, c.Title)
+	}
+
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlEnter}, buf)
+	if len(sent) != 1 || strings.Contains(sent[0].Context, line) || mm.CurrentModeType() != ModeNormal {
+		t.Fatalf("sent %+v in %v", sent, mm.CurrentModeType())
+	}
+}
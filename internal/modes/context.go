@@ -0,0 +1,69 @@
+package modes
+
+import (
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// Context is the editor around the modes, for what a mode does beyond
+// editing its buffer: running ex commands, opening windows and telling the
+// user things. Every mode of a ModeManager shares one.
+type Context struct {
+	executor *commands.CommandExecutor // Shared with command mode
+	message  string                    // Shown until the next key
+	mm       *ModeManager              // Plays macros, nil outside a ModeManager
+	viewport func() ui.Viewport        // What the screen shows, nil when unknown
+	reselect bool                      // Set by gv for visual mode to select its last area again
+}
+
+// NewContext creates a context running commands with executor
+func NewContext(executor *commands.CommandExecutor) *Context {
+	return &Context{executor: executor}
+}
+
+// Commands returns what the ex commands keep between command lines, like
+// the help they opened and the pick they left
+func (c *Context) Commands() *commands.State {
+	return c.executor.State()
+}
+
+// Contextual is a mode that uses the editor context. The ModeManager gives
+// it the context when the mode is registered.
+type Contextual interface {
+	SetContext(ctx *Context)
+}
+
+// Execute runs an ex command line on buf, for commands started by keys,
+// and shows its message. A message of more than one line, like hover
+// documentation, opens in a window instead.
+func (c *Context) Execute(line string, buf *buffer.Buffer) commands.CommandResult {
+	result := c.executor.Run(line, buf)
+	if strings.Contains(result.Message, "\n") {
+		c.OpenWindow("["+strings.Fields(line)[0]+"]", result.Message)
+	} else {
+		c.Message(result.Message)
+	}
+	return result
+}
+
+// Message shows message in place of the status line until the next key
+func (c *Context) Message(message string) {
+	c.message = message
+}
+
+// TextWidth returns how many columns of text the screen shows, or 0 when
+// that isn't known
+func (c *Context) TextWidth() int {
+	if c == nil || c.viewport == nil {
+		return 0
+	}
+	return c.viewport().Width
+}
+
+// OpenWindow shows text in a window titled title until :pclose
+func (c *Context) OpenWindow(title, text string) {
+	c.Commands().ShowPanel(title, text)
+}
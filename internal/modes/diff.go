@@ -0,0 +1,35 @@
+package modes
+
+import (
+	"slices"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+)
+
+// moveToDifference moves the cursor to the start of the next or previous
+// difference in diff mode, for ]c and [c
+func moveToDifference(state *commands.State, buf *buffer.Buffer, forward bool) {
+	moveToLine(buf, state.DiffHunkLines(buf), forward)
+}
+
+// moveToConflict moves the cursor to the next or previous conflict marker
+// block, for ]x and [x
+func moveToConflict(buf *buffer.Buffer, forward bool) {
+	moveToLine(buf, commands.ConflictLines(buf), forward)
+}
+
+// moveToLine moves the cursor to the first of starts after it, or the last
+// before it when not forward. Starts are in ascending order.
+func moveToLine(buf *buffer.Buffer, starts []int, forward bool) {
+	cursor := buf.Cursor()
+	if !forward {
+		slices.Reverse(starts)
+	}
+	for _, line := range starts {
+		if forward && line > cursor.Line || !forward && line < cursor.Line {
+			buf.SetCursor(buffer.Position{Line: line})
+			return
+		}
+	}
+}
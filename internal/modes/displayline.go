@@ -0,0 +1,89 @@
+package modes
+
+import (
+	"math"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// Display line motions (gj, gk, g0 and g$) move by the lines the screen
+// shows rather than the lines of the buffer. A line wider than the window
+// takes as many display lines as it would wrapped at the window's width.
+// The screen shows a character per column, so columns here count runes.
+
+// runeColumn returns the screen column of the byte offset col in line
+func runeColumn(line string, col int) int {
+	return utf8.RuneCountInString(line[:min(max(col, 0), len(line))])
+}
+
+// byteColumn returns the byte offset of screen column col in line,
+// clamped to its last character
+func byteColumn(line string, col int) int {
+	offset := 0
+	for i := 0; i < col && offset < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		if offset+size >= len(line) {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+// displayLineMove returns where gj (down) or gk moves from pos count times
+// in a window width columns wide, keeping the column within the display
+// line. With no width each display line is a whole line, like j and k.
+func displayLineMove(buf *buffer.Buffer, pos buffer.Position, count, width int, down bool) buffer.Position {
+	if width <= 0 {
+		width = math.MaxInt32
+	}
+	n := pos.Line
+	line, _ := buf.Line(n)
+	col := runeColumn(line, pos.Col)
+	for i := 0; i < count; i++ {
+		length := utf8.RuneCountInString(line)
+		switch {
+		case down && (col/width+1)*width < length:
+			col = min(col+width, length-1)
+		case down && n < buf.LineCount()-1:
+			n++
+			line, _ = buf.Line(n)
+			col %= width
+		case !down && col >= width:
+			col -= width
+		case !down && n > 0:
+			n--
+			line, _ = buf.Line(n)
+			last := max(utf8.RuneCountInString(line)-1, 0)
+			col = last/width*width + col%width
+		}
+	}
+	return buffer.Position{Line: n, Col: byteColumn(line, col)}
+}
+
+// displayLineEdge returns where g0 (the first character of the display
+// line pos is on) or g$ (its last, with end set) is, in a window width
+// columns wide
+func displayLineEdge(buf *buffer.Buffer, pos buffer.Position, width int, end bool) buffer.Position {
+	if width <= 0 {
+		width = math.MaxInt32
+	}
+	line, _ := buf.Line(pos.Line)
+	col := runeColumn(line, pos.Col) / width * width
+	if end {
+		col = min(col+width, utf8.RuneCountInString(line)) - 1
+	}
+	return buffer.Position{Line: pos.Line, Col: byteColumn(line, max(col, 0))}
+}
+
+// displayLineMotion returns where the display line motion ch (j, k, 0 or
+// $ after g) moves from pos count times
+func displayLineMotion(ch rune, buf *buffer.Buffer, pos buffer.Position, count, width int) buffer.Position {
+	switch ch {
+	case 'j', 'k':
+		return displayLineMove(buf, pos, count, width, ch == 'j')
+	default:
+		return displayLineEdge(buf, pos, width, ch == '$')
+	}
+}
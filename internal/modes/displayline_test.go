@@ -0,0 +1,43 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestNormalMode_DisplayLineMotions(t *testing.T) {
+	lines := []string{"abcdefghij", "xy", "héllo"}
+	tests := []struct {
+		keys   string
+		width  int
+		cursor buffer.Position
+		want   buffer.Position
+	}{
+		{"gj", 4, buffer.Position{Col: 1}, buffer.Position{Col: 5}},
+		{"2gj", 4, buffer.Position{Col: 1}, buffer.Position{Col: 9}},
+		{"3gj", 4, buffer.Position{Col: 1}, buffer.Position{Line: 1, Col: 1}},
+		{"gk", 4, buffer.Position{Line: 1, Col: 1}, buffer.Position{Col: 9}},
+		{"gk", 4, buffer.Position{Col: 6}, buffer.Position{Col: 2}},
+		{"g0", 4, buffer.Position{Col: 6}, buffer.Position{Col: 4}},
+		{"g$", 4, buffer.Position{Col: 6}, buffer.Position{Col: 7}},
+		{"g$", 4, buffer.Position{Col: 9}, buffer.Position{Col: 9}},
+		{"gj", 2, buffer.Position{Line: 2}, buffer.Position{Line: 2, Col: 3}},
+		// Without a screen each line is one display line
+		{"gj", 0, buffer.Position{Col: 6}, buffer.Position{Line: 1, Col: 1}},
+		{"g$", 0, buffer.Position{Col: 6}, buffer.Position{Col: 9}},
+	}
+	for _, tt := range tests {
+		mode := NewNormalMode()
+		ctx := NewContext(nil)
+		ctx.viewport = func() ui.Viewport { return ui.Viewport{Width: tt.width} }
+		mode.SetContext(ctx)
+		buf := newOperatorTestBuffer(lines...)
+		buf.SetCursor(tt.cursor)
+		typeKeys(mode, buf, tt.keys)
+		if got := buf.Cursor(); got != tt.want {
+			t.Errorf("%s in width %d from %v moved to %v, want %v", tt.keys, tt.width, tt.cursor, got, tt.want)
+		}
+	}
+}
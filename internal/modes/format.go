@@ -0,0 +1,177 @@
+package modes
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// defaultTextWidth is used by gq when no text width is configured
+const defaultTextWidth = 79
+
+// commentLeaders are line prefixes kept on every line when reflowing comments
+var commentLeaders = []string{"//", "#", "--", ";"}
+
+// proseLeaders are those of prose filetypes, which also keep the > of
+// quoted lines
+var proseLeaders = []string{"//", "#", "--", ";", ">"}
+
+// proseFiletypes quote lines with >
+var proseFiletypes = map[string]bool{"markdown": true, "text": true, "mail": true, "gitcommit": true}
+
+// blockLeader starts the lines continuing a /* */ comment
+const blockLeader = "*"
+
+// listItem matches the marker of a list item, which starts a paragraph
+// of its own whose later lines are indented under its text
+var listItem = regexp.MustCompile(`^([*+-]|[0-9]+[.)])\s+`)
+
+// formatLines reflows lines start..end (inclusive) to the buffer's text width
+// and leaves the cursor on the last formatted line
+func formatLines(buf *buffer.Buffer, start, end int) {
+	width := buf.Options().TextWidth
+	if width <= 0 {
+		width = defaultTextWidth
+	}
+
+	var lines []string
+	for i := start; i <= end; i++ {
+		line, err := buf.Line(i)
+		if err != nil {
+			return
+		}
+		lines = append(lines, line)
+	}
+
+	leaders := commentLeaders
+	if proseFiletypes[buf.Filetype()] {
+		leaders = proseLeaders
+	}
+	formatted := reflow(lines, width, leaders, inBlockComment(buf, start))
+	if err := buf.ReplaceLines(start, end, formatted); err != nil {
+		return
+	}
+	last := start + len(formatted) - 1
+	buf.SetCursor(buffer.Position{Line: last, Col: indentEnd(formatted[len(formatted)-1])})
+}
+
+// reflow rewraps paragraphs of lines to width. Paragraphs are runs of
+// non-blank lines sharing the same indentation and comment leader, one of
+// leaders or, with the lines in a /* */ comment from inBlock on, *. A list
+// item starts a paragraph of its own.
+func reflow(lines []string, width int, leaders []string, inBlock bool) []string {
+	var result []string
+	var words []string
+	firstPrefix, currentPrefix := "", "" // Of the paragraph's first line and of those after it
+
+	flush := func() {
+		if len(words) == 0 {
+			return
+		}
+		result = append(result, wrapWords(words, firstPrefix, currentPrefix, width)...)
+		words = nil
+	}
+
+	for _, line := range lines {
+		prefix, text := splitLinePrefix(line, leaders, inBlock)
+		inBlock = blockCommentAfter(line, inBlock)
+		if strings.TrimSpace(text) == "" || isCommentDelimiter(line) {
+			flush()
+			result = append(result, strings.TrimRight(line, " \t"))
+			continue
+		}
+		if marker := listItem.FindString(text); marker != "" {
+			flush()
+			firstPrefix, currentPrefix = prefix, prefix+strings.Repeat(" ", utf8.RuneCountInString(marker))
+		} else if prefix != currentPrefix {
+			flush()
+			firstPrefix, currentPrefix = prefix, prefix
+		}
+		words = append(words, strings.Fields(text)...)
+	}
+	flush()
+
+	return result
+}
+
+// isCommentDelimiter reports whether line only opens or closes a block
+// comment, which is kept as it is
+func isCommentDelimiter(line string) bool {
+	switch strings.TrimSpace(line) {
+	case "/*", "/**", "*/":
+		return true
+	}
+	return false
+}
+
+// inBlockComment reports whether line of buf is inside a /* */ comment
+// opened on a line before it
+func inBlockComment(buf *buffer.Buffer, line int) bool {
+	for i := line - 1; i >= 0; i-- {
+		text, err := buf.Line(i)
+		if err != nil {
+			return false
+		}
+		if strings.Contains(text, "/*") || strings.Contains(text, "*/") {
+			return blockCommentAfter(text, false)
+		}
+	}
+	return false
+}
+
+// blockCommentAfter reports whether a /* */ comment is open after line,
+// given whether one was open before it
+func blockCommentAfter(line string, inBlock bool) bool {
+	open, close := strings.LastIndex(line, "/*"), strings.LastIndex(line, "*/")
+	if open < 0 && close < 0 {
+		return inBlock
+	}
+	return open > close
+}
+
+// wrapWords joins words into lines no longer than width, the first starting
+// with firstPrefix and the others with prefix. A word longer than the
+// available width is put on a line of its own.
+func wrapWords(words []string, firstPrefix, prefix string, width int) []string {
+	var lines []string
+	current := firstPrefix
+	empty := true
+	for _, word := range words {
+		if !empty && utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, current)
+			current = prefix
+			empty = true
+		}
+		if !empty {
+			current += " "
+		}
+		current += word
+		empty = false
+	}
+	return append(lines, current)
+}
+
+// splitLinePrefix separates a line's indentation and comment leader, one
+// of leaders or * in a block comment, from its text
+func splitLinePrefix(line string, leaders []string, inBlock bool) (string, string) {
+	indent := line[:indentEnd(line)]
+	rest := line[len(indent):]
+
+	if inBlock {
+		leaders = append([]string{blockLeader}, leaders...)
+	}
+	for _, leader := range leaders {
+		if strings.HasPrefix(rest, leader) {
+			prefix := indent + leader
+			rest = rest[len(leader):]
+			if strings.HasPrefix(rest, " ") {
+				prefix += " "
+				rest = rest[1:]
+			}
+			return prefix, rest
+		}
+	}
+	return indent, rest
+}
@@ -0,0 +1,153 @@
+package modes
+
+import (
+	"slices"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// HelpMode reads the help opened by :help. It is entered whenever the help
+// is open and no other mode is in use.
+type HelpMode struct {
+	ctx *Context
+}
+
+// NewHelpMode creates a new help mode instance
+func NewHelpMode() *HelpMode {
+	return &HelpMode{}
+}
+
+// SetContext sets the editor context the help was opened in
+func (h *HelpMode) SetContext(ctx *Context) {
+	h.ctx = ctx
+}
+
+// Type returns the mode type
+func (h *HelpMode) Type() ModeType {
+	return ModeHelp
+}
+
+// HandleInput moves through the help, follows its tags and closes it
+func (h *HelpMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	view := h.ctx.Commands().HelpView()
+	if view == nil {
+		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+	}
+	lines := len(view.Doc().Lines())
+
+	switch event.Action {
+	case ui.KeyActionDown:
+		view.Move(1)
+	case ui.KeyActionUp:
+		view.Move(-1)
+	case ui.KeyActionCtrlD:
+		view.Page(1)
+	case ui.KeyActionCtrlU:
+		view.Page(-1)
+	case ui.KeyActionEnter:
+		view.Follow()
+	case ui.KeyActionBackspace:
+		view.Back()
+	case ui.KeyActionEscape, ui.KeyActionCtrlC:
+		h.ctx.Commands().CloseHelp()
+		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+	case ui.KeyActionChar:
+		switch event.Rune {
+		case 'j':
+			view.Move(1)
+		case 'k':
+			view.Move(-1)
+		case 'g':
+			view.Move(-lines)
+		case 'G':
+			view.Move(lines)
+		case 'q':
+			h.ctx.Commands().CloseHelp()
+			return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+		case ':':
+			// Commands, like :help for another topic, keep the help open
+			return ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
+		}
+	}
+	return ModeResult{Handled: true}
+}
+
+// OnEnter is called when entering help mode
+func (h *HelpMode) OnEnter(buf *buffer.Buffer) {}
+
+// OnExit is called when leaving help mode
+func (h *HelpMode) OnExit(buf *buffer.Buffer) {}
+
+// GetStatusText returns mode-specific status information
+func (h *HelpMode) GetStatusText() string {
+	return "-- HELP --"
+}
+
+// basicKeys describes the keys of the modes outside the keymap, in the
+// order :help lists them
+var basicKeys = []commands.KeyHelp{
+	{Mode: "Normal mode", Keys: "h j k l", Description: "Move left, down, up and right"},
+	{Mode: "Normal mode", Keys: "w b e", Description: "Next word, previous word, end of word"},
+	{Mode: "Normal mode", Keys: "0 ^ $", Description: "Start of line, first non-blank, end of line"},
+	{Mode: "Normal mode", Keys: "i a I A", Description: "Insert before, after, at the start or end of the line"},
+	{Mode: "Normal mode", Keys: "o O", Description: "Open a line below or above"},
+	{Mode: "Normal mode", Keys: "v", Description: "Visual mode"},
+	{Mode: "Normal mode", Keys: ":", Description: "Command line"},
+	{Mode: "Normal mode", Keys: "/ ?", Description: "Search forward or backward; an empty pattern searches for the last one"},
+	{Mode: "Normal mode", Keys: "n N", Description: "Next match of the last search, or the match the other way"},
+	{Mode: "Normal mode", Keys: "x X", Description: "Delete the character under or before the cursor"},
+	{Mode: "Normal mode", Keys: "d{motion}", Description: "Delete; dd deletes lines"},
+	{Mode: "Normal mode", Keys: "y{motion}", Description: "Yank; yy yanks lines"},
+	{Mode: "Normal mode", Keys: "p P", Description: "Put after or before the cursor"},
+	{Mode: "Normal mode", Keys: "do dp", Description: "Obtain or put the difference in diff mode"},
+	{Mode: "Normal mode", Keys: "u Ctrl-R", Description: "Undo, redo"},
+	{Mode: "Normal mode", Keys: "Ctrl-A Ctrl-X", Description: "Increment or decrement the number at the cursor"},
+	{Mode: "Normal mode", Keys: "Ctrl-N", Description: "Add a cursor at the next match of the word"},
+	{Mode: "Normal mode", Keys: "Ctrl-P", Description: "Command palette"},
+	{Mode: "Normal mode", Keys: "Alt-j Alt-k", Description: "Move the line down or up"},
+	{Mode: "Insert mode", Keys: "Esc", Description: "Back to normal mode"},
+	{Mode: "Insert mode", Keys: "Ctrl-W Ctrl-U", Description: "Delete the word before the cursor, or to the line start"},
+	{Mode: "Insert mode", Keys: "Ctrl-T Ctrl-D", Description: "Indent or dedent the line"},
+	{Mode: "Insert mode", Keys: "Ctrl-N Ctrl-P", Description: "Complete words from the open buffers"},
+	{Mode: "Insert mode", Keys: "Ctrl-X Ctrl-F", Description: "Complete file paths"},
+	{Mode: "Insert mode", Keys: "Ctrl-Space", Description: "Language server completion"},
+	{Mode: "Insert mode", Keys: "Ctrl-S", Description: "Save"},
+	{Mode: "Visual mode", Keys: "d x y", Description: "Delete or yank the selection"},
+	{Mode: "Visual mode", Keys: "u U ~", Description: "Lowercase, uppercase or toggle case"},
+	{Mode: "Visual mode", Keys: "gq gc", Description: "Reflow or toggle comments"},
+	{Mode: "Visual mode", Keys: ":", Description: "Command line on the selected lines"},
+	{Mode: "Visual mode", Keys: "Ctrl-N I A", Description: "A cursor on every selected line"},
+	{Mode: "Help viewer", Keys: "j k", Description: "Move down or up"},
+	{Mode: "Help viewer", Keys: "Ctrl-D Ctrl-U", Description: "Half a page down or up"},
+	{Mode: "Help viewer", Keys: "g G", Description: "First or last line"},
+	{Mode: "Help viewer", Keys: "Enter", Description: "Jump to the |tag| on the line"},
+	{Mode: "Help viewer", Keys: "Backspace", Description: "Back to where the last jump started"},
+	{Mode: "Help viewer", Keys: "q Esc", Description: "Close the help"},
+}
+
+// keyHelp describes the keys of the modes for :help, with the prefixed
+// keys and leader mappings of normal mode from its keymap
+func (mm *ModeManager) keyHelp() []commands.KeyHelp {
+	normalEnd := slices.IndexFunc(basicKeys, func(k commands.KeyHelp) bool { return k.Mode != "Normal mode" })
+	keys := slices.Clone(basicKeys[:normalEnd])
+
+	if normalMode, ok := mm.modes[ModeNormal].(*NormalMode); ok {
+		for _, prefix := range normalMode.keymap.Prefixes() {
+			if _, operator := operatorNames[prefix]; operator || prefix == leaderPrefix {
+				continue
+			}
+			for _, b := range normalMode.keymap.Continuations(prefix) {
+				keys = append(keys, commands.KeyHelp{Mode: "Normal mode", Keys: prefix + b.Keys, Description: b.Description})
+			}
+		}
+		for _, b := range normalMode.keymap.Continuations(leaderPrefix) {
+			keys = append(keys, commands.KeyHelp{Mode: "Leader mappings", Keys: leaderPrefix + b.Keys, Description: b.Description})
+		}
+	}
+	for _, m := range operatorMotions {
+		keys = append(keys, commands.KeyHelp{Mode: "Operator motions", Keys: m.Keys, Description: m.Description})
+	}
+	return append(keys, basicKeys[normalEnd:]...)
+}
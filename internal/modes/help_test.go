@@ -0,0 +1,71 @@
+package modes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestHelpMode(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	mm.SwitchToMode(ModeNormal, buf)
+
+	typeInto(mm, ":help gg", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if mm.CurrentModeType() != ModeHelp {
+		t.Fatalf(":help left the editor in %v", mm.CurrentModeType())
+	}
+	view := mm.Context().Commands().HelpView()
+	if line := view.Doc().Lines()[view.Line()]; !strings.Contains(line, "gg") {
+		t.Errorf(":help gg opened at %q", line)
+	}
+
+	// The keys from the keymap and the leader mappings are described
+	text := strings.Join(view.Doc().Lines(), "\n")
+	for _, want := range []string{"]s", "Go to the next misspelling", "Operator motions"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("help is missing %q", want)
+		}
+	}
+
+	start := view.Line()
+	typeInto(mm, "jj", buf)
+	if view.Line() != start+2 {
+		t.Errorf("jj moved to line %d from %d", view.Line(), start)
+	}
+	typeInto(mm, "g", buf)
+	if view.Line() != 0 {
+		t.Errorf("g moved to line %d", view.Line())
+	}
+
+	// The contents link to the sections
+	for view.Doc().References(view.Line()) == nil {
+		typeInto(mm, "j", buf)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if view.Line() == 0 {
+		t.Error("Enter didn't follow the tag")
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionBackspace}, buf)
+	if refs := view.Doc().References(view.Line()); refs == nil {
+		t.Error("Backspace didn't go back to the contents")
+	}
+
+	// Commands keep the help open
+	typeInto(mm, ":", buf)
+	if mm.CurrentModeType() != ModeCommand {
+		t.Fatalf(": switched to %v", mm.CurrentModeType())
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	if mm.CurrentModeType() != ModeHelp {
+		t.Errorf("leaving the command line switched to %v", mm.CurrentModeType())
+	}
+
+	typeInto(mm, "q", buf)
+	if mm.CurrentModeType() != ModeNormal || mm.Context().Commands().HelpView() != nil {
+		t.Errorf("q left %v with the help open: %v", mm.CurrentModeType(), mm.Context().Commands().HelpView() != nil)
+	}
+}
@@ -0,0 +1,137 @@
+package modes
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// numberPattern matches hex, octal and decimal literals (decimals may be negative)
+var numberPattern = regexp.MustCompile(`0[xX][0-9a-fA-F]+|-?[0-9]+`)
+
+// incrementAt adds delta to the first number under or after col on line.
+// It returns the new line, the column of the last digit of the new number,
+// and false if there is no number to change.
+func incrementAt(line string, col int, delta int64) (string, int, bool) {
+	for _, loc := range numberPattern.FindAllStringIndex(line, -1) {
+		start, end := loc[0], loc[1]
+		if end <= col {
+			continue
+		}
+
+		// A minus sign glued to a word (x-1) is not a sign
+		if line[start] == '-' && start > 0 && isIdentifierChar(rune(line[start-1])) {
+			start++
+		}
+
+		replacement, ok := addToNumber(line[start:end], delta)
+		if !ok {
+			return line, col, false
+		}
+		return line[:start] + replacement + line[end:], start + len(replacement) - 1, true
+	}
+	return line, col, false
+}
+
+// addToNumber adds delta to a number literal, preserving its base, case and zero padding
+func addToNumber(literal string, delta int64) (string, bool) {
+	switch {
+	case len(literal) > 2 && (literal[:2] == "0x" || literal[:2] == "0X"):
+		digits := literal[2:]
+		value, err := strconv.ParseUint(digits, 16, 64)
+		if err != nil {
+			return "", false
+		}
+		result := strconv.FormatUint(value+uint64(delta), 16)
+		if strings.ToLower(digits) != digits {
+			result = strings.ToUpper(result)
+		}
+		return literal[:2] + padZeros(result, len(digits)), true
+
+	case isOctalLiteral(literal):
+		value, err := strconv.ParseUint(literal[1:], 8, 64)
+		if err != nil {
+			return "", false
+		}
+		result := strconv.FormatUint(value+uint64(delta), 8)
+		return "0" + padZeros(result, len(literal)-1), true
+
+	default:
+		value, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%d", value+delta), true
+	}
+}
+
+// isOctalLiteral reports whether literal is a zero-prefixed octal number like 017
+func isOctalLiteral(literal string) bool {
+	if len(literal) < 2 || literal[0] != '0' {
+		return false
+	}
+	for _, ch := range literal[1:] {
+		if ch < '0' || ch > '7' {
+			return false
+		}
+	}
+	return true
+}
+
+// padZeros left-pads digits with zeros to width
+func padZeros(digits string, width int) string {
+	if len(digits) >= width {
+		return digits
+	}
+	return strings.Repeat("0", width-len(digits)) + digits
+}
+
+// replaceLine replaces the content of a line, leaving the cursor at col
+func replaceLine(buf *buffer.Buffer, lineNum int, text string, col int) {
+	if err := buf.ReplaceLines(lineNum, lineNum, []string{text}); err != nil {
+		return
+	}
+	buf.SetCursor(buffer.Position{Line: lineNum, Col: col})
+}
+
+// incrementNumber implements Ctrl-A/Ctrl-X on the current line
+func (n *NormalMode) incrementNumber(buf *buffer.Buffer, delta int64) ModeResult {
+	cursor := buf.Cursor()
+	if newLine, col, ok := incrementAt(buf.CurrentLine(), cursor.Col, delta); ok {
+		replaceLine(buf, cursor.Line, newLine, col)
+	}
+	return ModeResult{Handled: true}
+}
+
+// incrementSelection implements visual Ctrl-A/Ctrl-X. With progressive set (g Ctrl-A)
+// each selected line is changed by an increasing multiple of delta.
+func (v *VisualMode) incrementSelection(buf *buffer.Buffer, delta int64, progressive bool) ModeResult {
+	start, end := v.GetSelection(buf)
+
+	step := int64(0)
+	for lineNum := start.Line; lineNum <= end.Line; lineNum++ {
+		line, err := buf.Line(lineNum)
+		if err != nil {
+			break
+		}
+		col := 0
+		if lineNum == start.Line {
+			col = start.Col
+		}
+
+		amount := delta
+		if progressive {
+			amount = delta * (step + 1)
+		}
+		if newLine, _, ok := incrementAt(line, col, amount); ok {
+			replaceLine(buf, lineNum, newLine, 0)
+			step++
+		}
+	}
+
+	buf.SetCursor(start)
+	return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+}
@@ -0,0 +1,92 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestIncrementAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		col      int
+		delta    int64
+		expected string
+		ok       bool
+	}{
+		{"number under cursor", "x = 41", 5, 1, "x = 42", true},
+		{"number after cursor", "x = 41", 0, 1, "x = 42", true},
+		{"decrement below zero", "count 0", 0, -1, "count -1", true},
+		{"negative number", "y = -5", 0, 3, "y = -2", true},
+		{"dash after word is not a sign", "item-5", 0, 1, "item-6", true},
+		{"hex keeps case and width", "0x0F", 0, 1, "0x10", true},
+		{"hex lowercase", "mask 0xfe", 0, 1, "mask 0xff", true},
+		{"octal keeps width", "mode 0007", 0, 1, "mode 0010", true},
+		{"count", "a 10", 0, 5, "a 15", true},
+		{"number before cursor is skipped", "10 abc", 4, 1, "10 abc", false},
+		{"no number", "abc", 0, 1, "abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, ok := incrementAt(tt.line, tt.col, tt.delta)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNormalMode_IncrementWithCount(t *testing.T) {
+	mode := NewNormalMode()
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "width: 80px")
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+
+	typeKeys(mode, buf, "12")
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlX}, buf)
+
+	if buf.CurrentLine() != "width: 68px" {
+		t.Errorf("expected 'width: 68px', got %q", buf.CurrentLine())
+	}
+	if buf.Cursor().Col != 8 {
+		t.Errorf("expected cursor on last digit, got col %d", buf.Cursor().Col)
+	}
+
+	// The count is consumed by the command
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlA}, buf)
+	if buf.CurrentLine() != "width: 69px" {
+		t.Errorf("expected 'width: 69px', got %q", buf.CurrentLine())
+	}
+}
+
+func TestVisualMode_ProgressiveIncrement(t *testing.T) {
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "item 0")
+	for i := 1; i < 4; i++ {
+		buf.InsertLine()
+		buf.InsertTextAt(i, 0, "item 0")
+	}
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+
+	mode := NewVisualMode()
+	mode.OnEnter(buf)
+	buf.SetCursor(buffer.Position{Line: 3, Col: 0})
+
+	typeKeys(mode, buf, "g")
+	result := mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlA}, buf)
+	if result.SwitchToMode == nil || *result.SwitchToMode != ModeNormal {
+		t.Error("expected to return to normal mode")
+	}
+
+	for i, expected := range []string{"item 1", "item 2", "item 3", "item 4"} {
+		if line, _ := buf.Line(i); line != expected {
+			t.Errorf("line %d: expected %q, got %q", i, expected, line)
+		}
+	}
+}
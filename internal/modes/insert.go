@@ -4,7 +4,9 @@ import (
 	"context"
 	
 	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
 	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/registers"
 	"github.com/dshills/aied/internal/ui"
 	"go.lsp.dev/protocol"
 )
@@ -12,22 +14,31 @@ import (
 // InsertMode implements VIM insert mode behavior
 type InsertMode struct{
 	lspManager       *lsp.Manager
-	showingCompletion bool
-	completions      []CompletionItem
-	selectedIndex    int
+	popup            *ui.CompletionPopup
+	source           completionSource // Buffer word or path source refiltered while typing
+	completionStart  int              // Column where text replaced by a local completion starts
+	buffers          BufferProvider
+	ctrlX            bool // Ctrl-X pressed, waiting for the completion type
+	ctrlG            bool // Ctrl-G pressed, waiting for u to break the undo step
+	ctrlR            bool // Ctrl-R pressed, waiting for the register to insert
+	registers        *registers.Store
+	insertStart      buffer.Position // Where the text typed in this insert starts
+	expression       bool            // Reading an expression on the command line for Ctrl-R =
+	startExpression  func()          // Makes command mode read the expression
 }
 
 // CompletionItem represents a completion option
-type CompletionItem struct {
-	Label       string
-	Detail      string
-	InsertText  string
-	Kind        string
-}
+type CompletionItem = ui.CompletionItem
 
 // NewInsertMode creates a new insert mode instance
 func NewInsertMode() *InsertMode {
-	return &InsertMode{}
+	return &InsertMode{popup: ui.NewCompletionPopup(), registers: registers.NewStore()}
+}
+
+// SetRegisters sets the register store read by Ctrl-R and Ctrl-A, which
+// keeps the last inserted text
+func (i *InsertMode) SetRegisters(store *registers.Store) {
+	i.registers = store
 }
 
 // SetLSPManager sets the LSP manager for code completion
@@ -42,23 +53,82 @@ func (i *InsertMode) Type() ModeType {
 
 // HandleInput processes keyboard input in insert mode
 func (i *InsertMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	// Ctrl-G u and moving the cursor start a new undo step within this insert
+	moving := false
+	switch event.Action {
+	case ui.KeyActionUp, ui.KeyActionDown, ui.KeyActionLeft, ui.KeyActionRight, ui.KeyActionHome, ui.KeyActionEnd:
+		if !i.popup.IsVisible() {
+			buf.Commit()
+			moving = true
+		}
+	}
+	// The text typed after the cursor moves, or after deleting back past
+	// where it started, is what Ctrl-A inserts again
+	defer func() {
+		if cursor := buf.Cursor(); moving || positionBefore(cursor, i.insertStart) {
+			i.insertStart = cursor
+		}
+	}()
+
+	// Ctrl-R inserts the register named by the next key
+	if i.ctrlR {
+		i.ctrlR = false
+		if event.Action == ui.KeyActionChar && event.Rune == '=' && i.startExpression != nil {
+			// The expression register reads an expression on the command
+			// line and inserts its value
+			i.expression = true
+			i.startExpression()
+			return ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
+		}
+		if event.Action == ui.KeyActionChar && registers.IsReadable(event.Rune) {
+			i.insertRegister(buf, event.Rune)
+		}
+		return ModeResult{Handled: true}
+	}
+	if i.ctrlG {
+		i.ctrlG = false
+		if event.Action == ui.KeyActionChar && event.Rune == 'u' {
+			buf.Commit()
+			return ModeResult{Handled: true}
+		}
+	}
+	if event.Action == ui.KeyActionCtrlG {
+		i.ctrlG = true
+		return ModeResult{Handled: true}
+	}
+
+	// Ctrl-X selects the completion type with the next key
+	if i.ctrlX {
+		i.ctrlX = false
+		switch event.Action {
+		case ui.KeyActionCtrlF:
+			i.startLocalCompletion(buf, pathCompletions, false)
+			return ModeResult{Handled: true}
+		case ui.KeyActionCtrlN, ui.KeyActionCtrlP:
+			i.startLocalCompletion(buf, i.wordCompletions, event.Action == ui.KeyActionCtrlP)
+			return ModeResult{Handled: true}
+		}
+	}
+
 	// Handle completion navigation if showing completions
-	if i.showingCompletion {
+	if i.popup.IsVisible() {
 		switch event.Action {
+		case ui.KeyActionCtrlN:
+			i.popup.Next()
+			return ModeResult{Handled: true}
+		case ui.KeyActionCtrlP:
+			i.popup.Prev()
+			return ModeResult{Handled: true}
 		case ui.KeyActionUp:
-			if i.selectedIndex > 0 {
-				i.selectedIndex--
-			}
+			i.popup.MoveUp()
 			return ModeResult{Handled: true}
 		case ui.KeyActionDown:
-			if i.selectedIndex < len(i.completions)-1 {
-				i.selectedIndex++
-			}
+			i.popup.MoveDown()
 			return ModeResult{Handled: true}
 		case ui.KeyActionTab, ui.KeyActionEnter:
 			// Accept completion
-			if i.selectedIndex < len(i.completions) {
-				i.applyCompletion(buf, i.completions[i.selectedIndex])
+			if item := i.popup.GetSelectedItem(); item != nil {
+				i.applyCompletion(buf, *item)
 			}
 			i.hideCompletion()
 			return ModeResult{Handled: true}
@@ -76,7 +146,13 @@ func (i *InsertMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResu
 
 	case ui.KeyActionChar:
 		// Insert the character
-		buf.InsertChar(event.Rune)
+		atCursors(buf, func() { buf.InsertChar(event.Rune) })
+		i.refreshCompletion(buf)
+
+		// A | in a markdown table aligns it
+		if event.Rune == '|' && inTable(buf) {
+			commands.FormatTable(buf, buf.Cursor().Line)
+		}
 		
 		// Trigger completion on certain characters
 		if i.lspManager != nil {
@@ -88,58 +164,73 @@ func (i *InsertMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResu
 
 	case ui.KeyActionBackspace:
 		// Delete character before cursor
-		buf.Backspace()
+		atCursors(buf, func() { buf.Backspace() })
+		i.refreshCompletion(buf)
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionDelete:
 		// Delete character at cursor
-		buf.DeleteChar()
+		atCursors(buf, func() { buf.DeleteChar() })
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionEnter:
 		// Insert new line
-		buf.InsertLine()
+		atCursors(buf, func() { buf.InsertLine() })
 		return ModeResult{Handled: true}
 
-	case ui.KeyActionTab:
-		// Insert tab (4 spaces for now)
-		buf.InsertChar(' ')
-		buf.InsertChar(' ')
-		buf.InsertChar(' ')
-		buf.InsertChar(' ')
+	case ui.KeyActionTab, ui.KeyActionBacktab:
+		// Tab and Shift-Tab go between the cells of a markdown table
+		if inTable(buf) {
+			commands.NextTableCell(buf, event.Action == ui.KeyActionBacktab)
+			return ModeResult{Handled: true}
+		}
+		if event.Action == ui.KeyActionBacktab {
+			return ModeResult{Handled: false}
+		}
+		// Insert a tab, or tabstop spaces when indenting with spaces
+		indent := indentString(buf.Options(), buf.Options().TabWidth())
+		atCursors(buf, func() {
+			for _, ch := range indent {
+				buf.InsertChar(ch)
+			}
+		})
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionUp:
 		// Move cursor up (allow navigation in insert mode)
-		buf.MoveCursor(-1, 0)
+		atCursors(buf, func() { buf.MoveCursor(-1, 0) })
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionDown:
 		// Move cursor down
-		buf.MoveCursor(1, 0)
+		atCursors(buf, func() { buf.MoveCursor(1, 0) })
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionLeft:
 		// Move cursor left
-		buf.MoveCursor(0, -1)
+		atCursors(buf, func() { buf.MoveCursor(0, -1) })
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionRight:
 		// Move cursor right
-		buf.MoveCursor(0, 1)
+		atCursors(buf, func() { buf.MoveCursor(0, 1) })
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionHome:
 		// Move to beginning of line
-		cursor := buf.Cursor()
-		buf.SetCursor(buffer.Position{Line: cursor.Line, Col: 0})
+		atCursors(buf, func() {
+			cursor := buf.Cursor()
+			buf.SetCursor(buffer.Position{Line: cursor.Line, Col: 0})
+		})
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionEnd:
 		// Move to end of line
-		cursor := buf.Cursor()
-		lineLen := len(buf.CurrentLine())
-		buf.SetCursor(buffer.Position{Line: cursor.Line, Col: lineLen})
+		atCursors(buf, func() {
+			cursor := buf.Cursor()
+			lineLen := len(buf.CurrentLine())
+			buf.SetCursor(buffer.Position{Line: cursor.Line, Col: lineLen})
+		})
 		return ModeResult{Handled: true}
 
 	case ui.KeyActionCtrlC:
@@ -154,12 +245,52 @@ func (i *InsertMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResu
 		return ModeResult{Handled: true}
 		
 	case ui.KeyActionCtrlSpace:
-		// Manual completion trigger
-		if i.lspManager != nil {
+		// Manual completion trigger, falling back to buffer words and paths without LSP
+		if i.lspManager != nil && buf.Filename() != "" {
 			i.triggerCompletion(buf)
+		} else {
+			i.startLocalCompletion(buf, i.fallbackCompletion(buf), false)
 		}
 		return ModeResult{Handled: true}
 
+	case ui.KeyActionCtrlN, ui.KeyActionCtrlP:
+		// Complete words from the open buffers
+		i.startLocalCompletion(buf, i.wordCompletions, event.Action == ui.KeyActionCtrlP)
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlX:
+		i.ctrlX = true
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlR:
+		i.ctrlR = true
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlA:
+		// Insert the text typed in the last insert again
+		i.insertRegister(buf, registers.LastInsert)
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlW:
+		// Delete word before cursor
+		atCursors(buf, func() { i.deleteWordBefore(buf) })
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlU:
+		// Delete to start of line
+		atCursors(buf, func() { i.deleteToLineStart(buf) })
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlT:
+		// Indent current line
+		atCursors(buf, func() { i.indentLine(buf) })
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlD:
+		// Dedent current line
+		atCursors(buf, func() { i.dedentLine(buf) })
+		return ModeResult{Handled: true}
+
 	default:
 		return ModeResult{Handled: false}
 	}
@@ -170,31 +301,51 @@ func (i *InsertMode) OnEnter(buf *buffer.Buffer) {
 	if buf == nil {
 		return
 	}
+	// Back from reading an expression, the insert goes on
+	if i.expression {
+		i.expression = false
+		return
+	}
 	// In insert mode, cursor can be positioned after the last character
 	// No special adjustment needed
+	i.insertStart = buf.Cursor()
 }
 
 // OnExit is called when leaving insert mode
 func (i *InsertMode) OnExit(buf *buffer.Buffer) {
-	if buf == nil {
+	i.hideCompletion()
+	i.ctrlX = false
+	i.ctrlG = false
+	i.ctrlR = false
+	if buf == nil || i.expression {
 		return
 	}
+
+	// Remember the text typed for Ctrl-A and the . register
+	if cursor := buf.Cursor(); i.registers != nil && positionBefore(i.insertStart, cursor) {
+		i.registers.Inserted(rangeText(buf, textRange{Start: i.insertStart, End: cursor}))
+	}
 	
 	// When leaving insert mode, adjust cursor to be on a character (not after)
 	// This follows VIM behavior
-	cursor := buf.Cursor()
-	lineLen := len(buf.CurrentLine())
-	
-	if cursor.Col > 0 && cursor.Col >= lineLen && lineLen > 0 {
-		buf.SetCursor(buffer.Position{Line: cursor.Line, Col: lineLen - 1})
-	}
+	atCursors(buf, func() {
+		cursor := buf.Cursor()
+		lineLen := len(buf.CurrentLine())
+		
+		if cursor.Col > 0 && cursor.Col >= lineLen && lineLen > 0 {
+			buf.SetCursor(buffer.Position{Line: cursor.Line, Col: lineLen - 1})
+		}
+	})
 }
 
 // GetStatusText returns mode-specific status information
 func (i *InsertMode) GetStatusText() string {
-	if i.showingCompletion {
+	if i.popup.IsVisible() {
 		return "-- INSERT (completing) --"
 	}
+	if i.ctrlX {
+		return "-- INSERT ^X (^N^P^F) --"
+	}
 	return "-- INSERT --"
 }
 
@@ -230,9 +381,8 @@ func (i *InsertMode) triggerCompletion(buf *buffer.Buffer) {
 	}
 	
 	if len(items) > 0 {
-		i.completions = items
-		i.selectedIndex = 0
-		i.showingCompletion = true
+		i.source = nil
+		i.popup.SetItems(items)
 	}
 }
 
@@ -248,8 +398,12 @@ func (i *InsertMode) applyCompletion(buf *buffer.Buffer, item CompletionItem) {
 	
 	// Find word start
 	wordStart := cursor.Col
-	for wordStart > 0 && isIdentifierChar(rune(line[wordStart-1])) {
-		wordStart--
+	if i.source != nil {
+		wordStart = min(i.completionStart, cursor.Col)
+	} else {
+		for wordStart > 0 && isIdentifierChar(rune(line[wordStart-1])) {
+			wordStart--
+		}
 	}
 	
 	// Delete current partial word
@@ -265,9 +419,8 @@ func (i *InsertMode) applyCompletion(buf *buffer.Buffer, item CompletionItem) {
 
 // hideCompletion hides the completion popup
 func (i *InsertMode) hideCompletion() {
-	i.showingCompletion = false
-	i.completions = nil
-	i.selectedIndex = 0
+	i.popup.Hide()
+	i.source = nil
 }
 
 // isIdentifierChar checks if a character is part of an identifier
@@ -334,7 +487,53 @@ func getCompletionKindString(kind protocol.CompletionItemKind) string {
 	}
 }
 
-// GetCompletions returns current completion items for display
-func (i *InsertMode) GetCompletions() ([]CompletionItem, int, bool) {
-	return i.completions, i.selectedIndex, i.showingCompletion
-}
\ No newline at end of file
+// Completion returns the completion popup, for the frontend to show at the
+// cursor while it is visible
+func (i *InsertMode) Completion() *ui.CompletionPopup {
+	return i.popup
+}
+
+// insertRegister inserts the text of register name at each cursor as if
+// typed, a linewise register ending with a line break
+func (i *InsertMode) insertRegister(buf *buffer.Buffer, name rune) {
+	if i.registers == nil {
+		return
+	}
+	reg, ok := i.registers.Get(name)
+	if !ok {
+		return
+	}
+	text := reg.Text
+	if reg.Linewise {
+		text += "\n"
+	}
+	insertText(buf, text)
+	i.refreshCompletion(buf)
+}
+
+// inTable reports whether the cursor is on a row of a table in a
+// markdown buffer, which Tab and | align, without other cursors
+func inTable(buf *buffer.Buffer) bool {
+	return buf.Filetype() == "markdown" && !buf.HasMultipleCursors() && commands.IsTableRow(buf.CurrentLine())
+}
+
+// insertText inserts text at each cursor as if typed
+func insertText(buf *buffer.Buffer, text string) {
+	atCursors(buf, func() {
+		for _, ch := range text {
+			if ch == '\n' {
+				buf.InsertLine()
+			} else {
+				buf.InsertChar(ch)
+			}
+		}
+	})
+}
+
+// PendingText shows ^R while Ctrl-R waits for a register
+func (i *InsertMode) PendingText() string {
+	if i.ctrlR {
+		return "^R"
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package modes
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// deleteWordBefore implements Ctrl-W: delete trailing whitespace and the word before the cursor.
+// At the start of a line it joins with the previous line like backspace.
+func (i *InsertMode) deleteWordBefore(buf *buffer.Buffer) {
+	cursor := buf.Cursor()
+	if cursor.Col == 0 {
+		buf.Backspace()
+		return
+	}
+
+	line := buf.CurrentLine()
+	col := cursor.Col
+
+	// Skip whitespace before the cursor
+	for col > 0 && unicode.IsSpace(rune(line[col-1])) {
+		col--
+	}
+
+	// Delete a run of identifier characters or a run of punctuation
+	if col > 0 && isIdentifierChar(rune(line[col-1])) {
+		for col > 0 && isIdentifierChar(rune(line[col-1])) {
+			col--
+		}
+	} else {
+		for col > 0 && !isIdentifierChar(rune(line[col-1])) && !unicode.IsSpace(rune(line[col-1])) {
+			col--
+		}
+	}
+
+	for j := cursor.Col; j > col; j-- {
+		buf.Backspace()
+	}
+}
+
+// deleteToLineStart implements Ctrl-U: delete back to the end of the indentation,
+// or to the start of the line when the cursor is already inside the indentation
+func (i *InsertMode) deleteToLineStart(buf *buffer.Buffer) {
+	cursor := buf.Cursor()
+	if cursor.Col == 0 {
+		buf.Backspace()
+		return
+	}
+
+	target := indentEnd(buf.CurrentLine())
+	if cursor.Col <= target {
+		target = 0
+	}
+
+	for j := cursor.Col; j > target; j-- {
+		buf.Backspace()
+	}
+}
+
+// indentLine implements Ctrl-T: shift the current line right, keeping the cursor on the same text
+func (i *InsertMode) indentLine(buf *buffer.Buffer) {
+	cursor := buf.Cursor()
+	indent := indentString(buf.Options(), buf.Options().IndentWidth())
+	buf.InsertTextAt(cursor.Line, 0, indent)
+	buf.SetCursor(buffer.Position{Line: cursor.Line, Col: cursor.Col + len(indent)})
+}
+
+// dedentLine implements Ctrl-D: shift the current line left by one tab or up to shiftwidth spaces
+func (i *InsertMode) dedentLine(buf *buffer.Buffer) {
+	cursor := buf.Cursor()
+	line := buf.CurrentLine()
+	indentWidth := buf.Options().IndentWidth()
+
+	remove := 0
+	if len(line) > 0 && line[0] == '\t' {
+		remove = 1
+	} else {
+		for remove < indentWidth && remove < len(line) && line[remove] == ' ' {
+			remove++
+		}
+	}
+	if remove == 0 {
+		return
+	}
+
+	buf.SetCursor(buffer.Position{Line: cursor.Line, Col: 0})
+	for j := 0; j < remove; j++ {
+		buf.DeleteChar()
+	}
+	buf.SetCursor(buffer.Position{Line: cursor.Line, Col: max(cursor.Col-remove, 0)})
+}
+
+// indentString returns the text inserted to indent by width columns: a
+// tab when indenting with tabs, otherwise spaces
+func indentString(options buffer.Options, width int) string {
+	if options.IndentTabs {
+		return "\t"
+	}
+	return strings.Repeat(" ", width)
+}
+
+// indentEnd returns the byte offset where the leading whitespace of line ends
+func indentEnd(line string) int {
+	end := 0
+	for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+		end++
+	}
+	return end
+}
@@ -0,0 +1,188 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/registers"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestInsertMode_EditingShortcuts(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		col       int
+		action    ui.KeyAction
+		expected  string
+		cursorCol int
+	}{
+		{"ctrl-w deletes word", "foo bar", 7, ui.KeyActionCtrlW, "foo ", 4},
+		{"ctrl-w skips spaces", "foo bar  ", 9, ui.KeyActionCtrlW, "foo ", 4},
+		{"ctrl-w deletes punctuation run", "foo.(", 5, ui.KeyActionCtrlW, "foo", 3},
+		{"ctrl-u deletes to indent", "    foo bar", 11, ui.KeyActionCtrlU, "    ", 4},
+		{"ctrl-u inside indent", "    foo", 4, ui.KeyActionCtrlU, "foo", 0},
+		{"ctrl-t indents", "foo", 1, ui.KeyActionCtrlT, "    foo", 5},
+		{"ctrl-d dedents spaces", "      foo", 7, ui.KeyActionCtrlD, "  foo", 3},
+		{"ctrl-d dedents tab", "\t\tfoo", 3, ui.KeyActionCtrlD, "\tfoo", 2},
+		{"ctrl-d without indent", "foo", 2, ui.KeyActionCtrlD, "foo", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := NewInsertMode()
+			buf := buffer.New()
+			buf.InsertTextAt(0, 0, tt.line)
+			buf.SetCursor(buffer.Position{Line: 0, Col: tt.col})
+
+			result := mode.HandleInput(ui.KeyEvent{Action: tt.action}, buf)
+			if !result.Handled {
+				t.Fatal("expected key to be handled")
+			}
+			if buf.CurrentLine() != tt.expected {
+				t.Errorf("expected line %q, got %q", tt.expected, buf.CurrentLine())
+			}
+			if buf.Cursor().Col != tt.cursorCol {
+				t.Errorf("expected cursor col %d, got %d", tt.cursorCol, buf.Cursor().Col)
+			}
+		})
+	}
+}
+
+func TestInsertMode_CtrlWJoinsLines(t *testing.T) {
+	mode := NewInsertMode()
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "foo")
+	buf.InsertLine()
+	buf.InsertTextAt(1, 0, "bar")
+	buf.SetCursor(buffer.Position{Line: 1, Col: 0})
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlW}, buf)
+	if buf.LineCount() != 1 || buf.CurrentLine() != "foobar" {
+		t.Errorf("expected lines to join, got %q", buf.Lines())
+	}
+}
+
+func TestInsertMode_InsertsRegisters(t *testing.T) {
+	mm := NewModeManager()
+	store := registers.NewStore()
+	mm.SetRegisters(store)
+	store.Yank('a', "one", false)
+	store.Yank('b', "line", true)
+	buf := buffer.New()
+	mm.SwitchToMode(ModeNormal, buf)
+	ctrl := func(action ui.KeyAction) { mm.HandleInput(ui.KeyEvent{Action: action}, buf) }
+
+	// Ctrl-R inserts a register as if typed, a linewise one with its line break
+	typeInto(mm, "i", buf)
+	ctrl(ui.KeyActionCtrlR)
+	if got := mm.Pending(); got != "^R" {
+		t.Errorf("Ctrl-R pending shows %q", got)
+	}
+	typeInto(mm, "a", buf)
+	typeInto(mm, " ", buf)
+	ctrl(ui.KeyActionCtrlR)
+	typeInto(mm, "b", buf)
+	ctrl(ui.KeyActionEscape)
+	if got := buf.Lines(); len(got) != 2 || got[0] != "one line" || got[1] != "" {
+		t.Errorf("Ctrl-R inserted %q", got)
+	}
+
+	// Ctrl-A inserts the text typed in the last insert, also in register .
+	if reg, _ := store.Get(registers.LastInsert); reg.Text != "one line\n" {
+		t.Errorf("register . holds %q", reg.Text)
+	}
+	typeInto(mm, "ix", buf)
+	ctrl(ui.KeyActionEscape)
+	typeInto(mm, "o", buf)
+	ctrl(ui.KeyActionCtrlA)
+	ctrl(ui.KeyActionEscape)
+	if got, _ := buf.Line(2); got != "x" {
+		t.Errorf("Ctrl-A inserted %q, want the last insert", got)
+	}
+
+	// Ctrl-R on the command line
+	typeInto(mm, ":s/", buf)
+	ctrl(ui.KeyActionCtrlR)
+	if line, _, _ := mm.GetCommandInfo(); line != ":s/\"" {
+		t.Errorf("Ctrl-R on the command line shows %q", line)
+	}
+	typeInto(mm, "a", buf)
+	if line, _, _ := mm.GetCommandInfo(); line != ":s/one" {
+		t.Errorf("command line is %q after Ctrl-R a", line)
+	}
+}
+
+func TestInsertMode_ExpressionRegister(t *testing.T) {
+	mm := NewModeManager()
+	store := registers.NewStore()
+	mm.SetRegisters(store)
+	buf := buffer.New()
+	mm.SwitchToMode(ModeNormal, buf)
+	ctrl := func(action ui.KeyAction) { mm.HandleInput(ui.KeyEvent{Action: action}, buf) }
+
+	// Ctrl-R = reads an expression on the command line and inserts its value
+	typeInto(mm, "itotal ", buf)
+	ctrl(ui.KeyActionCtrlR)
+	typeInto(mm, "=", buf)
+	if line, _, _ := mm.GetCommandInfo(); line != "=" || mm.CurrentModeType() != ModeCommand {
+		t.Fatalf("Ctrl-R = shows %q in %v", line, mm.CurrentModeType())
+	}
+	typeInto(mm, "6 * 7", buf)
+	ctrl(ui.KeyActionEnter)
+	if mm.CurrentModeType() != ModeInsert {
+		t.Fatalf("after the expression the mode is %v, want insert", mm.CurrentModeType())
+	}
+	typeInto(mm, "!", buf)
+	ctrl(ui.KeyActionEscape)
+	if got, _ := buf.Line(0); got != "total 42!" {
+		t.Errorf("Ctrl-R = inserted %q", got)
+	}
+
+	// The insert around the expression is one insert, and one undo step
+	if reg, _ := store.Get(registers.LastInsert); reg.Text != "total 42!" {
+		t.Errorf("register . holds %q", reg.Text)
+	}
+	typeInto(mm, "u", buf)
+	if got, _ := buf.Line(0); got != "" {
+		t.Errorf("undo left %q", got)
+	}
+
+	// A bad expression inserts nothing
+	typeInto(mm, "i", buf)
+	ctrl(ui.KeyActionCtrlR)
+	typeInto(mm, "=1 +", buf)
+	ctrl(ui.KeyActionEnter)
+	if got, _ := buf.Line(0); got != "" || mm.CurrentModeType() != ModeInsert {
+		t.Errorf("a bad expression left %q in %v", got, mm.CurrentModeType())
+	}
+}
+
+func TestInsertMode_MarkdownTable(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.SetFilename("notes.md")
+	mm.SwitchToMode(ModeNormal, buf)
+	tab := func() { mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionTab}, buf) }
+
+	// Each | aligns the row, and Tab goes on to a new row
+	typeInto(mm, "i|name|qty|", buf)
+	if got := buf.CurrentLine(); got != "| name | qty |" || buf.Cursor().Col != len(got) {
+		t.Errorf("typing the header gave %q with the cursor at %d", got, buf.Cursor().Col)
+	}
+	tab()
+	typeInto(mm, "apple", buf)
+	tab()
+	typeInto(mm, "3", buf)
+	if got := buf.String(); got != "| name  | qty |\n| apple | 3    |" {
+		t.Errorf("Tab through the table gave %q", got)
+	}
+
+	// Outside markdown Tab inserts an indent
+	buf.SetFilename("notes.txt")
+	before := buf.CurrentLine()
+	tab()
+	if got := buf.CurrentLine(); len(got) <= len(before) || buf.LineCount() != 2 {
+		t.Errorf("Tab outside markdown changed %q to %q", before, got)
+	}
+}
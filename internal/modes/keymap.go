@@ -0,0 +1,148 @@
+package modes
+
+import (
+	"slices"
+	"strings"
+)
+
+// KeyBinding is a key that may follow a pending prefix, with what it does
+type KeyBinding struct {
+	Keys        string
+	Description string
+}
+
+// Keymap lists the keys that may follow each prefix, for the hints shown
+// while keys are pending
+type Keymap struct {
+	bindings map[string][]KeyBinding
+}
+
+// NewKeymap creates an empty keymap
+func NewKeymap() *Keymap {
+	return &Keymap{bindings: make(map[string][]KeyBinding)}
+}
+
+// Bind describes keys following prefix, replacing an earlier description of
+// the same keys
+func (k *Keymap) Bind(prefix, keys, description string) {
+	list := k.bindings[prefix]
+	if i := slices.IndexFunc(list, func(b KeyBinding) bool { return b.Keys == keys }); i >= 0 {
+		list[i].Description = description
+		return
+	}
+	k.bindings[prefix] = append(list, KeyBinding{Keys: keys, Description: description})
+}
+
+// Continuations returns the keys that may follow prefix, ordered by key
+func (k *Keymap) Continuations(prefix string) []KeyBinding {
+	list := slices.Clone(k.bindings[prefix])
+	slices.SortStableFunc(list, func(a, b KeyBinding) int {
+		return strings.Compare(a.Keys, b.Keys)
+	})
+	return list
+}
+
+// Prefixes returns the prefixes with keys described, sorted
+func (k *Keymap) Prefixes() []string {
+	var prefixes []string
+	for prefix, list := range k.bindings {
+		if len(list) > 0 {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	slices.Sort(prefixes)
+	return prefixes
+}
+
+// operatorMotions are the motions and text objects any operator accepts
+var operatorMotions = []KeyBinding{
+	{"w", "to the next word"},
+	{"e", "to the end of the word"},
+	{"b", "to the previous word"},
+	{"$", "to the end of the line"},
+	{"0", "to the start of the line"},
+	{"^", "to the first non-blank"},
+	{"j", "this and the next line"},
+	{"k", "this and the previous line"},
+	{"G", "to the last line"},
+	{"gg", "to the first line"},
+	{"ge", "back to the end of the word before"},
+	{"g_", "to the last non-blank"},
+	{"}", "to the next paragraph"},
+	{"{", "to the previous paragraph"},
+	{")", "to the next sentence"},
+	{"(", "to the start of the sentence"},
+	{"ip", "inner paragraph"},
+	{"ap", "a paragraph"},
+	{"is", "inner sentence"},
+	{"as", "a sentence"},
+}
+
+// operatorNames describes the operators waiting for a motion
+var operatorNames = map[string]string{
+	"d":  "Delete",
+	"y":  "Yank",
+	"gu": "Lowercase",
+	"gU": "Uppercase",
+	"g~": "Toggle case",
+	"gq": "Reflow",
+	"gc": "Toggle comments",
+}
+
+// defaultKeymap describes normal mode's prefix keys and operators
+func defaultKeymap() *Keymap {
+	k := NewKeymap()
+	for _, b := range []KeyBinding{
+		{"g", "Go to the first line"},
+		{"j", "Down a display line"},
+		{"k", "Up a display line"},
+		{"0", "Start of the display line"},
+		{"$", "End of the display line"},
+		{"e", "End of the word before"},
+		{"_", "Last non-blank character"},
+		{"I", "Insert at the start of the line"},
+		{"v", "Select the last visual area again"},
+		{"a", "Align the selected lines on a delimiter (visual)"},
+		{"d", "Go to definition"},
+		{"h", "Show hover information"},
+		{"r", "Find references"},
+		{"f", "Open the file under the cursor"},
+		{"x", "Open the URL under the cursor in the browser"},
+		{"u", "Lowercase {motion}"},
+		{"U", "Uppercase {motion}"},
+		{"~", "Toggle case of {motion}"},
+		{"q", "Reflow {motion}"},
+		{"c", "Toggle comments on {motion}"},
+		{"-", "Older text state"},
+		{"+", "Newer text state"},
+		{"Ctrl-G", "Count lines, words and characters"},
+	} {
+		k.Bind("g", b.Keys, b.Description)
+	}
+	k.Bind("z", "=", "Spelling suggestions")
+	k.Bind("z", "g", "Add the word to the dictionary")
+	for _, prefix := range []string{"[", "]"} {
+		direction := "next"
+		if prefix == "[" {
+			direction = "previous"
+		}
+		k.Bind(prefix, "s", "Go to the "+direction+" misspelling")
+		k.Bind(prefix, "c", "Go to the "+direction+" difference")
+		k.Bind(prefix, "x", "Go to the "+direction+" conflict")
+	}
+	k.Bind(`"`, "{a-z}", "Use the register for the next command")
+	k.Bind("@", ":", "Repeat the last command line")
+	k.Bind("@", "{a-z}", "Play the macro recorded in the register")
+	k.Bind("@", "@", "Play the last macro again")
+	k.Bind("q", "{a-z}", "Record keys into the register until q")
+
+	for op, name := range operatorNames {
+		k.Bind(op, op[len(op)-1:], name+" lines")
+		for _, m := range operatorMotions {
+			k.Bind(op, m.Keys, name+" "+m.Description)
+		}
+	}
+	k.Bind("d", "o", "Obtain the difference")
+	k.Bind("d", "p", "Put the difference")
+	return k
+}
@@ -0,0 +1,69 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestKeymap_Bind(t *testing.T) {
+	k := NewKeymap()
+	k.Bind("g", "x", "first")
+	k.Bind("g", "a", "second")
+	k.Bind("g", "x", "replaced")
+
+	got := k.Continuations("g")
+	want := []KeyBinding{{"a", "second"}, {"x", "replaced"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Continuations = %v, want %v", got, want)
+	}
+	if got := k.Continuations("z"); len(got) != 0 {
+		t.Errorf("unbound prefix has continuations %v", got)
+	}
+}
+
+// hasKeys reports whether bindings include keys
+func hasKeys(bindings []KeyBinding, keys string) bool {
+	for _, b := range bindings {
+		if b.Keys == keys {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNormalMode_PendingKeys(t *testing.T) {
+	mode := NewNormalMode()
+	buf := buffer.New()
+	press := func(r rune) {
+		mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: r}, buf)
+	}
+
+	if pending, _ := mode.PendingKeys(); pending != "" {
+		t.Fatalf("keys pending before any were typed: %q", pending)
+	}
+
+	press('g')
+	pending, next := mode.PendingKeys()
+	if pending != "g" || !hasKeys(next, "g") || !hasKeys(next, "u") {
+		t.Errorf("after g: %q %v", pending, next)
+	}
+
+	press('u')
+	pending, next = mode.PendingKeys()
+	if pending != "gu" || !hasKeys(next, "u") || !hasKeys(next, "w") {
+		t.Errorf("after gu: %q %v", pending, next)
+	}
+
+	press('i')
+	pending, next = mode.PendingKeys()
+	if pending != "gui" || len(next) != 2 || !hasKeys(next, "p") || !hasKeys(next, "s") {
+		t.Errorf("after gui: %q %v", pending, next)
+	}
+
+	press('p')
+	if pending, _ := mode.PendingKeys(); pending != "" {
+		t.Errorf("keys still pending after the command completed: %q", pending)
+	}
+}
@@ -0,0 +1,74 @@
+package modes
+
+import (
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// leaderPrefix names the leader key in the keymap and in key hints
+const leaderPrefix = "<leader>"
+
+// LeaderMapping is a command line run by typing its keys after the leader
+// key in normal mode. A command ending in a space is left on the command
+// line to finish.
+type LeaderMapping struct {
+	Command     string
+	Description string
+}
+
+// SetLeader sets the leader key and the mappings typed after it, by keys.
+// A zero leader disables leader mappings.
+func (n *NormalMode) SetLeader(leader rune, mappings map[string]LeaderMapping) {
+	n.leader = leader
+	n.leaderMappings = mappings
+	n.keymap.bindings[leaderPrefix] = nil
+	for keys, m := range mappings {
+		description := m.Description
+		if description == "" {
+			description = ":" + strings.TrimSpace(m.Command)
+		}
+		n.keymap.Bind(leaderPrefix, keys, description)
+	}
+}
+
+// startsLeader reports whether ch starts a leader mapping
+func (n *NormalMode) startsLeader(ch rune) bool {
+	return n.leader != 0 && ch == n.leader && len(n.leaderMappings) > 0 &&
+		n.prefix == 0 && n.operator == ""
+}
+
+// handleLeaderKey adds a key typed after the leader, running the mapping
+// once the keys typed name one that no longer mapping starts with. Keys
+// that no mapping starts with, and keys other than characters, cancel it.
+func (n *NormalMode) handleLeaderKey(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	if event.Action != ui.KeyActionChar {
+		n.leading, n.leaderKeys = false, ""
+		return ModeResult{Handled: true}
+	}
+	keys := n.leaderKeys + string(event.Rune)
+
+	longer := false
+	for k := range n.leaderMappings {
+		if len(k) > len(keys) && strings.HasPrefix(k, keys) {
+			longer = true
+			break
+		}
+	}
+	mapping, exact := n.leaderMappings[keys]
+	switch {
+	case longer:
+		n.leaderKeys = keys
+		return ModeResult{Handled: true}
+	case exact:
+		n.leading, n.leaderKeys = false, ""
+		if n.runCommand == nil {
+			return ModeResult{Handled: true}
+		}
+		return n.runCommand(mapping.Command, buf)
+	default:
+		n.leading, n.leaderKeys = false, ""
+		return ModeResult{Handled: true}
+	}
+}
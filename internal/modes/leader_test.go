@@ -0,0 +1,105 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestNormalMode_LeaderMappings(t *testing.T) {
+	mm := NewModeManager()
+	mm.SetLeader(' ', map[string]LeaderMapping{
+		"sw": {Command: "StripWhitespace", Description: "Strip whitespace"},
+		"ff": {Command: "e "},
+	})
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "hello   ")
+	mm.SwitchToMode(ModeNormal, buf)
+
+	typeInto(mm, " s", buf)
+	pending, next := mm.PendingKeys()
+	if pending != "<leader>s" || len(next) != 1 || next[0].Keys != "w" || next[0].Description != "Strip whitespace" {
+		t.Errorf("pending %q with hints %v", pending, next)
+	}
+	typeInto(mm, "w", buf)
+	if got := buf.CurrentLine(); got != "hello" {
+		t.Errorf("mapping not run, line is %q", got)
+	}
+
+	// A command ending in a space is left on the command line
+	mm.SwitchToMode(ModeNormal, buf)
+	typeInto(mm, " ff", buf)
+	if commandLine, _, ok := mm.GetCommandInfo(); !ok || commandLine != ":e " {
+		t.Errorf("command line is %q, want :e ", commandLine)
+	}
+
+	// Unmapped keys cancel the leader without running anything
+	mm.SwitchToMode(ModeNormal, buf)
+	typeInto(mm, " x", buf)
+	if pending, _ := mm.PendingKeys(); pending != "" || buf.CurrentLine() != "hello" {
+		t.Errorf("unmapped keys left %q pending, line %q", pending, buf.CurrentLine())
+	}
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'x'}, buf)
+	if got := buf.CurrentLine(); got != "ello" {
+		t.Errorf("x after a cancelled leader should delete, line is %q", got)
+	}
+
+	// Escape cancels it too
+	typeInto(mm, " ", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	if pending, _ := mm.PendingKeys(); pending != "" {
+		t.Errorf("Escape left %q pending", pending)
+	}
+}
+
+func TestNormalMode_LeaderWithoutMappings(t *testing.T) {
+	mode := NewNormalMode()
+	mode.SetLeader(' ', nil)
+	buf := buffer.New()
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: ' '}, buf)
+	if pending, _ := mode.PendingKeys(); pending != "" {
+		t.Errorf("leader without mappings left %q pending", pending)
+	}
+}
+
+func TestModeManager_TimeOut(t *testing.T) {
+	mm := NewModeManager()
+	mm.SetLeader(' ', map[string]LeaderMapping{
+		"s":  {Command: "StripWhitespace"},
+		"sw": {Command: "e "},
+	})
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "one two   ")
+	buf.SetCursor(buffer.Position{})
+	mm.SwitchToMode(ModeNormal, buf)
+
+	// An operator timing out is dropped, so the next key moves
+	typeInto(mm, "2d", buf)
+	if !mm.AwaitingKey() {
+		t.Fatal("d should await a motion")
+	}
+	mm.TimeOut(buf)
+	if mm.AwaitingKey() || mm.Pending() != "" {
+		t.Errorf("timed out operator left %q pending", mm.Pending())
+	}
+	typeInto(mm, "w", buf)
+	if got := buf.Cursor(); got.Col != 4 || buf.CurrentLine() != "one two   " {
+		t.Errorf("w after the timeout moved to %v, line %q", got, buf.CurrentLine())
+	}
+
+	// A leader mapping that a longer one starts with runs
+	typeInto(mm, " s", buf)
+	mm.TimeOut(buf)
+	if got := buf.CurrentLine(); got != "one two" {
+		t.Errorf("timed out <leader>s didn't run its mapping, line is %q", got)
+	}
+
+	// Visual mode drops its prefix
+	typeInto(mm, "vg", buf)
+	mm.TimeOut(buf)
+	if mm.AwaitingKey() || mm.CurrentModeType() != ModeVisual {
+		t.Errorf("visual g didn't time out, mode %v", mm.CurrentModeType())
+	}
+}
@@ -0,0 +1,99 @@
+package modes
+
+import (
+	"unicode"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// maxMacroDepth limits macros running macros, so one running itself stops
+const maxMacroDepth = 20
+
+// macros records keys typed into q{a-z} and plays them back with @{a-z}
+type macros struct {
+	recording rune          // Register being recorded, 0 when not recording
+	keys      []ui.KeyEvent // Recorded so far
+	recorded  map[rune][]ui.KeyEvent
+	last      rune // Last macro played, for @@
+	depth     int  // Macros being played
+}
+
+// record adds a key typed while recording
+func (m *macros) record(event ui.KeyEvent) {
+	if m.recording != 0 && m.depth == 0 {
+		m.keys = append(m.keys, event)
+	}
+}
+
+// Record starts recording keys into register reg, a-z; A-Z appends to the
+// macro in a-z
+func (c *Context) Record(reg rune) {
+	if c.mm == nil {
+		return
+	}
+	m := &c.mm.macros
+	m.recording = unicode.ToLower(reg)
+	m.keys = nil
+	if unicode.IsUpper(reg) {
+		m.keys = append(m.keys, m.recorded[m.recording]...)
+	}
+}
+
+// StopRecording stops recording, without the q that stopped it
+func (c *Context) StopRecording() {
+	if c.mm == nil {
+		return
+	}
+	m := &c.mm.macros
+	if len(m.keys) > 0 {
+		m.keys = m.keys[:len(m.keys)-1]
+	}
+	if m.recorded == nil {
+		m.recorded = make(map[rune][]ui.KeyEvent)
+	}
+	m.recorded[m.recording] = m.keys
+	m.recording, m.keys = 0, nil
+}
+
+// Recording returns the register being recorded, or 0
+func (c *Context) Recording() rune {
+	if c.mm == nil {
+		return 0
+	}
+	return c.mm.macros.recording
+}
+
+// Replay types the keys of the macro in reg count times, or of the last
+// macro played when reg is @
+func (c *Context) Replay(reg rune, count int, buf *buffer.Buffer) ModeResult {
+	if c.mm == nil {
+		return ModeResult{Handled: true}
+	}
+	m := &c.mm.macros
+	if reg == '@' {
+		reg = m.last
+	}
+	reg = unicode.ToLower(reg)
+	keys, ok := m.recorded[reg]
+	if !ok || m.depth >= maxMacroDepth {
+		return ModeResult{Handled: true}
+	}
+	m.last = reg
+
+	m.depth++
+	defer func() { m.depth-- }()
+	for range count {
+		for _, key := range keys {
+			if result := c.mm.HandleInput(key, buf); result.ExitEditor {
+				return result
+			}
+		}
+	}
+	return ModeResult{Handled: true}
+}
+
+// isMacroRegister reports whether q and @ take reg
+func isMacroRegister(reg rune) bool {
+	return reg >= 'a' && reg <= 'z' || reg >= 'A' && reg <= 'Z'
+}
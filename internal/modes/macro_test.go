@@ -0,0 +1,62 @@
+package modes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestMacros_RecordAndReplay(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c", "d"})
+
+	typeInto(mm, "qaI-", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	typeInto(mm, "jq", buf)
+	if got := mm.Pending(); got != "" {
+		t.Errorf("expected recording over, pending %q", got)
+	}
+	typeInto(mm, "@a2@@", buf)
+
+	if got := strings.Join(buf.Lines(), ","); got != "-a,-b,-c,-d" {
+		t.Errorf("lines = %q", got)
+	}
+}
+
+func TestMacros_AppendAndStopRunaway(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"abcdef"})
+
+	// qB appends to b
+	typeInto(mm, "qbxqqBlq", buf)
+	typeInto(mm, "@b", buf)
+	if got := buf.Lines()[0]; got != "bdef" {
+		t.Errorf("line = %q", got)
+	}
+
+	// A macro playing itself stops
+	typeInto(mm, "qc@cq@c", buf)
+}
+
+func TestModeManager_Pending(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+
+	typeInto(mm, `"a2d`, buf)
+	if got := mm.Pending(); got != `"a2d` {
+		t.Errorf("pending = %q", got)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	typeInto(mm, "qz", buf)
+	if got := mm.Pending(); got != "recording @z" {
+		t.Errorf("pending while recording = %q", got)
+	}
+	typeInto(mm, "3", buf)
+	if got := mm.Pending(); got != "3  recording @z" {
+		t.Errorf("pending count while recording = %q", got)
+	}
+}
@@ -1,8 +1,15 @@
 package modes
 
 import (
+	"strings"
+
 	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/config"
 	"github.com/dshills/aied/internal/lsp"
+	"github.com/dshills/aied/internal/palette"
+	"github.com/dshills/aied/internal/registers"
+	"github.com/dshills/aied/internal/spell"
 	"github.com/dshills/aied/internal/ui"
 )
 
@@ -14,6 +21,10 @@ const (
 	ModeInsert
 	ModeVisual
 	ModeCommand
+	ModePalette
+	ModeHelp
+	ModeCompose
+	ModeSuggest
 )
 
 // String returns the string representation of the mode
@@ -27,6 +38,14 @@ func (m ModeType) String() string {
 		return "VISUAL"
 	case ModeCommand:
 		return "COMMAND"
+	case ModePalette:
+		return "PALETTE"
+	case ModeHelp:
+		return "HELP"
+	case ModeCompose:
+		return "COMPOSE"
+	case ModeSuggest:
+		return "SUGGEST"
 	default:
 		return "UNKNOWN"
 	}
@@ -61,19 +80,48 @@ type Mode interface {
 type ModeManager struct {
 	currentMode Mode
 	modes       map[ModeType]Mode
+	ctx         *Context // Shared by the modes
+	macros      macros
 }
 
 // NewModeManager creates a new mode manager
 func NewModeManager() *ModeManager {
 	mm := &ModeManager{
 		modes: make(map[ModeType]Mode),
+		ctx:   NewContext(commands.NewCommandExecutor()),
 	}
+	mm.ctx.mm = mm
 
 	// Register all available modes
 	mm.RegisterMode(NewNormalMode())
 	mm.RegisterMode(NewInsertMode())
 	mm.RegisterMode(NewVisualMode())
-	mm.RegisterMode(NewCommandMode())
+	commandMode := NewCommandMode()
+	mm.RegisterMode(commandMode)
+	mm.RegisterMode(NewPaletteMode(commandMode))
+	mm.RegisterMode(NewHelpMode())
+	mm.RegisterMode(NewComposeMode())
+	mm.RegisterMode(NewSuggestMode())
+	mm.ctx.Commands().SetKeyHelp(mm.keyHelp)
+
+	// The modes share registers
+	mm.SetRegisters(registers.NewStore())
+
+	// @: in normal mode repeats the last command line, leader mappings run
+	// command lines and / and ? read a search pattern on the command line
+	if normalMode, ok := mm.modes[ModeNormal].(*NormalMode); ok {
+		normalMode.repeatCommand = commandMode.RepeatLast
+		normalMode.runCommand = commandMode.run
+		normalMode.startSearch = commandMode.startSearch
+	}
+	// ga in visual mode starts an :align command line
+	if visualMode, ok := mm.modes[ModeVisual].(*VisualMode); ok {
+		visualMode.startCommand = func(line string) { commandMode.startWith(line, "") }
+	}
+	// Ctrl-R = in insert mode reads an expression on the command line
+	if insertMode, ok := mm.modes[ModeInsert].(*InsertMode); ok {
+		insertMode.startExpression = commandMode.startExpression
+	}
 
 	// Start in Normal mode
 	mm.SwitchToMode(ModeNormal, nil)
@@ -81,14 +129,19 @@ func NewModeManager() *ModeManager {
 	return mm
 }
 
-// RegisterMode registers a mode with the manager
+// RegisterMode registers a mode with the manager, giving it the editor
+// context if it uses one
 func (mm *ModeManager) RegisterMode(mode Mode) {
 	mm.modes[mode.Type()] = mode
+	if contextual, ok := mode.(Contextual); ok {
+		contextual.SetContext(mm.ctx)
+	}
 }
 
 // SwitchToMode switches to the specified mode
 func (mm *ModeManager) SwitchToMode(modeType ModeType, buf *buffer.Buffer) {
 	if newMode, exists := mm.modes[modeType]; exists {
+		previous := mm.CurrentModeType()
 		// Exit current mode if we have one
 		if mm.currentMode != nil && buf != nil {
 			mm.currentMode.OnExit(buf)
@@ -101,6 +154,11 @@ func (mm *ModeManager) SwitchToMode(modeType ModeType, buf *buffer.Buffer) {
 		if buf != nil {
 			mm.currentMode.OnEnter(buf)
 		}
+
+		// : in visual mode works on the selected lines
+		if commandMode, ok := newMode.(*CommandMode); ok && previous == ModeVisual && commandMode.prompt == ':' && commandMode.commandLine == "" {
+			commandMode.setLine("'<,'>")
+		}
 	}
 }
 
@@ -110,6 +168,9 @@ func (mm *ModeManager) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeRe
 		return ModeResult{Handled: false}
 	}
 
+	// A message stays until the next key
+	mm.ctx.message = ""
+	mm.macros.record(event)
 	result := mm.currentMode.HandleInput(event, buf)
 
 	// Handle mode switching
@@ -117,6 +178,19 @@ func (mm *ModeManager) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeRe
 		mm.SwitchToMode(*result.SwitchToMode, buf)
 	}
 
+	// The help, once opened, takes the keys until it is closed
+	if mm.ctx.Commands().HelpView() != nil && mm.CurrentModeType() == ModeNormal {
+		mm.SwitchToMode(ModeHelp, buf)
+	}
+	mm.openPick(buf)
+
+	// Each command outside insert mode is one undo step; an insert is
+	// recorded when it ends, even while it reads an expression for Ctrl-R =
+	insertMode, _ := mm.modes[ModeInsert].(*InsertMode)
+	if buf != nil && mm.currentMode.Type() != ModeInsert && (insertMode == nil || !insertMode.expression) {
+		buf.Commit()
+	}
+
 	return result
 }
 
@@ -149,6 +223,124 @@ func (mm *ModeManager) SetLSPManager(lspManager *lsp.Manager) {
 	}
 }
 
+// SetBufferProvider sets the buffers insert mode searches for word completion
+func (mm *ModeManager) SetBufferProvider(provider BufferProvider) {
+	if insertMode, ok := mm.modes[ModeInsert].(*InsertMode); ok {
+		insertMode.SetBufferProvider(provider)
+	}
+}
+
+// SetCommandContext sets what the ex commands the modes run work with
+func (mm *ModeManager) SetCommandContext(cc *commands.CommandContext) {
+	mm.ctx.executor.SetContext(cc)
+}
+
+// SetUserCommands replaces the user-defined commands the modes run with
+// defs
+func (mm *ModeManager) SetUserCommands(defs map[string]config.UserCommandConfig) error {
+	return mm.ctx.executor.SetUserCommands(defs)
+}
+
+// SetViewport sets how the modes find what the screen shows, for the
+// motions that follow display lines
+func (mm *ModeManager) SetViewport(viewport func() ui.Viewport) {
+	mm.ctx.viewport = viewport
+}
+
+// SetSpellChecker sets the spell checker for modes that support it
+func (mm *ModeManager) SetSpellChecker(checker *spell.Checker) {
+	if normalMode, ok := mm.modes[ModeNormal].(*NormalMode); ok {
+		normalMode.SetSpellChecker(checker)
+	}
+}
+
+// SetRegisters sets the register store shared by the modes and ex commands
+// that yank and paste
+func (mm *ModeManager) SetRegisters(store *registers.Store) {
+	mm.ctx.Commands().SetRegisters(store)
+	if normalMode, ok := mm.modes[ModeNormal].(*NormalMode); ok {
+		normalMode.SetRegisters(store)
+	}
+	if visualMode, ok := mm.modes[ModeVisual].(*VisualMode); ok {
+		visualMode.SetRegisters(store)
+	}
+	if insertMode, ok := mm.modes[ModeInsert].(*InsertMode); ok {
+		insertMode.SetRegisters(store)
+	}
+	if commandMode, ok := mm.modes[ModeCommand].(*CommandMode); ok {
+		commandMode.SetRegisters(store)
+	}
+}
+
+// SetCommentStyles sets the comment strings used by the gc operator
+func (mm *ModeManager) SetCommentStyles(styles map[string]CommentStyle) {
+	if normalMode, ok := mm.modes[ModeNormal].(*NormalMode); ok {
+		normalMode.SetCommentStyles(styles)
+	}
+	if visualMode, ok := mm.modes[ModeVisual].(*VisualMode); ok {
+		visualMode.SetCommentStyles(styles)
+	}
+}
+
+// SetLeader sets the leader key and the normal mode mappings typed after it
+func (mm *ModeManager) SetLeader(leader rune, mappings map[string]LeaderMapping) {
+	if normalMode, ok := mm.modes[ModeNormal].(*NormalMode); ok {
+		normalMode.SetLeader(leader, mappings)
+	}
+}
+
+// SetPaletteHistory sets where the commands run from the palette are
+// remembered, to list them first
+func (mm *ModeManager) SetPaletteHistory(history *palette.Frecency) {
+	if paletteMode, ok := mm.modes[ModePalette].(*PaletteMode); ok {
+		paletteMode.SetHistory(history)
+	}
+}
+
+// Palette returns the command palette to display, or nil when it isn't open
+func (mm *ModeManager) Palette() *ui.Palette {
+	if paletteMode, ok := mm.currentMode.(*PaletteMode); ok {
+		return paletteMode.Palette()
+	}
+	return nil
+}
+
+// Composer returns the prompt composer to display, or nil when it isn't
+// open
+func (mm *ModeManager) Composer() *ui.Composer {
+	if composeMode, ok := mm.currentMode.(*ComposeMode); ok {
+		return composeMode.Composer()
+	}
+	return nil
+}
+
+// Suggestion returns the text offered for insertion at the cursor, or nil
+// when there is none
+func (mm *ModeManager) Suggestion() *ui.Suggestion {
+	if suggestMode, ok := mm.currentMode.(*SuggestMode); ok {
+		return suggestMode.Suggestion()
+	}
+	return nil
+}
+
+// PendingKeys returns the keys typed so far of an unfinished normal mode
+// command, with the keys that may complete it
+func (mm *ModeManager) PendingKeys() (string, []KeyBinding) {
+	if normalMode, ok := mm.currentMode.(*NormalMode); ok {
+		return normalMode.PendingKeys()
+	}
+	return "", nil
+}
+
+// CommandCursor returns the column of the cursor in the command line, or
+// 0 outside command mode
+func (mm *ModeManager) CommandCursor() int {
+	if cmdMode, ok := mm.currentMode.(*CommandMode); ok {
+		return cmdMode.CommandCursor()
+	}
+	return 0
+}
+
 // GetCommandInfo returns command line and message if in command mode
 func (mm *ModeManager) GetCommandInfo() (string, string, bool) {
 	if mm.currentMode == nil {
@@ -160,4 +352,111 @@ func (mm *ModeManager) GetCommandInfo() (string, string, bool) {
 	}
 	
 	return "", "", false
-}
\ No newline at end of file
+}
+// Execute runs an ex command line on buf with the command mode's executor,
+// without going through the command line, for callers driving the editor
+// from outside
+func (mm *ModeManager) Execute(line string, buf *buffer.Buffer) commands.CommandResult {
+	result := mm.ctx.executor.Execute(line, buf)
+	mm.openPick(buf)
+	if buf != nil && mm.CurrentModeType() != ModeInsert {
+		buf.Commit()
+	}
+	return result
+}
+
+// Finish shows the result of a command whose work went on in the
+// background, a message of more than one line in a window, and opens what
+// it left to pick from, compose or accept on buf
+func (mm *ModeManager) Finish(result commands.CommandResult, buf *buffer.Buffer) {
+	if strings.Contains(result.Message, "\n") {
+		mm.ctx.OpenWindow("[result]", result.Message)
+	} else {
+		mm.ctx.Message(result.Message)
+	}
+	mm.openPick(buf)
+	if buf != nil && mm.CurrentModeType() != ModeInsert {
+		buf.Commit()
+	}
+}
+
+// openPick opens the palette on the list a command left to pick from, the
+// composer on the prompt it left to write, or the suggestion it left
+func (mm *ModeManager) openPick(buf *buffer.Buffer) {
+	state := mm.ctx.Commands()
+	if state.PickPending() && mm.CurrentModeType() == ModeNormal {
+		mm.SwitchToMode(ModePalette, buf)
+	}
+	if state.ComposePending() && mm.CurrentModeType() == ModeNormal {
+		mm.SwitchToMode(ModeCompose, buf)
+	}
+	if state.SuggestionPending() && mm.CurrentModeType() == ModeNormal {
+		mm.SwitchToMode(ModeSuggest, buf)
+	}
+}
+
+// Context returns the editor context the modes share
+func (mm *ModeManager) Context() *Context {
+	return mm.ctx
+}
+
+// Message returns what a mode last told the user, until the next key
+func (mm *ModeManager) Message() string {
+	return mm.ctx.message
+}
+
+// sequencer is a mode whose commands may take more than one key, which
+// gives up waiting for the rest after timeoutlen
+type sequencer interface {
+	// AwaitingKey reports whether a prefix, operator or leader waits for
+	// the key that completes it
+	AwaitingKey() bool
+	// TimeOut ends the keys waiting, running what they name if anything
+	TimeOut(buf *buffer.Buffer) ModeResult
+}
+
+// AwaitingKey reports whether the keys typed so far wait for another to
+// complete a command, and so time out
+func (mm *ModeManager) AwaitingKey() bool {
+	mode, ok := mm.currentMode.(sequencer)
+	return ok && mode.AwaitingKey()
+}
+
+// TimeOut ends a command whose keys have waited timeoutlen for the next,
+// as if no more were coming
+func (mm *ModeManager) TimeOut(buf *buffer.Buffer) ModeResult {
+	mode, ok := mm.currentMode.(sequencer)
+	if !ok || !mode.AwaitingKey() {
+		return ModeResult{Handled: true}
+	}
+	result := mode.TimeOut(buf)
+	if result.SwitchToMode != nil {
+		mm.SwitchToMode(*result.SwitchToMode, buf)
+	}
+	mm.openPick(buf)
+	if buf != nil && mm.currentMode.Type() != ModeInsert {
+		buf.Commit()
+	}
+	return result
+}
+
+// pendingTexter is a mode that may be partway through a command
+type pendingTexter interface {
+	PendingText() string
+}
+
+// Pending returns what the status line shows at its right of state that
+// is otherwise invisible: the keys of an unfinished command and the macro
+// being recorded
+func (mm *ModeManager) Pending() string {
+	var parts []string
+	if mode, ok := mm.currentMode.(pendingTexter); ok {
+		if text := mode.PendingText(); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if reg := mm.macros.recording; reg != 0 {
+		parts = append(parts, "recording @"+string(reg))
+	}
+	return strings.Join(parts, "  ")
+}
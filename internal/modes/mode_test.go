@@ -1,6 +1,7 @@
 package modes
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/dshills/aied/internal/buffer"
@@ -16,6 +17,8 @@ func TestModeType_String(t *testing.T) {
 		{ModeInsert, "INSERT"},
 		{ModeVisual, "VISUAL"},
 		{ModeCommand, "COMMAND"},
+		{ModePalette, "PALETTE"},
+		{ModeHelp, "HELP"},
 		{ModeType(999), "UNKNOWN"},
 	}
 
@@ -137,4 +140,184 @@ func TestModeManager_GetStatusText(t *testing.T) {
 	if statusText != "-- VISUAL --" {
 		t.Errorf("expected '-- VISUAL --', got %q", statusText)
 	}
-}
\ No newline at end of file
+}
+func TestModeManager_UndoSteps(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	keys := func(s string) {
+		for _, r := range s {
+			mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: r}, buf)
+		}
+	}
+	key := func(action ui.KeyAction) {
+		mm.HandleInput(ui.KeyEvent{Action: action}, buf)
+	}
+
+	// One insert is one undo step, split with Ctrl-G u
+	keys("iab")
+	key(ui.KeyActionCtrlG)
+	keys("ucd")
+	key(ui.KeyActionEscape)
+	if buf.CurrentLine() != "abcd" {
+		t.Fatalf("expected %q, got %q", "abcd", buf.CurrentLine())
+	}
+
+	keys("u")
+	if buf.CurrentLine() != "ab" {
+		t.Errorf("after u expected %q, got %q", "ab", buf.CurrentLine())
+	}
+	keys("u")
+	if buf.CurrentLine() != "" {
+		t.Errorf("after second u expected empty line, got %q", buf.CurrentLine())
+	}
+	key(ui.KeyActionCtrlR)
+	if buf.CurrentLine() != "ab" {
+		t.Errorf("after Ctrl-R expected %q, got %q", "ab", buf.CurrentLine())
+	}
+
+	// A new change branches; g- still reaches the undone text
+	keys("xg-")
+	if buf.CurrentLine() != "abcd" {
+		t.Errorf("after g- expected %q, got %q", "abcd", buf.CurrentLine())
+	}
+	keys("g+")
+	if buf.CurrentLine() != "b" {
+		t.Errorf("after g+ expected %q, got %q", "b", buf.CurrentLine())
+	}
+}
+
+func TestModeManager_RepeatCommandLine(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c", "d"})
+	keys := func(s string) {
+		for _, r := range s {
+			mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: r}, buf)
+		}
+	}
+
+	keys(":1d")
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	keys("2@:")
+	if buf.String() != "d" {
+		t.Errorf("expected %q after :1d and 2@:, got %q", "d", buf.String())
+	}
+}
+
+func TestModeManager_VisualCommandLine(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"d", "c", "b", "a"})
+	keys := func(s string) {
+		for _, r := range s {
+			mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: r}, buf)
+		}
+	}
+
+	keys("jvj:")
+	commandMode, _ := mm.CurrentMode().(*CommandMode)
+	if commandMode == nil || commandMode.GetCommandLine() != ":'<,'>" {
+		t.Fatalf("expected the command line %q after v:, got mode %s", ":'<,'>", mm.CurrentModeType())
+	}
+	keys("sort")
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if buf.String() != "d\nb\nc\na" {
+		t.Errorf("expected lines 2-3 sorted, got %q", buf.String())
+	}
+}
+
+func TestModeManager_VisualAlign(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a = 1", "bcd = 2", "ef = 3"})
+	keys := func(s string) {
+		for _, r := range s {
+			mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: r}, buf)
+		}
+	}
+
+	// ga starts :align on the selection for the delimiter to be typed
+	keys("vjga")
+	if line, _, _ := mm.GetCommandInfo(); line != ":'<,'>align " {
+		t.Fatalf("expected the command line %q after vga, got %q", ":'<,'>align ", line)
+	}
+	keys("=")
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if buf.String() != "a   = 1\nbcd = 2\nef = 3" {
+		t.Errorf("expected lines 1-2 aligned, got %q", buf.String())
+	}
+}
+
+func TestModeManager_AIScratchpadEnter(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+
+	// Enter does nothing in other buffers
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if mm.Context().message != "" {
+		t.Errorf("expected Enter to send nothing outside the AI scratchpad, got %q", mm.Context().message)
+	}
+
+	mm.Execute("aiscratch", buf)
+	buf.ReplaceLines(0, 0, []string{"a prompt"})
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if got := mm.Context().message; got != "AI manager not initialized" {
+		t.Errorf("expected Enter to run :aisend in the AI scratchpad, got %q", got)
+	}
+}
+
+func TestModeManager_VisualMoveLines(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a", "b", "c", "d"})
+
+	for _, r := range "vj" {
+		mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: r}, buf)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionAltDown}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionAltDown}, buf)
+	if buf.String() != "c\nd\na\nb" {
+		t.Errorf("expected the selection moved to the bottom, got %q", buf.String())
+	}
+	start, end := mm.modes[ModeVisual].(*VisualMode).GetSelection(buf)
+	if mm.CurrentModeType() != ModeVisual || start.Line != 2 || end.Line != 3 {
+		t.Errorf("expected lines 3-4 still selected, got %d-%d in %s", start.Line+1, end.Line+1, mm.CurrentModeType())
+	}
+
+	// One undo step per move
+	buf.Undo()
+	if buf.String() != "c\na\nb\nd" {
+		t.Errorf("expected one move undone, got %q", buf.String())
+	}
+}
+
+func TestModeManager_LSPKeysRunCommands(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.SetFilename("main.go")
+
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'g'}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'd'}, buf)
+	if got := mm.Message(); got != "LSP not available" {
+		t.Errorf("message after gd = %q", got)
+	}
+
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'l'}, buf)
+	if got := mm.Message(); got != "" {
+		t.Errorf("expected the message cleared by the next key, got %q", got)
+	}
+}
+
+func TestContext_OpenWindow(t *testing.T) {
+	mm := NewModeManager()
+	ctx := mm.Context()
+
+	ctx.OpenWindow("[Hover]", "func Println(a ...any)\n\nPrintln formats...")
+	title, lines, open := ctx.Commands().ExplanationPanel(80)
+	if !open || !strings.HasPrefix(title, "[Hover]") || len(lines) == 0 {
+		t.Errorf("window = %q %v %v", title, lines, open)
+	}
+	if mm.Message() != "" {
+		t.Errorf("expected no message for a window, got %q", mm.Message())
+	}
+}
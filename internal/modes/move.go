@@ -0,0 +1,21 @@
+package modes
+
+import "github.com/dshills/aied/internal/buffer"
+
+// moveLinesBy moves lines start..end down by lines, or up when negative,
+// stopping at the edges of the buffer. It keeps the cursor column and
+// returns how many lines the block moved.
+func moveLinesBy(buf *buffer.Buffer, start, end, lines int) int {
+	col := buf.Cursor().Col
+	lines = max(min(lines, buf.LineCount()-1-end), -start)
+	if lines == 0 {
+		return 0
+	}
+	dest := end + lines
+	if lines < 0 {
+		dest = start + lines - 1
+	}
+	buf.MoveLines(start, end, dest)
+	buf.SetCursor(buffer.Position{Line: buf.Cursor().Line, Col: col})
+	return lines
+}
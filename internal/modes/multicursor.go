@@ -0,0 +1,132 @@
+package modes
+
+import (
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// multiCursorKeys are the normal mode commands repeated at every cursor
+const multiCursorKeys = "hjklwbe0$^iaIAxX"
+
+// atCursors runs edit at every cursor when multiple cursors are active
+func atCursors(buf *buffer.Buffer, edit func()) {
+	if buf.HasMultipleCursors() {
+		buf.ApplyAtCursors(edit)
+		return
+	}
+	edit()
+}
+
+// handleCharacterAtCursors runs a normal mode command, repeating motions
+// and insert commands at every cursor when multiple cursors are active
+func (n *NormalMode) handleCharacterAtCursors(ch rune, buf *buffer.Buffer) ModeResult {
+	if !buf.HasMultipleCursors() || n.prefix != 0 || n.operator != "" || !strings.ContainsRune(multiCursorKeys, ch) {
+		return n.handleCharacter(ch, buf)
+	}
+
+	var result ModeResult
+	buf.ApplyAtCursors(func() {
+		result = n.handleCharacter(ch, buf)
+	})
+	return result
+}
+
+// addCursorAtNextMatch implements Ctrl-N: the first press selects the word under
+// the cursor, and every press adds a cursor at the next occurrence of that word
+func (n *NormalMode) addCursorAtNextMatch(buf *buffer.Buffer) ModeResult {
+	if !buf.HasMultipleCursors() {
+		word, ok := wordUnderCursor(buf)
+		if !ok {
+			return ModeResult{Handled: true}
+		}
+		n.multiCursorWord = word.Word
+		buf.SetCursor(buffer.Position{Line: buf.Cursor().Line, Col: word.Start})
+	}
+
+	// Search after the last cursor so matches are added in order
+	from := buf.Cursor()
+	if cursors := buf.Cursors(); len(cursors) > 0 && positionBefore(from, cursors[len(cursors)-1]) {
+		from = cursors[len(cursors)-1]
+	}
+
+	if pos, ok := findWordAfter(buf, n.multiCursorWord, from); ok {
+		buf.AddCursor(pos)
+	}
+	return ModeResult{Handled: true}
+}
+
+// findWordAfter finds the next whole-word occurrence of word after from, wrapping around the buffer
+func findWordAfter(buf *buffer.Buffer, word string, from buffer.Position) (buffer.Position, bool) {
+	if word == "" {
+		return buffer.Position{}, false
+	}
+
+	lineCount := buf.LineCount()
+	for i := 0; i <= lineCount; i++ {
+		lineNum := (from.Line + i) % lineCount
+		line, err := buf.Line(lineNum)
+		if err != nil {
+			return buffer.Position{}, false
+		}
+
+		start := 0
+		if i == 0 {
+			start = from.Col + 1
+		}
+		for start <= len(line) {
+			idx := strings.Index(line[start:], word)
+			if idx < 0 {
+				break
+			}
+			col := start + idx
+			end := col + len(word)
+			if i == lineCount && col >= from.Col {
+				// Wrapped back to where the search began
+				return buffer.Position{}, false
+			}
+			before := col == 0 || !isIdentifierChar(rune(line[col-1]))
+			after := end == len(line) || !isIdentifierChar(rune(line[end]))
+			if before && after {
+				return buffer.Position{Line: lineNum, Col: col}, true
+			}
+			start = col + 1
+		}
+	}
+	return buffer.Position{}, false
+}
+
+// addColumnCursors puts a cursor at col on every selected line, skipping lines
+// that are too short. With clampToEnd set short lines get a cursor at their end.
+func (v *VisualMode) addColumnCursors(buf *buffer.Buffer, col int, clampToEnd bool) {
+	start, end := v.GetSelection(buf)
+
+	buf.ClearCursors()
+	primary := true
+	for lineNum := start.Line; lineNum <= end.Line; lineNum++ {
+		line, err := buf.Line(lineNum)
+		if err != nil {
+			break
+		}
+		pos := buffer.Position{Line: lineNum, Col: col}
+		if col > len(line) {
+			if !clampToEnd {
+				continue
+			}
+			pos.Col = len(line)
+		}
+
+		if primary {
+			buf.SetCursor(pos)
+			primary = false
+		} else {
+			buf.AddCursor(pos)
+		}
+	}
+}
+
+// blockColumns returns the leftmost and rightmost columns of the selection corners
+func (v *VisualMode) blockColumns(buf *buffer.Buffer) (int, int) {
+	cursor := buf.Cursor()
+	return min(v.startPos.Col, cursor.Col), max(v.startPos.Col, cursor.Col)
+}
@@ -0,0 +1,63 @@
+package modes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestNormalMode_CtrlNAddsCursorsAtMatches(t *testing.T) {
+	mm := NewModeManager()
+	buf := newOperatorTestBuffer("foo := 1", "bar(foo)", "food(foo)")
+	buf.SetCursor(buffer.Position{Line: 0, Col: 1})
+
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlN}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlN}, buf)
+
+	expected := []buffer.Position{{Line: 1, Col: 4}, {Line: 2, Col: 5}}
+	if got := buf.Cursors(); len(got) != 2 || got[0] != expected[0] || got[1] != expected[1] {
+		t.Fatalf("expected cursors %v, got %v", expected, got)
+	}
+
+	// Insert at every cursor
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'i'}, buf)
+	for _, ch := range "my" {
+		mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: ch}, buf)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+
+	want := []string{"myfoo := 1", "bar(myfoo)", "food(myfoo)"}
+	if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// Escape in normal mode returns to a single cursor
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	if buf.HasMultipleCursors() {
+		t.Error("expected escape to clear secondary cursors")
+	}
+}
+
+func TestVisualMode_ColumnInsert(t *testing.T) {
+	buf := newOperatorTestBuffer("one", "two", "", "four")
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+
+	mode := NewVisualMode()
+	mode.OnEnter(buf)
+	buf.SetCursor(buffer.Position{Line: 3, Col: 0})
+
+	result := mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'I'}, buf)
+	if result.SwitchToMode == nil || *result.SwitchToMode != ModeInsert {
+		t.Fatal("expected I to enter insert mode")
+	}
+
+	insert := NewInsertMode()
+	insert.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: '-'}, buf)
+
+	want := []string{"-one", "-two", "-", "-four"}
+	if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
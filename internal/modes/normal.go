@@ -1,21 +1,64 @@
 package modes
 
 import (
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/registers"
+	"github.com/dshills/aied/internal/spell"
 	"github.com/dshills/aied/internal/ui"
 )
 
 // NormalMode implements VIM normal mode behavior
 type NormalMode struct {
 	lastCommand rune // For repeat operations (.)
-	gPrefix     bool // Whether 'g' was pressed (for two-char commands)
+	prefix      rune // Pending prefix key for two-char commands (g, z, [, ])
+	count       int  // Pending count typed before a command (0 if none)
+
+	operator      string // Pending operator waiting for a motion (d, y, gu, gU, g~, gq, gc)
+	operatorCount int    // Count typed before the operator
+	operatorRegister rune // Register selected before the operator
+	register      rune   // Register selected with "x for the next command
+	registers     *registers.Store
+	commentStyles map[string]CommentStyle // Configured comment strings by filetype
+
+	multiCursorWord string // Word Ctrl-N adds cursors at
+
+	repeatCommand func(buf *buffer.Buffer) ModeResult // Runs the last command line again for @:
+
+	keymap *Keymap // Describes the keys that may follow a prefix or operator
+
+	ctx            *Context                                         // Runs gd, gh and gr and shows their results
+	runCommand     func(line string, buf *buffer.Buffer) ModeResult // Runs a command line for leader mappings
+	startSearch    func(backward bool, count int)                    // Reads a search pattern on the command line for / and ?
+	leader         rune                                             // Key starting leader mappings, 0 for none
+	leaderMappings map[string]LeaderMapping                         // Leader mappings by the keys after the leader
+	leading        bool                                             // Whether the leader was typed
+	leaderKeys     string                                           // Keys typed after the leader so far
+
+	spellChecker  *spell.Checker
+	suggestions   *ui.CompletionPopup // Spelling suggestions shown by z=
+	suggestTarget spell.Misspelling   // Word the suggestions replace
+	suggestLine   int                 // Line of the word the suggestions replace
 }
 
 // NewNormalMode creates a new normal mode instance
 func NewNormalMode() *NormalMode {
-	return &NormalMode{}
+	return &NormalMode{registers: registers.NewStore(), keymap: defaultKeymap(), suggestions: ui.NewCompletionPopup()}
+}
+
+// SetContext sets the editor context commands run in
+func (n *NormalMode) SetContext(ctx *Context) {
+	n.ctx = ctx
+}
+
+// SetRegisters sets the register store used by d, y and p
+func (n *NormalMode) SetRegisters(store *registers.Store) {
+	n.registers = store
 }
 
 // Type returns the mode type
@@ -23,43 +66,210 @@ func (n *NormalMode) Type() ModeType {
 	return ModeNormal
 }
 
+// SetCommentStyles sets the comment strings used by gc, overriding the defaults per filetype
+func (n *NormalMode) SetCommentStyles(styles map[string]CommentStyle) {
+	n.commentStyles = styles
+}
+
+// SetSpellChecker sets the spell checker used by ]s, [s, z= and zg
+func (n *NormalMode) SetSpellChecker(checker *spell.Checker) {
+	n.spellChecker = checker
+}
+
 // HandleInput processes keyboard input in normal mode
 func (n *NormalMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	if n.suggestions.IsVisible() {
+		return n.handleSuggestionInput(event, buf)
+	}
+	if n.leading {
+		n.count = 0
+		return n.handleLeaderKey(event, buf)
+	}
+
+	// Accumulate a count; 0 only extends a count already started
+	if event.Action == ui.KeyActionChar && n.prefix == 0 &&
+		(event.Rune >= '1' && event.Rune <= '9' || event.Rune == '0' && n.count > 0) {
+		n.count = n.count*10 + int(event.Rune-'0')
+		return ModeResult{Handled: true}
+	}
+
+	// Only characters can complete a pending operator
+	if n.operator != "" && event.Action != ui.KeyActionChar {
+		n.cancelOperator()
+		n.count = 0
+		return ModeResult{Handled: true}
+	}
+
+	selectingRegister := n.prefix == '"'
+
+	var result ModeResult
 	switch event.Action {
 	case ui.KeyActionChar:
-		return n.handleCharacter(event.Rune, buf)
+		if n.startsLeader(event.Rune) {
+			n.leading = true
+			result = ModeResult{Handled: true}
+		} else {
+			result = n.handleCharacterAtCursors(event.Rune, buf)
+		}
 	case ui.KeyActionUp, ui.KeyActionDown, ui.KeyActionLeft, ui.KeyActionRight:
-		return n.handleArrowKeys(event.Action, buf)
+		result = n.handleArrowKeys(event.Action, buf)
 	case ui.KeyActionHome, ui.KeyActionEnd:
-		return n.handleHomeEnd(event.Action, buf)
+		result = n.handleHomeEnd(event.Action, buf)
+	case ui.KeyActionCtrlA:
+		result = n.incrementNumber(buf, int64(n.countOrOne()))
+	case ui.KeyActionCtrlX:
+		result = n.incrementNumber(buf, -int64(n.countOrOne()))
+	case ui.KeyActionCtrlN:
+		result = n.addCursorAtNextMatch(buf)
+	case ui.KeyActionCtrlP:
+		result = ModeResult{SwitchToMode: &[]ModeType{ModePalette}[0], Handled: true}
+	case ui.KeyActionCtrlG:
+		// g Ctrl-G counts the words and characters up to the cursor
+		if n.prefix != 'g' || n.ctx == nil {
+			result = ModeResult{Handled: false}
+			break
+		}
+		n.prefix = 0
+		n.ctx.Execute("stats", buf)
+		result = ModeResult{Handled: true}
+	case ui.KeyActionAltUp, ui.KeyActionAltDown:
+		// Move the line up or down, keeping the cursor column
+		by, line := n.countOrOne(), buf.Cursor().Line
+		if event.Action == ui.KeyActionAltUp {
+			by = -by
+		}
+		moveLinesBy(buf, line, line, by)
+		result = ModeResult{Handled: true}
+	case ui.KeyActionCtrlR:
+		for i := 0; i < n.countOrOne(); i++ {
+			if !buf.Redo() {
+				break
+			}
+		}
+		result = ModeResult{Handled: true}
+	case ui.KeyActionEnter:
+		// Enter sends the prompt at the cursor in the AI scratchpad
+		if buf.Options().Scratch != commands.AIScratchpad || n.ctx == nil {
+			result = ModeResult{Handled: false}
+			break
+		}
+		n.ctx.Execute("aisend", buf)
+		result = ModeResult{Handled: true}
+	case ui.KeyActionEscape:
+		// Drop back to a single cursor
+		buf.ClearCursors()
+		result = ModeResult{Handled: true}
 	case ui.KeyActionCtrlC:
-		return ModeResult{ExitEditor: true, Handled: true}
+		result = ModeResult{ExitEditor: true, Handled: true}
 	default:
-		return ModeResult{Handled: false}
+		result = ModeResult{Handled: false}
 	}
+
+	// The count applies to the command that follows it, which may span a prefix key
+	if n.prefix == 0 {
+		n.count = 0
+		if !selectingRegister {
+			n.register = 0
+		}
+	}
+	return result
+}
+
+// countOrOne returns the pending count, defaulting to 1
+func (n *NormalMode) countOrOne() int {
+	if n.count > 0 {
+		return n.count
+	}
+	return 1
 }
 
 // handleCharacter processes character input in normal mode
 func (n *NormalMode) handleCharacter(ch rune, buf *buffer.Buffer) ModeResult {
-	// Handle g-prefix commands
-	if n.gPrefix {
-		n.gPrefix = false
+	if n.operator != "" {
+		return n.handleOperatorMotion(ch, buf)
+	}
+
+	// Handle prefixed two-char commands
+	if n.prefix != 0 {
+		prefix := n.prefix
+		n.prefix = 0
+		switch prefix {
+		case 'z':
+			return n.handleZCommand(ch, buf)
+		case '[', ']':
+			return n.handleBracketCommand(prefix, ch, buf)
+		case '"':
+			if registers.IsReadable(ch) {
+				n.register = ch
+			}
+			return ModeResult{Handled: true}
+		case '@':
+			return n.repeatCommandLine(ch, buf)
+		case 'q':
+			if isMacroRegister(ch) && n.ctx != nil {
+				n.ctx.Record(ch)
+			}
+			return ModeResult{Handled: true}
+		}
 		switch ch {
 		case 'd':
 			// Go to definition
-			return n.executeLSPCommand(":definition", buf)
+			return n.executeLSPCommand("definition", buf)
 		case 'h':
 			// Show hover information
-			return n.executeLSPCommand(":hover", buf)
+			return n.executeLSPCommand("hover", buf)
 		case 'r':
 			// Find references
-			return n.executeLSPCommand(":references", buf)
+			return n.executeLSPCommand("references", buf)
+		case 'f':
+			// Open the file named under the cursor
+			return n.executeLSPCommand("find", buf)
+		case 'x':
+			// Open the URL under the cursor in the browser
+			return n.executeLSPCommand("Open", buf)
 		case 'g':
 			// gg - go to first line
 			buf.SetCursor(buffer.Position{Line: 0, Col: 0})
 			return ModeResult{Handled: true}
+		case 'j', 'k', '0', '$':
+			// Display line movement
+			buf.SetCursor(displayLineMotion(ch, buf, buf.Cursor(), n.countOrOne(), n.ctx.TextWidth()))
+			return ModeResult{Handled: true}
+		case 'u', 'U', '~', 'q', 'c':
+			// Case, format and comment operators
+			return n.startOperator("g" + string(ch))
+		case '-':
+			// Older text state in time, across undo branches
+			buf.Earlier(n.countOrOne())
+			return ModeResult{Handled: true}
+		case '+':
+			// Newer text state in time
+			buf.Later(n.countOrOne())
+			return ModeResult{Handled: true}
+		case 'e':
+			// End of the word before
+			buf.SetCursor(wordEndBackward(buf, buf.Cursor(), n.countOrOne()))
+			return ModeResult{Handled: true}
+		case '_':
+			// Last non-blank character, count-1 lines down
+			buf.SetCursor(lastNonBlank(buf, min(buf.Cursor().Line+n.countOrOne()-1, buf.LineCount()-1)))
+			return ModeResult{Handled: true}
+		case 'I':
+			// Insert at the start of the line, before any indent
+			buf.SetCursor(buffer.Position{Line: buf.Cursor().Line})
+			return ModeResult{SwitchToMode: &[]ModeType{ModeInsert}[0], Handled: true}
+		case 'v':
+			// Select the last visual area again
+			if _, _, ok := buf.VisualArea(); !ok || n.ctx == nil {
+				return ModeResult{Handled: true}
+			}
+			n.ctx.reselect = true
+			return ModeResult{SwitchToMode: &[]ModeType{ModeVisual}[0], Handled: true}
 		default:
 			// Unknown g command
+			if n.ctx != nil {
+				n.ctx.Message("Unknown command: g" + string(ch))
+			}
 			return ModeResult{Handled: true}
 		}
 	}
@@ -91,6 +301,11 @@ func (n *NormalMode) handleCharacter(ch rune, buf *buffer.Buffer) ModeResult {
 	case 'e':
 		return n.moveToWordEnd(buf)
 
+	// Paragraph and sentence movement
+	case '{', '}', '(', ')':
+		buf.SetCursor(proseMotion(ch, buf, buf.Cursor(), n.countOrOne()))
+		return ModeResult{Handled: true}
+
 	// Mode switching
 	case 'i':
 		return ModeResult{SwitchToMode: &[]ModeType{ModeInsert}[0], Handled: true}
@@ -123,31 +338,54 @@ func (n *NormalMode) handleCharacter(ch rune, buf *buffer.Buffer) ModeResult {
 	case ':':
 		return ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
 
+	// Search
+	case '/', '?':
+		if n.startSearch == nil {
+			return ModeResult{Handled: true}
+		}
+		n.startSearch(ch == '?', n.countOrOne())
+		return ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
+	case 'n', 'N':
+		return n.searchNext(ch == 'N', buf)
+
 	// Deletion
 	case 'x':
 		return n.deleteChar(buf)
 	case 'X':
 		return n.deleteCharBefore(buf)
 
-	// Line operations
+	// Delete and yank operators (dd and yy for lines)
 	case 'd':
-		// TODO: Implement dd (delete line) with count support
-		return ModeResult{Handled: true}
+		return n.startOperator("d")
 	case 'y':
-		// TODO: Implement yy (yank line) with count support
-		return ModeResult{Handled: true}
-	case 'p':
-		// TODO: Implement paste
+		return n.startOperator("y")
+
+	// Paste
+	case 'p', 'P':
+		put(buf, n.registers, n.register, n.countOrOne(), ch == 'P')
 		return ModeResult{Handled: true}
 
-	// Undo/Redo
+	// Undo; Ctrl-R redoes
 	case 'u':
-		// TODO: Implement undo
+		for i := 0; i < n.countOrOne(); i++ {
+			if !buf.Undo() {
+				break
+			}
+		}
 		return ModeResult{Handled: true}
-	
+
+	// q stops recording a macro, or starts recording one with q{a-z}
+	case 'q':
+		if n.ctx != nil && n.ctx.Recording() != 0 {
+			n.ctx.StopRecording()
+			return ModeResult{Handled: true}
+		}
+		n.prefix = ch
+		return ModeResult{Handled: true}
+
 	// Two-character commands
-	case 'g':
-		n.gPrefix = true
+	case 'g', 'z', '[', ']', '"', '@':
+		n.prefix = ch
 		return ModeResult{Handled: true}
 
 	default:
@@ -155,6 +393,25 @@ func (n *NormalMode) handleCharacter(ch rune, buf *buffer.Buffer) ModeResult {
 	}
 }
 
+// repeatCommandLine handles @: which runs the last command line again,
+// and @{a-z} and @@ which play a macro, count times
+func (n *NormalMode) repeatCommandLine(ch rune, buf *buffer.Buffer) ModeResult {
+	if (isMacroRegister(ch) || ch == '@') && n.ctx != nil {
+		// The macro's own keys mustn't see the count
+		count := n.countOrOne()
+		n.count = 0
+		return n.ctx.Replay(ch, count, buf)
+	}
+	if ch != ':' || n.repeatCommand == nil {
+		return ModeResult{Handled: true}
+	}
+	var result ModeResult
+	for i := 0; i < n.countOrOne() && !result.ExitEditor; i++ {
+		result = n.repeatCommand(buf)
+	}
+	return result
+}
+
 // Movement methods
 func (n *NormalMode) moveLeft(buf *buffer.Buffer) ModeResult {
 	buf.MoveCursor(0, -1)
@@ -335,6 +592,57 @@ func (n *NormalMode) moveToWordEnd(buf *buffer.Buffer) ModeResult {
 	return ModeResult{Handled: true}
 }
 
+// wordEndBackward returns where ge moves from pos count times: the last
+// character of the word before, words being separated by whitespace and
+// line breaks as for e
+func wordEndBackward(buf *buffer.Buffer, pos buffer.Position, count int) buffer.Position {
+	for i := 0; i < count; i++ {
+		n := pos.Line
+		line, _ := buf.Line(n)
+		col := min(pos.Col, len(line))
+
+		// Back over the rest of the word under the cursor
+		if r, _ := utf8.DecodeRuneInString(line[col:]); col < len(line) && !unicode.IsSpace(r) {
+			for col > 0 {
+				r, size := utf8.DecodeLastRuneInString(line[:col])
+				if unicode.IsSpace(r) {
+					break
+				}
+				col -= size
+			}
+		}
+
+		// Then over whitespace and line breaks to the end of the word before
+		for {
+			if col == 0 {
+				if n == 0 {
+					return buffer.Position{}
+				}
+				n--
+				line, _ = buf.Line(n)
+				col = len(line)
+				continue
+			}
+			r, size := utf8.DecodeLastRuneInString(line[:col])
+			if !unicode.IsSpace(r) {
+				pos = buffer.Position{Line: n, Col: col - size}
+				break
+			}
+			col -= size
+		}
+	}
+	return pos
+}
+
+// lastNonBlank returns where g_ moves on line n: its last character that
+// isn't whitespace, or its start when it is blank
+func lastNonBlank(buf *buffer.Buffer, n int) buffer.Position {
+	line, _ := buf.Line(n)
+	trimmed := strings.TrimRightFunc(line, unicode.IsSpace)
+	_, size := utf8.DecodeLastRuneInString(trimmed)
+	return buffer.Position{Line: n, Col: len(trimmed) - size}
+}
+
 // Line operations
 func (n *NormalMode) openLineBelow(buf *buffer.Buffer) ModeResult {
 	cursor := buf.Cursor()
@@ -393,6 +701,8 @@ func (n *NormalMode) handleHomeEnd(action ui.KeyAction, buf *buffer.Buffer) Mode
 
 // Mode lifecycle methods
 func (n *NormalMode) OnEnter(buf *buffer.Buffer) {
+	n.cancelOperator()
+	n.count = 0
 	if buf == nil {
 		return
 	}
@@ -410,16 +720,95 @@ func (n *NormalMode) OnExit(buf *buffer.Buffer) {
 }
 
 func (n *NormalMode) GetStatusText() string {
-	if n.gPrefix {
-		return "g"
+	if n.suggestions.IsVisible() {
+		return "z= (Enter to replace, Esc to cancel)"
 	}
 	return ""
 }
 
-// executeLSPCommand executes an LSP command via command mode
+// PendingText returns the register, count, operator and prefix typed so
+// far of an unfinished command, like "a2d
+func (n *NormalMode) PendingText() string {
+	status := ""
+	if n.register != 0 {
+		status = "\"" + string(n.register)
+	}
+	if n.operatorRegister != 0 {
+		status = "\"" + string(n.operatorRegister)
+	}
+	if n.operatorCount > 0 {
+		status += strconv.Itoa(n.operatorCount)
+	}
+	status += n.operator
+	if n.count > 0 {
+		status += strconv.Itoa(n.count)
+	}
+	if n.prefix != 0 {
+		status += string(n.prefix)
+	}
+	if n.leading {
+		status += leaderPrefix + n.leaderKeys
+	}
+	return status
+}
+
+// PendingKeys returns the prefix or operator keys typed so far, with the keys
+// that may complete them. It is "" when no keys are pending.
+func (n *NormalMode) PendingKeys() (string, []KeyBinding) {
+	if n.leading {
+		return leaderPrefix + n.leaderKeys, narrow(n.keymap.Continuations(leaderPrefix), n.leaderKeys)
+	}
+	if n.operator == "" && n.prefix == 0 {
+		return "", nil
+	}
+	pending := n.operator
+	if n.prefix != 0 {
+		pending += string(n.prefix)
+	}
+	if n.operator == "" {
+		return pending, n.keymap.Continuations(pending)
+	}
+
+	// Keys typed after the operator narrow down its motions
+	return pending, narrow(n.keymap.Continuations(n.operator), pending[len(n.operator):])
+}
+
+// AwaitingKey reports whether a prefix, operator or leader waits for the
+// key that completes it
+func (n *NormalMode) AwaitingKey() bool {
+	return n.prefix != 0 || n.operator != "" || n.leading
+}
+
+// TimeOut ends the keys waiting for the next: a leader mapping that longer
+// ones start with runs, and anything else is dropped
+func (n *NormalMode) TimeOut(buf *buffer.Buffer) ModeResult {
+	mapping, exact := n.leaderMappings[n.leaderKeys]
+	leading := n.leading
+	n.cancelOperator()
+	n.count, n.register = 0, 0
+	n.leading, n.leaderKeys = false, ""
+	if leading && exact && n.runCommand != nil {
+		return n.runCommand(mapping.Command, buf)
+	}
+	return ModeResult{Handled: true}
+}
+
+// narrow returns the bindings starting with typed, without it
+func narrow(bindings []KeyBinding, typed string) []KeyBinding {
+	var next []KeyBinding
+	for _, b := range bindings {
+		if rest, ok := strings.CutPrefix(b.Keys, typed); ok && rest != "" {
+			next = append(next, KeyBinding{Keys: rest, Description: b.Description})
+		}
+	}
+	return next
+}
+
+// executeLSPCommand runs a language server command for gd, gh and gr,
+// showing its result
 func (n *NormalMode) executeLSPCommand(command string, buf *buffer.Buffer) ModeResult {
-	// We need to execute the command through the command system
-	// For now, just return a message
-	// TODO: Integrate with command execution system
+	if n.ctx != nil {
+		n.ctx.Execute(command, buf)
+	}
 	return ModeResult{Handled: true}
 }
\ No newline at end of file
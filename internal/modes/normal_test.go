@@ -1,6 +1,7 @@
 package modes
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/dshills/aied/internal/buffer"
@@ -249,6 +250,25 @@ func TestNormalMode_OpenLines(t *testing.T) {
 	}
 }
 
+func TestNormalMode_MoveLines(t *testing.T) {
+	mode := NewNormalMode()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a", "bee", "c", "d"})
+	buf.SetCursor(buffer.Position{Line: 1, Col: 2})
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionAltDown}, buf)
+	if buf.String() != "a\nc\nbee\nd" || buf.Cursor() != (buffer.Position{Line: 2, Col: 2}) {
+		t.Errorf("expected the line moved down with the cursor, got %q at %+v", buf.String(), buf.Cursor())
+	}
+
+	// A count past the top stops at the first line
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: '5'}, buf)
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionAltUp}, buf)
+	if buf.String() != "bee\na\nc\nd" || buf.Cursor().Line != 0 {
+		t.Errorf("expected the line moved to the top, got %q", buf.String())
+	}
+}
+
 func TestNormalMode_OnEnter(t *testing.T) {
 	mode := NewNormalMode()
 	buf := buffer.New()
@@ -264,4 +284,85 @@ func TestNormalMode_OnEnter(t *testing.T) {
 	if cursor.Col != 1 { // Should be on last character
 		t.Errorf("expected cursor to be adjusted to column 1, got %d", cursor.Col)
 	}
-}
\ No newline at end of file
+}
+func TestCountWords(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"one two three"})
+	mm.SwitchToMode(ModeNormal, buf)
+
+	typeInto(mm, "g", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlG}, buf)
+	if got := mm.Message(); !strings.HasPrefix(got, "Col 1 of 13; Line 1 of 1; Word 1 of 3") {
+		t.Errorf("g Ctrl-G = %q", got)
+	}
+
+	// Over a selection, only the selected text is counted
+	typeInto(mm, "vwwg", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlG}, buf)
+	if got := mm.Message(); got != "Selected 1 of 1 Lines; 3 of 3 Words; 9 of 14 Chars; 9 of 14 Bytes" {
+		t.Errorf("g Ctrl-G in visual mode = %q", got)
+	}
+	if mm.CurrentModeType() != ModeVisual {
+		t.Errorf("g Ctrl-G left visual mode for %v", mm.CurrentModeType())
+	}
+}
+
+func TestNormalMode_GCommands(t *testing.T) {
+	lines := []string{"one two  ", "", "  thrée four  "}
+	tests := []struct {
+		keys   string
+		cursor buffer.Position
+		want   buffer.Position
+		line   string // The first line after the keys, when they change it
+	}{
+		{"ge", buffer.Position{Col: 5}, buffer.Position{Col: 2}, ""},
+		{"ge", buffer.Position{Line: 2, Col: 3}, buffer.Position{Col: 6}, ""},
+		{"ge", buffer.Position{Line: 2, Col: 10}, buffer.Position{Line: 2, Col: 7}, ""},
+		{"2ge", buffer.Position{Line: 2, Col: 10}, buffer.Position{Col: 6}, ""},
+		{"ge", buffer.Position{Col: 1}, buffer.Position{}, ""},
+		{"g_", buffer.Position{}, buffer.Position{Col: 6}, ""},
+		{"3g_", buffer.Position{}, buffer.Position{Line: 2, Col: 12}, ""},
+		{"dge", buffer.Position{Col: 5}, buffer.Position{Col: 2}, "ono  "},
+		{"dg_", buffer.Position{Col: 4}, buffer.Position{Col: 4}, "one   "},
+	}
+	for _, tt := range tests {
+		mode := NewNormalMode()
+		buf := newOperatorTestBuffer(lines...)
+		buf.SetCursor(tt.cursor)
+		typeKeys(mode, buf, tt.keys)
+		if got := buf.Cursor(); got != tt.want {
+			t.Errorf("%s from %v moved to %v, want %v", tt.keys, tt.cursor, got, tt.want)
+		}
+		if got, _ := buf.Line(0); tt.line != "" && got != tt.line {
+			t.Errorf("%s from %v left %q, want %q", tt.keys, tt.cursor, got, tt.line)
+		}
+	}
+
+	mm := NewModeManager()
+	buf := newOperatorTestBuffer(lines...)
+	buf.SetCursor(buffer.Position{Line: 2, Col: 5})
+	mm.SwitchToMode(ModeNormal, buf)
+
+	// gI inserts before the indent
+	typeInto(mm, "gIx", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	if got, _ := buf.Line(2); got != "x  thrée four  " {
+		t.Errorf("gIx left %q", got)
+	}
+
+	// gv selects the last visual area again
+	buf.SetCursor(buffer.Position{Col: 1})
+	typeInto(mm, "vll", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	typeInto(mm, "0gvd", buf)
+	if got, _ := buf.Line(0); got != "otwo  " {
+		t.Errorf("gvd left %q", got)
+	}
+
+	// Unknown commands are reported
+	typeInto(mm, "gQ", buf)
+	if got := mm.Message(); got != "Unknown command: gQ" {
+		t.Errorf("gQ showed %q", got)
+	}
+}
@@ -0,0 +1,310 @@
+package modes
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// textRange is the region an operator applies to. For characterwise ranges
+// End is exclusive; for linewise ranges only the line numbers are used.
+type textRange struct {
+	Start    buffer.Position
+	End      buffer.Position
+	Linewise bool
+}
+
+// startOperator makes op (d, y, gu, gU, g~, gq, gc) wait for a motion
+func (n *NormalMode) startOperator(op string) ModeResult {
+	n.operator = op
+	n.operatorCount = n.count
+	n.operatorRegister = n.register
+	return ModeResult{Handled: true}
+}
+
+// cancelOperator discards a pending operator
+func (n *NormalMode) cancelOperator() {
+	n.operator = ""
+	n.operatorCount = 0
+	n.operatorRegister = 0
+	n.prefix = 0
+}
+
+// operatorEnv returns the settings for the pending operator
+func (n *NormalMode) operatorEnv() operatorEnv {
+	return operatorEnv{
+		commentStyles: n.commentStyles,
+		registers:     n.registers,
+		register:      n.operatorRegister,
+	}
+}
+
+// handleOperatorMotion resolves the motion or text object following a pending operator
+func (n *NormalMode) handleOperatorMotion(ch rune, buf *buffer.Buffer) ModeResult {
+	op := n.operator
+	env := n.operatorEnv()
+	count := max(n.operatorCount, 1) * n.countOrOne()
+	cursor := buf.Cursor()
+
+	prefix := n.prefix
+	n.prefix = 0
+	switch prefix {
+	case 'g':
+		switch {
+		case ch == 'g':
+			// gugg - to first line
+			n.cancelOperator()
+			return n.applyOperator(op, env, textRange{Start: buffer.Position{Line: 0}, End: cursor, Linewise: true}, buf)
+		case "g"+string(ch) == op:
+			// gugu - repeated operator works on lines
+			n.cancelOperator()
+			return n.applyOperator(op, env, n.lineRange(cursor.Line, count, buf), buf)
+		case ch == 'e':
+			// dge - back to the end of the word before, inclusive
+			n.cancelOperator()
+			return n.applyOperator(op, env, charRange(buf, cursor, wordEndBackward(buf, cursor, count), true), buf)
+		case ch == '_':
+			// dg_ - to the last non-blank character, inclusive
+			n.cancelOperator()
+			end := lastNonBlank(buf, min(cursor.Line+count-1, buf.LineCount()-1))
+			return n.applyOperator(op, env, charRange(buf, cursor, end, true), buf)
+		}
+		n.cancelOperator()
+		return ModeResult{Handled: true}
+	case 'i', 'a':
+		n.cancelOperator()
+		switch ch {
+		case 'p':
+			r := paragraphRange(buf, cursor.Line, prefix == 'a')
+			for i := 1; i < count && r.End.Line < buf.LineCount()-1; i++ {
+				r.End = paragraphRange(buf, r.End.Line+1, prefix == 'a').End
+			}
+			return n.applyOperator(op, env, r, buf)
+		case 's':
+			return n.applyOperator(op, env, sentenceRange(buf, cursor, count, prefix == 'a'), buf)
+		}
+		return ModeResult{Handled: true}
+	}
+
+	// do and dp obtain and put differences in diff mode
+	if op == "d" && (ch == 'o' || ch == 'p') {
+		n.cancelOperator()
+		if n.ctx == nil {
+			return ModeResult{Handled: true}
+		}
+		if ch == 'o' {
+			n.ctx.Commands().DiffGet(buf)
+		} else {
+			n.ctx.Commands().DiffPut(buf)
+		}
+		return ModeResult{Handled: true}
+	}
+
+	// Repeated last key (dd, yy, guu, gUU, g~~, gqq, gcc) works on lines
+	if string(ch) == op[len(op)-1:] {
+		n.cancelOperator()
+		return n.applyOperator(op, env, n.lineRange(cursor.Line, count, buf), buf)
+	}
+
+	switch ch {
+	case 'g', 'i', 'a':
+		n.prefix = ch
+		return ModeResult{Handled: true}
+	case 'j':
+		n.cancelOperator()
+		end := min(cursor.Line+count, buf.LineCount()-1)
+		return n.applyOperator(op, env, textRange{Start: cursor, End: buffer.Position{Line: end}, Linewise: true}, buf)
+	case 'k':
+		n.cancelOperator()
+		start := max(cursor.Line-count, 0)
+		return n.applyOperator(op, env, textRange{Start: buffer.Position{Line: start}, End: cursor, Linewise: true}, buf)
+	case 'G':
+		n.cancelOperator()
+		return n.applyOperator(op, env, textRange{Start: cursor, End: buffer.Position{Line: buf.LineCount() - 1}, Linewise: true}, buf)
+	case '{', '}', '(', ')':
+		n.cancelOperator()
+		start, end := cursor, proseMotion(ch, buf, cursor, count)
+		if positionBefore(end, start) {
+			start, end = end, start
+		}
+		return n.applyOperator(op, env, motionRange(buf, start, end), buf)
+	}
+
+	// Characterwise motions are resolved by moving the cursor and restoring it
+	var target buffer.Position
+	inclusive := false
+	switch ch {
+	case 'w':
+		for i := 0; i < count; i++ {
+			n.moveWordForward(buf)
+		}
+		target = buf.Cursor()
+		// Like cw/dw, a word motion never carries the operator onto the next line
+		if target.Line > cursor.Line {
+			line, _ := buf.Line(cursor.Line)
+			target = buffer.Position{Line: cursor.Line, Col: len(line)}
+		}
+	case 'e':
+		for i := 0; i < count; i++ {
+			n.moveToWordEnd(buf)
+		}
+		target = buf.Cursor()
+		inclusive = true
+	case 'b':
+		for i := 0; i < count; i++ {
+			n.moveWordBackward(buf)
+		}
+		target = buf.Cursor()
+	case '$':
+		target = buffer.Position{Line: cursor.Line, Col: len(buf.CurrentLine())}
+	case '0':
+		target = buffer.Position{Line: cursor.Line, Col: 0}
+	case '^':
+		n.moveToFirstNonWhitespace(buf)
+		target = buf.Cursor()
+	case 'l':
+		target = buffer.Position{Line: cursor.Line, Col: min(cursor.Col+count, len(buf.CurrentLine()))}
+	case 'h':
+		target = buffer.Position{Line: cursor.Line, Col: max(cursor.Col-count, 0)}
+	default:
+		n.cancelOperator()
+		return ModeResult{Handled: true}
+	}
+	buf.SetCursor(cursor)
+	n.cancelOperator()
+
+	return n.applyOperator(op, env, charRange(buf, cursor, target, inclusive), buf)
+}
+
+// charRange returns the characterwise range between a motion's start and
+// target, in either order, taking the character at the end when inclusive
+func charRange(buf *buffer.Buffer, start, end buffer.Position, inclusive bool) textRange {
+	if positionBefore(end, start) {
+		start, end = end, start
+	}
+	if inclusive {
+		line, _ := buf.Line(end.Line)
+		end.Col = min(end.Col+1, len(line))
+	}
+	return textRange{Start: start, End: end}
+}
+
+// lineRange returns a linewise range of count lines starting at line
+func (n *NormalMode) lineRange(line, count int, buf *buffer.Buffer) textRange {
+	end := min(line+count-1, buf.LineCount()-1)
+	return textRange{Start: buffer.Position{Line: line}, End: buffer.Position{Line: end}, Linewise: true}
+}
+
+// applyOperator runs op over r
+func (n *NormalMode) applyOperator(op string, env operatorEnv, r textRange, buf *buffer.Buffer) ModeResult {
+	applyOperatorRange(op, r, buf, env)
+	return ModeResult{Handled: true}
+}
+
+// applyOperatorRange runs a delete, yank, case, format or comment operator over a range.
+// Shared by normal mode motions and visual mode selections.
+func applyOperatorRange(op string, r textRange, buf *buffer.Buffer, env operatorEnv) {
+	if r.Linewise && r.End.Line < r.Start.Line {
+		r.Start, r.End = r.End, r.Start
+	}
+
+	switch op {
+	case "d":
+		cutRange(buf, r, env)
+		return
+	case "y":
+		yankRange(buf, r, env)
+		return
+	case "gq":
+		formatLines(buf, r.Start.Line, r.End.Line)
+		return
+	case "gc":
+		// Comments always apply to whole lines
+		if style, ok := commentStyleFor(buf, env.commentStyles); ok {
+			toggleComments(buf, r.Start.Line, r.End.Line, style)
+		}
+		return
+	}
+
+	var transform func(string) string
+	switch op {
+	case "gu":
+		transform = strings.ToLower
+	case "gU":
+		transform = strings.ToUpper
+	case "g~":
+		transform = toggleCase
+	default:
+		return
+	}
+
+	var lines []string
+	for lineNum := r.Start.Line; lineNum <= r.End.Line; lineNum++ {
+		line, err := buf.Line(lineNum)
+		if err != nil {
+			return
+		}
+
+		start, end := 0, len(line)
+		if !r.Linewise {
+			if lineNum == r.Start.Line {
+				start = min(r.Start.Col, len(line))
+			}
+			if lineNum == r.End.Line {
+				end = min(r.End.Col, len(line))
+			}
+		}
+		if start > end {
+			start = end
+		}
+		lines = append(lines, line[:start]+transform(line[start:end])+line[end:])
+	}
+
+	buf.ReplaceLines(r.Start.Line, r.End.Line, lines)
+	if r.Linewise {
+		buf.SetCursor(buffer.Position{Line: r.Start.Line, Col: 0})
+	} else {
+		buf.SetCursor(r.Start)
+	}
+}
+
+// toggleCase swaps the case of every letter in s
+func toggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// paragraphRange returns the linewise range of the paragraph around line.
+// With around set, trailing blank lines are included (ap).
+func paragraphRange(buf *buffer.Buffer, line int, around bool) textRange {
+	isBlankLine := func(i int) bool {
+		text, _ := buf.Line(i)
+		return strings.TrimSpace(text) == ""
+	}
+
+	blank := isBlankLine(line)
+	start, end := line, line
+	for start > 0 && isBlankLine(start-1) == blank {
+		start--
+	}
+	for end < buf.LineCount()-1 && isBlankLine(end+1) == blank {
+		end++
+	}
+	if around && !blank {
+		for end < buf.LineCount()-1 && isBlankLine(end+1) {
+			end++
+		}
+	}
+
+	return textRange{Start: buffer.Position{Line: start}, End: buffer.Position{Line: end}, Linewise: true}
+}
+
+// positionBefore reports whether a comes before b
+func positionBefore(a, b buffer.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+}
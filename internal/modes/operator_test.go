@@ -0,0 +1,160 @@
+package modes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func newOperatorTestBuffer(lines ...string) *buffer.Buffer {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, lines)
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+	return buf
+}
+
+func TestNormalMode_CaseOperators(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		cursor   buffer.Position
+		keys     string
+		expected []string
+	}{
+		{"gUw", []string{"hello world"}, buffer.Position{}, "gUw", []string{"HELLO world"}},
+		{"gU2w", []string{"one two three"}, buffer.Position{}, "gU2w", []string{"ONE TWO three"}},
+		{"gue", []string{"HELLO WORLD"}, buffer.Position{Col: 6}, "gue", []string{"HELLO world"}},
+		{"g~$", []string{"Hello World"}, buffer.Position{Col: 6}, "g~$", []string{"Hello wORLD"}},
+		{"gUU", []string{"abc", "def"}, buffer.Position{Line: 1}, "gUU", []string{"abc", "DEF"}},
+		{"2guu", []string{"ABC", "DEF", "GHI"}, buffer.Position{}, "2guu", []string{"abc", "def", "GHI"}},
+		{"gUgU", []string{"abc"}, buffer.Position{}, "gUgU", []string{"ABC"}},
+		{"gUj", []string{"abc", "def", "ghi"}, buffer.Position{}, "gUj", []string{"ABC", "DEF", "ghi"}},
+		{"gUw stays on line", []string{"abc", "def"}, buffer.Position{}, "gUw", []string{"ABC", "def"}},
+		{"gUip", []string{"ab", "cd", "", "ef"}, buffer.Position{Line: 1}, "gUip", []string{"AB", "CD", "", "ef"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := NewNormalMode()
+			buf := newOperatorTestBuffer(tt.lines...)
+			buf.SetCursor(tt.cursor)
+
+			typeKeys(mode, buf, tt.keys)
+
+			if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+			if mode.PendingText() != "" {
+				t.Errorf("expected no pending operator, got %q", mode.PendingText())
+			}
+		})
+	}
+}
+
+func TestNormalMode_FormatOperator(t *testing.T) {
+	mode := NewNormalMode()
+	buf := newOperatorTestBuffer(
+		"// the quick brown fox",
+		"// jumps over the lazy dog",
+		"",
+		"next paragraph",
+	)
+	buf.SetOptions(buffer.Options{TextWidth: 20})
+
+	typeKeys(mode, buf, "gqip")
+
+	expected := []string{
+		"// the quick brown",
+		"// fox jumps over",
+		"// the lazy dog",
+		"",
+		"next paragraph",
+	}
+	if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestReflow(t *testing.T) {
+	lines := []string{
+		"    indented text that is long",
+		"    continues here",
+		"",
+		"# a comment",
+		"# more",
+	}
+	expected := []string{
+		"    indented text",
+		"    that is long",
+		"    continues here",
+		"",
+		"# a comment more",
+	}
+
+	if got := reflow(lines, 20, commentLeaders, false); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestReflowListsAndBlockComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		leaders []string
+		inBlock bool
+		want    []string
+	}{
+		{
+			"bullets stay apart",
+			[]string{"* one", "* two is a longer item"},
+			proseLeaders, false,
+			[]string{"* one", "* two is a longer", "  item"},
+		},
+		{
+			"numbered items",
+			[]string{"1. first", "   more", "2. second"},
+			commentLeaders, false,
+			[]string{"1. first more", "2. second"},
+		},
+		{
+			"quotes in prose",
+			[]string{"> quoted", "> text"},
+			proseLeaders, false,
+			[]string{"> quoted text"},
+		},
+		{
+			"block comment",
+			[]string{"/*", " * a block", " * comment", " */"},
+			commentLeaders, false,
+			[]string{"/*", " * a block comment", " */"},
+		},
+		{
+			"inside a block opened above",
+			[]string{" * a block", " * comment"},
+			commentLeaders, true,
+			[]string{" * a block comment"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reflow(tt.lines, 20, tt.leaders, tt.inBlock); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVisualMode_CaseOperators(t *testing.T) {
+	buf := newOperatorTestBuffer("hello world")
+	buf.SetCursor(buffer.Position{Line: 0, Col: 6})
+
+	mode := NewVisualMode()
+	mode.OnEnter(buf)
+	buf.SetCursor(buffer.Position{Line: 0, Col: 10})
+
+	typeKeys(mode, buf, "U")
+	if buf.CurrentLine() != "hello WORLD" {
+		t.Errorf("expected 'hello WORLD', got %q", buf.CurrentLine())
+	}
+}
@@ -0,0 +1,194 @@
+package modes
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/palette"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// commandKeys are the normal mode keys that run a command, shown next to it
+// in the palette
+var commandKeys = map[string]string{
+	"w":    "Ctrl-S",
+	"undo": "u",
+	"redo": "Ctrl-R",
+}
+
+// PaletteMode is the command palette: typing filters every command by fuzzy
+// search, Enter runs the selected one and Tab puts it on the command line
+// to add arguments. A command may open it on a list of its own instead,
+// like :oldfiles on the recent files, for Enter to choose an item.
+type PaletteMode struct {
+	command  *CommandMode      // Runs the chosen commands
+	history  *palette.Frecency // Commands run from the palette, to list them first
+	pick     *commands.Pick    // The list being picked from instead of the commands, or nil
+	query    string
+	matches  []palette.Match
+	selected int
+}
+
+// NewPaletteMode creates a palette running commands through command
+func NewPaletteMode(command *CommandMode) *PaletteMode {
+	return &PaletteMode{command: command}
+}
+
+// Type returns the mode type
+func (p *PaletteMode) Type() ModeType {
+	return ModePalette
+}
+
+// SetHistory sets where the commands run from the palette are remembered
+func (p *PaletteMode) SetHistory(history *palette.Frecency) {
+	p.history = history
+}
+
+// HandleInput processes keyboard input in the palette
+func (p *PaletteMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	switch event.Action {
+	case ui.KeyActionEscape, ui.KeyActionCtrlC:
+		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+	case ui.KeyActionUp, ui.KeyActionCtrlP:
+		if p.selected > 0 {
+			p.selected--
+		}
+	case ui.KeyActionDown, ui.KeyActionCtrlN:
+		if p.selected < len(p.matches)-1 {
+			p.selected++
+		}
+	case ui.KeyActionBackspace:
+		if p.query == "" {
+			return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+		}
+		runes := []rune(p.query)
+		p.query = string(runes[:len(runes)-1])
+		p.filter()
+	case ui.KeyActionChar:
+		p.query += string(event.Rune)
+		p.filter()
+	case ui.KeyActionTab:
+		if name, ok := p.selectedName(); ok && p.pick == nil {
+			p.command.startWith(name+" ", "")
+			return ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
+		}
+	case ui.KeyActionEnter:
+		return p.run(buf)
+	}
+	return ModeResult{Handled: true}
+}
+
+// run runs the selected command. One that needs arguments is put on the
+// command line for them instead.
+func (p *PaletteMode) run(buf *buffer.Buffer) ModeResult {
+	name, ok := p.selectedName()
+	if !ok {
+		return ModeResult{Handled: true}
+	}
+	if p.pick != nil {
+		return p.choose(name, buf)
+	}
+	p.history.Record(name, time.Now())
+	return p.command.run(name, buf)
+}
+
+// choose chooses the picked item name, showing the result's message
+func (p *PaletteMode) choose(name string, buf *buffer.Buffer) ModeResult {
+	index := slices.Index(p.pick.Items, name)
+	result := p.pick.Choose(index, buf)
+	if p.command.ctx != nil {
+		p.command.ctx.Message(result.Message)
+	}
+	return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+}
+
+// selectedName returns the name of the selected command
+func (p *PaletteMode) selectedName() (string, bool) {
+	if p.selected >= len(p.matches) {
+		return "", false
+	}
+	return p.matches[p.selected].Name, true
+}
+
+// filter lists the commands, or the items picked from, matching the query
+func (p *PaletteMode) filter() {
+	if p.pick != nil {
+		p.matches = palette.Rank(p.pick.Items, p.query)
+		p.selected = 0
+		return
+	}
+	p.matches = palette.Filter(p.entries(), p.query, p.history, time.Now())
+	p.selected = 0
+}
+
+// entries returns every registered command
+func (p *PaletteMode) entries() []palette.Entry {
+	registry := p.command.executor.GetCommands()
+	var entries []palette.Entry
+	for _, name := range registry.ListCommands() {
+		cmd, _ := registry.GetCommand(name)
+		entries = append(entries, palette.Entry{Name: name, Help: cmd.Help(), Keys: commandKeys[name]})
+	}
+	return entries
+}
+
+// OnEnter opens the palette with every command listed, or the list a
+// command left to pick from
+func (p *PaletteMode) OnEnter(buf *buffer.Buffer) {
+	p.pick = p.command.ctx.Commands().TakePick()
+	p.query = ""
+	p.filter()
+}
+
+// OnExit is called when leaving the palette
+func (p *PaletteMode) OnExit(buf *buffer.Buffer) {
+	p.matches = nil
+	p.pick = nil
+}
+
+// GetStatusText returns mode-specific status information
+func (p *PaletteMode) GetStatusText() string {
+	return "-- PALETTE --"
+}
+
+// Palette returns the palette for display
+func (p *PaletteMode) Palette() *ui.Palette {
+	view := &ui.Palette{Query: p.query, Selected: p.selected}
+	if p.pick != nil {
+		view.Title = p.pick.Title
+		for _, m := range p.matches {
+			view.Items = append(view.Items, ui.PaletteItem{Label: m.Name, Matched: m.Positions})
+		}
+		return view
+	}
+	for _, m := range p.matches {
+		view.Items = append(view.Items, ui.PaletteItem{
+			Label:   ":" + m.Name,
+			Detail:  describe(m.Help),
+			Keys:    m.Keys,
+			Matched: shift(m.Positions, 1),
+		})
+	}
+	return view
+}
+
+// describe returns what a command's help says it does, without the usage
+// leading most help texts
+func describe(help string) string {
+	if _, what, ok := strings.Cut(help, " - "); ok {
+		return what
+	}
+	return help
+}
+
+// shift adds by to every offset
+func shift(offsets []int, by int) []int {
+	shifted := make([]int, len(offsets))
+	for i, o := range offsets {
+		shifted[i] = o + by
+	}
+	return shifted
+}
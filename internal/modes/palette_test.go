@@ -0,0 +1,135 @@
+package modes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/palette"
+	"github.com/dshills/aied/internal/recent"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// typeInto sends the characters of text to the mode manager
+func typeInto(mm *ModeManager, text string, buf *buffer.Buffer) {
+	for _, r := range text {
+		mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: r}, buf)
+	}
+}
+
+func TestPaletteMode_OpensFromNormalMode(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	mm.SwitchToMode(ModeNormal, buf)
+
+	if mm.Palette() != nil {
+		t.Fatal("palette shown outside palette mode")
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionCtrlP}, buf)
+	if mm.CurrentModeType() != ModePalette {
+		t.Fatalf("Ctrl-P switched to %v", mm.CurrentModeType())
+	}
+	p := mm.Palette()
+	if p == nil || len(p.Items) < 10 {
+		t.Fatalf("palette should list every command, got %+v", p)
+	}
+
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	if mm.CurrentModeType() != ModeNormal {
+		t.Errorf("Escape switched to %v", mm.CurrentModeType())
+	}
+}
+
+func TestPaletteMode_FiltersAndRuns(t *testing.T) {
+	mm := NewModeManager()
+	history, _ := palette.OpenFrecency("")
+	mm.SetPaletteHistory(history)
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "hello   ")
+	mm.SwitchToMode(ModePalette, buf)
+
+	typeInto(mm, "stripw", buf)
+	p := mm.Palette()
+	if len(p.Items) == 0 || p.Items[0].Label != ":StripWhitespace" {
+		t.Fatalf("query did not select :StripWhitespace: %+v", p.Items)
+	}
+	if !strings.Contains(p.Items[0].Detail, "whitespace") || strings.HasPrefix(p.Items[0].Detail, ":") {
+		t.Errorf("detail should describe the command without its usage: %q", p.Items[0].Detail)
+	}
+
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if got := buf.CurrentLine(); got != "hello" {
+		t.Errorf("command not run, line is %q", got)
+	}
+	if mm.CurrentModeType() != ModeNormal {
+		t.Errorf("palette left for %v after running the command", mm.CurrentModeType())
+	}
+
+	// The command just run is listed first next time
+	mm.SwitchToMode(ModePalette, buf)
+	if got := mm.Palette().Items[0].Label; got != ":StripWhitespace" {
+		t.Errorf("recently run command not listed first, got %s", got)
+	}
+}
+
+func TestPaletteMode_CommandNeedingArguments(t *testing.T) {
+	mm := NewModeManager()
+	buf := buffer.New()
+	mm.SwitchToMode(ModePalette, buf)
+
+	typeInto(mm, "diffsplit", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if commandLine, _, ok := mm.GetCommandInfo(); !ok || commandLine != ":diffsplit " {
+		t.Errorf("command needing arguments should start the command line, got %q", commandLine)
+	}
+
+	mm.SwitchToMode(ModePalette, buf)
+	typeInto(mm, "undo", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionTab}, buf)
+	if commandLine, _, ok := mm.GetCommandInfo(); !ok || commandLine != ":undo " {
+		t.Errorf("Tab should start the command line, got %q", commandLine)
+	}
+}
+
+func TestPaletteMode_PicksRecentFile(t *testing.T) {
+	dir := t.TempDir()
+	files, _ := recent.Open("")
+	for _, name := range []string{"main.go", "notes.txt"} {
+		filename := filepath.Join(dir, name)
+		os.WriteFile(filename, []byte(name+"\n"), 0644)
+		files.Add(filename)
+	}
+	files.Add(filepath.Join(dir, "deleted.go"))
+
+	mm := NewModeManager()
+	mm.SetCommandContext(&commands.CommandContext{Recent: files})
+	buf := buffer.New()
+	mm.SwitchToMode(ModeNormal, buf)
+
+	mm.Execute("oldfiles", buf)
+	if mm.CurrentModeType() != ModePalette {
+		t.Fatalf(":oldfiles switched to %v", mm.CurrentModeType())
+	}
+	p := mm.Palette()
+	if p.Title != "Recent files" || len(p.Items) != 2 || !strings.HasSuffix(p.Items[0].Label, "notes.txt") {
+		t.Fatalf("picker should list the existing files most recent first: %+v", p)
+	}
+
+	typeInto(mm, "main.go", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if buf.Filename() != filepath.Join(dir, "main.go") || buf.CurrentLine() != "main.go" {
+		t.Errorf("choosing main.go opened %q: %q", buf.Filename(), buf.CurrentLine())
+	}
+	if mm.CurrentModeType() != ModeNormal {
+		t.Errorf("picker left for %v", mm.CurrentModeType())
+	}
+
+	// The palette lists the commands again next time
+	mm.SwitchToMode(ModePalette, buf)
+	if p := mm.Palette(); p.Title != "" || !strings.HasPrefix(p.Items[0].Label, ":") {
+		t.Errorf("palette after picking = %+v", p)
+	}
+}
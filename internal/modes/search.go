@@ -0,0 +1,168 @@
+package modes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/search"
+)
+
+// searchPattern moves the cursor to the count'th match of pattern after
+// it, or before it for ?, and remembers the search for n and N. An empty
+// pattern searches for the last one again. It returns the message to show.
+func searchPattern(buf *buffer.Buffer, pattern string, backward bool, count int) string {
+	if pattern == "" {
+		if pattern, _ = search.Last(); pattern == "" {
+			return "No previous search pattern"
+		}
+	}
+	search.SetLast(pattern, backward)
+	return jumpToMatch(buf, pattern, backward, count)
+}
+
+// searchNext implements n, which repeats the last search, and N, which
+// repeats it in the other direction
+func (n *NormalMode) searchNext(reverse bool, buf *buffer.Buffer) ModeResult {
+	message := "No previous search pattern"
+	if pattern, backward := search.Last(); pattern != "" {
+		// Searching again shows the matches hidden by :noh
+		search.SetLast(pattern, backward)
+		message = jumpToMatch(buf, pattern, backward != reverse, n.countOrOne())
+	}
+	if n.ctx != nil {
+		n.ctx.Message(message)
+	}
+	return ModeResult{Handled: true}
+}
+
+// jumpToMatch moves the cursor count matches of pattern on, returning the
+// search as typed, or a note that it wrapped around the buffer or failed
+func jumpToMatch(buf *buffer.Buffer, pattern string, backward bool, count int) string {
+	re, err := search.Compile(pattern)
+	if err != nil {
+		return fmt.Sprintf("Invalid pattern: %v", err)
+	}
+
+	find := findMatch
+	if search.Multiline(pattern) {
+		find = findMatchAcrossLines
+	}
+	visual := search.VisualOnly(pattern)
+	pos, wrapped := buf.Cursor(), false
+	for range max(count, 1) {
+		next, wrap, ok := find(buf, re, visual, pos, backward)
+		if !ok {
+			return fmt.Sprintf("Pattern not found: %s", pattern)
+		}
+		pos, wrapped = next, wrapped || wrap
+	}
+	buf.SetCursor(pos)
+
+	switch {
+	case wrapped && backward:
+		return "search hit TOP, continuing at BOTTOM"
+	case wrapped:
+		return "search hit BOTTOM, continuing at TOP"
+	case backward:
+		return "?" + pattern
+	default:
+		return "/" + pattern
+	}
+}
+
+// findMatch finds the first match of re after from, or the last before it
+// when backward, wrapping around the buffer, and reports whether it
+// wrapped. With visual only matches inside the visual selection count.
+func findMatch(buf *buffer.Buffer, re search.Regexp, visual bool, from buffer.Position, backward bool) (buffer.Position, bool, bool) {
+	lineCount := buf.LineCount()
+	step := 1
+	if backward {
+		step = -1
+	}
+
+	// The cursor line is searched first on the cursor's one side, and last
+	// on the other once the search has come around
+	for i := 0; i <= lineCount; i++ {
+		lineNum := ((from.Line+step*i)%lineCount + lineCount) % lineCount
+		line, err := buf.Line(lineNum)
+		if err != nil {
+			return buffer.Position{}, false, false
+		}
+		matches := re.FindAllStringIndex(line, -1)
+		if visual {
+			matches = search.VisualMatches(buf, lineNum, matches)
+		}
+		for j := range matches {
+			m := matches[j]
+			if backward {
+				m = matches[len(matches)-1-j]
+			}
+			beyond := m[0] > from.Col
+			if backward {
+				beyond = m[0] < from.Col
+			}
+			if i == 0 && !beyond {
+				continue
+			}
+			if i == lineCount && beyond {
+				break
+			}
+			wrapped := from.Line+step*i < 0 || from.Line+step*i >= lineCount
+			return buffer.Position{Line: lineNum, Col: m[0]}, wrapped, true
+		}
+	}
+	return buffer.Position{}, false, false
+}
+
+// findMatchAcrossLines is findMatch for patterns matching line breaks,
+// which are matched against the whole buffer
+func findMatchAcrossLines(buf *buffer.Buffer, re search.Regexp, visual bool, from buffer.Position, backward bool) (buffer.Position, bool, bool) {
+	lines := buf.Lines()
+	var positions []buffer.Position
+	lineNum, lineStart := 0, 0
+	for _, m := range re.FindAllStringIndex(strings.Join(lines, "\n"), -1) {
+		for lineNum < len(lines)-1 && m[0] > lineStart+len(lines[lineNum]) {
+			lineStart += len(lines[lineNum]) + 1
+			lineNum++
+		}
+		start := buffer.Position{Line: lineNum, Col: m[0] - lineStart}
+		if visual && !buf.InVisualArea(start, endPosition(lines, start, m[1]-m[0])) {
+			continue
+		}
+		positions = append(positions, start)
+	}
+	if len(positions) == 0 {
+		return buffer.Position{}, false, false
+	}
+
+	after := func(p buffer.Position) bool {
+		return p.Line > from.Line || p.Line == from.Line && p.Col > from.Col
+	}
+	if backward {
+		for i := len(positions) - 1; i >= 0; i-- {
+			if p := positions[i]; !after(p) && p != from {
+				return p, false, true
+			}
+		}
+		return positions[len(positions)-1], true, true
+	}
+	for _, p := range positions {
+		if after(p) {
+			return p, false, true
+		}
+	}
+	return positions[0], true, true
+}
+
+// endPosition returns the position length bytes of lines, joined by line
+// breaks, after start
+func endPosition(lines []string, start buffer.Position, length int) buffer.Position {
+	pos := start
+	for pos.Line < len(lines)-1 && pos.Col+length > len(lines[pos.Line]) {
+		length -= len(lines[pos.Line]) - pos.Col + 1
+		pos = buffer.Position{Line: pos.Line + 1}
+	}
+	pos.Col += length
+	return pos
+}
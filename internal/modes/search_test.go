@@ -0,0 +1,113 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/search"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestNormalMode_Search(t *testing.T) {
+	defer search.SetLast("", false)
+	mm := NewModeManager()
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"foo one", "two foo", "three"})
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+
+	enter := ui.KeyEvent{Action: ui.KeyActionEnter}
+	typeInto(mm, "/", buf)
+	if line, _, ok := mm.GetCommandInfo(); !ok || line != "/" {
+		t.Fatalf("command line after / = %q, %v", line, ok)
+	}
+	typeInto(mm, "fo+", buf)
+	mm.HandleInput(enter, buf)
+	if cursor := buf.Cursor(); cursor != (buffer.Position{Line: 1, Col: 4}) || mm.CurrentModeType() != ModeNormal {
+		t.Fatalf("cursor after /fo+ = %+v in %v", cursor, mm.CurrentModeType())
+	}
+	if mm.Message() != "/fo+" {
+		t.Errorf("message = %q", mm.Message())
+	}
+
+	typeInto(mm, "n", buf)
+	if cursor := buf.Cursor(); cursor != (buffer.Position{Line: 0, Col: 0}) || mm.Message() != "search hit BOTTOM, continuing at TOP" {
+		t.Errorf("n = %+v, %q", cursor, mm.Message())
+	}
+	typeInto(mm, "N", buf)
+	if cursor := buf.Cursor(); cursor != (buffer.Position{Line: 1, Col: 4}) || mm.Message() != "search hit TOP, continuing at BOTTOM" {
+		t.Errorf("N = %+v, %q", cursor, mm.Message())
+	}
+
+	// ? searches backwards, and an empty pattern repeats the last one
+	typeInto(mm, "?", buf)
+	mm.HandleInput(enter, buf)
+	if cursor := buf.Cursor(); cursor != (buffer.Position{Line: 0, Col: 0}) {
+		t.Errorf("cursor after ? = %+v", cursor)
+	}
+	if _, backward := search.Last(); !backward {
+		t.Error("expected ? to make n search backwards")
+	}
+
+	typeInto(mm, "/nothing", buf)
+	mm.HandleInput(enter, buf)
+	if mm.Message() != "Pattern not found: nothing" || buf.Cursor() != (buffer.Position{Line: 0, Col: 0}) {
+		t.Errorf("search without a match = %q, cursor %+v", mm.Message(), buf.Cursor())
+	}
+
+	// : still starts an ex command line
+	typeInto(mm, ":", buf)
+	if line, _, _ := mm.GetCommandInfo(); line != ":" {
+		t.Errorf("command line after : = %q", line)
+	}
+}
+
+func TestFindMatch_CursorLine(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"a x a"})
+	re, _ := search.Compile("a")
+
+	// The only other match on the cursor's line is found after wrapping
+	pos, wrapped, ok := findMatch(buf, re, false, buffer.Position{Col: 4}, false)
+	if !ok || !wrapped || pos.Col != 0 {
+		t.Errorf("forward = %+v, wrapped %v, ok %v", pos, wrapped, ok)
+	}
+	pos, wrapped, ok = findMatch(buf, re, false, buffer.Position{Col: 0}, true)
+	if !ok || !wrapped || pos.Col != 4 {
+		t.Errorf("backward = %+v, wrapped %v, ok %v", pos, wrapped, ok)
+	}
+}
+
+func TestFindMatchAcrossLines(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"end", "begin", "x end", "begin"})
+	re, _ := search.Compile(`end\nbegin`)
+
+	pos, wrapped, ok := findMatchAcrossLines(buf, re, false, buffer.Position{}, false)
+	if !ok || wrapped || pos != (buffer.Position{Line: 2, Col: 2}) {
+		t.Errorf("forward = %+v, wrapped %v, ok %v", pos, wrapped, ok)
+	}
+	pos, wrapped, ok = findMatchAcrossLines(buf, re, false, buffer.Position{Line: 2, Col: 2}, false)
+	if !ok || !wrapped || pos != (buffer.Position{}) {
+		t.Errorf("wrapping forward = %+v, wrapped %v, ok %v", pos, wrapped, ok)
+	}
+	pos, wrapped, ok = findMatchAcrossLines(buf, re, false, buffer.Position{}, true)
+	if !ok || !wrapped || pos != (buffer.Position{Line: 2, Col: 2}) {
+		t.Errorf("backward = %+v, wrapped %v, ok %v", pos, wrapped, ok)
+	}
+}
+
+func TestFindMatch_Visual(t *testing.T) {
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"x x", "x x", "x x"})
+	buf.SetVisualArea(buffer.Position{Line: 0, Col: 2}, buffer.Position{Line: 1, Col: 0})
+	re, _ := search.Compile(`\%Vx`)
+
+	pos, _, _ := findMatch(buf, re, true, buffer.Position{Line: 0, Col: 2}, false)
+	if pos != (buffer.Position{Line: 1, Col: 0}) {
+		t.Errorf("forward = %+v", pos)
+	}
+	pos, wrapped, _ := findMatch(buf, re, true, buffer.Position{Line: 1, Col: 0}, false)
+	if pos != (buffer.Position{Line: 0, Col: 2}) || !wrapped {
+		t.Errorf("wrapping = %+v, %v", pos, wrapped)
+	}
+}
@@ -0,0 +1,175 @@
+package modes
+
+import (
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/spell"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// maxSpellSuggestions limits the z= suggestion menu
+const maxSpellSuggestions = 9
+
+// handleZCommand processes z-prefixed commands
+func (n *NormalMode) handleZCommand(ch rune, buf *buffer.Buffer) ModeResult {
+	switch ch {
+	case '=':
+		n.showSpellSuggestions(buf)
+	case 'g':
+		if n.spellChecker != nil {
+			if word, ok := wordUnderCursor(buf); ok {
+				n.spellChecker.AddGoodWord(word.Word)
+			}
+		}
+	}
+	return ModeResult{Handled: true}
+}
+
+// handleBracketCommand processes [ and ] prefixed commands
+func (n *NormalMode) handleBracketCommand(prefix, ch rune, buf *buffer.Buffer) ModeResult {
+	switch ch {
+	case 's':
+		n.moveToMisspelling(buf, prefix == ']')
+	case 'c':
+		if n.ctx != nil {
+			moveToDifference(n.ctx.Commands(), buf, prefix == ']')
+		}
+	case 'x':
+		moveToConflict(buf, prefix == ']')
+	}
+	return ModeResult{Handled: true}
+}
+
+// spellEnabled reports whether spell checking applies to the buffer
+func (n *NormalMode) spellEnabled(buf *buffer.Buffer) bool {
+	return n.spellChecker != nil && n.spellChecker.EnabledFor(buf.Filetype())
+}
+
+// moveToMisspelling moves the cursor to the next or previous misspelled word, wrapping around the buffer
+func (n *NormalMode) moveToMisspelling(buf *buffer.Buffer, forward bool) {
+	if !n.spellEnabled(buf) {
+		return
+	}
+
+	filetype := buf.Filetype()
+	cursor := buf.Cursor()
+	lineCount := buf.LineCount()
+
+	for i := 0; i <= lineCount; i++ {
+		lineNum := cursor.Line + i
+		if !forward {
+			lineNum = cursor.Line - i
+		}
+		lineNum = ((lineNum % lineCount) + lineCount) % lineCount
+
+		line, _ := buf.Line(lineNum)
+		misspellings := n.spellChecker.CheckLine(line, filetype)
+		if !forward {
+			// Walk the line from the end when searching backwards
+			for l, r := 0, len(misspellings)-1; l < r; l, r = l+1, r-1 {
+				misspellings[l], misspellings[r] = misspellings[r], misspellings[l]
+			}
+		}
+
+		for _, m := range misspellings {
+			if i == 0 && forward && m.Start <= cursor.Col {
+				continue
+			}
+			if i == 0 && !forward && m.Start >= cursor.Col {
+				continue
+			}
+			buf.SetCursor(buffer.Position{Line: lineNum, Col: m.Start})
+			return
+		}
+	}
+}
+
+// showSpellSuggestions opens the suggestion menu for the word under the cursor
+func (n *NormalMode) showSpellSuggestions(buf *buffer.Buffer) {
+	if n.spellChecker == nil {
+		return
+	}
+
+	word, ok := wordUnderCursor(buf)
+	if !ok {
+		return
+	}
+
+	var items []CompletionItem
+	for i, s := range n.spellChecker.Suggest(word.Word, maxSpellSuggestions) {
+		items = append(items, CompletionItem{Label: s, InsertText: s, Kind: string(rune('1' + i))})
+	}
+	n.suggestions.SetItems(items)
+	n.suggestTarget = word
+	n.suggestLine = buf.Cursor().Line
+}
+
+// handleSuggestionInput handles keys while the z= menu is open
+func (n *NormalMode) handleSuggestionInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	switch event.Action {
+	case ui.KeyActionUp:
+		n.suggestions.MoveUp()
+	case ui.KeyActionDown, ui.KeyActionTab:
+		n.suggestions.MoveDown()
+	case ui.KeyActionEnter:
+		n.applySuggestion(buf, n.suggestions.GetSelectedItem().InsertText)
+	case ui.KeyActionChar:
+		switch {
+		case event.Rune >= '1' && event.Rune <= '9':
+			if idx := int(event.Rune - '1'); idx < len(n.suggestions.Items()) {
+				n.applySuggestion(buf, n.suggestions.Items()[idx].InsertText)
+			}
+		case event.Rune == 'j':
+			n.suggestions.MoveDown()
+		case event.Rune == 'k':
+			n.suggestions.MoveUp()
+		default:
+			n.hideSuggestions()
+		}
+	default:
+		n.hideSuggestions()
+	}
+	return ModeResult{Handled: true}
+}
+
+// applySuggestion replaces the target word with the chosen suggestion
+func (n *NormalMode) applySuggestion(buf *buffer.Buffer, replacement string) {
+	target := n.suggestTarget
+	buf.SetCursor(buffer.Position{Line: n.suggestLine, Col: target.Start})
+	for i := target.Start; i < target.End; i++ {
+		buf.DeleteChar()
+	}
+	buf.InsertTextAt(n.suggestLine, target.Start, replacement)
+	buf.SetCursor(buffer.Position{Line: n.suggestLine, Col: target.Start})
+	n.hideSuggestions()
+}
+
+// hideSuggestions closes the z= menu
+func (n *NormalMode) hideSuggestions() {
+	n.suggestions.Hide()
+}
+
+// Suggestions returns the popup of z= spelling suggestions, for the
+// frontend to show at the cursor while it is visible
+func (n *NormalMode) Suggestions() *ui.CompletionPopup {
+	return n.suggestions
+}
+
+// wordUnderCursor returns the word at the cursor position
+func wordUnderCursor(buf *buffer.Buffer) (spell.Misspelling, bool) {
+	line := buf.CurrentLine()
+	col := buf.Cursor().Col
+	if col >= len(line) || !isIdentifierChar(rune(line[col])) && line[col] != '\'' {
+		return spell.Misspelling{}, false
+	}
+
+	start := col
+	for start > 0 && (isIdentifierChar(rune(line[start-1])) || line[start-1] == '\'') {
+		start--
+	}
+	end := col
+	for end < len(line) && (isIdentifierChar(rune(line[end])) || line[end] == '\'') {
+		end++
+	}
+
+	return spell.Misspelling{Start: start, End: end, Word: line[start:end]}, true
+}
@@ -0,0 +1,75 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/spell"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func newSpellTestMode() *NormalMode {
+	checker := spell.NewChecker()
+	for _, w := range []string{"the", "quick", "brown", "fox", "jumps"} {
+		checker.AddWord(w)
+	}
+
+	mode := NewNormalMode()
+	mode.SetSpellChecker(checker)
+	return mode
+}
+
+func typeKeys(mode Mode, buf *buffer.Buffer, keys string) {
+	for _, ch := range keys {
+		mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: ch}, buf)
+	}
+}
+
+func TestNormalMode_SpellNavigation(t *testing.T) {
+	mode := newSpellTestMode()
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "the quikc brown")
+	buf.InsertLine()
+	buf.InsertTextAt(1, 0, "fox jumsp")
+	buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+
+	typeKeys(mode, buf, "]s")
+	if cursor := buf.Cursor(); cursor != (buffer.Position{Line: 0, Col: 4}) {
+		t.Errorf("expected ]s to move to 'quikc', got %+v", cursor)
+	}
+
+	typeKeys(mode, buf, "]s")
+	if cursor := buf.Cursor(); cursor != (buffer.Position{Line: 1, Col: 4}) {
+		t.Errorf("expected ]s to move to 'jumsp', got %+v", cursor)
+	}
+
+	typeKeys(mode, buf, "[s")
+	if cursor := buf.Cursor(); cursor != (buffer.Position{Line: 0, Col: 4}) {
+		t.Errorf("expected [s to move back to 'quikc', got %+v", cursor)
+	}
+}
+
+func TestNormalMode_SpellSuggestions(t *testing.T) {
+	mode := newSpellTestMode()
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "the quikc fox")
+	buf.SetCursor(buffer.Position{Line: 0, Col: 5})
+
+	typeKeys(mode, buf, "z=")
+	popup := mode.Suggestions()
+	items, selected := popup.Items(), popup.Selected()
+	if !popup.IsVisible() || len(items) == 0 {
+		t.Fatal("expected z= to show suggestions")
+	}
+	if items[selected].Label != "quick" {
+		t.Errorf("expected first suggestion 'quick', got %q", items[selected].Label)
+	}
+
+	mode.HandleInput(ui.KeyEvent{Action: ui.KeyActionEnter}, buf)
+	if buf.CurrentLine() != "the quick fox" {
+		t.Errorf("expected word to be replaced, got %q", buf.CurrentLine())
+	}
+	if popup.IsVisible() {
+		t.Error("expected suggestions to close after replacement")
+	}
+}
@@ -0,0 +1,139 @@
+package modes
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+// suggestHint lists the keys of a suggestion on its border
+const suggestHint = "Tab accept  w word  l line  Esc reject"
+
+// SuggestMode shows text offered for insertion at the cursor, like the
+// completion of :aic, without changing the buffer: Tab or Enter inserts all
+// of it, w or Right its next word and l or Down the rest of its line, and
+// Esc drops what is left.
+type SuggestMode struct {
+	ctx   *Context
+	pos   buffer.Position // Where the rest of the text goes
+	text  string          // The text not accepted yet
+	title string
+}
+
+// NewSuggestMode creates the mode showing suggestions
+func NewSuggestMode() *SuggestMode {
+	return &SuggestMode{}
+}
+
+// SetContext sets the editor context the suggestions are left in
+func (s *SuggestMode) SetContext(ctx *Context) {
+	s.ctx = ctx
+}
+
+// Type returns the mode type
+func (s *SuggestMode) Type() ModeType {
+	return ModeSuggest
+}
+
+// HandleInput accepts the suggestion, or part of it, or rejects it
+func (s *SuggestMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	switch {
+	case event.Action == ui.KeyActionEscape || event.Action == ui.KeyActionCtrlC:
+		s.text = ""
+	case event.Action == ui.KeyActionTab || event.Action == ui.KeyActionEnter:
+		s.accept(buf, len(s.text))
+	case event.Action == ui.KeyActionRight || event.Action == ui.KeyActionChar && event.Rune == 'w':
+		s.accept(buf, nextWordEnd(s.text))
+	case event.Action == ui.KeyActionDown || event.Action == ui.KeyActionChar && event.Rune == 'l':
+		s.accept(buf, nextLineEnd(s.text))
+	}
+	if s.text == "" {
+		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+	}
+	return ModeResult{Handled: true}
+}
+
+// accept inserts the first n bytes of the text left, leaving the cursor
+// after them
+func (s *SuggestMode) accept(buf *buffer.Buffer, n int) {
+	buf.SetCursor(s.pos)
+	for i, part := range strings.Split(s.text[:n], "\n") {
+		if i > 0 {
+			buf.InsertLine()
+		}
+		if part != "" {
+			cursor := buf.Cursor()
+			buf.InsertTextAt(cursor.Line, cursor.Col, part)
+		}
+	}
+	s.pos = buf.Cursor()
+	s.text = s.text[n:]
+}
+
+// nextWordEnd returns where the word text starts with ends, after the
+// space before it: a run of identifier characters or of other symbols
+func nextWordEnd(text string) int {
+	i := 0
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == '\n' || !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	if i < len(text) && text[i] == '\n' {
+		return i + 1
+	}
+	word := isIdentifierChar
+	if r, _ := utf8.DecodeRuneInString(text[i:]); !isIdentifierChar(r) {
+		word = func(r rune) bool { return !isIdentifierChar(r) && !unicode.IsSpace(r) }
+	}
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !word(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// nextLineEnd returns where the line text starts with ends, taking the
+// line break after it along
+func nextLineEnd(text string) int {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return i + 1
+	}
+	return len(text)
+}
+
+// OnEnter shows the suggestion a command left
+func (s *SuggestMode) OnEnter(buf *buffer.Buffer) {
+	suggestion := s.ctx.Commands().TakeSuggestion()
+	if suggestion == nil {
+		s.text = ""
+		return
+	}
+	s.pos, s.text, s.title = suggestion.Pos, suggestion.Text, suggestion.Title
+}
+
+// OnExit drops what is left of the suggestion
+func (s *SuggestMode) OnExit(buf *buffer.Buffer) {
+	s.text = ""
+}
+
+// GetStatusText returns mode-specific status information
+func (s *SuggestMode) GetStatusText() string {
+	return "-- SUGGEST --"
+}
+
+// Suggestion returns what is left of the suggestion for display, or nil
+func (s *SuggestMode) Suggestion() *ui.Suggestion {
+	if s.text == "" {
+		return nil
+	}
+	return &ui.Suggestion{Title: s.title, Lines: strings.Split(s.text, "\n"), Hint: suggestHint}
+}
@@ -0,0 +1,80 @@
+package modes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestSuggestMode_AcceptsInParts(t *testing.T) {
+	provider := ai.NewMockProvider(ai.ProviderOllama)
+	provider.SetCompleteFunc(func(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+		return &ai.AIResponse{Content: "a, b)\nz := x + y", Provider: "ollama"}, nil
+	})
+	manager := ai.NewAIManager()
+	manager.RegisterProvider(provider)
+
+	mm := NewModeManager()
+	mm.SetCommandContext(&commands.CommandContext{AI: manager})
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, []string{"x := add(", "y := 2"})
+	buf.SetCursor(buffer.Position{Line: 0, Col: 9})
+
+	mm.Execute("aic", buf)
+	s := mm.Suggestion()
+	if mm.CurrentModeType() != ModeSuggest || s == nil || len(s.Lines) != 2 || s.Title != "ollama" {
+		t.Fatalf(":aic showed %+v in %v", s, mm.CurrentModeType())
+	}
+	if line, _ := buf.Line(0); line != "x := add(" {
+		t.Fatalf("the suggestion changed the buffer to %q", line)
+	}
+
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'w'}, buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionRight}, buf)
+	if line, _ := buf.Line(0); line != "x := add(a," {
+		t.Errorf("two words accepted gave %q", line)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: 'l'}, buf)
+	if s := mm.Suggestion(); s == nil || len(s.Lines) != 1 || s.Lines[0] != "z := x + y" {
+		t.Fatalf("the rest of the line accepted left %+v", s)
+	}
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionTab}, buf)
+	want := []string{"x := add(a, b)", "z := x + y", "y := 2"}
+	for i := range want {
+		if line, _ := buf.Line(i); line != want[i] {
+			t.Errorf("line %d = %q, want %q", i, line, want[i])
+		}
+	}
+	if mm.CurrentModeType() != ModeNormal || mm.Suggestion() != nil {
+		t.Errorf("accepting all of it left %v", mm.CurrentModeType())
+	}
+
+	// Esc rejects what is left without changing the buffer
+	mm.Execute("aic", buf)
+	mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionEscape}, buf)
+	if mm.CurrentModeType() != ModeNormal || buf.LineCount() != 3 {
+		t.Errorf("rejecting left %d lines in %v", buf.LineCount(), mm.CurrentModeType())
+	}
+}
+
+func TestNextWordEnd(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"foo(bar)", 3},
+		{"(bar)", 1},
+		{"  bar baz", 5},
+		{" \nbar", 2},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := nextWordEnd(tt.text); got != tt.want {
+			t.Errorf("nextWordEnd(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
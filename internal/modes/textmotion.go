@@ -0,0 +1,214 @@
+package modes
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// isBlank reports whether line n is empty or only whitespace, which
+// separates paragraphs
+func isBlank(buf *buffer.Buffer, n int) bool {
+	line, _ := buf.Line(n)
+	return strings.TrimSpace(line) == ""
+}
+
+// endOfBuffer returns the position after the last character of buf
+func endOfBuffer(buf *buffer.Buffer) buffer.Position {
+	last := buf.LineCount() - 1
+	line, _ := buf.Line(last)
+	return buffer.Position{Line: last, Col: len(line)}
+}
+
+// paragraphForward returns where } moves from pos count times: the blank
+// line after each paragraph, or the end of the buffer after the last
+func paragraphForward(buf *buffer.Buffer, pos buffer.Position, count int) buffer.Position {
+	line := pos.Line
+	for i := 0; i < count; i++ {
+		for line < buf.LineCount() && isBlank(buf, line) {
+			line++
+		}
+		for line < buf.LineCount() && !isBlank(buf, line) {
+			line++
+		}
+		if line >= buf.LineCount() {
+			return endOfBuffer(buf)
+		}
+	}
+	return buffer.Position{Line: line}
+}
+
+// paragraphBackward returns where { moves from pos count times: the blank
+// line before each paragraph, or the start of the buffer before the first
+func paragraphBackward(buf *buffer.Buffer, pos buffer.Position, count int) buffer.Position {
+	line := pos.Line
+	for i := 0; i < count; i++ {
+		for line >= 0 && isBlank(buf, line) {
+			line--
+		}
+		for line >= 0 && !isBlank(buf, line) {
+			line--
+		}
+		if line < 0 {
+			return buffer.Position{}
+		}
+	}
+	return buffer.Position{Line: line}
+}
+
+// proseMotion returns where the paragraph or sentence motion ch ({, },
+// ( or )) moves from pos count times
+func proseMotion(ch rune, buf *buffer.Buffer, pos buffer.Position, count int) buffer.Position {
+	switch ch {
+	case '{':
+		return paragraphBackward(buf, pos, count)
+	case '}':
+		return paragraphForward(buf, pos, count)
+	case '(':
+		return sentenceBackward(buf, pos, count)
+	default:
+		return sentenceForward(buf, pos, count)
+	}
+}
+
+// sentenceStarts returns where the sentences of buf start, in order. A
+// sentence ends at '.', '!' or '?', optionally followed by closing quotes
+// and brackets, then whitespace or the end of the line. Paragraphs start
+// sentences too, and the first blank line after one counts as a sentence.
+func sentenceStarts(buf *buffer.Buffer) []buffer.Position {
+	var starts []buffer.Position
+	atStart, ended, inBlank := true, false, false
+	for n := 0; n < buf.LineCount(); n++ {
+		if isBlank(buf, n) {
+			if !inBlank && n > 0 {
+				starts = append(starts, buffer.Position{Line: n})
+			}
+			atStart, ended, inBlank = true, false, true
+			continue
+		}
+		inBlank = false
+
+		line, _ := buf.Line(n)
+		for col, r := range line {
+			switch {
+			case unicode.IsSpace(r):
+				if ended {
+					atStart, ended = true, false
+				}
+				continue
+			case atStart:
+				starts = append(starts, buffer.Position{Line: n, Col: col})
+				atStart = false
+			}
+			switch {
+			case strings.ContainsRune(".!?", r):
+				ended = true
+			case ended && strings.ContainsRune(")]\"'", r):
+			default:
+				ended = false
+			}
+		}
+		// The end of the line ends a sentence like whitespace
+		if ended {
+			atStart, ended = true, false
+		}
+	}
+	return starts
+}
+
+// sentenceForward returns where ) moves from pos count times: the start
+// of each following sentence, or the end of the buffer after the last
+func sentenceForward(buf *buffer.Buffer, pos buffer.Position, count int) buffer.Position {
+	starts := sentenceStarts(buf)
+	for i := 0; i < count; i++ {
+		next := -1
+		for j, start := range starts {
+			if positionBefore(pos, start) {
+				next = j
+				break
+			}
+		}
+		if next < 0 {
+			return endOfBuffer(buf)
+		}
+		pos = starts[next]
+	}
+	return pos
+}
+
+// sentenceBackward returns where ( moves from pos count times: the start
+// of the sentence, or of the one before when already there
+func sentenceBackward(buf *buffer.Buffer, pos buffer.Position, count int) buffer.Position {
+	starts := sentenceStarts(buf)
+	for i := 0; i < count; i++ {
+		prev := -1
+		for j, start := range starts {
+			if !positionBefore(start, pos) {
+				break
+			}
+			prev = j
+		}
+		if prev < 0 {
+			return buffer.Position{}
+		}
+		pos = starts[prev]
+	}
+	return pos
+}
+
+// sentenceRange returns the characterwise range of the count sentences
+// from the one around pos. With around set the whitespace after them is
+// included (as); otherwise it is left out (is).
+func sentenceRange(buf *buffer.Buffer, pos buffer.Position, count int, around bool) textRange {
+	// One past pos, so a cursor on the first character stays in its sentence
+	line, _ := buf.Line(pos.Line)
+	_, size := utf8.DecodeRuneInString(line[min(pos.Col, len(line)):])
+	after := buffer.Position{Line: pos.Line, Col: pos.Col + max(size, 1)}
+
+	start := sentenceBackward(buf, after, 1)
+	end := sentenceForward(buf, start, count)
+	if !around {
+		end = trimSpaceBefore(buf, start, end)
+	}
+	return textRange{Start: start, End: end}
+}
+
+// trimSpaceBefore moves end back over whitespace and line breaks, but not
+// before start
+func trimSpaceBefore(buf *buffer.Buffer, start, end buffer.Position) buffer.Position {
+	for positionBefore(start, end) {
+		if end.Col == 0 {
+			line, _ := buf.Line(end.Line - 1)
+			end = buffer.Position{Line: end.Line - 1, Col: len(line)}
+			continue
+		}
+		line, _ := buf.Line(end.Line)
+		r, size := utf8.DecodeLastRuneInString(line[:min(end.Col, len(line))])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		end.Col -= size
+	}
+	return end
+}
+
+// motionRange returns the range an operator works on over an exclusive
+// motion from start to end. As in Vim, a motion ending at the start of a
+// line stops at the end of the line before, and one that also begins at
+// or before the first non-blank of its line takes whole lines.
+func motionRange(buf *buffer.Buffer, start, end buffer.Position) textRange {
+	if end.Col > 0 || end.Line <= start.Line {
+		return textRange{Start: start, End: end}
+	}
+	line, _ := buf.Line(end.Line - 1)
+	end = buffer.Position{Line: end.Line - 1, Col: len(line)}
+
+	first, _ := buf.Line(start.Line)
+	indent := len(first) - len(strings.TrimLeftFunc(first, unicode.IsSpace))
+	if start.Col <= indent {
+		return textRange{Start: buffer.Position{Line: start.Line}, End: end, Linewise: true}
+	}
+	return textRange{Start: start, End: end}
+}
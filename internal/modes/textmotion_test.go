@@ -0,0 +1,76 @@
+package modes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestNormalMode_ProseMotions(t *testing.T) {
+	lines := []string{
+		"One. Two words! \"Three?\" Four",
+		"still four.",
+		"",
+		"",
+		"Second paragraph.",
+	}
+	tests := []struct {
+		keys   string
+		cursor buffer.Position
+		want   buffer.Position
+	}{
+		{"}", buffer.Position{Col: 6}, buffer.Position{Line: 2}},
+		{"2}", buffer.Position{}, buffer.Position{Line: 4, Col: 17}},
+		{"{", buffer.Position{Line: 4, Col: 3}, buffer.Position{Line: 3}},
+		{"2{", buffer.Position{Line: 4, Col: 3}, buffer.Position{}},
+		{")", buffer.Position{}, buffer.Position{Col: 5}},
+		{"3)", buffer.Position{}, buffer.Position{Col: 25}},
+		{"4)", buffer.Position{}, buffer.Position{Line: 2}},
+		{"5)", buffer.Position{}, buffer.Position{Line: 4}},
+		{"(", buffer.Position{Col: 8}, buffer.Position{Col: 5}},
+		{"(", buffer.Position{Col: 5}, buffer.Position{}},
+		{"(", buffer.Position{Line: 1, Col: 3}, buffer.Position{Col: 25}},
+	}
+	for _, tt := range tests {
+		mode := NewNormalMode()
+		buf := newOperatorTestBuffer(lines...)
+		buf.SetCursor(tt.cursor)
+		typeKeys(mode, buf, tt.keys)
+		if got := buf.Cursor(); got != tt.want {
+			t.Errorf("%s from %v moved to %v, want %v", tt.keys, tt.cursor, got, tt.want)
+		}
+	}
+}
+
+func TestNormalMode_ProseOperators(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		cursor buffer.Position
+		keys   string
+		want   []string
+	}{
+		{"d} takes whole lines", []string{"a b", "c d", "", "e"}, buffer.Position{}, "d}", []string{"", "e"}},
+		{"d} from mid line", []string{"a b", "c d", "", "e"}, buffer.Position{Col: 2}, "d}", []string{"a ", "", "e"}},
+		{"d{", []string{"a", "", "b c", "d e"}, buffer.Position{Line: 3, Col: 2}, "d{", []string{"a", "e"}},
+		{"d)", []string{"One. Two."}, buffer.Position{}, "d)", []string{"Two."}},
+		{"d(", []string{"One. Two three."}, buffer.Position{Col: 9}, "d(", []string{"One. three."}},
+		{"dis", []string{"One. Two three. Four."}, buffer.Position{Col: 9}, "dis", []string{"One.  Four."}},
+		{"das", []string{"One. Two three. Four."}, buffer.Position{Col: 9}, "das", []string{"One. Four."}},
+		{"gUas across lines", []string{"One. Two", "three. Four."}, buffer.Position{Col: 5}, "gUas", []string{"One. TWO", "THREE. Four."}},
+		{"d2ap", []string{"a", "", "b", "", "c"}, buffer.Position{}, "d2ap", []string{"c"}},
+		{"dip", []string{"a", "b", "", "c"}, buffer.Position{Line: 1}, "dip", []string{"", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := NewNormalMode()
+			buf := newOperatorTestBuffer(tt.lines...)
+			buf.SetCursor(tt.cursor)
+			typeKeys(mode, buf, tt.keys)
+			if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,18 +1,47 @@
 package modes
 
 import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/commands"
+	"github.com/dshills/aied/internal/registers"
 	"github.com/dshills/aied/internal/ui"
 )
 
 // VisualMode implements VIM visual mode behavior
 type VisualMode struct {
 	startPos buffer.Position // Where selection started
+	prefix   rune            // Pending prefix key (g)
+	count    int             // Pending count typed before a command (0 if none)
+
+	commentStyles map[string]CommentStyle // Configured comment strings by filetype
+	register      rune                    // Register selected with "x for the next operator
+	registers     *registers.Store
+	ctx           *Context          // Shows the counts of g Ctrl-G
+	startCommand  func(line string) // Starts the command line with line, for ga
 }
 
 // NewVisualMode creates a new visual mode instance
 func NewVisualMode() *VisualMode {
-	return &VisualMode{}
+	return &VisualMode{registers: registers.NewStore()}
+}
+
+// SetContext sets the editor context
+func (v *VisualMode) SetContext(ctx *Context) {
+	v.ctx = ctx
+}
+
+// SetRegisters sets the register store used by y and d
+func (v *VisualMode) SetRegisters(store *registers.Store) {
+	v.registers = store
+}
+
+// SetCommentStyles sets the comment strings used by gc, overriding the defaults per filetype
+func (v *VisualMode) SetCommentStyles(styles map[string]CommentStyle) {
+	v.commentStyles = styles
 }
 
 // Type returns the mode type
@@ -22,12 +51,47 @@ func (v *VisualMode) Type() ModeType {
 
 // HandleInput processes keyboard input in visual mode
 func (v *VisualMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResult {
+	// The selection this key acts on is the one '< and '> refer to after
+	start, end := v.GetSelection(buf)
+	buf.SetVisualArea(start, end)
+
+	// Accumulate a count; 0 only extends a count already started
+	if event.Action == ui.KeyActionChar && v.prefix == 0 &&
+		(event.Rune >= '1' && event.Rune <= '9' || event.Rune == '0' && v.count > 0) {
+		v.count = v.count*10 + int(event.Rune-'0')
+		return ModeResult{Handled: true}
+	}
+
+	count := int64(max(v.count, 1))
+	prefix := v.prefix
+	v.count = 0
+	v.prefix = 0
+
 	switch event.Action {
 	case ui.KeyActionEscape:
 		// Return to normal mode
 		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
 
 	case ui.KeyActionChar:
+		if prefix == 'g' && strings.ContainsRune("jk0$", event.Rune) {
+			// Display line movement
+			buf.SetCursor(displayLineMotion(event.Rune, buf, buf.Cursor(), int(count), v.ctx.TextWidth()))
+			return ModeResult{Handled: true}
+		}
+		if prefix == 'g' {
+			return v.handleGCommand(event.Rune, int(count), buf)
+		}
+		if prefix == '"' {
+			if registers.IsReadable(event.Rune) {
+				v.register = event.Rune
+			}
+			return ModeResult{Handled: true}
+		}
+		if strings.ContainsRune("{}()", event.Rune) {
+			// Paragraph and sentence movement
+			buf.SetCursor(proseMotion(event.Rune, buf, buf.Cursor(), int(count)))
+			return ModeResult{Handled: true}
+		}
 		return v.handleCharacter(event.Rune, buf)
 
 	case ui.KeyActionUp, ui.KeyActionDown, ui.KeyActionLeft, ui.KeyActionRight:
@@ -40,6 +104,41 @@ func (v *VisualMode) HandleInput(event ui.KeyEvent, buf *buffer.Buffer) ModeResu
 		// Return to normal mode
 		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
 
+	case ui.KeyActionCtrlA:
+		// Increment numbers in selection (g Ctrl-A for a progressive sequence)
+		return v.incrementSelection(buf, count, prefix == 'g')
+
+	case ui.KeyActionCtrlX:
+		// Decrement numbers in selection (g Ctrl-X for a progressive sequence)
+		return v.incrementSelection(buf, -count, prefix == 'g')
+
+	case ui.KeyActionAltUp, ui.KeyActionAltDown:
+		// Move the selected lines, keeping them selected
+		by := int(count)
+		if event.Action == ui.KeyActionAltUp {
+			by = -by
+		}
+		cursor := buf.Cursor()
+		moved := moveLinesBy(buf, start.Line, end.Line, by)
+		v.startPos.Line += moved
+		buf.SetCursor(buffer.Position{Line: cursor.Line + moved, Col: cursor.Col})
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlG:
+		// g Ctrl-G counts the selected lines, words and characters
+		if prefix == 'g' && v.ctx != nil {
+			line, _ := buf.Line(end.Line)
+			_, size := utf8.DecodeRuneInString(line[min(end.Col, len(line)):])
+			end.Col += size
+			v.ctx.Message(commands.SelectionStats(buf, start, end))
+		}
+		return ModeResult{Handled: true}
+
+	case ui.KeyActionCtrlN:
+		// Put a cursor on every selected line at the cursor column
+		v.addColumnCursors(buf, buf.Cursor().Col, false)
+		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+
 	default:
 		return ModeResult{Handled: false}
 	}
@@ -86,21 +185,90 @@ func (v *VisualMode) handleCharacter(ch rune, buf *buffer.Buffer) ModeResult {
 
 	// Operations on selection
 	case 'd', 'x':
-		// Delete selected text (TODO: implement actual selection deletion)
-		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+		return v.applyOperator("d", buf)
 	case 'y':
-		// Yank (copy) selected text (TODO: implement actual selection copying)
-		return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+		return v.applyOperator("y", buf)
+	case '"':
+		v.prefix = '"'
+		return ModeResult{Handled: true}
 
 	// Switch to other modes
+	case ':':
+		// The command line starts with the selection's range, '<,'>
+		return ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
 	case 'i':
 		return ModeResult{SwitchToMode: &[]ModeType{ModeInsert}[0], Handled: true}
 
+	// Column-wise insert with a cursor on every selected line
+	case 'I':
+		left, _ := v.blockColumns(buf)
+		v.addColumnCursors(buf, left, false)
+		return ModeResult{SwitchToMode: &[]ModeType{ModeInsert}[0], Handled: true}
+	case 'A':
+		_, right := v.blockColumns(buf)
+		v.addColumnCursors(buf, right+1, true)
+		return ModeResult{SwitchToMode: &[]ModeType{ModeInsert}[0], Handled: true}
+
+	// Case operators
+	case 'u':
+		return v.applyOperator("gu", buf)
+	case 'U':
+		return v.applyOperator("gU", buf)
+	case '~':
+		return v.applyOperator("g~", buf)
+
+	// Prefix for g Ctrl-A / g Ctrl-X and gu, gU, g~, gq, gc
+	case 'g':
+		v.prefix = 'g'
+		return ModeResult{Handled: true}
+
 	default:
 		return ModeResult{Handled: false}
 	}
 }
 
+// handleGCommand processes the key following g in visual mode
+func (v *VisualMode) handleGCommand(ch rune, count int, buf *buffer.Buffer) ModeResult {
+	switch ch {
+	case 'u', 'U', '~', 'q', 'c':
+		return v.applyOperator("g"+string(ch), buf)
+	case 'g':
+		buf.SetCursor(buffer.Position{Line: 0, Col: 0})
+		return ModeResult{Handled: true}
+	case 'e':
+		buf.SetCursor(wordEndBackward(buf, buf.Cursor(), count))
+		return ModeResult{Handled: true}
+	case '_':
+		buf.SetCursor(lastNonBlank(buf, min(buf.Cursor().Line+count-1, buf.LineCount()-1)))
+		return ModeResult{Handled: true}
+	case 'a':
+		// Align the selected lines on the delimiter typed next
+		if v.startCommand != nil {
+			v.startCommand("'<,'>align ")
+		}
+		return ModeResult{SwitchToMode: &[]ModeType{ModeCommand}[0], Handled: true}
+	default:
+		if v.ctx != nil {
+			v.ctx.Message("Unknown command: g" + string(ch))
+		}
+		return ModeResult{Handled: true}
+	}
+}
+
+// applyOperator runs a case or format operator over the selection and returns to normal mode
+func (v *VisualMode) applyOperator(op string, buf *buffer.Buffer) ModeResult {
+	start, end := v.GetSelection(buf)
+
+	// The selection includes the character under its end
+	line, _ := buf.Line(end.Line)
+	end.Col = min(end.Col+1, len(line))
+
+	env := operatorEnv{commentStyles: v.commentStyles, registers: v.registers, register: v.register}
+	v.register = 0
+	applyOperatorRange(op, textRange{Start: start, End: end, Linewise: op == "gq" || op == "gc"}, buf, env)
+	return ModeResult{SwitchToMode: &[]ModeType{ModeNormal}[0], Handled: true}
+}
+
 // Word movement methods (similar to normal mode)
 func (v *VisualMode) moveWordForward(buf *buffer.Buffer) {
 	cursor := buf.Cursor()
@@ -238,9 +406,21 @@ func (v *VisualMode) handleHomeEnd(action ui.KeyAction, buf *buffer.Buffer) Mode
 
 // OnEnter is called when entering visual mode
 func (v *VisualMode) OnEnter(buf *buffer.Buffer) {
+	v.prefix = 0
+	v.count = 0
+	v.register = 0
 	if buf == nil {
 		return
 	}
+	// gv selects the last visual area again, with the cursor at its end
+	if v.ctx != nil && v.ctx.reselect {
+		v.ctx.reselect = false
+		if start, end, ok := buf.VisualArea(); ok {
+			v.startPos = start
+			buf.SetCursor(end)
+			return
+		}
+	}
 	// Remember where selection started
 	v.startPos = buf.Cursor()
 }
@@ -256,6 +436,33 @@ func (v *VisualMode) GetStatusText() string {
 	return "-- VISUAL --"
 }
 
+// PendingText returns the register, count and prefix typed so far of an
+// unfinished command
+func (v *VisualMode) PendingText() string {
+	status := ""
+	if v.register != 0 {
+		status = "\"" + string(v.register)
+	}
+	if v.count > 0 {
+		status += strconv.Itoa(v.count)
+	}
+	if v.prefix != 0 {
+		status += string(v.prefix)
+	}
+	return status
+}
+
+// AwaitingKey reports whether a prefix waits for the key that completes it
+func (v *VisualMode) AwaitingKey() bool {
+	return v.prefix != 0
+}
+
+// TimeOut drops the keys waiting for the next
+func (v *VisualMode) TimeOut(buf *buffer.Buffer) ModeResult {
+	v.count, v.prefix = 0, 0
+	return ModeResult{Handled: true}
+}
+
 // GetSelection returns the current selection range
 func (v *VisualMode) GetSelection(buf *buffer.Buffer) (buffer.Position, buffer.Position) {
 	currentPos := buf.Cursor()
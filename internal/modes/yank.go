@@ -0,0 +1,130 @@
+package modes
+
+import (
+	"strings"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/registers"
+)
+
+// operatorEnv carries the mode settings operators depend on
+type operatorEnv struct {
+	commentStyles map[string]CommentStyle
+	registers     *registers.Store
+	register      rune // Register selected with "x (0 for the unnamed register)
+}
+
+// rangeText returns the text covered by r, joining lines with newlines
+func rangeText(buf *buffer.Buffer, r textRange) string {
+	var parts []string
+	for lineNum := r.Start.Line; lineNum <= r.End.Line; lineNum++ {
+		line, err := buf.Line(lineNum)
+		if err != nil {
+			break
+		}
+		if !r.Linewise {
+			if lineNum == r.End.Line {
+				line = line[:min(r.End.Col, len(line))]
+			}
+			if lineNum == r.Start.Line {
+				line = line[min(r.Start.Col, len(line)):]
+			}
+		}
+		parts = append(parts, line)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// deleteRange removes the text covered by r
+func deleteRange(buf *buffer.Buffer, r textRange) {
+	if r.Linewise {
+		buf.ReplaceLines(r.Start.Line, r.End.Line, nil)
+		line := min(r.Start.Line, buf.LineCount()-1)
+		text, _ := buf.Line(line)
+		buf.SetCursor(buffer.Position{Line: line, Col: indentEnd(text)})
+		return
+	}
+
+	first, err := buf.Line(r.Start.Line)
+	if err != nil {
+		return
+	}
+	last, err := buf.Line(r.End.Line)
+	if err != nil {
+		return
+	}
+	joined := first[:min(r.Start.Col, len(first))] + last[min(r.End.Col, len(last)):]
+	buf.ReplaceLines(r.Start.Line, r.End.Line, []string{joined})
+	buf.SetCursor(r.Start)
+}
+
+// yankRange copies r into the selected register
+func yankRange(buf *buffer.Buffer, r textRange, env operatorEnv) {
+	if env.registers != nil {
+		env.registers.Yank(env.register, rangeText(buf, r), r.Linewise)
+	}
+	if r.Linewise {
+		buf.SetCursor(buffer.Position{Line: r.Start.Line, Col: buf.Cursor().Col})
+	} else {
+		buf.SetCursor(r.Start)
+	}
+}
+
+// cutRange moves r into the selected register
+func cutRange(buf *buffer.Buffer, r textRange, env operatorEnv) {
+	if env.registers != nil {
+		env.registers.Delete(env.register, rangeText(buf, r), r.Linewise)
+	}
+	deleteRange(buf, r)
+}
+
+// put pastes a register count times after (p) or before (P) the cursor
+func put(buf *buffer.Buffer, store *registers.Store, name rune, count int, before bool) {
+	if store == nil {
+		return
+	}
+	reg, ok := store.Get(name)
+	if !ok {
+		return
+	}
+
+	cursor := buf.Cursor()
+	if reg.Linewise {
+		lines := strings.Split(reg.Text, "\n")
+		var pasted []string
+		for i := 0; i < count; i++ {
+			pasted = append(pasted, lines...)
+		}
+
+		current := buf.CurrentLine()
+		target := cursor.Line + 1
+		replacement := append([]string{current}, pasted...)
+		if before {
+			target = cursor.Line
+			replacement = append(pasted, current)
+		}
+		buf.ReplaceLines(cursor.Line, cursor.Line, replacement)
+		buf.SetCursor(buffer.Position{Line: target, Col: indentEnd(pasted[0])})
+		return
+	}
+
+	text := strings.Repeat(reg.Text, count)
+	line := buf.CurrentLine()
+	col := cursor.Col
+	if !before && len(line) > 0 {
+		col = min(col+1, len(line))
+	}
+
+	parts := strings.Split(text, "\n")
+	parts[0] = line[:col] + parts[0]
+	last := len(parts) - 1
+	endCol := len(parts[last]) - 1
+	parts[last] += line[col:]
+	buf.ReplaceLines(cursor.Line, cursor.Line, parts)
+
+	if last == 0 {
+		buf.SetCursor(buffer.Position{Line: cursor.Line, Col: max(endCol, 0)})
+	} else {
+		buf.SetCursor(buffer.Position{Line: cursor.Line, Col: col})
+	}
+}
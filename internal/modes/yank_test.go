@@ -0,0 +1,54 @@
+package modes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/ui"
+)
+
+func TestNormalMode_YankAndPaste(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		cursor   buffer.Position
+		keys     string
+		expected []string
+	}{
+		{"yyp", []string{"one", "two"}, buffer.Position{}, "yyp", []string{"one", "one", "two"}},
+		{"2yyP", []string{"one", "two"}, buffer.Position{Line: 1}, "k2yyjP", []string{"one", "one", "two", "two"}},
+		{"dd", []string{"one", "two", "three"}, buffer.Position{Line: 1}, "dd", []string{"one", "three"}},
+		{"ddp moves line down", []string{"one", "two", "three"}, buffer.Position{}, "ddp", []string{"two", "one", "three"}},
+		{"dw", []string{"foo bar"}, buffer.Position{}, "dw", []string{"bar"}},
+		{"ywP", []string{"foo bar"}, buffer.Position{Col: 4}, "ywP", []string{"foo barbar"}},
+		{"named register", []string{"one", "two"}, buffer.Position{}, "\"ayyjdd\"ap", []string{"one", "one"}},
+		{"d$", []string{"keep this"}, buffer.Position{Col: 4}, "d$", []string{"keep"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := NewNormalMode()
+			buf := newOperatorTestBuffer(tt.lines...)
+			buf.SetCursor(tt.cursor)
+
+			typeKeys(mode, buf, tt.keys)
+
+			if got := buf.Lines(); strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestVisualMode_YankAndDelete(t *testing.T) {
+	mm := NewModeManager()
+	buf := newOperatorTestBuffer("hello world")
+
+	for _, ch := range "vey$p" {
+		mm.HandleInput(ui.KeyEvent{Action: ui.KeyActionChar, Rune: ch}, buf)
+	}
+	if buf.CurrentLine() != "hello worldhello" {
+		t.Errorf("expected visual yank to paste, got %q", buf.CurrentLine())
+	}
+}
@@ -0,0 +1,95 @@
+package palette
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// usage is how often and when a command was last run from the palette
+type usage struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// Frecency remembers the commands run from the palette, ranking them by how
+// often and how recently they were used. A nil *Frecency ranks none.
+type Frecency struct {
+	mu   sync.Mutex
+	path string // File the uses are saved to, or "" to keep them in memory
+	uses map[string]usage
+}
+
+// OpenFrecency loads the uses saved in path, which need not exist yet. An
+// empty path keeps them in memory only.
+func OpenFrecency(path string) (*Frecency, error) {
+	f := &Frecency{path: path, uses: make(map[string]usage)}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f.uses); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// Record counts a use of the command name and saves the uses
+func (f *Frecency) Record(name string, now time.Time) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u := f.uses[name]
+	u.Count++
+	u.Last = now
+	f.uses[name] = u
+	if f.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(f.uses)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0644)
+}
+
+// Score ranks the command name by its uses, weighting recent ones more
+func (f *Frecency) Score(name string, now time.Time) float64 {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	u, ok := f.uses[name]
+	f.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	age := now.Sub(u.Last)
+	weight := 10.0
+	switch {
+	case age < time.Hour:
+		weight = 100
+	case age < 24*time.Hour:
+		weight = 80
+	case age < 7*24*time.Hour:
+		weight = 60
+	case age < 30*24*time.Hour:
+		weight = 30
+	}
+	return float64(u.Count) * weight
+}
@@ -0,0 +1,134 @@
+// Package palette filters the editor's commands by fuzzy search, ordering
+// them by how often and how recently they were used.
+package palette
+
+import (
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Entry is a command offered by the palette
+type Entry struct {
+	Name string // Command name, run as :Name
+	Help string // What the command does
+	Keys string // Keys that run the command in normal mode, if any
+}
+
+// Match is an entry matching the query, with the byte offsets of the
+// matched characters of its name
+type Match struct {
+	Entry
+	Positions []int
+	byHelp    bool // Matched by its help only
+	score     float64
+}
+
+// Filter returns the entries whose name or help matches query, best first.
+// With an empty query every entry matches, most used first. Entries matched
+// by their help only come after those matched by name.
+func Filter(entries []Entry, query string, history *Frecency, now time.Time) []Match {
+	var matches []Match
+	for _, e := range entries {
+		m := Match{Entry: e}
+		if query != "" {
+			score, positions, ok := Fuzzy(query, e.Name)
+			if ok {
+				m.Positions = positions
+				m.score = float64(score)
+			} else if score, _, ok := Fuzzy(query, e.Help); ok {
+				m.byHelp = true
+				m.score = float64(score)
+			} else {
+				continue
+			}
+		}
+		m.score += history.Score(e.Name, now)
+		matches = append(matches, m)
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		switch {
+		case a.byHelp != b.byHelp:
+			if b.byHelp {
+				return -1
+			}
+			return 1
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return matches
+}
+
+// Rank returns the names matching query, best first and otherwise in the
+// order given, so an empty query keeps them all in that order. The entries
+// have only their names, for lists that aren't commands like recent files.
+func Rank(names []string, query string) []Match {
+	var matches []Match
+	for _, name := range names {
+		score, positions, ok := Fuzzy(query, name)
+		if ok {
+			matches = append(matches, Match{Entry: Entry{Name: name}, Positions: positions, score: float64(score)})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+	return matches
+}
+
+// Fuzzy reports whether the characters of pattern appear in text in order,
+// ignoring case, with a score that is higher for matches at the start of
+// text and of its words and for runs of consecutive characters. It also
+// returns the byte offsets of the matched characters.
+func Fuzzy(pattern, text string) (int, []int, bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+	pat := []rune(strings.ToLower(pattern))
+	score, p := 0, 0
+	var positions []int
+	prevMatched := false
+	var prev rune
+	for i, r := range text {
+		if p < len(pat) && unicode.ToLower(r) == pat[p] {
+			switch {
+			case i == 0:
+				score += 10
+			case !unicode.IsLetter(prev) && !unicode.IsDigit(prev):
+				score += 8
+			case unicode.IsUpper(r) && unicode.IsLower(prev):
+				score += 6
+			}
+			if prevMatched {
+				score += 5
+			}
+			score++
+			positions = append(positions, i)
+			p++
+			prevMatched = true
+		} else {
+			if p > 0 && p < len(pat) {
+				score-- // Gaps inside the match count against it
+			}
+			prevMatched = false
+		}
+		prev = r
+	}
+	if p < len(pat) {
+		return 0, nil, false
+	}
+	// Shorter texts match more closely
+	score -= len(text) / 8
+	return score, positions, true
+}
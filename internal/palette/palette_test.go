@@ -0,0 +1,124 @@
+package palette
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFuzzy(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		positions     []int
+		ok            bool
+	}{
+		{"", "write", nil, true},
+		{"wq", "wq", []int{0, 1}, true},
+		{"ah", "ai-history", []int{0, 3}, true},
+		{"AH", "ai-history", []int{0, 3}, true},
+		{"hw", "write", nil, false},
+		{"writes", "write", nil, false},
+	}
+	for _, tt := range tests {
+		_, positions, ok := Fuzzy(tt.pattern, tt.text)
+		if ok != tt.ok || !slices.Equal(positions, tt.positions) {
+			t.Errorf("Fuzzy(%q, %q) = %v, %v; want %v, %v", tt.pattern, tt.text, positions, ok, tt.positions, tt.ok)
+		}
+	}
+}
+
+func TestFuzzy_PrefersWordStartsAndRuns(t *testing.T) {
+	start, _, _ := Fuzzy("his", "ai-history")
+	middle, _, _ := Fuzzy("his", "chisel")
+	if start <= middle {
+		t.Errorf("word start scored %d, not above %d in the middle of a word", start, middle)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	entries := []Entry{
+		{Name: "write", Help: "Save the file"},
+		{Name: "quit", Help: "Close the editor"},
+		{Name: "wq", Help: "Write and quit"},
+		{Name: "edit", Help: "Open a file"},
+	}
+	now := time.Now()
+
+	names := func(matches []Match) []string {
+		var names []string
+		for _, m := range matches {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+
+	if got := names(Filter(entries, "", nil, now)); !slices.Equal(got, []string{"edit", "quit", "wq", "write"}) {
+		t.Errorf("empty query listed %v, want every entry by name", got)
+	}
+	// No name matches "file", so the entries are found by their help
+	if got := names(Filter(entries, "file", nil, now)); !slices.Equal(got, []string{"edit", "write"}) {
+		t.Errorf("help matches listed %v", got)
+	}
+	if got := names(Filter(entries, "qt", nil, now)); got[0] != "quit" {
+		t.Errorf("name match listed after help matches: %v", got)
+	}
+
+	history, _ := OpenFrecency("")
+	history.Record("wq", now)
+	if got := names(Filter(entries, "", history, now)); got[0] != "wq" {
+		t.Errorf("used command not listed first: %v", got)
+	}
+}
+
+func TestRank(t *testing.T) {
+	files := []string{"src/main.go", "README.md", "src/editor.go"}
+	names := func(matches []Match) []string {
+		var names []string
+		for _, m := range matches {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+
+	if got := names(Rank(files, "")); !slices.Equal(got, files) {
+		t.Errorf("empty query listed %v, want the given order", got)
+	}
+	if got := names(Rank(files, "edit")); !slices.Equal(got, []string{"src/editor.go"}) {
+		t.Errorf("query listed %v", got)
+	}
+}
+
+func TestFrecency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aied", "palette.json")
+	now := time.Now()
+
+	history, err := OpenFrecency(path)
+	if err != nil {
+		t.Fatalf("OpenFrecency failed on a missing file: %v", err)
+	}
+	if err := history.Record("make", now.Add(-48*time.Hour)); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	history.Record("make", now.Add(-48*time.Hour))
+	history.Record("write", now)
+
+	reopened, err := OpenFrecency(path)
+	if err != nil {
+		t.Fatalf("OpenFrecency failed: %v", err)
+	}
+	if got := reopened.Score("make", now); got != 120 {
+		t.Errorf("make scored %v, want 2 uses this week = 120", got)
+	}
+	if got := reopened.Score("write", now); got != 100 {
+		t.Errorf("write scored %v, want 1 use this hour = 100", got)
+	}
+	if got := reopened.Score("quit", now); got != 0 {
+		t.Errorf("unused command scored %v", got)
+	}
+
+	var none *Frecency
+	if none.Score("make", now) != 0 || none.Record("make", now) != nil {
+		t.Error("nil Frecency should rank nothing")
+	}
+}
@@ -0,0 +1,138 @@
+// Package perf records how long the editor takes to render, handle keys
+// and wait for language servers and AI providers, for :perf, and writes
+// pprof profiles for --profile and --pprof.
+package perf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// window is how many recent samples of each timing are kept for percentiles
+const window = 200
+
+// Stat summarizes the samples of one timing
+type Stat struct {
+	Name  string
+	Count int
+	Last  time.Duration
+	Avg   time.Duration
+	P95   time.Duration // Of the recent samples
+	Max   time.Duration
+}
+
+// series holds the samples of one timing
+type series struct {
+	recent []time.Duration
+	next   int
+	count  int
+	total  time.Duration
+	last   time.Duration
+	max    time.Duration
+}
+
+// Recorder keeps timings by name. It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{series: make(map[string]*series)}
+}
+
+// Record adds a sample of the timing name
+func (r *Recorder) Record(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series[name]
+	if !ok {
+		s = &series{}
+		r.series[name] = s
+	}
+	if len(s.recent) < window {
+		s.recent = append(s.recent, d)
+	} else {
+		s.recent[s.next] = d
+		s.next = (s.next + 1) % window
+	}
+	s.count++
+	s.total += d
+	s.last = d
+	s.max = max(s.max, d)
+}
+
+// Since records the time since start as a sample of name, as in
+// defer r.Since("render", time.Now())
+func (r *Recorder) Since(name string, start time.Time) {
+	r.Record(name, time.Since(start))
+}
+
+// Stats summarizes every timing, sorted by name
+func (r *Recorder) Stats() []Stat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make([]Stat, 0, len(r.series))
+	for name, s := range r.series {
+		sorted := slices.Clone(s.recent)
+		slices.Sort(sorted)
+		stats = append(stats, Stat{
+			Name:  name,
+			Count: s.count,
+			Last:  s.last,
+			Avg:   s.total / time.Duration(s.count),
+			P95:   sorted[(len(sorted)*95-1)/100],
+			Max:   s.max,
+		})
+	}
+	slices.SortFunc(stats, func(a, b Stat) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// Reset forgets every sample
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series = make(map[string]*series)
+}
+
+// Default is the recorder the editor's timings go to
+var Default = NewRecorder()
+
+// Record adds a sample to the default recorder
+func Record(name string, d time.Duration) {
+	Default.Record(name, d)
+}
+
+// Since records the time since start in the default recorder
+func Since(name string, start time.Time) {
+	Default.Since(name, start)
+}
+
+// Lines formats stats as a table
+func Lines(stats []Stat) []string {
+	lines := []string{fmt.Sprintf("%-36s %7s %9s %9s %9s %9s", "Timing", "Count", "Last", "Avg", "P95", "Max")}
+	for _, s := range stats {
+		lines = append(lines, fmt.Sprintf("%-36s %7d %9s %9s %9s %9s", s.Name, s.Count, short(s.Last), short(s.Avg), short(s.P95), short(s.Max)))
+	}
+	if len(stats) == 0 {
+		lines = append(lines, "Nothing timed yet")
+	}
+	return lines
+}
+
+// short formats d to three significant digits or so
+func short(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+	default:
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	}
+}
@@ -0,0 +1,85 @@
+package perf
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorderStats(t *testing.T) {
+	r := NewRecorder()
+	for i := 1; i <= 100; i++ {
+		r.Record("render", time.Duration(i)*time.Millisecond)
+	}
+	r.Record("ai ollama", 2*time.Second)
+
+	stats := r.Stats()
+	if len(stats) != 2 || stats[0].Name != "ai ollama" || stats[1].Name != "render" {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+	render := stats[1]
+	if render.Count != 100 || render.Last != 100*time.Millisecond || render.Max != 100*time.Millisecond {
+		t.Errorf("render = %+v", render)
+	}
+	if render.Avg != 50500*time.Microsecond || render.P95 != 95*time.Millisecond {
+		t.Errorf("render avg %v, p95 %v", render.Avg, render.P95)
+	}
+
+	lines := Lines(stats)
+	if len(lines) != 3 || !strings.Contains(lines[1], "2.00s") || !strings.Contains(lines[2], "95.0ms") {
+		t.Errorf("Lines() = %q", lines)
+	}
+
+	r.Reset()
+	if got := Lines(r.Stats()); got[1] != "Nothing timed yet" {
+		t.Errorf("Lines() after Reset = %q", got)
+	}
+}
+
+func TestRecorderKeepsRecentSamples(t *testing.T) {
+	r := NewRecorder()
+	for range window {
+		r.Record("key to frame", time.Second)
+	}
+	for range window {
+		r.Record("key to frame", time.Millisecond)
+	}
+	s := r.Stats()[0]
+	if s.P95 != time.Millisecond || s.Max != time.Second || s.Count != 2*window {
+		t.Errorf("stat = %+v, want the percentile of recent samples only", s)
+	}
+}
+
+func TestStartProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	stop, err := StartProfile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cpu.pprof", "heap.pprof"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestServePprof(t *testing.T) {
+	addr, err := ServePprof("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get("http://" + addr + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("pprof index status = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,130 @@
+// Package privacy checks AI requests before they leave the machine: it
+// redacts secrets, refuses requests about files that mustn't be shared and
+// keeps some projects on local providers.
+package privacy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dshills/aied/internal/ai"
+)
+
+// Redacted replaces secrets in requests
+const Redacted = "[REDACTED]"
+
+// Policy holds the privacy rules. It implements ai.Guard.
+type Policy struct {
+	redact     []*regexp.Regexp
+	blockFiles []string
+	localOnly  []string
+}
+
+// New compiles a policy. Text matching a redact pattern is replaced, or
+// only its group named "secret" if it has one. Block patterns are globs
+// matched against file names and paths. Local-only patterns are globs
+// matched against project roots and names, with ~ for the home directory.
+func New(redact, blockFiles, localOnly []string) (*Policy, error) {
+	p := &Policy{blockFiles: blockFiles}
+	for _, pattern := range redact {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		p.redact = append(p.redact, re)
+	}
+	for _, pattern := range slices.Concat(blockFiles, localOnly) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	home, _ := os.UserHomeDir()
+	for _, pattern := range localOnly {
+		if rest, ok := strings.CutPrefix(pattern, "~/"); ok && home != "" {
+			pattern = filepath.Join(home, rest)
+		}
+		p.localOnly = append(p.localOnly, pattern)
+	}
+	return p, nil
+}
+
+// Check returns req with secrets redacted, or an error if it is about a
+// blocked file. local reports whether its project is kept on local
+// providers.
+func (p *Policy) Check(req ai.AIRequest) (ai.AIRequest, bool, error) {
+	if p.Blocked(req.Filename) {
+		return req, false, fmt.Errorf("%s is blocked from AI requests by privacy.block_files", filepath.Base(req.Filename))
+	}
+	req.Prompt, _ = p.Redact(req.Prompt)
+	req.Context, _ = p.Redact(req.Context)
+	return req, p.LocalOnly(req.Root), nil
+}
+
+// Redact replaces the secrets in text and returns how many it replaced
+func (p *Policy) Redact(text string) (string, int) {
+	count := 0
+	for _, re := range p.redact {
+		secret := re.SubexpIndex("secret")
+		text = replaceAll(re, text, func(match []int) (int, int) {
+			count++
+			if secret > 0 && match[2*secret] >= 0 {
+				return match[2*secret], match[2*secret+1]
+			}
+			return match[0], match[1]
+		})
+	}
+	return text, count
+}
+
+// replaceAll replaces the part of each match of re in text that span
+// selects with Redacted
+func replaceAll(re *regexp.Regexp, text string, span func(match []int) (int, int)) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := span(match)
+		b.WriteString(text[last:start])
+		b.WriteString(Redacted)
+		last = end
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Blocked reports whether filename mustn't be sent to AI providers. A nil
+// policy blocks nothing.
+func (p *Policy) Blocked(filename string) bool {
+	if p == nil || filename == "" {
+		return false
+	}
+	return matchAny(p.blockFiles, filename)
+}
+
+// LocalOnly reports whether requests about the project at root are kept
+// on local providers
+func (p *Policy) LocalOnly(root string) bool {
+	if root == "" {
+		return false
+	}
+	return matchAny(p.localOnly, root)
+}
+
+// matchAny reports whether a glob in patterns matches path or its base name
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMarkers are the files and directories that mark a project root
+var DefaultMarkers = []string{
+	".git",
+	"go.mod",
+	"package.json",
+	"pyproject.toml",
+	"Cargo.toml",
+	".aied.yaml",
+	".aied.json",
+}
+
+// Project describes the workspace a file belongs to
+type Project struct {
+	Root   string // Absolute path of the project root
+	Marker string // Marker found at the root, empty when no marker was found
+}
+
+// Name returns the name of the project directory
+func (p Project) Name() string {
+	return filepath.Base(p.Root)
+}
+
+// Relative returns path relative to the project root when it lies inside it
+func (p Project) Relative(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(p.Root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+// Detect finds the project containing path by walking up from it to the
+// nearest directory holding one of markers (DefaultMarkers when empty).
+// An empty path searches from the working directory. Without a marker the
+// root is the file's directory, or the working directory for an empty path.
+func Detect(path string, markers []string) Project {
+	if len(markers) == 0 {
+		markers = DefaultMarkers
+	}
+
+	start := path
+	if start == "" {
+		start = "."
+	}
+	abs, err := filepath.Abs(start)
+	if err != nil {
+		return Project{Root: start}
+	}
+	if root, marker, ok := findRoot(abs, markers); ok {
+		return Project{Root: root, Marker: marker}
+	}
+	return Project{Root: startDir(abs)}
+}
+
+// startDir returns path itself if it is a directory, otherwise its parent
+func startDir(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return path
+	}
+	return filepath.Dir(path)
+}
+
+// findRoot walks up from the absolute path looking for a directory containing a marker
+func findRoot(path string, markers []string) (string, string, bool) {
+	dir := startDir(path)
+	for {
+		for _, marker := range markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir, marker, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,52 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	repo := filepath.Join(dir, "repo")
+	module := filepath.Join(repo, "service")
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	os.MkdirAll(filepath.Join(module, "cmd"), 0755)
+	os.WriteFile(filepath.Join(module, "go.mod"), []byte("module service\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "README.md"), nil, 0644)
+	outside := filepath.Join(dir, "loose", "notes.txt")
+	os.MkdirAll(filepath.Dir(outside), 0755)
+
+	tests := []struct {
+		name   string
+		path   string
+		root   string
+		marker string
+	}{
+		{"nearest marker wins", filepath.Join(module, "cmd", "main.go"), module, "go.mod"},
+		{"repository root", filepath.Join(repo, "README.md"), repo, ".git"},
+		{"directory path", module, module, "go.mod"},
+		{"no marker uses file directory", outside, filepath.Dir(outside), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proj := Detect(tt.path, []string{".git", "go.mod"})
+			if proj.Root != tt.root || proj.Marker != tt.marker {
+				t.Errorf("expected %s (%s), got %s (%s)", tt.root, tt.marker, proj.Root, proj.Marker)
+			}
+		})
+	}
+}
+
+func TestProject_Relative(t *testing.T) {
+	proj := Project{Root: "/src/app"}
+	if got := proj.Relative("/src/app/pkg/main.go"); got != filepath.Join("pkg", "main.go") {
+		t.Errorf("expected path inside root to be relative, got %q", got)
+	}
+	if got := proj.Relative("/src/other/main.go"); got != "/src/other/main.go" {
+		t.Errorf("expected path outside root unchanged, got %q", got)
+	}
+	if proj.Name() != "app" {
+		t.Errorf("expected name app, got %q", proj.Name())
+	}
+}
@@ -0,0 +1,148 @@
+package quickfix
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultErrorFormats match the error output of common compilers and test
+// runners: gcc/clang/go/tsc style file:line:col messages, Python
+// tracebacks and rustc location lines
+var DefaultErrorFormats = []string{
+	"%f:%l:%c: %trror: %m",
+	"%f:%l:%c: %tarning: %m",
+	"%f:%l:%c: %m",
+	"%f:%l: %m",
+	"%f(%l,%c): %m",
+	`File "%f", line %l%.%#`,
+	"--> %f:%l:%c",
+}
+
+// ErrorFormat is a compiled errorformat pattern. Patterns use a subset of
+// Vim's errorformat: %f file, %l line, %c column, %m message, %t type
+// character, %.%# any text and %% a literal percent sign.
+type ErrorFormat struct {
+	pattern *regexp.Regexp
+}
+
+// CompileErrorFormat compiles a single errorformat pattern
+func CompileErrorFormat(format string) (*ErrorFormat, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			expr.WriteString(regexp.QuoteMeta(format[i : i+1]))
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'f':
+			expr.WriteString(`(?P<f>[^\s:()"]+)`)
+		case 'l':
+			expr.WriteString(`(?P<l>\d+)`)
+		case 'c':
+			expr.WriteString(`(?P<c>\d+)`)
+		case 'm':
+			expr.WriteString(`(?P<m>.*)`)
+		case 't':
+			expr.WriteString(`(?P<t>[A-Za-z])`)
+		case '%':
+			expr.WriteString("%")
+		case '.':
+			if strings.HasPrefix(format[i:], ".%#") {
+				expr.WriteString(".*")
+				i += 2
+				continue
+			}
+			return nil, fmt.Errorf("unsupported errorformat item %%. in %q", format)
+		default:
+			return nil, fmt.Errorf("unsupported errorformat item %%%c in %q", format[i], format)
+		}
+	}
+	expr.WriteString("$")
+
+	pattern, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid errorformat %q: %w", format, err)
+	}
+	return &ErrorFormat{pattern: pattern}, nil
+}
+
+// CompileErrorFormats compiles a list of errorformat patterns, using
+// DefaultErrorFormats when formats is empty
+func CompileErrorFormats(formats []string) ([]*ErrorFormat, error) {
+	if len(formats) == 0 {
+		formats = DefaultErrorFormats
+	}
+	compiled := make([]*ErrorFormat, 0, len(formats))
+	for _, format := range formats {
+		ef, err := CompileErrorFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, ef)
+	}
+	return compiled, nil
+}
+
+// Match parses a line of output, returning false when it is not an error line
+func (ef *ErrorFormat) Match(line string) (Item, bool) {
+	match := ef.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return Item{}, false
+	}
+
+	var item Item
+	for i, name := range ef.pattern.SubexpNames() {
+		value := match[i]
+		switch name {
+		case "f":
+			item.Filename = value
+		case "l":
+			n, _ := strconv.Atoi(value)
+			item.Line = max(n-1, 0)
+		case "c":
+			n, _ := strconv.Atoi(value)
+			item.Col = max(n-1, 0)
+		case "m":
+			item.Text = strings.TrimSpace(value)
+		case "t":
+			item.Type = errorType(value)
+		}
+	}
+	return item, true
+}
+
+// ParseOutput turns command output into list entries. Each line is matched
+// against the formats in order after removing leading indentation; lines
+// that match none are kept as entries without a location.
+func ParseOutput(output []string, formats []*ErrorFormat) []Item {
+	items := make([]Item, 0, len(output))
+	for _, line := range output {
+		item := Item{Text: line, NoLocation: true}
+		trimmed := strings.TrimSpace(line)
+		for _, ef := range formats {
+			if match, ok := ef.Match(trimmed); ok {
+				item = match
+				break
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// errorType maps a %t character to an entry type
+func errorType(t string) string {
+	switch strings.ToUpper(t) {
+	case "E":
+		return "E"
+	case "W":
+		return "W"
+	case "I", "N":
+		return "I"
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package quickfix
+
+import "testing"
+
+func TestParseOutput(t *testing.T) {
+	formats, err := CompileErrorFormats(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := []string{
+		"# example.com/pkg",
+		"pkg/main.go:12:5: undefined: foo",
+		"src/app.c:3:1: warning: unused variable",
+		`  File "tool.py", line 7, in <module>`,
+		"    handler_test.go:42: expected 1, got 2",
+		"src/index.ts(4,10): error TS2322: bad type",
+		"FAIL",
+	}
+	items := ParseOutput(output, formats)
+	if len(items) != len(output) {
+		t.Fatalf("expected one entry per line, got %d", len(items))
+	}
+
+	tests := []struct {
+		index    int
+		expected Item
+	}{
+		{0, Item{Text: "# example.com/pkg", NoLocation: true}},
+		{1, Item{Filename: "pkg/main.go", Line: 11, Col: 4, Text: "undefined: foo"}},
+		{2, Item{Filename: "src/app.c", Line: 2, Col: 0, Text: "unused variable", Type: "W"}},
+		{3, Item{Filename: "tool.py", Line: 6}},
+		{4, Item{Filename: "handler_test.go", Line: 41, Text: "expected 1, got 2"}},
+		{5, Item{Filename: "src/index.ts", Line: 3, Col: 9, Text: "error TS2322: bad type"}},
+		{6, Item{Text: "FAIL", NoLocation: true}},
+	}
+	for _, tt := range tests {
+		if items[tt.index] != tt.expected {
+			t.Errorf("line %d: expected %+v, got %+v", tt.index, tt.expected, items[tt.index])
+		}
+	}
+}
+
+func TestCompileErrorFormat_Invalid(t *testing.T) {
+	if _, err := CompileErrorFormat("%f:%q"); err == nil {
+		t.Error("expected error for unsupported item")
+	}
+}
@@ -0,0 +1,80 @@
+package quickfix
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dshills/aied/internal/activity"
+)
+
+// maxGrepResults stops a search that matches too much to be useful
+const maxGrepResults = 10000
+
+// Grep searches files for pattern. Directories are searched recursively,
+// skipping hidden directories and binary files.
+func Grep(pattern *regexp.Regexp, paths []string) ([]Item, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	defer activity.Start("grep " + pattern.String())()
+
+	var items []Item
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root {
+					return err
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			items = append(items, grepFile(pattern, path)...)
+			if len(items) >= maxGrepResults {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			return items, err
+		}
+	}
+	return items, nil
+}
+
+// grepFile returns the matching lines of a single file
+func grepFile(pattern *regexp.Regexp, path string) []Item {
+	content, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+		return nil
+	}
+
+	var items []Item
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNum := 0; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if loc := pattern.FindStringIndex(line); loc != nil {
+			items = append(items, Item{
+				Filename: filepath.Clean(path),
+				Line:     lineNum,
+				Col:      loc[0],
+				Text:     strings.TrimSpace(line),
+			})
+		}
+	}
+	return items
+}
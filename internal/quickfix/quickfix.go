@@ -0,0 +1,186 @@
+package quickfix
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxHistory is the number of lists kept for :colder and :cnewer
+const maxHistory = 10
+
+var (
+	// ErrNoList is returned when there is no list to navigate
+	ErrNoList = errors.New("no list")
+	// ErrNoMoreItems is returned when moving past either end of a list
+	ErrNoMoreItems = errors.New("no more items")
+	// ErrNoValidItems is returned when a list has no entries with a location
+	ErrNoValidItems = errors.New("no entries with a location")
+)
+
+// Item is a single location in a list
+type Item struct {
+	Filename string
+	Line     int    // 0-based line number
+	Col      int    // 0-based column number
+	Text     string // Message shown for the location
+	Type     string // "E" for errors, "W" for warnings, "I" for info, or empty
+	// NoLocation marks command output kept for context; navigation skips it
+	NoLocation bool
+}
+
+// String formats the item as file:line:col: text
+func (it Item) String() string {
+	if it.NoLocation {
+		return "|| " + it.Text
+	}
+	prefix := ""
+	switch it.Type {
+	case "E":
+		prefix = "error: "
+	case "W":
+		prefix = "warning: "
+	case "I":
+		prefix = "info: "
+	}
+	if it.Filename == "" {
+		return fmt.Sprintf("%d:%d: %s%s", it.Line+1, it.Col+1, prefix, it.Text)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s%s", it.Filename, it.Line+1, it.Col+1, prefix, it.Text)
+}
+
+// List is a titled list of locations with a current entry
+type List struct {
+	Title string
+	Items []Item
+	Index int // Current entry
+}
+
+// Current returns the current entry of the list
+func (l *List) Current() (Item, bool) {
+	if l == nil || l.Index < 0 || l.Index >= len(l.Items) {
+		return Item{}, false
+	}
+	return l.Items[l.Index], true
+}
+
+// Stack keeps the history of lists produced by searches and builds, like
+// the quickfix list or a window's location list
+type Stack struct {
+	lists   []*List
+	current int
+	open    bool
+}
+
+// NewStack creates an empty list stack
+func NewStack() *Stack {
+	return &Stack{current: -1}
+}
+
+// Set adds a new list after the current one, dropping any newer lists
+func (s *Stack) Set(title string, items []Item) *List {
+	list := &List{Title: title, Items: items}
+	s.lists = append(s.lists[:s.current+1], list)
+	if len(s.lists) > maxHistory {
+		s.lists = s.lists[len(s.lists)-maxHistory:]
+	}
+	s.current = len(s.lists) - 1
+	return list
+}
+
+// Current returns the active list or nil when no list has been set
+func (s *Stack) Current() *List {
+	if s.current < 0 || s.current >= len(s.lists) {
+		return nil
+	}
+	return s.lists[s.current]
+}
+
+// Len returns the number of lists in the history
+func (s *Stack) Len() int {
+	return len(s.lists)
+}
+
+// Older makes the previous list in the history active
+func (s *Stack) Older(count int) (*List, error) {
+	if s.current < 0 {
+		return nil, ErrNoList
+	}
+	if s.current == 0 {
+		return nil, errors.New("at bottom of list stack")
+	}
+	s.current = max(0, s.current-count)
+	return s.lists[s.current], nil
+}
+
+// Newer makes the next list in the history active
+func (s *Stack) Newer(count int) (*List, error) {
+	if s.current < 0 {
+		return nil, ErrNoList
+	}
+	if s.current == len(s.lists)-1 {
+		return nil, errors.New("at top of list stack")
+	}
+	s.current = min(len(s.lists)-1, s.current+count)
+	return s.lists[s.current], nil
+}
+
+// Next moves count entries forward in the active list
+func (s *Stack) Next(count int) (Item, error) {
+	return s.step(count, 1)
+}
+
+// Prev moves count entries back in the active list
+func (s *Stack) Prev(count int) (Item, error) {
+	return s.step(count, -1)
+}
+
+// step moves count entries with a location in direction dir, stopping at
+// the last one found when the list ends first
+func (s *Stack) step(count, dir int) (Item, error) {
+	list := s.Current()
+	if list == nil || len(list.Items) == 0 {
+		return Item{}, ErrNoList
+	}
+
+	target := -1
+	for i := list.Index + dir; i >= 0 && i < len(list.Items) && count > 0; i += dir {
+		if !list.Items[i].NoLocation {
+			target = i
+			count--
+		}
+	}
+	if target < 0 {
+		return Item{}, ErrNoMoreItems
+	}
+	list.Index = target
+	return list.Items[target], nil
+}
+
+// Select makes entry n (0-based, clamped to the list) current. Entries
+// without a location select the nearest following entry with one.
+func (s *Stack) Select(n int) (Item, error) {
+	list := s.Current()
+	if list == nil || len(list.Items) == 0 {
+		return Item{}, ErrNoList
+	}
+	n = max(0, min(n, len(list.Items)-1))
+	for _, dir := range []int{1, -1} {
+		for i := n; i >= 0 && i < len(list.Items); i += dir {
+			if !list.Items[i].NoLocation {
+				list.Index = i
+				return list.Items[i], nil
+			}
+		}
+	}
+	return Item{}, ErrNoValidItems
+}
+
+// SetOpen shows or hides the list window
+func (s *Stack) SetOpen(open bool) {
+	s.open = open
+}
+
+// IsOpen reports whether the list window is shown
+func (s *Stack) IsOpen() bool {
+	return s.open
+}
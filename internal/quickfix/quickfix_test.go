@@ -0,0 +1,127 @@
+package quickfix
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func testItems(n int) []Item {
+	items := make([]Item, n)
+	for i := range items {
+		items[i] = Item{Filename: "main.go", Line: i, Text: "entry"}
+	}
+	return items
+}
+
+func TestStack_Navigation(t *testing.T) {
+	s := NewStack()
+	if _, err := s.Next(1); !errors.Is(err, ErrNoList) {
+		t.Fatalf("expected ErrNoList, got %v", err)
+	}
+
+	s.Set("build", testItems(3))
+	if item, err := s.Next(1); err != nil || item.Line != 1 {
+		t.Fatalf("expected second entry, got %v %v", item, err)
+	}
+	if item, err := s.Next(5); err != nil || item.Line != 2 {
+		t.Errorf("expected count to clamp to last entry, got %v %v", item, err)
+	}
+	if _, err := s.Next(1); !errors.Is(err, ErrNoMoreItems) {
+		t.Errorf("expected ErrNoMoreItems at end, got %v", err)
+	}
+	if item, err := s.Prev(2); err != nil || item.Line != 0 {
+		t.Errorf("expected first entry, got %v %v", item, err)
+	}
+	if _, err := s.Prev(1); !errors.Is(err, ErrNoMoreItems) {
+		t.Errorf("expected ErrNoMoreItems at start, got %v", err)
+	}
+}
+
+func TestStack_History(t *testing.T) {
+	s := NewStack()
+	s.Set("first", testItems(1))
+	s.Set("second", testItems(2))
+
+	if list, err := s.Older(1); err != nil || list.Title != "first" {
+		t.Fatalf("expected first list, got %v %v", list, err)
+	}
+	if _, err := s.Older(1); err == nil {
+		t.Error("expected error at bottom of stack")
+	}
+	if list, err := s.Newer(1); err != nil || list.Title != "second" {
+		t.Fatalf("expected second list, got %v %v", list, err)
+	}
+
+	// A new list replaces everything newer than the current one
+	s.Older(1)
+	s.Set("third", testItems(1))
+	if s.Len() != 2 || s.Current().Title != "third" {
+		t.Errorf("expected [first third], got %d lists ending in %q", s.Len(), s.Current().Title)
+	}
+
+	for i := 0; i < maxHistory+5; i++ {
+		s.Set("more", nil)
+	}
+	if s.Len() != maxHistory {
+		t.Errorf("expected history capped at %d, got %d", maxHistory, s.Len())
+	}
+}
+
+func TestItem_String(t *testing.T) {
+	item := Item{Filename: "main.go", Line: 9, Col: 4, Text: "undefined: x", Type: "E"}
+	if got := item.String(); got != "main.go:10:5: error: undefined: x" {
+		t.Errorf("unexpected format %q", got)
+	}
+}
+
+func TestGrep(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha\nneedle here\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "bin.dat"), []byte("needle\x00"), 0644)
+	os.Mkdir(filepath.Join(dir, ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, ".git", "config"), []byte("needle"), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("  a needle\n"), 0644)
+
+	items, err := Grep(regexp.MustCompile("needle"), []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 matches, got %v", items)
+	}
+	if items[0].Line != 1 || items[0].Col != 0 || items[0].Text != "needle here" {
+		t.Errorf("unexpected first match %+v", items[0])
+	}
+	if items[1].Filename != filepath.Join(dir, "sub", "b.txt") || items[1].Col != 4 {
+		t.Errorf("unexpected second match %+v", items[1])
+	}
+}
+
+func TestStack_SkipsEntriesWithoutLocation(t *testing.T) {
+	s := NewStack()
+	s.Set("make", []Item{
+		{Text: "building", NoLocation: true},
+		{Filename: "a.go", Line: 1},
+		{Text: "note", NoLocation: true},
+		{Filename: "b.go", Line: 2},
+	})
+
+	if item, err := s.Select(0); err != nil || item.Filename != "a.go" {
+		t.Fatalf("expected first located entry, got %v %v", item, err)
+	}
+	if item, err := s.Next(1); err != nil || item.Filename != "b.go" {
+		t.Errorf("expected next to skip output lines, got %v %v", item, err)
+	}
+	if _, err := s.Next(1); !errors.Is(err, ErrNoMoreItems) {
+		t.Errorf("expected ErrNoMoreItems, got %v", err)
+	}
+
+	s.Set("output", []Item{{Text: "ok", NoLocation: true}})
+	if _, err := s.Select(0); !errors.Is(err, ErrNoValidItems) {
+		t.Errorf("expected ErrNoValidItems, got %v", err)
+	}
+}
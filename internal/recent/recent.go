@@ -0,0 +1,87 @@
+// Package recent remembers the files opened in the editor between
+// sessions, most recent first, for :oldfiles and the start screen
+package recent
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// maxFiles is how many files are remembered
+const maxFiles = 100
+
+// Files is the list of recently opened files. A nil *Files remembers none.
+type Files struct {
+	mu    sync.Mutex
+	path  string   // File the list is saved to, or "" to keep it in memory
+	files []string // Absolute paths, most recent first
+}
+
+// Open loads the list saved in path, which need not exist yet. An empty
+// path keeps the list in memory only.
+func Open(path string) (*Files, error) {
+	f := &Files{path: path}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f.files); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// Add puts filename first in the list, removing an earlier entry for it
+// and the oldest beyond the most remembered, and saves the list
+func (f *Files) Add(filename string) error {
+	if f == nil || filename == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.files) > 0 && f.files[0] == abs {
+		return nil
+	}
+	f.files = slices.DeleteFunc(f.files, func(name string) bool { return name == abs })
+	f.files = slices.Insert(f.files, 0, abs)
+	if len(f.files) > maxFiles {
+		f.files = f.files[:maxFiles]
+	}
+	if f.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(f.files, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0644)
+}
+
+// List returns the files, most recently opened first
+func (f *Files) List() []string {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.files)
+}
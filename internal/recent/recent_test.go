@@ -0,0 +1,49 @@
+package recent
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "aied", "recent.json")
+	files, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open of a missing file: %v", err)
+	}
+
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	for _, name := range []string{a, b, a} {
+		if err := files.Add(name); err != nil {
+			t.Fatalf("Add(%q): %v", name, err)
+		}
+	}
+	if got := files.List(); !slices.Equal(got, []string{a, b}) {
+		t.Errorf("List() = %v, want %v", got, []string{a, b})
+	}
+
+	// The list is read back next session
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got := reopened.List(); !slices.Equal(got, []string{a, b}) {
+		t.Errorf("reopened List() = %v", got)
+	}
+
+	// Only the most recent are kept
+	for i := range maxFiles + 5 {
+		reopened.Add(filepath.Join(dir, fmt.Sprintf("%d.go", i)))
+	}
+	if got := reopened.List(); len(got) != maxFiles || got[0] != filepath.Join(dir, fmt.Sprintf("%d.go", maxFiles+4)) {
+		t.Errorf("List() after many adds has %d files starting %q", len(got), got[0])
+	}
+
+	var none *Files
+	if none.Add(a) != nil || none.List() != nil {
+		t.Error("a nil list should remember nothing")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package registers
+
+import "os"
+
+// lock is a no-op where files can't be locked with flock
+func lock(file *os.File) error {
+	return nil
+}
+
+// unlock is a no-op where files can't be locked with flock
+func unlock(file *os.File) error {
+	return nil
+}
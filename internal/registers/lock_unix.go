@@ -0,0 +1,19 @@
+//go:build unix
+
+package registers
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock takes an exclusive lock on file, waiting for other instances to
+// release theirs
+func lock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+// unlock releases the lock on file
+func unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
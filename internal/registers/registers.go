@@ -0,0 +1,314 @@
+package registers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// Unnamed is the register used when no register is given
+	Unnamed = '"'
+	// LastYank always holds the most recent yank
+	LastYank = '0'
+	// LastInsert holds the text typed in the last insert, and can only be read
+	LastInsert = '.'
+)
+
+// Register holds yanked or deleted text
+type Register struct {
+	Text     string    `json:"text"`
+	Linewise bool      `json:"linewise"`
+	Updated  time.Time `json:"updated"`
+	Private  bool      `json:"-"` // Kept in memory only, never written to the register file
+}
+
+// Store holds the editor registers and optionally persists them to a file
+type Store struct {
+	mu        sync.Mutex
+	registers map[rune]Register
+	path      string    // register file ("" disables persistence)
+	shared    bool      // reload and save the file on every access
+	loadedAt  time.Time // modification time of the file when last read
+	private   func() bool
+}
+
+// NewStore creates an in-memory register store
+func NewStore() *Store {
+	return &Store{registers: make(map[rune]Register)}
+}
+
+// Open creates a store persisted to path. When shared is set the file is
+// re-read before every access and written after every change so that
+// concurrently running editors see each other's registers.
+func Open(path string, shared bool) (*Store, error) {
+	s := NewStore()
+	s.path = path
+	s.shared = shared
+	if err := s.load(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// SetPrivate makes the registers filled while private reports true, such
+// as text yanked from an encrypted file, stay in memory instead of being
+// written to the register file
+func (s *Store) SetPrivate(private func() bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.private = private
+}
+
+// isPrivate reports whether text stored now must stay in memory
+func (s *Store) isPrivate() bool {
+	return s.private != nil && s.private()
+}
+
+// IsValid reports whether name is a register that can be read or written
+func IsValid(name rune) bool {
+	return name == Unnamed || (name >= '0' && name <= '9') || (name >= 'a' && name <= 'z') || (name >= 'A' && name <= 'Z')
+}
+
+// IsReadable reports whether name is a register that can be read: those
+// IsValid accepts and the last insert register
+func IsReadable(name rune) bool {
+	return IsValid(name) || name == LastInsert
+}
+
+// Get returns the content of a register
+func (s *Store) Get(name rune) (Register, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	reg, ok := s.registers[normalize(name)]
+	return reg, ok
+}
+
+// Yank records yanked text in the unnamed register, register 0 and the named register if given
+func (s *Store) Yank(name rune, text string, linewise bool) {
+	s.store(name, text, linewise, LastYank)
+}
+
+// Delete records deleted text in the unnamed register and the named register if given.
+// Linewise deletes also shift through the numbered registers 1-9.
+func (s *Store) Delete(name rune, text string, linewise bool) {
+	s.update(func() {
+		if !linewise {
+			s.storeLocked(name, text, linewise, 0)
+			return
+		}
+		for i := '9'; i > '1'; i-- {
+			if reg, ok := s.registers[i-1]; ok {
+				s.registers[i] = reg
+			}
+		}
+		s.storeLocked(name, text, linewise, '1')
+	})
+}
+
+// Inserted records text as the last inserted text, in register .
+func (s *Store) Inserted(text string) {
+	s.update(func() {
+		s.registers[LastInsert] = Register{Text: text, Updated: time.Now(), Private: s.isPrivate()}
+	})
+}
+
+// update changes the registers with change. In shared mode the register
+// file stays locked from reading the other instances' changes before it
+// to writing the result, so none of them is lost.
+func (s *Store) update(change func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shared {
+		defer s.lockFile()()
+		s.mergeFromDisk()
+	}
+	change()
+	if s.shared {
+		s.save()
+	}
+}
+
+// store writes text to the unnamed register, extra (if non-zero) and name
+func (s *Store) store(name rune, text string, linewise bool, extra rune) {
+	s.update(func() { s.storeLocked(name, text, linewise, extra) })
+}
+
+// storeLocked is store with s.mu held
+func (s *Store) storeLocked(name rune, text string, linewise bool, extra rune) {
+	reg := Register{Text: text, Linewise: linewise, Updated: time.Now(), Private: s.isPrivate()}
+	s.registers[Unnamed] = reg
+	if extra != 0 {
+		s.registers[extra] = reg
+	}
+
+	switch {
+	case name >= 'A' && name <= 'Z':
+		// Uppercase appends to the lowercase register
+		lower := normalize(name)
+		if existing, ok := s.registers[lower]; ok {
+			if existing.Linewise || linewise {
+				reg.Text = existing.Text + "\n" + text
+				reg.Linewise = true
+			} else {
+				reg.Text = existing.Text + text
+			}
+			reg.Private = reg.Private || existing.Private
+		}
+		s.registers[lower] = reg
+		s.registers[Unnamed] = reg
+	case name != 0 && name != Unnamed && IsValid(name):
+		s.registers[name] = reg
+	}
+}
+
+// Save writes the registers to the register file
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.lockFile()()
+	return s.save()
+}
+
+// lockFile locks the register file against other instances, through a
+// .lock file next to it, and returns the function that unlocks it. Where
+// it can't be locked the registers are saved without the lock.
+func (s *Store) lockFile() func() {
+	if s.path == "" {
+		return func() {}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return func() {}
+	}
+	file, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return func() {}
+	}
+	if err := lock(file); err != nil {
+		file.Close()
+		return func() {}
+	}
+	return func() {
+		unlock(file)
+		file.Close()
+	}
+}
+
+// save writes the registers atomically, merging with any newer entries on
+// disk. The register file must be locked.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	// Keep registers other instances changed since we last read the file
+	s.mergeFromDisk()
+
+	data := make(map[string]Register, len(s.registers))
+	for name, reg := range s.registers {
+		if !reg.Private {
+			data[string(name)] = reg
+		}
+	}
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode registers: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create register directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".registers-*")
+	if err != nil {
+		return fmt.Errorf("failed to write registers: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write registers: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write registers: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write registers: %w", err)
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.loadedAt = info.ModTime()
+	}
+	return nil
+}
+
+// load reads the register file, keeping the newer of each register
+func (s *Store) load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read registers: %w", err)
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read registers: %w", err)
+	}
+	var data map[string]Register
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("failed to parse registers %q: %w", s.path, err)
+	}
+
+	for key, reg := range data {
+		runes := []rune(key)
+		if len(runes) != 1 || !IsReadable(runes[0]) {
+			continue
+		}
+		if existing, ok := s.registers[runes[0]]; !ok || reg.Updated.After(existing.Updated) {
+			s.registers[runes[0]] = reg
+		}
+	}
+	s.loadedAt = info.ModTime()
+	return nil
+}
+
+// refresh reloads the register file in shared mode if another instance changed it
+func (s *Store) refresh() {
+	if s.shared {
+		s.mergeFromDisk()
+	}
+}
+
+// mergeFromDisk loads the register file if it changed since it was last read
+func (s *Store) mergeFromDisk() {
+	if s.path == "" {
+		return
+	}
+	info, err := os.Stat(s.path)
+	if err != nil || !info.ModTime().After(s.loadedAt) {
+		return
+	}
+	s.load()
+}
+
+// normalize maps uppercase register names to their lowercase register
+func normalize(name rune) rune {
+	if name >= 'A' && name <= 'Z' {
+		return name - 'A' + 'a'
+	}
+	if name == 0 {
+		return Unnamed
+	}
+	return name
+}
@@ -0,0 +1,142 @@
+package registers
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestYankAndDelete(t *testing.T) {
+	s := NewStore()
+
+	s.Yank('a', "hello", false)
+	if reg, _ := s.Get('a'); reg.Text != "hello" {
+		t.Errorf("expected register a to hold 'hello', got %q", reg.Text)
+	}
+	if reg, _ := s.Get(LastYank); reg.Text != "hello" {
+		t.Errorf("expected register 0 to hold last yank, got %q", reg.Text)
+	}
+
+	s.Delete(0, "line one", true)
+	s.Delete(0, "line two", true)
+	if reg, _ := s.Get(Unnamed); reg.Text != "line two" || !reg.Linewise {
+		t.Errorf("expected unnamed register to hold last delete, got %+v", reg)
+	}
+	if reg, _ := s.Get('2'); reg.Text != "line one" {
+		t.Errorf("expected deletes to shift into register 2, got %q", reg.Text)
+	}
+	if reg, _ := s.Get(LastYank); reg.Text != "hello" {
+		t.Errorf("expected deletes to leave register 0 alone, got %q", reg.Text)
+	}
+
+	s.Yank('A', " world", false)
+	if reg, _ := s.Get('a'); reg.Text != "hello world" {
+		t.Errorf("expected uppercase register to append, got %q", reg.Text)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registers.json")
+
+	s, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.Yank('q', "saved", false)
+	if err := s.Save(); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	reopened, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reg, ok := reopened.Get('q'); !ok || reg.Text != "saved" {
+		t.Errorf("expected register q to persist, got %+v", reg)
+	}
+}
+
+func TestSharedStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registers.json")
+
+	first, _ := Open(path, true)
+	second, _ := Open(path, true)
+
+	first.Yank('s', "from first", false)
+	if reg, ok := second.Get('s'); !ok || reg.Text != "from first" {
+		t.Errorf("expected shared register to be visible, got %+v", reg)
+	}
+}
+
+func TestPrivateRegisters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registers.json")
+	s, _ := Open(path, false)
+	private := true
+	s.SetPrivate(func() bool { return private })
+
+	s.Yank('a', "secret", false)
+	s.Inserted("typed secret")
+	private = false
+	s.Yank('b', "public", false)
+	s.Yank('A', " more", false) // Appending keeps a private
+	if reg, _ := s.Get('a'); reg.Text != "secret more" {
+		t.Errorf("private register a = %q", reg.Text)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, _ := Open(path, false)
+	if reg, ok := reopened.Get('a'); ok {
+		t.Errorf("private register a was saved: %+v", reg)
+	}
+	if reg, ok := reopened.Get(LastInsert); ok {
+		t.Errorf("private insert was saved: %+v", reg)
+	}
+	if reg, _ := reopened.Get('b'); reg.Text != "public" {
+		t.Errorf("register b = %q, want it saved", reg.Text)
+	}
+}
+
+func TestSharedDeletesShiftInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registers.json")
+	first, _ := Open(path, true)
+	second, _ := Open(path, true)
+
+	first.Delete(0, "one", true)
+	second.Delete(0, "two", true)
+	first.Delete(0, "three", true)
+
+	for _, s := range []*Store{first, second} {
+		for name, want := range map[rune]string{'1': "three", '2': "two", '3': "one"} {
+			if reg, _ := s.Get(name); reg.Text != want {
+				t.Errorf("register %c = %q, want %q", name, reg.Text, want)
+			}
+		}
+	}
+}
+
+func TestSharedStoresDontLoseWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registers.json")
+	first, _ := Open(path, true)
+	second, _ := Open(path, true)
+
+	var wg sync.WaitGroup
+	for i, s := range []*Store{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := 'a' + rune(i)*13; name < 'a'+rune(i+1)*13; name++ {
+				s.Yank(name, string(name), false)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reopened, _ := Open(path, false)
+	for name := 'a'; name <= 'z'; name++ {
+		if reg, ok := reopened.Get(name); !ok || reg.Text != string(name) {
+			t.Errorf("register %c = %+v, want it saved", name, reg)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package remote
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// controlPersist is how long an idle cached connection stays open
+const controlPersist = "10m"
+
+// Client transfers remote files with the system scp, which uses the SFTP
+// protocol on current OpenSSH. Connections are cached with ssh connection
+// sharing, so only the first transfer to a host pays for the handshake.
+// Authentication must not prompt (keys or an agent): the editor owns the
+// terminal, so ssh runs in batch mode.
+type Client struct {
+	mu         sync.Mutex
+	controlDir string              // Directory holding the connection sockets
+	hosts      map[string]Location // Hosts with a cached connection
+	run        func(name string, args ...string) ([]byte, error)
+}
+
+// NewClient creates a remote file client
+func NewClient() *Client {
+	return &Client{
+		hosts: make(map[string]Location),
+		run: func(name string, args ...string) ([]byte, error) {
+			return exec.Command(name, args...).CombinedOutput()
+		},
+	}
+}
+
+// Handles reports whether filename is a remote URL
+func (c *Client) Handles(filename string) bool {
+	return IsURL(filename)
+}
+
+// Indicator labels remote buffers in the status line with their host
+func (c *Client) Indicator(filename string) string {
+	loc, err := Parse(filename)
+	if err != nil {
+		return "[remote]"
+	}
+	return "[" + loc.Host + "]"
+}
+
+// ReadFile copies a remote file to a local temporary file and returns its content
+func (c *Client) ReadFile(filename string) ([]byte, error) {
+	loc, err := Parse(filename)
+	if err != nil {
+		return nil, err
+	}
+	local, err := tempFile()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(local)
+
+	if err := c.copy(loc, loc.Target(), local); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(local)
+}
+
+// WriteFile writes data to a local temporary file and copies it to the remote host
+func (c *Client) WriteFile(filename string, data []byte) error {
+	loc, err := Parse(filename)
+	if err != nil {
+		return err
+	}
+	local, err := tempFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local)
+
+	if err := os.WriteFile(local, data, 0600); err != nil {
+		return err
+	}
+	return c.copy(loc, local, loc.Target())
+}
+
+// Close closes the cached connections and removes their sockets
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.controlDir == "" {
+		return
+	}
+	for _, loc := range c.hosts {
+		args := append(c.sshOptions(), "-O", "exit")
+		if loc.Port != "" {
+			args = append(args, "-p", loc.Port)
+		}
+		c.run("ssh", append(args, loc.Destination())...)
+	}
+	c.hosts = make(map[string]Location)
+	os.RemoveAll(c.controlDir)
+	c.controlDir = ""
+}
+
+// copy runs scp from src to dst over the cached connection to loc's host
+func (c *Client) copy(loc Location, src, dst string) error {
+	c.mu.Lock()
+	if err := c.ensureControlDir(); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	args := append([]string{"-q"}, c.sshOptions()...)
+	c.hosts[loc.Destination()+":"+loc.Port] = loc
+	c.mu.Unlock()
+
+	if loc.Port != "" {
+		args = append(args, "-P", loc.Port)
+	}
+	args = append(args, "--", src, dst)
+
+	if output, err := c.run("scp", args...); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("scp: %s", msg)
+		}
+		return fmt.Errorf("scp: %w", err)
+	}
+	return nil
+}
+
+// sshOptions returns the options enabling batch mode and connection sharing
+func (c *Client) sshOptions() []string {
+	return []string{
+		"-o", "BatchMode=yes",
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(c.controlDir, "%C"),
+		"-o", "ControlPersist=" + controlPersist,
+	}
+}
+
+// ensureControlDir creates the private socket directory on first use
+func (c *Client) ensureControlDir() error {
+	if c.controlDir != "" {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "aied-ssh-")
+	if err != nil {
+		return fmt.Errorf("failed to create ssh control directory: %w", err)
+	}
+	c.controlDir = dir
+	return nil
+}
+
+// tempFile creates an empty private local file for a transfer
+func tempFile() (string, error) {
+	file, err := os.CreateTemp("", "aied-remote-")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	return file.Name(), nil
+}
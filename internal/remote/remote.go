@@ -0,0 +1,79 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schemes of the URLs handled as remote files
+var schemes = []string{"ssh://", "scp://"}
+
+// Location is a file on a remote host
+type Location struct {
+	User string // Login name, empty for the ssh default
+	Host string
+	Port string // Empty for the ssh default
+	Path string // Absolute, or relative to the remote home directory
+}
+
+// IsURL reports whether name is a remote file URL
+func IsURL(name string) bool {
+	for _, scheme := range schemes {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses an ssh://[user@]host[:port]/path or scp:// URL. As in Vim's
+// netrw, a single slash after the host starts a path relative to the remote
+// home directory and a double slash an absolute one:
+// scp://host/notes.txt is ~/notes.txt and scp://host//etc/hosts is /etc/hosts.
+func Parse(url string) (Location, error) {
+	rest := ""
+	for _, scheme := range schemes {
+		if strings.HasPrefix(url, scheme) {
+			rest = url[len(scheme):]
+			break
+		}
+	}
+	if rest == "" {
+		return Location{}, fmt.Errorf("not a remote URL: %q", url)
+	}
+
+	authority, path, found := strings.Cut(rest, "/")
+	if !found || path == "" {
+		return Location{}, fmt.Errorf("missing path in %q", url)
+	}
+
+	var loc Location
+	if user, host, ok := strings.Cut(authority, "@"); ok {
+		loc.User = user
+		authority = host
+	}
+	if host, port, ok := strings.Cut(authority, ":"); ok {
+		loc.Host = host
+		loc.Port = port
+	} else {
+		loc.Host = authority
+	}
+	if loc.Host == "" {
+		return Location{}, fmt.Errorf("missing host in %q", url)
+	}
+	loc.Path = path
+	return loc, nil
+}
+
+// Destination returns the ssh destination, [user@]host
+func (l Location) Destination() string {
+	if l.User != "" {
+		return l.User + "@" + l.Host
+	}
+	return l.Host
+}
+
+// Target returns the scp argument naming the file, [user@]host:path
+func (l Location) Target() string {
+	return l.Destination() + ":" + l.Path
+}
@@ -0,0 +1,145 @@
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    Location
+		wantErr bool
+	}{
+		{"scp://host/notes.txt", Location{Host: "host", Path: "notes.txt"}, false},
+		{"scp://host//etc/hosts", Location{Host: "host", Path: "/etc/hosts"}, false},
+		{"ssh://alice@example.com:2222/src/main.go", Location{User: "alice", Host: "example.com", Port: "2222", Path: "src/main.go"}, false},
+		{"ssh://host", Location{}, true},
+		{"ssh://host/", Location{}, true},
+		{"scp:///path", Location{}, true},
+		{"/local/file", Location{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestLocation_Target(t *testing.T) {
+	loc := Location{User: "alice", Host: "host", Path: "/etc/hosts"}
+	if got := loc.Target(); got != "alice@host:/etc/hosts" {
+		t.Errorf("Target() = %q", got)
+	}
+	loc.User = ""
+	if got := loc.Destination(); got != "host" {
+		t.Errorf("Destination() = %q", got)
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	for name, want := range map[string]bool{
+		"ssh://host/file": true,
+		"scp://host/file": true,
+		"file.txt":        false,
+		"http://host/x":   false,
+	} {
+		if got := IsURL(name); got != want {
+			t.Errorf("IsURL(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// fakeScp returns a command runner that copies files like scp, mapping
+// host:path targets into dir
+func fakeScp(dir string, calls *[][]string) func(string, ...string) ([]byte, error) {
+	resolve := func(arg string) string {
+		if _, path, ok := strings.Cut(arg, ":"); ok && !filepath.IsAbs(arg) {
+			return filepath.Join(dir, path)
+		}
+		return arg
+	}
+	return func(name string, args ...string) ([]byte, error) {
+		*calls = append(*calls, append([]string{name}, args...))
+		if name != "scp" {
+			return nil, nil
+		}
+		src, dst := resolve(args[len(args)-2]), resolve(args[len(args)-1])
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return []byte("scp: " + src + ": No such file or directory\n"), err
+		}
+		return nil, os.WriteFile(dst, data, 0644)
+	}
+}
+
+func TestClient_ReadWrite(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("remote\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls [][]string
+	client := NewClient()
+	client.run = fakeScp(dir, &calls)
+	defer client.Close()
+
+	data, err := client.ReadFile("scp://host:2222/notes.txt")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "remote\n" {
+		t.Errorf("ReadFile = %q", data)
+	}
+
+	if err := client.WriteFile("scp://host:2222/notes.txt", []byte("edited\n")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "notes.txt"))
+	if string(data) != "edited\n" {
+		t.Errorf("remote content = %q after write", data)
+	}
+
+	args := strings.Join(calls[0], " ")
+	for _, want := range []string{"BatchMode=yes", "ControlMaster=auto", "-P 2222", "host:notes.txt"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("scp arguments %q missing %q", args, want)
+		}
+	}
+
+	if _, err := client.ReadFile("scp://host/missing.txt"); err == nil || !strings.Contains(err.Error(), "No such file") {
+		t.Errorf("ReadFile of a missing file returned %v", err)
+	}
+}
+
+func TestClient_Close(t *testing.T) {
+	var calls [][]string
+	client := NewClient()
+	client.run = fakeScp(t.TempDir(), &calls)
+
+	// Closing an unused client runs nothing
+	client.Close()
+	if len(calls) != 0 {
+		t.Fatalf("Close of an unused client ran %v", calls)
+	}
+
+	client.ReadFile("scp://host/missing.txt")
+	controlDir := client.controlDir
+	client.Close()
+
+	last := strings.Join(calls[len(calls)-1], " ")
+	if !strings.HasPrefix(last, "ssh ") || !strings.Contains(last, "-O exit") {
+		t.Errorf("Close ran %q, want ssh -O exit", last)
+	}
+	if _, err := os.Stat(controlDir); !os.IsNotExist(err) {
+		t.Errorf("control directory %s was not removed", controlDir)
+	}
+}
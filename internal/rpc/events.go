@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"go.lsp.dev/jsonrpc2"
+)
+
+// The events clients may subscribe to
+const (
+	EventOpened  = "opened"  // Another file was opened
+	EventChanged = "changed" // The buffer's text changed
+	EventWritten = "written" // The buffer was written
+	EventMode    = "mode"    // The mode changed
+	EventCursor  = "cursor"  // The cursor moved
+)
+
+var eventNames = []string{EventOpened, EventChanged, EventWritten, EventMode, EventCursor}
+
+// Event is sent to subscribers as an "event" notification
+type Event struct {
+	Event string `json:"event"`
+	State State  `json:"state"`
+}
+
+// subscriber is a client connection and the events it asked for
+type subscriber struct {
+	conn       jsonrpc2.Conn
+	events     chan Event  // Waiting to be sent
+	wanted     []string    // Nil until it subscribes
+	authorized atomic.Bool // Whether it sent the server's token
+}
+
+// subscribe sends sub the events named, or all of them when none are
+func (s *Server) subscribe(sub *subscriber, events []string) error {
+	for _, name := range events {
+		if !slices.Contains(eventNames, name) {
+			return jsonrpc2.NewError(jsonrpc2.InvalidParams, fmt.Sprintf("%v: %s", errUnknownEvent, name))
+		}
+	}
+	if len(events) == 0 {
+		events = eventNames
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub.wanted = events
+	if !s.subscribers[sub] {
+		s.subscribers[sub] = true
+		go sub.send()
+	}
+	return nil
+}
+
+// unsubscribe stops sending sub events
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers[sub] {
+		delete(s.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// send writes the events of sub to its connection until it unsubscribes
+func (sub *subscriber) send() {
+	for ev := range sub.events {
+		sub.conn.Notify(context.Background(), "event", ev)
+	}
+}
+
+// Update tells subscribers how state and the buffer's text changed since
+// the last update. The editor calls it after handling each event; text is
+// only called while someone is subscribed.
+func (s *Server) Update(state State, text func() string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.subscribers) == 0 {
+		s.started = false
+		return
+	}
+	current := text()
+	if !s.started {
+		// Nothing to compare with until the first update
+		s.last, s.lastText, s.started = state, current, true
+		return
+	}
+
+	var events []string
+	switch {
+	case state.Filename != s.last.Filename:
+		events = append(events, EventOpened)
+	case current != s.lastText:
+		events = append(events, EventChanged)
+	}
+	if state.Filename == s.last.Filename && s.last.Modified && !state.Modified && current == s.lastText {
+		events = append(events, EventWritten)
+	}
+	if state.Mode != s.last.Mode {
+		events = append(events, EventMode)
+	}
+	if state.Line != s.last.Line || state.Column != s.last.Column {
+		events = append(events, EventCursor)
+	}
+	s.last, s.lastText = state, current
+
+	for _, name := range events {
+		for sub := range s.subscribers {
+			if !slices.Contains(sub.wanted, name) {
+				continue
+			}
+			select {
+			case sub.events <- Event{Event: name, State: state}:
+			default:
+				// A client that doesn't read its events loses them
+				// rather than holding up the editor
+			}
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Package rpc lets other programs drive the editor over a socket, like
+// nvim --listen: JSON-RPC 2.0 methods open files, get and set the buffer,
+// run ex commands, type keys and subscribe to the editor's events.
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"go.lsp.dev/jsonrpc2"
+
+	"github.com/dshills/aied/internal/ui"
+)
+
+// State is what a client sees of the editor
+type State struct {
+	Filename  string `json:"filename"`
+	Line      int    `json:"line"`   // 0-based cursor line
+	Column    int    `json:"column"` // 0-based cursor column
+	Mode      string `json:"mode"`
+	Modified  bool   `json:"modified"`
+	LineCount int    `json:"lineCount"`
+}
+
+// Editor is the editor the server drives. Its methods are only called on
+// the goroutine the server's post function runs them on.
+type Editor interface {
+	Open(filename string) error
+	Lines() []string
+	SetLines(lines []string) error
+	Execute(command string) (string, error) // Returns the command's message
+	Input(keys []ui.KeyEvent)
+	State() State
+}
+
+// Server answers JSON-RPC requests on the connections of a listener
+type Server struct {
+	editor   Editor
+	post     func(func()) // Runs a function on the editor's goroutine
+	listener net.Listener
+	token    string // Clients send it with auth before anything else, when set
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+	last        State
+	lastText    string
+	started     bool
+}
+
+// NewServer creates a server driving editor, calling it only through post,
+// such as ui.UI.Call
+func NewServer(editor Editor, post func(func())) *Server {
+	return &Server{editor: editor, post: post, subscribers: make(map[*subscriber]bool)}
+}
+
+// Listen accepts connections on addr in the background: a Unix socket
+// path, which only the user can connect to, or host:port for TCP. As any
+// client can run commands, and so shell commands, TCP is only listened on
+// loopback addresses and clients must first send the token with auth; the
+// token is the one set with SetToken or else a random one.
+func (s *Server) Listen(addr string) error {
+	var listener net.Listener
+	var err error
+	if strings.Contains(addr, "/") || !strings.Contains(addr, ":") {
+		listener, err = listenUnix(addr)
+	} else {
+		listener, err = s.listenTCP(addr)
+	}
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	s.listener = listener
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.ServeConn(conn)
+		}
+	}()
+	return nil
+}
+
+// listenUnix listens on the Unix socket path, readable and writable only
+// by the user
+func listenUnix(path string) (net.Listener, error) {
+	// A socket left by an editor that didn't exit cleanly
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// listenTCP listens on the loopback address addr, picking a token when
+// none is set
+func (s *Server) listenTCP(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !isLoopback(host) {
+		return nil, fmt.Errorf("%s isn't a loopback address, TCP is only listened on localhost", host)
+	}
+	if s.token == "" {
+		key := make([]byte, 16)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		s.token = hex.EncodeToString(key)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// isLoopback reports whether host only reaches this machine
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// SetToken sets the token clients must send with auth before any other
+// method; without one only TCP listeners need it
+func (s *Server) SetToken(token string) {
+	s.token = token
+}
+
+// Token returns the token clients send with auth, empty when they don't
+// need one
+func (s *Server) Token() string {
+	return s.token
+}
+
+// Addr returns the address listened on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops listening, removing a Unix socket
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// ServeConn answers the requests of one client in the background
+func (s *Server) ServeConn(nc net.Conn) {
+	conn := jsonrpc2.NewConn(jsonrpc2.NewRawStream(nc))
+	sub := &subscriber{conn: conn, events: make(chan Event, 64)}
+	conn.Go(context.Background(), func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		return s.handle(ctx, sub, reply, req)
+	})
+	go func() {
+		<-conn.Done()
+		s.unsubscribe(sub)
+	}()
+}
+
+// call runs fn on the editor's goroutine and waits for it
+func (s *Server) call(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	s.post(func() {
+		defer close(done)
+		fn()
+	})
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Params of the methods
+type (
+	openParams struct {
+		Filename string `json:"filename"`
+	}
+	linesParams struct {
+		Lines []string `json:"lines"`
+	}
+	commandParams struct {
+		Command string `json:"command"`
+	}
+	inputParams struct {
+		Keys string `json:"keys"`
+	}
+	authParams struct {
+		Token string `json:"token"`
+	}
+	subscribeParams struct {
+		Events []string `json:"events"` // All of them when empty
+	}
+)
+
+// commandResult is the reply of the command method
+type commandResult struct {
+	Message string `json:"message"`
+}
+
+// errUnauthorized answers the requests of a client that hasn't sent the
+// token
+var errUnauthorized = jsonrpc2.NewError(jsonrpc2.InvalidRequest, "send the token with auth first")
+
+// handle answers one request
+func (s *Server) handle(ctx context.Context, sub *subscriber, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	if s.token != "" && !sub.authorized.Load() && req.Method() != "auth" {
+		return reply(ctx, nil, errUnauthorized)
+	}
+
+	var err error
+	var result any
+	switch req.Method() {
+	case "auth":
+		var p authParams
+		if err = decode(req, &p); err == nil {
+			if subtle.ConstantTimeCompare([]byte(p.Token), []byte(s.token)) != 1 {
+				err = jsonrpc2.NewError(jsonrpc2.InvalidParams, "wrong token")
+			} else {
+				sub.authorized.Store(true)
+			}
+		}
+	case "open":
+		var p openParams
+		if err = decode(req, &p); err == nil {
+			err = s.callErr(ctx, func() error { return s.editor.Open(p.Filename) })
+		}
+	case "getLines":
+		var lines []string
+		err = s.call(ctx, func() { lines = s.editor.Lines() })
+		result = linesParams{Lines: lines}
+	case "setLines":
+		var p linesParams
+		if err = decode(req, &p); err == nil {
+			err = s.callErr(ctx, func() error { return s.editor.SetLines(p.Lines) })
+		}
+	case "command":
+		var p commandParams
+		var message string
+		if err = decode(req, &p); err == nil {
+			err = s.callErr(ctx, func() error {
+				var err error
+				message, err = s.editor.Execute(p.Command)
+				return err
+			})
+		}
+		result = commandResult{Message: message}
+	case "input":
+		var p inputParams
+		if err = decode(req, &p); err == nil {
+			var keys []ui.KeyEvent
+			if keys, err = ui.ParseKeys(p.Keys); err != nil {
+				err = jsonrpc2.NewError(jsonrpc2.InvalidParams, err.Error())
+			} else {
+				err = s.call(ctx, func() { s.editor.Input(keys) })
+			}
+		}
+	case "state":
+		var state State
+		err = s.call(ctx, func() { state = s.editor.State() })
+		result = state
+	case "subscribe":
+		var p subscribeParams
+		if err = decode(req, &p); err == nil {
+			err = s.subscribe(sub, p.Events)
+		}
+	case "unsubscribe":
+		s.unsubscribe(sub)
+	default:
+		return jsonrpc2.MethodNotFoundHandler(ctx, reply, req)
+	}
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, result, nil)
+}
+
+// callErr runs fn on the editor's goroutine, returning its error
+func (s *Server) callErr(ctx context.Context, fn func() error) error {
+	var err error
+	if callErr := s.call(ctx, func() { err = fn() }); callErr != nil {
+		return callErr
+	}
+	return err
+}
+
+// decode reads the params of req into v, which may be left out
+func decode(req jsonrpc2.Request, v any) error {
+	params := req.Params()
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return jsonrpc2.NewError(jsonrpc2.InvalidParams, err.Error())
+	}
+	return nil
+}
+
+// errUnknownEvent is returned subscribing to an event that doesn't exist
+var errUnknownEvent = errors.New("unknown event")
@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+
+	"github.com/dshills/aied/internal/ui"
+)
+
+// fakeEditor records what the server asks of it
+type fakeEditor struct {
+	state State
+	lines []string
+	keys  []ui.KeyEvent
+}
+
+func (e *fakeEditor) Open(filename string) error {
+	if filename == "missing" {
+		return errors.New("no such file")
+	}
+	e.state.Filename = filename
+	return nil
+}
+
+func (e *fakeEditor) Lines() []string { return e.lines }
+
+func (e *fakeEditor) SetLines(lines []string) error {
+	e.lines = lines
+	return nil
+}
+
+func (e *fakeEditor) Execute(command string) (string, error) {
+	if command != "w" {
+		return "", errors.New("Unknown command: " + command)
+	}
+	return "written", nil
+}
+
+func (e *fakeEditor) Input(keys []ui.KeyEvent) { e.keys = append(e.keys, keys...) }
+
+func (e *fakeEditor) State() State { return e.state }
+
+// connect serves one client of a server driving editor, returning the
+// client's connection and the events it is sent
+func connect(t *testing.T, editor Editor) (*Server, jsonrpc2.Conn, chan Event) {
+	t.Helper()
+	server := NewServer(editor, func(fn func()) { go fn() })
+	serverEnd, clientEnd := net.Pipe()
+	server.ServeConn(serverEnd)
+
+	events := make(chan Event, 10)
+	client := jsonrpc2.NewConn(jsonrpc2.NewRawStream(clientEnd))
+	client.Go(context.Background(), func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		var ev Event
+		json.Unmarshal(req.Params(), &ev)
+		events <- ev
+		return reply(ctx, nil, nil)
+	})
+	t.Cleanup(func() { client.Close() })
+	return server, client, events
+}
+
+func TestServerMethods(t *testing.T) {
+	editor := &fakeEditor{lines: []string{"one"}}
+	_, client, _ := connect(t, editor)
+	ctx := context.Background()
+
+	if _, err := client.Call(ctx, "open", openParams{Filename: "main.go"}, nil); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := client.Call(ctx, "open", openParams{Filename: "missing"}, nil); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("open of a missing file: %v", err)
+	}
+
+	if _, err := client.Call(ctx, "setLines", linesParams{Lines: []string{"a", "b"}}, nil); err != nil {
+		t.Fatalf("setLines: %v", err)
+	}
+	var lines linesParams
+	if _, err := client.Call(ctx, "getLines", nil, &lines); err != nil || strings.Join(lines.Lines, ",") != "a,b" {
+		t.Errorf("getLines = %v, %v", lines.Lines, err)
+	}
+
+	var result commandResult
+	if _, err := client.Call(ctx, "command", commandParams{Command: "w"}, &result); err != nil || result.Message != "written" {
+		t.Errorf("command = %q, %v", result.Message, err)
+	}
+	if _, err := client.Call(ctx, "command", commandParams{Command: "nope"}, nil); err == nil {
+		t.Error("expected a failing command to fail")
+	}
+
+	if _, err := client.Call(ctx, "input", inputParams{Keys: "ix<Esc>"}, nil); err != nil || len(editor.keys) != 3 {
+		t.Errorf("input gave %d keys, %v", len(editor.keys), err)
+	}
+	if _, err := client.Call(ctx, "input", inputParams{Keys: "<Bogus>"}, nil); err == nil {
+		t.Error("expected an unknown key to fail")
+	}
+
+	var state State
+	if _, err := client.Call(ctx, "state", nil, &state); err != nil || state.Filename != "main.go" {
+		t.Errorf("state = %+v, %v", state, err)
+	}
+
+	if _, err := client.Call(ctx, "nvim_command", nil, nil); err == nil {
+		t.Error("expected an unknown method to fail")
+	}
+}
+
+func TestServerEvents(t *testing.T) {
+	server, client, events := connect(t, &fakeEditor{})
+	ctx := context.Background()
+
+	if _, err := client.Call(ctx, "subscribe", subscribeParams{Events: []string{"bogus"}}, nil); err == nil {
+		t.Error("expected an unknown event to fail")
+	}
+	if _, err := client.Call(ctx, "subscribe", subscribeParams{Events: []string{EventChanged, EventWritten, EventMode}}, nil); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	text := "a"
+	update := func(state State) { server.Update(state, func() string { return text }) }
+	update(State{Filename: "f", Mode: "NORMAL"})
+	text = "ab"
+	update(State{Filename: "f", Mode: "INSERT", Modified: true, Column: 1})
+	update(State{Filename: "f", Mode: "NORMAL", Column: 1})
+
+	var got []string
+	for range 4 {
+		select {
+		case ev := <-events:
+			got = append(got, ev.Event)
+		case <-time.After(time.Second):
+			t.Fatalf("got events %v, expected 4", got)
+		}
+	}
+	// The cursor moved too, but that wasn't subscribed to
+	if strings.Join(got, ",") != "changed,mode,written,mode" {
+		t.Errorf("events = %v", got)
+	}
+}
+
+func TestServerListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aied.sock")
+	server := NewServer(&fakeEditor{}, func(fn func()) { go fn() })
+	if err := server.Listen(path); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer server.Close()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	if server.Token() != "" {
+		t.Errorf("a Unix socket asks for token %q", server.Token())
+	}
+}
+
+func TestServerListenTCP(t *testing.T) {
+	server := NewServer(&fakeEditor{}, func(fn func()) { go fn() })
+	if err := server.Listen("0.0.0.0:0"); err == nil {
+		server.Close()
+		t.Fatal("listened on all addresses")
+	}
+	if err := server.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer server.Close()
+	if len(server.Token()) != 32 {
+		t.Fatalf("token = %q, want a random one", server.Token())
+	}
+
+	nc, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	client := jsonrpc2.NewConn(jsonrpc2.NewRawStream(nc))
+	client.Go(context.Background(), jsonrpc2.MethodNotFoundHandler)
+	defer client.Close()
+	ctx := context.Background()
+
+	// Nothing runs before the token is sent
+	if _, err := client.Call(ctx, "command", commandParams{Command: "w"}, nil); err == nil || !strings.Contains(err.Error(), "auth") {
+		t.Errorf("command before auth: %v", err)
+	}
+	if _, err := client.Call(ctx, "auth", authParams{Token: "guess"}, nil); err == nil {
+		t.Error("a wrong token was accepted")
+	}
+	if _, err := client.Call(ctx, "command", commandParams{Command: "w"}, nil); err == nil {
+		t.Error("command ran after a wrong token")
+	}
+	if _, err := client.Call(ctx, "auth", authParams{Token: server.Token()}, nil); err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	var result commandResult
+	if _, err := client.Call(ctx, "command", commandParams{Command: "w"}, &result); err != nil || result.Message != "written" {
+		t.Errorf("command after auth = %q, %v", result.Message, err)
+	}
+}
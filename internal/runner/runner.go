@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Job is a shell command running in the background whose combined
+// stdout and stderr is collected line by line
+type Job struct {
+	Command string
+
+	mu       sync.Mutex
+	output   []string
+	done     bool
+	exitCode int
+	err      error
+	cancel   context.CancelFunc
+	drained  chan struct{} // closed once all output has been read
+	exited   chan struct{} // closed once the command has finished
+}
+
+// Start runs command through the shell in dir. onOutput, if not nil, is
+// called from the job's goroutine whenever output arrives or the job ends.
+func Start(command, dir string, onOutput func()) (*Job, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = dir
+	// Don't hang on output pipes held open by children of a cancelled command
+	cmd.WaitDelay = 2 * time.Second
+
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	job := &Job{Command: command, cancel: cancel, drained: make(chan struct{}), exited: make(chan struct{})}
+	notify := func() {
+		if onOutput != nil {
+			onOutput()
+		}
+	}
+
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		<-job.drained
+
+		job.mu.Lock()
+		job.done = true
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			job.exitCode = exitErr.ExitCode()
+		} else if err != nil {
+			job.err = err
+			job.exitCode = -1
+		}
+		job.mu.Unlock()
+		close(job.exited)
+		cancel()
+		notify()
+	}()
+
+	go func() {
+		defer close(job.drained)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			job.mu.Lock()
+			job.output = append(job.output, scanner.Text())
+			job.mu.Unlock()
+			notify()
+		}
+		// Drain anything left if a line was too long to scan
+		io.Copy(io.Discard, reader)
+	}()
+
+	return job, nil
+}
+
+// shellCommand runs command through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// Output returns a copy of the output lines collected so far
+func (j *Job) Output() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	result := make([]string, len(j.output))
+	copy(result, j.output)
+	return result
+}
+
+// Done reports whether the command has finished
+func (j *Job) Done() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done
+}
+
+// Result returns the exit code and any error starting or waiting for the
+// command. It is only meaningful once Done reports true.
+func (j *Job) Result() (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.exitCode, j.err
+}
+
+// Wait blocks until the command has finished
+func (j *Job) Wait() {
+	<-j.exited
+}
+
+// Cancel kills the command
+func (j *Job) Cancel() {
+	j.cancel()
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"testing"
+)
+
+func TestStart_CollectsOutput(t *testing.T) {
+	notified := make(chan struct{}, 100)
+	job, err := Start("echo one; echo two >&2; exit 3", "", func() {
+		select {
+		case notified <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Wait()
+
+	if !job.Done() {
+		t.Fatal("expected job to be done after Wait")
+	}
+	output := job.Output()
+	if len(output) != 2 || output[0] != "one" || output[1] != "two" {
+		t.Errorf("expected stdout and stderr lines, got %q", output)
+	}
+	if code, err := job.Result(); code != 3 || err != nil {
+		t.Errorf("expected exit 3, got %d %v", code, err)
+	}
+	if len(notified) == 0 {
+		t.Error("expected output notifications")
+	}
+}
+
+func TestStart_Cancel(t *testing.T) {
+	job, err := Start("exec sleep 10", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Cancel()
+	job.Wait()
+	if code, _ := job.Result(); code == 0 {
+		t.Error("expected a cancelled job to report failure")
+	}
+}
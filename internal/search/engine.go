@@ -0,0 +1,204 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Engine names the regular expression engine patterns are compiled with
+type Engine string
+
+const (
+	// EngineGo is Go's regexp package: RE2 syntax, linear time, no
+	// backreferences or lookarounds
+	EngineGo Engine = "go"
+	// EngineRegexp2 is a backtracking engine with .NET/Perl syntax, adding
+	// lookahead, lookbehind and backreferences like \1 in the pattern
+	EngineRegexp2 Engine = "regexp2"
+)
+
+// Engines lists the engines in the order :set regexpengine? offers them
+var Engines = []Engine{EngineGo, EngineRegexp2}
+
+// matchTimeout stops a backtracking pattern that would take forever, like
+// (a+)+b against a long line of a's, from hanging the editor
+const matchTimeout = time.Second
+
+// ParseEngine returns the engine called name; "" is the Go engine
+func ParseEngine(name string) (Engine, error) {
+	if name == "" {
+		return EngineGo, nil
+	}
+	for _, e := range Engines {
+		if string(e) == name {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("unknown regexp engine %q (want go or regexp2)", name)
+}
+
+// Regexp is a compiled pattern. *regexp.Regexp implements it, and so does
+// the wrapper around the regexp2 engine, with byte offsets like Go's.
+type Regexp interface {
+	String() string
+	MatchString(s string) bool
+	FindAllStringIndex(s string, n int) [][]int
+	FindAllStringSubmatchIndex(s string, n int) [][]int
+	// ExpandString appends template to dst with $1, ${1} and ${name}
+	// replaced by the groups of match, like regexp.Regexp.ExpandString
+	ExpandString(dst []byte, template string, src string, match []int) []byte
+}
+
+// compile compiles expr with engine
+func compile(engine Engine, expr string, ignoreCase bool) (Regexp, error) {
+	if engine == EngineRegexp2 {
+		var opts regexp2.RegexOptions = regexp2.Multiline
+		if ignoreCase {
+			opts |= regexp2.IgnoreCase
+		}
+		re, err := regexp2.Compile(expr, opts)
+		if err != nil {
+			return nil, err
+		}
+		re.MatchTimeout = matchTimeout
+		return &backtracking{re: re}, nil
+	}
+
+	var flags string
+	if Multiline(expr) {
+		// ^ and $ match at the line breaks of the text
+		flags += "m"
+	}
+	if ignoreCase {
+		flags += "i"
+	}
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// backtracking adapts a regexp2 pattern to Regexp. regexp2 reports
+// positions in runes, so they are converted to byte offsets.
+type backtracking struct {
+	re *regexp2.Regexp
+}
+
+func (b *backtracking) String() string {
+	return b.re.String()
+}
+
+func (b *backtracking) MatchString(s string) bool {
+	// A match that times out counts as no match
+	ok, _ := b.re.MatchString(s)
+	return ok
+}
+
+func (b *backtracking) FindAllStringIndex(s string, n int) [][]int {
+	matches := b.FindAllStringSubmatchIndex(s, n)
+	for i, m := range matches {
+		matches[i] = m[:2]
+	}
+	return matches
+}
+
+func (b *backtracking) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	var offsets []int // Byte offset of each rune, and of the end
+	for i := range s {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(s))
+
+	var matches [][]int
+	m, _ := b.re.FindStringMatch(s)
+	for m != nil && (n < 0 || len(matches) < n) {
+		groups := m.Groups()
+		loc := make([]int, 2*len(groups))
+		for i, g := range groups {
+			if len(g.Captures) == 0 {
+				loc[2*i], loc[2*i+1] = -1, -1
+				continue
+			}
+			loc[2*i], loc[2*i+1] = offsets[g.Index], offsets[g.Index+g.Length]
+		}
+		matches = append(matches, loc)
+		m, _ = b.re.FindNextMatch(m)
+	}
+	return matches
+}
+
+func (b *backtracking) ExpandString(dst []byte, template string, src string, match []int) []byte {
+	for {
+		before, after, found := strings.Cut(template, "$")
+		dst = append(dst, before...)
+		if !found {
+			return dst
+		}
+		if strings.HasPrefix(after, "$") {
+			dst = append(dst, '$')
+			template = after[1:]
+			continue
+		}
+		name, rest, ok := groupName(after)
+		if !ok {
+			dst = append(dst, '$')
+			template = after
+			continue
+		}
+		template = rest
+		if slot := b.slot(name); slot >= 0 && 2*slot+1 < len(match) && match[2*slot] >= 0 {
+			dst = append(dst, src[match[2*slot]:match[2*slot+1]]...)
+		}
+	}
+}
+
+// slot returns the position among the match's groups of the group called
+// name, which may be its number, or -1
+func (b *backtracking) slot(name string) int {
+	number, err := strconv.Atoi(name)
+	if err != nil {
+		number = b.re.GroupNumberFromName(name)
+	}
+	if number < 0 {
+		return -1
+	}
+	for i, n := range b.re.GetGroupNumbers() {
+		if n == number {
+			return i
+		}
+	}
+	return -1
+}
+
+// groupName cuts the name after a $ in a template: ${name} or the longest
+// run of letters, digits and underscores
+func groupName(s string) (string, string, bool) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 2 {
+			return "", s, false
+		}
+		return s[1:end], s[end+1:], true
+	}
+	end := 0
+	for end < len(s) && (s[end] == '_' || isAlnum(s[end])) {
+		end++
+	}
+	if end == 0 {
+		return "", s, false
+	}
+	return s[:end], s[end:], true
+}
+
+func isAlnum(ch byte) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
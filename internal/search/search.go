@@ -0,0 +1,214 @@
+// Package search compiles the patterns of /, ?, :s, :g and range
+// addresses with the editor's case options and regexp engine, and
+// remembers the last pattern searched for n, N and highlighting.
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// Options are the settings patterns are matched with
+type Options struct {
+	IgnoreCase bool   // Letters match either case
+	SmartCase  bool   // With IgnoreCase, a pattern with an upper case letter matches case
+	HLSearch   bool   // Highlight every match of the last pattern
+	Engine     Engine // Regexp engine; "" is the Go engine
+}
+
+var (
+	options  Options
+	last     string // Last pattern searched
+	backward bool   // Whether the last search went backwards
+	hidden   bool   // Highlighting hidden by :noh until the next search
+	lastRe   Regexp // last compiled with the options, nil when invalid
+)
+
+// SetOptions sets the options patterns are compiled with
+func SetOptions(o Options) {
+	options = o
+	lastRe, _ = Compile(last)
+}
+
+// Current returns the options patterns are compiled with
+func Current() Options {
+	return options
+}
+
+// Compile compiles pattern with the current options
+func Compile(pattern string) (Regexp, error) {
+	return options.Compile(pattern)
+}
+
+// Compile compiles pattern, a regular expression in the syntax of the
+// options' engine, ignoring case as the options say. \c anywhere in the
+// pattern ignores case and \C matches it, whatever the options, and \<
+// and \> match at the start and end of a word. ^ and $ match at line
+// breaks, which \n matches in patterns spanning lines. \%V is left out
+// for the callers to apply with VisualOnly and VisualMatches.
+func (o Options) Compile(pattern string) (Regexp, error) {
+	expr, override := caseOverride(cutVisual(pattern))
+	ignoreCase := o.IgnoreCase && !(o.SmartCase && hasUpper(expr))
+	if override != 0 {
+		ignoreCase = override == 'c'
+	}
+	return compile(o.Engine, wordBoundaries(expr), ignoreCase)
+}
+
+// Multiline reports whether pattern matches line breaks with \n, so it
+// must be matched against the text of several lines rather than each line
+func Multiline(pattern string) bool {
+	for i := 0; i+1 < len(pattern); i++ {
+		if pattern[i] == '\\' {
+			i++
+			if pattern[i] == 'n' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VisualOnly reports whether pattern has \%V, which restricts it to
+// matches inside the last visual selection
+func VisualOnly(pattern string) bool {
+	return cutVisual(pattern) != pattern
+}
+
+// VisualMatches returns the matches of a pattern on line lineNum of buf,
+// as FindAllStringIndex returns them, that lie inside the last visual
+// selection
+func VisualMatches(buf *buffer.Buffer, lineNum int, matches [][]int) [][]int {
+	var inside [][]int
+	for _, m := range matches {
+		if buf.InVisualArea(buffer.Position{Line: lineNum, Col: m[0]}, buffer.Position{Line: lineNum, Col: m[1]}) {
+			inside = append(inside, m)
+		}
+	}
+	return inside
+}
+
+// cutVisual removes every \%V from pattern
+func cutVisual(pattern string) string {
+	if !strings.Contains(pattern, `\%V`) {
+		return pattern
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			if strings.HasPrefix(pattern[i:], `\%V`) {
+				i += 2
+				continue
+			}
+			b.WriteByte('\\')
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// wordBoundaries replaces Vim's \< and \>, the start and end of a word,
+// with \b, which both engines know
+func wordBoundaries(pattern string) string {
+	if !strings.Contains(pattern, `\<`) && !strings.Contains(pattern, `\>`) {
+		return pattern
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+			if pattern[i] == '<' || pattern[i] == '>' {
+				b.WriteString(`\b`)
+				continue
+			}
+			b.WriteByte('\\')
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// caseOverride removes \c and \C from pattern, returning what is left and
+// the last of them found, or 0
+func caseOverride(pattern string) (string, byte) {
+	if !strings.Contains(pattern, `\c`) && !strings.Contains(pattern, `\C`) {
+		return pattern, 0
+	}
+	var b strings.Builder
+	var override byte
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+			if pattern[i] == 'c' || pattern[i] == 'C' {
+				override = pattern[i]
+				continue
+			}
+			b.WriteByte('\\')
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String(), override
+}
+
+// hasUpper reports whether pattern has an upper case letter of its own,
+// not counting escapes like \S and \PL
+func hasUpper(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; {
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			if (pattern[i] == 'p' || pattern[i] == 'P') && i+1 < len(pattern) {
+				// \pL or \p{Greek}
+				i++
+				if pattern[i] == '{' {
+					if end := strings.IndexByte(pattern[i:], '}'); end >= 0 {
+						i += end
+					}
+				}
+			}
+		case ch < 0x80:
+			if 'A' <= ch && ch <= 'Z' {
+				return true
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(pattern[i:])
+			if unicode.IsUpper(r) {
+				return true
+			}
+			i += size - 1
+		}
+	}
+	return false
+}
+
+// SetLast remembers pattern as the last one searched, for n, N and
+// highlighting, and shows highlighting hidden by HideHighlight again
+func SetLast(pattern string, back bool) {
+	last, backward, hidden = pattern, back, false
+	lastRe, _ = Compile(pattern)
+}
+
+// Last returns the last pattern searched and whether the search went
+// backwards
+func Last() (string, bool) {
+	return last, backward
+}
+
+// HideHighlight hides the highlighting of matches until the next search,
+// for :nohlsearch
+func HideHighlight() {
+	hidden = true
+}
+
+// Highlighted returns the pattern whose matches are highlighted, or nil
+// when nothing is
+func Highlighted() Regexp {
+	if !options.HLSearch || hidden || last == "" {
+		return nil
+	}
+	return lastRe
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptions_Compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		pattern string
+		text    string
+		want    bool
+	}{
+		{"case matters by default", Options{}, "foo", "FOO", false},
+		{"ignorecase", Options{IgnoreCase: true}, "foo", "FOO", true},
+		{"smartcase with lower case", Options{IgnoreCase: true, SmartCase: true}, "foo", "FOO", true},
+		{"smartcase with upper case", Options{IgnoreCase: true, SmartCase: true}, "Foo", "FOO", false},
+		{"smartcase ignores escapes", Options{IgnoreCase: true, SmartCase: true}, `foo\S`, "FOOX", true},
+		{"smartcase ignores classes", Options{IgnoreCase: true, SmartCase: true}, `\p{Greek}x`, "αX", true},
+		{"smartcase without ignorecase", Options{SmartCase: true}, "foo", "FOO", false},
+		{`\c ignores case`, Options{}, `foo\c`, "FOO", true},
+		{`\C matches case`, Options{IgnoreCase: true}, `\Cfoo`, "FOO", false},
+		{`an escaped backslash isn't \c`, Options{}, `a\\c`, `a\c`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := tt.options.Compile(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := re.MatchString(tt.text); got != tt.want {
+				t.Errorf("%q matches %q = %v, want %v", tt.pattern, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlighted(t *testing.T) {
+	old := Current()
+	defer func() {
+		SetOptions(old)
+		SetLast("", false)
+	}()
+
+	SetOptions(Options{HLSearch: true})
+	SetLast("foo", true)
+	if pattern, backward := Last(); pattern != "foo" || !backward {
+		t.Errorf("Last() = %q, %v", pattern, backward)
+	}
+	if re := Highlighted(); re == nil || !re.MatchString("a foo") {
+		t.Fatalf("Highlighted() = %v", re)
+	}
+
+	// Changing the options recompiles the pattern
+	SetOptions(Options{HLSearch: true, IgnoreCase: true})
+	if re := Highlighted(); re == nil || !re.MatchString("FOO") {
+		t.Errorf("expected the highlighting to ignore case, got %v", re)
+	}
+
+	HideHighlight()
+	if Highlighted() != nil {
+		t.Error("expected :noh to hide the highlighting")
+	}
+	SetLast("bar", false)
+	if Highlighted() == nil {
+		t.Error("expected a new search to show the highlighting again")
+	}
+	SetOptions(Options{})
+	if Highlighted() != nil {
+		t.Error("expected nothing highlighted without hlsearch")
+	}
+}
+
+func TestOptions_CompileRegexp2(t *testing.T) {
+	options := Options{Engine: EngineRegexp2}
+	tests := []struct {
+		pattern string
+		text    string
+		want    [][]int
+	}{
+		{`foo(?=bar)`, "foobar foobaz", [][]int{{0, 3}}},
+		{`(?<=é)x`, "éx x", [][]int{{2, 3}}}, // byte offsets, not runes
+		{`(\w)\1`, "abba", [][]int{{1, 3}}},
+		{`\<is\>`, "this is", [][]int{{5, 7}}},
+		{`a\nb`, "a\nb", [][]int{{0, 3}}},
+		{`^b`, "a\nb", [][]int{{2, 3}}},
+	}
+	for _, tt := range tests {
+		re, err := options.Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.pattern, err)
+		}
+		if got := re.FindAllStringIndex(tt.text, -1); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q in %q = %v, want %v", tt.pattern, tt.text, got, tt.want)
+		}
+	}
+
+	if _, err := (Options{}).Compile(`foo(?=bar)`); err == nil {
+		t.Error("the Go engine compiled a lookahead")
+	}
+	re, err := options.Compile(`(?<word>\w+)-(\d)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "ab-1"
+	m := re.FindAllStringSubmatchIndex(text, -1)[0]
+	if got := string(re.ExpandString(nil, "${1}$word$$", text, m)); got != "1ab$" {
+		t.Errorf("expanded %q", got)
+	}
+}
+
+func TestParseEngine(t *testing.T) {
+	for name, want := range map[string]Engine{"": EngineGo, "go": EngineGo, "regexp2": EngineRegexp2} {
+		if got, err := ParseEngine(name); err != nil || got != want {
+			t.Errorf("ParseEngine(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseEngine("pcre"); err == nil {
+		t.Error("ParseEngine accepted pcre")
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	for pattern, want := range map[string]bool{`a\nb`: true, `a\\nb`: false, `a\sb`: false, `\n`: true} {
+		if got := Multiline(pattern); got != want {
+			t.Errorf("Multiline(%q) = %v", pattern, got)
+		}
+	}
+}
+
+func TestVisualOnly(t *testing.T) {
+	for pattern, want := range map[string]bool{`\%Vfoo`: true, `foo\%V`: true, `\\%Vfoo`: false, `foo`: false} {
+		if got := VisualOnly(pattern); got != want {
+			t.Errorf("VisualOnly(%q) = %v", pattern, got)
+		}
+	}
+	re, err := Compile(`\%Vfoo\%V`)
+	if err != nil || !re.MatchString("foo") {
+		t.Errorf(`\%%V compiled to %v, %v`, re, err)
+	}
+}
@@ -0,0 +1,245 @@
+// Package setup runs the wizard that writes a starter config on first
+// launch, after finding the language servers and Ollama already installed.
+package setup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/config"
+)
+
+// OllamaURL is where a local Ollama server listens
+const OllamaURL = "http://localhost:11434"
+
+// declinedFile marks that the wizard was declined, so it isn't offered again
+const declinedFile = "setup-declined"
+
+// Found is what is already installed on the machine
+type Found struct {
+	Servers      []string // Names of the example language servers on the PATH
+	Ollama       bool     // Whether Ollama is running
+	OllamaModels []string // Models Ollama has pulled
+}
+
+// Detect finds the example language servers on the PATH and asks Ollama at
+// ollamaURL for its models
+func Detect(ollamaURL string) Found {
+	var found Found
+	for _, srv := range config.ExampleServers() {
+		if _, err := exec.LookPath(srv.Command); err == nil {
+			found.Servers = append(found.Servers, srv.Name)
+		}
+	}
+	if models, err := ollamaModels(ollamaURL); err == nil {
+		found.Ollama = true
+		found.OllamaModels = models
+	}
+	return found
+}
+
+// ollamaModels lists the models pulled into the Ollama server at baseURL
+func ollamaModels(baseURL string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned %s", resp.Status)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
+
+// Needed reports whether the wizard should run: no config file exists and
+// the wizard wasn't declined before
+func Needed() bool {
+	for _, path := range config.ConfigPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return false
+		}
+	}
+	dir, err := configDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, declinedFile))
+	return os.IsNotExist(err)
+}
+
+// configDir returns the directory of the user's config
+func configDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "aied"), nil
+}
+
+// provider is an AI provider the wizard offers
+type provider struct {
+	Type   ai.ProviderType
+	Name   string
+	KeyEnv string // Environment variable holding the API key, empty for none
+}
+
+var providers = []provider{
+	{Type: ai.ProviderOpenAI, Name: "OpenAI", KeyEnv: "OPENAI_API_KEY"},
+	{Type: ai.ProviderAnthropic, Name: "Anthropic", KeyEnv: "ANTHROPIC_API_KEY"},
+	{Type: ai.ProviderGoogle, Name: "Google", KeyEnv: "GOOGLE_API_KEY"},
+	{Type: ai.ProviderOllama, Name: "Ollama"},
+}
+
+// Wizard asks which providers and language servers to enable
+type Wizard struct {
+	in         *bufio.Reader
+	out        io.Writer
+	readSecret func(prompt string) (string, error)
+}
+
+// NewWizard creates a wizard reading answers from in and API keys with
+// readSecret, which shouldn't echo them
+func NewWizard(in io.Reader, out io.Writer, readSecret func(prompt string) (string, error)) *Wizard {
+	return &Wizard{in: bufio.NewReader(in), out: out, readSecret: readSecret}
+}
+
+// Ask walks through the setup given what was found installed. It returns
+// false if setup was declined.
+func (w *Wizard) Ask(found Found) (config.Setup, bool, error) {
+	var setup config.Setup
+
+	fmt.Fprintln(w.out, "Welcome to aied. No configuration was found.")
+	if len(found.Servers) > 0 {
+		fmt.Fprintf(w.out, "Language servers found: %s\n", strings.Join(found.Servers, ", "))
+	} else {
+		fmt.Fprintln(w.out, "No language servers were found on the PATH.")
+	}
+	if found.Ollama {
+		fmt.Fprintf(w.out, "Ollama is running with %d models.\n", len(found.OllamaModels))
+	}
+	ok, err := w.confirm("Set up a starter configuration now?", true)
+	if err != nil || !ok {
+		return setup, false, err
+	}
+
+	for _, p := range providers {
+		ok, err := w.confirm("Enable "+p.Name+"?", p.Type == ai.ProviderOllama && found.Ollama)
+		if err != nil {
+			return setup, false, err
+		}
+		if !ok {
+			continue
+		}
+		chosen := ai.ProviderConfig{Type: p.Type}
+		switch {
+		case p.KeyEnv != "" && os.Getenv(p.KeyEnv) != "":
+			fmt.Fprintf(w.out, "  Using the key in %s.\n", p.KeyEnv)
+		case p.KeyEnv != "":
+			key, err := w.readSecret(fmt.Sprintf("  %s API key (empty to set %s later): ", p.Name, p.KeyEnv))
+			if err != nil {
+				return setup, false, err
+			}
+			chosen.APIKey = strings.TrimSpace(key)
+		case len(found.OllamaModels) > 0:
+			chosen.Model = found.OllamaModels[0]
+			fmt.Fprintf(w.out, "  Using the model %s.\n", chosen.Model)
+		}
+		setup.Providers = append(setup.Providers, chosen)
+	}
+
+	for _, name := range found.Servers {
+		ok, err := w.confirm("Enable the "+name+" language server?", true)
+		if err != nil {
+			return setup, false, err
+		}
+		if ok {
+			setup.Servers = append(setup.Servers, name)
+		}
+	}
+	return setup, true, nil
+}
+
+// confirm asks a yes or no question, returning def for an empty answer
+func (w *Wizard) confirm(question string, def bool) (bool, error) {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	for {
+		fmt.Fprintf(w.out, "%s %s ", question, choices)
+		line, err := w.in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return def, nil
+		} else if err != nil && err != io.EOF {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(w.out, "Please answer y or n.")
+	}
+}
+
+// Run offers the wizard on in and out, writing the starter config to the
+// user's config directory, or remembering that it was declined. It returns
+// the path written, empty if declined.
+func Run(in io.Reader, out io.Writer, readSecret func(prompt string) (string, error)) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	setup, ok, err := NewWizard(in, out, readSecret).Ask(Detect(OllamaURL))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		fmt.Fprintln(out, "Skipped. Use :configgen to write an example configuration later.")
+		return "", os.WriteFile(filepath.Join(dir, declinedFile), nil, 0644)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := config.GenerateStarter(path, setup); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(out, "Wrote %s\n", path)
+	return path, nil
+}
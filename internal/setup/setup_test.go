@@ -0,0 +1,98 @@
+package setup
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/ai"
+)
+
+func TestAskChoosesProvidersAndServers(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "from-env")
+
+	var prompts []string
+	readSecret := func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return " sk-openai \n", nil
+	}
+	// Set up, OpenAI yes, Anthropic yes, Google default no, Ollama default
+	// yes, gopls no
+	in := strings.NewReader("\ny\nyes\n\n\nn\n")
+	var out strings.Builder
+	found := Found{Servers: []string{"gopls"}, Ollama: true, OllamaModels: []string{"qwen2.5-coder"}}
+
+	setup, ok, err := NewWizard(in, &out, readSecret).Ask(found)
+	if err != nil || !ok {
+		t.Fatalf("Ask() = %v, %v", ok, err)
+	}
+
+	want := []ai.ProviderConfig{
+		{Type: ai.ProviderOpenAI, APIKey: "sk-openai"},
+		{Type: ai.ProviderAnthropic},
+		{Type: ai.ProviderOllama, Model: "qwen2.5-coder"},
+	}
+	if fmt.Sprint(setup.Providers) != fmt.Sprint(want) {
+		t.Errorf("Providers = %+v, want %+v", setup.Providers, want)
+	}
+	if len(setup.Servers) != 0 {
+		t.Errorf("Servers = %v, want none", setup.Servers)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "OpenAI") {
+		t.Errorf("Expected a key asked only for OpenAI, got %q", prompts)
+	}
+	if !strings.Contains(out.String(), "ANTHROPIC_API_KEY") {
+		t.Errorf("Expected the environment key mentioned, got %q", out.String())
+	}
+}
+
+func TestAskDeclined(t *testing.T) {
+	in := strings.NewReader("n\n")
+	var out strings.Builder
+	_, ok, err := NewWizard(in, &out, nil).Ask(Found{})
+	if err != nil || ok {
+		t.Errorf("Ask() = %v, %v, want declined", ok, err)
+	}
+}
+
+func TestConfirmRepeatsUnclearAnswers(t *testing.T) {
+	var out strings.Builder
+	w := NewWizard(strings.NewReader("maybe\nY\n"), &out, nil)
+	ok, err := w.confirm("Continue?", false)
+	if err != nil || !ok {
+		t.Errorf("confirm() = %v, %v", ok, err)
+	}
+	if !strings.Contains(out.String(), "Please answer y or n.") {
+		t.Errorf("Expected the question asked again, got %q", out.String())
+	}
+
+	// The default applies once input runs out
+	w = NewWizard(strings.NewReader(""), &out, nil)
+	if ok, err := w.confirm("Continue?", true); err != nil || !ok {
+		t.Errorf("confirm() at end of input = %v, %v", ok, err)
+	}
+}
+
+func TestDetectOllama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"llama3:latest"},{"name":"codestral"}]}`)
+	}))
+	defer server.Close()
+
+	found := Detect(server.URL)
+	if !found.Ollama || fmt.Sprint(found.OllamaModels) != "[llama3:latest codestral]" {
+		t.Errorf("Detect() = %+v", found)
+	}
+
+	server.Close()
+	if found := Detect(server.URL); found.Ollama {
+		t.Error("Expected Ollama not found once the server stopped")
+	}
+}
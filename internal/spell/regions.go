@@ -0,0 +1,87 @@
+package spell
+
+import (
+	"strings"
+)
+
+// proseFiletypes are checked in full rather than only in comments and strings
+var proseFiletypes = map[string]bool{
+	"markdown":  true,
+	"text":      true,
+	"gitcommit": true,
+	"rst":       true,
+}
+
+// lineCommentPrefixes maps filetypes to their line comment markers
+var lineCommentPrefixes = map[string][]string{
+	"go":         {"//"},
+	"c":          {"//"},
+	"cpp":        {"//"},
+	"java":       {"//"},
+	"javascript": {"//"},
+	"typescript": {"//"},
+	"rust":       {"//"},
+	"swift":      {"//"},
+	"kotlin":     {"//"},
+	"php":        {"//", "#"},
+	"python":     {"#"},
+	"ruby":       {"#"},
+	"bash":       {"#"},
+	"yaml":       {"#"},
+	"toml":       {"#"},
+	"lua":        {"--"},
+	"sql":        {"--"},
+	"haskell":    {"--"},
+	"vim":        {"\""},
+}
+
+// Regions returns the byte ranges of a line that should be spell checked
+func Regions(line, filetype string) [][2]int {
+	if proseFiletypes[filetype] {
+		return [][2]int{{0, len(line)}}
+	}
+
+	var regions [][2]int
+	prefixes := lineCommentPrefixes[filetype]
+	inString := byte(0)
+	stringStart := 0
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+
+		if inString != 0 {
+			switch ch {
+			case '\\':
+				i++ // skip escaped character
+			case inString:
+				regions = append(regions, [2]int{stringStart, i})
+				inString = 0
+			}
+			continue
+		}
+
+		// Block comment contained on this line (or running to its end)
+		if strings.HasPrefix(line[i:], "/*") && len(prefixes) > 0 && prefixes[0] == "//" {
+			end := strings.Index(line[i+2:], "*/")
+			if end < 0 {
+				return append(regions, [2]int{i + 2, len(line)})
+			}
+			regions = append(regions, [2]int{i + 2, i + 2 + end})
+			i += end + 3
+			continue
+		}
+
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(line[i:], prefix) {
+				return append(regions, [2]int{i + len(prefix), len(line)})
+			}
+		}
+
+		if ch == '"' || ch == '\'' {
+			inString = ch
+			stringStart = i + 1
+		}
+	}
+
+	return regions
+}
@@ -0,0 +1,351 @@
+package spell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultDictionaryPaths are searched when no dictionaries are configured
+var DefaultDictionaryPaths = []string{
+	"/usr/share/hunspell/en_US.dic",
+	"/usr/share/myspell/en_US.dic",
+	"/usr/share/myspell/dicts/en_US.dic",
+	"/usr/share/dict/words",
+}
+
+// Checker is a pure-Go dictionary based spell checker
+type Checker struct {
+	mu       sync.RWMutex
+	words    map[string]struct{} // lowercased dictionary words
+	byLength map[int][]string    // dictionary words grouped by rune length, for suggestions
+	userDict string              // path of the user word list (zg appends here)
+
+	filetypes map[string]bool // filetypes spell checking is enabled for (nil means all)
+}
+
+// Misspelling is a misspelled word located on a line
+type Misspelling struct {
+	Start int    // byte offset of the first character
+	End   int    // byte offset just past the last character
+	Word  string // the misspelled word
+}
+
+// NewChecker creates an empty checker
+func NewChecker() *Checker {
+	return &Checker{
+		words:    make(map[string]struct{}),
+		byLength: make(map[int][]string),
+	}
+}
+
+// Load creates a checker from the given dictionaries, falling back to the
+// first available system dictionary when none are configured
+func Load(dictionaries []string, userDict string) (*Checker, error) {
+	c := NewChecker()
+
+	if len(dictionaries) == 0 {
+		for _, path := range DefaultDictionaryPaths {
+			if _, err := os.Stat(path); err == nil {
+				dictionaries = []string{path}
+				break
+			}
+		}
+	}
+
+	for _, path := range dictionaries {
+		if err := c.LoadDictionary(path); err != nil {
+			return c, err
+		}
+	}
+
+	if userDict != "" {
+		if err := c.LoadUserDictionary(userDict); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
+
+// LoadDictionary loads a word list or hunspell .dic file into the checker.
+// Hunspell affix flags ("word/FLAGS") are stripped; affix expansion is not performed.
+func (c *Checker) LoadDictionary(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open dictionary %q: %w", path, err)
+	}
+	defer file.Close()
+
+	isHunspell := filepath.Ext(path) == ".dic"
+	scanner := bufio.NewScanner(file)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first && isHunspell {
+			// First line of a .dic file is the approximate word count
+			first = false
+			if _, err := fmt.Sscanf(line, "%d", new(int)); err == nil {
+				continue
+			}
+		}
+		first = false
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if idx := strings.IndexByte(line, '/'); idx >= 0 {
+			line = line[:idx]
+		}
+		c.AddWord(line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read dictionary %q: %w", path, err)
+	}
+	return nil
+}
+
+// LoadUserDictionary loads (if present) and remembers the user word list
+func (c *Checker) LoadUserDictionary(path string) error {
+	c.mu.Lock()
+	c.userDict = path
+	c.mu.Unlock()
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	return c.LoadDictionary(path)
+}
+
+// AddWord adds a word to the in-memory dictionary
+func (c *Checker) AddWord(word string) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.words[word]; exists {
+		return
+	}
+	c.words[word] = struct{}{}
+	n := utf8.RuneCountInString(word)
+	c.byLength[n] = append(c.byLength[n], word)
+}
+
+// AddGoodWord adds a word to the dictionary and appends it to the user word list
+func (c *Checker) AddGoodWord(word string) error {
+	c.AddWord(word)
+
+	c.mu.RLock()
+	path := c.userDict
+	c.mu.RUnlock()
+	if path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create spell directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open user dictionary %q: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(strings.ToLower(word) + "\n"); err != nil {
+		return fmt.Errorf("failed to write user dictionary %q: %w", path, err)
+	}
+	return nil
+}
+
+// SetFiletypes restricts spell checking to the given filetypes
+func (c *Checker) SetFiletypes(filetypes []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(filetypes) == 0 {
+		c.filetypes = nil
+		return
+	}
+	c.filetypes = make(map[string]bool, len(filetypes))
+	for _, ft := range filetypes {
+		c.filetypes[ft] = true
+	}
+}
+
+// EnabledFor reports whether spell checking applies to a filetype
+func (c *Checker) EnabledFor(filetype string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filetypes == nil || c.filetypes[filetype]
+}
+
+// WordCount returns the number of words in the dictionary
+func (c *Checker) WordCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.words)
+}
+
+// Check reports whether a word is spelled correctly. An empty dictionary
+// accepts every word so that a missing dictionary never floods the screen.
+func (c *Checker) Check(word string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.words) == 0 {
+		return true
+	}
+	_, ok := c.words[strings.ToLower(word)]
+	return ok
+}
+
+// Suggest returns up to max dictionary words closest to word
+func (c *Checker) Suggest(word string, max int) []string {
+	lower := strings.ToLower(word)
+	target := []rune(lower)
+
+	type candidate struct {
+		word string
+		dist int
+	}
+	var candidates []candidate
+
+	c.mu.RLock()
+	for n := len(target) - 2; n <= len(target)+2; n++ {
+		for _, w := range c.byLength[n] {
+			if d := editDistance(target, []rune(w)); d <= 2 {
+				candidates = append(candidates, candidate{word: w, dist: d})
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].word < candidates[j].word
+	})
+
+	var result []string
+	for _, cand := range candidates {
+		if len(result) >= max {
+			break
+		}
+		result = append(result, matchCase(word, cand.word))
+	}
+	return result
+}
+
+// CheckLine returns the misspelled words on a line of the given filetype.
+// Only prose regions (comments and strings for code, everything for text) are checked.
+func (c *Checker) CheckLine(line, filetype string) []Misspelling {
+	var result []Misspelling
+	for _, region := range Regions(line, filetype) {
+		for _, w := range words(line, region[0], region[1]) {
+			if !c.Check(w.Word) {
+				result = append(result, w)
+			}
+		}
+	}
+	return result
+}
+
+// words splits line[start:end] into checkable words
+func words(line string, start, end int) []Misspelling {
+	var result []Misspelling
+	i := start
+	for i < end {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if !unicode.IsLetter(r) {
+			i += size
+			continue
+		}
+
+		wordStart := i
+		for i < end {
+			r, size = utf8.DecodeRuneInString(line[i:])
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+				i += size
+				continue
+			}
+			// Allow inner apostrophes (don't, it's)
+			if r == '\'' && i+size < end {
+				next, _ := utf8.DecodeRuneInString(line[i+size:])
+				if unicode.IsLetter(next) {
+					i += size
+					continue
+				}
+			}
+			break
+		}
+
+		word := line[wordStart:i]
+		if shouldCheck(word) {
+			result = append(result, Misspelling{Start: wordStart, End: i, Word: word})
+		}
+	}
+	return result
+}
+
+// shouldCheck filters out identifiers, acronyms and very short words
+func shouldCheck(word string) bool {
+	if utf8.RuneCountInString(word) < 2 {
+		return false
+	}
+	for i, r := range word {
+		if unicode.IsDigit(r) || r == '_' {
+			return false
+		}
+		// camelCase and ACRONYMS are treated as identifiers
+		if i > 0 && unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCase applies the capitalization of original to suggestion
+func matchCase(original, suggestion string) string {
+	r, _ := utf8.DecodeRuneInString(original)
+	if unicode.IsUpper(r) {
+		s, size := utf8.DecodeRuneInString(suggestion)
+		return string(unicode.ToUpper(s)) + suggestion[size:]
+	}
+	return suggestion
+}
+
+// editDistance computes the Damerau-Levenshtein (optimal string alignment) distance
+func editDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,140 @@
+package spell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestChecker(words ...string) *Checker {
+	c := NewChecker()
+	for _, w := range words {
+		c.AddWord(w)
+	}
+	return c
+}
+
+func TestChecker_Check(t *testing.T) {
+	c := newTestChecker("hello", "world", "don't")
+
+	tests := []struct {
+		word     string
+		expected bool
+	}{
+		{"hello", true},
+		{"Hello", true},
+		{"wrold", false},
+		{"don't", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := c.Check(tt.word); got != tt.expected {
+				t.Errorf("Check(%q) = %v, want %v", tt.word, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestChecker_EmptyDictionaryAcceptsAll(t *testing.T) {
+	c := NewChecker()
+	if !c.Check("anythingatall") {
+		t.Error("empty dictionary should accept every word")
+	}
+}
+
+func TestChecker_Suggest(t *testing.T) {
+	c := newTestChecker("hello", "help", "world", "word", "banana")
+
+	suggestions := c.Suggest("wrold", 5)
+	if len(suggestions) == 0 || suggestions[0] != "world" {
+		t.Errorf("expected first suggestion 'world', got %v", suggestions)
+	}
+
+	suggestions = c.Suggest("Helo", 5)
+	if len(suggestions) == 0 || suggestions[0] != "Hello" {
+		t.Errorf("expected capitalized suggestion 'Hello', got %v", suggestions)
+	}
+
+	for _, s := range c.Suggest("wrold", 5) {
+		if s == "banana" {
+			t.Error("distant word should not be suggested")
+		}
+	}
+}
+
+func TestChecker_CheckLine(t *testing.T) {
+	c := newTestChecker("this", "is", "a", "comment", "string", "value")
+
+	tests := []struct {
+		name     string
+		line     string
+		filetype string
+		expected []string
+	}{
+		{"go comment", "x := foo // this is a coment", "go", []string{"coment"}},
+		{"go code ignored", "misspeled := wrongg()", "go", nil},
+		{"go string", `fmt.Println("strng value")`, "go", []string{"strng"}},
+		{"markdown prose", "this is a tpyo", "markdown", []string{"tpyo"}},
+		{"python comment", "x = 1  # commnet", "python", []string{"commnet"}},
+		{"identifiers skipped", "// camelCase HTTP snake_case", "go", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.CheckLine(tt.line, tt.filetype)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %+v", tt.expected, got)
+			}
+			for i, m := range got {
+				if m.Word != tt.expected[i] {
+					t.Errorf("expected %q, got %q", tt.expected[i], m.Word)
+				}
+				if tt.line[m.Start:m.End] != m.Word {
+					t.Errorf("offsets %d-%d do not match word %q", m.Start, m.End, m.Word)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_LoadHunspellDictionary(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "en_US.dic")
+	if err := os.WriteFile(path, []byte("3\nhello/MS\nworld\ncolor/SM\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChecker()
+	if err := c.LoadDictionary(path); err != nil {
+		t.Fatalf("LoadDictionary failed: %v", err)
+	}
+
+	if c.WordCount() != 3 {
+		t.Errorf("expected 3 words, got %d", c.WordCount())
+	}
+	if !c.Check("color") {
+		t.Error("expected affix flags to be stripped")
+	}
+}
+
+func TestChecker_AddGoodWord(t *testing.T) {
+	dir := t.TempDir()
+	userDict := filepath.Join(dir, "spell", "user.add")
+
+	c := newTestChecker("hello")
+	if err := c.LoadUserDictionary(userDict); err != nil {
+		t.Fatalf("LoadUserDictionary failed: %v", err)
+	}
+	if err := c.AddGoodWord("aied"); err != nil {
+		t.Fatalf("AddGoodWord failed: %v", err)
+	}
+
+	reloaded := newTestChecker("hello")
+	if err := reloaded.LoadUserDictionary(userDict); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if !reloaded.Check("aied") {
+		t.Error("expected good word to persist in user dictionary")
+	}
+}
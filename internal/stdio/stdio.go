@@ -0,0 +1,81 @@
+// Package stdio lets a buffer be read from the standard input and written
+// to the standard output, for cmd | aied - | other.
+//
+// The editor draws on the terminal rather than the standard streams, so
+// while it runs the standard output may well be the same terminal. What
+// :w writes is therefore held until the editor exits and then printed.
+package stdio
+
+import (
+	"io"
+	"sync"
+)
+
+// Name is the filename standing for the standard input and output
+const Name = "-"
+
+// Handler reads the buffer named "-" from in and holds what is written to
+// it for Flush. It is safe for concurrent use.
+type Handler struct {
+	mu      sync.Mutex
+	in      io.Reader
+	read    bool
+	input   []byte
+	output  []byte
+	written bool
+}
+
+// NewHandler creates a handler reading from in
+func NewHandler(in io.Reader) *Handler {
+	return &Handler{in: in}
+}
+
+// Handles reports whether filename stands for the standard streams
+func (h *Handler) Handles(filename string) bool {
+	return filename == Name
+}
+
+// Indicator labels the buffer in the status line
+func (h *Handler) Indicator(filename string) string {
+	return "[stdin]"
+}
+
+// ReadFile returns everything on the input, read once
+func (h *Handler) ReadFile(filename string) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.read {
+		data, err := io.ReadAll(h.in)
+		if err != nil {
+			return nil, err
+		}
+		h.input, h.read = data, true
+	}
+	return h.input, nil
+}
+
+// WriteFile holds data to be printed when the editor exits, replacing
+// what was written before
+func (h *Handler) WriteFile(filename string, data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.output = append(h.output[:0], data...)
+	h.written = true
+	return nil
+}
+
+// Flush writes what was last written to the buffer to out, with a final
+// line break, if anything was
+func (h *Handler) Flush(out io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.written {
+		return nil
+	}
+	data := h.output
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	_, err := out.Write(data)
+	return err
+}
@@ -0,0 +1,57 @@
+package stdio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestHandlerReadsOnceAndFlushesLastWrite(t *testing.T) {
+	h := NewHandler(strings.NewReader("one\ntwo\n"))
+	if !h.Handles("-") || h.Handles("-.txt") {
+		t.Error("expected only - handled")
+	}
+
+	for range 2 {
+		data, err := h.ReadFile(Name)
+		if err != nil || string(data) != "one\ntwo\n" {
+			t.Errorf("ReadFile() = %q, %v", data, err)
+		}
+	}
+
+	var out strings.Builder
+	h.Flush(&out)
+	if out.Len() != 0 {
+		t.Errorf("Flush() before a write printed %q", out.String())
+	}
+
+	h.WriteFile(Name, []byte("first"))
+	h.WriteFile(Name, []byte("second"))
+	if err := h.Flush(&out); err != nil || out.String() != "second\n" {
+		t.Errorf("Flush() = %q, %v", out.String(), err)
+	}
+}
+
+func TestBufferThroughHandler(t *testing.T) {
+	h := NewHandler(strings.NewReader("hello\nworld\n"))
+	buffer.RegisterFileHandler(h)
+
+	buf, err := buffer.NewFromFile(Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.LineCount() != 2 || buf.FileIndicator() != "[stdin]" {
+		t.Errorf("buffer has %d lines, indicator %q", buf.LineCount(), buf.FileIndicator())
+	}
+	buf.InsertTextAt(0, 0, "> ")
+	if err := buf.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	h.Flush(&out)
+	if out.String() != "> hello\nworld\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// QueryBackground reports whether the terminal's background is light,
+// from $COLORFGBG or by asking the terminal with OSC 11. ok is false when
+// neither tells within timeout. It must be called before the UI takes the
+// terminal.
+func QueryBackground(timeout time.Duration) (light, ok bool) {
+	if light, ok := parseColorFGBG(os.Getenv("COLORFGBG")); ok {
+		return light, true
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, false
+	}
+	defer tty.Close()
+	// A read that can't time out would keep the keys typed after it
+	if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return false, false
+	}
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return false, false
+	}
+	defer term.Restore(int(tty.Fd()), state)
+
+	// Every terminal answers the device attributes query that follows, so
+	// one that ignores OSC 11 doesn't hold us until the timeout
+	if _, err := tty.WriteString("\x1b]11;?\x1b\\\x1b[c"); err != nil {
+		return false, false
+	}
+	var reply []byte
+	chunk := make([]byte, 64)
+	for !deviceAttributesAnswered(reply) {
+		n, err := tty.Read(chunk)
+		reply = append(reply, chunk[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	return parseOSC11(string(reply))
+}
+
+// deviceAttributesAnswered reports whether reply has the answer to
+// the device attributes query, ESC [ ? ... c
+func deviceAttributesAnswered(reply []byte) bool {
+	s := string(reply)
+	start := strings.LastIndex(s, "\x1b[?")
+	return start >= 0 && strings.IndexByte(s[start:], 'c') >= 0
+}
+
+// parseOSC11 finds the answer to OSC 11 in reply, like
+// ESC ] 11 ; rgb:ffff/ffff/dddd BEL, and reports whether the color is light
+func parseOSC11(reply string) (light, ok bool) {
+	start := strings.Index(reply, "\x1b]11;rgb:")
+	if start < 0 {
+		return false, false
+	}
+	spec := reply[start+len("\x1b]11;rgb:"):]
+	if end := strings.IndexAny(spec, "\x07\x1b"); end >= 0 {
+		spec = spec[:end]
+	}
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 {
+		return false, false
+	}
+	var rgb [3]float64
+	for i, part := range parts {
+		// Each component has 1 to 4 hex digits, scaled to its maximum
+		value, err := strconv.ParseUint(part, 16, 16)
+		if err != nil || len(part) > 4 {
+			return false, false
+		}
+		rgb[i] = float64(value) / float64(uint64(1)<<(4*len(part))-1)
+	}
+	return isLight(rgb[0], rgb[1], rgb[2]), true
+}
+
+// parseColorFGBG reads $COLORFGBG, like "15;0" for white on black, and
+// reports whether the background is light
+func parseColorFGBG(value string) (light, ok bool) {
+	fields := strings.Split(value, ";")
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if value == "" || err != nil || bg < 0 || bg > 15 {
+		return false, false
+	}
+	// Silver and the bright colors but dark gray are light
+	return bg == 7 || bg > 8, true
+}
+
+// isLight reports whether a color with components from 0 to 1 is light,
+// by its perceived brightness
+func isLight(r, g, b float64) bool {
+	return 0.299*r+0.587*g+0.114*b > 0.5
+}
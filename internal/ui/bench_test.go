@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func BenchmarkRenderFrame(b *testing.B) {
+	u, _ := newSimulatedUI(b, 120, 40)
+	lines := make([]string, 10000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("\tresult%d := compute(%d, \"value\") // line %d", i, i*7, i)
+	}
+	buf := buffer.New()
+	buf.ReplaceLines(0, 0, lines)
+	buf.SetCursor(buffer.Position{Line: 5000, Col: 8})
+
+	frame := &Frame{Buffer: buf, Status: "NORMAL"}
+	for b.Loop() {
+		u.Draw(frame)
+	}
+}
+
+// renderBudget is the slowest a frame may draw: loose enough for slow CI
+// machines and the race detector, and far below a frame drawing the whole
+// buffer instead of the lines on screen
+const renderBudget = 50 * time.Millisecond
+
+func TestRenderBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("benchmarks are slow")
+	}
+	result := testing.Benchmark(BenchmarkRenderFrame)
+	if perFrame := time.Duration(result.NsPerOp()); perFrame > renderBudget {
+		t.Errorf("a frame takes %v to draw, over the budget of %v", perFrame, renderBudget)
+	}
+}
@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Capabilities are what the terminal can show. The zero value is a
+// terminal that shows everything.
+type Capabilities struct {
+	Colors int  // Colors the terminal has: 2 for monochrome, 8, 16, 256, or 0 for no limit
+	ASCII  bool // Whether it shows only ASCII, so borders are drawn with - | +
+}
+
+// minWidth and minHeight are the smallest screen the editor is drawn on;
+// a smaller terminal is asked to resize instead
+const (
+	minWidth  = 20
+	minHeight = 4
+)
+
+// DetectCapabilities asks s what its terminal can show
+func DetectCapabilities(s tcell.Screen) Capabilities {
+	colors := s.Colors()
+	if colors < 8 {
+		// tcell counts monochrome terminals as having no colors
+		colors = 2
+	}
+	if colors > 256 {
+		colors = 0
+	}
+	return Capabilities{
+		Colors: colors,
+		ASCII:  !s.CanDisplay('─', false) || !s.CanDisplay('→', false),
+	}
+}
+
+// Glyphs are the characters borders and markers are drawn with
+type Glyphs struct {
+	Horizontal, Vertical                       rune
+	TopLeft, TopRight, BottomLeft, BottomRight rune
+	Arrow                                      string // Between a key and what it does
+}
+
+// Glyphs returns the box drawing glyphs, or ASCII ones when the terminal
+// can't show those
+func (c Capabilities) Glyphs() Glyphs {
+	if c.ASCII {
+		return Glyphs{'-', '|', '+', '+', '+', '+', "->"}
+	}
+	return Glyphs{'─', '│', '┌', '┐', '└', '┘', "→"}
+}
+
+// listRune returns display, the list mode glyph for ch, or an ASCII one
+// when the terminal can't show it
+func (c Capabilities) listRune(ch, display rune) rune {
+	if !c.ASCII || display <= unicode.MaxASCII {
+		return display
+	}
+	switch ch {
+	case '\t':
+		return '>'
+	case ' ':
+		return '+'
+	}
+	return '-'
+}
+
+// basicColors are the colors the default styles use beyond the 16 every
+// color terminal has, and the one of the 16 drawn instead, picked to keep
+// text readable rather than for being nearest
+var basicColors = map[tcell.Color]tcell.Color{
+	tcell.ColorDarkGray:      tcell.ColorGray,
+	tcell.ColorDarkSlateGray: tcell.ColorTeal,
+	tcell.ColorDarkGreen:     tcell.ColorGreen,
+}
+
+// dimColors are the bright colors of the 16 and the dim ones 8 color
+// terminals draw instead
+var dimColors = map[tcell.Color]tcell.Color{
+	tcell.ColorGray:    tcell.ColorSilver,
+	tcell.ColorRed:     tcell.ColorMaroon,
+	tcell.ColorLime:    tcell.ColorGreen,
+	tcell.ColorYellow:  tcell.ColorOlive,
+	tcell.ColorBlue:    tcell.ColorNavy,
+	tcell.ColorFuchsia: tcell.ColorPurple,
+	tcell.ColorAqua:    tcell.ColorTeal,
+	tcell.ColorWhite:   tcell.ColorSilver,
+}
+
+// color returns the color the terminal draws for color
+func (c Capabilities) color(color tcell.Color) tcell.Color {
+	if c.Colors == 0 || c.Colors >= 256 {
+		return color
+	}
+	if basic, ok := basicColors[color]; ok {
+		color = basic
+	}
+	if c.Colors < 16 {
+		if dim, ok := dimColors[color]; ok {
+			color = dim
+		}
+	}
+	return color
+}
+
+// style returns style in the colors the terminal has. Without colors,
+// styles with a background are drawn reversed so the cursor, status line
+// and selections still stand out.
+func (c Capabilities) style(style tcell.Style) tcell.Style {
+	fg, bg, _ := style.Decompose()
+	if c.Colors != 2 {
+		return style.Foreground(c.color(fg)).Background(c.color(bg))
+	}
+	plain := style.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault)
+	if bg != tcell.ColorDefault && bg != tcell.ColorBlack {
+		plain = plain.Reverse(true)
+	}
+	return plain
+}
+
+// styles returns styles in the colors the terminal has
+func (c Capabilities) styles(styles *StyleConfig) *StyleConfig {
+	adapted := *styles
+	for _, style := range adapted.named() {
+		*style = c.style(*style)
+	}
+	return &adapted
+}
+
+// renderTooSmall asks for a bigger terminal in place of the editor
+func (r *Renderer) renderTooSmall(width, height int) {
+	r.activitySlot = activitySlot{}
+	lines := []string{
+		"Terminal too small",
+		fmt.Sprintf("%dx%d, need %dx%d", width, height, minWidth, minHeight),
+	}
+	for i, line := range lines[:min(len(lines), height)] {
+		r.screen.SetText(max((width-len(line))/2, 0), (height-len(lines))/2+i, line, r.styles.Normal)
+	}
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestCapabilities_ASCIIBorders(t *testing.T) {
+	u, sim := newSimulatedUI(t, 40, 10)
+	u.screen.caps = Capabilities{ASCII: true}
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "fmt.")
+
+	popup := NewCompletionPopup()
+	popup.SetItems([]CompletionItem{{Label: "Println", Kind: "func"}})
+	u.Draw(&Frame{Buffer: buf, Status: "INSERT", Completion: popup})
+
+	if row := screenRow(sim, 1); !strings.Contains(row, "+---") {
+		t.Errorf("popup top row = %q, want an ASCII border", row)
+	}
+	if row := screenRow(sim, 2); !strings.Contains(row, "| func: Println") {
+		t.Errorf("popup row = %q, want an ASCII border", row)
+	}
+}
+
+func TestCapabilities_Styles(t *testing.T) {
+	styles := NewDefaultStyles()
+	tests := []struct {
+		caps  Capabilities
+		style tcell.Style
+		want  tcell.Style
+	}{
+		{Capabilities{}, styles.ColorColumn, styles.ColorColumn},
+		{Capabilities{Colors: 256}, styles.Whitespace, styles.Whitespace},
+		{Capabilities{Colors: 16}, styles.ColorColumn, tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorTeal)},
+		{Capabilities{Colors: 8}, styles.Normal, tcell.StyleDefault.Foreground(tcell.ColorSilver).Background(tcell.ColorBlack)},
+		{Capabilities{Colors: 8}, styles.Whitespace, tcell.StyleDefault.Foreground(tcell.ColorSilver).Background(tcell.ColorBlack)},
+		{Capabilities{Colors: 2}, styles.Cursor, tcell.StyleDefault.Reverse(true)},
+		{Capabilities{Colors: 2}, styles.Normal, tcell.StyleDefault},
+		{Capabilities{Colors: 2}, styles.Error, tcell.StyleDefault.Underline(true)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.caps.style(tt.style); got != tt.want {
+			t.Errorf("%+v: style(%v) = %v, want %v", tt.caps, tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestUI_DrawTooSmall(t *testing.T) {
+	u, sim := newSimulatedUI(t, 19, 6)
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "hello")
+	u.Draw(&Frame{Buffer: buf, Status: "NORMAL", Activity: "- grep"})
+	u.ShowActivity("| grep")
+
+	var screen strings.Builder
+	for y := range 6 {
+		screen.WriteString(screenRow(sim, y) + "\n")
+	}
+	if text := screen.String(); !strings.Contains(text, "too small") || strings.Contains(text, "hello") || strings.Contains(text, "grep") {
+		t.Errorf("screen =\n%s\nwant only the resize prompt", text)
+	}
+
+	// Sizes from nothing up draw without panicking
+	for width := range minWidth + 2 {
+		for height := range minHeight + 2 {
+			u, _ := newSimulatedUI(t, width, height)
+			u.Draw(&Frame{Buffer: buf, Status: "NORMAL", Completion: NewCompletionPopup()})
+		}
+	}
+}
@@ -1,58 +1,48 @@
 package ui
 
 import (
-	"github.com/gdamore/tcell/v2"
+	"github.com/dshills/aied/internal/buffer"
 )
 
 // CompletionItem represents a single completion option
 type CompletionItem struct {
-	Label       string // The text to display
-	Detail      string // Additional detail (e.g., type information)
-	InsertText  string // The text to insert when selected
-	Kind        int    // Type of completion (e.g., 1=Text, 3=Function, 6=Variable)
+	Label      string // The text to display
+	Detail     string // Additional detail (e.g., type information)
+	InsertText string // The text to insert when selected
+	Kind       string // Shown before the label, such as "Function", or empty
 }
 
-// CompletionPopup displays a list of code completion options
+// CompletionPopup is a list of choices shown at the cursor, such as insert
+// mode completions or spelling suggestions. The mode showing it owns the
+// popup and moves its selection; a Frame carries it to the frontend.
 type CompletionPopup struct {
 	items         []CompletionItem
 	selectedIndex int
 	visible       bool
-	x, y          int // Position of the popup
-	maxHeight     int // Maximum height of the popup
-	maxWidth      int // Maximum width of the popup
+	maxHeight     int // Maximum number of items shown
+	maxWidth      int // Maximum width of an item
 }
 
-// NewCompletionPopup creates a new completion popup
+// NewCompletionPopup creates a new, hidden completion popup
 func NewCompletionPopup() *CompletionPopup {
 	return &CompletionPopup{
-		items:         []CompletionItem{},
-		selectedIndex: 0,
-		visible:       false,
-		maxHeight:     10,
-		maxWidth:      50,
+		maxHeight: 10,
+		maxWidth:  40,
 	}
 }
 
-// SetItems sets the completion items to display
+// SetItems shows items with the first selected, or hides the popup when
+// there are none
 func (p *CompletionPopup) SetItems(items []CompletionItem) {
 	p.items = items
 	p.selectedIndex = 0
-	if len(items) > 0 {
-		p.visible = true
-	}
-}
-
-// Show displays the popup at the given position
-func (p *CompletionPopup) Show(x, y int) {
-	p.x = x
-	p.y = y
-	if len(p.items) > 0 {
-		p.visible = true
-	}
+	p.visible = len(items) > 0
 }
 
-// Hide hides the popup
+// Hide hides the popup and forgets its items
 func (p *CompletionPopup) Hide() {
+	p.items = nil
+	p.selectedIndex = 0
 	p.visible = false
 }
 
@@ -61,17 +51,42 @@ func (p *CompletionPopup) IsVisible() bool {
 	return p.visible
 }
 
-// MoveUp moves the selection up
+// Items returns the items shown
+func (p *CompletionPopup) Items() []CompletionItem {
+	return p.items
+}
+
+// Selected returns the index of the selected item
+func (p *CompletionPopup) Selected() int {
+	return p.selectedIndex
+}
+
+// Select selects item i, keeping the selection within the items
+func (p *CompletionPopup) Select(i int) {
+	p.selectedIndex = max(min(i, len(p.items)-1), 0)
+}
+
+// MoveUp moves the selection up, stopping at the first item
 func (p *CompletionPopup) MoveUp() {
-	if p.selectedIndex > 0 {
-		p.selectedIndex--
-	}
+	p.Select(p.selectedIndex - 1)
 }
 
-// MoveDown moves the selection down
+// MoveDown moves the selection down, stopping at the last item
 func (p *CompletionPopup) MoveDown() {
-	if p.selectedIndex < len(p.items)-1 {
-		p.selectedIndex++
+	p.Select(p.selectedIndex + 1)
+}
+
+// Next moves the selection down, wrapping to the first item
+func (p *CompletionPopup) Next() {
+	if len(p.items) > 0 {
+		p.selectedIndex = (p.selectedIndex + 1) % len(p.items)
+	}
+}
+
+// Prev moves the selection up, wrapping to the last item
+func (p *CompletionPopup) Prev() {
+	if len(p.items) > 0 {
+		p.selectedIndex = (p.selectedIndex + len(p.items) - 1) % len(p.items)
 	}
 }
 
@@ -83,173 +98,83 @@ func (p *CompletionPopup) GetSelectedItem() *CompletionItem {
 	return nil
 }
 
-// Render draws the popup on the screen
-func (p *CompletionPopup) Render(screen *Screen, styles *StyleConfig) {
-	if !p.visible || len(p.items) == 0 {
+// text returns how item is listed: "kind: label"
+func (item CompletionItem) text() string {
+	if item.Kind == "" {
+		return item.Label
+	}
+	return item.Kind + ": " + item.Label
+}
+
+// renderCompletionPopup draws popup in a box below the cursor, or above it
+// when there isn't room
+func (r *Renderer) renderCompletionPopup(buf *buffer.Buffer, popup *CompletionPopup) {
+	if popup == nil || !popup.IsVisible() || len(popup.items) == 0 {
 		return
 	}
 
-	// Calculate popup dimensions
-	width := p.calculateWidth()
-	height := p.calculateHeight()
+	cursor := buf.Cursor()
+	x := cursor.Col - r.viewport.StartCol + 1
+	y := cursor.Line - r.viewport.StartLine + 1
+	height := min(len(popup.items), popup.maxHeight)
+	// Scroll so the selected item is shown
+	first := max(popup.selectedIndex-height+1, 0)
+	items := popup.items[first : first+height]
+	width := 20
+	for _, item := range items {
+		width = max(width, len(item.text())+3)
+	}
+	width = min(width, popup.maxWidth)
 
-	// Adjust position if popup would go off screen
-	screenWidth, screenHeight := screen.Size()
-	if p.x+width > screenWidth {
-		p.x = screenWidth - width
+	screenWidth, screenHeight := r.screen.Size()
+	if x+width >= screenWidth {
+		x = screenWidth - width - 1
 	}
-	if p.y+height > screenHeight {
-		p.y = screenHeight - height
+	if y+height >= screenHeight-1 { // The status line
+		y = max(cursor.Line-r.viewport.StartLine-height, 0)
 	}
 
-	// Draw popup background with border
-	popupStyle := tcell.StyleDefault.Background(tcell.ColorDarkBlue).Foreground(tcell.ColorWhite)
-	selectedStyle := tcell.StyleDefault.Background(tcell.ColorBlue).Foreground(tcell.ColorWhite)
-
-	// Draw border
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			ch := ' '
-			if y == 0 || y == height-1 {
-				// Top and bottom border
-				if x == 0 && y == 0 {
-					ch = '┌'
-				} else if x == width-1 && y == 0 {
-					ch = '┐'
-				} else if x == 0 && y == height-1 {
-					ch = '└'
-				} else if x == width-1 && y == height-1 {
-					ch = '┘'
-				} else {
-					ch = '─'
-				}
-			} else if x == 0 || x == width-1 {
-				// Side borders
-				ch = '│'
+	normalStyle := r.styles.Normal
+	selectedStyle := r.styles.Selected
+	borderStyle := r.styles.LineNumber
+	glyphs := r.screen.caps.Glyphs()
+	for row := 0; row < height+2; row++ {
+		for col := 0; col < width+2; col++ {
+			ch, style := ' ', borderStyle
+			top, bottom := row == 0, row == height+1
+			left, right := col == 0, col == width+1
+			switch {
+			case top && left:
+				ch = glyphs.TopLeft
+			case top && right:
+				ch = glyphs.TopRight
+			case bottom && left:
+				ch = glyphs.BottomLeft
+			case bottom && right:
+				ch = glyphs.BottomRight
+			case top || bottom:
+				ch = glyphs.Horizontal
+			case left || right:
+				ch = glyphs.Vertical
+			default:
+				style = normalStyle
 			}
-			screen.SetCell(p.x+x, p.y+y, ch, popupStyle)
+			r.screen.SetCell(x+col, y+row, ch, style)
 		}
 	}
 
-	// Draw items
-	for i, item := range p.items {
-		if i >= height-2 {
-			break // Don't draw more items than can fit
-		}
-
-		style := popupStyle
-		if i == p.selectedIndex {
+	for i, item := range items {
+		style := normalStyle
+		if first+i == popup.selectedIndex {
 			style = selectedStyle
 		}
-
-		// Draw item label
-		label := item.Label
-		kindStr := getKindString(item.Kind)
-		if kindStr != "" {
-			label = kindStr + ": " + label
-		}
-
-		// Truncate if too long
-		maxLabelWidth := width - 4 // Account for borders and padding
-		if len(label) > maxLabelWidth {
-			label = label[:maxLabelWidth-3] + "..."
-		}
-
-		// Clear the line first
-		for x := 1; x < width-1; x++ {
-			screen.SetCell(p.x+x, p.y+i+1, ' ', style)
-		}
-
-		// Draw the label
-		screen.SetText(p.x+2, p.y+i+1, label, style)
-	}
-}
-
-// calculateWidth calculates the popup width based on content
-func (p *CompletionPopup) calculateWidth() int {
-	maxLen := 20 // Minimum width
-	for _, item := range p.items {
-		label := item.Label
-		kindStr := getKindString(item.Kind)
-		if kindStr != "" {
-			label = kindStr + ": " + label
+		text := item.text()
+		if len(text) > width {
+			text = text[:width-3] + "..."
 		}
-		if len(label) > maxLen {
-			maxLen = len(label)
+		for col := 1; col < width+1; col++ {
+			r.screen.SetCell(x+col, y+i+1, ' ', style)
 		}
+		r.screen.SetText(x+2, y+i+1, text, style)
 	}
-
-	// Add padding and borders
-	width := maxLen + 4
-	if width > p.maxWidth {
-		width = p.maxWidth
-	}
-	return width
 }
-
-// calculateHeight calculates the popup height based on content
-func (p *CompletionPopup) calculateHeight() int {
-	height := len(p.items) + 2 // Add 2 for borders
-	if height > p.maxHeight {
-		height = p.maxHeight
-	}
-	return height
-}
-
-// getKindString converts LSP completion kind to string
-func getKindString(kind int) string {
-	switch kind {
-	case 1:
-		return "text"
-	case 2:
-		return "method"
-	case 3:
-		return "func"
-	case 4:
-		return "ctor"
-	case 5:
-		return "field"
-	case 6:
-		return "var"
-	case 7:
-		return "class"
-	case 8:
-		return "iface"
-	case 9:
-		return "module"
-	case 10:
-		return "prop"
-	case 11:
-		return "unit"
-	case 12:
-		return "value"
-	case 13:
-		return "enum"
-	case 14:
-		return "keyword"
-	case 15:
-		return "snippet"
-	case 16:
-		return "color"
-	case 17:
-		return "file"
-	case 18:
-		return "ref"
-	case 19:
-		return "folder"
-	case 20:
-		return "enum"
-	case 21:
-		return "const"
-	case 22:
-		return "struct"
-	case 23:
-		return "event"
-	case 24:
-		return "op"
-	case 25:
-		return "type"
-	default:
-		return ""
-	}
-}
\ No newline at end of file
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestCompletionPopup_Selection(t *testing.T) {
+	p := NewCompletionPopup()
+	p.SetItems([]CompletionItem{{Label: "a"}, {Label: "b"}, {Label: "c"}})
+	if !p.IsVisible() || p.GetSelectedItem().Label != "a" {
+		t.Fatalf("expected the first item selected, got %+v", p.GetSelectedItem())
+	}
+
+	p.MoveUp()
+	if p.Selected() != 0 {
+		t.Errorf("MoveUp past the first item selected %d", p.Selected())
+	}
+	p.Prev()
+	if p.Selected() != 2 {
+		t.Errorf("Prev from the first item selected %d, expected to wrap to 2", p.Selected())
+	}
+	p.MoveDown()
+	if p.Selected() != 2 {
+		t.Errorf("MoveDown past the last item selected %d", p.Selected())
+	}
+	p.Next()
+	if p.Selected() != 0 {
+		t.Errorf("Next from the last item selected %d, expected to wrap to 0", p.Selected())
+	}
+
+	p.SetItems(nil)
+	if p.IsVisible() || p.GetSelectedItem() != nil {
+		t.Error("expected no items to hide the popup")
+	}
+}
+
+func TestUI_DrawCompletionScrollsToSelection(t *testing.T) {
+	u, sim := newSimulatedUI(t, 40, 20)
+	buf := buffer.New()
+
+	var items []CompletionItem
+	for i := range 15 {
+		items = append(items, CompletionItem{Label: fmt.Sprintf("item%02d", i)})
+	}
+	popup := NewCompletionPopup()
+	popup.SetItems(items)
+	popup.Select(12)
+	u.Draw(&Frame{Buffer: buf, Completion: popup})
+
+	// Ten items fit, so the last row shows the selected one
+	if row := screenRow(sim, 11); !strings.Contains(row, "item12") {
+		t.Errorf("last popup row = %q", row)
+	}
+	popup.Hide()
+	u.Draw(&Frame{Buffer: buf, Completion: popup})
+	if row := screenRow(sim, 11); strings.Contains(row, "item") {
+		t.Errorf("expected a hidden popup not to be drawn, got %q", row)
+	}
+}
@@ -0,0 +1,86 @@
+package ui
+
+// minComposerRows is the fewest lines of text the composer shows
+const minComposerRows = 8
+
+// Composer is the prompt composer drawn over the middle of the buffer: the
+// lines of a prompt being written, with what sending it runs on the top
+// border and the keys it takes on the bottom one
+type Composer struct {
+	Title string // Like ":ai"
+	Lines []string
+	Line  int    // Line of the cursor in Lines
+	Col   int    // Column of the cursor, in characters
+	Hint  string // Keys that send, close and browse the history
+}
+
+// SetComposer shows c over the buffer, or hides the composer when c is nil
+func (ui *UI) SetComposer(c *Composer) {
+	ui.renderer.composer = c
+}
+
+// renderComposer draws the composer centered on the screen, scrolled so
+// the cursor is visible
+func (r *Renderer) renderComposer() {
+	c := r.composer
+	if c == nil {
+		return
+	}
+	screenWidth, screenHeight := r.screen.Size()
+	width := min(screenWidth-2, max(60, screenWidth*2/3))
+	rows := min(max(len(c.Lines), minComposerRows), screenHeight-4)
+	if width < 10 || rows < 1 {
+		return
+	}
+	left := (screenWidth - width) / 2
+	top := (screenHeight - rows - 2) / 2
+
+	box := r.styles.Normal
+	border := r.styles.LineNumber
+	glyphs := r.screen.caps.Glyphs()
+	for y := 0; y < rows+2; y++ {
+		for x := -1; x <= width; x++ {
+			ch := ' '
+			switch {
+			case y == 0 || y == rows+1:
+				ch = glyphs.Horizontal
+			case x == -1 || x == width:
+				ch = glyphs.Vertical
+			}
+			r.screen.SetCell(left+x, top+y, ch, border)
+		}
+	}
+	if c.Title != "" {
+		r.screen.SetText(left+1, top, " "+c.Title+" ", border)
+	}
+	if hint := []rune(" " + c.Hint + " "); c.Hint != "" && len(hint) < width-2 {
+		r.screen.SetText(left+width-1-len(hint), top+rows+1, string(hint), border)
+	}
+
+	// Long lines scroll sideways with the cursor
+	offset := 0
+	if c.Line >= rows {
+		offset = c.Line - rows + 1
+	}
+	shift := max(c.Col-(width-3), 0)
+	for row := 0; row < rows && offset+row < len(c.Lines); row++ {
+		line := []rune(c.Lines[offset+row])
+		if shift < len(line) {
+			line = line[shift:]
+		} else {
+			line = nil
+		}
+		if len(line) > width-2 {
+			line = line[:width-2]
+		}
+		r.screen.SetText(left+1, top+1+row, string(line), box)
+	}
+	if y := c.Line - offset; y >= 0 && y < rows {
+		x := c.Col - shift
+		ch := ' '
+		if line := []rune(c.Lines[c.Line]); c.Col < len(line) {
+			ch = line[c.Col]
+		}
+		r.screen.SetCell(left+1+x, top+1+y, ch, r.styles.Cursor)
+	}
+}
@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/dshills/aied/internal/diff"
+)
+
+// DiffView is another buffer shown side by side with the edited one, with
+// the lines of both aligned and the differences highlighted
+type DiffView struct {
+	Other *buffer.Buffer
+	Title string // Name of the other side shown in the status line
+}
+
+// SetDiffView compares the edited buffer with v.Other, or shows the buffer
+// alone when v is nil
+func (ui *UI) SetDiffView(v *DiffView) {
+	ui.renderer.diff = v
+}
+
+// renderDiff draws the edited buffer on the left and the other buffer on
+// the right, with filler lines facing lines only one side has. The viewport
+// scrolls through rows rather than lines so both sides stay aligned.
+func (r *Renderer) renderDiff(buf *buffer.Buffer) {
+	lines, otherLines := buf.Lines(), r.diff.Other.Lines()
+	rows := diff.Align(diff.Lines(lines, otherLines), len(lines))
+	cursor := buf.Cursor()
+
+	cursorRow := 0
+	for i, row := range rows {
+		if row.A == cursor.Line {
+			cursorRow = i
+			break
+		}
+	}
+
+	// The panes share the width around a separator column
+	width := r.viewport.Width
+	paneWidth := max((width-1)/2, 1)
+	r.viewport.Width = paneWidth
+	r.adjustViewport(buffer.Position{Line: cursorRow, Col: cursor.Col}, len(rows))
+	r.viewport.Width = width
+
+	for screenY := 0; screenY < r.viewport.Height; screenY++ {
+		r.screen.SetCell(paneWidth, screenY, r.screen.caps.Glyphs().Vertical, r.styles.LineNumber)
+		i := r.viewport.StartLine + screenY
+		if i >= len(rows) {
+			r.renderDiffPane(0, screenY, paneWidth, "", -1, diff.RowEqual, nil, nil)
+			r.renderDiffPane(paneWidth+1, screenY, width-paneWidth-1, "", -1, diff.RowEqual, nil, nil)
+			continue
+		}
+
+		row := rows[i]
+		var left, right string
+		if row.A >= 0 {
+			left = lines[row.A]
+		}
+		if row.B >= 0 {
+			right = otherLines[row.B]
+		}
+		var leftSpans, rightSpans []diff.Span
+		if row.Kind == diff.RowChanged {
+			leftSpans, rightSpans = diff.Runes(left, right)
+		}
+		r.renderDiffPane(0, screenY, paneWidth, left, row.A, row.Kind, leftSpans, &cursor)
+		r.renderDiffPane(paneWidth+1, screenY, width-paneWidth-1, right, row.B, row.Kind, rightSpans, nil)
+	}
+}
+
+// renderDiffPane draws line lineNum of one side of a diff in width columns
+// from x. A line of -1 is a filler line, or past the end when kind is
+// RowEqual. The cursor is drawn when given.
+func (r *Renderer) renderDiffPane(x, y, width int, line string, lineNum int, kind diff.RowKind, spans []diff.Span, cursor *buffer.Position) {
+	style := r.styles.Normal
+	fill := ' '
+	switch {
+	case lineNum < 0 && kind != diff.RowEqual:
+		style, fill = r.styles.DiffDelete, '-'
+	case kind == diff.RowChanged:
+		style = r.styles.DiffChange
+	case kind != diff.RowEqual:
+		style = r.styles.DiffAdd
+	}
+
+	runes := []rune(line)
+	offsets := make([]int, 0, len(runes))
+	for offset := range line {
+		offsets = append(offsets, offset)
+	}
+
+	for screenX := 0; screenX < width; screenX++ {
+		col := r.viewport.StartCol + screenX
+		ch, cellStyle := fill, style
+		if col < len(runes) {
+			ch = runes[col]
+			if inSpans(offsets[col], spans) {
+				cellStyle = r.styles.DiffText
+			}
+		}
+		if cursor != nil && lineNum == cursor.Line && col == cursor.Col {
+			cellStyle = r.styles.Cursor
+			if col >= len(runes) {
+				ch = ' '
+			}
+		}
+		r.screen.SetCell(x+screenX, y, ch, cellStyle)
+	}
+}
+
+// inSpans reports whether a byte offset is within one of spans
+func inSpans(offset int, spans []diff.Span) bool {
+	for _, span := range spans {
+		if offset >= span.Start && offset < span.End {
+			return true
+		}
+	}
+	return false
+}
@@ -30,6 +30,20 @@ const (
 	KeyActionCtrlQ
 	KeyActionCtrlZ
 	KeyActionCtrlSpace
+	KeyActionCtrlW
+	KeyActionCtrlU
+	KeyActionCtrlT
+	KeyActionCtrlA
+	KeyActionCtrlX
+	KeyActionCtrlN
+	KeyActionCtrlP
+	KeyActionCtrlF
+	KeyActionCtrlR
+	KeyActionCtrlG
+	KeyActionAltUp   // Alt-k or Alt-Up
+	KeyActionAltDown // Alt-j or Alt-Down
+	KeyActionBacktab // Shift-Tab
+	KeyActionCtrlEnter // Ctrl-Enter, which many terminals send as Ctrl-J
 	KeyActionResize
 )
 
@@ -47,6 +61,15 @@ type ResizeEvent struct {
 	Height int
 }
 
+// RedrawEvent asks the main loop to render again, e.g. when background output arrives
+type RedrawEvent struct{}
+
+// CallEvent asks the main loop to run Fn, for other goroutines that need
+// to touch the editor's state
+type CallEvent struct {
+	Fn func()
+}
+
 // EventProcessor handles terminal events and converts them to editor events
 type EventProcessor struct {
 	screen *Screen
@@ -67,6 +90,12 @@ func (ep *EventProcessor) ProcessEvent(event tcell.Event) interface{} {
 	case *tcell.EventResize:
 		return ep.processResizeEvent(ev)
 	case *tcell.EventInterrupt:
+		switch data := ev.Data().(type) {
+		case RedrawEvent:
+			return RedrawEvent{}
+		case CallEvent:
+			return data
+		}
 		return KeyEvent{Action: KeyActionQuit}
 	default:
 		return nil
@@ -87,8 +116,15 @@ func (ep *EventProcessor) processKeyEvent(ev *tcell.EventKey) KeyEvent {
 		keyEvent.Action = KeyActionEscape
 	case tcell.KeyEnter:
 		keyEvent.Action = KeyActionEnter
+		if ev.Modifiers()&tcell.ModCtrl != 0 {
+			keyEvent.Action = KeyActionCtrlEnter
+		}
+	case tcell.KeyLF:
+		keyEvent.Action = KeyActionCtrlEnter
 	case tcell.KeyTab:
 		keyEvent.Action = KeyActionTab
+	case tcell.KeyBacktab:
+		keyEvent.Action = KeyActionBacktab
 	case tcell.KeyBackspace, tcell.KeyBackspace2:
 		keyEvent.Action = KeyActionBackspace
 	case tcell.KeyDelete:
@@ -119,6 +155,26 @@ func (ep *EventProcessor) processKeyEvent(ev *tcell.EventKey) KeyEvent {
 		keyEvent.Action = KeyActionCtrlQ
 	case tcell.KeyCtrlZ:
 		keyEvent.Action = KeyActionCtrlZ
+	case tcell.KeyCtrlW:
+		keyEvent.Action = KeyActionCtrlW
+	case tcell.KeyCtrlU:
+		keyEvent.Action = KeyActionCtrlU
+	case tcell.KeyCtrlT:
+		keyEvent.Action = KeyActionCtrlT
+	case tcell.KeyCtrlA:
+		keyEvent.Action = KeyActionCtrlA
+	case tcell.KeyCtrlX:
+		keyEvent.Action = KeyActionCtrlX
+	case tcell.KeyCtrlN:
+		keyEvent.Action = KeyActionCtrlN
+	case tcell.KeyCtrlP:
+		keyEvent.Action = KeyActionCtrlP
+	case tcell.KeyCtrlF:
+		keyEvent.Action = KeyActionCtrlF
+	case tcell.KeyCtrlR:
+		keyEvent.Action = KeyActionCtrlR
+	case tcell.KeyCtrlG:
+		keyEvent.Action = KeyActionCtrlG
 	case tcell.KeyNUL:
 		// Ctrl+Space
 		if ev.Modifiers()&tcell.ModCtrl != 0 {
@@ -133,6 +189,16 @@ func (ep *EventProcessor) processKeyEvent(ev *tcell.EventKey) KeyEvent {
 		keyEvent.Action = KeyActionNone
 	}
 
+	// Alt-j/k and Alt-Up/Down move lines
+	if ev.Modifiers()&tcell.ModAlt != 0 {
+		switch {
+		case keyEvent.Action == KeyActionUp, keyEvent.Action == KeyActionChar && ev.Rune() == 'k':
+			keyEvent.Action = KeyActionAltUp
+		case keyEvent.Action == KeyActionDown, keyEvent.Action == KeyActionChar && ev.Rune() == 'j':
+			keyEvent.Action = KeyActionAltDown
+		}
+	}
+
 	// Handle Ctrl+C as quit
 	if ev.Key() == tcell.KeyCtrlC {
 		keyEvent.Action = KeyActionQuit
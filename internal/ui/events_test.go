@@ -29,6 +29,7 @@ func TestEventProcessor_ProcessKeyEvent(t *testing.T) {
 		{"End key", tcell.KeyEnd, 0, KeyActionEnd},
 		{"Ctrl+C", tcell.KeyCtrlC, 0, KeyActionQuit},
 		{"Ctrl+S", tcell.KeyCtrlS, 0, KeyActionCtrlS},
+		{"Ctrl+J", tcell.KeyLF, 0, KeyActionCtrlEnter},
 		{"Character 'a'", tcell.KeyRune, 'a', KeyActionChar},
 		{"Character 'Z'", tcell.KeyRune, 'Z', KeyActionChar},
 		{"Space", tcell.KeyRune, ' ', KeyActionChar},
@@ -50,6 +51,30 @@ func TestEventProcessor_ProcessKeyEvent(t *testing.T) {
 	}
 }
 
+func TestEventProcessor_AltKeys(t *testing.T) {
+	processor := NewEventProcessor(&Screen{})
+	tests := []struct {
+		key  tcell.Key
+		rune rune
+		mods tcell.ModMask
+		want KeyAction
+	}{
+		{tcell.KeyRune, 'j', tcell.ModAlt, KeyActionAltDown},
+		{tcell.KeyRune, 'k', tcell.ModAlt, KeyActionAltUp},
+		{tcell.KeyDown, 0, tcell.ModAlt, KeyActionAltDown},
+		{tcell.KeyUp, 0, tcell.ModAlt, KeyActionAltUp},
+		{tcell.KeyRune, 'x', tcell.ModAlt, KeyActionChar},
+		{tcell.KeyRune, 'j', tcell.ModNone, KeyActionChar},
+	}
+
+	for _, tt := range tests {
+		result := processor.processKeyEvent(tcell.NewEventKey(tt.key, tt.rune, tt.mods))
+		if result.Action != tt.want {
+			t.Errorf("key %v rune %q mods %v: expected action %v, got %v", tt.key, tt.rune, tt.mods, tt.want, result.Action)
+		}
+	}
+}
+
 func TestEventProcessor_ProcessResizeEvent(t *testing.T) {
 	screen := &Screen{width: 40, height: 12} // Set initial size
 	processor := NewEventProcessor(screen)
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"github.com/dshills/aied/internal/buffer"
+)
+
+// Frontend is what the editing core needs of a user interface: events come
+// in through WaitForEvent and every screen goes out as one Frame. The tcell
+// UI is one frontend; a GUI or web frontend implements the same interface
+// to run on the same buffers, modes, commands, LSP and AI.
+type Frontend interface {
+	// WaitForEvent blocks until the next KeyEvent, ResizeEvent,
+	// RedrawEvent or CallEvent
+	WaitForEvent() interface{}
+	// HandleResize adapts the layout to a ResizeEvent
+	HandleResize(event ResizeEvent)
+	// Draw shows frame, replacing the last one
+	Draw(frame *Frame)
+	// GetSize returns the size of the frontend in cells
+	GetSize() (int, int)
+	// GetViewport returns the part of the buffer the last frame showed
+	GetViewport() Viewport
+
+	// RequestRedraw and Call wake WaitForEvent from other goroutines with
+	// a RedrawEvent, or a CallEvent running fn
+	RequestRedraw()
+	Call(fn func())
+	// RunInTerminal gives the terminal to run, for programs that need it
+	RunInTerminal(run func() error) error
+	// ShowActivity redraws just the activity on the status line, from any
+	// goroutine, to keep its spinner turning while the main loop is busy
+	ShowActivity(text string)
+
+	RenderOptions() RenderOptions
+	SetRenderOptions(options RenderOptions)
+	SetStyles(styles *StyleConfig)
+	AddHighlighter(h Highlighter)
+
+	IsRunning() bool
+	PostQuit()
+	Close()
+}
+
+var _ Frontend = (*UI)(nil)
+
+// Frame is everything one screen of the editor shows. Nil fields are not
+// shown.
+type Frame struct {
+	Buffer        *buffer.Buffer
+	Status        string // The mode and whatever else the status line says
+	Pending       string // Unfinished command keys and macro recording, right-aligned
+	Activity      string // Work running in the background, left of Pending
+	CommandLine   string // What has been typed in command mode
+	CommandCursor int    // Column of the cursor in CommandLine
+	Message       string // The last command's message, or a mode's until the next key
+	CommandMode   bool   // Whether the command line is shown
+	Title         string // The window title; "" leaves it as it is
+	Dir           string // The working directory reported to the terminal; "" reports none
+	Ruler         bool   // Whether the status line shows the line count, byte offset and scroll position
+
+	Panel      *Panel // Build output, quickfix lists, the undo tree, help...
+	Diff       *DiffView
+	Palette    *Palette
+	Composer   *Composer
+	Suggestion *Suggestion // Shown at the cursor, to accept or reject
+	KeyHints   *KeyHints
+	Completion *CompletionPopup // Shown at the cursor when visible
+}
+
+// Draw shows frame on the terminal
+func (ui *UI) Draw(frame *Frame) {
+	ui.SetPanel(frame.Panel)
+	ui.SetDiffView(frame.Diff)
+	ui.SetPalette(frame.Palette)
+	ui.SetComposer(frame.Composer)
+	ui.SetSuggestion(frame.Suggestion)
+	ui.SetKeyHints(frame.KeyHints)
+	ui.renderer.pending = frame.Pending
+	ui.renderer.activity = frame.Activity
+	ui.renderer.ruler = frame.Ruler
+	ui.renderer.cmdCursor = frame.CommandCursor
+	ui.setTitle(frame.Title)
+	ui.reportDir(frame.Dir)
+	commandLine := ""
+	if frame.CommandMode {
+		commandLine = frame.CommandLine
+	}
+	ui.draw(frame.Buffer, frame.Status, commandLine, frame.Message, frame.Completion)
+}
+
+// ShowActivity draws text where the last frame's activity was, leaving the
+// rest of the screen as it is
+func (ui *UI) ShowActivity(text string) {
+	ui.drawing.Lock()
+	defer ui.drawing.Unlock()
+	ui.renderer.drawActivity(text)
+	ui.screen.Show()
+}
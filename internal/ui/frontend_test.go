@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+	"github.com/gdamore/tcell/v2"
+)
+
+// newSimulatedUI creates a UI drawing to an in-memory screen
+func newSimulatedUI(t testing.TB, width, height int) (*UI, tcell.SimulationScreen) {
+	t.Helper()
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	sim.SetSize(width, height)
+	t.Cleanup(sim.Fini)
+	screen := &Screen{tcellScreen: sim, width: width, height: height, running: true}
+	return &UI{
+		screen:    screen,
+		renderer:  NewRenderer(screen),
+		processor: NewEventProcessor(screen),
+		running:   true,
+	}, sim
+}
+
+// screenRow returns the text of row y of sim
+func screenRow(sim tcell.SimulationScreen, y int) string {
+	cells, width, _ := sim.GetContents()
+	var row strings.Builder
+	for _, cell := range cells[y*width : (y+1)*width] {
+		if len(cell.Runes) > 0 {
+			row.WriteRune(cell.Runes[0])
+		}
+	}
+	return row.String()
+}
+
+func TestUI_DrawFrame(t *testing.T) {
+	u, sim := newSimulatedUI(t, 40, 10)
+	buf := buffer.New()
+	buf.InsertTextAt(0, 0, "hello")
+
+	popup := NewCompletionPopup()
+	popup.SetItems([]CompletionItem{{Label: "Println", Kind: "func"}})
+	u.Draw(&Frame{
+		Buffer:      buf,
+		Status:      "COMMAND",
+		CommandMode: true,
+		CommandLine: "wq",
+		Completion:  popup,
+	})
+
+	if row := screenRow(sim, 0); !strings.Contains(row, "hello") {
+		t.Errorf("first row = %q", row)
+	}
+	if row := screenRow(sim, 2); !strings.Contains(row, "func: Println") {
+		t.Errorf("completion popup row = %q", row)
+	}
+	if row := screenRow(sim, 9); !strings.HasPrefix(row, "wq") {
+		t.Errorf("status row = %q", row)
+	}
+}
+
+func TestUI_Call(t *testing.T) {
+	u, _ := newSimulatedUI(t, 40, 10)
+	called := false
+	u.Call(func() { called = true })
+
+	ev, ok := u.WaitForEvent().(CallEvent)
+	if !ok {
+		t.Fatalf("expected a CallEvent")
+	}
+	ev.Fn()
+	if !called {
+		t.Error("expected the function run")
+	}
+}
+
+func TestUI_DrawPendingAtRight(t *testing.T) {
+	u, sim := newSimulatedUI(t, 40, 5)
+	u.Draw(&Frame{Buffer: buffer.New(), Pending: `"a2d`})
+
+	if row := screenRow(sim, 4); !strings.HasSuffix(row, `"a2d `) {
+		t.Errorf("status row = %q", row)
+	}
+}
+
+func TestUI_DrawActivity(t *testing.T) {
+	u, sim := newSimulatedUI(t, 60, 5)
+	u.Draw(&Frame{Buffer: buffer.New(), Pending: "2d", Activity: "⠋ lsp hover"})
+
+	if row := screenRow(sim, 4); !strings.HasSuffix(row, "⠋ lsp hover  2d ") {
+		t.Errorf("status row = %q", row)
+	}
+
+	// While the main loop is busy the spinner is drawn on its own
+	u.ShowActivity("⠙ grep")
+	sim.Show()
+	if row := screenRow(sim, 4); !strings.HasSuffix(row, "     ⠙ grep  2d ") {
+		t.Errorf("status row after ShowActivity = %q", row)
+	}
+}
+
+func TestUI_DrawRuler(t *testing.T) {
+	u, sim := newSimulatedUI(t, 80, 5)
+	buf := buffer.New()
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	buf.ReplaceLines(0, 0, lines)
+
+	tests := []struct {
+		line int
+		want string
+	}{
+		{0, "Line: 1 of 20, Col: 1, Byte: 1 - Top"},
+		{10, "Line: 11 of 20, Col: 1, Byte: 51 - 43%"},
+		{19, "Line: 20 of 20, Col: 1, Byte: 96 - Bot"},
+	}
+	for _, tt := range tests {
+		buf.SetCursor(buffer.Position{Line: tt.line})
+		u.Draw(&Frame{Buffer: buf, Ruler: true})
+		if row := screenRow(sim, 4); !strings.Contains(row, tt.want) {
+			t.Errorf("status row at line %d = %q, want %q", tt.line+1, row, tt.want)
+		}
+	}
+
+	buf.SetCursor(buffer.Position{Line: 10})
+	u.Draw(&Frame{Buffer: buf})
+	if row := screenRow(sim, 4); !strings.Contains(row, "Line: 11, Col: 1") || strings.Contains(row, "Byte") {
+		t.Errorf("status row without the ruler = %q", row)
+	}
+}
+
+func TestUI_DrawCommandCursor(t *testing.T) {
+	u, sim := newSimulatedUI(t, 40, 5)
+	u.Draw(&Frame{Buffer: buffer.New(), CommandMode: true, CommandLine: ":wq", CommandCursor: 2})
+	if row := screenRow(sim, 4); !strings.HasPrefix(row, ":wq") {
+		t.Errorf("command row = %q", row)
+	}
+	cells, width, _ := sim.GetContents()
+	if cells[4*width+2].Style != u.renderer.styles.Cursor {
+		t.Error("the command line cursor isn't drawn on the q")
+	}
+}
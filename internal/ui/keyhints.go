@@ -0,0 +1,79 @@
+package ui
+
+// KeyHints lists the keys that may follow the pending keys of a command,
+// drawn above the status line
+type KeyHints struct {
+	Pending string // Keys typed so far
+	Items   []KeyHint
+}
+
+// KeyHint is a key that may be typed next, with what it does
+type KeyHint struct {
+	Keys        string
+	Description string
+}
+
+// maxKeyHintWidth is the widest column of hints
+const maxKeyHintWidth = 40
+
+// SetKeyHints shows h above the status line, or hides the hints when h is nil
+func (ui *UI) SetKeyHints(h *KeyHints) {
+	ui.renderer.keyHints = h
+}
+
+// renderKeyHints draws the hints in columns at the bottom of the buffer
+// area, as many as fit
+func (r *Renderer) renderKeyHints() {
+	h := r.keyHints
+	if h == nil || len(h.Items) == 0 {
+		return
+	}
+	screenWidth, screenHeight := r.screen.Size()
+
+	keysWidth := 0
+	itemWidth := 0
+	for _, item := range h.Items {
+		keysWidth = max(keysWidth, len([]rune(item.Keys)))
+	}
+	for _, item := range h.Items {
+		itemWidth = max(itemWidth, keysWidth+3+len([]rune(item.Description)))
+	}
+	colWidth := min(itemWidth, maxKeyHintWidth) + 2
+	cols := max(1, screenWidth/colWidth)
+	rows := (len(h.Items) + cols - 1) / cols
+	rows = min(rows, screenHeight-3)
+	if rows < 1 {
+		return
+	}
+	top := screenHeight - 2 - rows
+
+	border := r.styles.StatusLine
+	box := r.styles.Normal
+	keys := r.styles.Normal.Bold(true)
+
+	for x := 0; x < screenWidth; x++ {
+		r.screen.SetCell(x, top, ' ', border)
+	}
+	r.screen.SetText(0, top, " "+h.Pending, border)
+	for row := 0; row < rows; row++ {
+		y := top + 1 + row
+		for x := 0; x < screenWidth; x++ {
+			r.screen.SetCell(x, y, ' ', box)
+		}
+		for col := 0; col < cols; col++ {
+			i := col*rows + row
+			if i >= len(h.Items) {
+				break
+			}
+			item := h.Items[i]
+			x := col*colWidth + 1
+			r.screen.SetText(x, y, item.Keys, keys)
+			desc := []rune(r.screen.caps.Glyphs().Arrow + " " + item.Description)
+			room := colWidth - 2 - keysWidth - 1
+			if len(desc) > room {
+				desc = append(desc[:max(room-3, 0)], []rune("...")...)
+			}
+			r.screen.SetText(x+keysWidth+1, y, string(desc), box)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// namedKeys are the keys written as <Name> in ParseKeys, by lower case name
+var namedKeys = map[string]tcell.Key{
+	"esc":      tcell.KeyEscape,
+	"cr":       tcell.KeyEnter,
+	"enter":    tcell.KeyEnter,
+	"return":   tcell.KeyEnter,
+	"tab":      tcell.KeyTab,
+	"s-tab":    tcell.KeyBacktab,
+	"bs":       tcell.KeyBackspace2,
+	"del":      tcell.KeyDelete,
+	"up":       tcell.KeyUp,
+	"down":     tcell.KeyDown,
+	"left":     tcell.KeyLeft,
+	"right":    tcell.KeyRight,
+	"home":     tcell.KeyHome,
+	"end":      tcell.KeyEnd,
+	"pageup":   tcell.KeyPgUp,
+	"pagedown": tcell.KeyPgDn,
+}
+
+// ParseKeys turns keys written as in Vim mappings into the events typing
+// them would give: characters stand for themselves, and <Esc>, <CR>, <BS>,
+// <Tab>, <S-Tab>, <Up>, <C-r>, <A-j>, <Space> and <lt> for the rest
+func ParseKeys(keys string) ([]KeyEvent, error) {
+	var events []KeyEvent
+	ep := &EventProcessor{}
+	for rest := keys; rest != ""; {
+		if rest[0] == '<' {
+			if end := strings.IndexByte(rest, '>'); end > 1 {
+				ev, err := namedKey(rest[1:end])
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, ep.processKeyEvent(ev))
+				rest = rest[end+1:]
+				continue
+			}
+		}
+		r := []rune(rest)[0]
+		events = append(events, ep.processKeyEvent(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)))
+		rest = rest[len(string(r)):]
+	}
+	return events, nil
+}
+
+// namedKey returns the event of the key written <name>
+func namedKey(name string) (*tcell.EventKey, error) {
+	lower := strings.ToLower(name)
+	if key, ok := namedKeys[lower]; ok {
+		return tcell.NewEventKey(key, 0, tcell.ModNone), nil
+	}
+	switch lower {
+	case "space":
+		return tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone), nil
+	case "lt":
+		return tcell.NewEventKey(tcell.KeyRune, '<', tcell.ModNone), nil
+	}
+
+	if len(lower) == 3 && lower[1] == '-' {
+		r := rune(lower[2])
+		switch lower[0] {
+		case 'c':
+			if r >= 'a' && r <= 'z' {
+				return tcell.NewEventKey(tcell.KeyCtrlA+tcell.Key(r-'a'), 0, tcell.ModCtrl), nil
+			}
+			if r == '@' || r == ' ' {
+				return tcell.NewEventKey(tcell.KeyNUL, 0, tcell.ModCtrl), nil
+			}
+		case 'a', 'm':
+			return tcell.NewEventKey(tcell.KeyRune, rune(name[2]), tcell.ModAlt), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key <%s>", name)
+}
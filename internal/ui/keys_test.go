@@ -0,0 +1,29 @@
+package ui
+
+import "testing"
+
+func TestParseKeys(t *testing.T) {
+	events, err := ParseKeys("ix<lt><Esc>:w<CR><C-r><A-j>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KeyAction{KeyActionChar, KeyActionChar, KeyActionChar, KeyActionEscape, KeyActionChar, KeyActionChar, KeyActionEnter, KeyActionCtrlR, KeyActionAltDown}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, ev := range events {
+		if ev.Action != want[i] {
+			t.Errorf("event %d: action %v, want %v", i, ev.Action, want[i])
+		}
+	}
+	if events[2].Rune != '<' {
+		t.Errorf("<lt> gave %q", events[2].Rune)
+	}
+
+	if _, err := ParseKeys("<Nope>"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+	if events, _ := ParseKeys("a<b"); len(events) != 3 {
+		t.Errorf("a lone < should be typed, got %d events", len(events))
+	}
+}
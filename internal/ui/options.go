@@ -0,0 +1,89 @@
+package ui
+
+// ListChars defines the glyphs used to make whitespace visible in list mode
+type ListChars struct {
+	Tab   rune // Shown in place of a tab character
+	Trail rune // Shown in place of trailing spaces
+	Nbsp  rune // Shown in place of non-breaking spaces
+}
+
+// DefaultListChars returns the default whitespace glyphs
+func DefaultListChars() ListChars {
+	return ListChars{
+		Tab:   '»',
+		Trail: '·',
+		Nbsp:  '␣',
+	}
+}
+
+// RenderOptions controls optional buffer decorations
+type RenderOptions struct {
+	List      bool      // Show whitespace using ListChars
+	ListChars ListChars // Glyphs used when List is enabled
+
+	ColorColumns []int // 1-based columns drawn as vertical guides
+	CursorLine   bool  // Highlight the line containing the cursor
+	CursorColumn bool  // Highlight the column containing the cursor
+
+	ScrollOff     int // Lines kept visible above and below the cursor
+	SideScrollOff int // Columns kept visible left and right of the cursor
+}
+
+// DefaultRenderOptions returns the default rendering options
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		ListChars: DefaultListChars(),
+	}
+}
+
+// whitespaceInfo describes the whitespace layout of a line
+type whitespaceInfo struct {
+	indentEnd     int  // Rune index where leading indentation ends
+	trailingStart int  // Rune index where trailing whitespace begins
+	mixedIndent   bool // Whether indentation mixes tabs and spaces
+}
+
+// analyzeWhitespace inspects leading and trailing whitespace of a line
+func analyzeWhitespace(runes []rune) whitespaceInfo {
+	info := whitespaceInfo{trailingStart: len(runes)}
+
+	hasTab, hasSpace := false, false
+indent:
+	for ; info.indentEnd < len(runes); info.indentEnd++ {
+		switch runes[info.indentEnd] {
+		case '\t':
+			hasTab = true
+		case ' ':
+			hasSpace = true
+		default:
+			break indent
+		}
+	}
+	info.mixedIndent = hasTab && hasSpace
+
+	for info.trailingStart > 0 && isBlank(runes[info.trailingStart-1]) {
+		info.trailingStart--
+	}
+
+	return info
+}
+
+// isBlank reports whether r is a space, tab or non-breaking space
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\u00a0'
+}
+
+// listRune returns the glyph to display for the rune at index i in list mode
+func (o RenderOptions) listRune(runes []rune, i int, info whitespaceInfo) rune {
+	ch := runes[i]
+	switch {
+	case ch == '\t':
+		return o.ListChars.Tab
+	case ch == '\u00a0':
+		return o.ListChars.Nbsp
+	case ch == ' ' && i >= info.trailingStart:
+		return o.ListChars.Trail
+	default:
+		return ch
+	}
+}
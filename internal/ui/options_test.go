@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestAnalyzeWhitespace(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		indentEnd     int
+		trailingStart int
+		mixed         bool
+	}{
+		{"no whitespace", "code", 0, 4, false},
+		{"space indent", "    code", 4, 8, false},
+		{"mixed indent", "\t  code", 3, 7, true},
+		{"trailing spaces", "code   ", 0, 4, false},
+		{"blank line", "   ", 3, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := analyzeWhitespace([]rune(tt.line))
+			if info.indentEnd != tt.indentEnd {
+				t.Errorf("expected indentEnd %d, got %d", tt.indentEnd, info.indentEnd)
+			}
+			if info.trailingStart != tt.trailingStart {
+				t.Errorf("expected trailingStart %d, got %d", tt.trailingStart, info.trailingStart)
+			}
+			if info.mixedIndent != tt.mixed {
+				t.Errorf("expected mixedIndent %v, got %v", tt.mixed, info.mixedIndent)
+			}
+		})
+	}
+}
+
+func TestRenderOptions_ListRune(t *testing.T) {
+	options := DefaultRenderOptions()
+	runes := []rune("\tx\u00a0y  ")
+	info := analyzeWhitespace(runes)
+
+	expected := []rune{'»', 'x', '␣', 'y', '·', '·'}
+	for i, want := range expected {
+		if got := options.listRune(runes, i, info); got != want {
+			t.Errorf("rune %d: expected %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestApplyColumnGuides(t *testing.T) {
+	r := &Renderer{styles: NewDefaultStyles()}
+	r.SetOptions(RenderOptions{ColorColumns: []int{80, 120}})
+
+	_, guideBg, _ := r.styles.ColorColumn.Decompose()
+	for _, col := range []int{79, 119} {
+		_, bg, _ := r.applyColumnGuides(col, r.styles.Normal).Decompose()
+		if bg != guideBg {
+			t.Errorf("expected guide background at column %d", col+1)
+		}
+	}
+
+	_, normalBg, _ := r.styles.Normal.Decompose()
+	_, bg, _ := r.applyColumnGuides(80, r.styles.Normal).Decompose()
+	if bg != normalBg {
+		t.Error("expected no guide at column 81")
+	}
+}
+
+func TestApplyCursorGuides(t *testing.T) {
+	r := &Renderer{styles: NewDefaultStyles()}
+	r.SetOptions(RenderOptions{CursorLine: true, CursorColumn: true})
+	cursor := buffer.Position{Line: 2, Col: 5}
+
+	_, lineBg, _ := r.styles.CursorLine.Decompose()
+	_, columnBg, _ := r.styles.CursorColumn.Decompose()
+	_, normalBg, _ := r.styles.Normal.Decompose()
+
+	tests := []struct {
+		name string
+		line int
+		col  int
+		bg   tcell.Color
+	}{
+		{"cursor line", 2, 0, lineBg},
+		{"cursor column", 0, 5, columnBg},
+		{"elsewhere", 0, 0, normalBg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, bg, _ := r.applyCursorGuides(tt.line, tt.col, cursor, r.styles.Normal).Decompose()
+			if bg != tt.bg {
+				t.Errorf("expected background %v, got %v", tt.bg, bg)
+			}
+		})
+	}
+}
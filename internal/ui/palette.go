@@ -0,0 +1,124 @@
+package ui
+
+import "slices"
+
+// maxPaletteItems is the most commands the palette lists at once
+const maxPaletteItems = 12
+
+// Palette is the command palette drawn over the top of the buffer: a query
+// line and the commands matching it, or the items of another list under
+// its title
+type Palette struct {
+	Title    string // Shown on the top border when listing something other than commands
+	Query    string
+	Items    []PaletteItem
+	Selected int
+}
+
+// PaletteItem is a command listed by the palette
+type PaletteItem struct {
+	Label   string // Command name
+	Detail  string // What it does
+	Keys    string // Keys that run it, if any
+	Matched []int  // Byte offsets of Label matching the query
+}
+
+// SetPalette shows p over the buffer, or hides the palette when p is nil
+func (ui *UI) SetPalette(p *Palette) {
+	ui.renderer.palette = p
+}
+
+// renderPalette draws the palette centered at the top of the screen,
+// scrolled so the selected item is visible
+func (r *Renderer) renderPalette() {
+	p := r.palette
+	if p == nil {
+		return
+	}
+	screenWidth, screenHeight := r.screen.Size()
+	width := min(screenWidth-2, max(60, screenWidth*2/3))
+	rows := min(max(len(p.Items), 1), maxPaletteItems, screenHeight-4)
+	if width < 10 || rows < 1 {
+		return
+	}
+	left := (screenWidth - width) / 2
+
+	box := r.styles.Normal
+	selected := r.styles.CursorLine
+	border := r.styles.LineNumber
+	matched := r.styles.Normal.Bold(true).Underline(true)
+
+	// Border, with the query on the top line
+	glyphs := r.screen.caps.Glyphs()
+	for y := 0; y < rows+3; y++ {
+		for x := -1; x <= width; x++ {
+			ch := ' '
+			switch {
+			case y == 1 && (x == -1 || x == width):
+				ch = glyphs.Vertical
+			case y == 0 || y == 2 || y == rows+2:
+				ch = glyphs.Horizontal
+			case x == -1 || x == width:
+				ch = glyphs.Vertical
+			}
+			r.screen.SetCell(left+x, y, ch, border)
+		}
+	}
+	if p.Title != "" {
+		r.screen.SetText(left+1, 0, " "+p.Title+" ", border)
+	}
+	r.screen.SetText(left+1, 1, "> "+p.Query, box)
+	r.screen.SetCell(left+3+len([]rune(p.Query)), 1, ' ', r.styles.Cursor)
+
+	if len(p.Items) == 0 {
+		empty := "No matching commands"
+		if p.Title != "" {
+			empty = "No matches"
+		}
+		r.screen.SetText(left+1, 3, empty, border)
+		return
+	}
+
+	offset := 0
+	if p.Selected >= rows {
+		offset = p.Selected - rows + 1
+	}
+	for row := 0; row < rows && offset+row < len(p.Items); row++ {
+		item := p.Items[offset+row]
+		y := 3 + row
+		style := box
+		if offset+row == p.Selected {
+			style = selected
+		}
+		for x := 0; x < width; x++ {
+			r.screen.SetCell(left+x, y, ' ', style)
+		}
+
+		// Name with the matched characters marked, then the help, and
+		// the keys on the right
+		x := left + 1
+		for i, ch := range item.Label {
+			s := style
+			if slices.Contains(item.Matched, i) {
+				s = matched
+				if offset+row == p.Selected {
+					s = style.Bold(true).Underline(true)
+				}
+			}
+			r.screen.SetCell(x, y, ch, s)
+			x++
+		}
+		keysWidth := len([]rune(item.Keys))
+		detail := []rune(item.Detail)
+		room := left + width - 2 - keysWidth - (x + 2)
+		if room > 0 {
+			if len(detail) > room {
+				detail = append(detail[:max(room-3, 0)], []rune("...")...)
+			}
+			r.screen.SetText(x+2, y, string(detail), style)
+		}
+		if keysWidth > 0 {
+			r.screen.SetText(left+width-1-keysWidth, y, item.Keys, style)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ui
+
+// maxPanelLines is the most list lines a panel shows at once
+const maxPanelLines = 10
+
+// Panel is a titled list drawn between the buffer and the status line,
+// used for quickfix lists and command output
+type Panel struct {
+	Title    string
+	Lines    []string
+	Selected int // Highlighted line, or -1 for none
+	Height   int // Most lines shown, or 0 for maxPanelLines
+	Top      int // First line shown, if that keeps Selected visible
+}
+
+// SetPanel shows p at the bottom of the screen, or hides the panel when p is nil
+func (ui *UI) SetPanel(p *Panel) {
+	ui.renderer.panel = p
+}
+
+// panelHeight returns the rows taken by the panel including its title,
+// always leaving at least one row for the buffer
+func (r *Renderer) panelHeight() int {
+	if r.panel == nil {
+		return 0
+	}
+	_, height := r.screen.Size()
+	maxLines := maxPanelLines
+	if r.panel.Height > 0 {
+		maxLines = r.panel.Height
+	}
+	rows := min(max(len(r.panel.Lines), 1), maxLines) + 1
+	return max(0, min(rows, height-2))
+}
+
+// layout sizes the buffer area around the status line and panel
+func (r *Renderer) layout() {
+	_, height := r.screen.Size()
+	r.viewport.Height = max(1, height-1-r.panelHeight())
+}
+
+// renderPanel draws the panel below the buffer area, scrolled so the selected line is visible
+func (r *Renderer) renderPanel() {
+	rows := r.panelHeight()
+	if rows == 0 {
+		return
+	}
+	top := r.viewport.Height
+	listRows := rows - 1
+
+	for x := 0; x < r.viewport.Width; x++ {
+		r.screen.SetCell(x, top, ' ', r.styles.StatusLine)
+	}
+	r.screen.SetText(0, top, r.panel.Title, r.styles.StatusLine)
+
+	offset := r.panel.Top
+	if r.panel.Selected >= offset+listRows {
+		offset = r.panel.Selected - listRows + 1
+	}
+	if r.panel.Selected >= 0 && r.panel.Selected < offset {
+		offset = r.panel.Selected
+	}
+	for row := 0; row < listRows; row++ {
+		y := top + 1 + row
+		style := r.styles.Normal
+		if offset+row == r.panel.Selected {
+			style = r.styles.CursorLine
+		}
+		for x := 0; x < r.viewport.Width; x++ {
+			r.screen.SetCell(x, y, ' ', style)
+		}
+		if offset+row < len(r.panel.Lines) {
+			r.screen.SetText(0, y, r.panel.Lines[offset+row], style)
+		}
+	}
+}
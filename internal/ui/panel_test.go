@@ -0,0 +1,38 @@
+package ui
+
+import "testing"
+
+func TestRenderer_PanelLayout(t *testing.T) {
+	r := &Renderer{screen: &Screen{width: 80, height: 24}}
+	r.layout()
+	if r.viewport.Height != 23 {
+		t.Errorf("expected full height without panel, got %d", r.viewport.Height)
+	}
+
+	r.panel = &Panel{Lines: []string{"a", "b", "c"}}
+	r.layout()
+	if r.viewport.Height != 19 {
+		t.Errorf("expected panel of 3 lines and a title, got height %d", r.viewport.Height)
+	}
+
+	r.panel.Lines = make([]string, 50)
+	r.layout()
+	if r.viewport.Height != 23-maxPanelLines-1 {
+		t.Errorf("expected panel capped at %d lines, got height %d", maxPanelLines, r.viewport.Height)
+	}
+
+	r.screen = &Screen{width: 80, height: 4}
+	r.layout()
+	if r.viewport.Height != 1 {
+		t.Errorf("expected at least one buffer line, got %d", r.viewport.Height)
+	}
+}
+
+func TestRenderer_PanelHeight(t *testing.T) {
+	r := &Renderer{screen: &Screen{width: 80, height: 24}}
+	r.panel = &Panel{Lines: make([]string, 50), Height: 12}
+	r.layout()
+	if r.viewport.Height != 10 {
+		t.Errorf("expected panel of 12 lines and a title, got height %d", r.viewport.Height)
+	}
+}
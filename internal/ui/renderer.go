@@ -14,11 +14,51 @@ type Viewport struct {
 	Height    int // Viewport height in lines (excluding status line)
 }
 
+// HighlightKind identifies which style a highlighted range is drawn with
+type HighlightKind int
+
+const (
+	HighlightSpellBad HighlightKind = iota
+	HighlightSearch                    // Matches of the last search
+	HighlightColor                     // A color value, drawn on that color
+)
+
+// Highlight is a styled byte range [Start, End) on a buffer line
+type Highlight struct {
+	Start int
+	End   int
+	Kind  HighlightKind
+	Color tcell.Color // The color of a HighlightColor
+}
+
+// Highlighter returns the highlights for a buffer line
+type Highlighter func(lineNum int, line string) []Highlight
+
 // Renderer handles drawing the buffer content to the screen
 type Renderer struct {
-	screen   *Screen
-	viewport Viewport
-	styles   *StyleConfig
+	screen       *Screen
+	viewport     Viewport
+	styles       *StyleConfig
+	highlighters []Highlighter
+	options      RenderOptions
+	secondary    map[buffer.Position]bool // Secondary cursors of the buffer being rendered
+	panel        *Panel                   // List shown above the status line, nil when hidden
+	diff         *DiffView                // Buffer compared side by side, nil outside diff mode
+	palette      *Palette                 // Command palette drawn over the buffer, nil when closed
+	composer     *Composer                // Prompt composer drawn over the buffer, nil when closed
+	suggestion   *Suggestion              // Text offered for insertion at the cursor, nil when there is none
+	keyHints     *KeyHints                // Keys that may follow the pending keys, nil when hidden
+	pending      string                   // Shown at the right of the status line
+	activity     string                   // Work running in the background, shown left of pending
+	ruler        bool                     // Whether the status line shows the line count, byte offset and scroll position
+	cmdCursor    int                      // Column of the cursor on the command line, 0 for none
+	activitySlot activitySlot             // Where activity was drawn, for UI.ShowActivity
+}
+
+// activitySlot is the part of the status line the activity may be drawn in
+type activitySlot struct {
+	y, start, end int // Columns start up to end, on row y
+	drawn         int // Column the last activity drawn started at
 }
 
 // StyleConfig defines the visual styling for different elements
@@ -31,6 +71,19 @@ type StyleConfig struct {
 	Warning    tcell.Style
 	Info       tcell.Style
 	Hint       tcell.Style
+	SpellBad   tcell.Style
+	Search     tcell.Style // Matches of the last search with hlsearch
+	Whitespace tcell.Style
+	MixedIndent tcell.Style
+	ColorColumn tcell.Style
+	CursorLine tcell.Style
+	CursorColumn tcell.Style
+	SecondaryCursor tcell.Style
+	DiffAdd    tcell.Style // Lines only on one side of a diff
+	DiffDelete tcell.Style // Filler lines facing them
+	DiffChange tcell.Style // Changed lines
+	DiffText   tcell.Style // Changed text within changed lines
+	Selected   tcell.Style // The chosen item of the completion menu
 }
 
 // NewRenderer creates a new renderer for the given screen
@@ -51,10 +104,26 @@ func NewRenderer(screen *Screen) *Renderer {
 			Width:     width,
 			Height:    viewportHeight,
 		},
-		styles: NewDefaultStyles(),
+		styles:  screen.caps.styles(NewDefaultStyles()),
+		options: DefaultRenderOptions(),
 	}
 }
 
+// SetOptions updates the rendering options
+func (r *Renderer) SetOptions(options RenderOptions) {
+	r.options = options
+}
+
+// SetStyles sets the colors to draw with, in those the terminal has
+func (r *Renderer) SetStyles(styles *StyleConfig) {
+	r.styles = r.screen.caps.styles(styles)
+}
+
+// Options returns the current rendering options
+func (r *Renderer) Options() RenderOptions {
+	return r.options
+}
+
 // NewDefaultStyles creates the default color scheme
 func NewDefaultStyles() *StyleConfig {
 	return &StyleConfig{
@@ -66,6 +135,19 @@ func NewDefaultStyles() *StyleConfig {
 		Warning:    tcell.StyleDefault.Foreground(tcell.ColorYellow).Background(tcell.ColorBlack).Underline(true),
 		Info:       tcell.StyleDefault.Foreground(tcell.ColorBlue).Background(tcell.ColorBlack).Underline(true),
 		Hint:       tcell.StyleDefault.Foreground(tcell.ColorGray).Background(tcell.ColorBlack).Underline(true),
+		SpellBad:   tcell.StyleDefault.Foreground(tcell.ColorFuchsia).Background(tcell.ColorBlack).Underline(true),
+		Search:     tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorYellow),
+		Whitespace: tcell.StyleDefault.Foreground(tcell.ColorDarkGray).Background(tcell.ColorBlack),
+		MixedIndent: tcell.StyleDefault.Foreground(tcell.ColorDarkGray).Background(tcell.ColorMaroon),
+		ColorColumn: tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorDarkSlateGray),
+		CursorLine: tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorNavy),
+		CursorColumn: tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorNavy),
+		SecondaryCursor: tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorTeal),
+		DiffAdd:    tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorDarkGreen),
+		DiffDelete: tcell.StyleDefault.Foreground(tcell.ColorMaroon).Background(tcell.ColorBlack),
+		DiffChange: tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorPurple),
+		DiffText:   tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorRed).Bold(true),
+		Selected:   tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorBlue),
 	}
 }
 
@@ -79,6 +161,7 @@ func (r *Renderer) RenderBuffer(buf *buffer.Buffer) {
 	
 	// Adjust viewport to keep cursor visible
 	r.adjustViewport(cursor, lineCount)
+	r.collectCursors(buf)
 	
 	// Render visible lines
 	for screenY := 0; screenY < r.viewport.Height; screenY++ {
@@ -112,20 +195,62 @@ func (r *Renderer) RenderBuffer(buf *buffer.Buffer) {
 	r.screen.Show()
 }
 
+// renderLines draws the visible lines of the buffer, scrolled so the cursor is visible
+func (r *Renderer) renderLines(buf *buffer.Buffer) {
+	cursor := buf.Cursor()
+	lineCount := buf.LineCount()
+	r.adjustViewport(cursor, lineCount)
+	r.collectCursors(buf)
+
+	for screenY := 0; screenY < r.viewport.Height; screenY++ {
+		bufferLine := r.viewport.StartLine + screenY
+
+		if bufferLine >= lineCount {
+			// Past end of buffer, draw empty line
+			r.renderEmptyLine(screenY)
+			continue
+		}
+
+		line, err := buf.Line(bufferLine)
+		if err != nil {
+			// Error getting line, draw empty
+			r.renderEmptyLine(screenY)
+			continue
+		}
+
+		// Render the line with cursor and diagnostic highlighting
+		diagnostics := buf.GetDiagnosticsForLine(bufferLine)
+		if len(diagnostics) > 0 {
+			r.renderLineWithDiagnostics(screenY, line, bufferLine, cursor, diagnostics)
+		} else {
+			r.renderLine(screenY, line, bufferLine, cursor)
+		}
+	}
+}
+
 // adjustViewport ensures the cursor is visible by adjusting the viewport
 func (r *Renderer) adjustViewport(cursor buffer.Position, lineCount int) {
+	// Margins can't exceed half the viewport or the cursor could never settle
+	scrollOff := min(max(r.options.ScrollOff, 0), (r.viewport.Height-1)/2)
+	sideScrollOff := min(max(r.options.SideScrollOff, 0), (r.viewport.Width-1)/2)
+	
 	// Vertical scrolling
-	if cursor.Line < r.viewport.StartLine {
-		r.viewport.StartLine = cursor.Line
-	} else if cursor.Line >= r.viewport.StartLine+r.viewport.Height {
-		r.viewport.StartLine = cursor.Line - r.viewport.Height + 1
+	if cursor.Line-scrollOff < r.viewport.StartLine {
+		r.viewport.StartLine = cursor.Line - scrollOff
+	} else if cursor.Line+scrollOff >= r.viewport.StartLine+r.viewport.Height {
+		r.viewport.StartLine = cursor.Line + scrollOff - r.viewport.Height + 1
+		// Don't let the margin scroll past the end of the buffer
+		lastStart := max(lineCount-r.viewport.Height, cursor.Line-r.viewport.Height+1)
+		if r.viewport.StartLine > lastStart {
+			r.viewport.StartLine = lastStart
+		}
 	}
 	
 	// Horizontal scrolling
-	if cursor.Col < r.viewport.StartCol {
-		r.viewport.StartCol = cursor.Col
-	} else if cursor.Col >= r.viewport.StartCol+r.viewport.Width {
-		r.viewport.StartCol = cursor.Col - r.viewport.Width + 1
+	if cursor.Col-sideScrollOff < r.viewport.StartCol {
+		r.viewport.StartCol = cursor.Col - sideScrollOff
+	} else if cursor.Col+sideScrollOff >= r.viewport.StartCol+r.viewport.Width {
+		r.viewport.StartCol = cursor.Col + sideScrollOff - r.viewport.Width + 1
 	}
 	
 	// Ensure viewport doesn't go negative
@@ -137,10 +262,120 @@ func (r *Renderer) adjustViewport(cursor buffer.Position, lineCount int) {
 	}
 }
 
+// collectCursors records the buffer's secondary cursors for rendering
+func (r *Renderer) collectCursors(buf *buffer.Buffer) {
+	r.secondary = nil
+	if !buf.HasMultipleCursors() {
+		return
+	}
+	r.secondary = make(map[buffer.Position]bool)
+	for _, pos := range buf.Cursors() {
+		r.secondary[pos] = true
+	}
+}
+
+// AddHighlighter registers a highlighter consulted for every rendered line
+func (r *Renderer) AddHighlighter(h Highlighter) {
+	r.highlighters = append(r.highlighters, h)
+}
+
+// highlightStyle maps a highlight to its style
+func (r *Renderer) highlightStyle(h Highlight) tcell.Style {
+	switch h.Kind {
+	case HighlightColor:
+		return swatchStyle(r.styles.Normal, h.Color)
+	case HighlightSpellBad:
+		return r.styles.SpellBad
+	case HighlightSearch:
+		return r.styles.Search
+	default:
+		return r.styles.Normal
+	}
+}
+
+// lineStyles computes the base style of every rune on a line from the registered highlighters
+func (r *Renderer) lineStyles(bufferLine int, line string) []tcell.Style {
+	if len(r.highlighters) == 0 {
+		return nil
+	}
+
+	// Map rune index to byte offset since highlights use byte ranges
+	var offsets []int
+	for offset := range line {
+		offsets = append(offsets, offset)
+	}
+
+	styles := make([]tcell.Style, len(offsets))
+	for i := range styles {
+		styles[i] = r.styles.Normal
+	}
+
+	for _, highlighter := range r.highlighters {
+		for _, h := range highlighter(bufferLine, line) {
+			style := r.highlightStyle(h)
+			for i, offset := range offsets {
+				if offset >= h.Start && offset < h.End {
+					styles[i] = style
+				}
+			}
+		}
+	}
+
+	return styles
+}
+
+// decorateWhitespace applies list mode glyphs and styles to whitespace characters
+func (r *Renderer) decorateWhitespace(runes []rune, col int, info whitespaceInfo, ch rune, style tcell.Style) (rune, tcell.Style) {
+	if !r.options.List {
+		return ch, style
+	}
+
+	if info.mixedIndent && col < info.indentEnd {
+		style = r.styles.MixedIndent
+	}
+
+	display := r.screen.caps.listRune(ch, r.options.listRune(runes, col, info))
+	if display != ch {
+		if !info.mixedIndent || col >= info.indentEnd {
+			style = r.styles.Whitespace
+		}
+		ch = display
+	}
+
+	return ch, style
+}
+
+// applyColumnGuides paints the color column background onto a cell style
+func (r *Renderer) applyColumnGuides(bufferCol int, style tcell.Style) tcell.Style {
+	for _, column := range r.options.ColorColumns {
+		// Columns are 1-based like VIM's colorcolumn
+		if bufferCol == column-1 {
+			_, bg, _ := r.styles.ColorColumn.Decompose()
+			return style.Background(bg)
+		}
+	}
+	return style
+}
+
+// applyCursorGuides paints the cursorline and cursorcolumn backgrounds onto a cell style
+func (r *Renderer) applyCursorGuides(bufferLine, bufferCol int, cursor buffer.Position, style tcell.Style) tcell.Style {
+	if r.options.CursorLine && bufferLine == cursor.Line {
+		_, bg, _ := r.styles.CursorLine.Decompose()
+		style = style.Background(bg)
+	}
+	if r.options.CursorColumn && bufferCol == cursor.Col {
+		_, bg, _ := r.styles.CursorColumn.Decompose()
+		style = style.Background(bg)
+	}
+	return style
+}
+
 // renderLine draws a single line with cursor highlighting
 func (r *Renderer) renderLine(screenY int, line string, bufferLine int, cursor buffer.Position) {
 	// Convert line to runes for proper unicode handling
 	runes := []rune(line)
+	lineStyles := r.lineStyles(bufferLine, line)
+	wsInfo := analyzeWhitespace(runes)
 	
 	for screenX := 0; screenX < r.viewport.Width; screenX++ {
 		bufferCol := r.viewport.StartCol + screenX
@@ -151,8 +386,15 @@ func (r *Renderer) renderLine(screenY int, line string, bufferLine int, cursor b
 		// Get character if within line bounds
 		if bufferCol < len(runes) {
 			ch = runes[bufferCol]
+			if lineStyles != nil {
+				style = lineStyles[bufferCol]
+			}
+			ch, style = r.decorateWhitespace(runes, bufferCol, wsInfo, ch, style)
 		}
 		
+		style = r.applyColumnGuides(bufferCol, style)
+		style = r.applyCursorGuides(bufferLine, bufferCol, cursor, style)
+		
 		// Highlight cursor position
 		if bufferLine == cursor.Line && bufferCol == cursor.Col {
 			style = r.styles.Cursor
@@ -160,6 +402,11 @@ func (r *Renderer) renderLine(screenY int, line string, bufferLine int, cursor b
 			if bufferCol >= len(runes) {
 				ch = ' '
 			}
+		} else if r.secondary[buffer.Position{Line: bufferLine, Col: bufferCol}] {
+			style = r.styles.SecondaryCursor
+			if bufferCol >= len(runes) {
+				ch = ' '
+			}
 		}
 		
 		r.screen.SetCell(screenX, screenY, ch, style)
@@ -170,6 +417,8 @@ func (r *Renderer) renderLine(screenY int, line string, bufferLine int, cursor b
 func (r *Renderer) renderLineWithDiagnostics(screenY int, line string, bufferLine int, cursor buffer.Position, diagnostics []buffer.Diagnostic) {
 	// Convert line to runes for proper unicode handling
 	runes := []rune(line)
+	lineStyles := r.lineStyles(bufferLine, line)
+	wsInfo := analyzeWhitespace(runes)
 	
 	// Create a map of column positions to diagnostic severity
 	diagMap := make(map[int]int)
@@ -188,6 +437,10 @@ func (r *Renderer) renderLineWithDiagnostics(screenY int, line string, bufferLin
 		// Get character if within line bounds
 		if bufferCol < len(runes) {
 			ch = runes[bufferCol]
+			if lineStyles != nil {
+				style = lineStyles[bufferCol]
+			}
+			ch, style = r.decorateWhitespace(runes, bufferCol, wsInfo, ch, style)
 		}
 		
 		// Check for diagnostics at this position
@@ -204,6 +457,9 @@ func (r *Renderer) renderLineWithDiagnostics(screenY int, line string, bufferLin
 			}
 		}
 		
+		style = r.applyColumnGuides(bufferCol, style)
+		style = r.applyCursorGuides(bufferLine, bufferCol, cursor, style)
+		
 		// Highlight cursor position (overrides diagnostic highlighting)
 		if bufferLine == cursor.Line && bufferCol == cursor.Col {
 			style = r.styles.Cursor
@@ -211,6 +467,11 @@ func (r *Renderer) renderLineWithDiagnostics(screenY int, line string, bufferLin
 			if bufferCol >= len(runes) {
 				ch = ' '
 			}
+		} else if r.secondary[buffer.Position{Line: bufferLine, Col: bufferCol}] {
+			style = r.styles.SecondaryCursor
+			if bufferCol >= len(runes) {
+				ch = ' '
+			}
 		}
 		
 		r.screen.SetCell(screenX, screenY, ch, style)
@@ -236,21 +497,44 @@ func (r *Renderer) renderStatusLineWithMode(buf *buffer.Buffer, modeText string)
 	
 	cursor := buf.Cursor()
 	filename := buf.Filename()
-	if filename == "" {
+	if scratch := buf.Options().Scratch; scratch != "" {
+		filename = "[" + scratch + "]"
+	} else if filename == "" {
 		filename = "[No Name]"
 	}
 	
+	if indicator := buf.FileIndicator(); indicator != "" {
+		filename += " " + indicator
+	}
+	
 	modified := ""
 	if buf.Modified() {
 		modified = "[+]"
 	}
+	if buf.Options().ReadOnly {
+		modified += "[RO]"
+	}
 	
-	// Format: "filename [+] - Line: 1, Col: 1 - MODE"
+	// Format: "filename [host] [+] - Line: 1, Col: 1 - MODE"
 	status := ""
 	if len(filename) + len(modified) > 0 {
 		status = filename + " " + modified + " - "
 	}
-	status += "Line: " + formatInt(cursor.Line+1) + ", Col: " + formatInt(cursor.Col+1)
+	if r.ruler {
+		// Format: "Line: 12 of 120, Col: 5, Byte: 345 - 47%"
+		status += "Line: " + formatInt(cursor.Line+1) + " of " + formatInt(buf.LineCount()) +
+			", Col: " + formatInt(cursor.Col+1) + ", Byte: " + formatInt(buf.ByteOffset(cursor)+1) +
+			" - " + r.scrollPosition(buf.LineCount())
+	} else {
+		status += "Line: " + formatInt(cursor.Line+1) + ", Col: " + formatInt(cursor.Col+1)
+	}
+	if buf.HasMultipleCursors() {
+		status += " - " + formatInt(len(buf.Cursors())+1) + " cursors"
+	}
+	
+	if r.diff != nil {
+		status += " - diff: " + r.diff.Title
+	}
 	
 	if modeText != "" {
 		status += " - " + modeText
@@ -261,8 +545,50 @@ func (r *Renderer) renderStatusLineWithMode(buf *buffer.Buffer, modeText string)
 		r.screen.SetCell(x, statusY, ' ', r.styles.StatusLine)
 	}
 	
-	// Draw status text
+	// Draw status text, and what is pending and running at the right
 	r.screen.SetText(0, statusY, status, r.styles.StatusLine)
+	end := r.viewport.Width - 1
+	if r.pending != "" {
+		x := max(r.viewport.Width-len([]rune(r.pending))-1, len(status)+1)
+		r.screen.SetText(x, statusY, r.pending, r.styles.StatusLine)
+		end = x - 2
+	}
+	r.activitySlot = activitySlot{y: statusY, start: len(status) + 1, end: end, drawn: end}
+	r.drawActivity(r.activity)
+}
+
+// scrollPosition says how far the view is scrolled through lineCount
+// lines, like Vim's ruler: All when every line is visible, Top or Bot at
+// either end, and otherwise the percentage of the lines not visible that
+// are above it
+func (r *Renderer) scrollPosition(lineCount int) string {
+	above := r.viewport.StartLine
+	below := max(lineCount-r.viewport.StartLine-r.viewport.Height, 0)
+	switch {
+	case above == 0 && below == 0:
+		return "All"
+	case above == 0:
+		return "Top"
+	case below == 0:
+		return "Bot"
+	}
+	return formatInt(above*100/(above+below)) + "%"
+}
+
+// drawActivity draws text in the activity slot, right-aligned, clearing
+// what was drawn there before
+func (r *Renderer) drawActivity(text string) {
+	slot := &r.activitySlot
+	runes := []rune(text)
+	x := max(slot.end-len(runes), slot.start)
+	for col := min(slot.drawn, x); col < slot.end; col++ {
+		ch := ' '
+		if col >= x {
+			ch = runes[col-x]
+		}
+		r.screen.tcellScreen.SetContent(col, slot.y, ch, nil, r.styles.StatusLine)
+	}
+	slot.drawn = x
 }
 
 // renderStatusLineWithModeAndCommand draws the status line with mode, command line, and message
@@ -279,6 +605,13 @@ func (r *Renderer) renderStatusLineWithModeAndCommand(buf *buffer.Buffer, modeTe
 		
 		// Draw command line
 		r.screen.SetText(0, statusY, commandLine, r.styles.StatusLine)
+		if r.cmdCursor > 0 && r.cmdCursor < r.viewport.Width {
+			under := ' '
+			if runes := []rune(commandLine); r.cmdCursor < len(runes) {
+				under = runes[r.cmdCursor]
+			}
+			r.screen.SetCell(r.cmdCursor, statusY, under, r.styles.Cursor)
+		}
 		return
 	}
 	
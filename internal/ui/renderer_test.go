@@ -99,6 +99,39 @@ func TestRenderer_AdjustViewport(t *testing.T) {
 	}
 }
 
+func TestRenderer_AdjustViewport_ScrollOff(t *testing.T) {
+	screen := &Screen{width: 20, height: 11}
+	renderer := NewRenderer(screen)
+	renderer.SetOptions(RenderOptions{ScrollOff: 3, SideScrollOff: 4})
+	// viewport is 20x10
+
+	// Moving down keeps three lines below the cursor
+	renderer.adjustViewport(buffer.Position{Line: 7, Col: 0}, 100)
+	if renderer.viewport.StartLine != 1 {
+		t.Errorf("expected start line 1, got %d", renderer.viewport.StartLine)
+	}
+
+	// Moving up keeps three lines above the cursor
+	renderer.viewport.StartLine = 10
+	renderer.adjustViewport(buffer.Position{Line: 11, Col: 0}, 100)
+	if renderer.viewport.StartLine != 8 {
+		t.Errorf("expected start line 8, got %d", renderer.viewport.StartLine)
+	}
+
+	// The margin never scrolls past the end of the buffer
+	renderer.viewport.StartLine = 0
+	renderer.adjustViewport(buffer.Position{Line: 14, Col: 0}, 15)
+	if renderer.viewport.StartLine != 5 {
+		t.Errorf("expected start line 5 at end of buffer, got %d", renderer.viewport.StartLine)
+	}
+
+	// Horizontal margin
+	renderer.adjustViewport(buffer.Position{Line: 14, Col: 18}, 15)
+	if renderer.viewport.StartCol != 3 {
+		t.Errorf("expected start col 3, got %d", renderer.viewport.StartCol)
+	}
+}
+
 func TestRenderer_UpdateViewport(t *testing.T) {
 	screen := &Screen{width: 80, height: 24}
 	renderer := NewRenderer(screen)
@@ -12,6 +12,7 @@ type Screen struct {
 	width       int
 	height      int
 	running     bool
+	caps        Capabilities // What the terminal can show
 }
 
 // NewScreen creates and initializes a new terminal screen
@@ -32,6 +33,7 @@ func NewScreen() (*Screen, error) {
 		width:       width,
 		height:      height,
 		running:     true,
+		caps:        DetectCapabilities(tcellScreen),
 	}
 
 	// Set up initial screen
@@ -97,6 +99,34 @@ func (s *Screen) PostQuit() {
 	s.tcellScreen.PostEvent(tcell.NewEventInterrupt(nil))
 }
 
+// PostRedraw wakes the event loop with a RedrawEvent. It is safe to call from any goroutine.
+func (s *Screen) PostRedraw() {
+	if s.tcellScreen != nil {
+		s.tcellScreen.PostEvent(tcell.NewEventInterrupt(RedrawEvent{}))
+	}
+}
+
+// PostCall wakes the event loop with a CallEvent running fn. It is safe to
+// call from any goroutine.
+func (s *Screen) PostCall(fn func()) {
+	if s.tcellScreen != nil {
+		s.tcellScreen.PostEvent(tcell.NewEventInterrupt(CallEvent{Fn: fn}))
+	}
+}
+
+// Suspend releases the terminal while run executes, for programs that
+// need to talk to the user directly, and restores the screen afterwards
+func (s *Screen) Suspend(run func() error) error {
+	if s.tcellScreen == nil {
+		return run()
+	}
+	if err := s.tcellScreen.Suspend(); err != nil {
+		return err
+	}
+	defer s.tcellScreen.Resume()
+	return run()
+}
+
 // UpdateSize updates the screen size (typically called on resize)
 func (s *Screen) UpdateSize() {
 	if s.tcellScreen != nil {
@@ -0,0 +1,88 @@
+package ui
+
+import "github.com/dshills/aied/internal/buffer"
+
+// maxSuggestionRows is the most lines of a suggestion shown
+const maxSuggestionRows = 12
+
+// Suggestion is text offered for insertion at the cursor, such as an AI
+// completion, drawn in a box below the cursor before the buffer changes,
+// with where it comes from on the top border and the keys that accept or
+// reject it on the bottom one
+type Suggestion struct {
+	Title string // Like "ollama"
+	Lines []string
+	Hint  string
+}
+
+// SetSuggestion shows s at the cursor, or hides the suggestion when s is nil
+func (ui *UI) SetSuggestion(s *Suggestion) {
+	ui.renderer.suggestion = s
+}
+
+// renderSuggestion draws the suggestion below the cursor, or above it when
+// there isn't room, cut off at maxSuggestionRows lines
+func (r *Renderer) renderSuggestion(buf *buffer.Buffer) {
+	s := r.suggestion
+	if s == nil || len(s.Lines) == 0 {
+		return
+	}
+	screenWidth, screenHeight := r.screen.Size()
+	rows := min(len(s.Lines), maxSuggestionRows)
+	width := max(len([]rune(s.Hint))+2, len([]rune(s.Title))+4)
+	for _, line := range s.Lines[:rows] {
+		width = max(width, len([]rune(line)))
+	}
+	width = min(width, screenWidth-2)
+	if width < 10 {
+		return
+	}
+
+	cursor := buf.Cursor()
+	x := min(max(cursor.Col-r.viewport.StartCol, 0), screenWidth-width-2)
+	y := cursor.Line - r.viewport.StartLine + 1
+	if y+rows+2 >= screenHeight-1 { // The status line
+		y = max(cursor.Line-r.viewport.StartLine-rows-2, 0)
+	}
+
+	box := r.styles.Normal
+	border := r.styles.LineNumber
+	glyphs := r.screen.caps.Glyphs()
+	for row := 0; row < rows+2; row++ {
+		for col := 0; col < width+2; col++ {
+			ch, style := ' ', border
+			top, bottom := row == 0, row == rows+1
+			left, right := col == 0, col == width+1
+			switch {
+			case top && left:
+				ch = glyphs.TopLeft
+			case top && right:
+				ch = glyphs.TopRight
+			case bottom && left:
+				ch = glyphs.BottomLeft
+			case bottom && right:
+				ch = glyphs.BottomRight
+			case top || bottom:
+				ch = glyphs.Horizontal
+			case left || right:
+				ch = glyphs.Vertical
+			default:
+				style = box
+			}
+			r.screen.SetCell(x+col, y+row, ch, style)
+		}
+	}
+	if s.Title != "" && len([]rune(s.Title))+2 < width {
+		r.screen.SetText(x+2, y, " "+s.Title+" ", border)
+	}
+	if hint := []rune(" " + s.Hint + " "); s.Hint != "" && len(hint) <= width {
+		r.screen.SetText(x+width+1-len(hint), y+rows+1, string(hint), border)
+	}
+	for row, line := range s.Lines[:rows] {
+		text := []rune(line)
+		if len(text) > width {
+			text = text[:width]
+		}
+		r.screen.SetText(x+1, y+1+row, string(text), box)
+	}
+}
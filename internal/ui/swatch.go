@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// hexColorPattern matches #rrggbb colors, with an optional alpha
+var hexColorPattern = regexp.MustCompile(`#[0-9a-fA-F]{6}(?:[0-9a-fA-F]{2})?\b`)
+
+// rgbColorPattern matches CSS rgb() and rgba() colors
+var rgbColorPattern = regexp.MustCompile(`\brgba?\(([^()]*)\)`)
+
+// ColorSwatches is a Highlighter showing the #rrggbb and rgb() colors of
+// a line in the color they name, for editing stylesheets and themes
+func ColorSwatches(lineNum int, line string) []Highlight {
+	var highlights []Highlight
+	for _, m := range hexColorPattern.FindAllStringIndex(line, -1) {
+		value, _ := strconv.ParseUint(line[m[0]+1:m[0]+7], 16, 32)
+		color := tcell.NewHexColor(int32(value))
+		highlights = append(highlights, Highlight{Start: m[0], End: m[1], Kind: HighlightColor, Color: color})
+	}
+	for _, m := range rgbColorPattern.FindAllStringSubmatchIndex(line, -1) {
+		if color, ok := parseRGB(line[m[2]:m[3]]); ok {
+			highlights = append(highlights, Highlight{Start: m[0], End: m[1], Kind: HighlightColor, Color: color})
+		}
+	}
+	return highlights
+}
+
+// parseRGB parses the arguments of rgb() or rgba(): red, green and blue
+// from 0 to 255 or as percentages, separated by commas or spaces, and an
+// optional alpha, which the swatch leaves out
+func parseRGB(args string) (tcell.Color, bool) {
+	fields := strings.FieldsFunc(args, func(r rune) bool {
+		return r == ',' || r == '/' || r == ' ' || r == '\t'
+	})
+	if len(fields) != 3 && len(fields) != 4 {
+		return tcell.ColorDefault, false
+	}
+	var rgb [3]int32
+	for i, field := range fields[:3] {
+		scale := 1.0
+		if percent, ok := strings.CutSuffix(field, "%"); ok {
+			field, scale = percent, 255.0/100
+		}
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil || n < 0 || n*scale > 255 {
+			return tcell.ColorDefault, false
+		}
+		rgb[i] = int32(n*scale + 0.5)
+	}
+	return tcell.NewRGBColor(rgb[0], rgb[1], rgb[2]), true
+}
+
+// swatchStyle draws text on the color of a swatch, in black or white,
+// whichever is easier to read on it
+func swatchStyle(base tcell.Style, color tcell.Color) tcell.Style {
+	r, g, b := color.RGB()
+	text := tcell.ColorWhite
+	if 299*r+587*g+114*b > 128*1000 {
+		text = tcell.ColorBlack
+	}
+	return base.Background(color).Foreground(text)
+}
@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestColorSwatches(t *testing.T) {
+	line := `a { color: #ff8000; background: rgb(0, 0, 255); border: rgba(100% 0% 0% / 0.5); x: #abc; y: #12345g; z: rgb(300, 0, 0) }`
+	want := []struct {
+		text  string
+		color tcell.Color
+	}{
+		{"#ff8000", tcell.NewRGBColor(255, 128, 0)},
+		{"rgb(0, 0, 255)", tcell.NewRGBColor(0, 0, 255)},
+		{"rgba(100% 0% 0% / 0.5)", tcell.NewRGBColor(255, 0, 0)},
+	}
+	got := ColorSwatches(0, line)
+	if len(got) != len(want) {
+		t.Fatalf("ColorSwatches found %d colors, want %d: %+v", len(got), len(want), got)
+	}
+	for i, h := range got {
+		if text := line[h.Start:h.End]; text != want[i].text || h.Color != want[i].color || h.Kind != HighlightColor {
+			t.Errorf("swatch %d is %q in %v, want %q in %v", i, text, h.Color, want[i].text, want[i].color)
+		}
+	}
+
+	// The text is drawn in whichever of black and white reads on the color
+	if fg, _, _ := swatchStyle(tcell.StyleDefault, tcell.NewRGBColor(250, 250, 200)).Decompose(); fg != tcell.ColorBlack {
+		t.Errorf("text on a light swatch is %v, want black", fg)
+	}
+	if fg, _, _ := swatchStyle(tcell.StyleDefault, tcell.NewRGBColor(20, 20, 120)).Decompose(); fg != tcell.ColorWhite {
+		t.Errorf("text on a dark swatch is %v, want white", fg)
+	}
+}
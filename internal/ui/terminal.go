@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetTitle sets the title of the terminal's window or tab
+func (s *Screen) SetTitle(title string) {
+	if s.tcellScreen != nil {
+		s.tcellScreen.SetTitle(title)
+	}
+}
+
+// ReportDir tells the terminal the working directory with OSC 7, so the
+// tabs and splits it opens start there
+func (s *Screen) ReportDir(dir string) {
+	if s.tcellScreen == nil {
+		return
+	}
+	tty, ok := s.tcellScreen.Tty()
+	if !ok {
+		return
+	}
+	host, _ := os.Hostname()
+	tty.Write([]byte(osc7(host, dir)))
+}
+
+// osc7 returns the OSC 7 sequence reporting dir on host as a file URL
+func osc7(host, dir string) string {
+	path := filepath.ToSlash(dir)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths like C:/src become /C:/src
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Host: host, Path: path}
+	return "\x1b]7;" + u.String() + "\x1b\\"
+}
+
+// setTitle sets the title unless it is already showing
+func (ui *UI) setTitle(title string) {
+	if title != ui.title {
+		ui.screen.SetTitle(title)
+		ui.title = title
+	}
+}
+
+// reportDir reports dir unless the terminal already has it
+func (ui *UI) reportDir(dir string) {
+	if dir != "" && dir != ui.dir {
+		ui.screen.ReportDir(dir)
+		ui.dir = dir
+	}
+}
+
+// WindowTitle returns the title for editing filename, like
+// "main.go + (~/src/aied) - aied", where + marks unsaved changes
+func WindowTitle(filename string, modified bool) string {
+	name, dir := "[No Name]", ""
+	if filename != "" {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
+		}
+		name, dir = filepath.Base(filename), filepath.Dir(filename)
+		if home, err := os.UserHomeDir(); err == nil {
+			if rel, err := filepath.Rel(home, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				dir = filepath.Join("~", rel)
+			}
+		}
+	}
+
+	title := name
+	if modified {
+		title += " +"
+	}
+	if dir != "" {
+		title += " (" + dir + ")"
+	}
+	return title + " - aied"
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/aied/internal/buffer"
+)
+
+func TestWindowTitle(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	tests := []struct {
+		filename string
+		modified bool
+		want     string
+	}{
+		{"", false, "[No Name] - aied"},
+		{"", true, "[No Name] + - aied"},
+		{filepath.Join(home, "src", "main.go"), false, "main.go (~/src) - aied"},
+		{filepath.Join(home, "notes.txt"), true, "notes.txt + (~) - aied"},
+		{"/etc/hosts", false, "hosts (/etc) - aied"},
+	}
+	for _, tt := range tests {
+		if got := WindowTitle(tt.filename, tt.modified); got != tt.want {
+			t.Errorf("WindowTitle(%q, %v) = %q, want %q", tt.filename, tt.modified, got, tt.want)
+		}
+	}
+}
+
+func TestOSC7(t *testing.T) {
+	tests := []struct {
+		host, dir string
+		want      string
+	}{
+		{"box", "/home/me/src", "\x1b]7;file://box/home/me/src\x1b\\"},
+		{"box", "/home/me/my project", "\x1b]7;file://box/home/me/my%20project\x1b\\"},
+		{"", "/tmp", "\x1b]7;file:///tmp\x1b\\"},
+	}
+	for _, tt := range tests {
+		if got := osc7(tt.host, tt.dir); got != tt.want {
+			t.Errorf("osc7(%q, %q) = %q, want %q", tt.host, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestDrawSetsTitleOnce(t *testing.T) {
+	ui, _ := newSimulatedUI(t, 40, 10)
+	ui.Draw(&Frame{Buffer: buffer.New(), Title: "a - aied", Dir: "/tmp"})
+	if ui.title != "a - aied" || ui.dir != "/tmp" {
+		t.Errorf("after Draw title %q dir %q, want %q and %q", ui.title, ui.dir, "a - aied", "/tmp")
+	}
+}
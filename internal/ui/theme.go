@@ -0,0 +1,189 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Theme configures the colors the UI draws with
+type Theme struct {
+	Name        string                // One of Themes; "" is "default"
+	Transparent bool                  // Let the terminal's own background show through
+	Colors      map[string]ThemeColor // Overrides by style name, like "cursor_line"
+}
+
+// ThemeColor overrides the colors of a style. A color is "#rrggbb", a
+// name like "navy", or "none" for the terminal's default; "" keeps the
+// theme's.
+type ThemeColor struct {
+	Fg string
+	Bg string
+}
+
+// Themes lists the built-in themes. "default" follows the terminal's
+// background; the others are for a light or dark one.
+var Themes = []string{"default", "light", "monokai"}
+
+// Styles returns the styles of the theme. light says whether the
+// terminal's background is light, for the default theme.
+func (t Theme) Styles(light bool) (*StyleConfig, error) {
+	var styles *StyleConfig
+	switch t.Name {
+	case "", "default":
+		styles = NewDefaultStyles()
+		if light {
+			styles = lightStyles()
+		}
+	case "light":
+		styles = lightStyles()
+	case "monokai":
+		styles = monokaiStyles()
+	default:
+		return nil, fmt.Errorf("unknown theme %q (want %s)", t.Name, strings.Join(Themes, ", "))
+	}
+
+	if t.Transparent {
+		// Every style drawn on the theme's background draws on the terminal's
+		_, background, _ := styles.Normal.Decompose()
+		for _, style := range styles.named() {
+			if _, bg, _ := style.Decompose(); bg == background {
+				*style = style.Background(tcell.ColorDefault)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(t.Colors))
+	for name := range t.Colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		style, ok := styles.named()[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown style %q in colors", name)
+		}
+		color := t.Colors[name]
+		if color.Fg != "" {
+			fg, err := ParseColor(color.Fg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			*style = style.Foreground(fg)
+		}
+		if color.Bg != "" {
+			bg, err := ParseColor(color.Bg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			*style = style.Background(bg)
+		}
+	}
+	return styles, nil
+}
+
+// ParseColor parses a theme color: "#rrggbb" for 24-bit color, a name
+// like "navy" or "darkslategray", or "none" or "default" for the
+// terminal's own color
+func ParseColor(s string) (tcell.Color, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "none", "default":
+		return tcell.ColorDefault, nil
+	}
+	color := tcell.GetColor(s)
+	if color == tcell.ColorDefault {
+		return color, fmt.Errorf("unknown color %q", s)
+	}
+	return color, nil
+}
+
+// named returns the styles by the names themes override them with
+func (s *StyleConfig) named() map[string]*tcell.Style {
+	return map[string]*tcell.Style{
+		"normal":           &s.Normal,
+		"cursor":           &s.Cursor,
+		"status_line":      &s.StatusLine,
+		"line_number":      &s.LineNumber,
+		"error":            &s.Error,
+		"warning":          &s.Warning,
+		"info":             &s.Info,
+		"hint":             &s.Hint,
+		"spell_bad":        &s.SpellBad,
+		"search":           &s.Search,
+		"whitespace":       &s.Whitespace,
+		"mixed_indent":     &s.MixedIndent,
+		"color_column":     &s.ColorColumn,
+		"cursor_line":      &s.CursorLine,
+		"cursor_column":    &s.CursorColumn,
+		"secondary_cursor": &s.SecondaryCursor,
+		"diff_add":         &s.DiffAdd,
+		"diff_delete":      &s.DiffDelete,
+		"diff_change":      &s.DiffChange,
+		"diff_text":        &s.DiffText,
+		"selected":         &s.Selected,
+	}
+}
+
+// style returns the style with foreground fg on background bg, both
+// "#rrggbb"
+func style(fg, bg string) tcell.Style {
+	return tcell.StyleDefault.Foreground(tcell.GetColor(fg)).Background(tcell.GetColor(bg))
+}
+
+// lightStyles are the default theme for a light background
+func lightStyles() *StyleConfig {
+	const bg = "#ffffff"
+	return &StyleConfig{
+		Normal:          style("#000000", bg),
+		Cursor:          style("#ffffff", "#000000"),
+		StatusLine:      style("#000000", "#c0c0c0"),
+		LineNumber:      style("#808080", bg),
+		Error:           style("#af0000", bg).Underline(true),
+		Warning:         style("#875f00", bg).Underline(true),
+		Info:            style("#0000af", bg).Underline(true),
+		Hint:            style("#808080", bg).Underline(true),
+		SpellBad:        style("#af00af", bg).Underline(true),
+		Search:          style("#000000", "#ffff5f"),
+		Whitespace:      style("#bcbcbc", bg),
+		MixedIndent:     style("#bcbcbc", "#ffd7d7"),
+		ColorColumn:     style("#000000", "#eeeeee"),
+		CursorLine:      style("#000000", "#e4e4ff"),
+		CursorColumn:    style("#000000", "#e4e4ff"),
+		SecondaryCursor: style("#000000", "#5fd7d7"),
+		DiffAdd:         style("#000000", "#d7ffd7"),
+		DiffDelete:      style("#af0000", bg),
+		DiffChange:      style("#000000", "#d7d7ff"),
+		DiffText:        style("#000000", "#ffafaf").Bold(true),
+		Selected:        style("#ffffff", "#005fd7"),
+	}
+}
+
+// monokaiStyles are the Monokai colors, for a dark background
+func monokaiStyles() *StyleConfig {
+	const fg, bg = "#f8f8f2", "#272822"
+	return &StyleConfig{
+		Normal:          style(fg, bg),
+		Cursor:          style(bg, fg),
+		StatusLine:      style(fg, "#49483e"),
+		LineNumber:      style("#90908a", bg),
+		Error:           style("#f92672", bg).Underline(true),
+		Warning:         style("#fd971f", bg).Underline(true),
+		Info:            style("#66d9ef", bg).Underline(true),
+		Hint:            style("#75715e", bg).Underline(true),
+		SpellBad:        style("#ae81ff", bg).Underline(true),
+		Search:          style(bg, "#e6db74"),
+		Whitespace:      style("#464741", bg),
+		MixedIndent:     style("#464741", "#5f0000"),
+		ColorColumn:     style(fg, "#3e3d32"),
+		CursorLine:      style(fg, "#3e3d32"),
+		CursorColumn:    style(fg, "#3e3d32"),
+		SecondaryCursor: style(bg, "#66d9ef"),
+		DiffAdd:         style(fg, "#3a5f0b"),
+		DiffDelete:      style("#f92672", bg),
+		DiffChange:      style(fg, "#49483e"),
+		DiffText:        style(bg, "#fd971f").Bold(true),
+		Selected:        style(bg, "#a6e22e"),
+	}
+}
@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestTheme_Styles(t *testing.T) {
+	dark, err := Theme{}.Styles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dark != *NewDefaultStyles() {
+		t.Error("expected the default theme on a dark background to be the default styles")
+	}
+
+	light, err := Theme{Name: "default"}.Styles(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, bg, _ := light.Normal.Decompose(); bg != tcell.NewHexColor(0xffffff) {
+		t.Errorf("default theme on a light background has background %v", bg)
+	}
+
+	monokai, err := Theme{Name: "monokai"}.Styles(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, bg, _ := monokai.Normal.Decompose(); bg != tcell.GetColor("#272822") {
+		t.Errorf("monokai has background %v", bg)
+	}
+
+	if _, err := (Theme{Name: "solarized"}).Styles(false); err == nil || !strings.Contains(err.Error(), "unknown theme") {
+		t.Errorf("expected an unknown theme error, got %v", err)
+	}
+}
+
+func TestTheme_Transparent(t *testing.T) {
+	styles, err := Theme{Name: "monokai", Transparent: true}.Styles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, style := range map[string]tcell.Style{"normal": styles.Normal, "line_number": styles.LineNumber, "error": styles.Error} {
+		if _, bg, _ := style.Decompose(); bg != tcell.ColorDefault {
+			t.Errorf("%s has background %v, want the terminal's", name, bg)
+		}
+	}
+	if _, bg, _ := styles.CursorLine.Decompose(); bg != tcell.GetColor("#3e3d32") {
+		t.Errorf("cursor_line has background %v, want its own", bg)
+	}
+}
+
+func TestTheme_Colors(t *testing.T) {
+	styles, err := Theme{Colors: map[string]ThemeColor{
+		"cursor_line": {Bg: "#1c1c2e"},
+		"normal":      {Fg: "LightGray", Bg: "none"},
+	}}.Styles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, bg, _ := styles.CursorLine.Decompose(); bg != tcell.NewRGBColor(0x1c, 0x1c, 0x2e) {
+		t.Errorf("cursor_line background = %v", bg)
+	}
+	if fg, bg, _ := styles.Normal.Decompose(); fg != tcell.ColorLightGray || bg != tcell.ColorDefault {
+		t.Errorf("normal = %v on %v", fg, bg)
+	}
+
+	tests := []struct {
+		colors map[string]ThemeColor
+		want   string
+	}{
+		{map[string]ThemeColor{"gutter": {Fg: "red"}}, `unknown style "gutter"`},
+		{map[string]ThemeColor{"search": {Bg: "#ff"}}, `search: unknown color "#ff"`},
+	}
+	for _, tt := range tests {
+		if _, err := (Theme{Colors: tt.colors}).Styles(false); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Styles with %v: got %v, want %q", tt.colors, err, tt.want)
+		}
+	}
+}
+
+func TestParseOSC11(t *testing.T) {
+	tests := []struct {
+		reply     string
+		light, ok bool
+	}{
+		{"\x1b]11;rgb:ffff/ffff/ffff\x07\x1b[?62;22c", true, true},
+		{"\x1b]11;rgb:1e1e/1e1e/2e2e\x1b\\\x1b[?1;2c", false, true},
+		{"\x1b]11;rgb:fd/f6/e3\x07", true, true},
+		{"\x1b[?1;2c", false, false},
+		{"\x1b]11;rgb:zz/00/00\x07", false, false},
+	}
+	for _, tt := range tests {
+		if light, ok := parseOSC11(tt.reply); light != tt.light || ok != tt.ok {
+			t.Errorf("parseOSC11(%q) = %v, %v; want %v, %v", tt.reply, light, ok, tt.light, tt.ok)
+		}
+	}
+}
+
+func TestParseColorFGBG(t *testing.T) {
+	tests := []struct {
+		value     string
+		light, ok bool
+	}{
+		{"15;0", false, true},
+		{"0;15", true, true},
+		{"0;default;7", true, true},
+		{"7;8", false, true},
+		{"", false, false},
+		{"15;default", false, false},
+	}
+	for _, tt := range tests {
+		if light, ok := parseColorFGBG(tt.value); light != tt.light || ok != tt.ok {
+			t.Errorf("parseColorFGBG(%q) = %v, %v; want %v, %v", tt.value, light, ok, tt.light, tt.ok)
+		}
+	}
+}
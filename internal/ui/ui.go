@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/dshills/aied/internal/buffer"
 	"github.com/gdamore/tcell/v2"
@@ -9,11 +10,13 @@ import (
 
 // UI manages the terminal user interface
 type UI struct {
-	screen           *Screen
-	renderer         *Renderer
-	processor        *EventProcessor
-	completionPopup  *CompletionPopup
-	running          bool
+	screen    *Screen
+	renderer  *Renderer
+	processor *EventProcessor
+	running   bool
+	drawing   sync.Mutex // Held while drawing, as ShowActivity may be called from any goroutine
+	title     string     // The window title last set
+	dir       string     // The working directory last reported
 }
 
 // NewUI creates a new terminal UI
@@ -25,14 +28,12 @@ func NewUI() (*UI, error) {
 
 	renderer := NewRenderer(screen)
 	processor := NewEventProcessor(screen)
-	completionPopup := NewCompletionPopup()
 
 	return &UI{
-		screen:          screen,
-		renderer:        renderer,
-		processor:       processor,
-		completionPopup: completionPopup,
-		running:         true,
+		screen:    screen,
+		renderer:  renderer,
+		processor: processor,
+		running:   true,
 	}, nil
 }
 
@@ -52,12 +53,6 @@ func (ui *UI) IsRunning() bool {
 // Render draws the buffer to the screen
 func (ui *UI) Render(buf *buffer.Buffer) {
 	ui.renderer.RenderBuffer(buf)
-	
-	// Render completion popup if visible
-	if ui.completionPopup.IsVisible() {
-		ui.completionPopup.Render(ui.renderer.screen, ui.renderer.styles)
-		ui.renderer.screen.Show()
-	}
 }
 
 // RenderWithMode draws the buffer to the screen with mode information
@@ -67,48 +62,39 @@ func (ui *UI) RenderWithMode(buf *buffer.Buffer, modeText string) {
 
 // RenderWithModeAndCommand draws the buffer with mode and command line information
 func (ui *UI) RenderWithModeAndCommand(buf *buffer.Buffer, modeText, commandLine, message string) {
+	ui.draw(buf, modeText, commandLine, message, nil)
+}
+
+// draw renders a whole screen, with popup at the cursor if it isn't nil
+func (ui *UI) draw(buf *buffer.Buffer, modeText, commandLine, message string, popup *CompletionPopup) {
+	ui.drawing.Lock()
+	defer ui.drawing.Unlock()
 	ui.renderer.screen.Clear()
 	
-	// Get buffer information
-	cursor := buf.Cursor()
-	lineCount := buf.LineCount()
-	
-	// Adjust viewport to keep cursor visible
-	ui.renderer.adjustViewport(cursor, lineCount)
+	// A screen too small to lay out only asks to be made bigger
+	if width, height := ui.screen.Size(); width < minWidth || height < minHeight {
+		ui.renderer.renderTooSmall(width, height)
+		ui.renderer.screen.Show()
+		return
+	}
 	
-	// Render visible lines
-	for screenY := 0; screenY < ui.renderer.viewport.Height; screenY++ {
-		bufferLine := ui.renderer.viewport.StartLine + screenY
-		
-		if bufferLine >= lineCount {
-			// Past end of buffer, draw empty line
-			ui.renderer.renderEmptyLine(screenY)
-			continue
-		}
-		
-		line, err := buf.Line(bufferLine)
-		if err != nil {
-			// Error getting line, draw empty
-			ui.renderer.renderEmptyLine(screenY)
-			continue
-		}
-		
-		// Render the line with cursor and diagnostic highlighting
-		diagnostics := buf.GetDiagnosticsForLine(bufferLine)
-		if len(diagnostics) > 0 {
-			ui.renderer.renderLineWithDiagnostics(screenY, line, bufferLine, cursor, diagnostics)
-		} else {
-			ui.renderer.renderLine(screenY, line, bufferLine, cursor)
-		}
+	ui.renderer.layout()
+	if ui.renderer.diff != nil {
+		ui.renderer.renderDiff(buf)
+	} else {
+		ui.renderer.renderLines(buf)
 	}
 	
+	ui.renderer.renderPanel()
+	
 	// Render status line with mode, command line, and message
 	ui.renderer.renderStatusLineWithModeAndCommand(buf, modeText, commandLine, message)
 	
-	// Render completion popup if visible
-	if ui.completionPopup.IsVisible() {
-		ui.completionPopup.Render(ui.renderer.screen, ui.renderer.styles)
-	}
+	ui.renderer.renderKeyHints()
+	ui.renderer.renderPalette()
+	ui.renderer.renderComposer()
+	ui.renderer.renderSuggestion(buf)
+	ui.renderer.renderCompletionPopup(buf, popup)
 	
 	ui.renderer.screen.Show()
 }
@@ -123,6 +109,11 @@ func (ui *UI) HandleResize(event ResizeEvent) {
 	ui.renderer.UpdateViewport(event.Width, event.Height)
 }
 
+// Capabilities returns what the terminal can show
+func (ui *UI) Capabilities() Capabilities {
+	return ui.screen.caps
+}
+
 // GetSize returns the current terminal size
 func (ui *UI) GetSize() (int, int) {
 	return ui.screen.Size()
@@ -145,6 +136,21 @@ func (ui *UI) SetCursor(x, y int) {
 	ui.screen.SetCursor(x, y)
 }
 
+// RequestRedraw asks the event loop to render again from another goroutine
+func (ui *UI) RequestRedraw() {
+	ui.screen.PostRedraw()
+}
+
+// Call asks the event loop to run fn from another goroutine
+func (ui *UI) Call(fn func()) {
+	ui.screen.PostCall(fn)
+}
+
+// RunInTerminal suspends the UI while run uses the terminal
+func (ui *UI) RunInTerminal(run func() error) error {
+	return ui.screen.Suspend(run)
+}
+
 // PostQuit signals the UI to quit
 func (ui *UI) PostQuit() {
 	ui.screen.PostQuit()
@@ -155,35 +161,24 @@ func (ui *UI) GetViewport() Viewport {
 	return ui.renderer.GetViewport()
 }
 
-// ShowCompletions displays the completion popup with the given items
-func (ui *UI) ShowCompletions(items []CompletionItem, x, y int) {
-	ui.completionPopup.SetItems(items)
-	ui.completionPopup.Show(x, y)
-}
-
-// HideCompletions hides the completion popup
-func (ui *UI) HideCompletions() {
-	ui.completionPopup.Hide()
-}
-
-// IsCompletionVisible returns whether the completion popup is visible
-func (ui *UI) IsCompletionVisible() bool {
-	return ui.completionPopup.IsVisible()
+// SetRenderOptions updates the optional buffer decorations
+func (ui *UI) SetRenderOptions(options RenderOptions) {
+	ui.renderer.SetOptions(options)
 }
 
-// MoveCompletionUp moves the selection up in the completion popup
-func (ui *UI) MoveCompletionUp() {
-	ui.completionPopup.MoveUp()
+// RenderOptions returns the current rendering options
+func (ui *UI) RenderOptions() RenderOptions {
+	return ui.renderer.Options()
 }
 
-// MoveCompletionDown moves the selection down in the completion popup
-func (ui *UI) MoveCompletionDown() {
-	ui.completionPopup.MoveDown()
+// SetStyles sets the colors the UI draws with
+func (ui *UI) SetStyles(styles *StyleConfig) {
+	ui.renderer.SetStyles(styles)
 }
 
-// GetSelectedCompletion returns the currently selected completion item
-func (ui *UI) GetSelectedCompletion() *CompletionItem {
-	return ui.completionPopup.GetSelectedItem()
+// AddHighlighter registers a line highlighter with the renderer
+func (ui *UI) AddHighlighter(h Highlighter) {
+	ui.renderer.AddHighlighter(h)
 }
 
 // GetScreen returns the underlying screen for direct rendering
@@ -1,199 +1,271 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/dshills/aied/internal/activity"
 	"github.com/dshills/aied/internal/ai"
+	"github.com/dshills/aied/internal/batch"
 	"github.com/dshills/aied/internal/buffer"
 	"github.com/dshills/aied/internal/commands"
 	"github.com/dshills/aied/internal/config"
+	"github.com/dshills/aied/internal/crash"
+	"github.com/dshills/aied/internal/crypt"
+	"github.com/dshills/aied/internal/editor"
 	"github.com/dshills/aied/internal/lsp"
-	"github.com/dshills/aied/internal/modes"
+	"github.com/dshills/aied/internal/perf"
+	"github.com/dshills/aied/internal/privacy"
+	"github.com/dshills/aied/internal/project"
+	"github.com/dshills/aied/internal/remote"
+	"github.com/dshills/aied/internal/search"
+	"github.com/dshills/aied/internal/setup"
+	"github.com/dshills/aied/internal/stdio"
 	"github.com/dshills/aied/internal/ui"
-	"go.lsp.dev/protocol"
+	"golang.org/x/term"
 )
 
-// Global diagnostics cache - TODO: move to buffer manager when we support multiple buffers
-var diagnosticsCache = make(map[string][]buffer.Diagnostic)
-
 func main() {
-	// Initialize AI system
-	aiManager := initializeAI()
-	commands.SetAIManager(aiManager)
+	profileDir := flag.String("profile", "", "write CPU and heap profiles of the session to `dir`")
+	pprofAddr := flag.String("pprof", "", "serve pprof on `addr`, such as localhost:6060")
+	listenAddr := flag.String("listen", "", "let other programs drive the editor over JSON-RPC on `addr`, a socket path or a localhost host:port")
+	var exCommands stringList
+	flag.Var(&exCommands, "ex", "run the ex `command` on the files without the UI and write them; repeat for more")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file | -]\n       %s --ex command [--ex command...] file...\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Profile the session when asked
+	if *profileDir != "" {
+		stop, err := perf.StartProfile(*profileDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start profiling: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := stop(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write profiles: %v\n", err)
+			}
+		}()
+	}
+	if *pprofAddr != "" {
+		if _, err := perf.ServePprof(*pprofAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to serve pprof: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Offer to write a starter config the first time the editor is run
+	if len(exCommands) == 0 && setup.Needed() && term.IsTerminal(int(os.Stdin.Fd())) {
+		if _, err := setup.Run(os.Stdin, os.Stderr, promptSecret); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Setup failed: %v\n", err)
+		}
+	}
+
+	// Load the configuration everything below is set up from, and
+	// :configreload reloads
+	editorCfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to load config: %v\n", err)
+		editorCfg = config.DefaultConfig()
+	}
+	configService := config.NewService(editorCfg, config.Load)
+
+	// Initialize AI system; the providers are configured after the first
+	// frame, as finding out whether a local one runs can take seconds
+	aiManager, policy := initializeAI(editorCfg)
 	
-	// Initialize LSP system
-	lspManager := initializeLSP()
+	// Open ssh:// and scp:// URLs through the remote file client
+	remoteClient := remote.NewClient()
+	buffer.RegisterFileHandler(remoteClient)
+	defer remoteClient.Close()
+
+	// Decrypt .gpg and .age files in memory, asking for secrets on the
+	// terminal until the editor takes it over
+	encrypted := initializeEncryption(editorCfg)
+	if encrypted != nil {
+		buffer.RegisterFileHandler(encrypted)
+		encrypted.SetPrompt(promptSecret)
+	}
+
+	// "-" edits the standard input, and what :w writes to it is printed
+	// once the terminal is given back
+	if slices.Contains(flag.Args(), stdio.Name) && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Nothing to read: the standard input is a terminal, pipe text into aied -")
+		os.Exit(1)
+	}
+	streams := stdio.NewHandler(os.Stdin)
+	buffer.RegisterFileHandler(streams)
+	defer func() {
+		if err := streams.Flush(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write the standard output: %v\n", err)
+		}
+	}()
+
+	// Run the --ex commands on the files and exit, without the UI
+	if len(exCommands) > 0 {
+		cfg := editorCfg
+		// Without a screen to show first, the providers are configured now
+		for _, warning := range editor.ConfigureAI(aiManager, cfg) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+		executor := commands.NewCommandExecutor()
+		executor.SetContext(&commands.CommandContext{AI: aiManager, Config: configService, Privacy: policy})
+		if err := executor.SetUserCommands(cfg.Commands); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping user commands: %v\n", err)
+		}
+		executor.State().SetRootMarkers(cfg.Editor.RootMarkers)
+		executor.State().SetOrganizeImportsOnSave(cfg.LSP.OrganizeImportsOnSave)
+		search.SetOptions(editor.SearchOptions(cfg))
+		prepare := func(buf *buffer.Buffer) { editor.ApplyBufferConfig(cfg, buf) }
+		if err := batch.Run(executor, flag.Args(), exCommands, prepare, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialize LSP system; its servers start after the first frame
+	filename := flag.Arg(0)
+	lspManager := initializeLSP(filename, editorCfg)
 	if lspManager != nil {
 		defer lspManager.StopAll()
-		commands.SetLSPManager(lspManager)
 	}
 
 	// Create a new buffer
 	var buf *buffer.Buffer
-	var err error
 
 	// Check if a filename was provided
-	if len(os.Args) > 1 {
-		filename := os.Args[1]
+	if filename != "" {
 		buf, err = buffer.NewFromFile(filename)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error opening file %q: %v\n", filename, err)
 			os.Exit(1)
 		}
-		
-		// Open file in LSP if available
-		if lspManager != nil && buf.Filename() != "" {
-			content := lsp.GetBufferContent(buf)
-			ctx := context.Background()
-			if err := lspManager.OpenFile(ctx, buf.Filename(), content); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to open file in LSP: %v\n", err)
-			}
-		}
 	} else {
 		buf = buffer.New()
 	}
 
-	// Create the terminal UI
-	terminalUI, err := ui.NewUI()
+	// Ask the terminal for its background before the UI takes it
+	light := editor.TerminalLight(editorCfg)
+	styles, err := editor.ThemeStyles(editorCfg, light)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Using the default colors: %v\n", err)
+		styles = ui.NewDefaultStyles()
+	}
+
+	// Create the terminal UI. Everything below talks to it as a
+	// ui.Frontend, so another frontend could take its place.
+	tcellUI, err := ui.NewUI()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize terminal UI: %v\n", err)
 		os.Exit(1)
 	}
+	var terminalUI ui.Frontend = tcellUI
 	defer terminalUI.Close()
+	activity.Default.SetASCII(tcellUI.Capabilities().ASCII)
+	terminalUI.SetStyles(styles)
 
-	// Create mode manager (starts in Normal mode)
-	modeManager := modes.NewModeManager()
-	
-	// Set LSP manager if available
-	if lspManager != nil {
-		modeManager.SetLSPManager(lspManager)
-	}
+	// A panic gives the terminal back and saves the modified buffer first
+	crashHandler := crash.NewHandler(crash.DefaultDir(), terminalUI.Close, func() []*buffer.Buffer { return []*buffer.Buffer{buf} })
+	defer crashHandler.Recover()
 
-	// Initial render with mode
-	modeText := modeManager.GetStatusText()
-	terminalUI.RenderWithMode(buf, modeText)
-
-	// Main event loop
-	for terminalUI.IsRunning() {
-		event := terminalUI.WaitForEvent()
-
-		switch ev := event.(type) {
-		case ui.KeyEvent:
-			// Handle input through mode system
-			result := modeManager.HandleInput(ev, buf)
-			
-			if result.ExitEditor {
-				break // quit requested
-			}
-			
-			// Handle unhandled events with fallback logic
-			if !result.Handled {
-				if handleFallbackKeyEvent(ev, buf, terminalUI) {
-					break // quit requested
-				}
-			}
-		case ui.ResizeEvent:
-			terminalUI.HandleResize(ev)
-		}
+	if encrypted != nil {
+		encrypted.SetPrompt(nil)
+	}
+	
+	// The editor ties the buffer, modes, language servers and AI providers
+	// to the UI
+	ed := editor.New(editor.Options{
+		Frontend: terminalUI,
+		Buffer:   buf,
+		Config:   configService,
+		AI:       aiManager,
+		LSP:      lspManager,
+		Privacy:  policy,
+		Light:    light,
+	})
+	defer ed.Close()
 
-		// Update buffer diagnostics if available
-		if buf.Filename() != "" {
-			if diags, ok := diagnosticsCache[buf.Filename()]; ok {
-				buf.SetDiagnostics(diags)
-			}
-		}
-		
-		// Re-render after any changes with current mode
-		modeText := modeManager.GetStatusText()
-		
-		// Check if we're in command mode and need to show command line
-		if commandLine, message, isCommandMode := modeManager.GetCommandInfo(); isCommandMode {
-			terminalUI.RenderWithModeAndCommand(buf, modeText, commandLine, message)
-		} else {
-			terminalUI.RenderWithMode(buf, modeText)
-		}
-		
-		// Show completion popup if in insert mode and completions are available
-		if insertMode, ok := modeManager.CurrentMode().(*modes.InsertMode); ok {
-			if completions, selectedIndex, showing := insertMode.GetCompletions(); showing {
-				renderCompletionPopup(terminalUI, buf, completions, selectedIndex)
-			}
+	// Let other programs drive the editor, and the programs it runs find it
+	if *listenAddr != "" {
+		if err := ed.Serve(*listenAddr); err != nil {
+			terminalUI.Close()
+			fmt.Fprintf(os.Stderr, "Failed to serve RPC: %v\n", err)
+			os.Exit(1)
 		}
-		
 	}
+
+	ed.Run()
 }
 
-// handleFallbackKeyEvent processes unhandled keyboard input and returns true if quit was requested
-func handleFallbackKeyEvent(event ui.KeyEvent, buf *buffer.Buffer, terminalUI *ui.UI) bool {
-	switch event.Action {
-	case ui.KeyActionQuit, ui.KeyActionCtrlC:
-		return true
+// stringList is a flag that may be given more than once
+type stringList []string
 
-	case ui.KeyActionCtrlS:
-		// Global save command
-		if buf.Filename() != "" {
-			buf.Save()
-		}
-
-	default:
-		// Ignore other keys - they should be handled by modes
-	}
+func (l *stringList) String() string {
+	return strings.Join(*l, " | ")
+}
 
-	return false
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
-// initializeAI sets up the AI system with available providers
-func initializeAI() *ai.AIManager {
+// initializeAI sets up the AI system's routes and privacy rules, leaving
+// the providers to editor.ConfigureAI
+func initializeAI(cfg *config.Config) (*ai.AIManager, *privacy.Policy) {
 	aiManager := ai.NewAIManager()
-	
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to load config: %v\n", err)
-		// Continue with defaults
-		cfg = config.DefaultConfig()
-	}
-	
-	// Configure providers from config file
-	err = aiManager.ConfigureProviders(cfg.Providers)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to configure providers: %v\n", err)
+
+	// Send each type of request where the config routes it, falling back
+	// to the other providers in the configured order
+	var order []ai.ProviderType
+	for _, name := range cfg.AI.FallbackOrder {
+		if !ai.ProviderType(name).Valid() {
+			fmt.Fprintf(os.Stderr, "Warning: Unknown provider in fallback order: %s\n", name)
+			continue
+		}
+		order = append(order, ai.ProviderType(name))
 	}
-	
-	// Set default provider if specified
-	if cfg.AI.DefaultProvider != "" {
-		if err := aiManager.SetActiveProvider(ai.ProviderType(cfg.AI.DefaultProvider)); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to set default provider: %v\n", err)
+	aiManager.SetFallbackOrder(order)
+	routes := make(map[ai.RequestType]ai.Route)
+	for requestType, route := range cfg.AI.Routes {
+		switch {
+		case !ai.RequestType(requestType).Valid():
+			fmt.Fprintf(os.Stderr, "Warning: Unknown request type in AI routes: %s\n", requestType)
+		case !ai.ProviderType(route.Provider).Valid():
+			fmt.Fprintf(os.Stderr, "Warning: Unknown provider in AI route for %s: %s\n", requestType, route.Provider)
+		default:
+			routes[ai.RequestType(requestType)] = ai.Route{Provider: ai.ProviderType(route.Provider), Model: route.Model}
 		}
 	}
-	
-	return aiManager
-}
+	aiManager.SetRoutes(routes)
 
-// initializeLSP sets up the LSP system
-func initializeLSP() *lsp.Manager {
-	// Load configuration
-	cfg, err := config.Load()
+	// Check every request against the privacy rules before it is sent,
+	// refusing to start rather than send what they would have kept back
+	policy, err := privacy.New(cfg.Privacy.Redact, cfg.Privacy.BlockFiles, cfg.Privacy.LocalOnly)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to load config for LSP: %v\n", err)
-		cfg = config.DefaultConfig()
+		fmt.Fprintf(os.Stderr, "Invalid privacy rules: %v\n", err)
+		os.Exit(1)
 	}
+	aiManager.SetGuard(policy)
 	
+	return aiManager, policy
+}
+
+// initializeLSP sets up the LSP system rooted at the project containing filename
+func initializeLSP(filename string, cfg *config.Config) *lsp.Manager {
 	// Check if LSP is enabled
 	if !cfg.LSP.Enabled {
 		return nil
 	}
 	
-	// Get working directory
-	workDir, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to get working directory: %v\n", err)
-		workDir = "."
-	}
-	workDir, _ = filepath.Abs(workDir)
+	// Root the servers at the project of the opened file
+	workDir := project.Detect(filename, cfg.Editor.RootMarkers).Root
 	
 	// Create LSP manager
 	lspManager := lsp.NewManager(workDir)
@@ -215,176 +287,30 @@ func initializeLSP() *lsp.Manager {
 	// Configure servers
 	lspManager.Configure(serverConfigs)
 	
-	// Auto-start servers if configured
-	if cfg.LSP.AutoStart {
-		ctx := context.Background()
-		for _, srv := range serverConfigs {
-			if err := lspManager.Start(ctx, srv.Name); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to start LSP server %s: %v\n", srv.Name, err)
-			} else {
-				fmt.Printf("Started LSP server: %s\n", srv.Name)
-			}
-		}
-	}
-	
-	// Set up diagnostics handler
-	if cfg.LSP.ShowDiagnostics {
-		lspManager.SetDiagnosticsHandler(func(filename string, diagnostics []protocol.Diagnostic) {
-			// Convert LSP diagnostics to buffer diagnostics
-			var bufDiags []buffer.Diagnostic
-			for _, diag := range diagnostics {
-				bufDiags = append(bufDiags, buffer.Diagnostic{
-					Line:     int(diag.Range.Start.Line),
-					Column:   int(diag.Range.Start.Character),
-					Severity: int(diag.Severity),
-					Message:  diag.Message,
-					Source:   diag.Source,
-				})
-			}
-			
-			// Store diagnostics globally for now
-			diagnosticsCache[filename] = bufDiags
-		})
-	}
-	
 	return lspManager
 }
 
-// renderCompletionPopup renders a simple completion popup
-func renderCompletionPopup(terminalUI *ui.UI, buf *buffer.Buffer, completions []modes.CompletionItem, selectedIndex int) {
-	if len(completions) == 0 {
-		return
-	}
-	
-	screen := terminalUI.GetScreen()
-	cursor := buf.Cursor()
-	viewport := terminalUI.GetViewport()
-	
-	// Calculate popup position (below cursor)
-	popupX := cursor.Col - viewport.StartCol + 1
-	popupY := cursor.Line - viewport.StartLine + 1
-	
-	// Popup dimensions
-	maxWidth := 40
-	maxHeight := 10
-	popupHeight := len(completions)
-	if popupHeight > maxHeight {
-		popupHeight = maxHeight
-	}
-	
-	// Calculate actual width needed
-	popupWidth := 0
-	for i, item := range completions {
-		if i >= maxHeight {
-			break
-		}
-		itemWidth := len(item.Label) + len(item.Kind) + 3 // 3 for " - "
-		if itemWidth > popupWidth {
-			popupWidth = itemWidth
-		}
-	}
-	if popupWidth > maxWidth {
-		popupWidth = maxWidth
-	}
-	if popupWidth < 20 {
-		popupWidth = 20
-	}
-	
-	// Adjust position if popup would go off screen
-	screenWidth, screenHeight := screen.Size()
-	if popupX + popupWidth >= screenWidth {
-		popupX = screenWidth - popupWidth - 1
-	}
-	if popupY + popupHeight >= screenHeight - 1 { // -1 for status line
-		popupY = cursor.Line - viewport.StartLine - popupHeight
-		if popupY < 0 {
-			popupY = 0
-		}
-	}
-	
-	// Render popup background and border
-	normalStyle := ui.GetStyle("normal")
-	selectedStyle := ui.GetStyle("selected")
-	borderStyle := ui.GetStyle("border")
-	
-	// Draw border
-	for y := 0; y < popupHeight + 2; y++ {
-		for x := 0; x < popupWidth + 2; x++ {
-			ch := ' '
-			style := borderStyle
-			
-			if y == 0 || y == popupHeight + 1 {
-				// Top and bottom border
-				if x == 0 && y == 0 {
-					ch = '┌'
-				} else if x == popupWidth + 1 && y == 0 {
-					ch = '┐'
-				} else if x == 0 && y == popupHeight + 1 {
-					ch = '└'
-				} else if x == popupWidth + 1 && y == popupHeight + 1 {
-					ch = '┘'
-				} else {
-					ch = '─'
-				}
-			} else if x == 0 || x == popupWidth + 1 {
-				// Side borders
-				ch = '│'
-			} else {
-				// Interior
-				style = normalStyle
-			}
-			
-			screen.SetCell(popupX + x, popupY + y, ch, style)
-		}
-	}
-	
-	// Draw completion items
-	for i, item := range completions {
-		if i >= popupHeight {
-			break
-		}
-		
-		style := normalStyle
-		if i == selectedIndex {
-			style = selectedStyle
-		}
-		
-		// Format item text
-		text := item.Label
-		if item.Kind != "" {
-			text = item.Kind + ": " + text
-		}
-		
-		// Truncate if too long
-		if len(text) > popupWidth {
-			text = text[:popupWidth-3] + "..."
-		}
-		
-		// Clear the line
-		for x := 1; x < popupWidth + 1; x++ {
-			screen.SetCell(popupX + x, popupY + i + 1, ' ', style)
-		}
-		
-		// Draw the text
-		screen.SetText(popupX + 2, popupY + i + 1, text, style)
+// initializeEncryption creates the .gpg/.age file handler if encrypted editing is enabled
+func initializeEncryption(cfg *config.Config) *crypt.Handler {
+	enc := cfg.Editor.Encryption
+	if !enc.Enabled {
+		return nil
 	}
-	
-	screen.Show()
+	return crypt.NewHandler(crypt.Options{
+		GPGRecipients: enc.GPGRecipients,
+		AgeIdentity:   enc.AgeIdentity,
+		AgeRecipients: enc.AgeRecipients,
+	})
 }
 
-// updateLSPBuffer sends buffer changes to LSP server
-func updateLSPBuffer(lspManager *lsp.Manager, buf *buffer.Buffer) {
-	if buf.Filename() == "" {
-		return
-	}
-	
-	content := buf.String()
-	ctx := context.Background()
-	
-	// Update the file content in LSP
-	err := lspManager.UpdateFile(ctx, buf.Filename(), content, 1)
-	if err != nil {
-		// Silently ignore LSP update errors for now
-		return
+// promptSecret reads a passphrase from the terminal without echoing it
+func promptSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for %q", strings.TrimSuffix(prompt, ": "))
 	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(secret), err
 }